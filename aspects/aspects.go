@@ -87,6 +87,26 @@ func (e *FieldNotFoundError) Is(err error) bool {
 	return ok
 }
 
+// InvalidAccessError represents a failure to perform a read or write due to
+// the aspect's access control.
+type InvalidAccessError struct {
+	RequestedAccess accessType
+	FieldAccess     accessType
+	Field           string
+}
+
+func (e *InvalidAccessError) Error() string {
+	if e.RequestedAccess == write {
+		return fmt.Sprintf("cannot set field %q: path is not writeable", e.Field)
+	}
+	return fmt.Sprintf("cannot get field %q: path is not readable", e.Field)
+}
+
+func (e *InvalidAccessError) Is(err error) bool {
+	_, ok := err.(*InvalidAccessError)
+	return ok
+}
+
 // DataBag controls access to the aspect data storage.
 type DataBag interface {
 	Get(path string, value interface{}) error
@@ -121,7 +141,7 @@ func NewAspectBundle(name string, aspects map[string]interface{}, schema Schema)
 	}
 
 	for name, v := range aspects {
-		accessPatterns, ok := v.([]map[string]string)
+		accessPatterns, ok := accessPatternMaps(v)
 		if !ok {
 			return nil, fmt.Errorf("cannot define aspect %q: access patterns should be a list of maps", name)
 		} else if len(accessPatterns) == 0 {
@@ -139,6 +159,38 @@ func NewAspectBundle(name string, aspects map[string]interface{}, schema Schema)
 	return aspectBundle, nil
 }
 
+// accessPatternMaps converts the access patterns of an aspect into a list of
+// string maps. The patterns can be either already in that form or be generic
+// decoded data (e.g., from assertion headers), in which case all values must
+// be strings.
+func accessPatternMaps(v interface{}) ([]map[string]string, bool) {
+	switch patterns := v.(type) {
+	case []map[string]string:
+		return patterns, true
+	case []interface{}:
+		accessPatterns := make([]map[string]string, 0, len(patterns))
+		for _, rawPattern := range patterns {
+			rawMap, ok := rawPattern.(map[string]interface{})
+			if !ok {
+				return nil, false
+			}
+
+			pattern := make(map[string]string, len(rawMap))
+			for k, rawVal := range rawMap {
+				val, ok := rawVal.(string)
+				if !ok {
+					return nil, false
+				}
+				pattern[k] = val
+			}
+			accessPatterns = append(accessPatterns, pattern)
+		}
+		return accessPatterns, true
+	default:
+		return nil, false
+	}
+}
+
 func newAspect(bundle *Bundle, name string, aspectPatterns []map[string]string) (*Aspect, error) {
 	aspect := &Aspect{
 		Name:           name,
@@ -256,6 +308,11 @@ func getPlaceholders(aspectStr string) map[string]bool {
 	return placeholders
 }
 
+// Schema returns the schema used to validate the bundle's data.
+func (d *Bundle) Schema() Schema {
+	return d.schema
+}
+
 // Aspect returns an aspect from the aspect bundle.
 func (d *Bundle) Aspect(aspect string) *Aspect {
 	return d.aspects[aspect]
@@ -283,7 +340,7 @@ func (a *Aspect) Set(databag DataBag, name string, value interface{}) error {
 		}
 
 		if !accessPatt.isWriteable() {
-			return fmt.Errorf("cannot set field %q: path is not writeable", name)
+			return &InvalidAccessError{RequestedAccess: write, FieldAccess: accessPatt.access, Field: name}
 		}
 
 		if err := databag.Set(path, value); err != nil {
@@ -317,7 +374,7 @@ func (a *Aspect) Get(databag DataBag, name string, value interface{}) error {
 		}
 
 		if !accessPatt.isReadable() {
-			return fmt.Errorf("cannot get field %q: path is not readable", name)
+			return &InvalidAccessError{RequestedAccess: read, FieldAccess: accessPatt.access, Field: name}
 		}
 
 		if err := databag.Get(path, value); err != nil {
@@ -634,6 +691,37 @@ func (s JSONDataBag) Data() ([]byte, error) {
 	return json.Marshal(s)
 }
 
+// Equal returns whether the databag holds the same data as other.
+func (s JSONDataBag) Equal(other JSONDataBag) (bool, error) {
+	data, err := s.Data()
+	if err != nil {
+		return false, err
+	}
+
+	otherData, err := other.Data()
+	if err != nil {
+		return false, err
+	}
+
+	return bytes.Equal(data, otherData), nil
+}
+
+// copy returns a deep copy of the databag. The raw values are copied as they
+// are, so that no precision is lost on numbers by decoding them.
+func (s JSONDataBag) copy() (JSONDataBag, error) {
+	bag := make(JSONDataBag, len(s))
+	for k, v := range s {
+		if v == nil {
+			bag[k] = nil
+			continue
+		}
+		raw := make(json.RawMessage, len(v))
+		copy(raw, v)
+		bag[k] = raw
+	}
+	return bag, nil
+}
+
 // JSONSchema is the Schema implementation corresponding to JSONDataBag and it's
 // able to validate its data.
 type JSONSchema struct{}
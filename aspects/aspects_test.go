@@ -20,6 +20,7 @@
 package aspects_test
 
 import (
+	"errors"
 	"fmt"
 	"testing"
 
@@ -72,6 +73,29 @@ func (*aspectSuite) TestNewAspectBundle(c *C) {
 	c.Check(aspectBundle, Not(IsNil))
 }
 
+func (s *aspectSuite) TestNewAspectBundleGenericPatterns(c *C) {
+	// access patterns as decoded from assertion headers or JSON
+	aspectBundle, err := aspects.NewAspectBundle("foo", map[string]interface{}{
+		"bar": []interface{}{
+			map[string]interface{}{"name": "a", "path": "b", "access": "read"},
+		},
+	}, aspects.NewJSONSchema())
+	c.Assert(err, IsNil)
+	c.Check(aspectBundle.Aspect("bar"), NotNil)
+
+	_, err = aspects.NewAspectBundle("foo", map[string]interface{}{
+		"bar": []interface{}{"a"},
+	}, aspects.NewJSONSchema())
+	c.Assert(err, ErrorMatches, `cannot define aspect "bar": access patterns should be a list of maps`)
+
+	_, err = aspects.NewAspectBundle("foo", map[string]interface{}{
+		"bar": []interface{}{
+			map[string]interface{}{"name": "a", "path": []interface{}{"b"}},
+		},
+	}, aspects.NewJSONSchema())
+	c.Assert(err, ErrorMatches, `cannot define aspect "bar": access patterns should be a list of maps`)
+}
+
 func (s *aspectSuite) TestAccessTypes(c *C) {
 	type testcase struct {
 		access string
@@ -262,6 +286,7 @@ func (s *aspectSuite) TestAspectsAccessControl(c *C) {
 		err := aspect.Set(databag, t.name, "thing")
 		if t.setErr != "" {
 			c.Assert(err.Error(), Equals, t.setErr, cmt)
+			c.Assert(errors.Is(err, &aspects.InvalidAccessError{}), Equals, true, cmt)
 		} else {
 			c.Assert(err, IsNil, cmt)
 		}
@@ -270,6 +295,7 @@ func (s *aspectSuite) TestAspectsAccessControl(c *C) {
 		err = aspect.Get(databag, t.name, &value)
 		if t.getErr != "" {
 			c.Assert(err.Error(), Equals, t.getErr, cmt)
+			c.Assert(errors.Is(err, &aspects.InvalidAccessError{}), Equals, t.name == "write-only", cmt)
 		} else {
 			c.Assert(err, IsNil, cmt)
 		}
@@ -0,0 +1,797 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package aspects
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/snapcore/snapd/strutil"
+)
+
+// ValidationError represents a failure to validate data against a schema. The
+// Path identifies the offending element through a list of map keys (strings)
+// and array indexes (ints), starting at the top level of the data.
+type ValidationError struct {
+	Path []interface{}
+	Err  error
+}
+
+func (v *ValidationError) Error() string {
+	if len(v.Path) == 0 {
+		return fmt.Sprintf("cannot accept top level element: %v", v.Err)
+	}
+
+	return fmt.Sprintf("cannot accept element in %q: %v", v.PathString(), v.Err)
+}
+
+// PathString returns the path to the offending element in a dotted format
+// (e.g., "foo.bar[1].baz").
+func (v *ValidationError) PathString() string {
+	var sb strings.Builder
+	for _, part := range v.Path {
+		switch p := part.(type) {
+		case string:
+			if sb.Len() > 0 {
+				sb.WriteRune('.')
+			}
+			sb.WriteString(p)
+		case int:
+			sb.WriteString("[" + strconv.Itoa(p) + "]")
+		}
+	}
+	return sb.String()
+}
+
+func (v *ValidationError) Unwrap() error {
+	return v.Err
+}
+
+// validationErrorf returns a ValidationError for the element being validated.
+func validationErrorf(format string, args ...interface{}) error {
+	return &ValidationError{Err: fmt.Errorf(format, args...)}
+}
+
+// withPathPrefix prefixes the path of a ValidationError with the key or index
+// of the element that contained the offending value.
+func withPathPrefix(err error, prefix interface{}) error {
+	var vErr *ValidationError
+	if !errors.As(err, &vErr) {
+		vErr = &ValidationError{Err: err}
+	}
+
+	return &ValidationError{
+		Path: append([]interface{}{prefix}, vErr.Path...),
+		Err:  vErr.Err,
+	}
+}
+
+// schemaNode is a parsed element of a storage schema that can validate the
+// JSON data at its position in the storage.
+type schemaNode interface {
+	Schema
+
+	// parseConstraints parses the constraints of a type defined as a JSON
+	// object (e.g., {"type": "int", "min": 0}).
+	parseConstraints(constraints map[string]json.RawMessage) error
+}
+
+// StorageSchema represents an aspect bundle's storage schema. Schemas are
+// defined in JSON, at the top level they are always maps and can be composed
+// of the following types:
+//   - map: a JSON object, constrained either by a "schema" mapping keys to
+//     types (with optional "required" keys) or by the "keys" and "values" types
+//   - string: with optional "pattern" or "choices" constraints
+//   - int: an integer with optional "min", "max" or "choices" constraints
+//   - number: a number with optional "min", "max" or "choices" constraints
+//   - bool
+//   - array: a JSON array with optional "values" and "unique" constraints
+//   - any: accepts any value
+//
+// Types can be used in their short form (e.g., "int") if no constraints are
+// needed. User-defined types can be declared in the "aliases" map of the top
+// level and referenced as "${alias-name}".
+type StorageSchema struct {
+	topLevel *mapSchema
+}
+
+// ParseSchema parses a JSON storage schema and returns a Schema that can be
+// used to validate data.
+func ParseSchema(raw []byte) (*StorageSchema, error) {
+	var schemaDef map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &schemaDef); err != nil {
+		return nil, fmt.Errorf("cannot parse top level schema as map: %w", err)
+	}
+
+	if rawType, ok := schemaDef["type"]; ok {
+		var typ string
+		if err := json.Unmarshal(rawType, &typ); err != nil {
+			return nil, fmt.Errorf(`cannot parse top level schema's "type" entry: %w`, err)
+		}
+
+		if typ != "map" {
+			return nil, fmt.Errorf(`cannot parse top level schema: unexpected declared type %q, should be "map" or omitted`, typ)
+		}
+	}
+
+	p := &schemaParser{
+		aliases: make(map[string]schemaNode),
+		parsing: make(map[string]bool),
+	}
+
+	if rawAliases, ok := schemaDef["aliases"]; ok {
+		if err := json.Unmarshal(rawAliases, &p.rawAliases); err != nil {
+			return nil, fmt.Errorf(`cannot parse aliases map: %w`, err)
+		}
+
+		// parse all aliases (even unused ones) so errors are reported early
+		names := make([]string, 0, len(p.rawAliases))
+		for name := range p.rawAliases {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			if _, err := p.alias(name); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	topConstraints := make(map[string]json.RawMessage, len(schemaDef))
+	for k, v := range schemaDef {
+		if k == "aliases" {
+			continue
+		}
+		topConstraints[k] = v
+	}
+
+	topLevel := &mapSchema{parser: p}
+	if err := topLevel.parseConstraints(topConstraints); err != nil {
+		return nil, err
+	}
+
+	return &StorageSchema{topLevel: topLevel}, nil
+}
+
+// Validate validates the provided JSON object.
+func (s *StorageSchema) Validate(raw []byte) error {
+	return s.topLevel.Validate(raw)
+}
+
+// schemaParser holds the state required to parse a schema, namely the
+// user-defined types.
+type schemaParser struct {
+	rawAliases map[string]json.RawMessage
+	aliases    map[string]schemaNode
+	// parsing holds the aliases being parsed to detect cyclic definitions
+	parsing map[string]bool
+}
+
+var validAliasName = regexp.MustCompile(fmt.Sprintf("^%s$", subkeyRegex))
+
+// alias returns the parsed type for the named user-defined type, parsing it
+// if necessary.
+func (p *schemaParser) alias(name string) (schemaNode, error) {
+	if node, ok := p.aliases[name]; ok {
+		return node, nil
+	}
+
+	rawAlias, ok := p.rawAliases[name]
+	if !ok {
+		return nil, fmt.Errorf("cannot find type alias %q", name)
+	}
+
+	if !validAliasName.MatchString(name) {
+		return nil, fmt.Errorf("cannot parse alias name %q: must match %s", name, validAliasName)
+	}
+
+	if p.parsing[name] {
+		return nil, fmt.Errorf("cannot parse alias %q: cyclic type definitions are not supported", name)
+	}
+	p.parsing[name] = true
+	defer delete(p.parsing, name)
+
+	node, err := p.parseTypeDefinition(rawAlias)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse alias %q: %w", name, err)
+	}
+
+	p.aliases[name] = node
+	return node, nil
+}
+
+// parseTypeDefinition parses a type either in its short form (e.g., "int")
+// or as a JSON object with a "type" field and constraints.
+func (p *schemaParser) parseTypeDefinition(raw json.RawMessage) (schemaNode, error) {
+	var typ string
+	if err := json.Unmarshal(raw, &typ); err == nil {
+		return p.newTypeSchema(typ)
+	}
+
+	var constraints map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &constraints); err != nil {
+		return nil, fmt.Errorf("cannot parse type definition: type must be expressed as map or string: %s", raw)
+	}
+
+	rawType, ok := constraints["type"]
+	if !ok {
+		return nil, errors.New(`cannot parse type definition: type must be expressed as map or string: map must have a "type" field`)
+	}
+
+	if err := json.Unmarshal(rawType, &typ); err != nil {
+		return nil, fmt.Errorf(`cannot parse "type" field: %w`, err)
+	}
+
+	if isAliasReference(typ) {
+		if len(constraints) > 1 {
+			return nil, fmt.Errorf(`cannot parse type %q: constraints cannot be used with user-defined types`, typ)
+		}
+		return p.newTypeSchema(typ)
+	}
+
+	node, err := p.newTypeSchema(typ)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := node.parseConstraints(constraints); err != nil {
+		return nil, err
+	}
+
+	return node, nil
+}
+
+func isAliasReference(typ string) bool {
+	return strings.HasPrefix(typ, "${") && strings.HasSuffix(typ, "}")
+}
+
+// newTypeSchema returns a schema node for the type without any constraints.
+func (p *schemaParser) newTypeSchema(typ string) (schemaNode, error) {
+	switch typ {
+	case "map":
+		return &mapSchema{parser: p}, nil
+	case "string":
+		return &stringSchema{}, nil
+	case "int":
+		return &intSchema{}, nil
+	case "number":
+		return &numberSchema{}, nil
+	case "bool":
+		return &boolSchema{}, nil
+	case "array":
+		return &arraySchema{parser: p}, nil
+	case "any":
+		return &anySchema{}, nil
+	}
+
+	if isAliasReference(typ) {
+		return p.alias(typ[2 : len(typ)-1])
+	}
+
+	return nil, fmt.Errorf("cannot parse unknown type %q", typ)
+}
+
+// checkConstraintKeys checks that only the allowed constraints were used in
+// a type definition.
+func checkConstraintKeys(typ string, constraints map[string]json.RawMessage, allowed ...string) error {
+	keys := make([]string, 0, len(constraints))
+	for k := range constraints {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		if k == "type" || strutil.ListContains(allowed, k) {
+			continue
+		}
+		return fmt.Errorf(`cannot parse %s: unknown constraint %q`, typ, k)
+	}
+	return nil
+}
+
+// jsonTypeOf returns a description of the type of the JSON value, for use in
+// error messages.
+func jsonTypeOf(raw json.RawMessage) string {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 {
+		return "empty"
+	}
+
+	switch raw[0] {
+	case '{':
+		return "object"
+	case '[':
+		return "array"
+	case '"':
+		return "string"
+	case 't', 'f':
+		return "bool"
+	case 'n':
+		return "null"
+	default:
+		return "number"
+	}
+}
+
+type mapSchema struct {
+	parser *schemaParser
+
+	// entrySchemas maps keys to their types (if "schema" was used)
+	entrySchemas map[string]schemaNode
+	// required holds the keys that must be present (only used with "schema")
+	required []string
+
+	// keySchema and valueSchema validate all entries (if "keys" or "values"
+	// were used). The keys must be string based.
+	keySchema   schemaNode
+	valueSchema schemaNode
+}
+
+func (v *mapSchema) Validate(raw []byte) error {
+	var mapValue map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &mapValue); err != nil || mapValue == nil {
+		return validationErrorf("expected map type but value was %s", jsonTypeOf(raw))
+	}
+
+	if v.entrySchemas != nil {
+		for _, key := range v.required {
+			if _, ok := mapValue[key]; !ok {
+				return validationErrorf("cannot find required key %q", key)
+			}
+		}
+	}
+
+	keys := make([]string, 0, len(mapValue))
+	for k := range mapValue {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		val := mapValue[key]
+
+		if v.entrySchemas != nil {
+			entrySchema, ok := v.entrySchemas[key]
+			if !ok {
+				return validationErrorf("map contains unexpected key %q", key)
+			}
+
+			if err := entrySchema.Validate(val); err != nil {
+				return withPathPrefix(err, key)
+			}
+			continue
+		}
+
+		if v.keySchema != nil {
+			rawKey, err := json.Marshal(key)
+			if err != nil {
+				return err
+			}
+
+			if err := v.keySchema.Validate(rawKey); err != nil {
+				var vErr *ValidationError
+				if errors.As(err, &vErr) {
+					return validationErrorf("key %q is invalid: %v", key, vErr.Err)
+				}
+				return err
+			}
+		}
+
+		if v.valueSchema != nil {
+			if err := v.valueSchema.Validate(val); err != nil {
+				return withPathPrefix(err, key)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (v *mapSchema) parseConstraints(constraints map[string]json.RawMessage) error {
+	if err := checkConstraintKeys("map", constraints, "schema", "required", "keys", "values"); err != nil {
+		return err
+	}
+
+	rawSchema, hasSchema := constraints["schema"]
+	rawKeys, hasKeys := constraints["keys"]
+	rawValues, hasValues := constraints["values"]
+
+	if hasSchema && (hasKeys || hasValues) {
+		return errors.New(`cannot parse map: cannot use "schema" and "keys"/"values" constraints simultaneously`)
+	}
+
+	if hasSchema {
+		var entries map[string]json.RawMessage
+		if err := json.Unmarshal(rawSchema, &entries); err != nil {
+			return fmt.Errorf(`cannot parse map's "schema" constraint: %w`, err)
+		}
+
+		v.entrySchemas = make(map[string]schemaNode, len(entries))
+		for key, rawEntry := range entries {
+			if !validSubkey.MatchString(key) {
+				return fmt.Errorf(`cannot parse map: invalid key %q`, key)
+			}
+
+			entrySchema, err := v.parser.parseTypeDefinition(rawEntry)
+			if err != nil {
+				return fmt.Errorf(`cannot parse schema of key %q: %w`, key, err)
+			}
+			v.entrySchemas[key] = entrySchema
+		}
+	}
+
+	if rawRequired, ok := constraints["required"]; ok {
+		if !hasSchema {
+			return errors.New(`cannot parse map: "required" constraint can only be used with "schema" constraint`)
+		}
+
+		if err := json.Unmarshal(rawRequired, &v.required); err != nil {
+			return fmt.Errorf(`cannot parse map's "required" constraint: %w`, err)
+		}
+
+		for _, key := range v.required {
+			if _, ok := v.entrySchemas[key]; !ok {
+				return fmt.Errorf(`cannot parse map's "required" constraint: required key %q must have schema entry`, key)
+			}
+		}
+	}
+
+	if hasKeys {
+		keySchema, err := v.parser.parseTypeDefinition(rawKeys)
+		if err != nil {
+			return fmt.Errorf(`cannot parse map's "keys" constraint: %w`, err)
+		}
+
+		if _, ok := keySchema.(*stringSchema); !ok {
+			return errors.New(`cannot parse map's "keys" constraint: must be based on string`)
+		}
+		v.keySchema = keySchema
+	}
+
+	if hasValues {
+		valueSchema, err := v.parser.parseTypeDefinition(rawValues)
+		if err != nil {
+			return fmt.Errorf(`cannot parse map's "values" constraint: %w`, err)
+		}
+		v.valueSchema = valueSchema
+	}
+
+	return nil
+}
+
+type stringSchema struct {
+	// pattern is a regexp that the value must match
+	pattern *regexp.Regexp
+	// choices holds the acceptable values
+	choices []string
+}
+
+func (v *stringSchema) Validate(raw []byte) error {
+	var value *string
+	if err := json.Unmarshal(raw, &value); err != nil || value == nil {
+		return validationErrorf("expected string type but value was %s", jsonTypeOf(raw))
+	}
+
+	if len(v.choices) != 0 && !strutil.ListContains(v.choices, *value) {
+		return validationErrorf(`string %q is not one of the allowed choices`, *value)
+	}
+
+	if v.pattern != nil && !v.pattern.MatchString(*value) {
+		return validationErrorf(`string %q doesn't match schema pattern %s`, *value, v.pattern.String())
+	}
+
+	return nil
+}
+
+func (v *stringSchema) parseConstraints(constraints map[string]json.RawMessage) error {
+	if err := checkConstraintKeys("string", constraints, "pattern", "choices"); err != nil {
+		return err
+	}
+
+	rawPattern, hasPattern := constraints["pattern"]
+	rawChoices, hasChoices := constraints["choices"]
+	if hasPattern && hasChoices {
+		return errors.New(`cannot parse string: cannot use "choices" and "pattern" constraints in same schema`)
+	}
+
+	if hasPattern {
+		var patt string
+		if err := json.Unmarshal(rawPattern, &patt); err != nil {
+			return fmt.Errorf(`cannot parse "pattern" constraint: %w`, err)
+		}
+
+		var err error
+		if v.pattern, err = regexp.Compile(patt); err != nil {
+			return fmt.Errorf(`cannot parse "pattern" constraint: %w`, err)
+		}
+	}
+
+	if hasChoices {
+		if err := json.Unmarshal(rawChoices, &v.choices); err != nil {
+			return fmt.Errorf(`cannot parse "choices" constraint: %w`, err)
+		}
+
+		if len(v.choices) == 0 {
+			return errors.New(`cannot parse "choices" constraint: cannot be empty`)
+		}
+	}
+
+	return nil
+}
+
+type intSchema struct {
+	min     *int64
+	max     *int64
+	choices []int64
+}
+
+func (v *intSchema) Validate(raw []byte) error {
+	var num json.Number
+	if err := json.Unmarshal(raw, &num); err != nil || jsonTypeOf(raw) != "number" {
+		return validationErrorf("expected int type but value was %s", jsonTypeOf(raw))
+	}
+
+	value, err := num.Int64()
+	if err != nil {
+		return validationErrorf("expected int type but value was number %s", num)
+	}
+
+	if len(v.choices) != 0 && !intListContains(v.choices, value) {
+		return validationErrorf(`%d is not one of the allowed choices`, value)
+	}
+
+	if v.min != nil && value < *v.min {
+		return validationErrorf(`%d is less than the allowed minimum %d`, value, *v.min)
+	}
+
+	if v.max != nil && value > *v.max {
+		return validationErrorf(`%d is greater than the allowed maximum %d`, value, *v.max)
+	}
+
+	return nil
+}
+
+func intListContains(list []int64, val int64) bool {
+	for _, v := range list {
+		if v == val {
+			return true
+		}
+	}
+	return false
+}
+
+func (v *intSchema) parseConstraints(constraints map[string]json.RawMessage) error {
+	if err := checkConstraintKeys("int", constraints, "min", "max", "choices"); err != nil {
+		return err
+	}
+
+	if rawChoices, ok := constraints["choices"]; ok {
+		if constraints["min"] != nil || constraints["max"] != nil {
+			return errors.New(`cannot parse int: cannot have "choices" and "min"/"max" constraints`)
+		}
+
+		if err := json.Unmarshal(rawChoices, &v.choices); err != nil {
+			return fmt.Errorf(`cannot parse int's "choices" constraint: %w`, err)
+		}
+
+		if len(v.choices) == 0 {
+			return errors.New(`cannot parse int's "choices" constraint: cannot be empty`)
+		}
+	}
+
+	if rawMin, ok := constraints["min"]; ok {
+		if err := json.Unmarshal(rawMin, &v.min); err != nil {
+			return fmt.Errorf(`cannot parse int's "min" constraint: %w`, err)
+		}
+	}
+
+	if rawMax, ok := constraints["max"]; ok {
+		if err := json.Unmarshal(rawMax, &v.max); err != nil {
+			return fmt.Errorf(`cannot parse int's "max" constraint: %w`, err)
+		}
+	}
+
+	if v.min != nil && v.max != nil && *v.min > *v.max {
+		return fmt.Errorf(`cannot parse int: min constraint %d cannot be greater than max %d`, *v.min, *v.max)
+	}
+
+	return nil
+}
+
+type numberSchema struct {
+	min     *float64
+	max     *float64
+	choices []float64
+}
+
+func (v *numberSchema) Validate(raw []byte) error {
+	var value *float64
+	if err := json.Unmarshal(raw, &value); err != nil || value == nil {
+		return validationErrorf("expected number type but value was %s", jsonTypeOf(raw))
+	}
+
+	if len(v.choices) != 0 {
+		var found bool
+		for _, choice := range v.choices {
+			if choice == *value {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return validationErrorf(`%v is not one of the allowed choices`, *value)
+		}
+	}
+
+	if v.min != nil && *value < *v.min {
+		return validationErrorf(`%v is less than the allowed minimum %v`, *value, *v.min)
+	}
+
+	if v.max != nil && *value > *v.max {
+		return validationErrorf(`%v is greater than the allowed maximum %v`, *value, *v.max)
+	}
+
+	return nil
+}
+
+func (v *numberSchema) parseConstraints(constraints map[string]json.RawMessage) error {
+	if err := checkConstraintKeys("number", constraints, "min", "max", "choices"); err != nil {
+		return err
+	}
+
+	if rawChoices, ok := constraints["choices"]; ok {
+		if constraints["min"] != nil || constraints["max"] != nil {
+			return errors.New(`cannot parse number: cannot have "choices" and "min"/"max" constraints`)
+		}
+
+		if err := json.Unmarshal(rawChoices, &v.choices); err != nil {
+			return fmt.Errorf(`cannot parse number's "choices" constraint: %w`, err)
+		}
+
+		if len(v.choices) == 0 {
+			return errors.New(`cannot parse number's "choices" constraint: cannot be empty`)
+		}
+	}
+
+	if rawMin, ok := constraints["min"]; ok {
+		if err := json.Unmarshal(rawMin, &v.min); err != nil {
+			return fmt.Errorf(`cannot parse number's "min" constraint: %w`, err)
+		}
+	}
+
+	if rawMax, ok := constraints["max"]; ok {
+		if err := json.Unmarshal(rawMax, &v.max); err != nil {
+			return fmt.Errorf(`cannot parse number's "max" constraint: %w`, err)
+		}
+	}
+
+	if v.min != nil && v.max != nil && *v.min > *v.max {
+		return fmt.Errorf(`cannot parse number: min constraint %v cannot be greater than max %v`, *v.min, *v.max)
+	}
+
+	for _, bound := range []*float64{v.min, v.max} {
+		if bound != nil && (math.IsNaN(*bound) || math.IsInf(*bound, 0)) {
+			return fmt.Errorf(`cannot parse number: invalid bound %v`, *bound)
+		}
+	}
+
+	return nil
+}
+
+type boolSchema struct{}
+
+func (v *boolSchema) Validate(raw []byte) error {
+	var value *bool
+	if err := json.Unmarshal(raw, &value); err != nil || value == nil {
+		return validationErrorf("expected bool type but value was %s", jsonTypeOf(raw))
+	}
+
+	return nil
+}
+
+func (v *boolSchema) parseConstraints(constraints map[string]json.RawMessage) error {
+	return checkConstraintKeys("bool", constraints)
+}
+
+type arraySchema struct {
+	parser *schemaParser
+
+	// elementType is the type of the array's elements (if unset, any
+	// element is accepted)
+	elementType schemaNode
+	// unique requires the elements to be distinct
+	unique bool
+}
+
+func (v *arraySchema) Validate(raw []byte) error {
+	var array []json.RawMessage
+	if err := json.Unmarshal(raw, &array); err != nil || array == nil {
+		return validationErrorf("expected array type but value was %s", jsonTypeOf(raw))
+	}
+
+	seen := make(map[string]int, len(array))
+	for i, val := range array {
+		if v.elementType != nil {
+			if err := v.elementType.Validate(val); err != nil {
+				return withPathPrefix(err, i)
+			}
+		}
+
+		if v.unique {
+			// compact the value so that equal values are compared equally
+			var buf bytes.Buffer
+			if err := json.Compact(&buf, val); err != nil {
+				return err
+			}
+
+			if j, ok := seen[buf.String()]; ok {
+				return validationErrorf(`cannot accept duplicate values in array with "unique" constraint: elements %d and %d are equal`, j, i)
+			}
+			seen[buf.String()] = i
+		}
+	}
+
+	return nil
+}
+
+func (v *arraySchema) parseConstraints(constraints map[string]json.RawMessage) error {
+	if err := checkConstraintKeys("array", constraints, "values", "unique"); err != nil {
+		return err
+	}
+
+	if rawValues, ok := constraints["values"]; ok {
+		elementType, err := v.parser.parseTypeDefinition(rawValues)
+		if err != nil {
+			return fmt.Errorf(`cannot parse array's "values" constraint: %w`, err)
+		}
+		v.elementType = elementType
+	}
+
+	if rawUnique, ok := constraints["unique"]; ok {
+		if err := json.Unmarshal(rawUnique, &v.unique); err != nil {
+			return fmt.Errorf(`cannot parse array's "unique" constraint: %w`, err)
+		}
+	}
+
+	return nil
+}
+
+type anySchema struct{}
+
+func (v *anySchema) Validate(raw []byte) error {
+	var value interface{}
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return validationErrorf("cannot decode value: %v", err)
+	}
+	return nil
+}
+
+func (v *anySchema) parseConstraints(constraints map[string]json.RawMessage) error {
+	return checkConstraintKeys("any", constraints)
+}
@@ -0,0 +1,405 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package aspects_test
+
+import (
+	"errors"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/aspects"
+)
+
+type schemaSuite struct{}
+
+var _ = Suite(&schemaSuite{})
+
+func (*schemaSuite) TestTopLevelSchema(c *C) {
+	for _, raw := range []string{`{}`, `{"type": "map"}`, `{"schema": {"foo": "any"}}`} {
+		schema, err := aspects.ParseSchema([]byte(raw))
+		c.Assert(err, IsNil, Commentf("schema: %s", raw))
+		c.Check(schema, NotNil)
+	}
+
+	_, err := aspects.ParseSchema([]byte(`"foo"`))
+	c.Assert(err, ErrorMatches, `cannot parse top level schema as map: .*`)
+
+	_, err = aspects.ParseSchema([]byte(`{"type": "string"}`))
+	c.Assert(err, ErrorMatches, `cannot parse top level schema: unexpected declared type "string", should be "map" or omitted`)
+
+	_, err = aspects.ParseSchema([]byte(`{"type": 1}`))
+	c.Assert(err, ErrorMatches, `cannot parse top level schema's "type" entry: .*`)
+
+	schema, err := aspects.ParseSchema([]byte(`{}`))
+	c.Assert(err, IsNil)
+	err = schema.Validate([]byte(`[]`))
+	c.Assert(err, ErrorMatches, `cannot accept top level element: expected map type but value was array`)
+}
+
+func (*schemaSuite) TestParseInvalidTypes(c *C) {
+	type testcase struct {
+		schema string
+		err    string
+	}
+
+	for _, tc := range []testcase{
+		{
+			schema: `{"schema": {"foo": "bar"}}`,
+			err:    `cannot parse schema of key "foo": cannot parse unknown type "bar"`,
+		},
+		{
+			schema: `{"schema": {"foo": 1}}`,
+			err:    `cannot parse schema of key "foo": cannot parse type definition: type must be expressed as map or string: 1`,
+		},
+		{
+			schema: `{"schema": {"foo": {"min": 1}}}`,
+			err:    `cannot parse schema of key "foo": cannot parse type definition: type must be expressed as map or string: map must have a "type" field`,
+		},
+		{
+			schema: `{"schema": {"foo": {"type": "int", "pattern": "a"}}}`,
+			err:    `cannot parse schema of key "foo": cannot parse int: unknown constraint "pattern"`,
+		},
+		{
+			schema: `{"schema": {"Foo": "int"}}`,
+			err:    `cannot parse map: invalid key "Foo"`,
+		},
+		{
+			schema: `{"schema": {"foo": "int"}, "values": "int"}`,
+			err:    `cannot parse map: cannot use "schema" and "keys"/"values" constraints simultaneously`,
+		},
+		{
+			schema: `{"values": "int", "required": ["foo"]}`,
+			err:    `cannot parse map: "required" constraint can only be used with "schema" constraint`,
+		},
+		{
+			schema: `{"schema": {"foo": "int"}, "required": ["bar"]}`,
+			err:    `cannot parse map's "required" constraint: required key "bar" must have schema entry`,
+		},
+		{
+			schema: `{"keys": "int"}`,
+			err:    `cannot parse map's "keys" constraint: must be based on string`,
+		},
+		{
+			schema: `{"schema": {"foo": {"type": "string", "pattern": "[a", "choices": ["a"]}}}`,
+			err:    `cannot parse schema of key "foo": cannot parse string: cannot use "choices" and "pattern" constraints in same schema`,
+		},
+		{
+			schema: `{"schema": {"foo": {"type": "string", "pattern": "[a"}}}`,
+			err:    `cannot parse schema of key "foo": cannot parse "pattern" constraint: .*`,
+		},
+		{
+			schema: `{"schema": {"foo": {"type": "string", "choices": []}}}`,
+			err:    `cannot parse schema of key "foo": cannot parse "choices" constraint: cannot be empty`,
+		},
+		{
+			schema: `{"schema": {"foo": {"type": "int", "min": 2, "max": 1}}}`,
+			err:    `cannot parse schema of key "foo": cannot parse int: min constraint 2 cannot be greater than max 1`,
+		},
+		{
+			schema: `{"schema": {"foo": {"type": "int", "min": 1.5}}}`,
+			err:    `cannot parse schema of key "foo": cannot parse int's "min" constraint: .*`,
+		},
+		{
+			schema: `{"schema": {"foo": {"type": "int", "choices": [1], "max": 1}}}`,
+			err:    `cannot parse schema of key "foo": cannot parse int: cannot have "choices" and "min"/"max" constraints`,
+		},
+		{
+			schema: `{"schema": {"foo": {"type": "number", "min": 2.5, "max": 1.5}}}`,
+			err:    `cannot parse schema of key "foo": cannot parse number: min constraint 2.5 cannot be greater than max 1.5`,
+		},
+		{
+			schema: `{"schema": {"foo": {"type": "array", "values": "foo"}}}`,
+			err:    `cannot parse schema of key "foo": cannot parse array's "values" constraint: cannot parse unknown type "foo"`,
+		},
+		{
+			schema: `{"schema": {"foo": {"type": "array", "unique": "yes"}}}`,
+			err:    `cannot parse schema of key "foo": cannot parse array's "unique" constraint: .*`,
+		},
+		{
+			schema: `{"schema": {"foo": {"type": "bool", "min": 1}}}`,
+			err:    `cannot parse schema of key "foo": cannot parse bool: unknown constraint "min"`,
+		},
+	} {
+		_, err := aspects.ParseSchema([]byte(tc.schema))
+		c.Check(err, ErrorMatches, tc.err, Commentf("schema: %s", tc.schema))
+	}
+}
+
+func (*schemaSuite) TestValidateMapSchema(c *C) {
+	schema, err := aspects.ParseSchema([]byte(`{
+	"schema": {
+		"foo": {
+			"type": "map",
+			"schema": {
+				"bar": "string",
+				"baz": "int"
+			},
+			"required": ["bar"]
+		},
+		"other": {
+			"type": "map",
+			"keys": {
+				"type": "string",
+				"pattern": "^[a-z]+$"
+			},
+			"values": "bool"
+		}
+	}
+}`))
+	c.Assert(err, IsNil)
+
+	type testcase struct {
+		data string
+		err  string
+	}
+
+	for _, tc := range []testcase{
+		{data: `{}`},
+		{data: `{"foo": {"bar": "a", "baz": 1}, "other": {"abc": true}}`},
+		{data: `{"foo": {"baz": 1}}`, err: `cannot accept element in "foo": cannot find required key "bar"`},
+		{data: `{"foo": {"bar": "a", "other": 1}}`, err: `cannot accept element in "foo": map contains unexpected key "other"`},
+		{data: `{"foo": {"bar": 1}}`, err: `cannot accept element in "foo.bar": expected string type but value was number`},
+		{data: `{"foo": "a"}`, err: `cannot accept element in "foo": expected map type but value was string`},
+		{data: `{"other": {"ABC": true}}`, err: `cannot accept element in "other": key "ABC" is invalid: string "ABC" doesn't match schema pattern .*`},
+		{data: `{"other": {"abc": "true"}}`, err: `cannot accept element in "other.abc": expected bool type but value was string`},
+		{data: `{"bar": 1}`, err: `cannot accept top level element: map contains unexpected key "bar"`},
+	} {
+		err := schema.Validate([]byte(tc.data))
+		if tc.err == "" {
+			c.Check(err, IsNil, Commentf("data: %s", tc.data))
+		} else {
+			c.Check(err, ErrorMatches, tc.err, Commentf("data: %s", tc.data))
+		}
+	}
+}
+
+func (*schemaSuite) TestValidateScalars(c *C) {
+	schema, err := aspects.ParseSchema([]byte(`{
+	"schema": {
+		"str": "string",
+		"pattern": {"type": "string", "pattern": "^[0-9]+$"},
+		"str-choices": {"type": "string", "choices": ["a", "b"]},
+		"int": "int",
+		"int-range": {"type": "int", "min": 0, "max": 10},
+		"int-choices": {"type": "int", "choices": [1, 3]},
+		"num": "number",
+		"num-range": {"type": "number", "min": 0.5, "max": 1.5},
+		"num-choices": {"type": "number", "choices": [0.5, 1]},
+		"bool": "bool",
+		"any": "any"
+	}
+}`))
+	c.Assert(err, IsNil)
+
+	type testcase struct {
+		data string
+		err  string
+	}
+
+	for _, tc := range []testcase{
+		{data: `{"str": "foo"}`},
+		{data: `{"str": 1}`, err: `cannot accept element in "str": expected string type but value was number`},
+		{data: `{"str": null}`, err: `cannot accept element in "str": expected string type but value was null`},
+		{data: `{"pattern": "123"}`},
+		{data: `{"pattern": "12a"}`, err: `cannot accept element in "pattern": string "12a" doesn't match schema pattern .*`},
+		{data: `{"str-choices": "a"}`},
+		{data: `{"str-choices": "c"}`, err: `cannot accept element in "str-choices": string "c" is not one of the allowed choices`},
+		{data: `{"int": -5}`},
+		{data: `{"int": 1.5}`, err: `cannot accept element in "int": expected int type but value was number 1.5`},
+		{data: `{"int": "1"}`, err: `cannot accept element in "int": expected int type but value was string`},
+		{data: `{"int-range": 10}`},
+		{data: `{"int-range": -1}`, err: `cannot accept element in "int-range": -1 is less than the allowed minimum 0`},
+		{data: `{"int-range": 11}`, err: `cannot accept element in "int-range": 11 is greater than the allowed maximum 10`},
+		{data: `{"int-choices": 3}`},
+		{data: `{"int-choices": 2}`, err: `cannot accept element in "int-choices": 2 is not one of the allowed choices`},
+		{data: `{"num": 1.2}`},
+		{data: `{"num": true}`, err: `cannot accept element in "num": expected number type but value was bool`},
+		{data: `{"num-range": 0.4}`, err: `cannot accept element in "num-range": 0.4 is less than the allowed minimum 0.5`},
+		{data: `{"num-range": 1.6}`, err: `cannot accept element in "num-range": 1.6 is greater than the allowed maximum 1.5`},
+		{data: `{"num-choices": 1}`},
+		{data: `{"num-choices": 2}`, err: `cannot accept element in "num-choices": 2 is not one of the allowed choices`},
+		{data: `{"bool": false}`},
+		{data: `{"bool": "false"}`, err: `cannot accept element in "bool": expected bool type but value was string`},
+		{data: `{"any": {"a": [1, "b"]}}`},
+	} {
+		err := schema.Validate([]byte(tc.data))
+		if tc.err == "" {
+			c.Check(err, IsNil, Commentf("data: %s", tc.data))
+		} else {
+			c.Check(err, ErrorMatches, tc.err, Commentf("data: %s", tc.data))
+		}
+	}
+}
+
+func (*schemaSuite) TestValidateArray(c *C) {
+	schema, err := aspects.ParseSchema([]byte(`{
+	"schema": {
+		"list": {
+			"type": "array",
+			"values": {
+				"type": "map",
+				"schema": {"name": "string"}
+			}
+		},
+		"set": {
+			"type": "array",
+			"values": "int",
+			"unique": true
+		},
+		"anything": "array"
+	}
+}`))
+	c.Assert(err, IsNil)
+
+	type testcase struct {
+		data string
+		err  string
+	}
+
+	for _, tc := range []testcase{
+		{data: `{"list": [{"name": "a"}, {"name": "b"}]}`},
+		{data: `{"list": [{"name": "a"}, {"name": 1}]}`, err: `cannot accept element in "list\[1\].name": expected string type but value was number`},
+		{data: `{"list": {"name": "a"}}`, err: `cannot accept element in "list": expected array type but value was object`},
+		{data: `{"set": [1, 2, 3]}`},
+		{data: `{"set": [1, 2, 1]}`, err: `cannot accept element in "set": cannot accept duplicate values in array with "unique" constraint: elements 0 and 2 are equal`},
+		{data: `{"anything": [1, "a", {}]}`},
+	} {
+		err := schema.Validate([]byte(tc.data))
+		if tc.err == "" {
+			c.Check(err, IsNil, Commentf("data: %s", tc.data))
+		} else {
+			c.Check(err, ErrorMatches, tc.err, Commentf("data: %s", tc.data))
+		}
+	}
+}
+
+func (*schemaSuite) TestAliases(c *C) {
+	schema, err := aspects.ParseSchema([]byte(`{
+	"aliases": {
+		"ssid": {
+			"type": "string",
+			"pattern": "^[a-z]+$"
+		},
+		"network": {
+			"type": "map",
+			"schema": {
+				"ssid": "${ssid}",
+				"priority": {"type": "int", "min": 0}
+			},
+			"required": ["ssid"]
+		}
+	},
+	"schema": {
+		"networks": {
+			"type": "array",
+			"values": "${network}"
+		},
+		"by-ssid": {
+			"type": "map",
+			"keys": "${ssid}",
+			"values": "${network}"
+		}
+	}
+}`))
+	c.Assert(err, IsNil)
+
+	err = schema.Validate([]byte(`{"networks": [{"ssid": "foo", "priority": 1}], "by-ssid": {"foo": {"ssid": "foo"}}}`))
+	c.Assert(err, IsNil)
+
+	err = schema.Validate([]byte(`{"networks": [{"ssid": "foo"}, {"ssid": "Foo"}]}`))
+	c.Assert(err, ErrorMatches, `cannot accept element in "networks\[1\].ssid": string "Foo" doesn't match schema pattern .*`)
+
+	err = schema.Validate([]byte(`{"by-ssid": {"foo": {"priority": -1, "ssid": "foo"}}}`))
+	c.Assert(err, ErrorMatches, `cannot accept element in "by-ssid.foo.priority": -1 is less than the allowed minimum 0`)
+
+	var vErr *aspects.ValidationError
+	c.Assert(errors.As(err, &vErr), Equals, true)
+	c.Check(vErr.Path, DeepEquals, []interface{}{"by-ssid", "foo", "priority"})
+	c.Check(vErr.PathString(), Equals, "by-ssid.foo.priority")
+}
+
+func (*schemaSuite) TestInvalidAliases(c *C) {
+	type testcase struct {
+		schema string
+		err    string
+	}
+
+	for _, tc := range []testcase{
+		{
+			schema: `{"schema": {"foo": "${bar}"}}`,
+			err:    `cannot parse schema of key "foo": cannot find type alias "bar"`,
+		},
+		{
+			schema: `{"aliases": "foo"}`,
+			err:    `cannot parse aliases map: .*`,
+		},
+		{
+			schema: `{"aliases": {"Foo": "int"}}`,
+			err:    `cannot parse alias name "Foo": must match .*`,
+		},
+		{
+			schema: `{"aliases": {"foo": "bar"}}`,
+			err:    `cannot parse alias "foo": cannot parse unknown type "bar"`,
+		},
+		{
+			schema: `{"aliases": {"foo": "${bar}", "bar": "${foo}"}}`,
+			err:    `cannot parse alias "bar": cannot parse alias "foo": cannot parse alias "bar": cyclic type definitions are not supported`,
+		},
+		{
+			schema: `{"aliases": {"foo": "int"}, "schema": {"bar": {"type": "${foo}", "min": 1}}}`,
+			err:    `cannot parse schema of key "bar": cannot parse type "\${foo}": constraints cannot be used with user-defined types`,
+		},
+	} {
+		_, err := aspects.ParseSchema([]byte(tc.schema))
+		c.Check(err, ErrorMatches, tc.err, Commentf("schema: %s", tc.schema))
+	}
+}
+
+func (*schemaSuite) TestAspectSetEnforcesSchema(c *C) {
+	schema, err := aspects.ParseSchema([]byte(`{
+	"schema": {
+		"wifi": {
+			"type": "map",
+			"schema": {
+				"ssid": "string",
+				"channel": {"type": "int", "min": 1, "max": 14}
+			}
+		}
+	}
+}`))
+	c.Assert(err, IsNil)
+
+	aspectBundle, err := aspects.NewAspectBundle("network", map[string]interface{}{
+		"wifi-setup": []map[string]string{
+			{"name": "ssid", "path": "wifi.ssid"},
+			{"name": "channel", "path": "wifi.channel"},
+		},
+	}, schema)
+	c.Assert(err, IsNil)
+
+	asp := aspectBundle.Aspect("wifi-setup")
+	databag := aspects.NewJSONDataBag()
+
+	c.Assert(asp.Set(databag, "ssid", "foo"), IsNil)
+	c.Assert(asp.Set(databag, "channel", 6), IsNil)
+
+	err = asp.Set(databag, "channel", 20)
+	c.Assert(err, ErrorMatches, `cannot accept element in "wifi.channel": 20 is greater than the allowed maximum 14`)
+}
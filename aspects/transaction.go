@@ -0,0 +1,230 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package aspects
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync"
+
+	"github.com/snapcore/snapd/jsonutil"
+)
+
+// delta is a pending write to a databag path. A nil value unsets the path.
+type delta struct {
+	Path  string      `json:"path"`
+	Value interface{} `json:"value"`
+}
+
+// Transaction performs reads and writes to a databag in an atomic way. Writes
+// are recorded on top of the pristine databag and are only visible to readers
+// of the transaction until it's committed.
+type Transaction struct {
+	mu       sync.RWMutex
+	pristine JSONDataBag
+	deltas   []delta
+
+	// modified caches the pristine databag with the deltas applied
+	modified JSONDataBag
+}
+
+// NewTransaction returns a transaction on top of the given databag, which is
+// not modified by the transaction.
+func NewTransaction(databag JSONDataBag) (*Transaction, error) {
+	if databag == nil {
+		databag = NewJSONDataBag()
+	}
+
+	pristine, err := databag.copy()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Transaction{pristine: pristine}, nil
+}
+
+// Set records a write of value to the path. The write is only visible through
+// the transaction until it is committed.
+func (t *Transaction) Set(path string, value interface{}) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	modified, err := t.applied()
+	if err != nil {
+		return err
+	}
+
+	if err := modified.Set(path, value); err != nil {
+		return err
+	}
+
+	t.deltas = append(t.deltas, delta{Path: path, Value: value})
+	return nil
+}
+
+// Get reads the value at path, taking into account the pending writes.
+func (t *Transaction) Get(path string, value interface{}) error {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	modified, err := t.appliedReadOnly()
+	if err != nil {
+		return err
+	}
+
+	return modified.Get(path, value)
+}
+
+// Data returns the transaction's data, with the pending writes applied,
+// encoded in JSON.
+func (t *Transaction) Data() ([]byte, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	modified, err := t.appliedReadOnly()
+	if err != nil {
+		return nil, err
+	}
+
+	return modified.Data()
+}
+
+// Pristine returns the databag the transaction was started from.
+func (t *Transaction) Pristine() JSONDataBag {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	pristine, _ := t.pristine.copy()
+	return pristine
+}
+
+// Modified returns whether the transaction has pending writes.
+func (t *Transaction) Modified() bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	return len(t.deltas) > 0
+}
+
+// Clear discards all the pending writes, which rolls back the transaction to
+// its pristine state.
+func (t *Transaction) Clear() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.deltas = nil
+	t.modified = nil
+}
+
+// Commit validates the transaction's data against the schema and returns
+// the resulting databag, which is meant to replace the pristine one.
+func (t *Transaction) Commit(schema Schema) (JSONDataBag, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	modified, err := t.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := modified.Data()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := schema.Validate(data); err != nil {
+		return nil, err
+	}
+
+	return modified.copy()
+}
+
+// applied returns the pristine databag with the deltas applied. It must be
+// called with the write lock held.
+func (t *Transaction) applied() (JSONDataBag, error) {
+	if t.modified != nil {
+		return t.modified, nil
+	}
+
+	modified, err := t.appliedReadOnly()
+	if err != nil {
+		return nil, err
+	}
+
+	t.modified = modified
+	return modified, nil
+}
+
+// appliedReadOnly is like applied but doesn't cache the result, so it can be
+// called with only the read lock held.
+func (t *Transaction) appliedReadOnly() (JSONDataBag, error) {
+	if t.modified != nil {
+		return t.modified, nil
+	}
+
+	modified, err := t.pristine.copy()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, d := range t.deltas {
+		if err := modified.Set(d.Path, d.Value); err != nil {
+			return nil, err
+		}
+	}
+
+	return modified, nil
+}
+
+type transactionJSON struct {
+	Pristine JSONDataBag `json:"pristine"`
+	Deltas   []delta     `json:"deltas,omitempty"`
+}
+
+// MarshalJSON implements json.Marshaler so that a transaction can be kept in
+// the state across the tasks of a change.
+func (t *Transaction) MarshalJSON() ([]byte, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	return json.Marshal(transactionJSON{
+		Pristine: t.pristine,
+		Deltas:   t.deltas,
+	})
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (t *Transaction) UnmarshalJSON(data []byte) error {
+	var txJSON transactionJSON
+	if err := jsonutil.DecodeWithNumber(bytes.NewReader(data), &txJSON); err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.pristine = txJSON.Pristine
+	if t.pristine == nil {
+		t.pristine = NewJSONDataBag()
+	}
+	t.deltas = txJSON.Deltas
+	t.modified = nil
+	return nil
+}
@@ -0,0 +1,183 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package aspects_test
+
+import (
+	"encoding/json"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/aspects"
+)
+
+type transactionSuite struct{}
+
+var _ = Suite(&transactionSuite{})
+
+func (s *transactionSuite) TestSetAndGet(c *C) {
+	databag := aspects.NewJSONDataBag()
+	c.Assert(databag.Set("foo", "bar"), IsNil)
+
+	tx, err := aspects.NewTransaction(databag)
+	c.Assert(err, IsNil)
+	c.Check(tx.Modified(), Equals, false)
+
+	c.Assert(tx.Set("foo", "baz"), IsNil)
+	c.Assert(tx.Set("other.nested", 1), IsNil)
+	c.Check(tx.Modified(), Equals, true)
+
+	var value string
+	c.Assert(tx.Get("foo", &value), IsNil)
+	c.Check(value, Equals, "baz")
+
+	var nested int
+	c.Assert(tx.Get("other.nested", &nested), IsNil)
+	c.Check(nested, Equals, 1)
+
+	// the original databag and the pristine data are left untouched
+	c.Assert(databag.Get("foo", &value), IsNil)
+	c.Check(value, Equals, "bar")
+	c.Assert(tx.Pristine().Get("foo", &value), IsNil)
+	c.Check(value, Equals, "bar")
+
+	data, err := tx.Data()
+	c.Assert(err, IsNil)
+	c.Check(string(data), Equals, `{"foo":"baz","other":{"nested":1}}`)
+}
+
+func (s *transactionSuite) TestNilDatabag(c *C) {
+	tx, err := aspects.NewTransaction(nil)
+	c.Assert(err, IsNil)
+
+	var value interface{}
+	c.Assert(tx.Get("foo", &value), FitsTypeOf, &aspects.FieldNotFoundError{})
+
+	c.Assert(tx.Set("foo", "bar"), IsNil)
+	c.Assert(tx.Get("foo", &value), IsNil)
+	c.Check(value, Equals, "bar")
+}
+
+func (s *transactionSuite) TestUnset(c *C) {
+	databag := aspects.NewJSONDataBag()
+	c.Assert(databag.Set("foo", "bar"), IsNil)
+
+	tx, err := aspects.NewTransaction(databag)
+	c.Assert(err, IsNil)
+	c.Assert(tx.Set("foo", nil), IsNil)
+
+	var value string
+	c.Assert(tx.Get("foo", &value), FitsTypeOf, &aspects.FieldNotFoundError{})
+}
+
+func (s *transactionSuite) TestClear(c *C) {
+	tx, err := aspects.NewTransaction(nil)
+	c.Assert(err, IsNil)
+	c.Assert(tx.Set("foo", "bar"), IsNil)
+
+	tx.Clear()
+	c.Check(tx.Modified(), Equals, false)
+
+	var value string
+	c.Assert(tx.Get("foo", &value), FitsTypeOf, &aspects.FieldNotFoundError{})
+}
+
+func (s *transactionSuite) TestCommit(c *C) {
+	schema, err := aspects.ParseSchema([]byte(`{"schema": {"foo": "string"}}`))
+	c.Assert(err, IsNil)
+
+	tx, err := aspects.NewTransaction(nil)
+	c.Assert(err, IsNil)
+	c.Assert(tx.Set("foo", "bar"), IsNil)
+
+	databag, err := tx.Commit(schema)
+	c.Assert(err, IsNil)
+
+	var value string
+	c.Assert(databag.Get("foo", &value), IsNil)
+	c.Check(value, Equals, "bar")
+
+	// the committed databag doesn't share data with the transaction
+	c.Assert(tx.Set("foo", "baz"), IsNil)
+	c.Assert(databag.Get("foo", &value), IsNil)
+	c.Check(value, Equals, "bar")
+}
+
+func (s *transactionSuite) TestCommitInvalid(c *C) {
+	schema, err := aspects.ParseSchema([]byte(`{"schema": {"foo": "string"}}`))
+	c.Assert(err, IsNil)
+
+	tx, err := aspects.NewTransaction(nil)
+	c.Assert(err, IsNil)
+	c.Assert(tx.Set("foo", 1), IsNil)
+
+	_, err = tx.Commit(schema)
+	c.Assert(err, ErrorMatches, `cannot accept element in "foo": expected string type but value was number`)
+}
+
+func (s *transactionSuite) TestMarshalJSON(c *C) {
+	databag := aspects.NewJSONDataBag()
+	c.Assert(databag.Set("foo", "bar"), IsNil)
+
+	tx, err := aspects.NewTransaction(databag)
+	c.Assert(err, IsNil)
+	c.Assert(tx.Set("foo", "baz"), IsNil)
+	c.Assert(tx.Set("num", 1), IsNil)
+
+	data, err := json.Marshal(tx)
+	c.Assert(err, IsNil)
+	c.Check(string(data), Equals, `{"pristine":{"foo":"bar"},"deltas":[{"path":"foo","value":"baz"},{"path":"num","value":1}]}`)
+
+	var otherTx aspects.Transaction
+	c.Assert(json.Unmarshal(data, &otherTx), IsNil)
+
+	var value string
+	c.Assert(otherTx.Get("foo", &value), IsNil)
+	c.Check(value, Equals, "baz")
+	c.Assert(otherTx.Pristine().Get("foo", &value), IsNil)
+	c.Check(value, Equals, "bar")
+
+	var num int
+	c.Assert(otherTx.Get("num", &num), IsNil)
+	c.Check(num, Equals, 1)
+}
+
+func (s *transactionSuite) TestLargeNumbersKeepPrecision(c *C) {
+	databag := aspects.NewJSONDataBag()
+	c.Assert(databag.Set("foo", json.Number("9007199254740993")), IsNil)
+
+	tx, err := aspects.NewTransaction(databag)
+	c.Assert(err, IsNil)
+	c.Assert(tx.Set("bar", json.Number("9007199254740995")), IsNil)
+
+	committed, err := tx.Commit(aspects.NewJSONSchema())
+	c.Assert(err, IsNil)
+
+	var value uint64
+	c.Assert(tx.Pristine().Get("foo", &value), IsNil)
+	c.Check(value, Equals, uint64(9007199254740993))
+	c.Assert(committed.Get("foo", &value), IsNil)
+	c.Check(value, Equals, uint64(9007199254740993))
+	c.Assert(committed.Get("bar", &value), IsNil)
+	c.Check(value, Equals, uint64(9007199254740995))
+
+	data, err := committed.Data()
+	c.Assert(err, IsNil)
+	c.Check(string(data), Equals, `{"bar":9007199254740995,"foo":9007199254740993}`)
+}
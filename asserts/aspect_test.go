@@ -0,0 +1,194 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package asserts_test
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/aspects"
+	"github.com/snapcore/snapd/asserts"
+)
+
+type aspectBundleSuite struct {
+	ts     time.Time
+	tsLine string
+}
+
+var _ = Suite(&aspectBundleSuite{})
+
+func (s *aspectBundleSuite) SetUpSuite(c *C) {
+	s.ts = time.Now().Truncate(time.Second).UTC()
+	s.tsLine = "timestamp: " + s.ts.Format(time.RFC3339) + "\n"
+}
+
+const (
+	aspectBundleExample = `type: aspect-bundle
+authority-id: brand-id1
+account-id: brand-id1
+name: my-network
+summary: aspect-bundle description
+aspects:
+  wifi-setup:
+    -
+      name: ssids
+      path: wifi.ssids
+    -
+      name: ssid
+      path: wifi.ssid
+      access: read-write
+    -
+      name: password
+      path: wifi.psk
+      access: write
+    -
+      name: status
+      path: wifi.status
+      access: read
+    -
+      name: private.{placeholder}
+      path: wifi.{placeholder}
+` + "TSLINE" +
+		"body-length: BODYLEN\n" +
+		"sign-key-sha3-384: Jv8_JiHiIzJVcO9M55pPdqSDWUvuhfDIBJUS-3VW7F_idjix7Ffn5qMxB21ZQuij" +
+		"\n\n" +
+		"BODY" +
+		"\n\n" +
+		"AXNpZw=="
+
+	aspectBundleBody = `{
+  "storage": {
+    "aliases": {
+      "status": {
+        "type": "string",
+        "choices": ["online", "offline"]
+      }
+    },
+    "schema": {
+      "wifi": {
+        "type": "map",
+        "schema": {
+          "ssids": {
+            "type": "array",
+            "values": "string"
+          },
+          "ssid": "string",
+          "psk": "string",
+          "status": "${status}"
+        }
+      }
+    }
+  }
+}`
+)
+
+func (s *aspectBundleSuite) encode(headers, body string) string {
+	encoded := strings.Replace(headers, "TSLINE", s.tsLine, 1)
+	encoded = strings.Replace(encoded, "BODYLEN", fmt.Sprint(len(body)), 1)
+	if body == "" {
+		return strings.Replace(encoded, "BODY\n\n", "", 1)
+	}
+	return strings.Replace(encoded, "BODY", body, 1)
+}
+
+func (s *aspectBundleSuite) TestDecodeOK(c *C) {
+	encoded := s.encode(aspectBundleExample, aspectBundleBody)
+
+	a, err := asserts.Decode([]byte(encoded))
+	c.Assert(err, IsNil)
+	c.Check(a, NotNil)
+	c.Check(a.Type(), Equals, asserts.AspectBundleType)
+	ab := a.(*asserts.AspectBundle)
+	c.Check(ab.AuthorityID(), Equals, "brand-id1")
+	c.Check(ab.AccountID(), Equals, "brand-id1")
+	c.Check(ab.Name(), Equals, "my-network")
+	c.Check(ab.Timestamp(), Equals, s.ts)
+
+	bundle := ab.Bundle()
+	c.Assert(bundle, NotNil)
+	c.Check(bundle.Name, Equals, "my-network")
+	asp := bundle.Aspect("wifi-setup")
+	c.Assert(asp, NotNil)
+
+	databag := aspects.NewJSONDataBag()
+	c.Assert(asp.Set(databag, "ssid", "my-ssid"), IsNil)
+	var ssid string
+	c.Assert(asp.Get(databag, "ssid", &ssid), IsNil)
+	c.Check(ssid, Equals, "my-ssid")
+
+	c.Check(asp.Set(databag, "status", "online"), ErrorMatches, `cannot set field "status": path is not writeable`)
+
+	// the storage schema from the body is enforced
+	err = asp.Set(databag, "ssid", 1)
+	c.Check(err, ErrorMatches, `cannot accept element in "wifi.ssid": expected string type but value was number`)
+	err = asp.Set(databag, "private.foo", "bar")
+	c.Check(err, ErrorMatches, `cannot accept element in "wifi": map contains unexpected key "foo"`)
+}
+
+func (s *aspectBundleSuite) TestDecodeInvalid(c *C) {
+	const errPrefix = "assertion aspect-bundle: "
+
+	aspectsStanza := aspectBundleExample[strings.Index(aspectBundleExample, "aspects:"):strings.Index(aspectBundleExample, "TSLINE")]
+
+	invalidTests := []struct{ original, invalid, expectedErr string }{
+		{"account-id: brand-id1\n", "", `"account-id" header is mandatory`},
+		{"account-id: brand-id1\n", "account-id: \n", `"account-id" header should not be empty`},
+		{"account-id: brand-id1\n", "account-id: random\n", `authority-id and account-id must match, aspect-bundle assertions are expected to be signed by the issuer account: "brand-id1" != "random"`},
+		{"name: my-network\n", "", `"name" header is mandatory`},
+		{"name: my-network\n", "name: \n", `"name" header should not be empty`},
+		{"name: my-network\n", "name: my/network\n", `"name" primary key header cannot contain '/'`},
+		{"name: my-network\n", "name: my+network\n", `"name" header contains invalid characters: "my\+network"`},
+		{"summary: aspect-bundle description\n", "summary:\n  - foo\n", `"summary" header must be a string`},
+		{aspectsStanza, "", `"aspects" stanza is mandatory`},
+		{aspectsStanza, "aspects: foo\n", `"aspects" header must be a map`},
+		{aspectsStanza, "aspects:\n  foo: bar\n", `cannot define aspect "foo": access patterns should be a list of maps`},
+		{"      name: ssids\n", "      foo: ssids\n", `cannot define aspect "wifi-setup": access patterns must have a "name" field`},
+		{"      access: write\n", "      access: foo\n", `cannot define aspect "wifi-setup": cannot create aspect pattern: expected 'access' to be one of "read-write", "read", "write" but was "foo"`},
+		{"TSLINE", "timestamp: 12:30\n", `"timestamp" header is not a RFC3339 date: .*`},
+	}
+
+	for _, test := range invalidTests {
+		invalid := strings.Replace(aspectBundleExample, test.original, test.invalid, 1)
+		invalid = s.encode(invalid, aspectBundleBody)
+		_, err := asserts.Decode([]byte(invalid))
+		c.Check(err, ErrorMatches, errPrefix+test.expectedErr)
+	}
+}
+
+func (s *aspectBundleSuite) TestDecodeInvalidBody(c *C) {
+	const errPrefix = "assertion aspect-bundle: "
+
+	invalidTests := []struct{ body, expectedErr string }{
+		{"", `body must contain JSON`},
+		{"foo", `invalid JSON in body: .*`},
+		{`{"other": {}}`, `body must contain a "storage" stanza`},
+		{`{"storage": "foo"}`, `invalid schema: cannot parse top level schema as map: .*`},
+		{`{"storage": {"schema": {"foo": "bar"}}}`, `invalid schema: cannot parse schema of key "foo": cannot parse unknown type "bar"`},
+	}
+
+	for _, test := range invalidTests {
+		invalid := s.encode(aspectBundleExample, test.body)
+		_, err := asserts.Decode([]byte(invalid))
+		c.Check(err, ErrorMatches, errPrefix+test.expectedErr)
+	}
+}
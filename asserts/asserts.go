@@ -138,6 +138,7 @@ var (
 	ValidationSetType   = &AssertionType{"validation-set", []string{"series", "account-id", "name", "sequence"}, nil, assembleValidationSet, sequenceForming}
 	StoreType           = &AssertionType{"store", []string{"store"}, nil, assembleStore, 0}
 	PreseedType         = &AssertionType{"preseed", []string{"series", "brand-id", "model", "system-label"}, nil, assemblePreseed, 0}
+	AspectBundleType    = &AssertionType{"aspect-bundle", []string{"account-id", "name"}, nil, assembleAspectBundle, 0}
 
 // ...
 )
@@ -164,6 +165,7 @@ var typeRegistry = map[string]*AssertionType{
 	ValidationSetType.Name:   ValidationSetType,
 	RepairType.Name:          RepairType,
 	StoreType.Name:           StoreType,
+	AspectBundleType.Name:    AspectBundleType,
 	// no authority
 	DeviceSessionRequestType.Name: DeviceSessionRequestType,
 	SerialRequestType.Name:        SerialRequestType,
@@ -51,6 +51,7 @@ func (as *assertsSuite) TestTypeNames(c *C) {
 		"account",
 		"account-key",
 		"account-key-request",
+		"aspect-bundle",
 		// XXX "authority-delegation",
 		"base-declaration",
 		"device-session-request",
@@ -1185,6 +1186,7 @@ func (as *assertsSuite) TestWithAuthority(c *C) {
 	withAuthority := []string{
 		"account",
 		"account-key",
+		"aspect-bundle",
 		// XXX "authority-delegation",
 		"base-declaration",
 		"store",
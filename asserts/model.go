@@ -378,6 +378,22 @@ const (
 
 var validStorageSafeties = []string{string(StorageSafetyEncrypted), string(StorageSafetyPreferEncrypted), string(StorageSafetyPreferUnencrypted)}
 
+// SnapIntegrityPolicy characterizes whether the snaps installed on a
+// model device must carry integrity data verified at mount time.
+type SnapIntegrityPolicy string
+
+const (
+	SnapIntegrityUnset SnapIntegrityPolicy = "unset"
+	// SnapIntegrityRequired implies that snaps without verified
+	// integrity data are refused.
+	SnapIntegrityRequired SnapIntegrityPolicy = "required"
+	// SnapIntegrityOptional implies that integrity data are verified
+	// and used when available.
+	SnapIntegrityOptional SnapIntegrityPolicy = "optional"
+)
+
+var validSnapIntegrityPolicies = []string{string(SnapIntegrityRequired), string(SnapIntegrityOptional)}
+
 var validModelGrades = []string{string(ModelSecured), string(ModelSigned), string(ModelDangerous)}
 
 // gradeToCode encodes grades into 32 bits, trying to be slightly future-proof:
@@ -457,6 +473,8 @@ type Model struct {
 
 	storageSafety StorageSafety
 
+	snapIntegrity SnapIntegrityPolicy
+
 	allSnaps []*ModelSnap
 	// consumers of this info should care only about snap identity =>
 	// snapRef
@@ -522,6 +540,12 @@ func (mod *Model) StorageSafety() StorageSafety {
 	return mod.storageSafety
 }
 
+// SnapIntegrity returns the snap integrity policy for the model. Will be
+// SnapIntegrityUnset for Core 16/18 models.
+func (mod *Model) SnapIntegrity() SnapIntegrityPolicy {
+	return mod.snapIntegrity
+}
+
 // GadgetSnap returns the details of the gadget snap the model uses.
 func (mod *Model) GadgetSnap() *ModelSnap {
 	return mod.gadgetSnap
@@ -858,6 +882,9 @@ func assembleModel(assert assertionBase) (Assertion, error) {
 		if _, ok := assert.headers["storage-safety"]; ok {
 			return nil, fmt.Errorf("cannot specify storage-safety for model without the extended snaps header")
 		}
+		if _, ok := assert.headers["snap-integrity"]; ok {
+			return nil, fmt.Errorf("cannot specify snap-integrity for model without the extended snaps header")
+		}
 	}
 
 	if classic && !extended {
@@ -921,6 +948,7 @@ func assembleModel(assert assertionBase) (Assertion, error) {
 	var modSnaps *modelSnaps
 	grade := ModelGradeUnset
 	storageSafety := StorageSafetyUnset
+	snapIntegrity := SnapIntegrityUnset
 	if extended {
 		gradeStr, err := checkOptionalString(assert.headers, "grade")
 		if err != nil {
@@ -955,6 +983,18 @@ func assembleModel(assert assertionBase) (Assertion, error) {
 			return nil, fmt.Errorf(`secured grade model must not have storage-safety overridden, only "encrypted" is valid`)
 		}
 
+		snapIntegrityStr, err := checkOptionalString(assert.headers, "snap-integrity")
+		if err != nil {
+			return nil, err
+		}
+		if snapIntegrityStr != "" && !strutil.ListContains(validSnapIntegrityPolicies, snapIntegrityStr) {
+			return nil, fmt.Errorf("snap-integrity for model must be %s, not %q", strings.Join(validSnapIntegrityPolicies, "|"), snapIntegrityStr)
+		}
+		snapIntegrity = SnapIntegrityOptional
+		if snapIntegrityStr != "" {
+			snapIntegrity = SnapIntegrityPolicy(snapIntegrityStr)
+		}
+
 		modSnaps, err = checkExtendedSnaps(extendedSnaps, base, grade, classic)
 		if err != nil {
 			return nil, err
@@ -1056,6 +1096,7 @@ func assembleModel(assert assertionBase) (Assertion, error) {
 		kernelSnap:                 modSnaps.kernel,
 		grade:                      grade,
 		storageSafety:              storageSafety,
+		snapIntegrity:              snapIntegrity,
 		allSnaps:                   allSnaps,
 		requiredWithEssentialSnaps: requiredWithEssentialSnaps,
 		numEssentialSnaps:          numEssentialSnaps,
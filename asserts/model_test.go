@@ -236,6 +236,7 @@ func (mods *modelSuite) TestDecodeOK(c *C) {
 	c.Check(model.Store(), Equals, "brand-store")
 	c.Check(model.Grade(), Equals, asserts.ModelGradeUnset)
 	c.Check(model.StorageSafety(), Equals, asserts.StorageSafetyUnset)
+	c.Check(model.SnapIntegrity(), Equals, asserts.SnapIntegrityUnset)
 	essentialSnaps := model.EssentialSnaps()
 	c.Check(essentialSnaps, DeepEquals, []*asserts.ModelSnap{
 		model.KernelSnap(),
@@ -537,6 +538,7 @@ func (mods *modelSuite) TestDecodeInvalid(c *C) {
 		{sysUserAuths, "system-user-authority:\n  a: 1\n", `"system-user-authority" header must be '\*' or a list of account ids`},
 		{sysUserAuths, "system-user-authority:\n  - 5_6\n", `"system-user-authority" header must be '\*' or a list of account ids`},
 		{reqSnaps, "grade: dangerous\n", `cannot specify a grade for model without the extended snaps header`},
+		{reqSnaps, "snap-integrity: required\n", `cannot specify snap-integrity for model without the extended snaps header`},
 	}
 
 	for _, test := range invalidTests {
@@ -971,6 +973,27 @@ func (mods *modelSuite) TestCore20DefaultStorageSafetySignedDangerous(c *C) {
 	}
 }
 
+func (mods *modelSuite) TestCore20SnapIntegrity(c *C) {
+	encoded := strings.Replace(core20ModelExample, "TSLINE", mods.tsLine, 1)
+	encoded = strings.Replace(encoded, "OTHER", "", 1)
+
+	for _, tc := range []struct {
+		header string
+		si     asserts.SnapIntegrityPolicy
+	}{
+		{"", asserts.SnapIntegrityOptional},
+		{"snap-integrity: optional\n", asserts.SnapIntegrityOptional},
+		{"snap-integrity: required\n", asserts.SnapIntegrityRequired},
+	} {
+		ex := strings.Replace(encoded, "storage-safety: encrypted\n", "storage-safety: encrypted\n"+tc.header, 1)
+		a, err := asserts.Decode([]byte(ex))
+		c.Assert(err, IsNil)
+		c.Check(a.Type(), Equals, asserts.ModelType)
+		model := a.(*asserts.Model)
+		c.Check(model.SnapIntegrity(), Equals, tc.si)
+	}
+}
+
 func (mods *modelSuite) TestWithSnapsDecodeInvalid(c *C) {
 	tt := []struct {
 		modelRaw  string
@@ -1022,6 +1045,7 @@ func (mods *modelSuite) testWithSnapsDecodeInvalid(c *C, modelRaw string, isClas
 		{"grade: secured\n", "grade: foo\n", `grade for model must be secured|signed|dangerous`},
 		{"storage-safety: encrypted\n", "storage-safety: foo\n", `storage-safety for model must be encrypted\|prefer-encrypted\|prefer-unencrypted, not "foo"`},
 		{"storage-safety: encrypted\n", "storage-safety: prefer-unencrypted\n", `secured grade model must not have storage-safety overridden, only "encrypted" is valid`},
+		{"storage-safety: encrypted\n", "storage-safety: encrypted\nsnap-integrity: foo\n", `snap-integrity for model must be required\|optional, not "foo"`},
 	}
 	if isClassic {
 		classicInvalid := []struct{ original, invalid, expectedErr string }{
@@ -1885,6 +1885,50 @@ func (s *systemsSuite) TestMarkRecoveryCapableSystemNonRecoveryAware(c *C) {
 	c.Check(bl.SetBootVarsCalls, Equals, 0)
 }
 
+func (s *systemsSuite) TestUnmarkRecoveryCapableSystemHappy(c *C) {
+	rbl := bootloadertest.Mock("recovery", c.MkDir()).RecoveryAware()
+	bootloader.Force(rbl)
+
+	err := rbl.SetBootVars(map[string]string{
+		"snapd_good_recovery_systems": "1111,1234,2222",
+	})
+	c.Assert(err, IsNil)
+
+	err = boot.UnmarkRecoveryCapableSystem("1234")
+	c.Assert(err, IsNil)
+	vars, err := rbl.GetBootVars("snapd_good_recovery_systems")
+	c.Assert(err, IsNil)
+	c.Check(vars, DeepEquals, map[string]string{
+		"snapd_good_recovery_systems": "1111,2222",
+	})
+
+	// unknown system is a nop
+	rbl.SetBootVarsCalls = 0
+	err = boot.UnmarkRecoveryCapableSystem("9999")
+	c.Assert(err, IsNil)
+	c.Check(rbl.SetBootVarsCalls, Equals, 0)
+
+	// drop the last entry
+	err = boot.UnmarkRecoveryCapableSystem("2222")
+	c.Assert(err, IsNil)
+	err = boot.UnmarkRecoveryCapableSystem("1111")
+	c.Assert(err, IsNil)
+	vars, err = rbl.GetBootVars("snapd_good_recovery_systems")
+	c.Assert(err, IsNil)
+	c.Check(vars, DeepEquals, map[string]string{
+		"snapd_good_recovery_systems": "",
+	})
+}
+
+func (s *systemsSuite) TestUnmarkRecoveryCapableSystemNonRecoveryAware(c *C) {
+	bl := bootloadertest.Mock("recovery", c.MkDir())
+	bootloader.Force(bl)
+
+	err := boot.UnmarkRecoveryCapableSystem("1234")
+	c.Assert(err, IsNil)
+	c.Check(bl.SetBootVarsCalls, Equals, 0)
+}
+
 type initramfsMarkTryRecoverySystemSuite struct {
 	baseSystemsSuite
 
@@ -0,0 +1,55 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/url"
+	"strings"
+)
+
+// AspectGet asks for the values of the provided fields of the aspect
+// identified by aspectID (in the format "<account>/<bundle>/<aspect>").
+//
+// Note that the values may include json.Numbers.
+func (client *Client) AspectGet(aspectID string, fields []string) (result map[string]interface{}, err error) {
+	query := url.Values{}
+	query.Set("fields", strings.Join(fields, ","))
+
+	_, err = client.doSync("GET", "/v2/aspects/"+aspectID, query, nil, nil, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// AspectSet requests the provided fields of the aspect identified by aspectID
+// (in the format "<account>/<bundle>/<aspect>") to be set to the respective
+// values. A nil value unsets the field.
+func (client *Client) AspectSet(aspectID string, values map[string]interface{}) (changeID string, err error) {
+	b, err := json.Marshal(values)
+	if err != nil {
+		return "", err
+	}
+
+	return client.doAsync("PUT", "/v2/aspects/"+aspectID, nil, nil, bytes.NewReader(b))
+}
@@ -0,0 +1,82 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client_test
+
+import (
+	"encoding/json"
+
+	"gopkg.in/check.v1"
+)
+
+func (cs *clientSuite) TestAspectGet(c *check.C) {
+	cs.rsp = `{
+		"type": "sync",
+		"status-code": 200,
+		"result": {"ssid": "foo", "ssids": ["foo", "bar"]}
+	}`
+
+	res, err := cs.cli.AspectGet("system/network/wifi-setup", []string{"ssid", "ssids"})
+	c.Assert(err, check.IsNil)
+	c.Check(cs.req.Method, check.Equals, "GET")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/aspects/system/network/wifi-setup")
+	c.Check(cs.req.URL.Query().Get("fields"), check.Equals, "ssid,ssids")
+	c.Check(res, check.DeepEquals, map[string]interface{}{
+		"ssid":  "foo",
+		"ssids": []interface{}{"foo", "bar"},
+	})
+}
+
+func (cs *clientSuite) TestAspectGetError(c *check.C) {
+	cs.status = 404
+	cs.rsp = `{
+		"type": "error",
+		"status-code": 404,
+		"result": {"message": "aspect system/network/wifi-setup not found", "kind": "aspect-not-found"}
+	}`
+
+	_, err := cs.cli.AspectGet("system/network/wifi-setup", []string{"ssid"})
+	c.Assert(err, check.ErrorMatches, "aspect system/network/wifi-setup not found")
+}
+
+func (cs *clientSuite) TestAspectSet(c *check.C) {
+	cs.status = 202
+	cs.rsp = `{
+		"type": "async",
+		"status-code": 202,
+		"change": "123"
+	}`
+
+	chgID, err := cs.cli.AspectSet("system/network/wifi-setup", map[string]interface{}{
+		"ssid":     "foo",
+		"password": nil,
+	})
+	c.Assert(err, check.IsNil)
+	c.Check(chgID, check.Equals, "123")
+	c.Check(cs.req.Method, check.Equals, "PUT")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/aspects/system/network/wifi-setup")
+
+	var body map[string]interface{}
+	err = json.NewDecoder(cs.req.Body).Decode(&body)
+	c.Assert(err, check.IsNil)
+	c.Check(body, check.DeepEquals, map[string]interface{}{
+		"ssid":     "foo",
+		"password": nil,
+	})
+}
@@ -20,7 +20,9 @@
 package client
 
 import (
+	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/url"
@@ -90,6 +92,72 @@ func (client *Client) Change(id string) (*Change, error) {
 	return &chgd.Change, nil
 }
 
+// WatchChange follows the progress of a Change given its ID.
+//
+// The returned channel receives the change every time it or any of its
+// tasks is updated, and is closed once the change is ready or the
+// connection is lost. If the daemon does not support streaming change
+// updates, the channel receives the current state of the change only.
+func (client *Client) WatchChange(id string) (<-chan *Change, error) {
+	client.checkMaintenanceJSON()
+
+	query := url.Values{"follow": []string{"true"}}
+	rsp, err := client.raw(context.Background(), "GET", "/v2/changes/"+id, query, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	ch := make(chan *Change, 1)
+	if rsp.Header.Get("Content-Type") != "application/json-seq" {
+		// older snapd, the change comes in a regular sync response
+		defer rsp.Body.Close()
+		var r response
+		if err := decodeInto(rsp.Body, &r); err != nil {
+			return nil, err
+		}
+		if err := r.err(client, rsp.StatusCode); err != nil {
+			return nil, err
+		}
+		if r.Type != "sync" {
+			return nil, fmt.Errorf("expected sync response, got %q", r.Type)
+		}
+		var chgd changeAndData
+		if err := json.Unmarshal(r.Result, &chgd); err != nil {
+			return nil, fmt.Errorf("cannot unmarshal: %v", err)
+		}
+		chgd.Change.data = chgd.Data
+		ch <- &chgd.Change
+		close(ch)
+		return ch, nil
+	}
+
+	go func() {
+		// changes come in application/json-seq, described in RFC7464,
+		// see Logs for details
+		defer rsp.Body.Close()
+		defer close(ch)
+		scanner := bufio.NewScanner(rsp.Body)
+		scanner.Buffer(nil, 1024*1024)
+		for scanner.Scan() {
+			buf := scanner.Bytes()            // the scanner prunes the ending LF
+			idx := bytes.IndexByte(buf, 0x1E) // find the initial RS
+			if idx < 0 {
+				// no RS? skip
+				continue
+			}
+			var chgd changeAndData
+			if err := json.Unmarshal(buf[idx+1:], &chgd); err != nil {
+				// truncated/corrupted record? skip
+				continue
+			}
+			chgd.Change.data = chgd.Data
+			ch <- &chgd.Change
+		}
+	}()
+
+	return ch, nil
+}
+
 // Abort attempts to abort a change that is in not yet ready.
 func (client *Client) Abort(id string) (*Change, error) {
 	var postData struct {
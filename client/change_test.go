@@ -21,6 +21,7 @@ package client_test
 
 import (
 	"io/ioutil"
+	"net/http"
 	"time"
 
 	"gopkg.in/check.v1"
@@ -232,3 +233,56 @@ func (cs *clientSuite) TestClientAbort(c *check.C) {
 
 	c.Assert(string(body), check.Equals, "{\"action\":\"abort\"}\n")
 }
+
+func (cs *clientSuite) TestClientWatchChange(c *check.C) {
+	cs.header = http.Header{"Content-Type": []string{"application/json-seq"}}
+	cs.rsp = "\x1e" + `{"id": "uno", "kind": "foo", "status": "Doing", "ready": false, "tasks": [{"id": "1", "status": "Doing", "progress": {"label": "x", "done": 1, "total": 4}}]}` + "\n" +
+		"garbage\n" +
+		"\x1e" + `{"id": "uno", "kind": "foo", "status": "Doing", "ready": false, "tasks": [{"id": "1", "status": "Doing", "progress": {"label": "x", "done": 3, "total": 4}}]}` + "\n" +
+		"\x1e" + `{"truncated` + "\n" +
+		"\x1e" + `{"id": "uno", "kind": "foo", "status": "Done", "ready": true, "data": {"n": 42}}` + "\n"
+
+	ch, err := cs.cli.WatchChange("uno")
+	c.Assert(err, check.IsNil)
+	c.Check(cs.req.Method, check.Equals, "GET")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/changes/uno")
+	c.Check(cs.req.URL.RawQuery, check.Equals, "follow=true")
+
+	var chgs []*client.Change
+	for chg := range ch {
+		chgs = append(chgs, chg)
+	}
+	c.Assert(chgs, check.HasLen, 3)
+	c.Check(chgs[0].Tasks[0].Progress, check.Equals, client.TaskProgress{Label: "x", Done: 1, Total: 4})
+	c.Check(chgs[1].Tasks[0].Progress, check.Equals, client.TaskProgress{Label: "x", Done: 3, Total: 4})
+	c.Check(chgs[2].Status, check.Equals, "Done")
+	c.Check(chgs[2].Ready, check.Equals, true)
+	var n int
+	c.Assert(chgs[2].Get("n", &n), check.IsNil)
+	c.Check(n, check.Equals, 42)
+	c.Check(cs.countingCloser.closeCalled, check.Equals, 1)
+}
+
+func (cs *clientSuite) TestClientWatchChangeNotStreamed(c *check.C) {
+	cs.rsp = `{"type": "sync", "result": {"id": "uno", "kind": "foo", "status": "Do", "ready": false}}`
+
+	ch, err := cs.cli.WatchChange("uno")
+	c.Assert(err, check.IsNil)
+
+	var chgs []*client.Change
+	for chg := range ch {
+		chgs = append(chgs, chg)
+	}
+	c.Assert(chgs, check.HasLen, 1)
+	c.Check(chgs[0].ID, check.Equals, "uno")
+	c.Check(chgs[0].Status, check.Equals, "Do")
+}
+
+func (cs *clientSuite) TestClientWatchChangeError(c *check.C) {
+	cs.status = 404
+	cs.rsp = `{"type": "error", "result": {"message": "cannot find change with id \"uno\""}}`
+
+	_, err := cs.cli.WatchChange("uno")
+	c.Check(err, check.ErrorMatches, `cannot find change with id "uno"`)
+	c.Check(err.(*client.Error).StatusCode, check.Equals, 404)
+}
@@ -142,6 +142,21 @@ const (
 
 	// ErrorKindValidationSetNotFound: validation set cannot be found.
 	ErrorKindValidationSetNotFound ErrorKind = "validation-set-not-found"
+
+	// ErrorKindAspectNotFound: aspect cannot be found.
+	ErrorKindAspectNotFound ErrorKind = "aspect-not-found"
+	// ErrorKindAspectInvalidValue: the value doesn't conform to the aspect
+	// bundle's schema; the offending element is identified by `path` in the
+	// error value.
+	ErrorKindAspectInvalidValue ErrorKind = "aspect-invalid-value"
+
+	// ErrorKindPromptingRuleConflict: the new prompting rule conflicts with
+	// existing rules; the conflicts are listed in the error value.
+	ErrorKindPromptingRuleConflict ErrorKind = "prompting-rule-conflict"
+
+	// ErrorKindSnapshotKeyRequired: the snapshot set is encrypted and
+	// the passphrase or private key to decrypt it was not given.
+	ErrorKindSnapshotKeyRequired ErrorKind = "snapshot-key-required"
 )
 
 // Maintenance error kinds.
@@ -0,0 +1,252 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// ExecUpgradeProtocol is the protocol a connection to /v2/exec is upgraded
+// to. After the upgrade both ends exchange frames, each made of a one byte
+// stream identifier, the length of the payload as a big-endian uint32, and
+// the payload itself.
+const ExecUpgradeProtocol = "snapd-exec"
+
+// The streams of an exec session.
+const (
+	// ExecStreamStdin carries the input of the command; an empty frame
+	// closes it
+	ExecStreamStdin byte = iota
+	// ExecStreamStdout carries the output of the command
+	ExecStreamStdout
+	// ExecStreamStderr carries the error output of the command, unless
+	// it runs in a terminal
+	ExecStreamStderr
+	// ExecStreamControl carries JSON encoded ExecControl messages
+	ExecStreamControl
+	// ExecStreamExit carries the JSON encoded ExecExit with which the
+	// session ends
+	ExecStreamExit
+)
+
+// maxExecFrameSize is the largest payload a frame can carry.
+const maxExecFrameSize = 1 << 20
+
+// ExecOptions describe a command to run in the confinement of a snap.
+type ExecOptions struct {
+	// Command is the snap application to run, as given to "snap run",
+	// followed by its arguments
+	Command []string `json:"command"`
+	// User to run the command as; by default, root
+	User string `json:"user,omitempty"`
+	// Environment holds variables to set for the command
+	Environment map[string]string `json:"environment,omitempty"`
+	// WorkingDir is the directory to run the command in; by default,
+	// the home directory of the user
+	WorkingDir string `json:"working-dir,omitempty"`
+	// Tty requests that the command runs in a pseudo-terminal of the
+	// given size; its output is then all on ExecStreamStdout
+	Tty    bool `json:"tty,omitempty"`
+	Width  int  `json:"width,omitempty"`
+	Height int  `json:"height,omitempty"`
+}
+
+// ExecControl is a message to a running command.
+type ExecControl struct {
+	// Command is either "resize" or "signal"
+	Command string `json:"command"`
+	// Width and Height are the new size of the terminal, for "resize"
+	Width  int `json:"width,omitempty"`
+	Height int `json:"height,omitempty"`
+	// Signal is the number of the signal to send, for "signal"
+	Signal int `json:"signal,omitempty"`
+}
+
+// ExecExit reports how a command finished.
+type ExecExit struct {
+	ExitCode int `json:"exit-code"`
+	// Error is set if the command could not be run or waited for
+	Error string `json:"error,omitempty"`
+}
+
+// WriteExecFrame writes a frame with the given payload for the stream to w.
+// The frame is written in a single call, so frames written concurrently to
+// a net.Conn are not interleaved.
+func WriteExecFrame(w io.Writer, stream byte, payload []byte) error {
+	if len(payload) > maxExecFrameSize {
+		return fmt.Errorf("cannot write exec frame: payload too large (%d bytes)", len(payload))
+	}
+	buf := make([]byte, 5+len(payload))
+	buf[0] = stream
+	binary.BigEndian.PutUint32(buf[1:5], uint32(len(payload)))
+	copy(buf[5:], payload)
+	_, err := w.Write(buf)
+	return err
+}
+
+// ReadExecFrame reads the next frame from r.
+func ReadExecFrame(r io.Reader) (stream byte, payload []byte, err error) {
+	var hdr [5]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return 0, nil, err
+	}
+	size := binary.BigEndian.Uint32(hdr[1:])
+	if size > maxExecFrameSize {
+		return 0, nil, fmt.Errorf("cannot read exec frame: payload too large (%d bytes)", size)
+	}
+	payload = make([]byte, size)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return 0, nil, err
+	}
+	return hdr[0], payload, nil
+}
+
+// ExecStdio connects a command run with Exec to the caller.
+type ExecStdio struct {
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+	// Control, if set, delivers messages to pass on to the command,
+	// such as terminal size changes or signals
+	Control <-chan *ExecControl
+}
+
+type lockedWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (lw *lockedWriter) writeFrame(stream byte, payload []byte) error {
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+	return WriteExecFrame(lw.w, stream, payload)
+}
+
+// Exec runs a command in the confinement of a snap, streaming its input and
+// output, and returns its exit code once it finishes.
+func (client *Client) Exec(opts *ExecOptions, stdio *ExecStdio) (exitCode int, err error) {
+	data, err := json.Marshal(opts)
+	if err != nil {
+		return 0, fmt.Errorf("cannot marshal exec options: %v", err)
+	}
+	headers := map[string]string{
+		"Content-Type": "application/json",
+		"Connection":   "Upgrade",
+		"Upgrade":      ExecUpgradeProtocol,
+	}
+	rsp, err := client.raw(context.Background(), "POST", "/v2/exec", nil, headers, bytes.NewBuffer(data))
+	if err != nil {
+		return 0, err
+	}
+	defer rsp.Body.Close()
+	if rsp.StatusCode != 101 {
+		var r response
+		if err := decodeInto(rsp.Body, &r); err != nil {
+			return 0, err
+		}
+		if err := r.err(client, rsp.StatusCode); err != nil {
+			return 0, err
+		}
+		return 0, fmt.Errorf("cannot exec: unexpected status code %d", rsp.StatusCode)
+	}
+	conn, ok := rsp.Body.(io.ReadWriter)
+	if !ok {
+		return 0, fmt.Errorf("cannot exec: connection was not upgraded")
+	}
+
+	w := &lockedWriter{w: conn}
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		if stdio.Stdin != nil {
+			buf := make([]byte, 32*1024)
+			for {
+				n, err := stdio.Stdin.Read(buf)
+				if n > 0 {
+					if w.writeFrame(ExecStreamStdin, buf[:n]) != nil {
+						return
+					}
+				}
+				if err != nil {
+					break
+				}
+			}
+		}
+		w.writeFrame(ExecStreamStdin, nil)
+	}()
+	if stdio.Control != nil {
+		go func() {
+			for {
+				select {
+				case ctl := <-stdio.Control:
+					payload, err := json.Marshal(ctl)
+					if err != nil {
+						continue
+					}
+					if w.writeFrame(ExecStreamControl, payload) != nil {
+						return
+					}
+				case <-done:
+					return
+				}
+			}
+		}()
+	}
+
+	for {
+		stream, payload, err := ReadExecFrame(conn)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				err = io.ErrUnexpectedEOF
+			}
+			return 0, fmt.Errorf("cannot exec: %v", err)
+		}
+		switch stream {
+		case ExecStreamStdout:
+			if stdio.Stdout != nil {
+				stdio.Stdout.Write(payload)
+			}
+		case ExecStreamStderr:
+			if stdio.Stderr != nil {
+				stdio.Stderr.Write(payload)
+			}
+		case ExecStreamExit:
+			var exit ExecExit
+			if err := json.Unmarshal(payload, &exit); err != nil {
+				return 0, fmt.Errorf("cannot decode exit status: %v", err)
+			}
+			if exit.Error != "" {
+				return exit.ExitCode, fmt.Errorf("cannot exec: %s", exit.Error)
+			}
+			return exit.ExitCode, nil
+		}
+	}
+}
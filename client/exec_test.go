@@ -0,0 +1,171 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/client"
+)
+
+func (cs *clientSuite) TestExecFrameRoundtrip(c *check.C) {
+	var buf bytes.Buffer
+	c.Assert(client.WriteExecFrame(&buf, client.ExecStreamStdout, []byte("hello")), check.IsNil)
+	c.Assert(client.WriteExecFrame(&buf, client.ExecStreamStdin, nil), check.IsNil)
+	c.Check(buf.Bytes(), check.DeepEquals, []byte("\x01\x00\x00\x00\x05hello\x00\x00\x00\x00\x00"))
+
+	stream, payload, err := client.ReadExecFrame(&buf)
+	c.Assert(err, check.IsNil)
+	c.Check(stream, check.Equals, client.ExecStreamStdout)
+	c.Check(string(payload), check.Equals, "hello")
+	stream, payload, err = client.ReadExecFrame(&buf)
+	c.Assert(err, check.IsNil)
+	c.Check(stream, check.Equals, client.ExecStreamStdin)
+	c.Check(payload, check.HasLen, 0)
+	_, _, err = client.ReadExecFrame(&buf)
+	c.Check(err, check.Equals, io.EOF)
+
+	_, _, err = client.ReadExecFrame(strings.NewReader("\x01\x00\x00\x00\x05hel"))
+	c.Check(err, check.Equals, io.ErrUnexpectedEOF)
+	_, _, err = client.ReadExecFrame(strings.NewReader("\x01\xff\x00\x00\x00"))
+	c.Check(err, check.ErrorMatches, `cannot read exec frame: payload too large \(4278190080 bytes\)`)
+	err = client.WriteExecFrame(&buf, client.ExecStreamStdout, make([]byte, 1<<20+1))
+	c.Check(err, check.ErrorMatches, `cannot write exec frame: payload too large \(1048577 bytes\)`)
+}
+
+func mockExecServer(c *check.C, handle func(opts *client.ExecOptions, r *bufio.Reader, w io.Writer)) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, check.Equals, "POST")
+		c.Check(r.URL.Path, check.Equals, "/v2/exec")
+		c.Check(r.Header.Get("Upgrade"), check.Equals, client.ExecUpgradeProtocol)
+		var opts client.ExecOptions
+		c.Assert(json.NewDecoder(r.Body).Decode(&opts), check.IsNil)
+
+		conn, rw, err := w.(http.Hijacker).Hijack()
+		c.Assert(err, check.IsNil)
+		defer conn.Close()
+		fmt.Fprintf(conn, "HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: %s\r\n\r\n", client.ExecUpgradeProtocol)
+		handle(&opts, rw.Reader, conn)
+	}))
+}
+
+func (cs *clientSuite) TestClientExec(c *check.C) {
+	srv := mockExecServer(c, func(opts *client.ExecOptions, r *bufio.Reader, w io.Writer) {
+		c.Check(opts, check.DeepEquals, &client.ExecOptions{
+			Command: []string{"foo.bar", "--baz"},
+			User:    "test",
+			Tty:     true,
+			Width:   80,
+			Height:  25,
+		})
+		// the input and control streams are independent
+		var stdin []byte
+		var controls []string
+		for eof := false; !eof || len(controls) == 0; {
+			stream, payload, err := client.ReadExecFrame(r)
+			c.Assert(err, check.IsNil)
+			switch stream {
+			case client.ExecStreamControl:
+				controls = append(controls, string(payload))
+			case client.ExecStreamStdin:
+				eof = len(payload) == 0
+				stdin = append(stdin, payload...)
+			default:
+				c.Fatalf("unexpected stream %d", stream)
+			}
+		}
+		c.Check(controls, check.DeepEquals, []string{`{"command":"resize","width":100,"height":40}`})
+		c.Check(string(stdin), check.Equals, "some input")
+
+		client.WriteExecFrame(w, client.ExecStreamStdout, []byte("some output"))
+		client.WriteExecFrame(w, client.ExecStreamStderr, []byte("some error"))
+		client.WriteExecFrame(w, client.ExecStreamExit, []byte(`{"exit-code": 42}`))
+	})
+	defer srv.Close()
+
+	control := make(chan *client.ExecControl, 1)
+	control <- &client.ExecControl{Command: "resize", Width: 100, Height: 40}
+	stdin := strings.NewReader("some input")
+	var stdout, stderr bytes.Buffer
+	cli := client.New(&client.Config{BaseURL: srv.URL})
+	code, err := cli.Exec(&client.ExecOptions{
+		Command: []string{"foo.bar", "--baz"},
+		User:    "test",
+		Tty:     true,
+		Width:   80,
+		Height:  25,
+	}, &client.ExecStdio{
+		Stdin:   stdin,
+		Stdout:  &stdout,
+		Stderr:  &stderr,
+		Control: control,
+	})
+	c.Assert(err, check.IsNil)
+	c.Check(code, check.Equals, 42)
+	c.Check(stdout.String(), check.Equals, "some output")
+	c.Check(stderr.String(), check.Equals, "some error")
+}
+
+func (cs *clientSuite) TestClientExecStartError(c *check.C) {
+	srv := mockExecServer(c, func(opts *client.ExecOptions, r *bufio.Reader, w io.Writer) {
+		client.WriteExecFrame(w, client.ExecStreamExit, []byte(`{"exit-code": -1, "error": "cannot start command: boom"}`))
+	})
+	defer srv.Close()
+
+	cli := client.New(&client.Config{BaseURL: srv.URL})
+	_, err := cli.Exec(&client.ExecOptions{Command: []string{"foo"}}, &client.ExecStdio{})
+	c.Assert(err, check.ErrorMatches, "cannot exec: cannot start command: boom")
+}
+
+func (cs *clientSuite) TestClientExecConnectionClosed(c *check.C) {
+	srv := mockExecServer(c, func(opts *client.ExecOptions, r *bufio.Reader, w io.Writer) {
+		client.WriteExecFrame(w, client.ExecStreamStdout, []byte("partial"))
+	})
+	defer srv.Close()
+
+	var stdout bytes.Buffer
+	cli := client.New(&client.Config{BaseURL: srv.URL})
+	_, err := cli.Exec(&client.ExecOptions{Command: []string{"foo"}}, &client.ExecStdio{Stdout: &stdout})
+	c.Assert(err, check.ErrorMatches, "cannot exec: unexpected EOF")
+	c.Check(stdout.String(), check.Equals, "partial")
+}
+
+func (cs *clientSuite) TestClientExecError(c *check.C) {
+	cs.status = 404
+	cs.rsp = `{"type": "error", "status-code": 404, "result": {"message": "snap \"foo\" not found", "kind": "snap-not-found"}}`
+	_, err := cs.cli.Exec(&client.ExecOptions{Command: []string{"foo"}}, &client.ExecStdio{})
+	c.Assert(err, check.ErrorMatches, `snap "foo" not found`)
+	c.Check(cs.req.Method, check.Equals, "POST")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/exec")
+	c.Check(cs.req.Header.Get("Upgrade"), check.Equals, client.ExecUpgradeProtocol)
+	body, err := ioutil.ReadAll(cs.req.Body)
+	c.Assert(err, check.IsNil)
+	c.Check(string(body), check.Equals, `{"command":["foo"]}`)
+}
@@ -71,10 +71,24 @@ type Interface struct {
 
 // InterfaceAction represents an action performed on the interface system.
 type InterfaceAction struct {
-	Action string `json:"action"`
-	Forget bool   `json:"forget,omitempty"`
-	Plugs  []Plug `json:"plugs,omitempty"`
-	Slots  []Slot `json:"slots,omitempty"`
+	Action      string       `json:"action"`
+	Forget      bool         `json:"forget,omitempty"`
+	PersistRule bool         `json:"persist-rule,omitempty"`
+	Plugs       []Plug       `json:"plugs,omitempty"`
+	Slots       []Slot       `json:"slots,omitempty"`
+	HotplugRule *HotplugRule `json:"hotplug-rule,omitempty"`
+}
+
+// HotplugRule decides whether the slots created for hotplugged devices
+// matching it are connected to the plugs of a snap.
+type HotplugRule struct {
+	ID        int               `json:"id,omitempty"`
+	Interface string            `json:"interface,omitempty"`
+	Match     map[string]string `json:"match,omitempty"`
+	Snap      string            `json:"snap,omitempty"`
+	Plug      string            `json:"plug,omitempty"`
+	// Action is either "connect" or "refuse".
+	Action string `json:"action,omitempty"`
 }
 
 // InterfaceOptions represents opt-in elements include in responses.
@@ -86,6 +100,13 @@ type InterfaceOptions struct {
 	Connected bool
 }
 
+// ConnectOptions represents extra options for connect op
+type ConnectOptions struct {
+	// PersistRule adds a hotplug rule connecting the plug to the slots
+	// of the device whenever it is plugged in again.
+	PersistRule bool
+}
+
 // DisconnectOptions represents extra options for disconnect op
 type DisconnectOptions struct {
 	Forget bool
@@ -130,11 +151,12 @@ func (client *Client) performInterfaceAction(sa *InterfaceAction) (changeID stri
 
 // Connect establishes a connection between a plug and a slot.
 // The plug and the slot must have the same interface.
-func (client *Client) Connect(plugSnapName, plugName, slotSnapName, slotName string) (changeID string, err error) {
+func (client *Client) Connect(plugSnapName, plugName, slotSnapName, slotName string, opts *ConnectOptions) (changeID string, err error) {
 	return client.performInterfaceAction(&InterfaceAction{
-		Action: "connect",
-		Plugs:  []Plug{{Snap: plugSnapName, Name: plugName}},
-		Slots:  []Slot{{Snap: slotSnapName, Name: slotName}},
+		Action:      "connect",
+		PersistRule: opts != nil && opts.PersistRule,
+		Plugs:       []Plug{{Snap: plugSnapName, Name: plugName}},
+		Slots:       []Slot{{Snap: slotSnapName, Name: slotName}},
 	})
 }
 
@@ -147,3 +169,42 @@ func (client *Client) Disconnect(plugSnapName, plugName, slotSnapName, slotName
 		Slots:  []Slot{{Snap: slotSnapName, Name: slotName}},
 	})
 }
+
+// HotplugRules returns the hotplug rules defined on the device.
+func (client *Client) HotplugRules() ([]*HotplugRule, error) {
+	query := url.Values{}
+	query.Set("select", "hotplug-rules")
+	var rules []*HotplugRule
+	_, err := client.doSync("GET", "/v2/interfaces", query, nil, nil, &rules)
+	return rules, err
+}
+
+// AddHotplugRule adds the given hotplug rule and returns it with its
+// allocated ID.
+func (client *Client) AddHotplugRule(rule *HotplugRule) (*HotplugRule, error) {
+	b, err := json.Marshal(&InterfaceAction{
+		Action:      "add-hotplug-rule",
+		HotplugRule: rule,
+	})
+	if err != nil {
+		return nil, err
+	}
+	var added HotplugRule
+	if _, err := client.doSync("POST", "/v2/interfaces", nil, nil, bytes.NewReader(b), &added); err != nil {
+		return nil, err
+	}
+	return &added, nil
+}
+
+// RemoveHotplugRule removes the hotplug rule with the given ID.
+func (client *Client) RemoveHotplugRule(id int) error {
+	b, err := json.Marshal(&InterfaceAction{
+		Action:      "remove-hotplug-rule",
+		HotplugRule: &HotplugRule{ID: id},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = client.doSync("POST", "/v2/interfaces", nil, nil, bytes.NewReader(b), nil)
+	return err
+}
@@ -157,7 +157,7 @@ func (cs *clientSuite) TestClientInterfacesMultiple(c *check.C) {
 }
 
 func (cs *clientSuite) TestClientConnectCallsEndpoint(c *check.C) {
-	cs.cli.Connect("producer", "plug", "consumer", "slot")
+	cs.cli.Connect("producer", "plug", "consumer", "slot", nil)
 	c.Check(cs.req.Method, check.Equals, "POST")
 	c.Check(cs.req.URL.Path, check.Equals, "/v2/interfaces")
 }
@@ -170,7 +170,7 @@ func (cs *clientSuite) TestClientConnect(c *check.C) {
 		"result": { },
                 "change": "foo"
 	}`
-	id, err := cs.cli.Connect("producer", "plug", "consumer", "slot")
+	id, err := cs.cli.Connect("producer", "plug", "consumer", "slot", nil)
 	c.Assert(err, check.IsNil)
 	c.Check(id, check.Equals, "foo")
 	var body map[string]interface{}
@@ -194,6 +194,105 @@ func (cs *clientSuite) TestClientConnect(c *check.C) {
 	})
 }
 
+func (cs *clientSuite) TestClientConnectPersistRule(c *check.C) {
+	cs.status = 202
+	cs.rsp = `{
+		"type": "async",
+		"status-code": 202,
+		"result": { },
+		"change": "foo"
+	}`
+	id, err := cs.cli.Connect("consumer", "plug", "core", "webcam", &client.ConnectOptions{PersistRule: true})
+	c.Assert(err, check.IsNil)
+	c.Check(id, check.Equals, "foo")
+	var body map[string]interface{}
+	decoder := json.NewDecoder(cs.req.Body)
+	err = decoder.Decode(&body)
+	c.Check(err, check.IsNil)
+	c.Check(body, check.DeepEquals, map[string]interface{}{
+		"action":       "connect",
+		"persist-rule": true,
+		"plugs": []interface{}{
+			map[string]interface{}{
+				"snap": "consumer",
+				"plug": "plug",
+			},
+		},
+		"slots": []interface{}{
+			map[string]interface{}{
+				"snap": "core",
+				"slot": "webcam",
+			},
+		},
+	})
+}
+
+func (cs *clientSuite) TestClientHotplugRules(c *check.C) {
+	cs.rsp = `{
+		"type": "sync",
+		"result": [
+			{"id": 1, "interface": "camera", "match": {"ID_VENDOR_ID": "046d"}, "snap": "consumer", "action": "connect"}
+		]
+	}`
+	rules, err := cs.cli.HotplugRules()
+	c.Assert(err, check.IsNil)
+	c.Check(cs.req.Method, check.Equals, "GET")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/interfaces")
+	c.Check(cs.req.URL.RawQuery, check.Equals, "select=hotplug-rules")
+	c.Check(rules, check.DeepEquals, []*client.HotplugRule{{
+		ID:        1,
+		Interface: "camera",
+		Match:     map[string]string{"ID_VENDOR_ID": "046d"},
+		Snap:      "consumer",
+		Action:    "connect",
+	}})
+}
+
+func (cs *clientSuite) TestClientAddHotplugRule(c *check.C) {
+	cs.rsp = `{
+		"type": "sync",
+		"result": {"id": 3, "interface": "camera", "match": {"ID_VENDOR_ID": "046d"}, "snap": "consumer", "plug": "camera", "action": "refuse"}
+	}`
+	rule, err := cs.cli.AddHotplugRule(&client.HotplugRule{
+		Interface: "camera",
+		Match:     map[string]string{"ID_VENDOR_ID": "046d"},
+		Snap:      "consumer",
+		Plug:      "camera",
+		Action:    "refuse",
+	})
+	c.Assert(err, check.IsNil)
+	c.Check(rule.ID, check.Equals, 3)
+	c.Check(cs.req.Method, check.Equals, "POST")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/interfaces")
+	var body map[string]interface{}
+	c.Assert(json.NewDecoder(cs.req.Body).Decode(&body), check.IsNil)
+	c.Check(body, check.DeepEquals, map[string]interface{}{
+		"action": "add-hotplug-rule",
+		"hotplug-rule": map[string]interface{}{
+			"interface": "camera",
+			"match":     map[string]interface{}{"ID_VENDOR_ID": "046d"},
+			"snap":      "consumer",
+			"plug":      "camera",
+			"action":    "refuse",
+		},
+	})
+}
+
+func (cs *clientSuite) TestClientRemoveHotplugRule(c *check.C) {
+	cs.rsp = `{"type": "sync", "result": null}`
+	c.Assert(cs.cli.RemoveHotplugRule(3), check.IsNil)
+	c.Check(cs.req.Method, check.Equals, "POST")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/interfaces")
+	var body map[string]interface{}
+	c.Assert(json.NewDecoder(cs.req.Body).Decode(&body), check.IsNil)
+	c.Check(body, check.DeepEquals, map[string]interface{}{
+		"action": "remove-hotplug-rule",
+		"hotplug-rule": map[string]interface{}{
+			"id": 3.0,
+		},
+	})
+}
+
 func (cs *clientSuite) TestClientDisconnectCallsEndpoint(c *check.C) {
 	cs.cli.Disconnect("producer", "plug", "consumer", "slot", nil)
 	c.Check(cs.req.Method, check.Equals, "POST")
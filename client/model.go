@@ -24,7 +24,12 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
 	"net/url"
+	"os"
+	"path/filepath"
 
 	"golang.org/x/xerrors"
 
@@ -50,6 +55,80 @@ func (client *Client) Remodel(b []byte) (changeID string, err error) {
 	return client.doAsync("POST", "/v2/model", nil, headers, bytes.NewReader(data))
 }
 
+// RemodelOffline tries to remodel the system with the given assertion data
+// without contacting the store, using the given local snap files and the
+// assertions needed to validate them, read from the given assertion files.
+func (client *Client) RemodelOffline(b []byte, snapPaths, assertPaths []string) (changeID string, err error) {
+	var assertions [][]byte
+	for _, path := range assertPaths {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("cannot read assertions from %q: %w", path, err)
+		}
+		assertions = append(assertions, data)
+	}
+
+	var files []*os.File
+	for _, path := range snapPaths {
+		f, err := os.Open(path)
+		if err != nil {
+			for _, openFile := range files {
+				openFile.Close()
+			}
+			return "", fmt.Errorf("cannot open %q: %w", path, err)
+		}
+
+		files = append(files, f)
+	}
+
+	pr, pw := io.Pipe()
+	mw := multipart.NewWriter(pw)
+	go sendRemodelFiles(b, assertions, snapPaths, files, pw, mw)
+
+	headers := map[string]string{
+		"Content-Type": mw.FormDataContentType(),
+	}
+
+	_, changeID, err = client.doAsyncFull("POST", "/v2/model", nil, headers, pr, doNoTimeoutAndRetry)
+	return changeID, err
+}
+
+func sendRemodelFiles(model []byte, assertions [][]byte, paths []string, files []*os.File, pw *io.PipeWriter, mw *multipart.Writer) {
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+
+	if err := mw.WriteField("new-model", string(model)); err != nil {
+		pw.CloseWithError(err)
+		return
+	}
+
+	for _, assertion := range assertions {
+		if err := mw.WriteField("assertion", string(assertion)); err != nil {
+			pw.CloseWithError(err)
+			return
+		}
+	}
+
+	for i, file := range files {
+		fw, err := mw.CreateFormFile("snap", filepath.Base(paths[i]))
+		if err != nil {
+			pw.CloseWithError(err)
+			return
+		}
+
+		if _, err := io.Copy(fw, file); err != nil {
+			pw.CloseWithError(err)
+			return
+		}
+	}
+
+	mw.Close()
+	pw.Close()
+}
+
 // CurrentModelAssertion returns the current model assertion
 func (client *Client) CurrentModelAssertion() (*asserts.Model, error) {
 	assert, err := currentAssertion(client, "/v2/model")
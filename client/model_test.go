@@ -24,6 +24,7 @@ import (
 	"errors"
 	"io/ioutil"
 	"net/http"
+	"path/filepath"
 
 	"golang.org/x/xerrors"
 	. "gopkg.in/check.v1"
@@ -129,6 +130,45 @@ func (cs *clientSuite) TestClientRemodel(c *C) {
 	c.Check(jsonBody["new-model"], Equals, string(remodelJsonData))
 }
 
+func (cs *clientSuite) TestClientRemodelOffline(c *C) {
+	cs.status = 202
+	cs.rsp = `{
+		"type": "async",
+		"status-code": 202,
+		"result": {},
+		"change": "d728"
+	}`
+	dir := c.MkDir()
+	snapPath := filepath.Join(dir, "foo.snap")
+	c.Assert(ioutil.WriteFile(snapPath, []byte("snap-data"), 0644), IsNil)
+	assertPath := filepath.Join(dir, "foo.assert")
+	c.Assert(ioutil.WriteFile(assertPath, []byte("assertion-data"), 0644), IsNil)
+
+	id, err := cs.cli.RemodelOffline([]byte("some-model"), []string{snapPath}, []string{assertPath})
+	c.Assert(err, IsNil)
+	c.Check(id, Equals, "d728")
+	c.Check(cs.req.Method, Equals, "POST")
+	c.Check(cs.req.URL.Path, Equals, "/v2/model")
+	c.Assert(cs.req.Header.Get("Content-Type"), Matches, "multipart/form-data; boundary=.*")
+
+	body, err := ioutil.ReadAll(cs.req.Body)
+	c.Assert(err, IsNil)
+	c.Check(string(body), Matches, `(?s).*Content-Disposition: form-data; name="new-model"\r\n\r\nsome-model\r\n.*`)
+	c.Check(string(body), Matches, `(?s).*Content-Disposition: form-data; name="assertion"\r\n\r\nassertion-data\r\n.*`)
+	c.Check(string(body), Matches, `(?s).*Content-Disposition: form-data; name="snap"; filename="foo.snap"\r\nContent-Type: application/octet-stream\r\n\r\nsnap-data\r\n.*`)
+
+	_, ok := cs.req.Context().Deadline()
+	c.Check(ok, Equals, false)
+}
+
+func (cs *clientSuite) TestClientRemodelOfflineMissingFile(c *C) {
+	_, err := cs.cli.RemodelOffline([]byte("some-model"), []string{"/does/not/exist.snap"}, nil)
+	c.Check(err, ErrorMatches, `cannot open "/does/not/exist.snap": open /does/not/exist.snap: no such file or directory`)
+
+	_, err = cs.cli.RemodelOffline([]byte("some-model"), nil, []string{"/does/not/exist.assert"})
+	c.Check(err, ErrorMatches, `cannot read assertions from "/does/not/exist.assert": open /does/not/exist.assert: no such file or directory`)
+}
+
 func (cs *clientSuite) TestClientGetModelHappy(c *C) {
 	cs.status = 200
 	cs.rsp = happyModelAssertionResponse
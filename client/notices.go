@@ -0,0 +1,122 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Notice is an aggregated event recorded by snapd, identified by its type
+// and key. Repeated occurrences of the same notice bump its occurrence
+// count and timestamps.
+type Notice struct {
+	ID            string            `json:"id"`
+	UserID        *uint32           `json:"user-id"`
+	Type          string            `json:"type"`
+	Key           string            `json:"key"`
+	FirstOccurred time.Time         `json:"first-occurred"`
+	LastOccurred  time.Time         `json:"last-occurred"`
+	LastRepeated  time.Time         `json:"last-repeated"`
+	Occurrences   int               `json:"occurrences"`
+	LastData      map[string]string `json:"last-data,omitempty"`
+	RepeatAfter   time.Duration     `json:"repeat-after,omitempty"`
+	ExpireAfter   time.Duration     `json:"expire-after,omitempty"`
+}
+
+type jsonNotice struct {
+	Notice
+	RepeatAfter string `json:"repeat-after,omitempty"`
+	ExpireAfter string `json:"expire-after,omitempty"`
+}
+
+func (jn *jsonNotice) notice() *Notice {
+	n := jn.Notice
+	n.RepeatAfter, _ = time.ParseDuration(jn.RepeatAfter)
+	n.ExpireAfter, _ = time.ParseDuration(jn.ExpireAfter)
+	return &n
+}
+
+// NoticesOptions contains options for querying snapd for notices.
+type NoticesOptions struct {
+	// Types, if not empty, includes only notices whose type is one of these.
+	Types []string
+
+	// Keys, if not empty, includes only notices whose key is one of these.
+	Keys []string
+
+	// After, if set, includes only notices that were last repeated after
+	// this time.
+	After time.Time
+
+	// Timeout, if set, makes snapd wait up to this long for a matching
+	// notice to occur if none exists yet.
+	Timeout time.Duration
+}
+
+// Notices returns the notices matching the given options, ordered by the
+// time they were last repeated.
+func (client *Client) Notices(opts *NoticesOptions) ([]*Notice, error) {
+	if opts == nil {
+		opts = &NoticesOptions{}
+	}
+	q := make(url.Values)
+	if len(opts.Types) > 0 {
+		q.Set("types", strings.Join(opts.Types, ","))
+	}
+	if len(opts.Keys) > 0 {
+		q.Set("keys", strings.Join(opts.Keys, ","))
+	}
+	if !opts.After.IsZero() {
+		q.Set("after", opts.After.Format(time.RFC3339Nano))
+	}
+
+	var doOpts *doOptions
+	if opts.Timeout > 0 {
+		q.Set("timeout", opts.Timeout.String())
+		doOpts = &doOptions{
+			// allow some slack on top of the server-side wait
+			Timeout: opts.Timeout + doTimeout,
+			Retry:   doRetry,
+		}
+	}
+
+	var jns []*jsonNotice
+	if _, err := client.doSyncWithOpts("GET", "/v2/notices", q, nil, nil, &jns, doOpts); err != nil {
+		return nil, err
+	}
+
+	notices := make([]*Notice, len(jns))
+	for i, jn := range jns {
+		notices[i] = jn.notice()
+	}
+	return notices, nil
+}
+
+// Notice returns the notice with the given ID.
+func (client *Client) Notice(id string) (*Notice, error) {
+	var jn jsonNotice
+	if _, err := client.doSync("GET", "/v2/notices/"+url.PathEscape(id), nil, nil, nil, &jn); err != nil {
+		return nil, fmt.Errorf("cannot get notice %q: %v", id, err)
+	}
+	return jn.notice(), nil
+}
@@ -0,0 +1,125 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client_test
+
+import (
+	"net/url"
+	"time"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/client"
+)
+
+func (cs *clientSuite) TestNotices(c *check.C) {
+	cs.rsp = `{"type": "sync", "status-code": 200, "result": [{
+		"id": "1",
+		"user-id": 1000,
+		"type": "snap-notify",
+		"key": "some-snap/foo",
+		"first-occurred": "2023-09-01T05:23:01Z",
+		"last-occurred": "2023-09-01T07:23:02Z",
+		"last-repeated": "2023-09-01T06:23:03.123456789Z",
+		"occurrences": 2,
+		"last-data": {"k": "v"},
+		"repeat-after": "1h0m0s",
+		"expire-after": "168h0m0s"
+	}]}`
+
+	after := time.Date(2023, 9, 1, 1, 0, 0, 42, time.UTC)
+	notices, err := cs.cli.Notices(&client.NoticesOptions{
+		Types: []string{"snap-notify", "change-update"},
+		Keys:  []string{"some-snap/foo"},
+		After: after,
+	})
+	c.Assert(err, check.IsNil)
+	c.Check(cs.req.Method, check.Equals, "GET")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/notices")
+	c.Check(cs.req.URL.Query(), check.DeepEquals, url.Values{
+		"types": {"snap-notify,change-update"},
+		"keys":  {"some-snap/foo"},
+		"after": {"2023-09-01T01:00:00.000000042Z"},
+	})
+
+	uid := uint32(1000)
+	c.Check(notices, check.DeepEquals, []*client.Notice{{
+		ID:            "1",
+		UserID:        &uid,
+		Type:          "snap-notify",
+		Key:           "some-snap/foo",
+		FirstOccurred: time.Date(2023, 9, 1, 5, 23, 1, 0, time.UTC),
+		LastOccurred:  time.Date(2023, 9, 1, 7, 23, 2, 0, time.UTC),
+		LastRepeated:  time.Date(2023, 9, 1, 6, 23, 3, 123456789, time.UTC),
+		Occurrences:   2,
+		LastData:      map[string]string{"k": "v"},
+		RepeatAfter:   time.Hour,
+		ExpireAfter:   7 * 24 * time.Hour,
+	}})
+}
+
+func (cs *clientSuite) TestNoticesTimeout(c *check.C) {
+	cs.rsp = `{"type": "sync", "status-code": 200, "result": []}`
+
+	notices, err := cs.cli.Notices(&client.NoticesOptions{Timeout: 10 * time.Second})
+	c.Assert(err, check.IsNil)
+	c.Check(notices, check.HasLen, 0)
+	c.Check(cs.req.URL.Query(), check.DeepEquals, url.Values{
+		"timeout": {"10s"},
+	})
+}
+
+func (cs *clientSuite) TestNotice(c *check.C) {
+	cs.rsp = `{"type": "sync", "status-code": 200, "result": {
+		"id": "42",
+		"user-id": null,
+		"type": "change-update",
+		"key": "7",
+		"first-occurred": "2023-09-01T05:23:01Z",
+		"last-occurred": "2023-09-01T05:23:01Z",
+		"last-repeated": "2023-09-01T05:23:01Z",
+		"occurrences": 1,
+		"last-data": {"kind": "install-snap"},
+		"expire-after": "168h0m0s"
+	}}`
+
+	notice, err := cs.cli.Notice("42")
+	c.Assert(err, check.IsNil)
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/notices/42")
+	t := time.Date(2023, 9, 1, 5, 23, 1, 0, time.UTC)
+	c.Check(notice, check.DeepEquals, &client.Notice{
+		ID:            "42",
+		Type:          "change-update",
+		Key:           "7",
+		FirstOccurred: t,
+		LastOccurred:  t,
+		LastRepeated:  t,
+		Occurrences:   1,
+		LastData:      map[string]string{"kind": "install-snap"},
+		ExpireAfter:   7 * 24 * time.Hour,
+	})
+}
+
+func (cs *clientSuite) TestNoticeNotFound(c *check.C) {
+	cs.status = 404
+	cs.rsp = `{"type": "error", "status-code": 404, "result": {"message": "cannot find notice with ID \"42\""}}`
+
+	_, err := cs.cli.Notice("42")
+	c.Check(err, check.ErrorMatches, `cannot get notice "42": cannot find notice with ID "42"`)
+}
@@ -0,0 +1,204 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// PromptingRequest is an access request of a snap waiting for a decision of
+// the user.
+type PromptingRequest struct {
+	ID          string    `json:"id"`
+	Timestamp   time.Time `json:"timestamp"`
+	Snap        string    `json:"snap"`
+	App         string    `json:"app"`
+	Interface   string    `json:"interface"`
+	Path        string    `json:"path"`
+	Permissions []string  `json:"permissions"`
+}
+
+// PromptingReply is the decision of the user on a request.
+type PromptingReply struct {
+	// Outcome is either "allow" or "deny".
+	Outcome string `json:"outcome"`
+	// Lifespan is one of "once", "session", "forever" or "timespan".
+	Lifespan string `json:"lifespan"`
+	// PathPattern is the pattern of the paths the decision applies to,
+	// defaulting to the requested path.
+	PathPattern string `json:"path-pattern,omitempty"`
+	// Permissions are the permissions the decision applies to, defaulting
+	// to the requested ones.
+	Permissions []string `json:"permissions,omitempty"`
+	// Duration is for how long a decision with a "timespan" lifespan
+	// applies, e.g. "10m".
+	Duration string `json:"duration,omitempty"`
+}
+
+// PromptingRule is a decision of the user which applies to future requests.
+type PromptingRule struct {
+	ID          string    `json:"id"`
+	Timestamp   time.Time `json:"timestamp"`
+	Snap        string    `json:"snap"`
+	Interface   string    `json:"interface"`
+	PathPattern string    `json:"path-pattern"`
+	Permissions []string  `json:"permissions"`
+	Outcome     string    `json:"outcome"`
+	Lifespan    string    `json:"lifespan"`
+	Expiration  time.Time `json:"expiration,omitempty"`
+}
+
+// PromptingRulesOptions selects the rules to list or remove.
+type PromptingRulesOptions struct {
+	Snap      string
+	Interface string
+}
+
+// PromptingKernelRequest is an access request received from the kernel, as
+// forwarded to snapd by the prompting listener.
+type PromptingKernelRequest struct {
+	Pid         uint32   `json:"pid"`
+	Label       string   `json:"label"`
+	SubjectUID  uint32   `json:"subject-uid"`
+	Path        string   `json:"path"`
+	Permissions []string `json:"permissions"`
+}
+
+// PromptingRequests returns the pending requests of the calling user.
+func (client *Client) PromptingRequests() ([]*PromptingRequest, error) {
+	var reqs []*PromptingRequest
+	if _, err := client.doSync("GET", "/v2/prompting/requests", nil, nil, nil, &reqs); err != nil {
+		return nil, err
+	}
+	return reqs, nil
+}
+
+// PromptingRequest returns the pending request of the calling user with the
+// given ID.
+func (client *Client) PromptingRequest(id string) (*PromptingRequest, error) {
+	var req PromptingRequest
+	if _, err := client.doSync("GET", "/v2/prompting/requests/"+url.PathEscape(id), nil, nil, nil, &req); err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+// PromptingReply replies to the pending request with the given ID. It returns
+// the rule created from the reply, if its lifespan is not "once".
+func (client *Client) PromptingReply(id string, reply *PromptingReply) (*PromptingRule, error) {
+	b, err := json.Marshal(reply)
+	if err != nil {
+		return nil, err
+	}
+
+	var rule *PromptingRule
+	if _, err := client.doSync("POST", "/v2/prompting/requests/"+url.PathEscape(id), nil, nil, bytes.NewReader(b), &rule); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+// PromptingRules returns the rules of the calling user, optionally only
+// those of the snap and interface given in the options.
+func (client *Client) PromptingRules(opts *PromptingRulesOptions) ([]*PromptingRule, error) {
+	query := url.Values{}
+	if opts != nil {
+		if opts.Snap != "" {
+			query.Set("snap", opts.Snap)
+		}
+		if opts.Interface != "" {
+			query.Set("interface", opts.Interface)
+		}
+	}
+
+	var rules []*PromptingRule
+	if _, err := client.doSync("GET", "/v2/prompting/rules", query, nil, nil, &rules); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// PromptingRule returns the rule of the calling user with the given ID.
+func (client *Client) PromptingRule(id string) (*PromptingRule, error) {
+	var rule PromptingRule
+	if _, err := client.doSync("GET", "/v2/prompting/rules/"+url.PathEscape(id), nil, nil, nil, &rule); err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// PromptingRemoveRule removes the rule of the calling user with the given ID
+// and returns it.
+func (client *Client) PromptingRemoveRule(id string) (*PromptingRule, error) {
+	b, err := json.Marshal(map[string]string{"action": "remove"})
+	if err != nil {
+		return nil, err
+	}
+
+	var rule PromptingRule
+	if _, err := client.doSync("POST", "/v2/prompting/rules/"+url.PathEscape(id), nil, nil, bytes.NewReader(b), &rule); err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// PromptingRemoveRules removes the rules of the calling user for the snap
+// given in the options, optionally only those for the given interface, and
+// returns them.
+func (client *Client) PromptingRemoveRules(opts *PromptingRulesOptions) ([]*PromptingRule, error) {
+	if opts == nil || opts.Snap == "" {
+		return nil, fmt.Errorf("cannot remove prompting rules: no snap given")
+	}
+	b, err := json.Marshal(map[string]string{
+		"action":    "remove",
+		"snap":      opts.Snap,
+		"interface": opts.Interface,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var rules []*PromptingRule
+	if _, err := client.doSync("POST", "/v2/prompting/rules", nil, nil, bytes.NewReader(b), &rules); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// PromptingForward forwards a request received from the kernel to snapd and
+// waits for the decision, which may take until the user replies.
+func (client *Client) PromptingForward(req *PromptingKernelRequest) (allow bool, err error) {
+	b, err := json.Marshal(req)
+	if err != nil {
+		return false, err
+	}
+
+	var result struct {
+		Outcome string `json:"outcome"`
+	}
+	if _, err := client.doSyncWithOpts("POST", "/v2/prompting/requests", nil, nil, bytes.NewReader(b), &result, doNoTimeoutAndRetry); err != nil {
+		return false, err
+	}
+	return result.Outcome == "allow", nil
+}
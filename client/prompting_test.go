@@ -0,0 +1,251 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client_test
+
+import (
+	"encoding/json"
+	"net/url"
+	"time"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/client"
+)
+
+func (cs *clientSuite) TestPromptingRequests(c *check.C) {
+	cs.rsp = `{
+		"type": "sync",
+		"status-code": 200,
+		"result": [{
+			"id": "0000000000000001",
+			"timestamp": "2023-06-01T12:00:00Z",
+			"snap": "foo",
+			"app": "app",
+			"path": "/home/test/file.txt",
+			"permissions": ["read"]
+		}]
+	}`
+
+	reqs, err := cs.cli.PromptingRequests()
+	c.Assert(err, check.IsNil)
+	c.Check(cs.req.Method, check.Equals, "GET")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/prompting/requests")
+	c.Check(reqs, check.DeepEquals, []*client.PromptingRequest{{
+		ID:          "0000000000000001",
+		Timestamp:   time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC),
+		Snap:        "foo",
+		App:         "app",
+		Path:        "/home/test/file.txt",
+		Permissions: []string{"read"},
+	}})
+}
+
+func (cs *clientSuite) TestPromptingRequest(c *check.C) {
+	cs.rsp = `{
+		"type": "sync",
+		"status-code": 200,
+		"result": {"id": "0000000000000001", "snap": "foo", "path": "/home/test/file.txt", "permissions": ["read"]}
+	}`
+
+	req, err := cs.cli.PromptingRequest("0000000000000001")
+	c.Assert(err, check.IsNil)
+	c.Check(cs.req.Method, check.Equals, "GET")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/prompting/requests/0000000000000001")
+	c.Check(req.ID, check.Equals, "0000000000000001")
+	c.Check(req.Path, check.Equals, "/home/test/file.txt")
+}
+
+func (cs *clientSuite) TestPromptingRequestNotFound(c *check.C) {
+	cs.status = 404
+	cs.rsp = `{
+		"type": "error",
+		"status-code": 404,
+		"result": {"message": "no request with the given ID is pending"}
+	}`
+
+	_, err := cs.cli.PromptingRequest("0000000000000001")
+	c.Assert(err, check.ErrorMatches, "no request with the given ID is pending")
+}
+
+func (cs *clientSuite) TestPromptingReply(c *check.C) {
+	cs.rsp = `{
+		"type": "sync",
+		"status-code": 200,
+		"result": {
+			"id": "0000000000000002",
+			"snap": "foo",
+			"path-pattern": "/home/test/**",
+			"permissions": ["read"],
+			"outcome": "allow",
+			"lifespan": "forever"
+		}
+	}`
+
+	rule, err := cs.cli.PromptingReply("0000000000000001", &client.PromptingReply{
+		Outcome:     "allow",
+		Lifespan:    "forever",
+		PathPattern: "/home/test/**",
+	})
+	c.Assert(err, check.IsNil)
+	c.Check(cs.req.Method, check.Equals, "POST")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/prompting/requests/0000000000000001")
+	c.Check(rule, check.DeepEquals, &client.PromptingRule{
+		ID:          "0000000000000002",
+		Snap:        "foo",
+		PathPattern: "/home/test/**",
+		Permissions: []string{"read"},
+		Outcome:     "allow",
+		Lifespan:    "forever",
+	})
+
+	var body map[string]interface{}
+	c.Assert(json.NewDecoder(cs.req.Body).Decode(&body), check.IsNil)
+	c.Check(body, check.DeepEquals, map[string]interface{}{
+		"outcome":      "allow",
+		"lifespan":     "forever",
+		"path-pattern": "/home/test/**",
+	})
+}
+
+func (cs *clientSuite) TestPromptingReplyOnce(c *check.C) {
+	cs.rsp = `{"type": "sync", "status-code": 200, "result": null}`
+
+	rule, err := cs.cli.PromptingReply("0000000000000001", &client.PromptingReply{Outcome: "deny", Lifespan: "once"})
+	c.Assert(err, check.IsNil)
+	c.Check(rule, check.IsNil)
+}
+
+func (cs *clientSuite) TestPromptingRules(c *check.C) {
+	cs.rsp = `{
+		"type": "sync",
+		"status-code": 200,
+		"result": [{"id": "0000000000000001", "snap": "foo", "interface": "home", "path-pattern": "/home/test/*", "permissions": ["read", "write"], "outcome": "deny", "lifespan": "session"}]
+	}`
+
+	rules, err := cs.cli.PromptingRules(nil)
+	c.Assert(err, check.IsNil)
+	c.Check(cs.req.Method, check.Equals, "GET")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/prompting/rules")
+	c.Check(cs.req.URL.RawQuery, check.Equals, "")
+	c.Check(rules, check.DeepEquals, []*client.PromptingRule{{
+		ID:          "0000000000000001",
+		Snap:        "foo",
+		Interface:   "home",
+		PathPattern: "/home/test/*",
+		Permissions: []string{"read", "write"},
+		Outcome:     "deny",
+		Lifespan:    "session",
+	}})
+
+	_, err = cs.cli.PromptingRules(&client.PromptingRulesOptions{Snap: "foo", Interface: "home"})
+	c.Assert(err, check.IsNil)
+	c.Check(cs.req.URL.Query(), check.DeepEquals, url.Values{"snap": []string{"foo"}, "interface": []string{"home"}})
+}
+
+func (cs *clientSuite) TestPromptingRule(c *check.C) {
+	cs.rsp = `{
+		"type": "sync",
+		"status-code": 200,
+		"result": {"id": "0000000000000001", "snap": "foo", "interface": "home", "path-pattern": "/home/test/*", "permissions": ["read"], "outcome": "allow", "lifespan": "timespan", "expiration": "2023-06-01T12:10:00Z"}
+	}`
+
+	rule, err := cs.cli.PromptingRule("0000000000000001")
+	c.Assert(err, check.IsNil)
+	c.Check(cs.req.Method, check.Equals, "GET")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/prompting/rules/0000000000000001")
+	c.Check(rule, check.DeepEquals, &client.PromptingRule{
+		ID:          "0000000000000001",
+		Snap:        "foo",
+		Interface:   "home",
+		PathPattern: "/home/test/*",
+		Permissions: []string{"read"},
+		Outcome:     "allow",
+		Lifespan:    "timespan",
+		Expiration:  time.Date(2023, 6, 1, 12, 10, 0, 0, time.UTC),
+	})
+}
+
+func (cs *clientSuite) TestPromptingRemoveRule(c *check.C) {
+	cs.rsp = `{
+		"type": "sync",
+		"status-code": 200,
+		"result": {"id": "0000000000000001", "snap": "foo"}
+	}`
+
+	rule, err := cs.cli.PromptingRemoveRule("0000000000000001")
+	c.Assert(err, check.IsNil)
+	c.Check(cs.req.Method, check.Equals, "POST")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/prompting/rules/0000000000000001")
+	var body map[string]interface{}
+	c.Assert(json.NewDecoder(cs.req.Body).Decode(&body), check.IsNil)
+	c.Check(body, check.DeepEquals, map[string]interface{}{"action": "remove"})
+	c.Check(rule, check.DeepEquals, &client.PromptingRule{ID: "0000000000000001", Snap: "foo"})
+}
+
+func (cs *clientSuite) TestPromptingRemoveRules(c *check.C) {
+	cs.rsp = `{
+		"type": "sync",
+		"status-code": 200,
+		"result": [{"id": "0000000000000001", "snap": "foo"}, {"id": "0000000000000002", "snap": "foo"}]
+	}`
+
+	rules, err := cs.cli.PromptingRemoveRules(&client.PromptingRulesOptions{Snap: "foo"})
+	c.Assert(err, check.IsNil)
+	c.Check(cs.req.Method, check.Equals, "POST")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/prompting/rules")
+	var body map[string]interface{}
+	c.Assert(json.NewDecoder(cs.req.Body).Decode(&body), check.IsNil)
+	c.Check(body, check.DeepEquals, map[string]interface{}{"action": "remove", "snap": "foo", "interface": ""})
+	c.Check(rules, check.HasLen, 2)
+
+	_, err = cs.cli.PromptingRemoveRules(nil)
+	c.Check(err, check.ErrorMatches, "cannot remove prompting rules: no snap given")
+}
+
+func (cs *clientSuite) TestPromptingForward(c *check.C) {
+	cs.rsp = `{"type": "sync", "status-code": 200, "result": {"outcome": "allow"}}`
+
+	allow, err := cs.cli.PromptingForward(&client.PromptingKernelRequest{
+		Pid:         1234,
+		Label:       "snap.foo.app",
+		SubjectUID:  1000,
+		Path:        "/home/test/file.txt",
+		Permissions: []string{"read"},
+	})
+	c.Assert(err, check.IsNil)
+	c.Check(allow, check.Equals, true)
+	c.Check(cs.req.Method, check.Equals, "POST")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/prompting/requests")
+
+	var body map[string]interface{}
+	c.Assert(json.NewDecoder(cs.req.Body).Decode(&body), check.IsNil)
+	c.Check(body, check.DeepEquals, map[string]interface{}{
+		"pid":         1234.0,
+		"label":       "snap.foo.app",
+		"subject-uid": 1000.0,
+		"path":        "/home/test/file.txt",
+		"permissions": []interface{}{"read"},
+	})
+
+	cs.rsp = `{"type": "sync", "status-code": 200, "result": {"outcome": "deny"}}`
+	allow, err = cs.cli.PromptingForward(&client.PromptingKernelRequest{Path: "/foo", Permissions: []string{"read"}})
+	c.Assert(err, check.IsNil)
+	c.Check(allow, check.Equals, false)
+}
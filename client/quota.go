@@ -66,12 +66,18 @@ type QuotaJournalValues struct {
 	*QuotaJournalRate
 }
 
+type QuotaDiskValues struct {
+	Size     quantity.Size `json:"size,omitempty"`
+	UserData bool          `json:"user-data,omitempty"`
+}
+
 type QuotaValues struct {
 	Memory  quantity.Size       `json:"memory,omitempty"`
 	CPU     *QuotaCPUValues     `json:"cpu,omitempty"`
 	CPUSet  *QuotaCPUSetValues  `json:"cpu-set,omitempty"`
 	Threads int                 `json:"threads,omitempty"`
 	Journal *QuotaJournalValues `json:"journal,omitempty"`
+	Disk    *QuotaDiskValues    `json:"disk,omitempty"`
 }
 
 type EnsureQuotaOptions struct {
@@ -67,6 +67,10 @@ func (cs *clientSuite) TestEnsureQuotaGroup(c *check.C) {
 				RatePeriod: time.Minute,
 			},
 		},
+		Disk: &client.QuotaDiskValues{
+			Size:     quantity.SizeGiB,
+			UserData: true,
+		},
 	}
 
 	chgID, err := cs.cli.EnsureQuota("foo", &client.EnsureQuotaOptions{
@@ -105,6 +109,10 @@ func (cs *clientSuite) TestEnsureQuotaGroup(c *check.C) {
 				"rate-count":  json.Number("150"),
 				"rate-period": json.Number("60000000000"),
 			},
+			"disk": map[string]interface{}{
+				"size":      json.Number("1073741824"),
+				"user-data": true,
+			},
 		},
 	})
 }
@@ -42,14 +42,41 @@ const SnapshotExportMediaType = "application/x.snapd.snapshot"
 var (
 	ErrSnapshotSetNotFound   = errors.New("no snapshot set with the given ID")
 	ErrSnapshotSnapsNotFound = errors.New("no snapshot for the requested snaps found in the set with the given ID")
+	ErrSnapshotKeyRequired   = errors.New("snapshot set is encrypted: passphrase or private key required")
 )
 
 // A snapshotAction is used to request an operation on a snapshot.
 type snapshotAction struct {
-	SetID  uint64   `json:"set"`
-	Action string   `json:"action"`
-	Snaps  []string `json:"snaps,omitempty"`
-	Users  []string `json:"users,omitempty"`
+	SetID  uint64       `json:"set"`
+	Action string       `json:"action"`
+	Snaps  []string     `json:"snaps,omitempty"`
+	Users  []string     `json:"users,omitempty"`
+	Key    *SnapshotKey `json:"key,omitempty"`
+	Target string       `json:"target,omitempty"`
+}
+
+// A SnapshotKey is used to encrypt a snapshot set when saving it, or to
+// decrypt it when checking or restoring it. Either a passphrase is given, or
+// a base64 encoded X25519 key: the public key to encrypt, and the matching
+// private key to decrypt.
+type SnapshotKey struct {
+	Passphrase string `json:"passphrase,omitempty"`
+	PublicKey  string `json:"public-key,omitempty"`
+	PrivateKey string `json:"private-key,omitempty"`
+}
+
+// SnapshotEncryption describes how the archives of an encrypted snapshot are
+// protected.
+type SnapshotEncryption struct {
+	// Method is either "passphrase" or "public-key"
+	Method string `json:"method"`
+	// Salt used to derive a key from the passphrase
+	Salt []byte `json:"salt,omitempty"`
+	// EphemeralKey is the public half of the key used with the
+	// recipient's public key
+	EphemeralKey []byte `json:"ephemeral-key,omitempty"`
+	// WrappedKey is the encrypted key the archives are encrypted with
+	WrappedKey []byte `json:"wrapped-key"`
 }
 
 // A Snapshot is a collection of archives with a simple metadata json file
@@ -81,6 +108,9 @@ type Snapshot struct {
 	// dynamic snapshot options
 	Options *snap.SnapshotOptions `json:"options,omitempty"`
 
+	// set if the archives are encrypted
+	Encryption *SnapshotEncryption `json:"encryption,omitempty"`
+
 	// if the snapshot failed to open this will be the reason why
 	Broken string `json:"broken,omitempty"`
 
@@ -89,6 +119,10 @@ type Snapshot struct {
 	// newer snapd just updates this flag on the fly for snapshots
 	// returned by List().
 	Auto bool `json:"auto,omitempty"`
+
+	// set if the snapshot was created by the snapshot schedule; like
+	// Auto this is updated on the fly by List().
+	Scheduled bool `json:"scheduled,omitempty"`
 }
 
 // IsValid checks whether the snapshot is missing information that
@@ -195,26 +229,43 @@ func (client *Client) ForgetSnapshots(setID uint64, snaps []string) (changeID st
 // CheckSnapshots verifies the archive checksums in the given snapshot set.
 //
 // If snaps or users are non-empty, limit to checking only those
-// archives of the snapshot.
-func (client *Client) CheckSnapshots(setID uint64, snaps []string, users []string) (changeID string, err error) {
+// archives of the snapshot. The key is needed for encrypted snapshot sets.
+func (client *Client) CheckSnapshots(setID uint64, snaps []string, users []string, key *SnapshotKey) (changeID string, err error) {
 	return client.snapshotAction(&snapshotAction{
 		SetID:  setID,
 		Action: "check",
 		Snaps:  snaps,
 		Users:  users,
+		Key:    key,
 	})
 }
 
 // RestoreSnapshots extracts the given snapshot set.
 //
 // If snaps or users are non-empty, limit to checking only those
-// archives of the snapshot.
-func (client *Client) RestoreSnapshots(setID uint64, snaps []string, users []string) (changeID string, err error) {
+// archives of the snapshot. The key is needed for encrypted snapshot sets.
+func (client *Client) RestoreSnapshots(setID uint64, snaps []string, users []string, key *SnapshotKey) (changeID string, err error) {
 	return client.snapshotAction(&snapshotAction{
 		SetID:  setID,
 		Action: "restore",
 		Snaps:  snaps,
 		Users:  users,
+		Key:    key,
+	})
+}
+
+// RestoreSnapshotInto extracts the archive of the given snap from the
+// snapshot set into another installed instance of the same snap, for
+// example from "foo" into "foo_test". The key is needed for encrypted
+// snapshot sets.
+func (client *Client) RestoreSnapshotInto(setID uint64, snap, instanceName string, users []string, key *SnapshotKey) (changeID string, err error) {
+	return client.snapshotAction(&snapshotAction{
+		SetID:  setID,
+		Action: "restore",
+		Snaps:  []string{snap},
+		Users:  users,
+		Key:    key,
+		Target: instanceName,
 	})
 }
 
@@ -23,6 +23,11 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"os"
+	"path/filepath"
 
 	"golang.org/x/xerrors"
 
@@ -45,6 +50,9 @@ type System struct {
 	// Current is true when the system running now was installed from that
 	// recovery seed
 	Current bool `json:"current,omitempty"`
+	// DefaultRecoverySystem is true when the system is the one used by
+	// default for recovery
+	DefaultRecoverySystem bool `json:"default-recovery-system,omitempty"`
 	// Label of the recovery system
 	Label string `json:"label,omitempty"`
 	// Model information
@@ -140,6 +148,182 @@ func (client *Client) RebootToSystem(systemLabel, mode string) error {
 	return nil
 }
 
+// CreateSystemOptions holds the options for creating a new recovery system.
+type CreateSystemOptions struct {
+	// Label of the new recovery system.
+	Label string `json:"label,omitempty"`
+	// ValidationSets is a list of validation sets, in the account/name or
+	// account/name=sequence form, the snaps of the new recovery system
+	// must be consistent with.
+	ValidationSets []string `json:"validation-sets,omitempty"`
+	// MarkDefault makes the new recovery system the default one once
+	// it has been created.
+	MarkDefault bool `json:"mark-default,omitempty"`
+
+	// SnapPaths are local snap files to upload and use for the new
+	// recovery system instead of the installed snaps.
+	SnapPaths []string `json:"-"`
+	// AssertionPaths are files with the assertions needed to validate
+	// the snap files in SnapPaths.
+	AssertionPaths []string `json:"-"`
+}
+
+// CreateSystem issues a request to create a new recovery system from the
+// installed snaps or, if any are given, from the uploaded snap files.
+func (client *Client) CreateSystem(opts *CreateSystemOptions) (changeID string, err error) {
+	if opts == nil || opts.Label == "" {
+		return "", fmt.Errorf("cannot create a recovery system without a label")
+	}
+
+	if len(opts.SnapPaths) == 0 && len(opts.AssertionPaths) == 0 {
+		req := struct {
+			Action string `json:"action"`
+			*CreateSystemOptions
+		}{
+			Action:              "create",
+			CreateSystemOptions: opts,
+		}
+
+		var body bytes.Buffer
+		if err := json.NewEncoder(&body).Encode(&req); err != nil {
+			return "", err
+		}
+		changeID, err = client.doAsync("POST", "/v2/systems", nil, nil, &body)
+		if err != nil {
+			return "", xerrors.Errorf("cannot create recovery system %q: %v", opts.Label, err)
+		}
+		return changeID, nil
+	}
+
+	var assertions [][]byte
+	for _, path := range opts.AssertionPaths {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("cannot read assertions from %q: %w", path, err)
+		}
+		assertions = append(assertions, data)
+	}
+
+	var files []*os.File
+	for _, path := range opts.SnapPaths {
+		f, err := os.Open(path)
+		if err != nil {
+			for _, openFile := range files {
+				openFile.Close()
+			}
+			return "", fmt.Errorf("cannot open %q: %w", path, err)
+		}
+
+		files = append(files, f)
+	}
+
+	pr, pw := io.Pipe()
+	mw := multipart.NewWriter(pw)
+	go sendCreateSystemFiles(opts, assertions, files, pw, mw)
+
+	headers := map[string]string{
+		"Content-Type": mw.FormDataContentType(),
+	}
+
+	_, changeID, err = client.doAsyncFull("POST", "/v2/systems", nil, headers, pr, doNoTimeoutAndRetry)
+	if err != nil {
+		return "", xerrors.Errorf("cannot create recovery system %q: %v", opts.Label, err)
+	}
+	return changeID, nil
+}
+
+func sendCreateSystemFiles(opts *CreateSystemOptions, assertions [][]byte, files []*os.File, pw *io.PipeWriter, mw *multipart.Writer) {
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+
+	// pairs of field name and value
+	fields := [][2]string{
+		{"action", "create"},
+		{"label", opts.Label},
+	}
+	for _, vs := range opts.ValidationSets {
+		fields = append(fields, [2]string{"validation-sets", vs})
+	}
+	if opts.MarkDefault {
+		fields = append(fields, [2]string{"mark-default", "true"})
+	}
+	for _, assertion := range assertions {
+		fields = append(fields, [2]string{"assertion", string(assertion)})
+	}
+	for _, field := range fields {
+		if err := mw.WriteField(field[0], field[1]); err != nil {
+			pw.CloseWithError(err)
+			return
+		}
+	}
+
+	for i, file := range files {
+		fw, err := mw.CreateFormFile("snap", filepath.Base(opts.SnapPaths[i]))
+		if err != nil {
+			pw.CloseWithError(err)
+			return
+		}
+
+		if _, err := io.Copy(fw, file); err != nil {
+			pw.CloseWithError(err)
+			return
+		}
+	}
+
+	mw.Close()
+	pw.Close()
+}
+
+// RemoveSystem issues a request to remove the recovery system with the
+// given label.
+func (client *Client) RemoveSystem(systemLabel string) (changeID string, err error) {
+	if systemLabel == "" {
+		return "", fmt.Errorf("cannot remove a recovery system without a label")
+	}
+
+	req := struct {
+		Action string `json:"action"`
+	}{
+		Action: "remove",
+	}
+
+	var body bytes.Buffer
+	if err := json.NewEncoder(&body).Encode(&req); err != nil {
+		return "", err
+	}
+	changeID, err = client.doAsync("POST", "/v2/systems/"+systemLabel, nil, nil, &body)
+	if err != nil {
+		return "", xerrors.Errorf("cannot remove recovery system %q: %v", systemLabel, err)
+	}
+	return changeID, nil
+}
+
+// SetDefaultSystem issues a request to make the recovery system with the
+// given label the default one.
+func (client *Client) SetDefaultSystem(systemLabel string) error {
+	if systemLabel == "" {
+		return fmt.Errorf("cannot set the default recovery system without a label")
+	}
+
+	req := struct {
+		Action string `json:"action"`
+	}{
+		Action: "set-default",
+	}
+
+	var body bytes.Buffer
+	if err := json.NewEncoder(&body).Encode(&req); err != nil {
+		return err
+	}
+	if _, err := client.doSync("POST", "/v2/systems/"+systemLabel, nil, nil, &body, nil); err != nil {
+		return xerrors.Errorf("cannot set default recovery system %q: %v", systemLabel, err)
+	}
+	return nil
+}
+
 type StorageEncryptionSupport string
 
 const (
@@ -22,6 +22,7 @@ package client_test
 import (
 	"encoding/json"
 	"io/ioutil"
+	"path/filepath"
 
 	"gopkg.in/check.v1"
 
@@ -413,3 +414,167 @@ func (cs *clientSuite) TestRequestSystemInstallHappy(c *check.C) {
 		},
 	})
 }
+
+func (cs *clientSuite) TestCreateSystemHappy(c *check.C) {
+	cs.status = 202
+	cs.rsp = `{
+		"type": "async",
+		"status-code": 202,
+		"result": {},
+		"change": "d728"
+	}`
+	id, err := cs.cli.CreateSystem(&client.CreateSystemOptions{
+		Label:          "1234",
+		ValidationSets: []string{"foo/bar=2"},
+		MarkDefault:    true,
+	})
+	c.Assert(err, check.IsNil)
+	c.Check(id, check.Equals, "d728")
+	c.Check(cs.req.Method, check.Equals, "POST")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/systems")
+
+	body, err := ioutil.ReadAll(cs.req.Body)
+	c.Assert(err, check.IsNil)
+	var req map[string]interface{}
+	err = json.Unmarshal(body, &req)
+	c.Assert(err, check.IsNil)
+	c.Assert(req, check.DeepEquals, map[string]interface{}{
+		"action":          "create",
+		"label":           "1234",
+		"validation-sets": []interface{}{"foo/bar=2"},
+		"mark-default":    true,
+	})
+}
+
+func (cs *clientSuite) TestCreateSystemWithFiles(c *check.C) {
+	cs.status = 202
+	cs.rsp = `{
+		"type": "async",
+		"status-code": 202,
+		"result": {},
+		"change": "d728"
+	}`
+	dir := c.MkDir()
+	snapPath := filepath.Join(dir, "foo.snap")
+	c.Assert(ioutil.WriteFile(snapPath, []byte("snap-data"), 0644), check.IsNil)
+	assertPath := filepath.Join(dir, "foo.assert")
+	c.Assert(ioutil.WriteFile(assertPath, []byte("assertion-data"), 0644), check.IsNil)
+
+	id, err := cs.cli.CreateSystem(&client.CreateSystemOptions{
+		Label:          "1234",
+		MarkDefault:    true,
+		SnapPaths:      []string{snapPath},
+		AssertionPaths: []string{assertPath},
+	})
+	c.Assert(err, check.IsNil)
+	c.Check(id, check.Equals, "d728")
+	c.Check(cs.req.Method, check.Equals, "POST")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/systems")
+	c.Assert(cs.req.Header.Get("Content-Type"), check.Matches, "multipart/form-data; boundary=.*")
+
+	body, err := ioutil.ReadAll(cs.req.Body)
+	c.Assert(err, check.IsNil)
+	c.Check(string(body), check.Matches, `(?s).*Content-Disposition: form-data; name="action"\r\n\r\ncreate\r\n.*`)
+	c.Check(string(body), check.Matches, `(?s).*Content-Disposition: form-data; name="label"\r\n\r\n1234\r\n.*`)
+	c.Check(string(body), check.Matches, `(?s).*Content-Disposition: form-data; name="mark-default"\r\n\r\ntrue\r\n.*`)
+	c.Check(string(body), check.Matches, `(?s).*Content-Disposition: form-data; name="assertion"\r\n\r\nassertion-data\r\n.*`)
+	c.Check(string(body), check.Matches, `(?s).*Content-Disposition: form-data; name="snap"; filename="foo.snap"\r\nContent-Type: application/octet-stream\r\n\r\nsnap-data\r\n.*`)
+
+	_, ok := cs.req.Context().Deadline()
+	c.Check(ok, check.Equals, false)
+}
+
+func (cs *clientSuite) TestCreateSystemErrors(c *check.C) {
+	_, err := cs.cli.CreateSystem(nil)
+	c.Check(err, check.ErrorMatches, "cannot create a recovery system without a label")
+	_, err = cs.cli.CreateSystem(&client.CreateSystemOptions{})
+	c.Check(err, check.ErrorMatches, "cannot create a recovery system without a label")
+
+	_, err = cs.cli.CreateSystem(&client.CreateSystemOptions{
+		Label:     "1234",
+		SnapPaths: []string{"/does/not/exist.snap"},
+	})
+	c.Check(err, check.ErrorMatches, `cannot open "/does/not/exist.snap": open /does/not/exist.snap: no such file or directory`)
+
+	cs.status = 400
+	cs.rsp = `{
+	    "type": "error",
+	    "status-code": 400,
+	    "result": {"message": "failed"}
+	}`
+	_, err = cs.cli.CreateSystem(&client.CreateSystemOptions{Label: "1234"})
+	c.Check(err, check.ErrorMatches, `cannot create recovery system "1234": failed`)
+}
+
+func (cs *clientSuite) TestRemoveSystemHappy(c *check.C) {
+	cs.status = 202
+	cs.rsp = `{
+		"type": "async",
+		"status-code": 202,
+		"result": {},
+		"change": "d728"
+	}`
+	id, err := cs.cli.RemoveSystem("1234")
+	c.Assert(err, check.IsNil)
+	c.Check(id, check.Equals, "d728")
+	c.Check(cs.req.Method, check.Equals, "POST")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/systems/1234")
+
+	body, err := ioutil.ReadAll(cs.req.Body)
+	c.Assert(err, check.IsNil)
+	var req map[string]interface{}
+	err = json.Unmarshal(body, &req)
+	c.Assert(err, check.IsNil)
+	c.Assert(req, check.DeepEquals, map[string]interface{}{
+		"action": "remove",
+	})
+}
+
+func (cs *clientSuite) TestRemoveSystemErrors(c *check.C) {
+	_, err := cs.cli.RemoveSystem("")
+	c.Check(err, check.ErrorMatches, "cannot remove a recovery system without a label")
+
+	cs.status = 400
+	cs.rsp = `{
+	    "type": "error",
+	    "status-code": 400,
+	    "result": {"message": "failed"}
+	}`
+	_, err = cs.cli.RemoveSystem("1234")
+	c.Check(err, check.ErrorMatches, `cannot remove recovery system "1234": failed`)
+}
+
+func (cs *clientSuite) TestSetDefaultSystemHappy(c *check.C) {
+	cs.rsp = `{
+	    "type": "sync",
+	    "status-code": 200,
+	    "result": {}
+	}`
+	err := cs.cli.SetDefaultSystem("1234")
+	c.Assert(err, check.IsNil)
+	c.Check(cs.req.Method, check.Equals, "POST")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/systems/1234")
+
+	body, err := ioutil.ReadAll(cs.req.Body)
+	c.Assert(err, check.IsNil)
+	var req map[string]interface{}
+	err = json.Unmarshal(body, &req)
+	c.Assert(err, check.IsNil)
+	c.Assert(req, check.DeepEquals, map[string]interface{}{
+		"action": "set-default",
+	})
+}
+
+func (cs *clientSuite) TestSetDefaultSystemErrors(c *check.C) {
+	err := cs.cli.SetDefaultSystem("")
+	c.Check(err, check.ErrorMatches, "cannot set the default recovery system without a label")
+
+	cs.status = 400
+	cs.rsp = `{
+	    "type": "error",
+	    "status-code": 400,
+	    "result": {"message": "failed"}
+	}`
+	err = cs.cli.SetDefaultSystem("1234")
+	c.Check(err, check.ErrorMatches, `cannot set default recovery system "1234": failed`)
+}
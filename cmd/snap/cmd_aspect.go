@@ -0,0 +1,53 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/snapcore/snapd/i18n"
+)
+
+type cmdAspect struct{}
+
+var shortAspectHelp = i18n.G("Get and set aspect configuration")
+var longAspectHelp = i18n.G(`
+The aspect command contains sub-commands to read and write configuration
+through an aspect, a view over the configuration of an account's aspect
+bundle. Aspects are identified as <account-id>/<bundle>/<aspect>.
+`)
+
+// validateAspectID checks that the aspect identifier has the form
+// <account-id>/<bundle>/<aspect>.
+func validateAspectID(id string) error {
+	parts := strings.Split(id, "/")
+	if len(parts) != 3 {
+		return fmt.Errorf(i18n.G("aspect identifier must conform to format: <account-id>/<bundle>/<aspect>"))
+	}
+
+	for _, part := range parts {
+		if part == "" {
+			return fmt.Errorf(i18n.G("aspect identifier must conform to format: <account-id>/<bundle>/<aspect>"))
+		}
+	}
+
+	return nil
+}
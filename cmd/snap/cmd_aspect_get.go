@@ -0,0 +1,131 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jessevdk/go-flags"
+
+	"github.com/snapcore/snapd/i18n"
+)
+
+var shortAspectGetHelp = i18n.G("Print aspect fields")
+var longAspectGetHelp = i18n.G(`
+The get command prints the values of the requested fields of an aspect.
+
+    $ snap aspect get system/network/wifi-setup ssid
+    my-network
+
+If multiple fields are provided, the corresponding values are returned:
+
+    $ snap aspect get system/network/wifi-setup ssid ssids
+    Key    Value
+    ssid   my-network
+    ssids  [my-network other-network]
+`)
+
+type cmdAspectGet struct {
+	clientMixin
+	Positional struct {
+		Aspect string   `required:"yes"`
+		Fields []string `required:"1"`
+	} `positional-args:"yes" required:"yes"`
+
+	Document bool `short:"d"`
+}
+
+func init() {
+	addAspectCommand("get", shortAspectGetHelp, longAspectGetHelp, func() flags.Commander { return &cmdAspectGet{} },
+		map[string]string{
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"d": i18n.G("Always return document, even with single field"),
+		}, []argDesc{
+			{
+				// TRANSLATORS: This needs to begin with < and end with >
+				name: i18n.G("<aspect-id>"),
+				// TRANSLATORS: This should not start with a lowercase letter.
+				desc: i18n.G("Aspect identifier formatted as <account-id>/<bundle>/<aspect>"),
+			},
+			{
+				// TRANSLATORS: This needs to begin with < and end with >
+				name: i18n.G("<field>"),
+				// TRANSLATORS: This should not start with a lowercase letter.
+				desc: i18n.G("Aspect field to be read"),
+			},
+		})
+}
+
+func (x *cmdAspectGet) Execute(args []string) error {
+	if len(args) > 0 {
+		// TRANSLATORS: the %s is the list of extra arguments
+		return fmt.Errorf(i18n.G("too many arguments: %s"), strings.Join(args, " "))
+	}
+
+	if err := validateAspectID(x.Positional.Aspect); err != nil {
+		return err
+	}
+
+	values, err := x.client.AspectGet(x.Positional.Aspect, x.Positional.Fields)
+	if err != nil {
+		return err
+	}
+
+	if x.Document {
+		return outputAspectJSON(values)
+	}
+
+	if len(x.Positional.Fields) == 1 {
+		value := values[x.Positional.Fields[0]]
+		if s, ok := value.(string); ok {
+			fmt.Fprintln(Stdout, s)
+			return nil
+		}
+		return outputAspectJSON(value)
+	}
+
+	fields := make([]string, 0, len(values))
+	for field := range values {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	w := tabWriter()
+	defer w.Flush()
+
+	fmt.Fprintf(w, "Key\tValue\n")
+	for _, field := range fields {
+		fmt.Fprintf(w, "%s\t%v\n", field, values[field])
+	}
+	return nil
+}
+
+func outputAspectJSON(value interface{}) error {
+	bytes, err := json.MarshalIndent(value, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(Stdout, string(bytes))
+	return nil
+}
@@ -0,0 +1,100 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"github.com/jessevdk/go-flags"
+
+	"github.com/snapcore/snapd/i18n"
+)
+
+var shortAspectSetHelp = i18n.G("Change aspect fields")
+var longAspectSetHelp = i18n.G(`
+The set command changes the provided aspect fields as requested.
+
+    $ snap aspect set system/network/wifi-setup ssid=my-network password=$PASSWORD
+
+All fields are changed at once and the values are validated against the
+aspect bundle's storage schema.
+
+Fields may be unset with an exclamation mark:
+    $ snap aspect set system/network/wifi-setup password!
+`)
+
+type cmdAspectSet struct {
+	waitMixin
+	Positional struct {
+		Aspect string
+		Values []string `required:"1"`
+	} `positional-args:"yes" required:"yes"`
+
+	Typed  bool `short:"t"`
+	String bool `short:"s"`
+}
+
+func init() {
+	addAspectCommand("set", shortAspectSetHelp, longAspectSetHelp, func() flags.Commander { return &cmdAspectSet{} },
+		waitDescs.also(map[string]string{
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"t": i18n.G("Parse the value strictly as JSON document"),
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"s": i18n.G("Parse the value as a string"),
+		}), []argDesc{
+			{
+				// TRANSLATORS: This needs to begin with < and end with >
+				name: i18n.G("<aspect-id>"),
+				// TRANSLATORS: This should not start with a lowercase letter.
+				desc: i18n.G("Aspect identifier formatted as <account-id>/<bundle>/<aspect>"),
+			}, {
+				// TRANSLATORS: This needs to begin with < and end with >
+				name: i18n.G("<field value>"),
+				// TRANSLATORS: This should not start with a lowercase letter.
+				desc: i18n.G("Set (field=value) or unset (field!) aspect field"),
+			},
+		})
+}
+
+func (x *cmdAspectSet) Execute(args []string) error {
+	if err := validateAspectID(x.Positional.Aspect); err != nil {
+		return err
+	}
+
+	values, err := parseConfigValues(x.Positional.Values, &parseConfigOptions{
+		String: x.String,
+		Typed:  x.Typed,
+	})
+	if err != nil {
+		return err
+	}
+
+	id, err := x.client.AspectSet(x.Positional.Aspect, values)
+	if err != nil {
+		return err
+	}
+
+	if _, err := x.wait(id); err != nil {
+		if err == noWait {
+			return nil
+		}
+		return err
+	}
+
+	return nil
+}
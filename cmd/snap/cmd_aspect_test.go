@@ -0,0 +1,136 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main_test
+
+import (
+	"fmt"
+	"net/http"
+
+	"gopkg.in/check.v1"
+
+	main "github.com/snapcore/snapd/cmd/snap"
+)
+
+type aspectSuite struct {
+	BaseSnapSuite
+}
+
+var _ = check.Suite(&aspectSuite{})
+
+func (s *aspectSuite) mockAspectGetServer(c *check.C, fields string, result string) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, check.Equals, "GET")
+		c.Check(r.URL.Path, check.Equals, "/v2/aspects/system/network/wifi-setup")
+		c.Check(r.URL.Query().Get("fields"), check.Equals, fields)
+		fmt.Fprintf(w, `{"type": "sync", "status-code": 200, "result": %s}`, result)
+	})
+}
+
+func (s *aspectSuite) TestAspectGetSingleString(c *check.C) {
+	s.mockAspectGetServer(c, "ssid", `{"ssid": "foo"}`)
+
+	rest, err := main.Parser(main.Client()).ParseArgs([]string{"aspect", "get", "system/network/wifi-setup", "ssid"})
+	c.Assert(err, check.IsNil)
+	c.Check(rest, check.HasLen, 0)
+	c.Check(s.Stdout(), check.Equals, "foo\n")
+	c.Check(s.Stderr(), check.Equals, "")
+}
+
+func (s *aspectSuite) TestAspectGetSingleNonString(c *check.C) {
+	s.mockAspectGetServer(c, "ssids", `{"ssids": ["foo", "bar"]}`)
+
+	_, err := main.Parser(main.Client()).ParseArgs([]string{"aspect", "get", "system/network/wifi-setup", "ssids"})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Equals, "[\n\t\"foo\",\n\t\"bar\"\n]\n")
+}
+
+func (s *aspectSuite) TestAspectGetMany(c *check.C) {
+	s.mockAspectGetServer(c, "ssid,status", `{"ssid": "foo", "status": "connected"}`)
+
+	_, err := main.Parser(main.Client()).ParseArgs([]string{"aspect", "get", "system/network/wifi-setup", "ssid", "status"})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Equals, `Key     Value
+ssid    foo
+status  connected
+`)
+}
+
+func (s *aspectSuite) TestAspectGetDocument(c *check.C) {
+	s.mockAspectGetServer(c, "ssid", `{"ssid": "foo"}`)
+
+	_, err := main.Parser(main.Client()).ParseArgs([]string{"aspect", "get", "-d", "system/network/wifi-setup", "ssid"})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Equals, "{\n\t\"ssid\": \"foo\"\n}\n")
+}
+
+func (s *aspectSuite) TestAspectGetError(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(404)
+		fmt.Fprintln(w, `{"type": "error", "status-code": 404, "result": {"message": "aspect system/network/wifi-setup not found", "kind": "aspect-not-found"}}`)
+	})
+
+	_, err := main.Parser(main.Client()).ParseArgs([]string{"aspect", "get", "system/network/wifi-setup", "ssid"})
+	c.Assert(err, check.ErrorMatches, "aspect system/network/wifi-setup not found")
+}
+
+func (s *aspectSuite) TestAspectInvalidID(c *check.C) {
+	for _, id := range []string{"system", "system/network", "system//wifi-setup", "a/b/c/d"} {
+		_, err := main.Parser(main.Client()).ParseArgs([]string{"aspect", "get", id, "ssid"})
+		c.Check(err, check.ErrorMatches, "aspect identifier must conform to format: <account-id>/<bundle>/<aspect>")
+
+		_, err = main.Parser(main.Client()).ParseArgs([]string{"aspect", "set", id, "ssid=foo"})
+		c.Check(err, check.ErrorMatches, "aspect identifier must conform to format: <account-id>/<bundle>/<aspect>")
+	}
+}
+
+func (s *aspectSuite) TestAspectSet(c *check.C) {
+	var putCalls int
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/aspects/system/network/wifi-setup":
+			c.Check(r.Method, check.Equals, "PUT")
+			c.Check(DecodedRequestBody(c, r), check.DeepEquals, map[string]interface{}{
+				"ssid":     "foo",
+				"password": nil,
+				"ssids":    []interface{}{"foo", "bar"},
+			})
+			w.WriteHeader(202)
+			fmt.Fprintln(w, `{"type": "async", "status-code": 202, "change": "zzz"}`)
+			putCalls++
+		case "/v2/changes/zzz":
+			c.Check(r.Method, check.Equals, "GET")
+			fmt.Fprintln(w, `{"type": "sync", "result": {"ready": true, "status": "Done"}}`)
+		default:
+			c.Fatalf("unexpected path %q", r.URL.Path)
+		}
+	})
+
+	_, err := main.Parser(main.Client()).ParseArgs([]string{"aspect", "set", "system/network/wifi-setup", "ssid=foo", "password!", `ssids=["foo", "bar"]`})
+	c.Assert(err, check.IsNil)
+	c.Check(putCalls, check.Equals, 1)
+}
+
+func (s *aspectSuite) TestAspectSetInvalidValue(c *check.C) {
+	_, err := main.Parser(main.Client()).ParseArgs([]string{"aspect", "set", "system/network/wifi-setup", "ssid"})
+	c.Assert(err, check.ErrorMatches, `invalid configuration: "ssid" \(want key=value\)`)
+
+	_, err = main.Parser(main.Client()).ParseArgs([]string{"aspect", "set", "-t", "system/network/wifi-setup", "ssid=foo"})
+	c.Assert(err, check.ErrorMatches, `failed to parse JSON: .*`)
+}
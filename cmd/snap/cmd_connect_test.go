@@ -53,9 +53,15 @@ $ snap connect <snap>:<plug>
 Connects the provided plug to the slot in the core snap with a name matching
 the plug name.
 
+When the slot belongs to a hotplugged device, the --persist-rule flag can be
+added to the connect command to also connect the plug to the device whenever
+it is plugged in again.
+
 [connect command options]
       --no-wait          Do not wait for the operation to finish but just print
                          the change id.
+      --persist-rule     Reconnect the plug whenever the hotplugged device of
+                         the slot reappears.
 `
 	s.testSubCommandHelp(c, "connect", msg)
 }
@@ -94,6 +100,41 @@ func (s *SnapSuite) TestConnectExplicitEverything(c *C) {
 	c.Assert(rest, DeepEquals, []string{})
 }
 
+func (s *SnapSuite) TestConnectPersistRule(c *C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/interfaces":
+			c.Check(r.Method, Equals, "POST")
+			c.Check(DecodedRequestBody(c, r), DeepEquals, map[string]interface{}{
+				"action":       "connect",
+				"persist-rule": true,
+				"plugs": []interface{}{
+					map[string]interface{}{
+						"snap": "consumer",
+						"plug": "camera",
+					},
+				},
+				"slots": []interface{}{
+					map[string]interface{}{
+						"snap": "core",
+						"slot": "webcam",
+					},
+				},
+			})
+			w.WriteHeader(202)
+			fmt.Fprintln(w, `{"type":"async", "status-code": 202, "change": "zzz"}`)
+		case "/v2/changes/zzz":
+			c.Check(r.Method, Equals, "GET")
+			fmt.Fprintln(w, `{"type":"sync", "result":{"ready": true, "status": "Done"}}`)
+		default:
+			c.Fatalf("unexpected path %q", r.URL.Path)
+		}
+	})
+	rest, err := Parser(Client()).ParseArgs([]string{"connect", "--persist-rule", "consumer:camera", "core:webcam"})
+	c.Assert(err, IsNil)
+	c.Assert(rest, DeepEquals, []string{})
+}
+
 func (s *SnapSuite) TestConnectExplicitPlugImplicitSlot(c *C) {
 	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
 		switch r.URL.Path {
@@ -0,0 +1,188 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
+
+	"github.com/jessevdk/go-flags"
+	"golang.org/x/crypto/ssh/terminal"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/i18n"
+)
+
+var shortExecRemoteHelp = i18n.G("Run a command in a snap's confinement through snapd")
+var longExecRemoteHelp = i18n.G(`
+The exec-remote command asks snapd to run the given snap application with
+the given arguments, as if through "snap run", and connects it to the
+terminal. Input, output and signals are passed on to the command, and
+snap exits with the exit code of the command.
+
+By default the command runs as root, in the home directory of the user,
+and in a pseudo-terminal if both the input and output of snap are
+terminals. Use -- to separate the options of the application from those
+of snap.
+`)
+
+type cmdExecRemote struct {
+	clientMixin
+	User  string   `long:"user"`
+	Env   []string `long:"env"`
+	Cwd   string   `long:"cwd"`
+	Tty   bool     `short:"t" long:"tty"`
+	NoTty bool     `short:"T" long:"no-tty"`
+
+	Positional struct {
+		SnapApp string   `required:"yes"`
+		Args    []string `positional-arg-name:"<args>"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+func init() {
+	addCommand("exec-remote", shortExecRemoteHelp, longExecRemoteHelp, func() flags.Commander {
+		return &cmdExecRemote{}
+	}, map[string]string{
+		// TRANSLATORS: This should not start with a lowercase letter.
+		"user": i18n.G("Run the command as the given user"),
+		// TRANSLATORS: This should not start with a lowercase letter.
+		"env": i18n.G("Set an environment variable for the command, as NAME=VALUE"),
+		// TRANSLATORS: This should not start with a lowercase letter.
+		"cwd": i18n.G("Run the command in the given directory"),
+		// TRANSLATORS: This should not start with a lowercase letter.
+		"tty": i18n.G("Run the command in a pseudo-terminal"),
+		// TRANSLATORS: This should not start with a lowercase letter.
+		"no-tty": i18n.G("Do not run the command in a pseudo-terminal"),
+	}, []argDesc{{
+		// TRANSLATORS: This needs to begin with < and end with >
+		name: i18n.G("<snap>.<app>"),
+		// TRANSLATORS: This should not start with a lowercase letter.
+		desc: i18n.G("The snap application to run"),
+	}, {
+		// TRANSLATORS: This needs to begin with < and end with >
+		name: i18n.G("<args>"),
+		// TRANSLATORS: This should not start with a lowercase letter.
+		desc: i18n.G("Arguments to pass to the application"),
+	}})
+}
+
+var (
+	terminalMakeRaw = terminal.MakeRaw
+	terminalRestore = terminal.Restore
+)
+
+func (x *cmdExecRemote) Execute(args []string) error {
+	if len(args) > 0 {
+		return ErrExtraArgs
+	}
+	if x.Tty && x.NoTty {
+		return fmt.Errorf(i18n.G("cannot use --tty and --no-tty together"))
+	}
+
+	opts := &client.ExecOptions{
+		Command:    append([]string{x.Positional.SnapApp}, x.Positional.Args...),
+		User:       x.User,
+		WorkingDir: x.Cwd,
+		Tty:        x.Tty || (!x.NoTty && isStdinTTY && isStdoutTTY),
+	}
+	for _, kv := range x.Env {
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok || k == "" {
+			return fmt.Errorf(i18n.G("invalid environment variable %q: expected NAME=VALUE"), kv)
+		}
+		if opts.Environment == nil {
+			opts.Environment = make(map[string]string)
+		}
+		opts.Environment[k] = v
+	}
+
+	control := make(chan *client.ExecControl, 1)
+	stdio := &client.ExecStdio{
+		Stdin:   Stdin,
+		Stdout:  Stdout,
+		Stderr:  Stderr,
+		Control: control,
+	}
+
+	var sigs []os.Signal
+	if opts.Tty {
+		if term := os.Getenv("TERM"); term != "" {
+			if _, ok := opts.Environment["TERM"]; !ok {
+				if opts.Environment == nil {
+					opts.Environment = make(map[string]string)
+				}
+				opts.Environment["TERM"] = term
+			}
+		}
+		opts.Width, opts.Height = termSize()
+		if isStdinTTY {
+			state, err := terminalMakeRaw(0)
+			if err != nil {
+				return fmt.Errorf(i18n.G("cannot put terminal in raw mode: %v"), err)
+			}
+			defer terminalRestore(0, state)
+		}
+		// with a terminal, interrupts are passed on as input
+		sigs = []os.Signal{syscall.SIGWINCH}
+	} else {
+		sigs = []os.Signal{syscall.SIGINT, syscall.SIGTERM}
+	}
+
+	ch, stop := signalNotify(sigs...)
+	defer stop()
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			var sig os.Signal
+			select {
+			case sig = <-ch:
+			case <-done:
+				return
+			}
+			ctl := &client.ExecControl{Command: "signal"}
+			if sig == syscall.SIGWINCH {
+				ctl.Command = "resize"
+				ctl.Width, ctl.Height = termSize()
+			} else if s, ok := sig.(syscall.Signal); ok {
+				ctl.Signal = int(s)
+			}
+			select {
+			case control <- ctl:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	exitCode, err := x.client.Exec(opts, stdio)
+	if err != nil {
+		return err
+	}
+	if exitCode != 0 {
+		// deferred calls still run, restoring the terminal, before
+		// main handles the exit status
+		panic(&exitStatus{exitCode})
+	}
+	return nil
+}
@@ -0,0 +1,195 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"syscall"
+
+	"golang.org/x/crypto/ssh/terminal"
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/client"
+	snap "github.com/snapcore/snapd/cmd/snap"
+)
+
+func (s *SnapSuite) mockExecServer(c *C, handle func(opts *client.ExecOptions, r *bufio.Reader, w io.Writer)) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, Equals, "POST")
+		c.Check(r.URL.Path, Equals, "/v2/exec")
+		c.Check(r.Header.Get("Upgrade"), Equals, client.ExecUpgradeProtocol)
+		var opts client.ExecOptions
+		c.Assert(json.NewDecoder(r.Body).Decode(&opts), IsNil)
+
+		conn, rw, err := w.(http.Hijacker).Hijack()
+		c.Assert(err, IsNil)
+		defer conn.Close()
+		fmt.Fprintf(conn, "HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: %s\r\n\r\n", client.ExecUpgradeProtocol)
+		handle(&opts, rw.Reader, conn)
+	})
+}
+
+func writeExit(c *C, w io.Writer, code int) {
+	payload, err := json.Marshal(&client.ExecExit{ExitCode: code})
+	c.Assert(err, IsNil)
+	c.Assert(client.WriteExecFrame(w, client.ExecStreamExit, payload), IsNil)
+}
+
+func (s *SnapSuite) TestExecRemote(c *C) {
+	s.mockExecServer(c, func(opts *client.ExecOptions, r *bufio.Reader, w io.Writer) {
+		c.Check(opts, DeepEquals, &client.ExecOptions{
+			Command:     []string{"foo.bar", "--baz", "quux"},
+			User:        "test",
+			Environment: map[string]string{"FOO": "bar=baz", "EMPTY": ""},
+			WorkingDir:  "/tmp",
+		})
+		stream, payload, err := client.ReadExecFrame(r)
+		c.Assert(err, IsNil)
+		c.Check(stream, Equals, client.ExecStreamStdin)
+		c.Check(string(payload), Equals, "some input")
+		stream, payload, err = client.ReadExecFrame(r)
+		c.Assert(err, IsNil)
+		c.Check(stream, Equals, client.ExecStreamStdin)
+		c.Check(payload, HasLen, 0)
+
+		client.WriteExecFrame(w, client.ExecStreamStdout, []byte("some output\n"))
+		client.WriteExecFrame(w, client.ExecStreamStderr, []byte("some error\n"))
+		writeExit(c, w, 0)
+	})
+	s.stdin.WriteString("some input")
+
+	rest, err := snap.Parser(snap.Client()).ParseArgs([]string{"exec-remote", "--user=test", "--env", "FOO=bar=baz", "--env=EMPTY=", "--cwd=/tmp", "--", "foo.bar", "--baz", "quux"})
+	c.Assert(err, IsNil)
+	c.Check(rest, HasLen, 0)
+	c.Check(s.Stdout(), Equals, "some output\n")
+	c.Check(s.Stderr(), Equals, "some error\n")
+}
+
+func (s *SnapSuite) TestExecRemoteTty(c *C) {
+	restore := snap.MockTermSize(func() (int, int) { return 100, 40 })
+	defer restore()
+	os.Setenv("TERM", "xterm")
+	defer os.Unsetenv("TERM")
+
+	s.mockExecServer(c, func(opts *client.ExecOptions, r *bufio.Reader, w io.Writer) {
+		c.Check(opts, DeepEquals, &client.ExecOptions{
+			Command:     []string{"foo"},
+			Environment: map[string]string{"TERM": "xterm"},
+			Tty:         true,
+			Width:       100,
+			Height:      40,
+		})
+		writeExit(c, w, 0)
+	})
+
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"exec-remote", "-t", "foo"})
+	c.Assert(err, IsNil)
+}
+
+func (s *SnapSuite) TestExecRemoteAutoTty(c *C) {
+	restore := snap.MockTermSize(func() (int, int) { return 100, 40 })
+	defer restore()
+	restore = snap.MockIsStdoutTTY(true)
+	defer restore()
+	restore = snap.MockIsStdinTTY(true)
+	defer restore()
+	var raw, restored int
+	restore = snap.MockTerminalRawMode(func(fd int) (*terminal.State, error) {
+		c.Check(fd, Equals, 0)
+		raw++
+		return nil, nil
+	}, func(fd int, state *terminal.State) error {
+		c.Check(fd, Equals, 0)
+		restored++
+		return nil
+	})
+	defer restore()
+
+	for _, t := range []struct {
+		args []string
+		tty  bool
+	}{
+		{[]string{"exec-remote", "foo"}, true},
+		{[]string{"exec-remote", "-T", "foo"}, false},
+	} {
+		s.mockExecServer(c, func(opts *client.ExecOptions, r *bufio.Reader, w io.Writer) {
+			c.Check(opts.Tty, Equals, t.tty, Commentf("%v", t.args))
+			writeExit(c, w, 0)
+		})
+		_, err := snap.Parser(snap.Client()).ParseArgs(t.args)
+		c.Assert(err, IsNil)
+	}
+	// only the session with a terminal changes its mode
+	c.Check(raw, Equals, 1)
+	c.Check(restored, Equals, 1)
+}
+
+func (s *SnapSuite) TestExecRemoteForwardsSignals(c *C) {
+	sigCh := make(chan os.Signal, 1)
+	restore := snap.MockSignalNotify(func(sig ...os.Signal) (chan os.Signal, func()) {
+		c.Check(sig, DeepEquals, []os.Signal{syscall.SIGINT, syscall.SIGTERM})
+		return sigCh, func() {}
+	})
+	defer restore()
+
+	s.mockExecServer(c, func(opts *client.ExecOptions, r *bufio.Reader, w io.Writer) {
+		sigCh <- syscall.SIGTERM
+		for {
+			stream, payload, err := client.ReadExecFrame(r)
+			c.Assert(err, IsNil)
+			if stream == client.ExecStreamControl {
+				c.Check(string(payload), Equals, `{"command":"signal","signal":15}`)
+				break
+			}
+		}
+		writeExit(c, w, 143)
+	})
+
+	c.Check(func() {
+		snap.Parser(snap.Client()).ParseArgs([]string{"exec-remote", "foo"})
+	}, PanicMatches, `internal error: exitStatus\{143\} .*`)
+}
+
+func (s *SnapSuite) TestExecRemoteErrors(c *C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.URL.Path, Equals, "/v2/exec")
+		w.WriteHeader(404)
+		fmt.Fprintln(w, `{"type":"error","result":{"message":"snap \"foo\" has no app \"bar\"","kind":"app-not-found"},"status-code":404}`)
+	})
+
+	for _, t := range []struct {
+		args []string
+		err  string
+	}{
+		{[]string{"exec-remote"}, `the required argument .* was not provided`},
+		{[]string{"exec-remote", "-t", "-T", "foo"}, `cannot use --tty and --no-tty together`},
+		{[]string{"exec-remote", "--env=FOO", "foo"}, `invalid environment variable "FOO": expected NAME=VALUE`},
+		{[]string{"exec-remote", "--env==bar", "foo"}, `invalid environment variable "=bar": expected NAME=VALUE`},
+		{[]string{"exec-remote", "foo.bar"}, `snap "foo" has no app "bar"`},
+	} {
+		_, err := snap.Parser(snap.Client()).ParseArgs(t.args)
+		c.Check(err, ErrorMatches, t.err, Commentf("%v", t.args))
+	}
+}
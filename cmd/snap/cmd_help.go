@@ -217,13 +217,15 @@ var helpCategories = []helpCategory{
 		Description: i18n.G("manage services"),
 		Commands:    []string{"services", "start", "stop", "restart", "logs"},
 	}, {
-		Label:       i18n.G("Permissions"),
-		Description: i18n.G("manage permissions"),
-		Commands:    []string{"connections", "interface", "connect", "disconnect"},
+		Label:           i18n.G("Permissions"),
+		Description:     i18n.G("manage permissions"),
+		Commands:        []string{"connections", "interface", "connect", "disconnect"},
+		AllOnlyCommands: []string{"prompting-rules"},
 	}, {
-		Label:       i18n.G("Configuration"),
-		Description: i18n.G("system administration and configuration"),
-		Commands:    []string{"get", "set", "unset", "wait"},
+		Label:           i18n.G("Configuration"),
+		Description:     i18n.G("system administration and configuration"),
+		Commands:        []string{"get", "set", "unset", "wait"},
+		AllOnlyCommands: []string{"aspect"},
 	}, {
 		Label:       i18n.G("App Aliases"),
 		Description: i18n.G("manage aliases"),
@@ -255,13 +257,13 @@ var helpCategories = []helpCategory{
 		Label:           i18n.G("Introspection"),
 		Other:           true,
 		Description:     i18n.G("introspection and debugging of snapd"),
-		Commands:        []string{"version"},
+		Commands:        []string{"version", "notices"},
 		AllOnlyCommands: []string{"debug"},
 	}, {
 		Label:           i18n.G("Development"),
 		Description:     i18n.G("developer-oriented features"),
 		Commands:        []string{"download", "pack", "run", "try"},
-		AllOnlyCommands: []string{"prepare-image"},
+		AllOnlyCommands: []string{"prepare-image", "exec-remote"},
 	}, {
 		Label:       i18n.G("Quota Groups"),
 		Description: i18n.G("Manage quota groups for snaps"),
@@ -0,0 +1,114 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jessevdk/go-flags"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/i18n"
+)
+
+type cmdNotices struct {
+	clientMixin
+	timeMixin
+
+	Types   []string `long:"type" value-name:"<type>"`
+	Keys    []string `long:"key" value-name:"<key>"`
+	After   string   `long:"after" value-name:"<timestamp>"`
+	Timeout string   `long:"timeout" value-name:"<duration>"`
+}
+
+var shortNoticesHelp = i18n.G("List notices")
+var longNoticesHelp = i18n.G(`
+The notices command lists the notices recorded by the system, such as change
+status updates, inhibited refreshes, newly published snap revisions and custom
+notices recorded by snaps through "snapctl notify".
+
+Each notice is identified by its type and key; repeated occurrences of the same
+notice are aggregated into a single entry with an occurrence count.
+
+With --timeout, the command waits up to the given duration for a matching
+notice to occur if none exists yet.
+`)
+
+func init() {
+	addCommand("notices", shortNoticesHelp, longNoticesHelp, func() flags.Commander { return &cmdNotices{} }, timeDescs.also(map[string]string{
+		// TRANSLATORS: This should not start with a lowercase letter.
+		"type": i18n.G("Only list notices of this type (can be repeated)"),
+		// TRANSLATORS: This should not start with a lowercase letter.
+		"key": i18n.G("Only list notices with this key (can be repeated)"),
+		// TRANSLATORS: This should not start with a lowercase letter.
+		"after": i18n.G("Only list notices repeated after this time (in RFC 3339 format)"),
+		// TRANSLATORS: This should not start with a lowercase letter.
+		"timeout": i18n.G("Wait up to this duration for matching notices to occur"),
+	}), nil)
+}
+
+func (cmd *cmdNotices) Execute(args []string) error {
+	if len(args) > 0 {
+		return ErrExtraArgs
+	}
+
+	opts := &client.NoticesOptions{
+		Types: cmd.Types,
+		Keys:  cmd.Keys,
+	}
+	if cmd.After != "" {
+		after, err := time.Parse(time.RFC3339Nano, cmd.After)
+		if err != nil {
+			return fmt.Errorf(i18n.G("cannot parse --after timestamp %q: %v"), cmd.After, err)
+		}
+		opts.After = after
+	}
+	if cmd.Timeout != "" {
+		timeout, err := time.ParseDuration(cmd.Timeout)
+		if err != nil || timeout < 0 {
+			return fmt.Errorf(i18n.G("invalid --timeout duration %q"), cmd.Timeout)
+		}
+		opts.Timeout = timeout
+	}
+
+	notices, err := cmd.client.Notices(opts)
+	if err != nil {
+		return err
+	}
+	if len(notices) == 0 {
+		fmt.Fprintln(Stderr, i18n.G("No matching notices."))
+		return nil
+	}
+
+	w := tabWriter()
+	defer w.Flush()
+
+	fmt.Fprintln(w, i18n.G("ID\tUser\tType\tKey\tFirst\tRepeated\tOccurrences"))
+	for _, n := range notices {
+		user := "public"
+		if n.UserID != nil {
+			user = fmt.Sprintf("%d", *n.UserID)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%d\n", n.ID, user, n.Type, n.Key,
+			cmd.fmtTime(n.FirstOccurred), cmd.fmtTime(n.LastRepeated), n.Occurrences)
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"gopkg.in/check.v1"
+
+	snap "github.com/snapcore/snapd/cmd/snap"
+)
+
+type noticesSuite struct {
+	BaseSnapSuite
+}
+
+var _ = check.Suite(&noticesSuite{})
+
+func (s *noticesSuite) mockNoticesHandler(c *check.C, query url.Values, body string) {
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		n++
+		if n > 1 {
+			c.Fatalf("expected a single request")
+		}
+		c.Check(r.Method, check.Equals, "GET")
+		c.Check(r.URL.Path, check.Equals, "/v2/notices")
+		c.Check(r.URL.Query(), check.DeepEquals, query)
+		fmt.Fprintln(w, body)
+	})
+}
+
+func (s *noticesSuite) TestNotices(c *check.C) {
+	s.mockNoticesHandler(c, url.Values{}, `{"type": "sync", "status-code": 200, "result": [{
+		"id": "1",
+		"user-id": null,
+		"type": "change-update",
+		"key": "42",
+		"first-occurred": "2023-09-01T05:23:01Z",
+		"last-occurred": "2023-09-01T07:23:02Z",
+		"last-repeated": "2023-09-01T06:23:03Z",
+		"occurrences": 3,
+		"last-data": {"kind": "install-snap"},
+		"expire-after": "168h0m0s"
+	}, {
+		"id": "2",
+		"user-id": 1000,
+		"type": "snap-notify",
+		"key": "some-snap/foo",
+		"first-occurred": "2023-09-01T08:00:00Z",
+		"last-occurred": "2023-09-01T08:00:00Z",
+		"last-repeated": "2023-09-01T08:00:00Z",
+		"occurrences": 1,
+		"expire-after": "168h0m0s"
+	}]}`)
+
+	rest, err := snap.Parser(snap.Client()).ParseArgs([]string{"notices", "--abs-time"})
+	c.Assert(err, check.IsNil)
+	c.Check(rest, check.HasLen, 0)
+	c.Check(s.Stdout(), check.Equals, `
+ID   User    Type           Key            First                 Repeated              Occurrences
+1    public  change-update  42             2023-09-01T05:23:01Z  2023-09-01T06:23:03Z  3
+2    1000    snap-notify    some-snap/foo  2023-09-01T08:00:00Z  2023-09-01T08:00:00Z  1
+`[1:])
+	c.Check(s.Stderr(), check.Equals, "")
+}
+
+func (s *noticesSuite) TestNoticesFilters(c *check.C) {
+	s.mockNoticesHandler(c, url.Values{
+		"types":   {"snap-published,refresh-inhibit"},
+		"keys":    {"some-snap"},
+		"after":   {"2023-09-01T05:23:01.5Z"},
+		"timeout": {"30s"},
+	}, `{"type": "sync", "status-code": 200, "result": []}`)
+
+	rest, err := snap.Parser(snap.Client()).ParseArgs([]string{"notices",
+		"--type=snap-published", "--type=refresh-inhibit", "--key=some-snap",
+		"--after=2023-09-01T05:23:01.5Z", "--timeout=30s"})
+	c.Assert(err, check.IsNil)
+	c.Check(rest, check.HasLen, 0)
+	c.Check(s.Stdout(), check.Equals, "")
+	c.Check(s.Stderr(), check.Equals, "No matching notices.\n")
+}
+
+func (s *noticesSuite) TestNoticesInvalidArgs(c *check.C) {
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"notices", "--after=yesterday"})
+	c.Check(err, check.ErrorMatches, `cannot parse --after timestamp "yesterday": .*`)
+
+	_, err = snap.Parser(snap.Client()).ParseArgs([]string{"notices", "--timeout=forever"})
+	c.Check(err, check.ErrorMatches, `invalid --timeout duration "forever"`)
+}
@@ -0,0 +1,52 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/i18n"
+)
+
+type cmdPromptingRules struct{}
+
+var shortPromptingRulesHelp = i18n.G("Manage the rules created by prompting")
+var longPromptingRulesHelp = i18n.G(`
+The prompting-rules command contains sub-commands to list and remove the
+rules created from the replies of the calling user to interactive prompts,
+which decide on the future accesses of snaps.
+`)
+
+func printPromptingRules(x timeMixin, rules []*client.PromptingRule) {
+	w := tabWriter()
+	defer w.Flush()
+
+	fmt.Fprintln(w, i18n.G("ID\tSnap\tInterface\tPath pattern\tPermissions\tOutcome\tLifespan\tExpires"))
+	for _, rule := range rules {
+		expires := "-"
+		if !rule.Expiration.IsZero() {
+			expires = x.fmtTime(rule.Expiration)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", rule.ID, rule.Snap, rule.Interface, rule.PathPattern,
+			strings.Join(rule.Permissions, ","), rule.Outcome, rule.Lifespan, expires)
+	}
+}
@@ -0,0 +1,82 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/jessevdk/go-flags"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/i18n"
+)
+
+var shortPromptingRulesListHelp = i18n.G("List prompting rules")
+var longPromptingRulesListHelp = i18n.G(`
+The list command lists the prompting rules of the calling user, optionally
+only those of the given snap and interface.
+
+    $ snap prompting-rules list firefox
+    ID                Snap     Interface  Path pattern             Permissions  Outcome  Lifespan  Expires
+    0000000000000001  firefox  home       /home/test/Downloads/**  read,write   allow    forever   -
+`)
+
+type cmdPromptingRulesList struct {
+	clientMixin
+	timeMixin
+	Interface  string `long:"interface"`
+	Positional struct {
+		Snap installedSnapName `positional-arg-name:"<snap>"`
+	} `positional-args:"yes"`
+}
+
+func init() {
+	addPromptingRulesCommand("list", shortPromptingRulesListHelp, longPromptingRulesListHelp, func() flags.Commander { return &cmdPromptingRulesList{} },
+		timeDescs.also(map[string]string{
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"interface": i18n.G("Only list the rules for the given interface"),
+		}), []argDesc{{
+			// TRANSLATORS: This needs to begin with < and end with >
+			name: i18n.G("<snap>"),
+			// TRANSLATORS: This should not start with a lowercase letter.
+			desc: i18n.G("Only list the rules of the given snap"),
+		}})
+}
+
+func (x *cmdPromptingRulesList) Execute(args []string) error {
+	if len(args) > 0 {
+		return ErrExtraArgs
+	}
+
+	rules, err := x.client.PromptingRules(&client.PromptingRulesOptions{
+		Snap:      string(x.Positional.Snap),
+		Interface: x.Interface,
+	})
+	if err != nil {
+		return err
+	}
+	if len(rules) == 0 {
+		fmt.Fprintln(Stderr, i18n.G("No prompting rules found."))
+		return nil
+	}
+
+	printPromptingRules(x.timeMixin, rules)
+	return nil
+}
@@ -0,0 +1,108 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/jessevdk/go-flags"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/i18n"
+)
+
+var shortPromptingRulesRemoveHelp = i18n.G("Remove prompting rules")
+var longPromptingRulesRemoveHelp = i18n.G(`
+The remove command removes the prompting rules of the calling user with the
+given IDs, or with --snap all the rules of the given snap, optionally only
+those for the given interface. The removed rules are listed.
+
+    $ snap prompting-rules remove 0000000000000001
+    $ snap prompting-rules remove --snap=firefox --interface=home
+`)
+
+type cmdPromptingRulesRemove struct {
+	clientMixin
+	timeMixin
+	Snap       installedSnapName `long:"snap"`
+	Interface  string            `long:"interface"`
+	Positional struct {
+		IDs []string `positional-arg-name:"<rule-id>"`
+	} `positional-args:"yes"`
+}
+
+func init() {
+	addPromptingRulesCommand("remove", shortPromptingRulesRemoveHelp, longPromptingRulesRemoveHelp, func() flags.Commander { return &cmdPromptingRulesRemove{} },
+		timeDescs.also(map[string]string{
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"snap": i18n.G("Remove all the rules of the given snap"),
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"interface": i18n.G("With --snap, only remove the rules for the given interface"),
+		}), []argDesc{{
+			// TRANSLATORS: This needs to begin with < and end with >
+			name: i18n.G("<rule-id>"),
+			// TRANSLATORS: This should not start with a lowercase letter.
+			desc: i18n.G("The ID of the rule to remove"),
+		}})
+}
+
+func (x *cmdPromptingRulesRemove) Execute(args []string) error {
+	if len(args) > 0 {
+		return ErrExtraArgs
+	}
+
+	snap := string(x.Snap)
+	switch {
+	case snap != "" && len(x.Positional.IDs) > 0:
+		return fmt.Errorf(i18n.G("cannot remove prompting rules by ID and by snap at the same time"))
+	case snap == "" && len(x.Positional.IDs) == 0:
+		return fmt.Errorf(i18n.G("the IDs of the rules to remove or --snap must be given"))
+	case snap == "" && x.Interface != "":
+		return fmt.Errorf(i18n.G("--interface can only be used with --snap"))
+	}
+
+	var removed []*client.PromptingRule
+	if snap != "" {
+		rules, err := x.client.PromptingRemoveRules(&client.PromptingRulesOptions{
+			Snap:      snap,
+			Interface: x.Interface,
+		})
+		if err != nil {
+			return err
+		}
+		removed = rules
+	} else {
+		for _, id := range x.Positional.IDs {
+			rule, err := x.client.PromptingRemoveRule(id)
+			if err != nil {
+				return fmt.Errorf(i18n.G("cannot remove prompting rule %s: %v"), id, err)
+			}
+			removed = append(removed, rule)
+		}
+	}
+
+	if len(removed) == 0 {
+		fmt.Fprintln(Stderr, i18n.G("No prompting rules were removed."))
+		return nil
+	}
+
+	printPromptingRules(x.timeMixin, removed)
+	return nil
+}
@@ -0,0 +1,157 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main_test
+
+import (
+	"fmt"
+	"net/http"
+
+	"gopkg.in/check.v1"
+
+	main "github.com/snapcore/snapd/cmd/snap"
+)
+
+type promptingRulesSuite struct {
+	BaseSnapSuite
+}
+
+var _ = check.Suite(&promptingRulesSuite{})
+
+const promptingRulesJSON = `[
+	{"id": "0000000000000001", "snap": "foo", "interface": "home", "path-pattern": "/home/test/**", "permissions": ["read", "write"], "outcome": "allow", "lifespan": "forever"},
+	{"id": "0000000000000002", "snap": "foo", "interface": "home", "path-pattern": "/home/test/.ssh/*", "permissions": ["read"], "outcome": "deny", "lifespan": "timespan", "expiration": "2023-06-01T12:10:00Z"}
+]`
+
+const promptingRulesTable = `ID                Snap  Interface  Path pattern       Permissions  Outcome  Lifespan  Expires
+0000000000000001  foo   home       /home/test/**      read,write   allow    forever   -
+0000000000000002  foo   home       /home/test/.ssh/*  read         deny     timespan  2023-06-01T12:10:00Z
+`
+
+func (s *promptingRulesSuite) TestList(c *check.C) {
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, check.Equals, "GET")
+		c.Check(r.URL.Path, check.Equals, "/v2/prompting/rules")
+		switch n {
+		case 0:
+			c.Check(r.URL.RawQuery, check.Equals, "")
+		case 1:
+			c.Check(r.URL.Query().Get("snap"), check.Equals, "foo")
+			c.Check(r.URL.Query().Get("interface"), check.Equals, "home")
+		}
+		n++
+		fmt.Fprintf(w, `{"type": "sync", "status-code": 200, "result": %s}`, promptingRulesJSON)
+	})
+
+	rest, err := main.Parser(main.Client()).ParseArgs([]string{"prompting-rules", "list", "--abs-time"})
+	c.Assert(err, check.IsNil)
+	c.Check(rest, check.HasLen, 0)
+	c.Check(s.Stdout(), check.Equals, promptingRulesTable)
+	c.Check(s.Stderr(), check.Equals, "")
+
+	s.ResetStdStreams()
+	_, err = main.Parser(main.Client()).ParseArgs([]string{"prompting-rules", "list", "--abs-time", "--interface=home", "foo"})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Equals, promptingRulesTable)
+	c.Check(n, check.Equals, 2)
+}
+
+func (s *promptingRulesSuite) TestListNone(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"type": "sync", "status-code": 200, "result": []}`)
+	})
+
+	_, err := main.Parser(main.Client()).ParseArgs([]string{"prompting-rules", "list"})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Equals, "")
+	c.Check(s.Stderr(), check.Equals, "No prompting rules found.\n")
+}
+
+func (s *promptingRulesSuite) TestRemoveByID(c *check.C) {
+	var removed []string
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, check.Equals, "POST")
+		c.Check(DecodedRequestBody(c, r), check.DeepEquals, map[string]interface{}{"action": "remove"})
+		switch r.URL.Path {
+		case "/v2/prompting/rules/0000000000000001":
+			fmt.Fprintln(w, `{"type": "sync", "status-code": 200, "result": {"id": "0000000000000001", "snap": "foo", "interface": "home", "path-pattern": "/home/test/**", "permissions": ["read", "write"], "outcome": "allow", "lifespan": "forever"}}`)
+		case "/v2/prompting/rules/0000000000000002":
+			fmt.Fprintln(w, `{"type": "sync", "status-code": 200, "result": {"id": "0000000000000002", "snap": "foo", "interface": "home", "path-pattern": "/home/test/.ssh/*", "permissions": ["read"], "outcome": "deny", "lifespan": "timespan", "expiration": "2023-06-01T12:10:00Z"}}`)
+		default:
+			w.WriteHeader(404)
+			fmt.Fprintln(w, `{"type": "error", "status-code": 404, "result": {"message": "no rule with the given ID exists"}}`)
+		}
+		removed = append(removed, r.URL.Path)
+	})
+
+	_, err := main.Parser(main.Client()).ParseArgs([]string{"prompting-rules", "remove", "--abs-time", "0000000000000001", "0000000000000002"})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Equals, promptingRulesTable)
+	c.Check(removed, check.HasLen, 2)
+
+	_, err = main.Parser(main.Client()).ParseArgs([]string{"prompting-rules", "remove", "0000000000000003"})
+	c.Check(err, check.ErrorMatches, "cannot remove prompting rule 0000000000000003: no rule with the given ID exists")
+}
+
+func (s *promptingRulesSuite) TestRemoveBySnap(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, check.Equals, "POST")
+		c.Check(r.URL.Path, check.Equals, "/v2/prompting/rules")
+		c.Check(DecodedRequestBody(c, r), check.DeepEquals, map[string]interface{}{
+			"action":    "remove",
+			"snap":      "foo",
+			"interface": "home",
+		})
+		fmt.Fprintf(w, `{"type": "sync", "status-code": 200, "result": %s}`, promptingRulesJSON)
+	})
+
+	_, err := main.Parser(main.Client()).ParseArgs([]string{"prompting-rules", "remove", "--abs-time", "--snap=foo", "--interface=home"})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Equals, promptingRulesTable)
+}
+
+func (s *promptingRulesSuite) TestRemoveBySnapNone(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"type": "sync", "status-code": 200, "result": []}`)
+	})
+
+	_, err := main.Parser(main.Client()).ParseArgs([]string{"prompting-rules", "remove", "--snap=foo"})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Equals, "")
+	c.Check(s.Stderr(), check.Equals, "No prompting rules were removed.\n")
+}
+
+func (s *promptingRulesSuite) TestRemoveErrors(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Fatalf("unexpected request")
+	})
+
+	for _, t := range []struct {
+		args []string
+		err  string
+	}{
+		{[]string{}, "the IDs of the rules to remove or --snap must be given"},
+		{[]string{"--snap=foo", "0000000000000001"}, "cannot remove prompting rules by ID and by snap at the same time"},
+		{[]string{"--interface=home", "0000000000000001"}, "--interface can only be used with --snap"},
+	} {
+		_, err := main.Parser(main.Client()).ParseArgs(append([]string{"prompting-rules", "remove"}, t.args...))
+		c.Check(err, check.ErrorMatches, t.err)
+	}
+}
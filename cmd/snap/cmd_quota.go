@@ -103,6 +103,12 @@ The journal limits can be increased and decreased after being set on a group.
 Setting a journal limit will cause the snaps in the group to be put into the same
 journal namespace. This will affect the behaviour of the log command.
 
+The disk limit caps the total size of the $SNAP_DATA and $SNAP_COMMON
+directories of the snaps in the group. With --disk-user-data the per-user data
+directories are included as well, covering those which exist when the limit is
+set. Disk limits require project quotas to be enabled on the filesystem holding
+/var/snap, can be both increased and decreased, and cannot be nested.
+
 New quotas can be set on existing quota groups, but existing quotas cannot be removed
 from a quota group, without removing and recreating the entire group.
 
@@ -122,6 +128,8 @@ func init() {
 			"threads":            i18n.G("Threads quota"),
 			"journal-size":       i18n.G("Journal size quota"),
 			"journal-rate-limit": i18n.G("Journal rate limit as <message count>/<message period>"),
+			"disk":               i18n.G("Disk space quota"),
+			"disk-user-data":     i18n.G("Include per-user data in the disk space quota"),
 			"parent":             i18n.G("Parent quota group"),
 		}), nil)
 	addCommand("quota", shortQuotaHelp, longQuotaHelp, func() flags.Commander { return &cmdQuota{} }, nil, nil)
@@ -138,6 +146,8 @@ type cmdSetQuota struct {
 	ThreadsMax       string `long:"threads" optional:"true"`
 	JournalSizeMax   string `long:"journal-size" optional:"true"`
 	JournalRateLimit string `long:"journal-rate-limit" optional:"true"`
+	DiskMax          string `long:"disk" optional:"true"`
+	DiskUserData     bool   `long:"disk-user-data"`
 	Parent           string `long:"parent" optional:"true"`
 	Positional       struct {
 		GroupName string        `positional-arg-name:"<group-name>" required:"true"`
@@ -266,12 +276,27 @@ func (x *cmdSetQuota) parseQuotas() (*client.QuotaValues, error) {
 		}
 	}
 
+	if x.DiskUserData && x.DiskMax == "" {
+		return nil, fmt.Errorf("cannot use --disk-user-data without --disk")
+	}
+	if x.DiskMax != "" {
+		value, err := strutil.ParseByteSize(x.DiskMax)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse disk size %q: %v", x.DiskMax, err)
+		}
+		quotaValues.Disk = &client.QuotaDiskValues{
+			Size:     quantity.Size(value),
+			UserData: x.DiskUserData,
+		}
+	}
+
 	return &quotaValues, nil
 }
 
 func (x *cmdSetQuota) hasQuotaSet() bool {
 	return x.MemoryMax != "" || x.CPUMax != "" || x.CPUSet != "" ||
-		x.ThreadsMax != "" || x.JournalSizeMax != "" || x.JournalRateLimit != ""
+		x.ThreadsMax != "" || x.JournalSizeMax != "" || x.JournalRateLimit != "" ||
+		x.DiskMax != ""
 }
 
 func (x *cmdSetQuota) splitSnapsAndServices() (snaps []string, services []string) {
@@ -438,12 +463,23 @@ func (x *cmdQuota) Execute(args []string) (err error) {
 				group.Constraints.Journal.RatePeriod)
 		}
 	}
+	if group.Constraints.Disk != nil {
+		val := strings.TrimSpace(fmtSize(int64(group.Constraints.Disk.Size)))
+		fmt.Fprintf(w, "  disk:\t%s\n", val)
+		if group.Constraints.Disk.UserData {
+			fmt.Fprintf(w, "  disk-user-data:\ttrue\n")
+		}
+	}
 
 	memoryUsage := "0B"
 	currentThreads := 0
+	diskUsage := "0B"
 	if group.Current != nil {
 		memoryUsage = strings.TrimSpace(fmtSize(int64(group.Current.Memory)))
 		currentThreads = group.Current.Threads
+		if group.Current.Disk != nil {
+			diskUsage = strings.TrimSpace(fmtSize(int64(group.Current.Disk.Size)))
+		}
 	}
 
 	fmt.Fprintf(w, "current:\n")
@@ -453,6 +489,9 @@ func (x *cmdQuota) Execute(args []string) (err error) {
 	if group.Constraints.Threads != 0 {
 		fmt.Fprintf(w, "  threads:\t%d\n", currentThreads)
 	}
+	if group.Constraints.Disk != nil {
+		fmt.Fprintf(w, "  disk:\t%s\n", diskUsage)
+	}
 
 	if len(group.Subgroups) > 0 {
 		fmt.Fprint(w, "subgroups:\n")
@@ -560,7 +599,12 @@ func (x *cmdQuotas) Execute(args []string) (err error) {
 			}
 		}
 
-		// format current resource values as memory=N,threads=N
+		// format disk constraint as disk=N
+		if q.Constraints.Disk != nil {
+			grpConstraints = append(grpConstraints, "disk="+strings.TrimSpace(fmtSize(int64(q.Constraints.Disk.Size))))
+		}
+
+		// format current resource values as memory=N,threads=N,disk=N
 		var grpCurrent []string
 		if q.Current != nil {
 			if q.Constraints.Memory != 0 && q.Current.Memory != 0 {
@@ -569,6 +613,9 @@ func (x *cmdQuotas) Execute(args []string) (err error) {
 			if q.Constraints.Threads != 0 && q.Current.Threads != 0 {
 				grpCurrent = append(grpCurrent, "threads="+fmt.Sprintf("%d", q.Current.Threads))
 			}
+			if q.Constraints.Disk != nil && q.Current.Disk != nil && q.Current.Disk.Size != 0 {
+				grpCurrent = append(grpCurrent, "disk="+strings.TrimSpace(fmtSize(int64(q.Current.Disk.Size))))
+			}
 		}
 
 		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", q.GroupName, q.Parent, strings.Join(grpConstraints, ","), strings.Join(grpCurrent, ","))
@@ -212,6 +212,8 @@ func (s *quotaSuite) TestParseQuotas(c *check.C) {
 		threadsMax       string
 		journalSizeMax   string
 		journalRateLimit string
+		diskMax          string
+		diskUserData     bool
 
 		// Use the JSON representation of the quota, as it's easier to handle in the test data
 		quotas string
@@ -227,6 +229,8 @@ func (s *quotaSuite) TestParseQuotas(c *check.C) {
 		{journalRateLimit: "1500/15ms", quotas: `{"journal":{"rate-count":1500,"rate-period":15000000}}`},
 		{journalRateLimit: "1/15us", quotas: `{"journal":{"rate-count":1,"rate-period":15000}}`},
 		{journalRateLimit: "0/0s", quotas: `{"journal":{"rate-count":0,"rate-period":0}}`},
+		{diskMax: "2GB", quotas: `{"disk":{"size":2000000000}}`},
+		{diskMax: "2GB", diskUserData: true, quotas: `{"disk":{"size":2000000000,"user-data":true}}`},
 
 		// Error cases
 		{cpuMax: "ASD", err: `cannot parse cpu quota string "ASD"`},
@@ -243,9 +247,12 @@ func (s *quotaSuite) TestParseQuotas(c *check.C) {
 		{journalRateLimit: "0", err: `cannot parse journal rate limit "0": rate limit must be of the form <number of messages>/<period duration>`},
 		{journalRateLimit: "x/5m", err: `cannot parse journal rate limit "x/5m": cannot parse message count: strconv.Atoi: parsing "x": invalid syntax`},
 		{journalRateLimit: "1/wow", err: `cannot parse journal rate limit "1/wow": cannot parse period: time: invalid duration ["]?wow["]?`},
+		{diskMax: "1X", err: `cannot parse disk size "1X": .*`},
+		{diskUserData: true, err: `cannot use --disk-user-data without --disk`},
 	} {
 		quotas, err := main.ParseQuotaValues(testData.maxMemory, testData.cpuMax,
-			testData.cpuSet, testData.threadsMax, testData.journalSizeMax, testData.journalRateLimit)
+			testData.cpuSet, testData.threadsMax, testData.journalSizeMax, testData.journalRateLimit,
+			testData.diskMax, testData.diskUserData)
 		testLabel := check.Commentf("%v", testData)
 		if testData.err == "" {
 			c.Check(err, check.IsNil, testLabel)
@@ -510,6 +517,39 @@ current:
 	c.Check(s.quotaGetGroupHandlerCalls, check.Equals, 1)
 }
 
+func (s *quotaSuite) TestDiskQuotaGroupSimple(c *check.C) {
+	const jsonTemplate = `{
+		"type": "sync",
+		"status-code": 200,
+		"result": {
+			"group-name": "foo",
+			"constraints": {"disk":{"size":2000000000,"user-data":true}},
+			"current": {"disk":{"size":1000000}},
+			"snaps": ["some-snap"]
+		}
+	}`
+
+	s.RedirectClientToTestServer(s.makeFakeGetQuotaGroupHandler(c, jsonTemplate))
+
+	outputTemplate := `
+name:  foo
+constraints:
+  disk:            2.00GB
+  disk-user-data:  true
+current:
+  disk:  1.00MB
+snaps:
+  - some-snap
+`[1:]
+
+	rest, err := main.Parser(main.Client()).ParseArgs([]string{"quota", "foo"})
+	c.Assert(err, check.IsNil)
+	c.Check(rest, check.HasLen, 0)
+	c.Check(s.Stderr(), check.Equals, "")
+	c.Check(s.Stdout(), check.Equals, outputTemplate)
+	c.Check(s.quotaGetGroupHandlerCalls, check.Equals, 1)
+}
+
 func (s *quotaSuite) TestSetQuotaGroupCreateNew(c *check.C) {
 	const postJSON = `{"type": "async", "status-code": 202,"change":"42", "result": []}`
 	fakeHandlerOpts := fakeQuotaGroupPostHandlerOpts{
@@ -710,7 +750,8 @@ func (s *quotaSuite) TestGetAllQuotaGroups(c *check.C) {
 			{"group-name":"cp2","subgroups":["cps1"],"constraints":{"cpu":{"count":2,"percentage":100},"cpu-set":{"cpus":[0,1]}}},
 			{"group-name":"cps1","parent":"cp2","constraints":{"memory":9900,"cpu":{"percentage":50},"cpu-set":{"cpus":[1]}},"current":{"memory":10000}},
 			{"group-name":"js0","parent":"cp1","constraints":{"journal":{"size":1048576,"rate-count":50,"rate-period":60000000000}}},
-			{"group-name":"js1","parent":"cp1","constraints":{"journal":{"rate-count":0,"rate-period":0}}}
+			{"group-name":"js1","parent":"cp1","constraints":{"journal":{"rate-count":0,"rate-period":0}}},
+			{"group-name":"dsk","constraints":{"disk":{"size":2000000000}},"current":{"disk":{"size":5000}}}
 			]}`))
 
 	rest, err := main.Parser(main.Client()).ParseArgs([]string{"quotas"})
@@ -726,6 +767,7 @@ js0      cp1     journal-size=1.05MB,journal-rate=50/1m0s
 js1      cp1     journal-rate=0/0s                         
 cp2              cpu=2x100%,cpu-set=0,1                    
 cps1     cp2     memory=9.9kB,cpu=50%,cpu-set=1            memory=10.0kB
+dsk              disk=2.00GB                               disk=5000B
 ggg              memory=1000B,threads=100                  memory=3000B
 hhh              threads=100                               
 xxx              memory=9.9kB                              memory=10.0kB
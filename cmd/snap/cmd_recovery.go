@@ -31,35 +31,82 @@ import (
 )
 
 type cmdRecovery struct {
-	clientMixin
+	waitMixin
 	colorMixin
 
 	ShowKeys bool `long:"show-keys"`
+
+	Create         bool             `long:"create"`
+	Remove         bool             `long:"remove"`
+	Default        bool             `long:"default"`
+	ValidationSets []string         `long:"validation-set"`
+	Snaps          []flags.Filename `long:"snap"`
+	Assertions     []flags.Filename `long:"assertion"`
+
+	Positional struct {
+		Label string
+	} `positional-args:"true"`
 }
 
-var shortRecoveryHelp = i18n.G("List available recovery systems")
+var shortRecoveryHelp = i18n.G("List and manage recovery systems")
 var longRecoveryHelp = i18n.G(`
 The recovery command lists the available recovery systems.
 
 With --show-keys it displays recovery keys that can be used to unlock the encrypted partitions if the device-specific automatic unlocking does not work.
+
+With --create a new recovery system with the given label is created from the
+installed snaps. The snaps can instead be provided as local files with --snap,
+together with the assertions needed to validate them with --assertion. With
+--validation-set the snaps of the new recovery system are checked against the
+given validation sets. Combined with --default, the new recovery system becomes
+the default one once created.
+
+With --remove the recovery system with the given label is removed.
+
+With --default alone the recovery system with the given label becomes the
+default one, that is the one used when reinstalling the device.
 `)
 
 func init() {
 	addCommand("recovery", shortRecoveryHelp, longRecoveryHelp, func() flags.Commander {
 		// XXX: if we want more/nicer details we can add `snap recovery <system>` later
 		return &cmdRecovery{}
-	}, colorDescs.also(
+	}, colorDescs.also(waitDescs).also(
 		map[string]string{
 			// TRANSLATORS: This should not start with a lowercase letter.
 			"show-keys": i18n.G("Show recovery keys (if available) to unlock encrypted partitions."),
-		}), nil)
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"create": i18n.G("Create a new recovery system with the given label"),
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"remove": i18n.G("Remove the recovery system with the given label"),
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"default": i18n.G("Make the recovery system with the given label the default one"),
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"validation-set": i18n.G("Validation set the new recovery system must be consistent with (can be repeated)"),
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"snap": i18n.G("Local snap file to use for the new recovery system (can be repeated)"),
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"assertion": i18n.G("Local assertion file to use for the new recovery system (can be repeated)"),
+		}), []argDesc{{
+		// TRANSLATORS: This needs to begin with < and end with >
+		name: i18n.G("<label>"),
+		// TRANSLATORS: This should not start with a lowercase letter.
+		desc: i18n.G("Label of the recovery system"),
+	}})
 }
 
 func notesForSystem(sys *client.System) string {
+	var notes []string
 	if sys.Current {
-		return "current"
+		notes = append(notes, "current")
+	}
+	if sys.DefaultRecoverySystem {
+		notes = append(notes, "default")
+	}
+	if len(notes) == 0 {
+		return "-"
 	}
-	return "-"
+	return strings.Join(notes, ",")
 }
 
 func (x *cmdRecovery) showKeys(w io.Writer) error {
@@ -75,11 +122,83 @@ func (x *cmdRecovery) showKeys(w io.Writer) error {
 	return nil
 }
 
+func (x *cmdRecovery) manage() error {
+	label := x.Positional.Label
+	if label == "" {
+		return fmt.Errorf(i18n.G("a recovery system label is required"))
+	}
+
+	switch {
+	case x.Create:
+		opts := &client.CreateSystemOptions{
+			Label:          label,
+			ValidationSets: x.ValidationSets,
+			MarkDefault:    x.Default,
+		}
+		for _, path := range x.Snaps {
+			opts.SnapPaths = append(opts.SnapPaths, string(path))
+		}
+		for _, path := range x.Assertions {
+			opts.AssertionPaths = append(opts.AssertionPaths, string(path))
+		}
+		changeID, err := x.client.CreateSystem(opts)
+		if err != nil {
+			return err
+		}
+		if _, err := x.wait(changeID); err != nil {
+			if err == noWait {
+				return nil
+			}
+			return err
+		}
+		if x.Default {
+			fmt.Fprintf(Stdout, i18n.G("Recovery system %q created and set as default\n"), label)
+		} else {
+			fmt.Fprintf(Stdout, i18n.G("Recovery system %q created\n"), label)
+		}
+	case x.Remove:
+		changeID, err := x.client.RemoveSystem(label)
+		if err != nil {
+			return err
+		}
+		if _, err := x.wait(changeID); err != nil {
+			if err == noWait {
+				return nil
+			}
+			return err
+		}
+		fmt.Fprintf(Stdout, i18n.G("Recovery system %q removed\n"), label)
+	case x.Default:
+		if err := x.client.SetDefaultSystem(label); err != nil {
+			return err
+		}
+		fmt.Fprintf(Stdout, i18n.G("Recovery system %q set as default\n"), label)
+	}
+	return nil
+}
+
 func (x *cmdRecovery) Execute(args []string) error {
 	if len(args) > 0 {
 		return ErrExtraArgs
 	}
 
+	manage := x.Create || x.Remove || x.Default
+	if x.ShowKeys && manage {
+		return fmt.Errorf(i18n.G("cannot use --show-keys with --create, --remove or --default"))
+	}
+	if x.Remove && (x.Create || x.Default) {
+		return fmt.Errorf(i18n.G("cannot use --remove with --create or --default"))
+	}
+	if !x.Create && (len(x.ValidationSets) > 0 || len(x.Snaps) > 0 || len(x.Assertions) > 0) {
+		return fmt.Errorf(i18n.G("cannot use --validation-set, --snap or --assertion without --create"))
+	}
+	if manage {
+		return x.manage()
+	}
+	if x.Positional.Label != "" {
+		return fmt.Errorf(i18n.G("a recovery system label can only be used with --create, --remove or --default"))
+	}
+
 	esc := x.getEscapes()
 	w := tabWriter()
 	defer w.Flush()
@@ -21,7 +21,9 @@ package main_test
 
 import (
 	"fmt"
+	"io/ioutil"
 	"net/http"
+	"path/filepath"
 
 	. "gopkg.in/check.v1"
 
@@ -31,20 +33,49 @@ import (
 
 func (s *SnapSuite) TestRecoveryHelp(c *C) {
 	msg := `Usage:
-  snap.test recovery [recovery-OPTIONS]
+  snap.test recovery [recovery-OPTIONS] [<label>]
 
 The recovery command lists the available recovery systems.
 
 With --show-keys it displays recovery keys that can be used to unlock the
 encrypted partitions if the device-specific automatic unlocking does not work.
 
+With --create a new recovery system with the given label is created from the
+installed snaps. The snaps can instead be provided as local files with --snap,
+together with the assertions needed to validate them with --assertion. With
+--validation-set the snaps of the new recovery system are checked against the
+given validation sets. Combined with --default, the new recovery system becomes
+the default one once created.
+
+With --remove the recovery system with the given label is removed.
+
+With --default alone the recovery system with the given label becomes the
+default one, that is the one used when reinstalling the device.
+
 [recovery command options]
+      --no-wait                       Do not wait for the operation to finish
+                                      but just print the change id.
       --color=[auto|never|always]     Use a little bit of color to highlight
                                       some things. (default: auto)
       --unicode=[auto|never|always]   Use a little bit of Unicode to improve
                                       legibility. (default: auto)
       --show-keys                     Show recovery keys (if available) to
                                       unlock encrypted partitions.
+      --create                        Create a new recovery system with the
+                                      given label
+      --remove                        Remove the recovery system with the given
+                                      label
+      --default                       Make the recovery system with the given
+                                      label the default one
+      --validation-set=               Validation set the new recovery system
+                                      must be consistent with (can be repeated)
+      --snap=                         Local snap file to use for the new
+                                      recovery system (can be repeated)
+      --assertion=                    Local assertion file to use for the new
+                                      recovery system (can be repeated)
+
+[recovery command arguments]
+  <label>:                            Label of the recovery system
 `
 	s.testSubCommandHelp(c, "recovery", msg)
 }
@@ -79,6 +110,7 @@ func (s *SnapSuite) TestRecovery(c *C) {
            },
            {
                 "label": "20200802",
+                "default-recovery-system": true,
                 "model": {
                     "model": "model-id-2",
                     "brand-id": "brand-id-1",
@@ -108,7 +140,7 @@ func (s *SnapSuite) TestRecovery(c *C) {
 	c.Check(s.Stdout(), Equals, `
 Label     Brand    Model       Notes
 20200101  brand-1  model-id-1  current
-20200802  brand-2  model-id-2  -
+20200802  brand-2  model-id-2  default
 `[1:])
 	c.Check(s.Stderr(), Equals, "")
 }
@@ -208,3 +240,176 @@ func (s *SnapSuite) TestRecoveryShowRecoveryKeyAloneHappy(c *C) {
 	c.Check(s.Stderr(), Equals, "")
 	c.Check(n, Equals, 1)
 }
+
+func (s *SnapSuite) TestRecoveryNotesCurrentAndDefault(c *C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, Equals, "GET")
+		c.Check(r.URL.Path, Equals, "/v2/systems")
+		fmt.Fprintln(w, `{"type": "sync", "result": {
+        "systems": [
+           {
+                "current": true,
+                "default-recovery-system": true,
+                "label": "20200101",
+                "model": {"model": "model-id-1", "brand-id": "brand-id-1"},
+                "brand": {"id": "brand-id-1", "username": "brand-1"}
+           }
+        ]
+}}`)
+	})
+	rest, err := snap.Parser(snap.Client()).ParseArgs([]string{"recovery"})
+	c.Assert(err, IsNil)
+	c.Assert(rest, DeepEquals, []string{})
+	c.Check(s.Stdout(), Equals, `
+Label     Brand    Model       Notes
+20200101  brand-1  model-id-1  current,default
+`[1:])
+}
+
+func (s *SnapSuite) TestRecoveryCreate(c *C) {
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch n {
+		case 0:
+			c.Check(r.Method, Equals, "POST")
+			c.Check(r.URL.Path, Equals, "/v2/systems")
+			c.Check(DecodedRequestBody(c, r), DeepEquals, map[string]interface{}{
+				"action":          "create",
+				"label":           "1234",
+				"validation-sets": []interface{}{"foo/bar", "foo/baz=2"},
+				"mark-default":    true,
+			})
+			w.WriteHeader(202)
+			fmt.Fprintln(w, `{"type":"async", "status-code": 202, "change": "42"}`)
+		case 1:
+			c.Check(r.Method, Equals, "GET")
+			c.Check(r.URL.Path, Equals, "/v2/changes/42")
+			fmt.Fprintln(w, `{"type": "sync", "result": {"ready": true, "status": "Done"}}`)
+		default:
+			c.Fatalf("expected to get 2 requests, now on %d", n+1)
+		}
+
+		n++
+	})
+	rest, err := snap.Parser(snap.Client()).ParseArgs([]string{"recovery", "--create", "--default",
+		"--validation-set", "foo/bar", "--validation-set", "foo/baz=2", "1234"})
+	c.Assert(err, IsNil)
+	c.Assert(rest, DeepEquals, []string{})
+	c.Check(s.Stdout(), Equals, "Recovery system \"1234\" created and set as default\n")
+	c.Check(s.Stderr(), Equals, "")
+	c.Check(n, Equals, 2)
+}
+
+func (s *SnapSuite) TestRecoveryCreateWithSnapsNoWait(c *C) {
+	snapPath := filepath.Join(c.MkDir(), "pc.snap")
+	c.Assert(ioutil.WriteFile(snapPath, []byte("snap-data"), 0644), IsNil)
+	assertPath := filepath.Join(c.MkDir(), "pc.assert")
+	c.Assert(ioutil.WriteFile(assertPath, []byte("assertion-data"), 0644), IsNil)
+
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch n {
+		case 0:
+			c.Check(r.Method, Equals, "POST")
+			c.Check(r.URL.Path, Equals, "/v2/systems")
+			form, err := r.MultipartReader()
+			c.Assert(err, IsNil)
+			values, err := form.ReadForm(1 << 20)
+			c.Assert(err, IsNil)
+			c.Check(values.Value["action"], DeepEquals, []string{"create"})
+			c.Check(values.Value["label"], DeepEquals, []string{"1234"})
+			c.Check(values.Value["assertion"], DeepEquals, []string{"assertion-data"})
+			c.Assert(values.File["snap"], HasLen, 1)
+			c.Check(values.File["snap"][0].Filename, Equals, "pc.snap")
+			w.WriteHeader(202)
+			fmt.Fprintln(w, `{"type":"async", "status-code": 202, "change": "42"}`)
+		default:
+			c.Fatalf("expected to get 1 requests, now on %d", n+1)
+		}
+
+		n++
+	})
+	rest, err := snap.Parser(snap.Client()).ParseArgs([]string{"recovery", "--create", "--no-wait",
+		"--snap", snapPath, "--assertion", assertPath, "1234"})
+	c.Assert(err, IsNil)
+	c.Assert(rest, DeepEquals, []string{})
+	c.Check(s.Stdout(), Equals, "42\n")
+	c.Check(s.Stderr(), Equals, "")
+	c.Check(n, Equals, 1)
+}
+
+func (s *SnapSuite) TestRecoveryRemove(c *C) {
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch n {
+		case 0:
+			c.Check(r.Method, Equals, "POST")
+			c.Check(r.URL.Path, Equals, "/v2/systems/1234")
+			c.Check(DecodedRequestBody(c, r), DeepEquals, map[string]interface{}{
+				"action": "remove",
+			})
+			w.WriteHeader(202)
+			fmt.Fprintln(w, `{"type":"async", "status-code": 202, "change": "42"}`)
+		case 1:
+			c.Check(r.Method, Equals, "GET")
+			c.Check(r.URL.Path, Equals, "/v2/changes/42")
+			fmt.Fprintln(w, `{"type": "sync", "result": {"ready": true, "status": "Done"}}`)
+		default:
+			c.Fatalf("expected to get 2 requests, now on %d", n+1)
+		}
+
+		n++
+	})
+	rest, err := snap.Parser(snap.Client()).ParseArgs([]string{"recovery", "--remove", "1234"})
+	c.Assert(err, IsNil)
+	c.Assert(rest, DeepEquals, []string{})
+	c.Check(s.Stdout(), Equals, "Recovery system \"1234\" removed\n")
+	c.Check(s.Stderr(), Equals, "")
+	c.Check(n, Equals, 2)
+}
+
+func (s *SnapSuite) TestRecoverySetDefault(c *C) {
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch n {
+		case 0:
+			c.Check(r.Method, Equals, "POST")
+			c.Check(r.URL.Path, Equals, "/v2/systems/1234")
+			c.Check(DecodedRequestBody(c, r), DeepEquals, map[string]interface{}{
+				"action": "set-default",
+			})
+			fmt.Fprintln(w, `{"type": "sync", "result": null}`)
+		default:
+			c.Fatalf("expected to get 1 requests, now on %d", n+1)
+		}
+
+		n++
+	})
+	rest, err := snap.Parser(snap.Client()).ParseArgs([]string{"recovery", "--default", "1234"})
+	c.Assert(err, IsNil)
+	c.Assert(rest, DeepEquals, []string{})
+	c.Check(s.Stdout(), Equals, "Recovery system \"1234\" set as default\n")
+	c.Check(s.Stderr(), Equals, "")
+	c.Check(n, Equals, 1)
+}
+
+func (s *SnapSuite) TestRecoveryManageErrors(c *C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Fatalf("unexpected request to %s", r.URL.Path)
+	})
+	for _, tc := range []struct {
+		args []string
+		err  string
+	}{
+		{[]string{"--create"}, `a recovery system label is required`},
+		{[]string{"--remove", "--create", "1234"}, `cannot use --remove with --create or --default`},
+		{[]string{"--remove", "--default", "1234"}, `cannot use --remove with --create or --default`},
+		{[]string{"--show-keys", "--remove", "1234"}, `cannot use --show-keys with --create, --remove or --default`},
+		{[]string{"--default", "--snap", "foo.snap", "1234"}, `cannot use --validation-set, --snap or --assertion without --create`},
+		{[]string{"--validation-set", "foo/bar"}, `cannot use --validation-set, --snap or --assertion without --create`},
+		{[]string{"1234"}, `a recovery system label can only be used with --create, --remove or --default`},
+	} {
+		_, err := snap.Parser(snap.Client()).ParseArgs(append([]string{"recovery"}, tc.args...))
+		c.Check(err, ErrorMatches, tc.err, Commentf("%v", tc.args))
+	}
+}
@@ -36,11 +36,19 @@ revision or a full new model.
 
 In the process it applies any implied changes to the device: new required
 snaps, new kernel or gadget etc.
+
+With --offline the store is not contacted. The snaps needed by the new model
+must then be provided as local files with --snap, together with the
+assertions needed to validate them with --assertion, unless they are already
+installed.
 `)
 )
 
 type cmdRemodel struct {
 	waitMixin
+	Offline        bool             `long:"offline"`
+	Snaps          []flags.Filename `long:"snap"`
+	Assertions     []flags.Filename `long:"assertion"`
 	RemodelOptions struct {
 		NewModelFile flags.Filename
 	} `positional-args:"true" required:"true"`
@@ -52,7 +60,14 @@ func init() {
 		longRemodelHelp,
 		func() flags.Commander {
 			return &cmdRemodel{}
-		}, nil, []argDesc{{
+		}, waitDescs.also(map[string]string{
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"offline": i18n.G("Remodel without contacting the store"),
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"snap": i18n.G("Local snap file to use for an offline remodel (can be repeated)"),
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"assertion": i18n.G("Local assertion file to use for an offline remodel (can be repeated)"),
+		}), []argDesc{{
 			// TRANSLATORS: This needs to begin with < and end with >
 			name: i18n.G("<new model file>"),
 			// TRANSLATORS: This should not start with a lowercase letter.
@@ -65,12 +80,28 @@ func (x *cmdRemodel) Execute(args []string) error {
 	if len(args) > 0 {
 		return ErrExtraArgs
 	}
+	if !x.Offline && (len(x.Snaps) > 0 || len(x.Assertions) > 0) {
+		return fmt.Errorf(i18n.G("cannot use --snap or --assertion without --offline"))
+	}
 	newModelFile := x.RemodelOptions.NewModelFile
 	modelData, err := ioutil.ReadFile(string(newModelFile))
 	if err != nil {
 		return err
 	}
-	changeID, err := x.client.Remodel(modelData)
+	var changeID string
+	if x.Offline {
+		snapPaths := make([]string, len(x.Snaps))
+		for i, path := range x.Snaps {
+			snapPaths[i] = string(path)
+		}
+		assertPaths := make([]string, len(x.Assertions))
+		for i, path := range x.Assertions {
+			assertPaths[i] = string(path)
+		}
+		changeID, err = x.client.RemodelOffline(modelData, snapPaths, assertPaths)
+	} else {
+		changeID, err = x.client.Remodel(modelData)
+	}
 	if err != nil {
 		return fmt.Errorf("cannot remodel: %v", err)
 	}
@@ -0,0 +1,72 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	snap "github.com/snapcore/snapd/cmd/snap"
+)
+
+func (s *SnapSuite) TestRemodelOffline(c *C) {
+	dir := c.MkDir()
+	modelPath := filepath.Join(dir, "new-model")
+	c.Assert(ioutil.WriteFile(modelPath, []byte("model-data"), 0644), IsNil)
+	snapPath := filepath.Join(dir, "foo.snap")
+	c.Assert(ioutil.WriteFile(snapPath, []byte("snap-data"), 0644), IsNil)
+	assertPath := filepath.Join(dir, "foo.assert")
+	c.Assert(ioutil.WriteFile(assertPath, []byte("assertion-data"), 0644), IsNil)
+
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		n++
+		c.Check(r.Method, Equals, "POST")
+		c.Check(r.URL.Path, Equals, "/v2/model")
+		c.Check(r.Header.Get("Content-Type"), Matches, "multipart/form-data; boundary=.*")
+		c.Assert(r.ParseMultipartForm(1<<20), IsNil)
+		c.Check(r.MultipartForm.Value["new-model"], DeepEquals, []string{"model-data"})
+		c.Check(r.MultipartForm.Value["assertion"], DeepEquals, []string{"assertion-data"})
+		c.Assert(r.MultipartForm.File["snap"], HasLen, 1)
+		c.Check(r.MultipartForm.File["snap"][0].Filename, Equals, "foo.snap")
+		w.WriteHeader(202)
+		fmt.Fprintln(w, `{"type":"async", "status-code": 202, "change": "42"}`)
+	})
+
+	rest, err := snap.Parser(snap.Client()).ParseArgs([]string{"remodel", "--no-wait", "--offline", "--snap", snapPath, "--assertion", assertPath, modelPath})
+	c.Assert(err, IsNil)
+	c.Check(rest, DeepEquals, []string{})
+	c.Check(s.Stdout(), Equals, "42\n")
+	c.Check(s.Stderr(), Equals, "")
+	c.Check(n, Equals, 1)
+}
+
+func (s *SnapSuite) TestRemodelSnapsWithoutOffline(c *C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Fatalf("unexpected request")
+	})
+
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"remodel", "--snap", "foo.snap", "new-model"})
+	c.Assert(err, ErrorMatches, "cannot use --snap or --assertion without --offline")
+}
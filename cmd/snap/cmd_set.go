@@ -79,28 +79,66 @@ func init() {
 }
 
 func (x *cmdSet) Execute(args []string) error {
-	if x.String && x.Typed {
-		return fmt.Errorf(i18n.G("cannot use -t and -s together"))
+	patchValues, err := parseConfigValues(x.Positional.ConfValues, &parseConfigOptions{
+		String: x.String,
+		Typed:  x.Typed,
+	})
+	if err != nil {
+		return err
+	}
+
+	snapName := string(x.Positional.Snap)
+	id, err := x.client.SetConf(snapName, patchValues)
+	if err != nil {
+		return err
+	}
+
+	if _, err := x.wait(id); err != nil {
+		if err == noWait {
+			return nil
+		}
+		return err
+	}
+
+	return nil
+}
+
+type parseConfigOptions struct {
+	// String parses all values as strings.
+	String bool
+	// Typed parses all values strictly as JSON documents.
+	Typed bool
+}
+
+// parseConfigValues parses a list of "key=value" (set) or "key!" (unset)
+// arguments into a map from keys to values, in which unset keys map to nil.
+func parseConfigValues(confValues []string, opts *parseConfigOptions) (map[string]interface{}, error) {
+	if opts == nil {
+		opts = &parseConfigOptions{}
+	}
+
+	if opts.String && opts.Typed {
+		return nil, fmt.Errorf(i18n.G("cannot use -t and -s together"))
 	}
 
 	patchValues := make(map[string]interface{})
-	for _, patchValue := range x.Positional.ConfValues {
+	for _, patchValue := range confValues {
 		parts := strings.SplitN(patchValue, "=", 2)
 		if len(parts) == 1 && strings.HasSuffix(patchValue, "!") {
 			patchValues[strings.TrimSuffix(patchValue, "!")] = nil
 			continue
 		}
 		if len(parts) != 2 {
-			return fmt.Errorf(i18n.G("invalid configuration: %q (want key=value)"), patchValue)
+			return nil, fmt.Errorf(i18n.G("invalid configuration: %q (want key=value)"), patchValue)
 		}
 
-		if x.String {
+		if opts.String {
 			patchValues[parts[0]] = parts[1]
 		} else {
 			var value interface{}
 			if err := jsonutil.DecodeWithNumber(strings.NewReader(parts[1]), &value); err != nil {
-				if x.Typed {
-					return fmt.Errorf("failed to parse JSON: %w", err)
+				if opts.Typed {
+					return nil, fmt.Errorf("failed to parse JSON: %w", err)
 				}
 
 				// Not valid JSON-- just save the string as-is.
@@ -111,18 +149,5 @@ func (x *cmdSet) Execute(args []string) error {
 		}
 	}
 
-	snapName := string(x.Positional.Snap)
-	id, err := x.client.SetConf(snapName, patchValues)
-	if err != nil {
-		return err
-	}
-
-	if _, err := x.wait(id); err != nil {
-		if err == noWait {
-			return nil
-		}
-		return err
-	}
-
-	return nil
+	return patchValues, nil
 }
@@ -37,6 +37,7 @@ import (
 	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/release"
 	"github.com/snapcore/snapd/snap/channel"
+	"github.com/snapcore/snapd/snap/naming"
 	"github.com/snapcore/snapd/strutil"
 )
 
@@ -64,6 +65,9 @@ override, taking the snap back to the current revision of the channel it's
 tracking.
 
 Use --name to set the instance name when installing from snap file.
+
+To install a component of an installed snap, use <snap>+<component> as the
+name, or pass a component file (with the .comp extension).
 `)
 
 var longRemoveHelp = i18n.G(`
@@ -76,6 +80,8 @@ revision is removed.
 Unless automatic snapshots are disabled, a snapshot of all data for the snap is 
 saved upon removal, which is then available for future restoration with snap
 restore. The --purge option disables automatically creating snapshots.
+
+To remove a component of a snap, use <snap>+<component> as the name.
 `)
 
 var longRefreshHelp = i18n.G(`
@@ -519,6 +525,11 @@ func (x *cmdInstall) installOne(nameOrPath, desiredName string, opts *client.Sna
 		if desiredName != "" {
 			return errors.New(i18n.G("cannot use explicit name when installing from store"))
 		}
+		if strings.Contains(snapName, "+") {
+			if _, _, err := naming.SplitFullComponentName(snapName); err != nil {
+				return err
+			}
+		}
 		changeID, err = x.client.Install(snapName, opts)
 	}
 	if err != nil {
@@ -543,6 +554,14 @@ func (x *cmdInstall) installOne(nameOrPath, desiredName string, opts *client.Sna
 		if err := chg.Get("snap-name", &snapName); err != nil {
 			return fmt.Errorf("cannot extract the snap-name from local file %q: %s", nameOrPath, err)
 		}
+		var compName string
+		if err := chg.Get("component-name", &compName); err != nil && err != client.ErrNoData {
+			return fmt.Errorf("cannot extract the component-name from local file %q: %s", nameOrPath, err)
+		}
+		if compName != "" {
+			fmt.Fprintf(Stdout, i18n.G("component %s+%s installed\n"), snapName, compName)
+			return nil
+		}
 	}
 
 	// TODO: mention details of the install (e.g. like switch does)
@@ -550,7 +569,7 @@ func (x *cmdInstall) installOne(nameOrPath, desiredName string, opts *client.Sna
 }
 
 func isLocalSnap(name string) bool {
-	return strings.Contains(name, "/") || strings.HasSuffix(name, ".snap") || strings.Contains(name, ".snap.")
+	return strings.Contains(name, "/") || strings.HasSuffix(name, ".snap") || strings.Contains(name, ".snap.") || strings.HasSuffix(name, ".comp")
 }
 
 func (x *cmdInstall) installMany(names []string, opts *client.SnapOptions) error {
@@ -2970,3 +2970,87 @@ func (s *SnapOpSuite) TestSnapOpNetworkTimeoutError(c *check.C) {
 	_, err := snap.Parser(snap.Client()).ParseArgs(cmd)
 	c.Assert(err, check.ErrorMatches, `unable to contact snap store`)
 }
+
+func (s *SnapOpSuite) TestInstallComponentPath(c *check.C) {
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch n {
+		case 0:
+			c.Check(r.Method, check.Equals, "POST")
+			c.Check(r.URL.Path, check.Equals, "/v2/snaps")
+			form := testForm(r, c)
+			defer form.RemoveAll()
+
+			c.Check(form.Value["action"], check.DeepEquals, []string{"install"})
+			c.Check(form.Value["dangerous"], check.DeepEquals, []string{"true"})
+			c.Check(form.Value["snap-path"], check.HasLen, 1)
+			c.Check(strings.HasSuffix(form.Value["snap-path"][0], "/foo+comp.comp"), check.Equals, true)
+
+			name, _, body := formFile(form, c)
+			c.Check(name, check.Equals, "snap")
+			c.Check(string(body), check.Equals, "comp-data")
+
+			w.WriteHeader(202)
+			fmt.Fprintln(w, `{"type":"async", "change": "42", "status-code": 202}`)
+		case 1:
+			c.Check(r.Method, check.Equals, "GET")
+			c.Check(r.URL.Path, check.Equals, "/v2/changes/42")
+			fmt.Fprintln(w, `{"type": "sync", "result": {"ready": true, "status": "Done", "data": {"snap-name": "foo", "component-name": "comp"}}}`)
+		default:
+			c.Fatalf("expected to get 2 requests, now on %d", n+1)
+		}
+		n++
+	})
+
+	compPath := filepath.Join(c.MkDir(), "foo+comp.comp")
+	c.Assert(ioutil.WriteFile(compPath, []byte("comp-data"), 0644), check.IsNil)
+
+	rest, err := snap.Parser(snap.Client()).ParseArgs([]string{"install", "--dangerous", compPath})
+	c.Assert(err, check.IsNil)
+	c.Assert(rest, check.DeepEquals, []string{})
+	c.Check(s.Stdout(), check.Equals, "component foo+comp installed\n")
+	c.Check(s.Stderr(), check.Equals, "")
+	c.Check(n, check.Equals, 2)
+}
+
+func (s *SnapOpSuite) TestInstallComponentFromStore(c *check.C) {
+	s.srv.checker = func(r *http.Request) {
+		c.Check(r.URL.Path, check.Equals, "/v2/snaps/foo+comp")
+		c.Check(DecodedRequestBody(c, r), check.DeepEquals, map[string]interface{}{
+			"action":      "install",
+			"transaction": "per-snap",
+		})
+	}
+	s.RedirectClientToTestServer(s.srv.handle)
+
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"install", "foo+comp"})
+	c.Assert(err, check.IsNil)
+	c.Check(s.srv.n, check.Equals, s.srv.total)
+}
+
+func (s *SnapOpSuite) TestInstallComponentInvalidName(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Fatalf("unexpected request to %s", r.URL.Path)
+	})
+
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"install", "foo+comp+other"})
+	c.Assert(err, check.ErrorMatches, `incorrect component name "foo\+comp\+other"`)
+}
+
+func (s *SnapOpSuite) TestRemoveComponent(c *check.C) {
+	s.srv.total = 3
+	s.srv.checker = func(r *http.Request) {
+		c.Check(r.URL.Path, check.Equals, "/v2/snaps/foo+comp")
+		c.Check(DecodedRequestBody(c, r), check.DeepEquals, map[string]interface{}{
+			"action": "remove",
+		})
+	}
+
+	s.RedirectClientToTestServer(s.srv.handle)
+	rest, err := snap.Parser(snap.Client()).ParseArgs([]string{"remove", "foo+comp"})
+	c.Assert(err, check.IsNil)
+	c.Assert(rest, check.DeepEquals, []string{})
+	c.Check(s.Stdout(), check.Matches, `(?sm).*foo\+comp removed`)
+	c.Check(s.Stderr(), check.Equals, "")
+	c.Check(s.srv.n, check.Equals, s.srv.total)
+}
@@ -20,14 +20,18 @@
 package main
 
 import (
+	"encoding/base64"
+	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"strconv"
 	"strings"
 
 	"github.com/jessevdk/go-flags"
 
+	"github.com/snapcore/snapd/client"
 	"github.com/snapcore/snapd/i18n"
 	"github.com/snapcore/snapd/strutil"
 	"github.com/snapcore/snapd/strutil/quantity"
@@ -62,6 +66,11 @@ for which users, or a combination of these.
 If a snap is included in a save operation, excluding its system and
 configuration data from the snapshot is not currently possible. This
 restriction may be lifted in the future.
+
+With --encrypt, the snapshot is encrypted with a passphrase that is
+asked for interactively. Alternatively, with --public-key, it is
+encrypted for the X25519 public key in the given file (base64 encoded),
+and the matching private key is needed to restore it.
 `)
 var longForgetHelp = i18n.G(`
 The forget command deletes a snapshot. This operation can not be
@@ -87,6 +96,10 @@ for which users, or a combination of these.
 If a snap is included in a check-snapshot operation, excluding its
 system and configuration data from the check is not currently
 possible. This restriction may be lifted in the future.
+
+Without a key, only the integrity of the stored data of an encrypted
+snapshot is checked. Pass its private key with --private-key, or give
+its passphrase when asked, to also check that the data decrypts.
 `)
 var longRestoreHelp = i18n.G(`
 The restore command replaces the current user, system and
@@ -100,6 +113,13 @@ for which users, or a combination of these.
 If a snap is included in a restore operation, excluding its system and
 configuration data from the restore is not currently possible. This
 restriction may be lifted in the future.
+
+Restoring an encrypted snapshot asks for its passphrase, unless its
+private key is given with --private-key.
+
+With --into, the data of the one given snap is restored into another
+installed instance of the same snap instead, for example from "foo"
+into "foo_test"; that instance must be able to read the data.
 `)
 
 var longExportSnapshotHelp = i18n.G(`
@@ -160,6 +180,12 @@ func (x *savedCmd) Execute([]string) error {
 			if sh.Auto {
 				notes = append(notes, "auto")
 			}
+			if sh.Scheduled {
+				notes = append(notes, "scheduled")
+			}
+			if sh.Encryption != nil {
+				notes = append(notes, "encrypted")
+			}
 			if sh.Broken != "" {
 				notes = append(notes, "broken: "+sh.Broken)
 			}
@@ -175,19 +201,106 @@ func (x *savedCmd) Execute([]string) error {
 	return nil
 }
 
+// readSnapshotKeyFile reads a base64 encoded X25519 key from the given file.
+func readSnapshotKeyFile(fname string) (string, error) {
+	data, err := ioutil.ReadFile(fname)
+	if err != nil {
+		return "", fmt.Errorf(i18n.G("cannot read key: %v"), err)
+	}
+	key := strings.TrimSpace(string(data))
+	if raw, err := base64.StdEncoding.DecodeString(key); err != nil || len(raw) != 32 {
+		return "", fmt.Errorf(i18n.G("cannot use key from %q: not a base64 encoded X25519 key"), fname)
+	}
+	return key, nil
+}
+
+func readSnapshotPassphrase(prompt string) (string, error) {
+	fmt.Fprint(Stdout, prompt)
+	passphrase, err := ReadPassword(0)
+	fmt.Fprint(Stdout, "\n")
+	if err != nil {
+		return "", err
+	}
+	// strings.TrimSpace needed because we get \r from the pty in the tests
+	return strings.TrimSpace(string(passphrase)), nil
+}
+
+// isSnapshotKeyRequired checks whether err is the daemon telling us that a
+// key is needed for the operation on an encrypted snapshot.
+func isSnapshotKeyRequired(err error) bool {
+	var e *client.Error
+	return errors.As(err, &e) && e.Kind == client.ErrorKindSnapshotKeyRequired
+}
+
+// withSnapshotKey calls op with the private key from the given file, if
+// any; otherwise, if op fails because the snapshot is encrypted, it asks for
+// the passphrase and calls op again.
+func withSnapshotKey(setID snapshotID, privateKeyFile string, op func(key *client.SnapshotKey) (string, error)) (string, error) {
+	if privateKeyFile != "" {
+		privateKey, err := readSnapshotKeyFile(privateKeyFile)
+		if err != nil {
+			return "", err
+		}
+		return op(&client.SnapshotKey{PrivateKey: privateKey})
+	}
+	changeID, err := op(nil)
+	if err == nil || !isSnapshotKeyRequired(err) || !isStdinTTY {
+		return changeID, err
+	}
+	passphrase, err := readSnapshotPassphrase(fmt.Sprintf(i18n.G("Passphrase for snapshot #%s: "), setID))
+	if err != nil {
+		return "", err
+	}
+	return op(&client.SnapshotKey{Passphrase: passphrase})
+}
+
 type saveCmd struct {
 	waitMixin
 	durationMixin
-	Users      string `long:"users"`
+	Users      string         `long:"users"`
+	Encrypt    bool           `long:"encrypt"`
+	PublicKey  flags.Filename `long:"public-key"`
 	Positional struct {
 		Snaps []installedSnapName `positional-arg-name:"<snap>"`
 	} `positional-args:"yes"`
 }
 
+func (x *saveCmd) key() (*client.SnapshotKey, error) {
+	if x.PublicKey != "" {
+		publicKey, err := readSnapshotKeyFile(string(x.PublicKey))
+		if err != nil {
+			return nil, err
+		}
+		return &client.SnapshotKey{PublicKey: publicKey}, nil
+	}
+	if !x.Encrypt {
+		return nil, nil
+	}
+	passphrase, err := readSnapshotPassphrase(i18n.G("Passphrase: "))
+	if err != nil {
+		return nil, err
+	}
+	if passphrase == "" {
+		return nil, errors.New(i18n.G("cannot encrypt snapshot with an empty passphrase"))
+	}
+	again, err := readSnapshotPassphrase(i18n.G("Repeat passphrase: "))
+	if err != nil {
+		return nil, err
+	}
+	if again != passphrase {
+		return nil, errors.New(i18n.G("passphrases do not match"))
+	}
+	return &client.SnapshotKey{Passphrase: passphrase}, nil
+}
+
 func (x *saveCmd) Execute([]string) error {
 	snaps := installedSnapNames(x.Positional.Snaps)
 	users := strutil.CommaSeparatedList(x.Users)
-	setID, changeID, err := x.client.SnapshotMany(snaps, users)
+	key, err := x.key()
+	if err != nil {
+		return err
+	}
+	setID, changeID, err := x.client.SnapshotMany(snaps, users, key)
 	if err != nil {
 		return err
 	}
@@ -243,7 +356,8 @@ func (x *forgetCmd) Execute([]string) error {
 
 type checkSnapshotCmd struct {
 	waitMixin
-	Users      string `long:"users"`
+	Users      string         `long:"users"`
+	PrivateKey flags.Filename `long:"private-key"`
 	Positional struct {
 		ID    snapshotID          `positional-arg-name:"<id>"`
 		Snaps []installedSnapName `positional-arg-name:"<snap>"`
@@ -257,7 +371,9 @@ func (x *checkSnapshotCmd) Execute([]string) error {
 	}
 	snaps := installedSnapNames(x.Positional.Snaps)
 	users := strutil.CommaSeparatedList(x.Users)
-	changeID, err := x.client.CheckSnapshots(setID, snaps, users)
+	changeID, err := withSnapshotKey(x.Positional.ID, string(x.PrivateKey), func(key *client.SnapshotKey) (string, error) {
+		return x.client.CheckSnapshots(setID, snaps, users, key)
+	})
 	if err != nil {
 		return err
 	}
@@ -282,7 +398,9 @@ func (x *checkSnapshotCmd) Execute([]string) error {
 
 type restoreCmd struct {
 	waitMixin
-	Users      string `long:"users"`
+	Users      string         `long:"users"`
+	PrivateKey flags.Filename `long:"private-key"`
+	Into       string         `long:"into"`
 	Positional struct {
 		ID    snapshotID          `positional-arg-name:"<id>"`
 		Snaps []installedSnapName `positional-arg-name:"<snap>"`
@@ -295,8 +413,16 @@ func (x *restoreCmd) Execute([]string) error {
 		return err
 	}
 	snaps := installedSnapNames(x.Positional.Snaps)
+	if x.Into != "" && len(snaps) != 1 {
+		return fmt.Errorf(i18n.G("--into requires exactly one snap"))
+	}
 	users := strutil.CommaSeparatedList(x.Users)
-	changeID, err := x.client.RestoreSnapshots(setID, snaps, users)
+	changeID, err := withSnapshotKey(x.Positional.ID, string(x.PrivateKey), func(key *client.SnapshotKey) (string, error) {
+		if x.Into != "" {
+			return x.client.RestoreSnapshotInto(setID, snaps[0], x.Into, users, key)
+		}
+		return x.client.RestoreSnapshots(setID, snaps, users, key)
+	})
 	if err != nil {
 		return err
 	}
@@ -309,7 +435,10 @@ func (x *restoreCmd) Execute([]string) error {
 	}
 
 	// TODO: also mention the home archives that were actually restored
-	if len(snaps) > 0 {
+	if x.Into != "" {
+		fmt.Fprintf(Stdout, i18n.G("Restored snapshot #%s of snap %q into %q.\n"),
+			x.Positional.ID, snaps[0], x.Into)
+	} else if len(snaps) > 0 {
 		// TRANSLATORS: the %s is a comma-separated list of quoted snap names
 		fmt.Fprintf(Stdout, i18n.G("Restored snapshot #%s of snaps %s.\n"),
 			x.Positional.ID, strutil.Quoted(snaps))
@@ -340,6 +469,10 @@ func init() {
 		}, durationDescs.also(waitDescs).also(map[string]string{
 			// TRANSLATORS: This should not start with a lowercase letter.
 			"users": i18n.G("Snapshot data of only specific users (comma-separated) (default: all users)"),
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"encrypt": i18n.G("Encrypt the snapshot with a passphrase"),
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"public-key": i18n.G("Encrypt the snapshot for the public key in the given file"),
 		}), nil)
 
 	addCommand("restore",
@@ -350,6 +483,10 @@ func init() {
 		}, waitDescs.also(map[string]string{
 			// TRANSLATORS: This should not start with a lowercase letter.
 			"users": i18n.G("Restore data of only specific users (comma-separated) (default: all users)"),
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"private-key": i18n.G("Decrypt the snapshot with the private key in the given file"),
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"into": i18n.G("Restore the data into this other instance of the snap"),
 		}), []argDesc{
 			{
 				name: "<id>",
@@ -387,6 +524,8 @@ func init() {
 		}, waitDescs.also(map[string]string{
 			// TRANSLATORS: This should not start with a lowercase letter.
 			"users": i18n.G("Check data of only specific users (comma-separated) (default: all users)"),
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"private-key": i18n.G("Decrypt the snapshot with the private key in the given file"),
 		}), []argDesc{
 			{
 				name: "<id>",
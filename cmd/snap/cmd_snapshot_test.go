@@ -20,6 +20,9 @@
 package main_test
 
 import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
@@ -44,6 +47,9 @@ var snapshotsTests = []getCmdArgs{{
 }, {
 	args:   "saved --id=3",
 	stdout: "Set  Snap  Age    Version  Rev   Size    Notes\n3    htop  .*  2        1168      1B  auto\n",
+}, {
+	args:   "saved --id=5",
+	stdout: "Set  Snap  Age    Version  Rev   Size    Notes\n5    htop  .*  2        1168      1B  scheduled\n",
 }, {
 	args:   "saved",
 	stdout: "Set  Snap  Age    Version  Rev   Size    Notes\n1    htop  .*  2        1168      1B  -\n",
@@ -120,6 +126,10 @@ func (s *SnapSuite) mockSnapshotsServer(c *C) {
 			if r.Method == "GET" {
 				// simulate a 1-month old snapshot
 				snapshotTime := time.Now().AddDate(0, -1, 0).Format(time.RFC3339)
+				if r.URL.Query().Get("set") == "5" {
+					fmt.Fprintf(w, `{"type":"sync","status-code":200,"status":"OK","result":[{"id":5,"snapshots":[{"set":5,"time":%q,"snap":"htop","revision":"1168","snap-id":"Z","scheduled":true,"epoch":{"read":[0],"write":[0]},"summary":"","version":"2","sha3-384":{"archive.tgz":""},"size":1}]}]}`, snapshotTime)
+					return
+				}
 				if r.URL.Query().Get("set") == "3" {
 					fmt.Fprintf(w, `{"type":"sync","status-code":200,"status":"OK","result":[{"id":3,"snapshots":[{"set":3,"time":%q,"snap":"htop","revision":"1168","snap-id":"Z","auto":true,"epoch":{"read":[0],"write":[0]},"summary":"","version":"2","sha3-384":{"archive.tgz":""},"size":1}]}]}`, snapshotTime)
 					return
@@ -167,3 +177,159 @@ Set  Snap  Age    Version  Rev   Size    Notes
 1    htop  %-6s 2        1168      1B  -
 `, ageStr))
 }
+
+func (s *SnapSuite) mockEncryptedSnapshotsServer(c *C, keys *[]*client.SnapshotKey) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/snapshots", "/v2/snaps":
+			if r.Method == "GET" {
+				snapshotTime := time.Now().AddDate(0, -1, 0).Format(time.RFC3339)
+				fmt.Fprintf(w, `{"type":"sync","status-code":200,"status":"OK","result":[{"id":42,"snapshots":[{"set":42,"time":%q,"snap":"htop","revision":"1168","snap-id":"Z","epoch":{"read":[0],"write":[0]},"summary":"","version":"2","sha3-384":{"archive.tgz":""},"size":1,"encryption":{"method":"passphrase","wrapped-key":"AAAA"}}]}]}`, snapshotTime)
+				return
+			}
+			var action struct {
+				Key         *client.SnapshotKey `json:"key"`
+				SnapshotKey *client.SnapshotKey `json:"snapshot-key"`
+			}
+			c.Assert(json.NewDecoder(r.Body).Decode(&action), IsNil)
+			key := action.Key
+			if r.URL.Path == "/v2/snaps" {
+				key = action.SnapshotKey
+			}
+			*keys = append(*keys, key)
+			if key == nil && r.URL.Path == "/v2/snapshots" {
+				w.WriteHeader(400)
+				fmt.Fprintln(w, `{"type": "error", "status-code": 400, "result": {"message": "snapshot set is encrypted: passphrase or private key required", "kind": "snapshot-key-required"}}`)
+				return
+			}
+			w.WriteHeader(202)
+			fmt.Fprintln(w, `{"type":"async", "status-code": 202, "change": "9", "result": {"set-id": 42}}`)
+		case "/v2/changes/9":
+			fmt.Fprintln(w, `{"type": "sync", "result": {"ready": true, "status": "Done", "data": {}}}`)
+		default:
+			c.Errorf("unexpected path %q", r.URL.Path)
+		}
+	})
+}
+
+func (s *SnapSuite) TestSnapshotSaveEncrypted(c *C) {
+	var keys []*client.SnapshotKey
+	s.mockEncryptedSnapshotsServer(c, &keys)
+	s.password = "sekrit"
+
+	_, err := main.Parser(main.Client()).ParseArgs([]string{"save", "--encrypt", "htop"})
+	c.Assert(err, IsNil)
+	c.Check(keys, DeepEquals, []*client.SnapshotKey{{Passphrase: "sekrit"}})
+	c.Check(s.Stdout(), testutil.MatchesWrapped, `Passphrase: 
+Repeat passphrase: 
+Set  Snap  Age    Version  Rev   Size    Notes
+42   htop  .*  2        1168      1B  encrypted
+`)
+}
+
+func (s *SnapSuite) TestSnapshotSaveEncryptedEmptyPassphrase(c *C) {
+	var keys []*client.SnapshotKey
+	s.mockEncryptedSnapshotsServer(c, &keys)
+
+	_, err := main.Parser(main.Client()).ParseArgs([]string{"save", "--encrypt", "htop"})
+	c.Assert(err, ErrorMatches, "cannot encrypt snapshot with an empty passphrase")
+	c.Check(keys, HasLen, 0)
+}
+
+func (s *SnapSuite) TestSnapshotSavePublicKey(c *C) {
+	var keys []*client.SnapshotKey
+	s.mockEncryptedSnapshotsServer(c, &keys)
+
+	keyFile := filepath.Join(c.MkDir(), "key.pub")
+	publicKey := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{7}, 32))
+	c.Assert(ioutil.WriteFile(keyFile, []byte(publicKey+"\n"), 0644), IsNil)
+
+	_, err := main.Parser(main.Client()).ParseArgs([]string{"save", "--public-key", keyFile, "htop"})
+	c.Assert(err, IsNil)
+	c.Check(keys, DeepEquals, []*client.SnapshotKey{{PublicKey: publicKey}})
+
+	c.Assert(ioutil.WriteFile(keyFile, []byte("AAAA"), 0644), IsNil)
+	_, err = main.Parser(main.Client()).ParseArgs([]string{"save", "--public-key", keyFile, "htop"})
+	c.Assert(err, ErrorMatches, `cannot use key from ".*/key.pub": not a base64 encoded X25519 key`)
+	c.Check(keys, HasLen, 1)
+}
+
+func (s *SnapSuite) TestSnapshotRestoreAsksForPassphrase(c *C) {
+	var keys []*client.SnapshotKey
+	s.mockEncryptedSnapshotsServer(c, &keys)
+	defer main.MockIsStdinTTY(true)()
+	s.password = "sekrit"
+
+	for _, cmd := range []string{"restore", "check-snapshot"} {
+		keys = nil
+		s.stdout.Truncate(0)
+		_, err := main.Parser(main.Client()).ParseArgs([]string{cmd, "42"})
+		c.Assert(err, IsNil)
+		c.Check(keys, DeepEquals, []*client.SnapshotKey{nil, {Passphrase: "sekrit"}})
+		c.Check(s.Stdout(), testutil.Contains, "Passphrase for snapshot #42: \n")
+	}
+}
+
+func (s *SnapSuite) TestSnapshotRestoreNoTTY(c *C) {
+	var keys []*client.SnapshotKey
+	s.mockEncryptedSnapshotsServer(c, &keys)
+
+	_, err := main.Parser(main.Client()).ParseArgs([]string{"restore", "42"})
+	c.Assert(err, ErrorMatches, "snapshot set is encrypted: passphrase or private key required")
+	c.Check(keys, DeepEquals, []*client.SnapshotKey{nil})
+}
+
+func (s *SnapSuite) TestSnapshotRestoreInto(c *C) {
+	var actions []map[string]interface{}
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/snapshots":
+			c.Check(r.Method, Equals, "POST")
+			var action map[string]interface{}
+			c.Assert(json.NewDecoder(r.Body).Decode(&action), IsNil)
+			actions = append(actions, action)
+			w.WriteHeader(202)
+			fmt.Fprintln(w, `{"type":"async", "status-code": 202, "change": "9"}`)
+		case "/v2/changes/9":
+			fmt.Fprintln(w, `{"type": "sync", "result": {"ready": true, "status": "Done", "data": {}}}`)
+		default:
+			c.Errorf("unexpected path %q", r.URL.Path)
+		}
+	})
+
+	_, err := main.Parser(main.Client()).ParseArgs([]string{"restore", "--into", "htop_test", "--users", "bar", "42", "htop"})
+	c.Assert(err, IsNil)
+	c.Check(actions, DeepEquals, []map[string]interface{}{{
+		"set":    42.,
+		"action": "restore",
+		"snaps":  []interface{}{"htop"},
+		"users":  []interface{}{"bar"},
+		"target": "htop_test",
+	}})
+	c.Check(s.Stdout(), Equals, "Restored snapshot #42 of snap \"htop\" into \"htop_test\".\n")
+
+	for _, args := range [][]string{
+		{"restore", "--into", "htop_test", "42"},
+		{"restore", "--into", "htop_test", "42", "htop", "foo"},
+	} {
+		_, err := main.Parser(main.Client()).ParseArgs(args)
+		c.Check(err, ErrorMatches, "--into requires exactly one snap")
+	}
+	c.Check(actions, HasLen, 1)
+}
+
+func (s *SnapSuite) TestSnapshotRestorePrivateKey(c *C) {
+	var keys []*client.SnapshotKey
+	s.mockEncryptedSnapshotsServer(c, &keys)
+
+	keyFile := filepath.Join(c.MkDir(), "key")
+	privateKey := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{7}, 32))
+	c.Assert(ioutil.WriteFile(keyFile, []byte(privateKey), 0600), IsNil)
+
+	for _, cmd := range []string{"restore", "check-snapshot"} {
+		keys = nil
+		_, err := main.Parser(main.Client()).ParseArgs([]string{cmd, "--private-key", keyFile, "42"})
+		c.Assert(err, IsNil)
+		c.Check(keys, DeepEquals, []*client.SnapshotKey{{PrivateKey: privateKey}})
+	}
+}
@@ -26,6 +26,7 @@ import (
 
 	. "gopkg.in/check.v1"
 
+	"github.com/snapcore/snapd/client"
 	snap "github.com/snapcore/snapd/cmd/snap"
 	"github.com/snapcore/snapd/progress"
 	"github.com/snapcore/snapd/progress/progresstest"
@@ -76,6 +77,120 @@ func (s *SnapSuite) TestCmdWatch(c *C) {
 	c.Check(s.Stderr(), Equals, "")
 }
 
+var fmtWatchChangeSeq = "\x1e" + `{"id": "two", "kind": "some-kind", "summary": "some summary...", "status": "Doing", "ready": false, "tasks": [{"id": "84", "kind": "bar", "summary": "some summary", "status": "Doing", "progress": {"label": "my-snap", "done": %d, "total": %d}}]}` + "\n"
+
+func (s *SnapSuite) TestCmdWatchStreamed(c *C) {
+	meter := &progresstest.Meter{}
+	defer progress.MockMeter(meter)()
+	defer snap.MockMaxGoneTime(time.Millisecond)()
+	defer snap.MockPollTime(time.Millisecond)()
+
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		n++
+		switch n {
+		case 1:
+			c.Check(r.Method, Equals, "GET")
+			c.Check(r.URL.Path, Equals, "/v2/changes/two")
+			c.Check(r.URL.Query().Get("follow"), Equals, "true")
+			w.Header().Set("Content-Type", "application/json-seq")
+			fmt.Fprintf(w, fmtWatchChangeSeq, 0, 100*1024)
+			fmt.Fprintf(w, fmtWatchChangeSeq, 50*1024, 100*1024)
+			fmt.Fprintln(w, "\x1e"+`{"id": "two", "ready": true, "status": "Done"}`)
+		default:
+			c.Errorf("expected 1 query, currently on %d", n)
+		}
+	})
+
+	rest, err := snap.Parser(snap.Client()).ParseArgs([]string{"watch", "two"})
+	c.Assert(err, IsNil)
+	c.Assert(rest, HasLen, 0)
+	c.Check(n, Equals, 1)
+	c.Check(meter.Values, DeepEquals, []float64{51200})
+	c.Check(s.Stdout(), Equals, "")
+	c.Check(s.Stderr(), Equals, "")
+}
+
+func (s *SnapSuite) TestCmdWatchStreamInterrupted(c *C) {
+	meter := &progresstest.Meter{}
+	defer progress.MockMeter(meter)()
+	defer snap.MockMaxGoneTime(time.Millisecond)()
+	defer snap.MockPollTime(time.Millisecond)()
+
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		n++
+		switch n {
+		case 1:
+			c.Check(r.URL.Path, Equals, "/v2/changes/two")
+			c.Check(r.URL.Query().Get("follow"), Equals, "true")
+			w.Header().Set("Content-Type", "application/json-seq")
+			fmt.Fprintf(w, fmtWatchChangeSeq, 0, 100*1024)
+		case 2:
+			// the stream ended before the change was ready, poll
+			c.Check(r.URL.Path, Equals, "/v2/changes/two")
+			c.Check(r.URL.RawQuery, Equals, "")
+			fmt.Fprintf(w, fmtWatchChangeJSON, 50*1024, 100*1024)
+		case 3:
+			c.Check(r.URL.Path, Equals, "/v2/changes/two")
+			c.Check(r.URL.RawQuery, Equals, "")
+			fmt.Fprintln(w, `{"type": "sync", "result": {"id": "two", "ready": true, "status": "Done"}}`)
+		default:
+			c.Errorf("expected 3 queries, currently on %d", n)
+		}
+	})
+
+	rest, err := snap.Parser(snap.Client()).ParseArgs([]string{"watch", "two"})
+	c.Assert(err, IsNil)
+	c.Assert(rest, HasLen, 0)
+	c.Check(n, Equals, 3)
+	c.Check(meter.Values, DeepEquals, []float64{51200})
+	c.Check(s.Stdout(), Equals, "")
+	c.Check(s.Stderr(), Equals, "")
+}
+
+func (s *SnapSuite) TestCmdWatchStreamedSpinsAndChecksMaintenance(c *C) {
+	meter := &progresstest.Meter{}
+	defer progress.MockMeter(meter)()
+	defer snap.MockMaxGoneTime(time.Millisecond)()
+	defer snap.MockPollTime(time.Millisecond)()
+	defer snap.MockMaintenanceCheckTime(50 * time.Millisecond)()
+
+	stop := make(chan struct{})
+	defer close(stop)
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		n++
+		switch n {
+		case 1:
+			c.Check(r.URL.Path, Equals, "/v2/changes/two")
+			c.Check(r.URL.Query().Get("follow"), Equals, "true")
+			w.Header().Set("Content-Type", "application/json-seq")
+			fmt.Fprintf(w, fmtWatchChangeSeq, 0, 1)
+			w.(http.Flusher).Flush()
+			// the change is busy, nothing is streamed for a while
+			select {
+			case <-stop:
+			case <-r.Context().Done():
+			}
+		case 2:
+			// maintenance is checked with a regular request
+			c.Check(r.URL.Path, Equals, "/v2/changes/two")
+			c.Check(r.URL.RawQuery, Equals, "")
+			fmt.Fprintln(w, `{"type": "sync", "result": {"id": "two", "status": "Doing"}, "maintenance": {"kind": "system-restart", "message": "system is restarting"}}`)
+		default:
+			c.Errorf("expected 2 queries, currently on %d", n)
+		}
+	})
+
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"watch", "two"})
+	c.Assert(err, DeepEquals, &client.Error{Kind: client.ErrorKindSystemRestart, Message: "system is restarting"})
+	c.Check(n, Equals, 2)
+	// the spinner kept going while waiting for updates
+	c.Check(len(meter.Labels) > 2, Equals, true)
+	c.Check(meter.Labels[0], Equals, "some summary")
+}
+
 func (s *SnapSuite) TestWatchLast(c *C) {
 	meter := &progresstest.Meter{}
 	defer progress.MockMeter(meter)()
@@ -25,6 +25,7 @@ import (
 	"time"
 
 	"github.com/jessevdk/go-flags"
+	"golang.org/x/crypto/ssh/terminal"
 
 	"github.com/snapcore/snapd/client"
 	"github.com/snapcore/snapd/cmd/snaplock/runinhibit"
@@ -163,6 +164,14 @@ func MockPollTime(d time.Duration) (restore func()) {
 	}
 }
 
+func MockMaintenanceCheckTime(d time.Duration) (restore func()) {
+	d0 := maintenanceCheckTime
+	maintenanceCheckTime = d
+	return func() {
+		maintenanceCheckTime = d0
+	}
+}
+
 func MockMaxGoneTime(d time.Duration) (restore func()) {
 	d0 := maxGoneTime
 	maxGoneTime = d
@@ -478,7 +487,7 @@ func MockAutostartSessionApps(f func(string) error) func() {
 	}
 }
 
-func ParseQuotaValues(maxMemory, cpuMax, cpuSet, threadsMax, journalSizeMax, journalRateLimit string) (*client.QuotaValues, error) {
+func ParseQuotaValues(maxMemory, cpuMax, cpuSet, threadsMax, journalSizeMax, journalRateLimit, diskMax string, diskUserData bool) (*client.QuotaValues, error) {
 	var quotas cmdSetQuota
 
 	quotas.MemoryMax = maxMemory
@@ -487,6 +496,8 @@ func ParseQuotaValues(maxMemory, cpuMax, cpuSet, threadsMax, journalSizeMax, jou
 	quotas.ThreadsMax = threadsMax
 	quotas.JournalSizeMax = journalSizeMax
 	quotas.JournalRateLimit = journalRateLimit
+	quotas.DiskMax = diskMax
+	quotas.DiskUserData = diskUserData
 
 	return quotas.parseQuotas()
 }
@@ -496,3 +507,14 @@ func MockSeedWriterReadManifest(f func(manifestFile string) (*seedwriter.Manifes
 	seedwriterReadManifest = f
 	return restore
 }
+
+func MockTerminalRawMode(makeRaw func(fd int) (*terminal.State, error), restore func(fd int, state *terminal.State) error) (restoreMock func()) {
+	oldMakeRaw := terminalMakeRaw
+	oldRestore := terminalRestore
+	terminalMakeRaw = makeRaw
+	terminalRestore = restore
+	return func() {
+		terminalMakeRaw = oldMakeRaw
+		terminalRestore = oldRestore
+	}
+}
@@ -101,6 +101,13 @@ var debugCommands []*cmdInfo
 // routineCommands holds information about all internal commands.
 var routineCommands []*cmdInfo
 
+// aspectCommands holds information about all aspect commands.
+var aspectCommands []*cmdInfo
+
+// promptingRulesCommands holds information about all prompting-rules
+// commands.
+var promptingRulesCommands []*cmdInfo
+
 // addCommand replaces parser.addCommand() in a way that is compatible with
 // re-constructing a pristine parser.
 func addCommand(name, shortHelp, longHelp string, builder func() flags.Commander, optDescs map[string]string, argDescs []argDesc) *cmdInfo {
@@ -148,6 +155,38 @@ func addRoutineCommand(name, shortHelp, longHelp string, builder func() flags.Co
 	return info
 }
 
+// addAspectCommand replaces parser.addCommand() in a way that is
+// compatible with re-constructing a pristine parser. It is meant for
+// adding "snap aspect" commands.
+func addAspectCommand(name, shortHelp, longHelp string, builder func() flags.Commander, optDescs map[string]string, argDescs []argDesc) *cmdInfo {
+	info := &cmdInfo{
+		name:      name,
+		shortHelp: shortHelp,
+		longHelp:  longHelp,
+		builder:   builder,
+		optDescs:  optDescs,
+		argDescs:  argDescs,
+	}
+	aspectCommands = append(aspectCommands, info)
+	return info
+}
+
+// addPromptingRulesCommand replaces parser.addCommand() in a way that is
+// compatible with re-constructing a pristine parser. It is meant for
+// adding "snap prompting-rules" commands.
+func addPromptingRulesCommand(name, shortHelp, longHelp string, builder func() flags.Commander, optDescs map[string]string, argDescs []argDesc) *cmdInfo {
+	info := &cmdInfo{
+		name:      name,
+		shortHelp: shortHelp,
+		longHelp:  longHelp,
+		builder:   builder,
+		optDescs:  optDescs,
+		argDescs:  argDescs,
+	}
+	promptingRulesCommands = append(promptingRulesCommands, info)
+	return info
+}
+
 type parserSetter interface {
 	setParser(*flags.Parser)
 }
@@ -330,7 +369,7 @@ func Parser(cli *client.Client) *flags.Parser {
 	// add --help like what go-flags would do for us, but hidden
 	addHelp(parser)
 
-	seen := make(map[string]bool, len(commands)+len(debugCommands)+len(routineCommands))
+	seen := make(map[string]bool, len(commands)+len(debugCommands)+len(routineCommands)+len(aspectCommands)+len(promptingRulesCommands))
 	checkUnique := func(ci *cmdInfo, kind string) {
 		if seen[ci.shortHelp] && ci.shortHelp != "Internal" && ci.shortHelp != "Deprecated (hidden)" {
 			logger.Panicf(`%scommand %q has an already employed description != "Internal"|"Deprecated (hidden)": %s`, kind, ci.name, ci.shortHelp)
@@ -361,6 +400,24 @@ func Parser(cli *client.Client) *flags.Parser {
 	registerCommands(cli, parser, routineCommand, routineCommands, func(ci *cmdInfo) {
 		checkUnique(ci, "routine ")
 	})
+	// Add the aspect command
+	aspectCommand, err := parser.AddCommand("aspect", shortAspectHelp, longAspectHelp, &cmdAspect{})
+	if err != nil {
+		logger.Panicf("cannot add command %q: %v", "aspect", err)
+	}
+	// Add all the sub-commands of the aspect command
+	registerCommands(cli, parser, aspectCommand, aspectCommands, func(ci *cmdInfo) {
+		checkUnique(ci, "aspect ")
+	})
+	// Add the prompting-rules command
+	promptingRulesCommand, err := parser.AddCommand("prompting-rules", shortPromptingRulesHelp, longPromptingRulesHelp, &cmdPromptingRules{})
+	if err != nil {
+		logger.Panicf("cannot add command %q: %v", "prompting-rules", err)
+	}
+	// Add all the sub-commands of the prompting-rules command
+	registerCommands(cli, parser, promptingRulesCommand, promptingRulesCommands, func(ci *cmdInfo) {
+		checkUnique(ci, "prompting-rules ")
+	})
 	return parser
 }
 
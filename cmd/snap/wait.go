@@ -35,6 +35,10 @@ import (
 var (
 	maxGoneTime = 5 * time.Second
 	pollTime    = 100 * time.Millisecond
+	// maintenanceCheckTime is how often to check for maintenance, such
+	// as a system restart, while following change updates, as it is
+	// only reported with regular responses.
+	maintenanceCheckTime = 1 * time.Second
 )
 
 type waitMixin struct {
@@ -82,12 +86,54 @@ func (wmx waitMixin) wait(id string) (*client.Change, error) {
 
 	tMax := time.Time{}
 
+	// follow the change updates as they are streamed by the daemon,
+	// falling back to polling once the stream ends without the change
+	// being ready (or the daemon doesn't support streaming)
+	var updates <-chan *client.Change
+	follow := true
+	var lastChg *client.Change
+	var lastCheck time.Time
+	nextChange := func() (*client.Change, error) {
+		if updates == nil && follow {
+			ch, err := cli.WatchChange(id)
+			if err != nil {
+				return nil, err
+			}
+			updates = ch
+			lastCheck = time.Now()
+		}
+		for updates != nil {
+			select {
+			case chg, ok := <-updates:
+				if ok {
+					lastChg = chg
+					return chg, nil
+				}
+				updates = nil
+				follow = false
+			case <-time.After(pollTime):
+				if time.Since(lastCheck) >= maintenanceCheckTime {
+					// a regular request also refreshes the
+					// maintenance reported by the daemon
+					lastCheck = time.Now()
+					return cli.Change(id)
+				}
+				if lastChg != nil {
+					// nothing new, go through the last
+					// update again to keep the spinner going
+					return lastChg, nil
+				}
+			}
+		}
+		return cli.Change(id)
+	}
+
 	var lastID string
 	lastLog := map[string]string{}
 	var waitCtrlcMsg sync.Once
 	for {
 		var rebootingErr error
-		chg, err := cli.Change(id)
+		chg, err := nextChange()
 		if err != nil {
 			// a client.Error means we were able to communicate with
 			// the server (got an answer)
@@ -174,6 +220,11 @@ func (wmx waitMixin) wait(id string) (*client.Change, error) {
 			return nil, rebootingErr
 		}
 
+		if updates != nil {
+			// the next update is pushed by the daemon
+			continue
+		}
+
 		// note this very purposely is not a ticker; we want
 		// to sleep 100ms between calls, not call once every
 		// 100ms.
@@ -0,0 +1,61 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/sandbox/apparmor/notify/listener"
+)
+
+var (
+	Run           = run
+	HandleRequest = handleRequest
+)
+
+type RequestListener = requestListener
+
+type PromptingClient = promptingClient
+
+func MockListenerRegister(f func() (RequestListener, error)) (restore func()) {
+	old := listenerRegister
+	listenerRegister = f
+	return func() {
+		listenerRegister = old
+	}
+}
+
+func MockNewClient(f func() PromptingClient) (restore func()) {
+	old := newClient
+	newClient = f
+	return func() {
+		newClient = old
+	}
+}
+
+func MockReplyToRequest(f func(req *listener.Request, allow bool) error) (restore func()) {
+	old := replyToRequest
+	replyToRequest = f
+	return func() {
+		replyToRequest = old
+	}
+}
+
+// make sure the client implements the interface
+var _ PromptingClient = (*client.Client)(nil)
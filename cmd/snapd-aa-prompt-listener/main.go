@@ -17,13 +17,22 @@
  *
  */
 
+// snapd-aa-prompt-listener receives the AppArmor prompting notifications of
+// the kernel, forwards the access requests to snapd, which decides on them
+// either from the existing rules or by asking the user, and sends the
+// decisions back to the kernel.
 package main
 
 import (
 	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
 
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/interfaces/prompting"
 	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/sandbox/apparmor/notify/listener"
 	"github.com/snapcore/snapd/snapdtool"
 )
 
@@ -34,8 +43,91 @@ func init() {
 	}
 }
 
+// requestListener is the part of listener.Listener used here.
+type requestListener interface {
+	Reqs() <-chan *listener.Request
+	Run() error
+	Close() error
+}
+
+// promptingClient is the part of client.Client used here.
+type promptingClient interface {
+	PromptingForward(req *client.PromptingKernelRequest) (allow bool, err error)
+}
+
+var (
+	listenerRegister = func() (requestListener, error) {
+		return listener.Register()
+	}
+	newClient = func() promptingClient {
+		return client.New(nil)
+	}
+	replyToRequest = (*listener.Request).Reply
+)
+
+// handleRequest asks snapd to decide on the request and replies to the
+// kernel. The access is denied if snapd cannot decide.
+func handleRequest(cli promptingClient, req *listener.Request) {
+	allow := false
+	defer func() {
+		if err := replyToRequest(req, allow); err != nil {
+			logger.Noticef("cannot reply to request for %q: %v", req.Path, err)
+		}
+	}()
+
+	perms, err := prompting.PermissionsFromFilePermission(req.Permission)
+	if err != nil {
+		logger.Noticef("denying request for %q: %v", req.Path, err)
+		return
+	}
+
+	allow, err = cli.PromptingForward(&client.PromptingKernelRequest{
+		Pid:         req.Pid,
+		Label:       req.Label,
+		SubjectUID:  req.SubjectUID,
+		Path:        req.Path,
+		Permissions: perms,
+	})
+	if err != nil {
+		logger.Noticef("denying request for %q: cannot forward it to snapd: %v", req.Path, err)
+		allow = false
+	}
+}
+
+func run(sigs <-chan os.Signal) error {
+	l, err := listenerRegister()
+	if err != nil {
+		if err == listener.ErrNotSupported {
+			logger.Noticef("AppArmor prompting is not supported by the kernel, exiting")
+			return nil
+		}
+		return err
+	}
+
+	go func() {
+		<-sigs
+		l.Close()
+	}()
+
+	cli := newClient()
+	go func() {
+		// the channel is closed when Run returns
+		for req := range l.Reqs() {
+			go handleRequest(cli, req)
+		}
+	}()
+
+	return l.Run()
+}
+
 func main() {
 	snapdtool.ExecInSnapdOrCoreSnap()
-	// This point is only reached if reexec did not happen
-	fmt.Fprintln(os.Stderr, "AA Prompt listener not implemented")
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+
+	if err := run(sigs); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
 }
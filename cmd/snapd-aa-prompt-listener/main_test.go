@@ -0,0 +1,183 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main_test
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/client"
+	main "github.com/snapcore/snapd/cmd/snapd-aa-prompt-listener"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/sandbox/apparmor/notify"
+	"github.com/snapcore/snapd/sandbox/apparmor/notify/listener"
+	"github.com/snapcore/snapd/testutil"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type listenerSuite struct {
+	testutil.BaseTest
+
+	replies chan bool
+}
+
+var _ = Suite(&listenerSuite{})
+
+func (s *listenerSuite) SetUpTest(c *C) {
+	s.BaseTest.SetUpTest(c)
+
+	s.replies = make(chan bool, 10)
+	s.AddCleanup(main.MockReplyToRequest(func(req *listener.Request, allow bool) error {
+		s.replies <- allow
+		return nil
+	}))
+}
+
+type fakeClient struct {
+	forwarded chan *client.PromptingKernelRequest
+	allow     bool
+	err       error
+}
+
+func (cli *fakeClient) PromptingForward(req *client.PromptingKernelRequest) (bool, error) {
+	cli.forwarded <- req
+	return cli.allow, cli.err
+}
+
+type fakeListener struct {
+	reqs   chan *listener.Request
+	closed chan struct{}
+}
+
+func (l *fakeListener) Reqs() <-chan *listener.Request {
+	return l.reqs
+}
+
+func (l *fakeListener) Run() error {
+	<-l.closed
+	close(l.reqs)
+	return nil
+}
+
+func (l *fakeListener) Close() error {
+	close(l.closed)
+	return nil
+}
+
+func (s *listenerSuite) waitReply(c *C) bool {
+	select {
+	case allow := <-s.replies:
+		return allow
+	case <-time.After(5 * time.Second):
+		c.Fatal("timeout waiting for the reply")
+	}
+	return false
+}
+
+func (s *listenerSuite) TestHandleRequest(c *C) {
+	for _, allow := range []bool{true, false} {
+		cli := &fakeClient{forwarded: make(chan *client.PromptingKernelRequest, 1), allow: allow}
+		main.HandleRequest(cli, &listener.Request{
+			Pid:        1234,
+			Label:      "snap.foo.app",
+			SubjectUID: 1000,
+			Path:       "/home/test/file.txt",
+			Permission: notify.AA_MAY_READ | notify.AA_MAY_OPEN | notify.AA_MAY_WRITE,
+		})
+
+		c.Check(<-cli.forwarded, DeepEquals, &client.PromptingKernelRequest{
+			Pid:         1234,
+			Label:       "snap.foo.app",
+			SubjectUID:  1000,
+			Path:        "/home/test/file.txt",
+			Permissions: []string{"read", "write"},
+		})
+		c.Check(s.waitReply(c), Equals, allow)
+	}
+}
+
+func (s *listenerSuite) TestHandleRequestDeniedOnErrors(c *C) {
+	logbuf, restore := logger.MockLogger()
+	defer restore()
+
+	cli := &fakeClient{forwarded: make(chan *client.PromptingKernelRequest, 1), allow: true, err: errors.New("boom")}
+	main.HandleRequest(cli, &listener.Request{Path: "/foo", Permission: notify.AA_MAY_READ})
+	c.Check(s.waitReply(c), Equals, false)
+	c.Check(logbuf.String(), testutil.Contains, `denying request for "/foo": cannot forward it to snapd: boom`)
+
+	main.HandleRequest(cli, &listener.Request{Path: "/bar", Permission: 1 << 20})
+	c.Check(s.waitReply(c), Equals, false)
+	c.Check(cli.forwarded, HasLen, 1)
+	c.Check(logbuf.String(), testutil.Contains, `denying request for "/bar": cannot map unknown file permissions: 0x100000`)
+}
+
+func (s *listenerSuite) TestRun(c *C) {
+	l := &fakeListener{reqs: make(chan *listener.Request), closed: make(chan struct{})}
+	restore := main.MockListenerRegister(func() (main.RequestListener, error) {
+		return l, nil
+	})
+	defer restore()
+
+	cli := &fakeClient{forwarded: make(chan *client.PromptingKernelRequest, 1), allow: true}
+	restore = main.MockNewClient(func() main.PromptingClient { return cli })
+	defer restore()
+
+	sigs := make(chan os.Signal, 1)
+	runErr := make(chan error, 1)
+	go func() { runErr <- main.Run(sigs) }()
+
+	l.reqs <- &listener.Request{Label: "snap.foo.app", Path: "/foo", Permission: notify.AA_MAY_EXEC}
+	req := <-cli.forwarded
+	c.Check(req.Permissions, DeepEquals, []string{"execute"})
+	c.Check(s.waitReply(c), Equals, true)
+
+	sigs <- syscall.SIGTERM
+	select {
+	case err := <-runErr:
+		c.Check(err, IsNil)
+	case <-time.After(5 * time.Second):
+		c.Fatal("timeout waiting for run to return")
+	}
+}
+
+func (s *listenerSuite) TestRunNotSupported(c *C) {
+	logbuf, restore := logger.MockLogger()
+	defer restore()
+
+	restore = main.MockListenerRegister(func() (main.RequestListener, error) {
+		return nil, listener.ErrNotSupported
+	})
+	defer restore()
+
+	c.Check(main.Run(nil), IsNil)
+	c.Check(logbuf.String(), testutil.Contains, "AppArmor prompting is not supported by the kernel, exiting")
+
+	restore = main.MockListenerRegister(func() (main.RequestListener, error) {
+		return nil, errors.New("boom")
+	})
+	defer restore()
+	c.Check(main.Run(nil), ErrorMatches, "boom")
+}
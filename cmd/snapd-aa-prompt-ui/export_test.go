@@ -1,4 +1,5 @@
 // -*- Mode: Go; indent-tabs-mode: t -*-
+
 /*
  * Copyright (C) 2023 Canonical Ltd
  *
@@ -16,19 +17,31 @@
  *
  */
 
-package aspecttest
+package main
+
+import (
+	"time"
+)
+
+var (
+	Run     = run
+	RunOnce = runOnce
+)
+
+type PromptingClient = promptingClient
+
+func MockNewClient(f func() PromptingClient) (restore func()) {
+	old := newClient
+	newClient = f
+	return func() {
+		newClient = old
+	}
+}
 
-// MockWifiSetupAspect returns some mocked aspect access patterns for the
-// system/network/wifi-setup. This will eventually be replaced by proper
-// aspect assertions.
-func MockWifiSetupAspect() map[string]interface{} {
-	return map[string]interface{}{
-		"wifi-setup": []map[string]string{
-			{"name": "ssids", "path": "wifi.ssids"},
-			{"name": "ssid", "path": "wifi.ssid", "access": "read-write"},
-			{"name": "password", "path": "wifi.psk", "access": "write"},
-			{"name": "status", "path": "wifi.status", "access": "read"},
-			{"name": "private.{placeholder}", "path": "wifi.{placeholder}"},
-		},
+func MockPollInterval(d time.Duration) (restore func()) {
+	old := pollInterval
+	pollInterval = d
+	return func() {
+		pollInterval = old
 	}
 }
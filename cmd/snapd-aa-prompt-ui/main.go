@@ -17,12 +17,21 @@
  *
  */
 
+// snapd-aa-prompt-ui is a minimal terminal front-end for AppArmor prompting:
+// it polls snapd for the pending prompting requests of the calling user and
+// asks about each of them on the terminal.
 package main
 
 import (
+	"bufio"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"strings"
+	"time"
 
+	"github.com/snapcore/snapd/client"
 	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/snapdtool"
 )
@@ -34,8 +43,145 @@ func init() {
 	}
 }
 
+type promptingClient interface {
+	PromptingRequests() ([]*client.PromptingRequest, error)
+	PromptingReply(id string, reply *client.PromptingReply) (*client.PromptingRule, error)
+}
+
+var (
+	newClient = func() promptingClient {
+		return client.New(nil)
+	}
+
+	pollInterval = 1 * time.Second
+)
+
+// errInputClosed is returned when the terminal input was closed while
+// asking about a request.
+var errInputClosed = errors.New("input closed")
+
+// ask prints the question to out and returns the answer read from in, or
+// def if the answer is empty.
+func ask(in *bufio.Reader, out io.Writer, question, def string) (string, error) {
+	fmt.Fprint(out, question)
+	line, err := in.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		if err == io.EOF {
+			return "", errInputClosed
+		}
+		return "", err
+	}
+	answer := strings.TrimSpace(line)
+	if answer == "" {
+		return def, nil
+	}
+	return answer, nil
+}
+
+// askReply asks the user how to reply to the given request.
+func askReply(in *bufio.Reader, out io.Writer, req *client.PromptingRequest) (*client.PromptingReply, error) {
+	fmt.Fprintf(out, "Snap %q (app %q) wants to %s %q\n", req.Snap, req.App, strings.Join(req.Permissions, ","), req.Path)
+
+	reply := &client.PromptingReply{}
+	for reply.Outcome == "" {
+		answer, err := ask(in, out, "Allow? [y/N] ", "n")
+		if err != nil {
+			return nil, err
+		}
+		switch strings.ToLower(answer) {
+		case "y", "yes":
+			reply.Outcome = "allow"
+		case "n", "no":
+			reply.Outcome = "deny"
+		}
+	}
+	for reply.Lifespan == "" {
+		answer, err := ask(in, out, "Remember the decision? [O]nce/[s]ession/[f]orever ", "o")
+		if err != nil {
+			return nil, err
+		}
+		switch strings.ToLower(answer) {
+		case "o", "once":
+			reply.Lifespan = "once"
+		case "s", "session":
+			reply.Lifespan = "session"
+		case "f", "forever":
+			reply.Lifespan = "forever"
+		}
+	}
+	if reply.Lifespan != "once" {
+		answer, err := ask(in, out, fmt.Sprintf("Apply to path pattern [%s] ", req.Path), req.Path)
+		if err != nil {
+			return nil, err
+		}
+		reply.PathPattern = answer
+	}
+	return reply, nil
+}
+
+// runOnce asks about all the pending requests which were not seen before and
+// sends the replies to snapd.
+func runOnce(cli promptingClient, in *bufio.Reader, out io.Writer, seen map[string]bool) error {
+	reqs, err := cli.PromptingRequests()
+	if err != nil {
+		return fmt.Errorf("cannot list prompting requests: %v", err)
+	}
+
+	pending := make(map[string]bool, len(reqs))
+	for _, req := range reqs {
+		pending[req.ID] = true
+		if seen[req.ID] {
+			continue
+		}
+		seen[req.ID] = true
+
+		reply, err := askReply(in, out, req)
+		if err != nil {
+			return err
+		}
+		rule, err := cli.PromptingReply(req.ID, reply)
+		if err != nil {
+			var cerr *client.Error
+			if errors.As(err, &cerr) && cerr.StatusCode == 404 {
+				fmt.Fprintf(out, "Request for %q is no longer pending\n", req.Path)
+				continue
+			}
+			return fmt.Errorf("cannot reply to prompting request: %v", err)
+		}
+		if rule != nil {
+			fmt.Fprintf(out, "Added rule %s: %s %s on %q\n", rule.ID, rule.Outcome, strings.Join(rule.Permissions, ","), rule.PathPattern)
+		}
+	}
+
+	// forget about requests which are gone
+	for id := range seen {
+		if !pending[id] {
+			delete(seen, id)
+		}
+	}
+	return nil
+}
+
+func run(in io.Reader, out io.Writer) error {
+	cli := newClient()
+	bufIn := bufio.NewReader(in)
+	seen := make(map[string]bool)
+	for {
+		if err := runOnce(cli, bufIn, out, seen); err != nil {
+			if err == errInputClosed {
+				return nil
+			}
+			return err
+		}
+		time.Sleep(pollInterval)
+	}
+}
+
 func main() {
 	snapdtool.ExecInSnapdOrCoreSnap()
 	// This point is only reached if reexec did not happen
-	fmt.Fprintln(os.Stderr, "AA Prompt UI not implemented")
+	if err := run(os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
 }
@@ -0,0 +1,152 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main_test
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/client"
+	main "github.com/snapcore/snapd/cmd/snapd-aa-prompt-ui"
+	"github.com/snapcore/snapd/testutil"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type uiSuite struct {
+	testutil.BaseTest
+}
+
+var _ = Suite(&uiSuite{})
+
+type fakeClient struct {
+	reqs     []*client.PromptingRequest
+	listErr  error
+	replies  map[string]*client.PromptingReply
+	replyErr error
+}
+
+func (cli *fakeClient) PromptingRequests() ([]*client.PromptingRequest, error) {
+	return cli.reqs, cli.listErr
+}
+
+func (cli *fakeClient) PromptingReply(id string, reply *client.PromptingReply) (*client.PromptingRule, error) {
+	if cli.replyErr != nil {
+		return nil, cli.replyErr
+	}
+	cli.replies[id] = reply
+	if reply.Lifespan == "once" {
+		return nil, nil
+	}
+	return &client.PromptingRule{
+		ID:          "0000000000000001",
+		PathPattern: reply.PathPattern,
+		Permissions: []string{"read"},
+		Outcome:     reply.Outcome,
+	}, nil
+}
+
+func newFakeClient(reqs ...*client.PromptingRequest) *fakeClient {
+	return &fakeClient{
+		reqs:    reqs,
+		replies: make(map[string]*client.PromptingReply),
+	}
+}
+
+var (
+	req1 = &client.PromptingRequest{ID: "1", Snap: "foo", App: "app", Path: "/home/test/a.txt", Permissions: []string{"read"}}
+	req2 = &client.PromptingRequest{ID: "2", Snap: "bar", App: "bar", Path: "/home/test/b.txt", Permissions: []string{"write"}}
+)
+
+func (s *uiSuite) TestRunOnce(c *C) {
+	cli := newFakeClient(req1, req2)
+	in := bufio.NewReader(strings.NewReader("y\n\nn\nbogus\nf\n/home/test/**\n"))
+	var out bytes.Buffer
+	seen := make(map[string]bool)
+
+	c.Assert(main.RunOnce(cli, in, &out, seen), IsNil)
+	c.Check(cli.replies, DeepEquals, map[string]*client.PromptingReply{
+		"1": {Outcome: "allow", Lifespan: "once"},
+		"2": {Outcome: "deny", Lifespan: "forever", PathPattern: "/home/test/**"},
+	})
+	c.Check(out.String(), testutil.Contains, `Snap "foo" (app "app") wants to read "/home/test/a.txt"`)
+	c.Check(out.String(), testutil.Contains, `Snap "bar" (app "bar") wants to write "/home/test/b.txt"`)
+	c.Check(out.String(), testutil.Contains, `Added rule 0000000000000001: deny read on "/home/test/**"`)
+	c.Check(seen, DeepEquals, map[string]bool{"1": true, "2": true})
+
+	// already seen requests are not asked about again, gone ones are
+	// forgotten
+	cli.reqs = []*client.PromptingRequest{req2}
+	cli.replies = make(map[string]*client.PromptingReply)
+	c.Assert(main.RunOnce(cli, in, &out, seen), IsNil)
+	c.Check(cli.replies, HasLen, 0)
+	c.Check(seen, DeepEquals, map[string]bool{"2": true})
+}
+
+func (s *uiSuite) TestRunOnceDefaultPathPattern(c *C) {
+	cli := newFakeClient(req1)
+	in := bufio.NewReader(strings.NewReader("yes\nsession\n\n"))
+	var out bytes.Buffer
+
+	c.Assert(main.RunOnce(cli, in, &out, make(map[string]bool)), IsNil)
+	c.Check(cli.replies, DeepEquals, map[string]*client.PromptingReply{
+		"1": {Outcome: "allow", Lifespan: "session", PathPattern: "/home/test/a.txt"},
+	})
+}
+
+func (s *uiSuite) TestRunOnceNoLongerPending(c *C) {
+	cli := newFakeClient(req1)
+	cli.replyErr = &client.Error{Message: "no request with the given ID is pending", StatusCode: 404}
+	in := bufio.NewReader(strings.NewReader("y\no\n"))
+	var out bytes.Buffer
+
+	c.Assert(main.RunOnce(cli, in, &out, make(map[string]bool)), IsNil)
+	c.Check(out.String(), testutil.Contains, `Request for "/home/test/a.txt" is no longer pending`)
+}
+
+func (s *uiSuite) TestRunOnceErrors(c *C) {
+	var out bytes.Buffer
+
+	cli := newFakeClient()
+	cli.listErr = errors.New("boom")
+	err := main.RunOnce(cli, bufio.NewReader(strings.NewReader("")), &out, make(map[string]bool))
+	c.Check(err, ErrorMatches, "cannot list prompting requests: boom")
+
+	cli = newFakeClient(req1)
+	cli.replyErr = &client.Error{Message: "boom", StatusCode: 500}
+	err = main.RunOnce(cli, bufio.NewReader(strings.NewReader("y\no\n")), &out, make(map[string]bool))
+	c.Check(err, ErrorMatches, "cannot reply to prompting request: boom")
+}
+
+func (s *uiSuite) TestRunStopsWhenInputIsClosed(c *C) {
+	s.AddCleanup(main.MockPollInterval(time.Millisecond))
+	cli := newFakeClient(req1)
+	s.AddCleanup(main.MockNewClient(func() main.PromptingClient { return cli }))
+
+	var out bytes.Buffer
+	c.Check(main.Run(strings.NewReader("n\n"), &out), IsNil)
+	c.Check(cli.replies, HasLen, 0)
+}
@@ -67,6 +67,7 @@ var api = []*Command{
 	debugCmd,
 	snapshotCmd,
 	snapshotExportCmd,
+	execCmd,
 	connectionsCmd,
 	modelCmd,
 	cohortsCmd,
@@ -81,6 +82,13 @@ var api = []*Command{
 	systemRecoveryKeysCmd,
 	quotaGroupsCmd,
 	quotaGroupInfoCmd,
+	aspectsCmd,
+	promptingRequestsCmd,
+	promptingRequestCmd,
+	promptingRulesCmd,
+	promptingRuleCmd,
+	noticesCmd,
+	noticeCmd,
 }
 
 const (
@@ -150,6 +158,8 @@ var (
 	snapstateHoldRefreshesBySystem          = snapstate.HoldRefreshesBySystem
 	snapstateLongestGatingHold              = snapstate.LongestGatingHold
 	snapstateSystemHold                     = snapstate.SystemHold
+	snapstateInstallComponentPath           = snapstate.InstallComponentPath
+	snapstateRemoveComponent                = snapstate.RemoveComponent
 
 	configstateConfigureInstalled = configstate.ConfigureInstalled
 
@@ -0,0 +1,136 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package daemon
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/snapcore/snapd/aspects"
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/jsonutil"
+	"github.com/snapcore/snapd/overlord/aspectstate"
+	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/strutil"
+)
+
+var (
+	aspectsCmd = &Command{
+		Path:        "/v2/aspects/{account}/{bundle}/{aspect}",
+		GET:         getAspect,
+		PUT:         setAspect,
+		ReadAccess:  authenticatedAccess{Polkit: polkitActionManage},
+		WriteAccess: authenticatedAccess{Polkit: polkitActionManage},
+	}
+)
+
+var (
+	aspectstateGet = aspectstate.Get
+	aspectstateSet = aspectstate.Set
+)
+
+func getAspect(c *Command, r *http.Request, _ *auth.UserState) Response {
+	vars := muxVars(r)
+	account, bundleName, aspect := vars["account"], vars["bundle"], vars["aspect"]
+
+	fields := strutil.CommaSeparatedList(r.URL.Query().Get("fields"))
+	if len(fields) == 0 {
+		return BadRequest("missing aspect fields")
+	}
+
+	st := c.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+
+	results := make(map[string]interface{}, len(fields))
+	for _, field := range fields {
+		var value interface{}
+		if err := aspectstateGet(st, account, bundleName, aspect, field, &value); err != nil {
+			return aspectErrorToResponse(err)
+		}
+		results[field] = value
+	}
+
+	return SyncResponse(results)
+}
+
+func setAspect(c *Command, r *http.Request, _ *auth.UserState) Response {
+	vars := muxVars(r)
+	account, bundleName, aspect := vars["account"], vars["bundle"], vars["aspect"]
+
+	var values map[string]interface{}
+	if err := jsonutil.DecodeWithNumber(r.Body, &values); err != nil {
+		return BadRequest("cannot decode aspect request body: %v", err)
+	}
+	if len(values) == 0 {
+		return BadRequest("missing aspect fields to set")
+	}
+
+	st := c.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+
+	chg, err := aspectstateSet(st, account, bundleName, aspect, values)
+	if err != nil {
+		return aspectErrorToResponse(err)
+	}
+
+	ensureStateSoon(st)
+
+	return AsyncResponse(nil, chg.ID())
+}
+
+func aspectErrorToResponse(err error) Response {
+	var vErr *aspects.ValidationError
+	var conflErr *snapstate.ChangeConflictError
+	switch {
+	case errors.Is(err, &aspects.AspectNotFoundError{}):
+		return &apiError{
+			Status:  404,
+			Message: err.Error(),
+			Kind:    client.ErrorKindAspectNotFound,
+		}
+	case errors.Is(err, &aspects.FieldNotFoundError{}):
+		return &apiError{
+			Status:  404,
+			Message: err.Error(),
+			Kind:    client.ErrorKindConfigNoSuchOption,
+		}
+	case errors.Is(err, &aspects.InvalidAccessError{}):
+		return &apiError{
+			Status:  403,
+			Message: err.Error(),
+		}
+	case errors.As(err, &conflErr):
+		return SnapChangeConflict(conflErr)
+	case errors.As(err, &vErr):
+		return &apiError{
+			Status:  400,
+			Message: err.Error(),
+			Kind:    client.ErrorKindAspectInvalidValue,
+			Value: map[string]interface{}{
+				"path": vErr.PathString(),
+			},
+		}
+	default:
+		return InternalError(err.Error())
+	}
+}
@@ -0,0 +1,255 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package daemon_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/aspects"
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/daemon"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+type aspectsSuite struct {
+	apiBaseSuite
+
+	ensureSoonCalled int
+}
+
+var _ = check.Suite(&aspectsSuite{})
+
+func (s *aspectsSuite) SetUpTest(c *check.C) {
+	s.apiBaseSuite.SetUpTest(c)
+
+	s.expectReadAccess(daemon.AuthenticatedAccess{Polkit: "io.snapcraft.snapd.manage"})
+	s.expectWriteAccess(daemon.AuthenticatedAccess{Polkit: "io.snapcraft.snapd.manage"})
+
+	s.ensureSoonCalled = 0
+	_, restore := daemon.MockEnsureStateSoon(func(st *state.State) {
+		s.ensureSoonCalled++
+	})
+	s.AddCleanup(restore)
+}
+
+func (s *aspectsSuite) TestGetAspect(c *check.C) {
+	s.daemon(c)
+
+	var calls []string
+	restore := daemon.MockAspectstateGet(func(_ *state.State, acc, bundle, aspect, field string, value interface{}) error {
+		c.Check(acc, check.Equals, "system")
+		c.Check(bundle, check.Equals, "network")
+		c.Check(aspect, check.Equals, "wifi-setup")
+		calls = append(calls, field)
+
+		switch field {
+		case "ssid":
+			*value.(*interface{}) = "foo"
+		case "ssids":
+			*value.(*interface{}) = []interface{}{"foo", "bar"}
+		}
+		return nil
+	})
+	defer restore()
+
+	req, err := http.NewRequest("GET", "/v2/aspects/system/network/wifi-setup?fields=ssid,ssids", nil)
+	c.Assert(err, check.IsNil)
+
+	rsp := s.syncReq(c, req, nil)
+	c.Check(rsp.Status, check.Equals, 200)
+	c.Check(rsp.Result, check.DeepEquals, map[string]interface{}{
+		"ssid":  "foo",
+		"ssids": []interface{}{"foo", "bar"},
+	})
+	c.Check(calls, check.DeepEquals, []string{"ssid", "ssids"})
+}
+
+func (s *aspectsSuite) TestGetAspectNoFields(c *check.C) {
+	s.daemon(c)
+
+	req, err := http.NewRequest("GET", "/v2/aspects/system/network/wifi-setup", nil)
+	c.Assert(err, check.IsNil)
+
+	rspe := s.errorReq(c, req, nil)
+	c.Check(rspe.Status, check.Equals, 400)
+	c.Check(rspe.Message, check.Equals, "missing aspect fields")
+}
+
+func (s *aspectsSuite) TestAspectErrors(c *check.C) {
+	s.daemon(c)
+
+	type testcase struct {
+		err     error
+		status  int
+		kind    client.ErrorKind
+		message string
+	}
+
+	for _, t := range []testcase{
+		{
+			err:     &aspects.AspectNotFoundError{Account: "system", BundleName: "network", Aspect: "wifi-setup"},
+			status:  404,
+			kind:    client.ErrorKindAspectNotFound,
+			message: "aspect system/network/wifi-setup not found",
+		},
+		{
+			err:     &aspects.FieldNotFoundError{Message: `cannot get field "ssid": not found`},
+			status:  404,
+			kind:    client.ErrorKindConfigNoSuchOption,
+			message: `cannot get field "ssid": not found`,
+		},
+		{
+			err:     &aspects.InvalidAccessError{Field: "ssid"},
+			status:  403,
+			message: `cannot get field "ssid": path is not readable`,
+		},
+		{
+			err:     errors.New("boom"),
+			status:  500,
+			message: "boom",
+		},
+	} {
+		restore := daemon.MockAspectstateGet(func(*state.State, string, string, string, string, interface{}) error {
+			return t.err
+		})
+
+		req, err := http.NewRequest("GET", "/v2/aspects/system/network/wifi-setup?fields=ssid", nil)
+		c.Assert(err, check.IsNil)
+
+		rspe := s.errorReq(c, req, nil)
+		c.Check(rspe.Status, check.Equals, t.status)
+		c.Check(rspe.Kind, check.Equals, t.kind)
+		c.Check(rspe.Message, check.Equals, t.message)
+
+		restore()
+	}
+}
+
+func (s *aspectsSuite) TestSetAspect(c *check.C) {
+	s.daemon(c)
+
+	var chgID string
+	restore := daemon.MockAspectstateSet(func(st *state.State, acc, bundle, aspect string, values map[string]interface{}) (*state.Change, error) {
+		c.Check(acc, check.Equals, "system")
+		c.Check(bundle, check.Equals, "network")
+		c.Check(aspect, check.Equals, "wifi-setup")
+		c.Check(values, check.DeepEquals, map[string]interface{}{"ssid": "foo", "password": nil})
+
+		chg := st.NewChange("set-aspect", "Set aspect system/network/wifi-setup")
+		chgID = chg.ID()
+		return chg, nil
+	})
+	defer restore()
+
+	body, err := json.Marshal(map[string]interface{}{"ssid": "foo", "password": nil})
+	c.Assert(err, check.IsNil)
+	req, err := http.NewRequest("PUT", "/v2/aspects/system/network/wifi-setup", bytes.NewReader(body))
+	c.Assert(err, check.IsNil)
+
+	rsp := s.asyncReq(c, req, nil)
+	c.Check(rsp.Status, check.Equals, 202)
+	c.Check(rsp.Change, check.Equals, chgID)
+	c.Check(s.ensureSoonCalled, check.Equals, 1)
+}
+
+func (s *aspectsSuite) TestSetAspectConflict(c *check.C) {
+	s.daemon(c)
+
+	restore := daemon.MockAspectstateSet(func(*state.State, string, string, string, map[string]interface{}) (*state.Change, error) {
+		return nil, &snapstate.ChangeConflictError{
+			Message:    "cannot write aspect bundle system/network: change 1 in progress",
+			ChangeKind: "set-aspect",
+			ChangeID:   "1",
+		}
+	})
+	defer restore()
+
+	req, err := http.NewRequest("PUT", "/v2/aspects/system/network/wifi-setup", strings.NewReader(`{"ssid": "foo"}`))
+	c.Assert(err, check.IsNil)
+
+	rspe := s.errorReq(c, req, nil)
+	c.Check(rspe.Status, check.Equals, 409)
+	c.Check(rspe.Kind, check.Equals, client.ErrorKindSnapChangeConflict)
+	c.Check(rspe.Message, check.Equals, "cannot write aspect bundle system/network: change 1 in progress")
+	c.Check(s.ensureSoonCalled, check.Equals, 0)
+}
+
+func (s *aspectsSuite) TestSetAspectBadRequest(c *check.C) {
+	s.daemon(c)
+
+	for _, t := range []struct {
+		body string
+		err  string
+	}{
+		{body: `{`, err: `cannot decode aspect request body: .*`},
+		{body: `{}`, err: `missing aspect fields to set`},
+	} {
+		req, err := http.NewRequest("PUT", "/v2/aspects/system/network/wifi-setup", strings.NewReader(t.body))
+		c.Assert(err, check.IsNil)
+
+		rspe := s.errorReq(c, req, nil)
+		c.Check(rspe.Status, check.Equals, 400)
+		c.Check(rspe.Message, check.Matches, t.err)
+	}
+}
+
+func (s *aspectsSuite) TestSetAspectInvalidValue(c *check.C) {
+	s.daemon(c)
+
+	restore := daemon.MockAspectstateSet(func(*state.State, string, string, string, map[string]interface{}) (*state.Change, error) {
+		return nil, &aspects.ValidationError{
+			Path: []interface{}{"wifi", "ssids", 1},
+			Err:  errors.New("expected string type but value was number"),
+		}
+	})
+	defer restore()
+
+	req, err := http.NewRequest("PUT", "/v2/aspects/system/network/wifi-setup", strings.NewReader(`{"ssids": ["a", 1]}`))
+	c.Assert(err, check.IsNil)
+
+	rspe := s.errorReq(c, req, nil)
+	c.Check(rspe.Status, check.Equals, 400)
+	c.Check(rspe.Kind, check.Equals, client.ErrorKindAspectInvalidValue)
+	c.Check(rspe.Message, check.Equals, `cannot accept element in "wifi.ssids[1]": expected string type but value was number`)
+	c.Check(rspe.Value, check.DeepEquals, map[string]interface{}{"path": "wifi.ssids[1]"})
+}
+
+func (s *aspectsSuite) TestSetAspectAccessError(c *check.C) {
+	s.daemon(c)
+
+	restore := daemon.MockAspectstateSet(func(*state.State, string, string, string, map[string]interface{}) (*state.Change, error) {
+		return nil, &aspects.InvalidAccessError{Field: "status"}
+	})
+	defer restore()
+
+	req, err := http.NewRequest("PUT", "/v2/aspects/system/network/wifi-setup", strings.NewReader(`{"status": "foo"}`))
+	c.Assert(err, check.IsNil)
+
+	rspe := s.errorReq(c, req, nil)
+	c.Check(rspe.Status, check.Equals, 403)
+	c.Check(rspe.Kind, check.Equals, client.ErrorKind(""))
+}
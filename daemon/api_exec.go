@@ -0,0 +1,417 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package daemon
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/user"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/osutil/sys"
+	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/snap"
+)
+
+var execCmd = &Command{
+	Path:        "/v2/exec",
+	POST:        postExec,
+	WriteAccess: rootAccess{},
+}
+
+var (
+	userLookup    = user.Lookup
+	osutilOpenPty = osutil.OpenPty
+)
+
+func postExec(c *Command, r *http.Request, _ *auth.UserState) Response {
+	if !strings.EqualFold(r.Header.Get("Upgrade"), client.ExecUpgradeProtocol) {
+		return BadRequest("exec requires upgrading the connection to %q", client.ExecUpgradeProtocol)
+	}
+
+	var opts client.ExecOptions
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&opts); err != nil {
+		return BadRequest("cannot decode request body into exec options: %v", err)
+	}
+	if len(opts.Command) == 0 {
+		return BadRequest("exec requires a command")
+	}
+	if opts.Width < 0 || opts.Height < 0 {
+		return BadRequest("invalid terminal size %dx%d", opts.Width, opts.Height)
+	}
+
+	snapName, appName := snap.SplitSnapApp(opts.Command[0])
+	st := c.d.overlord.State()
+	st.Lock()
+	info, err := snapstate.CurrentInfo(st, snapName)
+	st.Unlock()
+	if err != nil {
+		var notInstalled *snap.NotInstalledError
+		if errors.As(err, &notInstalled) {
+			return SnapNotFound(snapName, err)
+		}
+		return InternalError("%v", err)
+	}
+	app := info.Apps[appName]
+	if app == nil {
+		return AppNotFound("snap %q has no app %q", snapName, appName)
+	}
+
+	username := opts.User
+	if username == "" {
+		username = "root"
+	}
+	usr, err := userLookup(username)
+	if err != nil {
+		return BadRequest("cannot exec as user %q: %v", username, err)
+	}
+	cred, err := execCredential(usr)
+	if err != nil {
+		return InternalError("cannot exec as user %q: %v", username, err)
+	}
+
+	dir := opts.WorkingDir
+	if dir == "" {
+		dir = usr.HomeDir
+	}
+
+	return &execResponse{
+		argv:    append([]string{app.WrapperPath()}, opts.Command[1:]...),
+		env:     execEnvironment(usr, opts.Environment),
+		dir:     dir,
+		cred:    cred,
+		tty:     opts.Tty,
+		width:   opts.Width,
+		height:  opts.Height,
+		tracker: c.d.connTracker,
+	}
+}
+
+// execCredential returns the credential to run commands as the given user
+// with, or nil if that is the user snapd runs as.
+func execCredential(usr *user.User) (*syscall.Credential, error) {
+	uid, err := strconv.ParseUint(usr.Uid, 10, 32)
+	if err != nil {
+		return nil, err
+	}
+	if sys.UserID(uid) == sys.Geteuid() {
+		return nil, nil
+	}
+	gid, err := strconv.ParseUint(usr.Gid, 10, 32)
+	if err != nil {
+		return nil, err
+	}
+	groupIDs, err := usr.GroupIds()
+	if err != nil {
+		return nil, err
+	}
+	groups := make([]uint32, 0, len(groupIDs))
+	for _, g := range groupIDs {
+		id, err := strconv.ParseUint(g, 10, 32)
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, uint32(id))
+	}
+	return &syscall.Credential{Uid: uint32(uid), Gid: uint32(gid), Groups: groups}, nil
+}
+
+// execEnvironment returns a minimal login-like environment for the user,
+// with the requested variables on top.
+func execEnvironment(usr *user.User, extra map[string]string) []string {
+	env := osutil.Environment{
+		"HOME":            usr.HomeDir,
+		"USER":            usr.Username,
+		"LOGNAME":         usr.Username,
+		"PATH":            "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin:" + dirs.SnapBinariesDir,
+		"XDG_RUNTIME_DIR": fmt.Sprintf("%s/%s", dirs.XdgRuntimeDirBase, usr.Uid),
+	}
+	for k, v := range extra {
+		env[k] = v
+	}
+	return env.ForExec()
+}
+
+// execResponse runs a command, streaming its input and output over the
+// upgraded connection until it finishes.
+type execResponse struct {
+	argv   []string
+	env    []string
+	dir    string
+	cred   *syscall.Credential
+	tty    bool
+	width  int
+	height int
+
+	tracker *connTracker
+}
+
+func (rsp *execResponse) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		InternalError("cannot exec: connection cannot be upgraded").ServeHTTP(w, r)
+		return
+	}
+
+	sess, err := rsp.start()
+	if err != nil {
+		InternalError("cannot exec: %v", err).ServeHTTP(w, r)
+		return
+	}
+
+	// what is left of the request body must not be taken for frames
+	io.Copy(ioutil.Discard, r.Body)
+	conn, bufrw, err := hj.Hijack()
+	if err != nil {
+		logger.Noticef("cannot exec: %v", err)
+		sess.kill()
+		sess.wait()
+		return
+	}
+	defer conn.Close()
+	if rsp.tracker != nil {
+		// the connection is no longer tracked once hijacked, but
+		// snapd should not go into standby while the command runs
+		rsp.tracker.trackConn(conn, http.StateActive)
+		defer rsp.tracker.trackConn(conn, http.StateClosed)
+	}
+
+	fmt.Fprintf(conn, "HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: %s\r\n\r\n", client.ExecUpgradeProtocol)
+	sess.run(bufrw.Reader, conn)
+}
+
+type execSession struct {
+	cmd  *exec.Cmd
+	ptmx *os.File
+
+	stdin   io.WriteCloser
+	outputs map[byte]io.Reader
+
+	mu       sync.Mutex
+	finished bool
+}
+
+func (rsp *execResponse) start() (*execSession, error) {
+	cmd := exec.Command(rsp.argv[0], rsp.argv[1:]...)
+	cmd.Env = rsp.env
+	cmd.Dir = rsp.dir
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		// a session of its own, so that signals can go to the
+		// whole process group
+		Setsid:     true,
+		Credential: rsp.cred,
+	}
+	sess := &execSession{cmd: cmd}
+
+	if rsp.tty {
+		ptmx, pts, err := osutilOpenPty()
+		if err != nil {
+			return nil, err
+		}
+		defer pts.Close()
+		if rsp.width > 0 && rsp.height > 0 {
+			if err := osutil.SetTerminalSize(ptmx, rsp.width, rsp.height); err != nil {
+				ptmx.Close()
+				return nil, err
+			}
+		}
+		cmd.Stdin, cmd.Stdout, cmd.Stderr = pts, pts, pts
+		cmd.SysProcAttr.Setctty = true
+		if err := cmd.Start(); err != nil {
+			ptmx.Close()
+			return nil, err
+		}
+		sess.ptmx = ptmx
+		sess.stdin = ptmx
+		sess.outputs = map[byte]io.Reader{client.ExecStreamStdout: ptmx}
+		return sess, nil
+	}
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	sess.stdin = stdin
+	sess.outputs = map[byte]io.Reader{
+		client.ExecStreamStdout: stdout,
+		client.ExecStreamStderr: stderr,
+	}
+	return sess, nil
+}
+
+type execFrameWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (fw *execFrameWriter) writeFrame(stream byte, payload []byte) error {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+	return client.WriteExecFrame(fw.w, stream, payload)
+}
+
+func (sess *execSession) run(r io.Reader, w io.Writer) {
+	fw := &execFrameWriter{w: w}
+
+	var wg sync.WaitGroup
+	for stream, out := range sess.outputs {
+		wg.Add(1)
+		go func(stream byte, out io.Reader) {
+			defer wg.Done()
+			buf := make([]byte, 32*1024)
+			for {
+				n, err := out.Read(buf)
+				if n > 0 {
+					// keep draining even if the client is gone
+					fw.writeFrame(stream, buf[:n])
+				}
+				if err != nil {
+					// io.EOF, or EIO from a terminal
+					return
+				}
+			}
+		}(stream, out)
+	}
+	go sess.handleInput(r)
+	wg.Wait()
+
+	exit := sess.wait()
+	payload, err := json.Marshal(exit)
+	if err != nil {
+		logger.Noticef("cannot marshal exit status: %v", err)
+		return
+	}
+	fw.writeFrame(client.ExecStreamExit, payload)
+}
+
+func (sess *execSession) wait() *client.ExecExit {
+	err := sess.cmd.Wait()
+	sess.mu.Lock()
+	sess.finished = true
+	sess.mu.Unlock()
+	if sess.ptmx != nil {
+		sess.ptmx.Close()
+	}
+
+	if err == nil {
+		return &client.ExecExit{}
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		if ws, ok := exitErr.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+			return &client.ExecExit{ExitCode: 128 + int(ws.Signal())}
+		}
+		return &client.ExecExit{ExitCode: exitErr.ExitCode()}
+	}
+	return &client.ExecExit{ExitCode: -1, Error: err.Error()}
+}
+
+// signal sends sig to the process group of the command, unless it
+// already finished.
+func (sess *execSession) signal(sig syscall.Signal) error {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	if sess.finished {
+		return nil
+	}
+	return syscall.Kill(-sess.cmd.Process.Pid, sig)
+}
+
+func (sess *execSession) kill() {
+	if err := sess.signal(syscall.SIGKILL); err != nil {
+		logger.Noticef("cannot kill command: %v", err)
+	}
+}
+
+func (sess *execSession) handleInput(r io.Reader) {
+	for {
+		stream, payload, err := client.ReadExecFrame(r)
+		if err != nil {
+			// the client went away, or the command finished and
+			// the connection was closed
+			sess.kill()
+			return
+		}
+		switch stream {
+		case client.ExecStreamStdin:
+			if len(payload) > 0 {
+				sess.stdin.Write(payload)
+				continue
+			}
+			if sess.ptmx != nil {
+				// end of transmission, as if ^D was typed
+				sess.ptmx.Write([]byte{4})
+			} else {
+				sess.stdin.Close()
+			}
+		case client.ExecStreamControl:
+			var ctl client.ExecControl
+			if err := json.Unmarshal(payload, &ctl); err != nil {
+				logger.Debugf("cannot decode exec control message: %v", err)
+				continue
+			}
+			if err := sess.control(&ctl); err != nil {
+				logger.Debugf("cannot handle exec control message: %v", err)
+			}
+		default:
+			logger.Debugf("unexpected exec stream %d", stream)
+		}
+	}
+}
+
+func (sess *execSession) control(ctl *client.ExecControl) error {
+	switch ctl.Command {
+	case "resize":
+		if sess.ptmx == nil {
+			return fmt.Errorf("cannot resize: command is not running in a terminal")
+		}
+		return osutil.SetTerminalSize(sess.ptmx, ctl.Width, ctl.Height)
+	case "signal":
+		return sess.signal(syscall.Signal(ctl.Signal))
+	default:
+		return fmt.Errorf("unknown command %q", ctl.Command)
+	}
+}
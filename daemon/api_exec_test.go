@@ -0,0 +1,247 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package daemon_test
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/daemon"
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/testutil"
+)
+
+var _ = check.Suite(&execSuite{})
+
+type execSuite struct {
+	apiBaseSuite
+
+	home string
+}
+
+func (s *execSuite) SetUpTest(c *check.C) {
+	s.apiBaseSuite.SetUpTest(c)
+	s.daemon(c)
+	s.expectRootAccess()
+
+	s.home = c.MkDir()
+	s.AddCleanup(daemon.MockUserLookup(func(name string) (*user.User, error) {
+		if name != "root" && name != "test" {
+			return nil, user.UnknownUserError(name)
+		}
+		// whoever runs the tests, so no switching is needed
+		return &user.User{
+			Uid:      fmt.Sprint(os.Geteuid()),
+			Gid:      fmt.Sprint(os.Getegid()),
+			Username: name,
+			HomeDir:  s.home,
+		}, nil
+	}))
+
+	s.mockSnap(c, "{name: foo, version: 1, apps: {foo: {command: bin/foo}, bar: {command: bin/bar}}}")
+}
+
+func snapWrapper(app string) string {
+	return filepath.Join(dirs.SnapBinariesDir, app)
+}
+
+func execRequest(c *check.C, body string) *http.Request {
+	req, err := http.NewRequest("POST", "/v2/exec", strings.NewReader(body))
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", client.ExecUpgradeProtocol)
+	return req
+}
+
+func (s *execSuite) TestExecErrors(c *check.C) {
+	req, err := http.NewRequest("POST", "/v2/exec", strings.NewReader(`{"command": ["foo"]}`))
+	c.Assert(err, check.IsNil)
+	rspe := s.errorReq(c, req, nil)
+	c.Check(rspe.Status, check.Equals, 400)
+	c.Check(rspe.Message, check.Equals, `exec requires upgrading the connection to "snapd-exec"`)
+
+	for _, t := range []struct {
+		body    string
+		status  int
+		message string
+	}{
+		{`"foo`, 400, `cannot decode request body into exec options: .*`},
+		{`{}`, 400, `exec requires a command`},
+		{`{"command": ["foo"], "tty": true, "width": -1}`, 400, `invalid terminal size -1x0`},
+		{`{"command": ["bar"]}`, 404, `snap "bar" is not installed`},
+		{`{"command": ["foo.baz"]}`, 404, `snap "foo" has no app "baz"`},
+		{`{"command": ["foo"], "user": "nobody-here"}`, 400, `cannot exec as user "nobody-here": user: unknown user nobody-here`},
+	} {
+		rspe := s.errorReq(c, execRequest(c, t.body), nil)
+		c.Check(rspe.Status, check.Equals, t.status, check.Commentf(t.body))
+		c.Check(rspe.Message, check.Matches, t.message, check.Commentf(t.body))
+	}
+}
+
+// exec runs the response to the given request behind a real server, so the
+// connection can be upgraded, and talks to it with the client.
+func (s *execSuite) exec(c *check.C, body string, opts *client.ExecOptions, stdio *client.ExecStdio) (int, error) {
+	rsp := s.req(c, execRequest(c, body), nil)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rsp.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+	cli := client.New(&client.Config{BaseURL: srv.URL})
+	return cli.Exec(opts, stdio)
+}
+
+func (s *execSuite) TestExec(c *check.C) {
+	cmd := testutil.MockCommand(c, snapWrapper("foo.bar"), `
+echo "out: $*"
+echo "home: $HOME user: $USER extra: $EXTRA pwd: $PWD"
+echo "err" >&2
+read line
+echo "in: $line"
+exit 3
+`)
+	defer cmd.Restore()
+
+	opts := &client.ExecOptions{
+		Command:     []string{"foo.bar", "--baz", "quux"},
+		User:        "test",
+		Environment: map[string]string{"EXTRA": "yes"},
+	}
+	body := `{"command": ["foo.bar", "--baz", "quux"], "user": "test", "environment": {"EXTRA": "yes"}}`
+	var stdout, stderr bytes.Buffer
+	code, err := s.exec(c, body, opts, &client.ExecStdio{
+		Stdin:  strings.NewReader("some input\n"),
+		Stdout: &stdout,
+		Stderr: &stderr,
+	})
+	c.Assert(err, check.IsNil)
+	c.Check(code, check.Equals, 3)
+	c.Check(stdout.String(), check.Equals, fmt.Sprintf("out: --baz quux\nhome: %s user: test extra: yes pwd: %s\nin: some input\n", s.home, s.home))
+	c.Check(stderr.String(), check.Equals, "err\n")
+	c.Check(cmd.Calls(), check.DeepEquals, [][]string{{"foo.bar", "--baz", "quux"}})
+}
+
+func (s *execSuite) TestExecSignaled(c *check.C) {
+	cmd := testutil.MockCommand(c, snapWrapper("foo"), `kill -TERM $$`)
+	defer cmd.Restore()
+
+	code, err := s.exec(c, `{"command": ["foo"]}`, &client.ExecOptions{Command: []string{"foo"}}, &client.ExecStdio{})
+	c.Assert(err, check.IsNil)
+	c.Check(code, check.Equals, 128+15)
+}
+
+func (s *execSuite) TestExecTty(c *check.C) {
+	cmd := testutil.MockCommand(c, snapWrapper("foo"), `
+if [ -t 0 ] && [ -t 1 ]; then echo "in a tty"; fi
+stty size
+read line
+echo "got $line"
+`)
+	defer cmd.Restore()
+
+	var stdout bytes.Buffer
+	opts := &client.ExecOptions{Command: []string{"foo"}, Tty: true, Width: 80, Height: 25}
+	code, err := s.exec(c, `{"command": ["foo"], "tty": true, "width": 80, "height": 25}`, opts, &client.ExecStdio{
+		Stdin:  strings.NewReader("go\n"),
+		Stdout: &stdout,
+	})
+	c.Assert(err, check.IsNil)
+	c.Check(code, check.Equals, 0)
+	// the terminal translates newlines, and echoes the input at
+	// whatever point it arrives
+	c.Check(stdout.String(), testutil.Contains, "in a tty\r\n")
+	c.Check(stdout.String(), testutil.Contains, "25 80\r\n")
+	c.Check(stdout.String(), testutil.Contains, "got go\r\n")
+}
+
+func (s *execSuite) TestExecTtyResize(c *check.C) {
+	cmd := testutil.MockCommand(c, snapWrapper("foo"), `
+for _ in $(seq 100); do
+    if [ "$(stty size)" = "40 100" ]; then
+        echo resized
+        exit 0
+    fi
+    sleep 0.05
+done
+exit 1
+`)
+	defer cmd.Restore()
+
+	control := make(chan *client.ExecControl, 1)
+	control <- &client.ExecControl{Command: "resize", Width: 100, Height: 40}
+	var stdout bytes.Buffer
+	opts := &client.ExecOptions{Command: []string{"foo"}, Tty: true}
+	code, err := s.exec(c, `{"command": ["foo"], "tty": true}`, opts, &client.ExecStdio{
+		Stdout:  &stdout,
+		Control: control,
+	})
+	c.Assert(err, check.IsNil)
+	c.Check(code, check.Equals, 0)
+	c.Check(stdout.String(), check.Equals, "resized\r\n")
+}
+
+func (s *execSuite) TestExecClientGoesAwayKillsCommand(c *check.C) {
+	marker := filepath.Join(c.MkDir(), "marker")
+	cmd := testutil.MockCommand(c, snapWrapper("foo"), fmt.Sprintf(`
+echo started
+sleep 10
+touch %q
+`, marker))
+	defer cmd.Restore()
+
+	rsp := s.req(c, execRequest(c, `{"command": ["foo"]}`), nil)
+	done := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rsp.ServeHTTP(w, r)
+		close(done)
+	}))
+	defer srv.Close()
+
+	conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+	c.Assert(err, check.IsNil)
+	fmt.Fprintf(conn, "POST /v2/exec HTTP/1.1\r\nHost: localhost\r\nConnection: Upgrade\r\nUpgrade: snapd-exec\r\nContent-Length: 0\r\n\r\n")
+	br := bufio.NewReader(conn)
+	hrsp, err := http.ReadResponse(br, nil)
+	c.Assert(err, check.IsNil)
+	c.Check(hrsp.StatusCode, check.Equals, 101)
+	stream, payload, err := client.ReadExecFrame(br)
+	c.Assert(err, check.IsNil)
+	c.Check(stream, check.Equals, client.ExecStreamStdout)
+	c.Check(string(payload), check.Equals, "started\n")
+	conn.Close()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		c.Fatal("command was not killed")
+	}
+	c.Check(marker, testutil.FileAbsent)
+}
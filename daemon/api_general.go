@@ -26,6 +26,7 @@ import (
 	"net/http"
 	"os/exec"
 	"sort"
+	"strconv"
 	"time"
 
 	"github.com/snapcore/snapd/arch"
@@ -210,6 +211,18 @@ func getChange(c *Command, r *http.Request, user *auth.UserState) Response {
 		return NotFound("cannot find change with id %q", chID)
 	}
 
+	follow := false
+	if s := r.URL.Query().Get("follow"); s != "" {
+		f, err := strconv.ParseBool(s)
+		if err != nil {
+			return BadRequest(`invalid value for follow: %q: %v`, s, err)
+		}
+		follow = f
+	}
+	if follow {
+		return &changeSeqResponse{st: state, chg: chg, dying: c.d.Dying()}
+	}
+
 	return SyncResponse(change2changeInfo(chg))
 }
 
@@ -21,6 +21,7 @@ package daemon_test
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -599,6 +600,164 @@ func (s *generalSuite) TestStateChange(c *check.C) {
 	})
 }
 
+// seqRecorder is a http.ResponseWriter that hands every write over a
+// channel, so that streamed records can be checked as they are written.
+type seqRecorder struct {
+	header http.Header
+	writes chan []byte
+}
+
+func newSeqRecorder() *seqRecorder {
+	return &seqRecorder{header: make(http.Header), writes: make(chan []byte)}
+}
+
+func (r *seqRecorder) Header() http.Header { return r.header }
+func (r *seqRecorder) WriteHeader(int)     {}
+func (r *seqRecorder) Write(b []byte) (int, error) {
+	r.writes <- append([]byte(nil), b...)
+	return len(b), nil
+}
+
+func (r *seqRecorder) next(c *check.C) map[string]interface{} {
+	var buf []byte
+	select {
+	case buf = <-r.writes:
+	case <-time.After(5 * time.Second):
+		c.Fatalf("timeout waiting for a streamed record")
+	}
+	c.Assert(len(buf) > 2, check.Equals, true)
+	c.Assert(buf[0], check.Equals, byte(0x1E))
+	c.Assert(buf[len(buf)-1], check.Equals, byte('\n'))
+	var record map[string]interface{}
+	c.Assert(json.Unmarshal(buf[1:], &record), check.IsNil)
+	return record
+}
+
+func (s *generalSuite) TestStateChangeFollow(c *check.C) {
+	restore := state.MockTime(time.Date(2016, 04, 21, 1, 2, 3, 0, time.UTC))
+	defer restore()
+
+	d := s.daemon(c)
+	st := d.Overlord().State()
+	st.Lock()
+	ids := setupChanges(st)
+	st.Unlock()
+
+	req, err := http.NewRequest("GET", "/v2/changes/"+ids[0]+"?follow=true", nil)
+	c.Assert(err, check.IsNil)
+	rsp := s.req(c, req, nil)
+
+	rec := newSeqRecorder()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		rsp.ServeHTTP(rec, req)
+	}()
+
+	record := rec.next(c)
+	c.Check(rec.Header().Get("Content-Type"), check.Equals, "application/json-seq")
+	c.Check(record["id"], check.Equals, ids[0])
+	c.Check(record["status"], check.Equals, "Do")
+	c.Check(record["ready"], check.Equals, false)
+
+	st.Lock()
+	t1 := st.Task(ids[2])
+	t1.SetStatus(state.DoingStatus)
+	t1.SetProgress("downloading", 5, 10)
+	st.Unlock()
+
+	record = rec.next(c)
+	c.Check(record["status"], check.Equals, "Doing")
+	task := record["tasks"].([]interface{})[0].(map[string]interface{})
+	c.Check(task["status"], check.Equals, "Doing")
+	c.Check(task["progress"], check.DeepEquals, map[string]interface{}{"label": "downloading", "done": 5., "total": 10.})
+
+	st.Lock()
+	st.Change(ids[0]).SetStatus(state.DoneStatus)
+	st.Unlock()
+
+	record = rec.next(c)
+	c.Check(record["status"], check.Equals, "Done")
+	c.Check(record["ready"], check.Equals, true)
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		c.Fatalf("stream did not end with the change being ready")
+	}
+}
+
+func (s *generalSuite) TestStateChangeFollowReady(c *check.C) {
+	d := s.daemon(c)
+	st := d.Overlord().State()
+	st.Lock()
+	ids := setupChanges(st)
+	st.Unlock()
+
+	req, err := http.NewRequest("GET", "/v2/changes/"+ids[1]+"?follow=true", nil)
+	c.Assert(err, check.IsNil)
+	rsp := s.req(c, req, nil)
+
+	rec := httptest.NewRecorder()
+	rsp.ServeHTTP(rec, req)
+
+	c.Check(rec.Code, check.Equals, 200)
+	c.Check(rec.Header().Get("Content-Type"), check.Equals, "application/json-seq")
+	body := rec.Body.Bytes()
+	c.Assert(bytes.Count(body, []byte{0x1E}), check.Equals, 1)
+	var record map[string]interface{}
+	c.Assert(json.Unmarshal(body[1:], &record), check.IsNil)
+	c.Check(record["id"], check.Equals, ids[1])
+	c.Check(record["status"], check.Equals, "Error")
+	c.Check(record["ready"], check.Equals, true)
+}
+
+func (s *generalSuite) TestStateChangeFollowClientGone(c *check.C) {
+	d := s.daemon(c)
+	st := d.Overlord().State()
+	st.Lock()
+	ids := setupChanges(st)
+	st.Unlock()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req, err := http.NewRequest("GET", "/v2/changes/"+ids[0]+"?follow=true", nil)
+	c.Assert(err, check.IsNil)
+	req = req.WithContext(ctx)
+	rsp := s.req(c, req, nil)
+
+	rec := newSeqRecorder()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		rsp.ServeHTTP(rec, req)
+	}()
+
+	record := rec.next(c)
+	c.Check(record["ready"], check.Equals, false)
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		c.Fatalf("stream did not end with the client going away")
+	}
+}
+
+func (s *generalSuite) TestStateChangeFollowInvalid(c *check.C) {
+	d := s.daemon(c)
+	st := d.Overlord().State()
+	st.Lock()
+	ids := setupChanges(st)
+	st.Unlock()
+
+	req, err := http.NewRequest("GET", "/v2/changes/"+ids[0]+"?follow=maybe", nil)
+	c.Assert(err, check.IsNil)
+
+	rspe := s.errorReq(c, req, nil)
+	c.Check(rspe.Status, check.Equals, 400)
+	c.Check(rspe.Message, check.Equals, `invalid value for follow: "maybe": strconv.ParseBool: parsing "maybe": invalid syntax`)
+}
+
 func (s *generalSuite) expectManageAccess() {
 	s.expectWriteAccess(daemon.AuthenticatedAccess{Polkit: "io.snapcraft.snapd.manage"})
 }
@@ -48,13 +48,31 @@ var (
 func interfacesConnectionsMultiplexer(c *Command, r *http.Request, user *auth.UserState) Response {
 	query := r.URL.Query()
 	qselect := query.Get("select")
-	if qselect == "" {
+	switch qselect {
+	case "":
 		return getLegacyConnections(c, r, user)
-	} else {
+	case "hotplug-rules":
+		return getHotplugRules(c, r, user)
+	default:
 		return getInterfaces(c, r, user)
 	}
 }
 
+func getHotplugRules(c *Command, r *http.Request, user *auth.UserState) Response {
+	st := c.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+
+	rules, err := ifacestate.HotplugRules(st)
+	if err != nil {
+		return InternalError("%v", err)
+	}
+	if rules == nil {
+		rules = []*ifacestate.HotplugRule{}
+	}
+	return SyncResponse(rules)
+}
+
 func getInterfaces(c *Command, r *http.Request, user *auth.UserState) Response {
 	// Collect query options from request arguments.
 	q := r.URL.Query()
@@ -132,12 +150,18 @@ func changeInterfaces(c *Command, r *http.Request, user *auth.UserState) Respons
 	if a.Action == "" {
 		return BadRequest("interface action not specified")
 	}
+	if a.Action == "add-hotplug-rule" || a.Action == "remove-hotplug-rule" {
+		return changeHotplugRules(c, &a)
+	}
 	if len(a.Plugs) > 1 || len(a.Slots) > 1 {
 		return NotImplemented("many-to-many operations are not implemented")
 	}
 	if a.Action != "connect" && a.Action != "disconnect" {
 		return BadRequest("unsupported interface action: %q", a.Action)
 	}
+	if a.PersistRule && a.Action != "connect" {
+		return BadRequest("persist-rule can only be used with the connect action")
+	}
 	if len(a.Plugs) == 0 || len(a.Slots) == 0 {
 		return BadRequest("at least one plug and slot is required")
 	}
@@ -190,12 +214,29 @@ func changeInterfaces(c *Command, r *http.Request, user *auth.UserState) Respons
 			var ts *state.TaskSet
 			affected = snapNamesFromConns([]*interfaces.ConnRef{connRef})
 			summary = fmt.Sprintf("Connect %s:%s to %s:%s", connRef.PlugRef.Snap, connRef.PlugRef.Name, connRef.SlotRef.Snap, connRef.SlotRef.Name)
+			var rule *ifacestate.HotplugRule
+			if a.PersistRule {
+				rule, err = ifacestate.HotplugRuleForConnection(st, connRef)
+				if err != nil {
+					return BadRequest("%v", err)
+				}
+			}
 			ts, err = ifacestate.Connect(st, connRef.PlugRef.Snap, connRef.PlugRef.Name, connRef.SlotRef.Snap, connRef.SlotRef.Name)
 			if _, ok := err.(*ifacestate.ErrAlreadyConnected); ok {
+				if rule != nil {
+					if err := ifacestate.AddHotplugRule(st, rule); err != nil {
+						return InternalError("%v", err)
+					}
+				}
 				change := newChange(st, a.Action+"-snap", summary, nil, affected)
 				change.SetStatus(state.DoneStatus)
 				return AsyncResponse(nil, change.ID())
 			}
+			if err == nil && rule != nil {
+				if err := ifacestate.AddHotplugRule(st, rule); err != nil {
+					return InternalError("%v", err)
+				}
+			}
 			tasksets = append(tasksets, ts)
 		}
 	case "disconnect":
@@ -241,6 +282,36 @@ func changeInterfaces(c *Command, r *http.Request, user *auth.UserState) Respons
 	return AsyncResponse(nil, change.ID())
 }
 
+// changeHotplugRules adds or removes the hotplug rules deciding how
+// hotplugged devices are connected.
+func changeHotplugRules(c *Command, a *interfaceAction) Response {
+	if a.HotplugRule == nil {
+		return BadRequest("hotplug rule not specified")
+	}
+
+	st := c.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+
+	switch a.Action {
+	case "add-hotplug-rule":
+		rule := *a.HotplugRule
+		if err := ifacestate.AddHotplugRule(st, &rule); err != nil {
+			return BadRequest("%v", err)
+		}
+		return SyncResponse(&rule)
+	default:
+		err := ifacestate.RemoveHotplugRule(st, a.HotplugRule.ID)
+		if _, ok := err.(*ifacestate.NoHotplugRuleError); ok {
+			return NotFound("%v", err)
+		}
+		if err != nil {
+			return InternalError("%v", err)
+		}
+		return SyncResponse(nil)
+	}
+}
+
 func snapNamesFromConns(conns []*interfaces.ConnRef) []string {
 	m := make(map[string]bool)
 	for _, conn := range conns {
@@ -1185,3 +1185,148 @@ func (s *interfacesSuite) TestInterfacesModern(c *check.C) {
 		"type":        "sync",
 	})
 }
+
+func (s *interfacesSuite) TestConnectPersistRule(c *check.C) {
+	revert := builtin.MockInterface(&ifacetest.TestInterface{InterfaceName: "test"})
+	defer revert()
+	d := s.daemon(c)
+
+	s.mockSnap(c, consumerYaml)
+	s.mockSnap(c, coreProducerYaml)
+
+	d.Overlord().Loop()
+	defer d.Overlord().Stop()
+
+	st := d.Overlord().State()
+	st.Lock()
+	st.Set("hotplug-slots", map[string]*ifacestate.HotplugSlotInfo{
+		"slot": {
+			Name:       "slot",
+			Interface:  "test",
+			HotplugKey: "1234",
+			Device:     map[string]string{"ID_VENDOR_ID": "046d", "ID_MODEL_ID": "0825"},
+		},
+	})
+	st.Unlock()
+
+	action := &client.InterfaceAction{
+		Action:      "connect",
+		PersistRule: true,
+		Plugs:       []client.Plug{{Snap: "consumer", Name: "plug"}},
+		Slots:       []client.Slot{{Snap: "core", Name: "slot"}},
+	}
+	text, err := json.Marshal(action)
+	c.Assert(err, check.IsNil)
+	req, err := http.NewRequest("POST", "/v2/interfaces", bytes.NewBuffer(text))
+	c.Assert(err, check.IsNil)
+	rsp := s.asyncReq(c, req, nil)
+
+	st.Lock()
+	chg := st.Change(rsp.Change)
+	st.Unlock()
+	c.Assert(chg, check.NotNil)
+
+	<-chg.Ready()
+
+	st.Lock()
+	defer st.Unlock()
+	c.Assert(chg.Err(), check.IsNil)
+
+	rules, err := ifacestate.HotplugRules(st)
+	c.Assert(err, check.IsNil)
+	c.Check(rules, check.DeepEquals, []*ifacestate.HotplugRule{{
+		ID:        1,
+		Interface: "test",
+		Match:     map[string]string{"ID_VENDOR_ID": "046d", "ID_MODEL_ID": "0825"},
+		Snap:      "consumer",
+		Plug:      "plug",
+		Action:    "connect",
+	}})
+}
+
+func (s *interfacesSuite) TestConnectPersistRuleNotHotplug(c *check.C) {
+	revert := builtin.MockInterface(&ifacetest.TestInterface{InterfaceName: "test"})
+	defer revert()
+	s.daemon(c)
+
+	s.mockSnap(c, consumerYaml)
+	s.mockSnap(c, producerYaml)
+
+	action := &client.InterfaceAction{
+		Action:      "connect",
+		PersistRule: true,
+		Plugs:       []client.Plug{{Snap: "consumer", Name: "plug"}},
+		Slots:       []client.Slot{{Snap: "producer", Name: "slot"}},
+	}
+	text, err := json.Marshal(action)
+	c.Assert(err, check.IsNil)
+	req, err := http.NewRequest("POST", "/v2/interfaces", bytes.NewBuffer(text))
+	c.Assert(err, check.IsNil)
+	rspe := s.errorReq(c, req, nil)
+	c.Check(rspe.Status, check.Equals, 400)
+	c.Check(rspe.Message, check.Equals, `cannot persist a rule for slot producer:slot: not a hotplug slot`)
+
+	action.Action = "disconnect"
+	text, err = json.Marshal(action)
+	c.Assert(err, check.IsNil)
+	req, err = http.NewRequest("POST", "/v2/interfaces", bytes.NewBuffer(text))
+	c.Assert(err, check.IsNil)
+	rspe = s.errorReq(c, req, nil)
+	c.Check(rspe.Status, check.Equals, 400)
+	c.Check(rspe.Message, check.Equals, `persist-rule can only be used with the connect action`)
+}
+
+func (s *interfacesSuite) TestHotplugRules(c *check.C) {
+	s.daemon(c)
+
+	hotplugRuleAction := func(action string, rule *client.HotplugRule) *http.Request {
+		text, err := json.Marshal(&client.InterfaceAction{Action: action, HotplugRule: rule})
+		c.Assert(err, check.IsNil)
+		req, err := http.NewRequest("POST", "/v2/interfaces", bytes.NewBuffer(text))
+		c.Assert(err, check.IsNil)
+		return req
+	}
+	listReq, err := http.NewRequest("GET", "/v2/interfaces?select=hotplug-rules", nil)
+	c.Assert(err, check.IsNil)
+
+	rsp := s.syncReq(c, listReq, nil)
+	c.Check(rsp.Result, check.DeepEquals, []*ifacestate.HotplugRule{})
+
+	rsp = s.syncReq(c, hotplugRuleAction("add-hotplug-rule", &client.HotplugRule{
+		Interface: "camera",
+		Match:     map[string]string{"ID_VENDOR_ID": "046d"},
+		Snap:      "consumer",
+		Action:    "refuse",
+	}), nil)
+	rule := &ifacestate.HotplugRule{
+		ID:        1,
+		Interface: "camera",
+		Match:     map[string]string{"ID_VENDOR_ID": "046d"},
+		Snap:      "consumer",
+		Action:    "refuse",
+	}
+	c.Check(rsp.Result, check.DeepEquals, rule)
+
+	rsp = s.syncReq(c, listReq, nil)
+	c.Check(rsp.Result, check.DeepEquals, []*ifacestate.HotplugRule{rule})
+
+	rspe := s.errorReq(c, hotplugRuleAction("add-hotplug-rule", &client.HotplugRule{
+		Interface: "camera",
+		Snap:      "consumer",
+		Action:    "refuse",
+	}), nil)
+	c.Check(rspe.Status, check.Equals, 400)
+	c.Check(rspe.Message, check.Equals, "hotplug rule must match at least one device attribute")
+
+	rspe = s.errorReq(c, hotplugRuleAction("add-hotplug-rule", nil), nil)
+	c.Check(rspe.Status, check.Equals, 400)
+	c.Check(rspe.Message, check.Equals, "hotplug rule not specified")
+
+	s.syncReq(c, hotplugRuleAction("remove-hotplug-rule", &client.HotplugRule{ID: 1}), nil)
+	rspe = s.errorReq(c, hotplugRuleAction("remove-hotplug-rule", &client.HotplugRule{ID: 1}), nil)
+	c.Check(rspe.Status, check.Equals, 404)
+	c.Check(rspe.Message, check.Equals, "cannot find hotplug rule 1")
+
+	rsp = s.syncReq(c, listReq, nil)
+	c.Check(rsp.Result, check.DeepEquals, []*ifacestate.HotplugRule{})
+}
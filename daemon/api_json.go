@@ -21,6 +21,7 @@ package daemon
 
 import (
 	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/overlord/ifacestate"
 )
 
 // plugJSON aids in marshaling snap.PlugInfo into JSON.
@@ -58,10 +59,12 @@ type interfaceJSON struct {
 
 // interfaceAction is an action performed on the interface system.
 type interfaceAction struct {
-	Action string     `json:"action"`
-	Forget bool       `json:"forget,omitempty"`
-	Plugs  []plugJSON `json:"plugs,omitempty"`
-	Slots  []slotJSON `json:"slots,omitempty"`
+	Action      string                  `json:"action"`
+	Forget      bool                    `json:"forget,omitempty"`
+	PersistRule bool                    `json:"persist-rule,omitempty"`
+	Plugs       []plugJSON              `json:"plugs,omitempty"`
+	Slots       []slotJSON              `json:"slots,omitempty"`
+	HotplugRule *ifacestate.HotplugRule `json:"hotplug-rule,omitempty"`
 }
 
 // connectionsJSON aids in marshalling information about a single connection
@@ -20,16 +20,25 @@
 package daemon
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
 	"net/http"
+	"strings"
 
 	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/asserts/snapasserts"
 	"github.com/snapcore/snapd/client"
 	"github.com/snapcore/snapd/client/clientutil"
+	"github.com/snapcore/snapd/overlord/assertstate"
 	"github.com/snapcore/snapd/overlord/auth"
 	"github.com/snapcore/snapd/overlord/devicestate"
+	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
 )
 
 var (
@@ -49,40 +58,147 @@ var (
 	}
 )
 
-var devicestateRemodel = devicestate.Remodel
+var (
+	devicestateRemodel        = devicestate.Remodel
+	devicestateRemodelOffline = devicestate.RemodelOffline
+)
 
 type postModelData struct {
 	NewModel string `json:"new-model"`
+	// Offline is set to remodel without contacting the store, using
+	// only the snaps already installed
+	Offline bool `json:"offline,omitempty"`
 }
 
 func postModel(c *Command, r *http.Request, _ *auth.UserState) Response {
 	defer r.Body.Close()
+
+	contentType := r.Header.Get("Content-Type")
+	if strings.HasPrefix(contentType, "multipart/") {
+		_, params, err := mime.ParseMediaType(contentType)
+		if err != nil {
+			return BadRequest("cannot parse content type: %v", err)
+		}
+		return remodelOffline(c, r.Body, params["boundary"])
+	}
+
 	var data postModelData
 	decoder := json.NewDecoder(r.Body)
 	if err := decoder.Decode(&data); err != nil {
 		return BadRequest("cannot decode request body into remodel operation: %v", err)
 	}
-	rawNewModel, err := asserts.Decode([]byte(data.NewModel))
+	newModel, errRsp := decodeNewModel(data.NewModel)
+	if errRsp != nil {
+		return errRsp
+	}
+
+	st := c.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+
+	var chg *state.Change
+	var err error
+	if data.Offline {
+		chg, err = devicestateRemodelOffline(st, newModel, nil, nil)
+	} else {
+		chg, err = devicestateRemodel(st, newModel)
+	}
+	if err != nil {
+		return BadRequest("cannot remodel device: %v", err)
+	}
+	ensureStateSoon(st)
+
+	return AsyncResponse(nil, chg.ID())
+
+}
+
+func decodeNewModel(encoded string) (*asserts.Model, *apiError) {
+	rawNewModel, err := asserts.Decode([]byte(encoded))
 	if err != nil {
-		return BadRequest("cannot decode new model assertion: %v", err)
+		return nil, BadRequest("cannot decode new model assertion: %v", err)
 	}
 	newModel, ok := rawNewModel.(*asserts.Model)
 	if !ok {
-		return BadRequest("new model is not a model assertion: %v", newModel.Type())
+		return nil, BadRequest("new model is not a model assertion: %v", rawNewModel.Type())
+	}
+	return newModel, nil
+}
+
+// remodelOffline performs a remodel using the snap files and assertions
+// uploaded in a multipart/form-data request, without contacting the store.
+// The form carries the new model in a "new-model" value, any number of
+// assertion streams in "assertion" values and the snap files in "snap"
+// file parts.
+func remodelOffline(c *Command, body io.Reader, boundary string) Response {
+	form, errRsp := readForm(multipart.NewReader(body, boundary))
+	if errRsp != nil {
+		return errRsp
+	}
+
+	// we are in charge of the temp files, until they're handed off to the change
+	var pathsToNotRemove []string
+	defer func() {
+		form.RemoveAllExcept(pathsToNotRemove)
+	}()
+
+	if len(form.Values["new-model"]) != 1 {
+		return BadRequest(`cannot find exactly one "new-model" value in provided multipart/form-data payload`)
+	}
+	newModel, errRsp := decodeNewModel(form.Values["new-model"][0])
+	if errRsp != nil {
+		return errRsp
+	}
+
+	batch := asserts.NewBatch(nil)
+	for _, stream := range form.Values["assertion"] {
+		if _, err := batch.AddStream(bytes.NewBufferString(stream)); err != nil {
+			return BadRequest("cannot decode assertions: %v", err)
+		}
 	}
 
 	st := c.d.overlord.State()
 	st.Lock()
 	defer st.Unlock()
 
-	chg, err := devicestateRemodel(st, newModel)
+	if err := assertstate.AddBatch(st, batch, &asserts.CommitOptions{
+		Precheck: true,
+	}); err != nil {
+		return BadRequest("cannot add assertions: %v", err)
+	}
+
+	refs := form.FileRefs["snap"]
+	sideInfos := make([]*snap.SideInfo, 0, len(refs))
+	paths := make([]string, 0, len(refs))
+	for _, ref := range refs {
+		si, err := snapasserts.DeriveSideInfo(ref.TmpPath, newModel, assertstate.DB(st))
+		if err != nil {
+			if errors.Is(err, &asserts.NotFoundError{}) {
+				return BadRequest("cannot find signatures with metadata for snap %q", ref.Filename)
+			}
+			return BadRequest(err.Error())
+		}
+		sideInfos = append(sideInfos, si)
+		paths = append(paths, ref.TmpPath)
+	}
+
+	chg, err := devicestateRemodelOffline(st, newModel, sideInfos, paths)
 	if err != nil {
 		return BadRequest("cannot remodel device: %v", err)
 	}
 	ensureStateSoon(st)
 
-	return AsyncResponse(nil, chg.ID())
+	// the snap files used by the change are removed by it once installed
+	for _, t := range chg.Tasks() {
+		snapsup, err := snapstate.TaskSnapSetup(t)
+		if err != nil {
+			continue
+		}
+		if snapsup.SnapPath != "" {
+			pathsToNotRemove = append(pathsToNotRemove, snapsup.SnapPath)
+		}
+	}
 
+	return AsyncResponse(nil, chg.ID())
 }
 
 // getModel gets the current model assertion using the DeviceManager
@@ -23,9 +23,12 @@ import (
 	"bytes"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
 	"time"
 
 	"gopkg.in/check.v1"
@@ -35,12 +38,18 @@ import (
 	"github.com/snapcore/snapd/client"
 	"github.com/snapcore/snapd/client/clientutil"
 	"github.com/snapcore/snapd/daemon"
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/overlord/assertstate"
 	"github.com/snapcore/snapd/overlord/assertstate/assertstatetest"
 	"github.com/snapcore/snapd/overlord/auth"
 	"github.com/snapcore/snapd/overlord/devicestate"
 	"github.com/snapcore/snapd/overlord/devicestate/devicestatetest"
 	"github.com/snapcore/snapd/overlord/hookstate"
+	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snaptest"
+	"github.com/snapcore/snapd/testutil"
 )
 
 var modelDefaults = map[string]interface{}{
@@ -134,6 +143,182 @@ func (s *modelSuite) TestPostRemodel(c *check.C) {
 	c.Assert(soon, check.Equals, 1)
 }
 
+func (s *modelSuite) setupRemodel(c *check.C) *daemon.Daemon {
+	oldModel := s.Brands.Model("my-brand", "my-old-model", modelDefaults)
+
+	d := s.daemonWithOverlordMockAndStore()
+	hookMgr, err := hookstate.Manager(d.Overlord().State(), d.Overlord().TaskRunner())
+	c.Assert(err, check.IsNil)
+	deviceMgr, err := devicestate.Manager(d.Overlord().State(), hookMgr, d.Overlord().TaskRunner(), nil)
+	c.Assert(err, check.IsNil)
+	d.Overlord().AddManager(deviceMgr)
+	st := d.Overlord().State()
+	st.Lock()
+	defer st.Unlock()
+	assertstatetest.AddMany(st, s.StoreSigning.StoreAccountKey(""))
+	assertstatetest.AddMany(st, s.Brands.AccountsAndKeys("my-brand")...)
+	s.mockModel(st, oldModel)
+	return d
+}
+
+func (s *modelSuite) TestPostRemodelOfflineJSON(c *check.C) {
+	s.expectRootAccess()
+
+	newModel := s.Brands.Model("my-brand", "my-old-model", modelDefaults, map[string]interface{}{
+		"revision": "2",
+	})
+	d := s.setupRemodel(c)
+
+	defer daemon.MockDevicestateRemodel(func(st *state.State, nm *asserts.Model) (*state.Change, error) {
+		c.Fatalf("unexpected online remodel")
+		return nil, nil
+	})()
+	var gotModel *asserts.Model
+	defer daemon.MockDevicestateRemodelOffline(func(st *state.State, nm *asserts.Model, sis []*snap.SideInfo, paths []string) (*state.Change, error) {
+		gotModel = nm
+		c.Check(sis, check.HasLen, 0)
+		c.Check(paths, check.HasLen, 0)
+		return st.NewChange("remodel", "..."), nil
+	})()
+
+	data, err := json.Marshal(daemon.PostModelData{NewModel: string(asserts.Encode(newModel)), Offline: true})
+	c.Assert(err, check.IsNil)
+	req, err := http.NewRequest("POST", "/v2/model", bytes.NewBuffer(data))
+	c.Assert(err, check.IsNil)
+	rsp := s.asyncReq(c, req, nil)
+	c.Assert(rsp.Status, check.Equals, 202)
+	c.Check(gotModel, check.DeepEquals, newModel)
+
+	st := d.Overlord().State()
+	st.Lock()
+	defer st.Unlock()
+	c.Check(st.Change(rsp.Change), check.NotNil)
+}
+
+func (s *modelSuite) remodelOfflineBody(c *check.C, newModel *asserts.Model, assertions []asserts.Assertion, snapContent []byte) *bytes.Buffer {
+	body := new(bytes.Buffer)
+	body.WriteString("----hello--\r\n" +
+		"Content-Disposition: form-data; name=\"new-model\"\r\n\r\n")
+	body.Write(asserts.Encode(newModel))
+	body.WriteString("\r\n")
+	if len(assertions) > 0 {
+		body.WriteString("----hello--\r\n" +
+			"Content-Disposition: form-data; name=\"assertion\"\r\n\r\n")
+		for _, a := range assertions {
+			body.Write(asserts.Encode(a))
+			body.WriteString("\n")
+		}
+		body.WriteString("\r\n")
+	}
+	body.WriteString("----hello--\r\n" +
+		"Content-Disposition: form-data; name=\"snap\"; filename=\"foo.snap\"\r\n\r\n")
+	body.Write(snapContent)
+	body.WriteString("\r\n----hello--\r\n")
+	return body
+}
+
+func (s *modelSuite) TestPostRemodelOffline(c *check.C) {
+	s.expectRootAccess()
+
+	newModel := s.Brands.Model("my-brand", "my-old-model", modelDefaults, map[string]interface{}{
+		"revision":       "2",
+		"required-snaps": []interface{}{"foo"},
+	})
+	d := s.setupRemodel(c)
+
+	fooSnap := snaptest.MakeTestSnapWithFiles(c, `name: foo
+version: 1`, nil)
+	digest, size, err := asserts.SnapFileSHA3_384(fooSnap)
+	c.Assert(err, check.IsNil)
+	snapContent, err := ioutil.ReadFile(fooSnap)
+	c.Assert(err, check.IsNil)
+
+	dev1Acct := assertstest.NewAccount(s.StoreSigning, "devel1", nil, "")
+	snapDecl, err := s.StoreSigning.Sign(asserts.SnapDeclarationType, map[string]interface{}{
+		"series":       "16",
+		"snap-id":      "foo-id",
+		"snap-name":    "foo",
+		"publisher-id": dev1Acct.AccountID(),
+		"timestamp":    time.Now().Format(time.RFC3339),
+	}, nil, "")
+	c.Assert(err, check.IsNil)
+	snapRev, err := s.StoreSigning.Sign(asserts.SnapRevisionType, map[string]interface{}{
+		"snap-sha3-384": digest,
+		"snap-size":     fmt.Sprintf("%d", size),
+		"snap-id":       "foo-id",
+		"snap-revision": "41",
+		"developer-id":  dev1Acct.AccountID(),
+		"timestamp":     time.Now().Format(time.RFC3339),
+	}, nil, "")
+	c.Assert(err, check.IsNil)
+
+	var snapPath string
+	defer daemon.MockDevicestateRemodelOffline(func(st *state.State, nm *asserts.Model, sis []*snap.SideInfo, paths []string) (*state.Change, error) {
+		c.Check(nm, check.DeepEquals, newModel)
+		c.Check(sis, check.DeepEquals, []*snap.SideInfo{{
+			RealName: "foo",
+			SnapID:   "foo-id",
+			Revision: snap.R(41),
+		}})
+		c.Assert(paths, check.HasLen, 1)
+		c.Check(paths[0], testutil.FileEquals, string(snapContent))
+		snapPath = paths[0]
+
+		// the assertions were added
+		_, err := assertstate.DB(st).Find(asserts.SnapRevisionType, map[string]string{
+			"snap-sha3-384": digest,
+		})
+		c.Check(err, check.IsNil)
+
+		t := st.NewTask("fake-install", "...")
+		t.Set("snap-setup", &snapstate.SnapSetup{SideInfo: sis[0], SnapPath: paths[0]})
+		chg := st.NewChange("remodel", "...")
+		chg.AddTask(t)
+		return chg, nil
+	})()
+
+	body := s.remodelOfflineBody(c, newModel, []asserts.Assertion{dev1Acct, snapDecl, snapRev}, snapContent)
+	req, err := http.NewRequest("POST", "/v2/model", body)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", "multipart/form-data; boundary=--hello--")
+	rsp := s.asyncReq(c, req, nil)
+	c.Assert(rsp.Status, check.Equals, 202)
+
+	st := d.Overlord().State()
+	st.Lock()
+	defer st.Unlock()
+	c.Check(st.Change(rsp.Change), check.NotNil)
+	// the snap file was handed off to the change
+	c.Check(snapPath, testutil.FilePresent)
+}
+
+func (s *modelSuite) TestPostRemodelOfflineNoSignatures(c *check.C) {
+	s.expectRootAccess()
+
+	newModel := s.Brands.Model("my-brand", "my-old-model", modelDefaults, map[string]interface{}{
+		"revision": "2",
+	})
+	s.setupRemodel(c)
+
+	defer daemon.MockDevicestateRemodelOffline(func(st *state.State, nm *asserts.Model, sis []*snap.SideInfo, paths []string) (*state.Change, error) {
+		c.Fatalf("unexpected remodel")
+		return nil, nil
+	})()
+
+	body := s.remodelOfflineBody(c, newModel, nil, []byte("foo snap content"))
+	req, err := http.NewRequest("POST", "/v2/model", body)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", "multipart/form-data; boundary=--hello--")
+	rspe := s.errorReq(c, req, nil)
+	c.Check(rspe.Status, check.Equals, 400)
+	c.Check(rspe.Message, check.Equals, `cannot find signatures with metadata for snap "foo.snap"`)
+
+	// the uploaded file was removed
+	matches, err := filepath.Glob(filepath.Join(dirs.SnapBlobDir, dirs.LocalInstallBlobTempPrefix+"*"))
+	c.Assert(err, check.IsNil)
+	c.Check(matches, check.HasLen, 0)
+}
+
 func (s *modelSuite) TestGetModelNoModelAssertion(c *check.C) {
 
 	d := s.daemonWithOverlordMockAndStore()
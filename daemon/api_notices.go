@@ -0,0 +1,166 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package daemon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/strutil"
+)
+
+var (
+	noticesCmd = &Command{
+		Path:       "/v2/notices",
+		GET:        getNotices,
+		ReadAccess: openAccess{},
+	}
+
+	noticeCmd = &Command{
+		Path:       "/v2/notices/{id}",
+		GET:        getNotice,
+		ReadAccess: openAccess{},
+	}
+)
+
+// noticeFilterForRequest returns the notice filter for the user of the
+// request: root can see all notices, other users only see public notices
+// and their own.
+func noticeFilterForRequest(r *http.Request) (*state.NoticeFilter, Response) {
+	uid, rsp := requestUID(r)
+	if rsp != nil {
+		return nil, rsp
+	}
+	filter := &state.NoticeFilter{}
+	if uid != 0 {
+		filter.UserID = &uid
+	}
+	return filter, nil
+}
+
+func getNotices(c *Command, r *http.Request, _ *auth.UserState) Response {
+	query := r.URL.Query()
+
+	filter, rsp := noticeFilterForRequest(r)
+	if rsp != nil {
+		return rsp
+	}
+
+	types, err := noticeTypesFromQuery(query)
+	if err != nil {
+		return BadRequest("%v", err)
+	}
+	filter.Types = types
+	filter.Keys = strutil.CommaSeparatedList(query.Get("keys"))
+
+	if after := query.Get("after"); after != "" {
+		filter.After, err = time.Parse(time.RFC3339Nano, after)
+		if err != nil {
+			return BadRequest("invalid after timestamp %q: %v", after, err)
+		}
+	}
+
+	var timeout time.Duration
+	if timeoutStr := query.Get("timeout"); timeoutStr != "" {
+		timeout, err = time.ParseDuration(timeoutStr)
+		if err != nil || timeout < 0 {
+			return BadRequest("invalid timeout %q", timeoutStr)
+		}
+	}
+
+	st := c.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+
+	var notices []*state.Notice
+	if timeout != 0 {
+		// long-poll until a matching notice shows up, the timeout
+		// elapses, the client goes away or the daemon is stopped
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		go func() {
+			select {
+			case <-c.d.Dying():
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+		notices, err = st.WaitNotices(ctx, filter)
+		switch {
+		case errors.Is(err, context.Canceled):
+			return BadRequest("request canceled")
+		case errors.Is(err, context.DeadlineExceeded):
+			// no matching notices before the timeout
+		case err != nil:
+			return InternalError("cannot wait for notices: %v", err)
+		}
+	} else {
+		notices = st.Notices(filter)
+	}
+
+	if notices == nil {
+		notices = []*state.Notice{}
+	}
+	return SyncResponse(notices)
+}
+
+func noticeTypesFromQuery(query url.Values) ([]state.NoticeType, error) {
+	var types []state.NoticeType
+	for _, typeStr := range strutil.CommaSeparatedList(query.Get("types")) {
+		noticeType := state.NoticeType(typeStr)
+		switch noticeType {
+		case state.ChangeUpdateNotice, state.RefreshInhibitNotice, state.SnapPublishedNotice, state.SnapNotifyNotice:
+		default:
+			return nil, fmt.Errorf("invalid notice type %q", typeStr)
+		}
+		types = append(types, noticeType)
+	}
+	return types, nil
+}
+
+func getNotice(c *Command, r *http.Request, _ *auth.UserState) Response {
+	filter, rsp := noticeFilterForRequest(r)
+	if rsp != nil {
+		return rsp
+	}
+
+	noticeID := muxVars(r)["id"]
+
+	st := c.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+
+	notice := st.Notice(noticeID)
+	if notice == nil {
+		return NotFound("cannot find notice with ID %q", noticeID)
+	}
+	if filter.UserID != nil {
+		if uid, ok := notice.UserID(); ok && uid != *filter.UserID {
+			return NotFound("cannot find notice with ID %q", noticeID)
+		}
+	}
+	return SyncResponse(notice)
+}
@@ -0,0 +1,212 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package daemon_test
+
+import (
+	"net/http"
+	"net/url"
+	"time"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/daemon"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+type noticesSuite struct {
+	apiBaseSuite
+}
+
+var _ = check.Suite(&noticesSuite{})
+
+func (s *noticesSuite) SetUpTest(c *check.C) {
+	s.apiBaseSuite.SetUpTest(c)
+
+	s.expectReadAccess(daemon.OpenAccess{})
+}
+
+func (s *noticesSuite) addNotices(c *check.C) {
+	st := s.d.Overlord().State()
+	st.Lock()
+	defer st.Unlock()
+
+	uid := uint32(1000)
+	otherUID := uint32(1001)
+	_, err := st.AddNotice(nil, state.SnapPublishedNotice, "snap-a", &state.AddNoticeOptions{
+		Data: map[string]string{"revision": "2"},
+	})
+	c.Assert(err, check.IsNil)
+	_, err = st.AddNotice(&uid, state.SnapNotifyNotice, "snap-a/foo", nil)
+	c.Assert(err, check.IsNil)
+	_, err = st.AddNotice(&otherUID, state.SnapNotifyNotice, "snap-a/foo", nil)
+	c.Assert(err, check.IsNil)
+	_, err = st.AddNotice(nil, state.RefreshInhibitNotice, "snap-b", nil)
+	c.Assert(err, check.IsNil)
+}
+
+func noticeKeys(c *check.C, result interface{}) []string {
+	notices, ok := result.([]*state.Notice)
+	c.Assert(ok, check.Equals, true)
+	keys := make([]string, 0, len(notices))
+	for _, n := range notices {
+		keys = append(keys, n.String())
+	}
+	return keys
+}
+
+func (s *noticesSuite) TestGetNoticesAsRoot(c *check.C) {
+	s.daemon(c)
+	s.addNotices(c)
+
+	req, err := http.NewRequest("GET", "/v2/notices", nil)
+	c.Assert(err, check.IsNil)
+	rsp := s.syncReq(c, asUID(req, 0), nil)
+	c.Check(rsp.Status, check.Equals, 200)
+	c.Check(noticeKeys(c, rsp.Result), check.DeepEquals, []string{
+		"Notice 1 (public:snap-published:snap-a)",
+		"Notice 2 (1000:snap-notify:snap-a/foo)",
+		"Notice 3 (1001:snap-notify:snap-a/foo)",
+		"Notice 4 (public:refresh-inhibit:snap-b)",
+	})
+}
+
+func (s *noticesSuite) TestGetNoticesAsUser(c *check.C) {
+	s.daemon(c)
+	s.addNotices(c)
+
+	req, err := http.NewRequest("GET", "/v2/notices", nil)
+	c.Assert(err, check.IsNil)
+	rsp := s.syncReq(c, asUID(req, 1000), nil)
+	c.Check(rsp.Status, check.Equals, 200)
+	c.Check(noticeKeys(c, rsp.Result), check.DeepEquals, []string{
+		"Notice 1 (public:snap-published:snap-a)",
+		"Notice 2 (1000:snap-notify:snap-a/foo)",
+		"Notice 4 (public:refresh-inhibit:snap-b)",
+	})
+}
+
+func (s *noticesSuite) TestGetNoticesFilters(c *check.C) {
+	s.daemon(c)
+	s.addNotices(c)
+
+	st := s.d.Overlord().State()
+	st.Lock()
+	after := st.Notice("2").LastRepeated()
+	st.Unlock()
+
+	for _, t := range []struct {
+		query    url.Values
+		expected []string
+	}{{
+		query:    url.Values{"types": {"snap-published,refresh-inhibit"}},
+		expected: []string{"Notice 1 (public:snap-published:snap-a)", "Notice 4 (public:refresh-inhibit:snap-b)"},
+	}, {
+		query:    url.Values{"keys": {"snap-b"}},
+		expected: []string{"Notice 4 (public:refresh-inhibit:snap-b)"},
+	}, {
+		query:    url.Values{"after": {after.Format(time.RFC3339Nano)}},
+		expected: []string{"Notice 3 (1001:snap-notify:snap-a/foo)", "Notice 4 (public:refresh-inhibit:snap-b)"},
+	}, {
+		query:    url.Values{"types": {"change-update"}},
+		expected: []string{},
+	}} {
+		req, err := http.NewRequest("GET", "/v2/notices?"+t.query.Encode(), nil)
+		c.Assert(err, check.IsNil)
+		rsp := s.syncReq(c, asUID(req, 0), nil)
+		c.Check(rsp.Status, check.Equals, 200)
+		c.Check(noticeKeys(c, rsp.Result), check.DeepEquals, t.expected, check.Commentf("%v", t.query))
+	}
+}
+
+func (s *noticesSuite) TestGetNoticesInvalidParams(c *check.C) {
+	s.daemon(c)
+
+	for _, t := range []struct {
+		query string
+		err   string
+	}{
+		{"types=foo", `invalid notice type "foo"`},
+		{"after=yesterday", `invalid after timestamp "yesterday": .*`},
+		{"timeout=forever", `invalid timeout "forever"`},
+		{"timeout=-1s", `invalid timeout "-1s"`},
+	} {
+		req, err := http.NewRequest("GET", "/v2/notices?"+t.query, nil)
+		c.Assert(err, check.IsNil)
+		rspe := s.errorReq(c, asUID(req, 0), nil)
+		c.Check(rspe.Status, check.Equals, 400)
+		c.Check(rspe.Message, check.Matches, t.err)
+	}
+}
+
+func (s *noticesSuite) TestGetNoticesWait(c *check.C) {
+	s.daemon(c)
+
+	st := s.d.Overlord().State()
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		st.Lock()
+		defer st.Unlock()
+		_, err := st.AddNotice(nil, state.SnapPublishedNotice, "snap-a", nil)
+		c.Check(err, check.IsNil)
+	}()
+
+	req, err := http.NewRequest("GET", "/v2/notices?timeout=5s&keys=snap-a", nil)
+	c.Assert(err, check.IsNil)
+	rsp := s.syncReq(c, asUID(req, 0), nil)
+	c.Check(rsp.Status, check.Equals, 200)
+	c.Check(noticeKeys(c, rsp.Result), check.DeepEquals, []string{
+		"Notice 1 (public:snap-published:snap-a)",
+	})
+}
+
+func (s *noticesSuite) TestGetNoticesWaitTimeout(c *check.C) {
+	s.daemon(c)
+
+	req, err := http.NewRequest("GET", "/v2/notices?timeout=10ms", nil)
+	c.Assert(err, check.IsNil)
+	rsp := s.syncReq(c, asUID(req, 0), nil)
+	c.Check(rsp.Status, check.Equals, 200)
+	c.Check(noticeKeys(c, rsp.Result), check.DeepEquals, []string{})
+}
+
+func (s *noticesSuite) TestGetNotice(c *check.C) {
+	s.daemon(c)
+	s.addNotices(c)
+
+	req, err := http.NewRequest("GET", "/v2/notices/2", nil)
+	c.Assert(err, check.IsNil)
+	rsp := s.syncReq(c, asUID(req, 1000), nil)
+	c.Check(rsp.Status, check.Equals, 200)
+	notice, ok := rsp.Result.(*state.Notice)
+	c.Assert(ok, check.Equals, true)
+	c.Check(notice.String(), check.Equals, "Notice 2 (1000:snap-notify:snap-a/foo)")
+
+	// other users cannot see it
+	req, err = http.NewRequest("GET", "/v2/notices/2", nil)
+	c.Assert(err, check.IsNil)
+	rspe := s.errorReq(c, asUID(req, 1001), nil)
+	c.Check(rspe.Status, check.Equals, 404)
+	c.Check(rspe.Message, check.Equals, `cannot find notice with ID "2"`)
+
+	req, err = http.NewRequest("GET", "/v2/notices/42", nil)
+	c.Assert(err, check.IsNil)
+	rspe = s.errorReq(c, asUID(req, 0), nil)
+	c.Check(rspe.Status, check.Equals, 404)
+}
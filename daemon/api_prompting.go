@@ -0,0 +1,272 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package daemon
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"path/filepath"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/interfaces/prompting"
+	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/overlord/promptingstate"
+	"github.com/snapcore/snapd/snap/naming"
+)
+
+var (
+	promptingRequestsCmd = &Command{
+		Path: "/v2/prompting/requests",
+		GET:  getPromptingRequests,
+		// requests are forwarded by the prompting listener
+		POST:        postPromptingRequest,
+		ReadAccess:  openAccess{},
+		WriteAccess: rootAccess{},
+	}
+
+	promptingRequestCmd = &Command{
+		Path:        "/v2/prompting/requests/{id}",
+		GET:         getPromptingRequest,
+		POST:        postPromptingReply,
+		ReadAccess:  openAccess{},
+		WriteAccess: openAccess{},
+	}
+
+	promptingRulesCmd = &Command{
+		Path:        "/v2/prompting/rules",
+		GET:         getPromptingRules,
+		POST:        postPromptingRules,
+		ReadAccess:  openAccess{},
+		WriteAccess: openAccess{},
+	}
+
+	promptingRuleCmd = &Command{
+		Path:        "/v2/prompting/rules/{id}",
+		GET:         getPromptingRule,
+		POST:        postPromptingRule,
+		ReadAccess:  openAccess{},
+		WriteAccess: openAccess{},
+	}
+)
+
+// promptingInterface is the interface through which the file accesses
+// forwarded by the listener are prompted for.
+const promptingInterface = "home"
+
+// requestUID returns the user ID of the sender of the request, which
+// requests and rules are bound to.
+func requestUID(r *http.Request) (uint32, Response) {
+	ucred, err := ucrednetGet(r.RemoteAddr)
+	if err != nil {
+		return 0, Forbidden("cannot get remote user: %v", err)
+	}
+	return ucred.Uid, nil
+}
+
+func getPromptingRequests(c *Command, r *http.Request, _ *auth.UserState) Response {
+	uid, rsp := requestUID(r)
+	if rsp != nil {
+		return rsp
+	}
+
+	reqs := c.d.overlord.PromptingManager().Requests(uid)
+	if reqs == nil {
+		reqs = []*promptingstate.Request{}
+	}
+	return SyncResponse(reqs)
+}
+
+func getPromptingRequest(c *Command, r *http.Request, _ *auth.UserState) Response {
+	uid, rsp := requestUID(r)
+	if rsp != nil {
+		return rsp
+	}
+
+	req, err := c.d.overlord.PromptingManager().RequestWithID(uid, muxVars(r)["id"])
+	if err != nil {
+		return promptingErrorToResponse(err)
+	}
+	return SyncResponse(req)
+}
+
+type kernelRequest struct {
+	Pid         uint32   `json:"pid"`
+	Label       string   `json:"label"`
+	SubjectUID  uint32   `json:"subject-uid"`
+	Path        string   `json:"path"`
+	Permissions []string `json:"permissions"`
+}
+
+func postPromptingRequest(c *Command, r *http.Request, _ *auth.UserState) Response {
+	var kreq kernelRequest
+	if err := json.NewDecoder(r.Body).Decode(&kreq); err != nil {
+		return BadRequest("cannot decode prompting request: %v", err)
+	}
+
+	tag, err := naming.ParseSecurityTag(kreq.Label)
+	if err != nil {
+		return BadRequest("cannot handle prompting request for label %q: %v", kreq.Label, err)
+	}
+	var app string
+	switch t := tag.(type) {
+	case naming.AppSecurityTag:
+		app = t.AppName()
+	case naming.HookSecurityTag:
+		app = "hook." + t.HookName()
+	}
+
+	if !filepath.IsAbs(kreq.Path) {
+		return BadRequest("cannot handle prompting request for relative path %q", kreq.Path)
+	}
+	if err := prompting.ValidatePermissions(kreq.Permissions); err != nil {
+		return BadRequest("cannot handle prompting request: %v", err)
+	}
+
+	// this blocks until the user replies, or the listener goes away
+	allow, err := c.d.overlord.PromptingManager().HandleRequest(r.Context(), kreq.SubjectUID, tag.InstanceName(), app, promptingInterface, kreq.Path, kreq.Permissions)
+	if err != nil {
+		return InternalError("cannot handle prompting request: %v", err)
+	}
+
+	outcome := prompting.OutcomeDeny
+	if allow {
+		outcome = prompting.OutcomeAllow
+	}
+	return SyncResponse(map[string]interface{}{"outcome": outcome})
+}
+
+func postPromptingReply(c *Command, r *http.Request, _ *auth.UserState) Response {
+	uid, rsp := requestUID(r)
+	if rsp != nil {
+		return rsp
+	}
+
+	var reply promptingstate.Reply
+	if err := json.NewDecoder(r.Body).Decode(&reply); err != nil {
+		return BadRequest("cannot decode prompting reply: %v", err)
+	}
+
+	rule, err := c.d.overlord.PromptingManager().Reply(uid, muxVars(r)["id"], &reply)
+	if err != nil {
+		return promptingErrorToResponse(err)
+	}
+	return SyncResponse(rule)
+}
+
+func getPromptingRules(c *Command, r *http.Request, _ *auth.UserState) Response {
+	uid, rsp := requestUID(r)
+	if rsp != nil {
+		return rsp
+	}
+
+	query := r.URL.Query()
+	rules := c.d.overlord.PromptingManager().Rules(uid, query.Get("snap"), query.Get("interface"))
+	if rules == nil {
+		rules = []*promptingstate.Rule{}
+	}
+	return SyncResponse(rules)
+}
+
+type promptingRulesAction struct {
+	Action    string `json:"action"`
+	Snap      string `json:"snap"`
+	Interface string `json:"interface"`
+}
+
+func postPromptingRules(c *Command, r *http.Request, _ *auth.UserState) Response {
+	uid, rsp := requestUID(r)
+	if rsp != nil {
+		return rsp
+	}
+
+	var action promptingRulesAction
+	if err := json.NewDecoder(r.Body).Decode(&action); err != nil {
+		return BadRequest("cannot decode prompting rules action: %v", err)
+	}
+	if action.Action != "remove" {
+		return BadRequest("unsupported prompting rules action %q", action.Action)
+	}
+
+	removed, err := c.d.overlord.PromptingManager().RemoveRules(uid, action.Snap, action.Interface)
+	if err != nil {
+		return promptingErrorToResponse(err)
+	}
+	if removed == nil {
+		removed = []*promptingstate.Rule{}
+	}
+	return SyncResponse(removed)
+}
+
+func getPromptingRule(c *Command, r *http.Request, _ *auth.UserState) Response {
+	uid, rsp := requestUID(r)
+	if rsp != nil {
+		return rsp
+	}
+
+	rule, err := c.d.overlord.PromptingManager().RuleWithID(uid, muxVars(r)["id"])
+	if err != nil {
+		return promptingErrorToResponse(err)
+	}
+	return SyncResponse(rule)
+}
+
+type promptingRuleAction struct {
+	Action string `json:"action"`
+}
+
+func postPromptingRule(c *Command, r *http.Request, _ *auth.UserState) Response {
+	uid, rsp := requestUID(r)
+	if rsp != nil {
+		return rsp
+	}
+
+	var action promptingRuleAction
+	if err := json.NewDecoder(r.Body).Decode(&action); err != nil {
+		return BadRequest("cannot decode prompting rule action: %v", err)
+	}
+	if action.Action != "remove" {
+		return BadRequest("unsupported prompting rule action %q", action.Action)
+	}
+
+	rule, err := c.d.overlord.PromptingManager().RemoveRule(uid, muxVars(r)["id"])
+	if err != nil {
+		return promptingErrorToResponse(err)
+	}
+	return SyncResponse(rule)
+}
+
+func promptingErrorToResponse(err error) Response {
+	var conflErr *promptingstate.RuleConflictError
+	switch {
+	case errors.Is(err, promptingstate.ErrRequestNotFound), errors.Is(err, promptingstate.ErrRuleNotFound):
+		return NotFound(err.Error())
+	case errors.As(err, &conflErr):
+		return &apiError{
+			Status:  409,
+			Message: err.Error(),
+			Kind:    client.ErrorKindPromptingRuleConflict,
+			Value: map[string]interface{}{
+				"conflicts": conflErr.Conflicts,
+			},
+		}
+	}
+	return BadRequest(err.Error())
+}
@@ -0,0 +1,397 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package daemon_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/daemon"
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/interfaces/prompting"
+	"github.com/snapcore/snapd/overlord/promptingstate"
+)
+
+type promptingSuite struct {
+	apiBaseSuite
+
+	mgr *promptingstate.PromptingManager
+}
+
+var _ = check.Suite(&promptingSuite{})
+
+func (s *promptingSuite) SetUpTest(c *check.C) {
+	s.apiBaseSuite.SetUpTest(c)
+
+	d := s.daemonWithOverlordMock()
+	mgr, err := promptingstate.Manager(d.Overlord().State())
+	c.Assert(err, check.IsNil)
+	s.mgr = mgr
+	d.Overlord().AddManager(s.mgr)
+}
+
+func asUID(req *http.Request, uid int) *http.Request {
+	req.RemoteAddr = fmt.Sprintf("pid=100;uid=%d;socket=%s;", uid, dirs.SnapdSocket)
+	return req
+}
+
+// pendingRequest adds a request from snap "foo" for the user and returns it
+// together with the channel delivering the decision.
+func (s *promptingSuite) pendingRequest(c *check.C, uid uint32, path string) (*promptingstate.Request, <-chan bool) {
+	allowCh := make(chan bool, 1)
+	go func() {
+		allow, err := s.mgr.HandleRequest(context.Background(), uid, "foo", "app", "home", path, []string{"read"})
+		c.Check(err, check.IsNil)
+		allowCh <- allow
+	}()
+
+	for i := 0; i < 500; i++ {
+		if reqs := s.mgr.Requests(uid); len(reqs) > 0 {
+			return reqs[len(reqs)-1], allowCh
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	c.Fatal("timeout waiting for the request")
+	return nil, nil
+}
+
+func (s *promptingSuite) TestGetRequests(c *check.C) {
+	s.expectReadAccess(daemon.OpenAccess{})
+
+	req, err := http.NewRequest("GET", "/v2/prompting/requests", nil)
+	c.Assert(err, check.IsNil)
+	rsp := s.syncReq(c, asUID(req, 1000), nil)
+	c.Check(rsp.Result, check.DeepEquals, []*promptingstate.Request{})
+
+	pending, _ := s.pendingRequest(c, 1000, "/home/test/file.txt")
+
+	rsp = s.syncReq(c, asUID(req, 1000), nil)
+	c.Check(rsp.Result, check.DeepEquals, []*promptingstate.Request{pending})
+
+	// other users don't see the request
+	rsp = s.syncReq(c, asUID(req, 1001), nil)
+	c.Check(rsp.Result, check.DeepEquals, []*promptingstate.Request{})
+}
+
+func (s *promptingSuite) TestGetRequest(c *check.C) {
+	s.expectReadAccess(daemon.OpenAccess{})
+
+	pending, _ := s.pendingRequest(c, 1000, "/home/test/file.txt")
+
+	req, err := http.NewRequest("GET", "/v2/prompting/requests/"+pending.ID, nil)
+	c.Assert(err, check.IsNil)
+	rsp := s.syncReq(c, asUID(req, 1000), nil)
+	c.Check(rsp.Result, check.Equals, pending)
+
+	rspe := s.errorReq(c, asUID(req, 1001), nil)
+	c.Check(rspe.Status, check.Equals, 404)
+	c.Check(rspe.Message, check.Equals, "no request with the given ID is pending")
+}
+
+func (s *promptingSuite) TestPostReply(c *check.C) {
+	s.expectWriteAccess(daemon.OpenAccess{})
+
+	pending, allowCh := s.pendingRequest(c, 1000, "/home/test/file.txt")
+
+	body := `{"outcome": "allow", "lifespan": "forever", "path-pattern": "/home/test/*"}`
+	req, err := http.NewRequest("POST", "/v2/prompting/requests/"+pending.ID, strings.NewReader(body))
+	c.Assert(err, check.IsNil)
+	rsp := s.syncReq(c, asUID(req, 1000), nil)
+
+	rule, ok := rsp.Result.(*promptingstate.Rule)
+	c.Assert(ok, check.Equals, true)
+	c.Check(rule.Snap, check.Equals, "foo")
+	c.Check(rule.PathPattern, check.Equals, "/home/test/*")
+	c.Check(rule.Permissions, check.DeepEquals, []string{"read"})
+
+	select {
+	case allow := <-allowCh:
+		c.Check(allow, check.Equals, true)
+	case <-time.After(5 * time.Second):
+		c.Fatal("timeout waiting for the decision")
+	}
+}
+
+func (s *promptingSuite) TestPostReplyErrors(c *check.C) {
+	s.expectWriteAccess(daemon.OpenAccess{})
+
+	pending, _ := s.pendingRequest(c, 1000, "/home/test/file.txt")
+
+	for _, t := range []struct {
+		uid    int
+		body   string
+		status int
+		err    string
+	}{
+		{1000, `{`, 400, `cannot decode prompting reply: .*`},
+		{1000, `{"outcome": "allow", "lifespan": "always"}`, 400, `invalid lifespan "always", .*`},
+		{1000, `{"outcome": "allow", "lifespan": "once", "path-pattern": "/etc/*"}`, 400, `path pattern "/etc/\*" does not match the requested path "/home/test/file.txt"`},
+		{1001, `{"outcome": "allow", "lifespan": "once"}`, 404, `no request with the given ID is pending`},
+	} {
+		req, err := http.NewRequest("POST", "/v2/prompting/requests/"+pending.ID, strings.NewReader(t.body))
+		c.Assert(err, check.IsNil)
+		rspe := s.errorReq(c, asUID(req, t.uid), nil)
+		c.Check(rspe.Status, check.Equals, t.status)
+		c.Check(rspe.Message, check.Matches, t.err)
+	}
+}
+
+func (s *promptingSuite) TestPostRequestDecidedByRule(c *check.C) {
+	s.expectWriteAccess(daemon.RootAccess{})
+
+	pending, _ := s.pendingRequest(c, 1000, "/home/test/file.txt")
+	_, err := s.mgr.Reply(1000, pending.ID, &promptingstate.Reply{Outcome: "deny", Lifespan: "session", PathPattern: "/home/test/**"})
+	c.Assert(err, check.IsNil)
+
+	body, err := json.Marshal(map[string]interface{}{
+		"pid":         1234,
+		"label":       "snap.foo.app",
+		"subject-uid": 1000,
+		"path":        "/home/test/other.txt",
+		"permissions": []string{"read"},
+	})
+	c.Assert(err, check.IsNil)
+	req, err := http.NewRequest("POST", "/v2/prompting/requests", bytes.NewReader(body))
+	c.Assert(err, check.IsNil)
+	rsp := s.syncReq(c, asUID(req, 0), nil)
+	c.Check(rsp.Result, check.DeepEquals, map[string]interface{}{"outcome": prompting.OutcomeDeny})
+}
+
+func (s *promptingSuite) TestPostRequestPrompts(c *check.C) {
+	s.expectWriteAccess(daemon.RootAccess{})
+
+	body := `{"label": "snap.foo.hook.configure", "subject-uid": 1000, "path": "/home/test/file.txt", "permissions": ["read", "write"]}`
+	req, err := http.NewRequest("POST", "/v2/prompting/requests", strings.NewReader(body))
+	c.Assert(err, check.IsNil)
+
+	rspCh := make(chan daemon.Response, 1)
+	go func() {
+		rspCh <- s.req(c, asUID(req, 0), nil)
+	}()
+
+	var pending *promptingstate.Request
+	for i := 0; i < 500 && pending == nil; i++ {
+		if reqs := s.mgr.Requests(1000); len(reqs) > 0 {
+			pending = reqs[0]
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	c.Assert(pending, check.NotNil)
+	c.Check(pending.Snap, check.Equals, "foo")
+	c.Check(pending.App, check.Equals, "hook.configure")
+	c.Check(pending.Interface, check.Equals, "home")
+	c.Check(pending.Permissions, check.DeepEquals, []string{"read", "write"})
+
+	_, err = s.mgr.Reply(1000, pending.ID, &promptingstate.Reply{Outcome: "allow", Lifespan: "once"})
+	c.Assert(err, check.IsNil)
+
+	select {
+	case rsp := <-rspCh:
+		c.Check(rsp.(daemon.StructuredResponse).JSON().Result, check.DeepEquals, map[string]interface{}{"outcome": prompting.OutcomeAllow})
+	case <-time.After(5 * time.Second):
+		c.Fatal("timeout waiting for the response")
+	}
+}
+
+func (s *promptingSuite) TestPostRequestCancelled(c *check.C) {
+	s.expectWriteAccess(daemon.RootAccess{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	body := `{"label": "snap.foo.app", "subject-uid": 1000, "path": "/home/test/file.txt", "permissions": ["read"]}`
+	req, err := http.NewRequestWithContext(ctx, "POST", "/v2/prompting/requests", strings.NewReader(body))
+	c.Assert(err, check.IsNil)
+	rspe := s.errorReq(c, asUID(req, 0), nil)
+	c.Check(rspe.Status, check.Equals, 500)
+	c.Check(rspe.Message, check.Equals, "cannot handle prompting request: context canceled")
+	c.Check(s.mgr.Requests(1000), check.HasLen, 0)
+}
+
+func (s *promptingSuite) TestPostRequestErrors(c *check.C) {
+	s.expectWriteAccess(daemon.RootAccess{})
+
+	for _, t := range []struct {
+		body string
+		err  string
+	}{
+		{`{`, `cannot decode prompting request: .*`},
+		{`{"label": "unconfined", "path": "/foo", "permissions": ["read"]}`, `cannot handle prompting request for label "unconfined": invalid security tag`},
+		{`{"label": "snap.foo.app", "path": "foo", "permissions": ["read"]}`, `cannot handle prompting request for relative path "foo"`},
+		{`{"label": "snap.foo.app", "path": "/foo", "permissions": []}`, `cannot handle prompting request: permissions must not be empty`},
+	} {
+		req, err := http.NewRequest("POST", "/v2/prompting/requests", strings.NewReader(t.body))
+		c.Assert(err, check.IsNil)
+		rspe := s.errorReq(c, asUID(req, 0), nil)
+		c.Check(rspe.Status, check.Equals, 400)
+		c.Check(rspe.Message, check.Matches, t.err)
+	}
+}
+
+func (s *promptingSuite) TestGetRules(c *check.C) {
+	s.expectReadAccess(daemon.OpenAccess{})
+
+	req, err := http.NewRequest("GET", "/v2/prompting/rules", nil)
+	c.Assert(err, check.IsNil)
+	rsp := s.syncReq(c, asUID(req, 1000), nil)
+	c.Check(rsp.Result, check.DeepEquals, []*promptingstate.Rule{})
+
+	pending, _ := s.pendingRequest(c, 1000, "/home/test/file.txt")
+	rule, err := s.mgr.Reply(1000, pending.ID, &promptingstate.Reply{Outcome: "allow", Lifespan: "forever"})
+	c.Assert(err, check.IsNil)
+
+	rsp = s.syncReq(c, asUID(req, 1000), nil)
+	c.Check(rsp.Result, check.DeepEquals, []*promptingstate.Rule{rule})
+
+	req, err = http.NewRequest("GET", "/v2/prompting/rules?snap=foo&interface=home", nil)
+	c.Assert(err, check.IsNil)
+	rsp = s.syncReq(c, asUID(req, 1000), nil)
+	c.Check(rsp.Result, check.DeepEquals, []*promptingstate.Rule{rule})
+
+	for _, query := range []string{"snap=bar", "interface=camera"} {
+		req, err = http.NewRequest("GET", "/v2/prompting/rules?"+query, nil)
+		c.Assert(err, check.IsNil)
+		rsp = s.syncReq(c, asUID(req, 1000), nil)
+		c.Check(rsp.Result, check.DeepEquals, []*promptingstate.Rule{})
+	}
+}
+
+func (s *promptingSuite) addRule(c *check.C, uid uint32, path string) *promptingstate.Rule {
+	pending, _ := s.pendingRequest(c, uid, path)
+	rule, err := s.mgr.Reply(uid, pending.ID, &promptingstate.Reply{Outcome: "allow", Lifespan: "forever"})
+	c.Assert(err, check.IsNil)
+	return rule
+}
+
+func (s *promptingSuite) TestGetRule(c *check.C) {
+	s.expectReadAccess(daemon.OpenAccess{})
+
+	rule := s.addRule(c, 1000, "/home/test/file.txt")
+
+	req, err := http.NewRequest("GET", "/v2/prompting/rules/"+rule.ID, nil)
+	c.Assert(err, check.IsNil)
+	rsp := s.syncReq(c, asUID(req, 1000), nil)
+	c.Check(rsp.Result, check.DeepEquals, rule)
+
+	rspe := s.errorReq(c, asUID(req, 1001), nil)
+	c.Check(rspe.Status, check.Equals, 404)
+	c.Check(rspe.Message, check.Equals, "no rule with the given ID exists")
+}
+
+func (s *promptingSuite) TestPostRuleRemove(c *check.C) {
+	s.expectWriteAccess(daemon.OpenAccess{})
+
+	rule := s.addRule(c, 1000, "/home/test/file.txt")
+
+	body := `{"action": "remove"}`
+	req, err := http.NewRequest("POST", "/v2/prompting/rules/"+rule.ID, strings.NewReader(body))
+	c.Assert(err, check.IsNil)
+	rspe := s.errorReq(c, asUID(req, 1001), nil)
+	c.Check(rspe.Status, check.Equals, 404)
+
+	req, err = http.NewRequest("POST", "/v2/prompting/rules/"+rule.ID, strings.NewReader(body))
+	c.Assert(err, check.IsNil)
+	rsp := s.syncReq(c, asUID(req, 1000), nil)
+	c.Check(rsp.Result, check.DeepEquals, rule)
+	c.Check(s.mgr.Rules(1000, "", ""), check.HasLen, 0)
+
+	for _, t := range []struct {
+		body string
+		err  string
+	}{
+		{`{`, `cannot decode prompting rule action: .*`},
+		{`{"action": "edit"}`, `unsupported prompting rule action "edit"`},
+	} {
+		req, err := http.NewRequest("POST", "/v2/prompting/rules/"+rule.ID, strings.NewReader(t.body))
+		c.Assert(err, check.IsNil)
+		rspe := s.errorReq(c, asUID(req, 1000), nil)
+		c.Check(rspe.Status, check.Equals, 400)
+		c.Check(rspe.Message, check.Matches, t.err)
+	}
+}
+
+func (s *promptingSuite) TestPostRulesRemove(c *check.C) {
+	s.expectWriteAccess(daemon.OpenAccess{})
+
+	rule1 := s.addRule(c, 1000, "/home/test/a")
+	rule2 := s.addRule(c, 1000, "/home/test/b")
+	rule3 := s.addRule(c, 1001, "/home/other/a")
+
+	req, err := http.NewRequest("POST", "/v2/prompting/rules", strings.NewReader(`{"action": "remove", "snap": "foo", "interface": "home"}`))
+	c.Assert(err, check.IsNil)
+	rsp := s.syncReq(c, asUID(req, 1000), nil)
+	c.Check(rsp.Result, check.DeepEquals, []*promptingstate.Rule{rule1, rule2})
+	c.Check(s.mgr.Rules(1000, "", ""), check.HasLen, 0)
+	c.Check(s.mgr.Rules(1001, "", ""), check.DeepEquals, []*promptingstate.Rule{rule3})
+
+	req, err = http.NewRequest("POST", "/v2/prompting/rules", strings.NewReader(`{"action": "remove", "snap": "foo"}`))
+	c.Assert(err, check.IsNil)
+	rsp = s.syncReq(c, asUID(req, 1000), nil)
+	c.Check(rsp.Result, check.DeepEquals, []*promptingstate.Rule{})
+
+	for _, t := range []struct {
+		body string
+		err  string
+	}{
+		{`{`, `cannot decode prompting rules action: .*`},
+		{`{"action": "edit", "snap": "foo"}`, `unsupported prompting rules action "edit"`},
+		{`{"action": "remove"}`, `snap must be given to remove rules`},
+	} {
+		req, err := http.NewRequest("POST", "/v2/prompting/rules", strings.NewReader(t.body))
+		c.Assert(err, check.IsNil)
+		rspe := s.errorReq(c, asUID(req, 1000), nil)
+		c.Check(rspe.Status, check.Equals, 400)
+		c.Check(rspe.Message, check.Matches, t.err)
+	}
+}
+
+func (s *promptingSuite) TestPostReplyConflict(c *check.C) {
+	s.expectWriteAccess(daemon.OpenAccess{})
+
+	rule := s.addRule(c, 1000, "/home/test/file.txt")
+	pending, _ := s.pendingRequest(c, 1000, "/home/test/other.txt")
+
+	body := `{"outcome": "deny", "lifespan": "forever", "path-pattern": "/home/test/*.txt"}`
+	req, err := http.NewRequest("POST", "/v2/prompting/requests/"+pending.ID, strings.NewReader(body))
+	c.Assert(err, check.IsNil)
+	rsp := s.syncReq(c, asUID(req, 1000), nil)
+	c.Assert(rsp.Result, check.FitsTypeOf, &promptingstate.Rule{})
+
+	pending, _ = s.pendingRequest(c, 1000, "/home/test/other.pdf")
+	body = `{"outcome": "allow", "lifespan": "forever", "path-pattern": "/home/test/othe*"}`
+	req, err = http.NewRequest("POST", "/v2/prompting/requests/"+pending.ID, strings.NewReader(body))
+	c.Assert(err, check.IsNil)
+	rspe := s.errorReq(c, asUID(req, 1000), nil)
+	c.Check(rspe.Status, check.Equals, 409)
+	c.Check(rspe.Kind, check.Equals, client.ErrorKindPromptingRuleConflict)
+	c.Check(rspe.Message, check.Equals, `cannot add rule: conflicts with existing rules on "read" with rule 0000000000000002`)
+	c.Check(rspe.Value, check.DeepEquals, map[string]interface{}{
+		"conflicts": []promptingstate.RuleConflict{{Permission: "read", ConflictingID: "0000000000000002"}},
+	})
+	c.Check(rule.ID, check.Equals, "0000000000000001")
+}
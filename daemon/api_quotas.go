@@ -82,6 +82,14 @@ var getQuotaUsage = func(grp *quota.Group) (*client.QuotaValues, error) {
 		currentUsage.Threads = threads
 	}
 
+	if grp.DiskLimit != nil {
+		disk, err := grp.CurrentDiskUsage()
+		if err != nil {
+			return nil, err
+		}
+		currentUsage.Disk = &client.QuotaDiskValues{Size: disk}
+	}
+
 	return &currentUsage, nil
 }
 
@@ -110,6 +118,12 @@ func createQuotaValues(grp *quota.Group) *client.QuotaValues {
 			}
 		}
 	}
+	if grp.DiskLimit != nil {
+		constraints.Disk = &client.QuotaDiskValues{
+			Size:     grp.DiskLimit.Size,
+			UserData: grp.DiskLimit.UserData,
+		}
+	}
 	return &constraints
 }
 
@@ -219,6 +233,12 @@ func quotaValuesToResources(values client.QuotaValues) quota.Resources {
 			resourcesBuilder.WithJournalRate(values.Journal.RateCount, values.Journal.RatePeriod)
 		}
 	}
+	if values.Disk != nil {
+		resourcesBuilder.WithDiskLimit(values.Disk.Size)
+		if values.Disk.UserData {
+			resourcesBuilder.WithDiskUserData()
+		}
+	}
 	return resourcesBuilder.Build()
 }
 
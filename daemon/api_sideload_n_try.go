@@ -29,6 +29,7 @@ import (
 	"mime/multipart"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/snapcore/snapd/asserts"
 	"github.com/snapcore/snapd/asserts/snapasserts"
@@ -193,6 +194,8 @@ func sideloadOrTrySnap(c *Command, body io.ReadCloser, boundary string, user *au
 	var chg *state.Change
 	if len(snapFiles) > 1 {
 		chg, errRsp = sideloadManySnaps(st, snapFiles, sideloadFlags, user)
+	} else if isComponentFile(snapFiles[0].filename) {
+		chg, errRsp = sideloadComponent(st, snapFiles[0], sideloadFlags)
 	} else {
 		chg, errRsp = sideloadSnap(st, snapFiles[0], sideloadFlags)
 	}
@@ -295,6 +298,53 @@ func sideloadSnap(st *state.State, snapFile *uploadedSnap, flags sideloadFlags)
 	return chg, nil
 }
 
+// isComponentFile returns whether the uploaded file is a component, which
+// is recognized by its extension.
+func isComponentFile(filename string) bool {
+	return strings.HasSuffix(filename, ".comp")
+}
+
+func sideloadComponent(st *state.State, compFile *uploadedSnap, flags sideloadFlags) (*state.Change, *apiError) {
+	// there are no assertions for components yet
+	if !flags.dangerousOK {
+		return nil, BadRequest("cannot install component file %q: only dangerous installs are supported", compFile.filename)
+	}
+
+	compInfo, err := unsafeReadComponentInfo(compFile.tmpPath)
+	if err != nil {
+		return nil, BadRequest("cannot read component file: %v", err)
+	}
+
+	instanceName := compInfo.Component.SnapName
+	if compFile.instanceName != "" {
+		// caller has specified the instance of the snap
+		if err := snap.ValidateInstanceName(compFile.instanceName); err != nil {
+			return nil, BadRequest(err.Error())
+		}
+		if snap.InstanceSnap(compFile.instanceName) != compInfo.Component.SnapName {
+			return nil, BadRequest("instance name %q does not match the snap name %q of component %q", compFile.instanceName, compInfo.Component.SnapName, compInfo.FullName())
+		}
+		instanceName = compFile.instanceName
+	}
+
+	info, err := snapstate.CurrentInfo(st, instanceName)
+	if err != nil {
+		return nil, errToResponse(err, []string{instanceName}, InternalError, "cannot install component file: %v")
+	}
+
+	csi := snap.NewComponentSideInfo(compInfo.Component, snap.Revision{})
+	tset, err := snapstateInstallComponentPath(st, csi, info, compFile.tmpPath, flags.Flags)
+	if err != nil {
+		return nil, errToResponse(err, []string{instanceName}, InternalError, "cannot install component file: %v")
+	}
+
+	msg := fmt.Sprintf(i18n.G("Install %q component from file %q"), compInfo.FullName(), compFile.filename)
+	chg := newChange(st, "install-component", msg, []*state.TaskSet{tset}, []string{instanceName})
+	chg.Set("api-data", map[string]string{"snap-name": instanceName, "component-name": compInfo.Component.ComponentName})
+
+	return chg, nil
+}
+
 func readSideInfo(st *state.State, tempPath string, origPath string, flags sideloadFlags, model *asserts.Model) (*snap.SideInfo, *apiError) {
 	var sideInfo *snap.SideInfo
 
@@ -480,7 +530,10 @@ func trySnap(st *state.State, trydir string, flags snapstate.Flags) Response {
 	return AsyncResponse(nil, chg.ID())
 }
 
-var unsafeReadSnapInfo = unsafeReadSnapInfoImpl
+var (
+	unsafeReadSnapInfo      = unsafeReadSnapInfoImpl
+	unsafeReadComponentInfo = unsafeReadComponentInfoImpl
+)
 
 func unsafeReadSnapInfoImpl(snapPath string) (*snap.Info, error) {
 	// Condider using DeriveSideInfo before falling back to this!
@@ -490,3 +543,11 @@ func unsafeReadSnapInfoImpl(snapPath string) (*snap.Info, error) {
 	}
 	return snap.ReadInfoFromSnapFile(snapf, nil)
 }
+
+func unsafeReadComponentInfoImpl(compPath string) (*snap.ComponentInfo, error) {
+	compf, err := snapfile.Open(compPath)
+	if err != nil {
+		return nil, err
+	}
+	return snap.ReadComponentInfoFromContainer(compf, nil, nil)
+}
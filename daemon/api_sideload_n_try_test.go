@@ -30,6 +30,7 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"strings"
 	"time"
 
 	"gopkg.in/check.v1"
@@ -45,6 +46,7 @@ import (
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/sandbox"
 	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/naming"
 	"github.com/snapcore/snapd/snap/snaptest"
 	"github.com/snapcore/snapd/strutil"
 	"github.com/snapcore/snapd/testutil"
@@ -1173,3 +1175,101 @@ func (s *sideloadSuite) TestSideloadSnapInvalidTransaction(c *check.C) {
 	rspe := s.errorReq(c, req, nil)
 	c.Assert(rspe.Message, check.Matches, `transaction must be either "per-snap" or "all-snaps"`)
 }
+
+var sideloadComponentBody = "" +
+	"----hello--\r\n" +
+	"Content-Disposition: form-data; name=\"snap\"; filename=\"x\"\r\n" +
+	"\r\n" +
+	"xyzzy\r\n" +
+	"----hello--\r\n" +
+	"Content-Disposition: form-data; name=\"dangerous\"\r\n" +
+	"\r\n" +
+	"true\r\n" +
+	"----hello--\r\n" +
+	"Content-Disposition: form-data; name=\"snap-path\"\r\n" +
+	"\r\n" +
+	"a/b/local+comp.comp\r\n" +
+	"----hello--\r\n"
+
+func (s *sideloadSuite) mockComponentFile(compName string) (restore func()) {
+	return daemon.MockUnsafeReadComponentInfo(func(path string) (*snap.ComponentInfo, error) {
+		return &snap.ComponentInfo{
+			Component: naming.NewComponentRef("local", compName),
+			Type:      snap.TestComponent,
+			Version:   "1.0",
+		}, nil
+	})
+}
+
+func (s *sideloadSuite) TestSideloadComponent(c *check.C) {
+	d := s.daemonWithFakeSnapManager(c)
+	s.markSeeded(d)
+	s.mkInstalledInState(c, d, "local", "", "1", snap.R(1), true, "components:\n  comp:\n    type: test\n")
+	defer s.mockComponentFile("comp")()
+
+	var installed bool
+	defer daemon.MockSnapstateInstallComponentPath(func(st *state.State, csi *snap.ComponentSideInfo, info *snap.Info, path string, flags snapstate.Flags) (*state.TaskSet, error) {
+		c.Check(csi, check.DeepEquals, snap.NewComponentSideInfo(naming.NewComponentRef("local", "comp"), snap.R(0)))
+		c.Check(info.InstanceName(), check.Equals, "local")
+		c.Check(info.Revision, check.Equals, snap.R(1))
+		c.Check(path, testutil.FileEquals, "xyzzy")
+		c.Check(flags, check.DeepEquals, snapstate.Flags{RemoveSnapPath: true, Transaction: client.TransactionPerSnap})
+		installed = true
+
+		t := st.NewTask("fake-install-component", "Doing a fake install")
+		return state.NewTaskSet(t), nil
+	})()
+
+	req, err := http.NewRequest("POST", "/v2/snaps", bytes.NewBufferString(sideloadComponentBody))
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", "multipart/thing; boundary=--hello--")
+
+	rsp := s.asyncReq(c, req, nil)
+	c.Check(installed, check.Equals, true)
+
+	st := d.Overlord().State()
+	st.Lock()
+	defer st.Unlock()
+	chg := st.Change(rsp.Change)
+	c.Assert(chg, check.NotNil)
+	c.Check(chg.Kind(), check.Equals, "install-component")
+	c.Check(chg.Summary(), check.Equals, `Install "local+comp" component from file "a/b/local+comp.comp"`)
+	var names []string
+	c.Assert(chg.Get("snap-names", &names), check.IsNil)
+	c.Check(names, check.DeepEquals, []string{"local"})
+	var apiData map[string]interface{}
+	c.Assert(chg.Get("api-data", &apiData), check.IsNil)
+	c.Check(apiData, check.DeepEquals, map[string]interface{}{
+		"snap-name":      "local",
+		"component-name": "comp",
+	})
+}
+
+func (s *sideloadSuite) TestSideloadComponentNotDangerous(c *check.C) {
+	d := s.daemonWithFakeSnapManager(c)
+	s.markSeeded(d)
+
+	body := strings.Replace(sideloadComponentBody, "\"dangerous\"", "\"devmode\"", 1)
+	req, err := http.NewRequest("POST", "/v2/snaps", bytes.NewBufferString(body))
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", "multipart/thing; boundary=--hello--")
+
+	rspe := s.errorReq(c, req, nil)
+	c.Check(rspe.Status, check.Equals, 400)
+	c.Check(rspe.Message, check.Equals, `cannot install component file "a/b/local+comp.comp": only dangerous installs are supported`)
+}
+
+func (s *sideloadSuite) TestSideloadComponentSnapNotInstalled(c *check.C) {
+	d := s.daemonWithFakeSnapManager(c)
+	s.markSeeded(d)
+	defer s.mockComponentFile("comp")()
+
+	req, err := http.NewRequest("POST", "/v2/snaps", bytes.NewBufferString(sideloadComponentBody))
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", "multipart/thing; boundary=--hello--")
+
+	rspe := s.errorReq(c, req, nil)
+	c.Check(rspe.Status, check.Equals, 400)
+	c.Check(rspe.Kind, check.Equals, client.ErrorKindSnapNotInstalled)
+	c.Check(rspe.Message, check.Equals, `snap "local" is not installed`)
+}
@@ -42,6 +42,7 @@ import (
 	"github.com/snapcore/snapd/sandbox"
 	"github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/snap/channel"
+	"github.com/snapcore/snapd/snap/naming"
 	"github.com/snapcore/snapd/strutil"
 )
 
@@ -208,6 +209,7 @@ type snapInstruction struct {
 	Snaps                  []string                         `json:"snaps"`
 	Users                  []string                         `json:"users"`
 	SnapshotOptions        map[string]*snap.SnapshotOptions `json:"snapshot-options"`
+	SnapshotKey            *client.SnapshotKey              `json:"snapshot-key"`
 	ValidationSets         []string                         `json:"validation-sets"`
 	QuotaGroupName         string                           `json:"quota-group"`
 	Time                   string                           `json:"time"`
@@ -279,6 +281,23 @@ func (inst *snapInstruction) cleanSnapshotOptions() {
 	}
 }
 
+func (inst *snapInstruction) validateSnapshotKey() error {
+	if inst.SnapshotKey == nil {
+		return nil
+	}
+	if inst.Action != "snapshot" {
+		return fmt.Errorf("snapshot-key can only be specified for snapshot action")
+	}
+	key, err := snapshotEncryptionKey(inst.SnapshotKey)
+	if err != nil {
+		return err
+	}
+	if len(key.PrivateKey) != 0 {
+		return fmt.Errorf("cannot encrypt snapshot with a private key")
+	}
+	return nil
+}
+
 func (inst *snapInstruction) validateSnapshotOptions() error {
 	if inst.SnapshotOptions == nil {
 		return nil
@@ -358,6 +377,9 @@ func (inst *snapInstruction) validate() error {
 	if err := inst.validateSnapshotOptions(); err != nil {
 		return err
 	}
+	if err := inst.validateSnapshotKey(); err != nil {
+		return err
+	}
 
 	if inst.Action == "snapshot" {
 		inst.cleanSnapshotOptions()
@@ -402,6 +424,10 @@ func snapInstall(inst *snapInstruction, st *state.State) (string, []*state.TaskS
 		return "", nil, fmt.Errorf(i18n.G("cannot install snap with empty name"))
 	}
 
+	if strings.Contains(inst.Snaps[0], "+") {
+		return "", nil, fmt.Errorf(i18n.G("cannot install component %q: installing components from the store is not supported yet"), inst.Snaps[0])
+	}
+
 	flags, err := inst.installFlags()
 	if err != nil {
 		return "", nil, err
@@ -464,6 +490,10 @@ func snapUpdate(inst *snapInstruction, st *state.State) (string, []*state.TaskSe
 }
 
 func snapRemove(inst *snapInstruction, st *state.State) (string, []*state.TaskSet, error) {
+	if strings.Contains(inst.Snaps[0], "+") {
+		return componentRemove(inst, st)
+	}
+
 	ts, err := snapstate.Remove(st, inst.Snaps[0], inst.Revision, &snapstate.RemoveFlags{Purge: inst.Purge})
 	if err != nil {
 		return "", nil, err
@@ -473,6 +503,23 @@ func snapRemove(inst *snapInstruction, st *state.State) (string, []*state.TaskSe
 	return msg, []*state.TaskSet{ts}, nil
 }
 
+func componentRemove(inst *snapInstruction, st *state.State) (string, []*state.TaskSet, error) {
+	if !inst.Revision.Unset() {
+		return "", nil, errors.New("cannot remove a specific revision of a component")
+	}
+	instanceName, compName, err := naming.SplitFullComponentName(inst.Snaps[0])
+	if err != nil {
+		return "", nil, err
+	}
+	ts, err := snapstateRemoveComponent(st, instanceName, compName)
+	if err != nil {
+		return "", nil, err
+	}
+
+	msg := fmt.Sprintf(i18n.G("Remove %q component"), inst.Snaps[0])
+	return msg, []*state.TaskSet{ts}, nil
+}
+
 func snapRevert(inst *snapInstruction, st *state.State) (string, []*state.TaskSet, error) {
 	var ts *state.TaskSet
 
@@ -45,6 +45,7 @@ import (
 	"github.com/snapcore/snapd/overlord/assertstate"
 	"github.com/snapcore/snapd/overlord/auth"
 	"github.com/snapcore/snapd/overlord/healthstate"
+	"github.com/snapcore/snapd/overlord/snapshotstate"
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/sandbox"
@@ -529,7 +530,7 @@ func (s *snapsSuite) TestPostSnapsOptionsOtherErrors(c *check.C) {
 func (s *snapsSuite) TestPostSnapsOptionsClean(c *check.C) {
 	var snapshotSaveCalled int
 	defer daemon.MockSnapshotSave(func(s *state.State, snaps, users []string,
-		options map[string]*snap.SnapshotOptions) (uint64, []string, *state.TaskSet, error) {
+		options map[string]*snap.SnapshotOptions, key *snapshotstate.EncryptionKey) (uint64, []string, *state.TaskSet, error) {
 		snapshotSaveCalled++
 
 		c.Check(snaps, check.HasLen, 3)
@@ -3009,3 +3010,50 @@ func (s *snapsSuite) TestHoldAllSnapsGeneralRefreshesNotSupported(c *check.C) {
 	c.Check(rspe.Status, check.Equals, 400)
 	c.Assert(rspe.Error(), check.Matches, `cannot hold: holding general refreshes for all snaps is not supported.*`)
 }
+
+func (s *snapsSuite) TestPostSnapRemoveComponent(c *check.C) {
+	d := s.daemonWithOverlordMock()
+
+	var removed bool
+	defer daemon.MockSnapstateRemoveComponent(func(st *state.State, instanceName, compName string) (*state.TaskSet, error) {
+		c.Check(instanceName, check.Equals, "foo")
+		c.Check(compName, check.Equals, "comp")
+		removed = true
+		t := st.NewTask("fake-remove-component", "Doing a fake remove")
+		return state.NewTaskSet(t), nil
+	})()
+
+	buf := bytes.NewBufferString(`{"action": "remove"}`)
+	req, err := http.NewRequest("POST", "/v2/snaps/foo+comp", buf)
+	c.Assert(err, check.IsNil)
+
+	rsp := s.asyncReq(c, req, nil)
+	c.Check(removed, check.Equals, true)
+
+	st := d.Overlord().State()
+	st.Lock()
+	defer st.Unlock()
+	chg := st.Change(rsp.Change)
+	c.Assert(chg, check.NotNil)
+	c.Check(chg.Summary(), check.Equals, `Remove "foo+comp" component`)
+	c.Check(chg.Tasks()[0].Summary(), check.Equals, "Doing a fake remove")
+}
+
+func (s *snapsSuite) TestPostSnapRemoveComponentErrors(c *check.C) {
+	s.daemonWithOverlordMock()
+
+	for _, t := range []struct {
+		name, body, err string
+	}{
+		{"foo+comp", `{"action": "remove", "revision": "2"}`, `cannot remove a specific revision of a component`},
+		{"foo+comp+other", `{"action": "remove"}`, `incorrect component name "foo\+comp\+other"`},
+		{"foo+comp", `{"action": "install"}`, `cannot install component "foo\+comp": installing components from the store is not supported yet`},
+	} {
+		req, err := http.NewRequest("POST", "/v2/snaps/"+t.name, bytes.NewBufferString(t.body))
+		c.Assert(err, check.IsNil)
+
+		rspe := s.errorReq(c, req, nil)
+		c.Check(rspe.Status, check.Equals, 400)
+		c.Check(rspe.Message, check.Matches, ".*"+t.err)
+	}
+}
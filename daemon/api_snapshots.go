@@ -21,7 +21,9 @@ package daemon
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -52,13 +54,14 @@ var snapshotExportCmd = &Command{
 }
 
 var (
-	snapshotList    = snapshotstate.List
-	snapshotCheck   = snapshotstate.Check
-	snapshotForget  = snapshotstate.Forget
-	snapshotRestore = snapshotstate.Restore
-	snapshotSave    = snapshotstate.Save
-	snapshotExport  = snapshotstate.Export
-	snapshotImport  = snapshotstate.Import
+	snapshotList        = snapshotstate.List
+	snapshotCheck       = snapshotstate.Check
+	snapshotForget      = snapshotstate.Forget
+	snapshotRestore     = snapshotstate.Restore
+	snapshotRestoreInto = snapshotstate.RestoreInto
+	snapshotSave        = snapshotstate.Save
+	snapshotExport      = snapshotstate.Export
+	snapshotImport      = snapshotstate.Import
 )
 
 func listSnapshots(c *Command, r *http.Request, user *auth.UserState) Response {
@@ -85,23 +88,30 @@ func listSnapshots(c *Command, r *http.Request, user *auth.UserState) Response {
 // A snapshotAction is used to request an operation on a snapshot
 // keep this in sync with client/snapshotAction...
 type snapshotAction struct {
-	SetID  uint64   `json:"set"`
-	Action string   `json:"action"`
-	Snaps  []string `json:"snaps,omitempty"`
-	Users  []string `json:"users,omitempty"`
+	SetID  uint64              `json:"set"`
+	Action string              `json:"action"`
+	Snaps  []string            `json:"snaps,omitempty"`
+	Users  []string            `json:"users,omitempty"`
+	Key    *client.SnapshotKey `json:"key,omitempty"`
+	// Target is the instance to restore the data of a single snap into
+	Target string `json:"target,omitempty"`
 }
 
 func (action snapshotAction) String() string {
-	// verb of snapshot #N [for snaps %q] [for users %q]
+	// verb of snapshot #N [for snaps %q] [into %q] [for users %q]
 	var snaps string
+	var target string
 	var users string
 	if len(action.Snaps) > 0 {
 		snaps = " for snaps " + strutil.Quoted(action.Snaps)
 	}
+	if action.Target != "" {
+		target = fmt.Sprintf(" into %q", action.Target)
+	}
 	if len(action.Users) > 0 {
 		users = " for users " + strutil.Quoted(action.Users)
 	}
-	return fmt.Sprintf("%s of snapshot set #%d%s%s", strings.Title(action.Action), action.SetID, snaps, users)
+	return fmt.Sprintf("%s of snapshot set #%d%s%s%s", strings.Title(action.Action), action.SetID, snaps, target, users)
 }
 
 func changeSnapshots(c *Command, r *http.Request, user *auth.UserState) Response {
@@ -127,9 +137,22 @@ func changeSnapshots(c *Command, r *http.Request, user *auth.UserState) Response
 		return BadRequest("snapshot operation requires action")
 	}
 
+	if action.Target != "" {
+		if action.Action != "restore" {
+			return BadRequest("snapshot %q operation cannot specify a target", action.Action)
+		}
+		if len(action.Snaps) != 1 {
+			return BadRequest("snapshot restore into a target requires exactly one snap")
+		}
+	}
+
+	key, err := snapshotEncryptionKey(action.Key)
+	if err != nil {
+		return BadRequest("%v", err)
+	}
+
 	var affected []string
 	var ts *state.TaskSet
-	var err error
 
 	st := c.d.overlord.State()
 	st.Lock()
@@ -137,13 +160,21 @@ func changeSnapshots(c *Command, r *http.Request, user *auth.UserState) Response
 
 	switch action.Action {
 	case "check":
-		affected, ts, err = snapshotCheck(st, action.SetID, action.Snaps, action.Users)
+		affected, ts, err = snapshotCheck(st, action.SetID, action.Snaps, action.Users, key)
 	case "restore":
-		affected, ts, err = snapshotRestore(st, action.SetID, action.Snaps, action.Users)
+		if action.Target != "" {
+			ts, err = snapshotRestoreInto(st, action.SetID, action.Snaps[0], action.Target, action.Users, key)
+			affected = []string{action.Target}
+			break
+		}
+		affected, ts, err = snapshotRestore(st, action.SetID, action.Snaps, action.Users, key)
 	case "forget":
 		if len(action.Users) != 0 {
 			return BadRequest(`snapshot "forget" operation cannot specify users`)
 		}
+		if key != nil {
+			return BadRequest(`snapshot "forget" operation cannot specify a key`)
+		}
 		affected, ts, err = snapshotForget(st, action.SetID, action.Snaps)
 	default:
 		return BadRequest("unknown snapshot operation %q", action.Action)
@@ -154,7 +185,17 @@ func changeSnapshots(c *Command, r *http.Request, user *auth.UserState) Response
 		// woo
 	case client.ErrSnapshotSetNotFound, client.ErrSnapshotSnapsNotFound:
 		return NotFound("%v", err)
+	case client.ErrSnapshotKeyRequired:
+		return &apiError{
+			Status:  400,
+			Message: err.Error(),
+			Kind:    client.ErrorKindSnapshotKeyRequired,
+		}
 	default:
+		var keyErr *snapshotstate.KeyError
+		if errors.As(err, &keyErr) {
+			return BadRequest("%v", err)
+		}
 		return InternalError("%v", err)
 	}
 
@@ -217,8 +258,36 @@ func doSnapshotImport(c *Command, r *http.Request, user *auth.UserState) Respons
 	return SyncResponse(result)
 }
 
+// snapshotEncryptionKey converts the snapshot key given over the API, if
+// any, to the one used by snapshotstate.
+func snapshotEncryptionKey(key *client.SnapshotKey) (*snapshotstate.EncryptionKey, error) {
+	if key == nil {
+		return nil, nil
+	}
+	encKey := &snapshotstate.EncryptionKey{Passphrase: key.Passphrase}
+	var err error
+	if key.PublicKey != "" {
+		if encKey.PublicKey, err = base64.StdEncoding.DecodeString(key.PublicKey); err != nil {
+			return nil, fmt.Errorf("cannot decode snapshot public key: %v", err)
+		}
+	}
+	if key.PrivateKey != "" {
+		if encKey.PrivateKey, err = base64.StdEncoding.DecodeString(key.PrivateKey); err != nil {
+			return nil, fmt.Errorf("cannot decode snapshot private key: %v", err)
+		}
+	}
+	if err := encKey.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid snapshot key: %v", err)
+	}
+	return encKey, nil
+}
+
 func snapshotMany(inst *snapInstruction, st *state.State) (*snapInstructionResult, error) {
-	setID, snapshotted, ts, err := snapshotSave(st, inst.Snaps, inst.Users, inst.SnapshotOptions)
+	key, err := snapshotEncryptionKey(inst.SnapshotKey)
+	if err != nil {
+		return nil, err
+	}
+	setID, snapshotted, ts, err := snapshotSave(st, inst.Snaps, inst.Users, inst.SnapshotOptions, key)
 	if err != nil {
 		return nil, err
 	}
@@ -22,6 +22,7 @@ package daemon_test
 import (
 	"bytes"
 	"context"
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"io"
@@ -54,7 +55,7 @@ func (s *snapshotSuite) SetUpTest(c *check.C) {
 
 func (s *snapshotSuite) TestSnapshotManyOptionsNone(c *check.C) {
 	defer daemon.MockSnapshotSave(func(s *state.State, snaps, users []string,
-		options map[string]*snap.SnapshotOptions) (uint64, []string, *state.TaskSet, error) {
+		options map[string]*snap.SnapshotOptions, key *snapshotstate.EncryptionKey) (uint64, []string, *state.TaskSet, error) {
 		c.Check(snaps, check.HasLen, 2)
 		c.Check(options, check.IsNil)
 		t := s.NewTask("fake-snapshot-2", "Snapshot two")
@@ -75,7 +76,7 @@ func (s *snapshotSuite) TestSnapshotManyOptionsNone(c *check.C) {
 func (s *snapshotSuite) TestSnapshotManyOptionsFull(c *check.C) {
 	var snapshotSaveCalled int
 	defer daemon.MockSnapshotSave(func(s *state.State, snaps, users []string,
-		options map[string]*snap.SnapshotOptions) (uint64, []string, *state.TaskSet, error) {
+		options map[string]*snap.SnapshotOptions, key *snapshotstate.EncryptionKey) (uint64, []string, *state.TaskSet, error) {
 		snapshotSaveCalled++
 		c.Check(snaps, check.HasLen, 2)
 		c.Check(options, check.HasLen, 2)
@@ -100,9 +101,49 @@ func (s *snapshotSuite) TestSnapshotManyOptionsFull(c *check.C) {
 	c.Check(snapshotSaveCalled, check.Equals, 1)
 }
 
+func (s *snapshotSuite) TestSnapshotManyEncrypted(c *check.C) {
+	var keys []*snapshotstate.EncryptionKey
+	defer daemon.MockSnapshotSave(func(s *state.State, snaps, users []string,
+		options map[string]*snap.SnapshotOptions, key *snapshotstate.EncryptionKey) (uint64, []string, *state.TaskSet, error) {
+		keys = append(keys, key)
+		t := s.NewTask("fake-snapshot-2", "Snapshot two")
+		return 1, snaps, state.NewTaskSet(t), nil
+	})()
+
+	publicKey := bytes.Repeat([]byte{7}, 32)
+	for _, body := range []string{
+		`{"action": "snapshot", "snaps": ["foo"], "snapshot-key": {"passphrase": "sekrit"}}`,
+		fmt.Sprintf(`{"action": "snapshot", "snaps": ["foo"], "snapshot-key": {"public-key": %q}}`, base64.StdEncoding.EncodeToString(publicKey)),
+	} {
+		inst := daemon.MustUnmarshalSnapInstruction(c, body)
+		c.Assert(inst.Validate(), check.IsNil)
+
+		st := s.d.Overlord().State()
+		st.Lock()
+		_, err := inst.DispatchForMany()(inst, st)
+		st.Unlock()
+		c.Assert(err, check.IsNil)
+	}
+	c.Check(keys, check.DeepEquals, []*snapshotstate.EncryptionKey{
+		{Passphrase: "sekrit"},
+		{PublicKey: publicKey},
+	})
+}
+
+func (s *snapshotSuite) TestSnapshotManyInvalidKey(c *check.C) {
+	for _, t := range []struct{ body, err string }{
+		{`{"action": "refresh", "snapshot-key": {"passphrase": "sekrit"}}`, "snapshot-key can only be specified for snapshot action"},
+		{`{"action": "snapshot", "snapshot-key": {"private-key": "BwcHBwcHBwcHBwcHBwcHBwcHBwcHBwcHBwcHBwcHBwc="}}`, "cannot encrypt snapshot with a private key"},
+		{`{"action": "snapshot", "snapshot-key": {"public-key": "BwcH"}}`, "invalid snapshot key: invalid public key size 3"},
+	} {
+		inst := daemon.MustUnmarshalSnapInstruction(c, t.body)
+		c.Check(inst.Validate(), check.ErrorMatches, t.err)
+	}
+}
+
 func (s *snapshotSuite) TestSnapshotManyError(c *check.C) {
 	defer daemon.MockSnapshotSave(func(s *state.State, snaps, users []string,
-		options map[string]*snap.SnapshotOptions) (uint64, []string, *state.TaskSet, error) {
+		options map[string]*snap.SnapshotOptions, key *snapshotstate.EncryptionKey) (uint64, []string, *state.TaskSet, error) {
 		c.Check(snaps, check.HasLen, 2)
 		return 0, nil, nil, &snap.NotInstalledError{Snap: "foo"}
 	})()
@@ -237,6 +278,27 @@ func (s *snapshotSuite) TestChangeSnapshots400(c *check.C) {
 		}, {
 			body:  `{"set": 42, "action": "forget", "users": ["foo"]}`,
 			error: `snapshot "forget" operation cannot specify users`,
+		}, {
+			body:  `{"set": 42, "action": "forget", "key": {"passphrase": "sekrit"}}`,
+			error: `snapshot "forget" operation cannot specify a key`,
+		}, {
+			body:  `{"set": 42, "action": "restore", "key": {}}`,
+			error: `invalid snapshot key: exactly one of passphrase, public key or private key must be given`,
+		}, {
+			body:  `{"set": 42, "action": "restore", "key": {"private-key": "!!"}}`,
+			error: `cannot decode snapshot private key: .*`,
+		}, {
+			body:  `{"set": 42, "action": "check", "key": {"private-key": "AAAA"}}`,
+			error: `invalid snapshot key: invalid private key size 3`,
+		}, {
+			body:  `{"set": 42, "action": "check", "snaps": ["foo"], "target": "foo_test"}`,
+			error: `snapshot "check" operation cannot specify a target`,
+		}, {
+			body:  `{"set": 42, "action": "restore", "target": "foo_test"}`,
+			error: `snapshot restore into a target requires exactly one snap`,
+		}, {
+			body:  `{"set": 42, "action": "restore", "snaps": ["foo", "bar"], "target": "foo_test"}`,
+			error: `snapshot restore into a target requires exactly one snap`,
 		},
 	}
 
@@ -254,11 +316,11 @@ func (s *snapshotSuite) TestChangeSnapshots400(c *check.C) {
 func (s *snapshotSuite) TestChangeSnapshots404(c *check.C) {
 	var done string
 	expectedError := errors.New("bzzt")
-	defer daemon.MockSnapshotCheck(func(*state.State, uint64, []string, []string) ([]string, *state.TaskSet, error) {
+	defer daemon.MockSnapshotCheck(func(*state.State, uint64, []string, []string, *snapshotstate.EncryptionKey) ([]string, *state.TaskSet, error) {
 		done = "check"
 		return nil, nil, expectedError
 	})()
-	defer daemon.MockSnapshotRestore(func(*state.State, uint64, []string, []string) ([]string, *state.TaskSet, error) {
+	defer daemon.MockSnapshotRestore(func(*state.State, uint64, []string, []string, *snapshotstate.EncryptionKey) ([]string, *state.TaskSet, error) {
 		done = "restore"
 		return nil, nil, expectedError
 	})()
@@ -285,11 +347,11 @@ func (s *snapshotSuite) TestChangeSnapshots404(c *check.C) {
 func (s *snapshotSuite) TestChangeSnapshots500(c *check.C) {
 	var done string
 	expectedError := errors.New("bzzt")
-	defer daemon.MockSnapshotCheck(func(*state.State, uint64, []string, []string) ([]string, *state.TaskSet, error) {
+	defer daemon.MockSnapshotCheck(func(*state.State, uint64, []string, []string, *snapshotstate.EncryptionKey) ([]string, *state.TaskSet, error) {
 		done = "check"
 		return nil, nil, expectedError
 	})()
-	defer daemon.MockSnapshotRestore(func(*state.State, uint64, []string, []string) ([]string, *state.TaskSet, error) {
+	defer daemon.MockSnapshotRestore(func(*state.State, uint64, []string, []string, *snapshotstate.EncryptionKey) ([]string, *state.TaskSet, error) {
 		done = "restore"
 		return nil, nil, expectedError
 	})()
@@ -310,13 +372,71 @@ func (s *snapshotSuite) TestChangeSnapshots500(c *check.C) {
 	}
 }
 
+func (s *snapshotSuite) TestChangeSnapshotsKeyErrors(c *check.C) {
+	var expectedError error
+	defer daemon.MockSnapshotCheck(func(*state.State, uint64, []string, []string, *snapshotstate.EncryptionKey) ([]string, *state.TaskSet, error) {
+		return nil, nil, expectedError
+	})()
+	defer daemon.MockSnapshotRestore(func(*state.State, uint64, []string, []string, *snapshotstate.EncryptionKey) ([]string, *state.TaskSet, error) {
+		return nil, nil, expectedError
+	})()
+
+	for _, action := range []string{"check", "restore"} {
+		comm := check.Commentf("%s", action)
+
+		expectedError = client.ErrSnapshotKeyRequired
+		body := fmt.Sprintf(`{"set": 42, "action": "%s"}`, action)
+		req, err := http.NewRequest("POST", "/v2/snapshots", strings.NewReader(body))
+		c.Assert(err, check.IsNil, comm)
+		rspe := s.errorReq(c, req, nil)
+		c.Check(rspe.Status, check.Equals, 400, comm)
+		c.Check(rspe.Kind, check.Equals, client.ErrorKindSnapshotKeyRequired, comm)
+		c.Check(rspe.Message, check.Equals, client.ErrSnapshotKeyRequired.Error(), comm)
+
+		expectedError = &snapshotstate.KeyError{SetID: 42, Snap: "foo", Err: errors.New("wrong passphrase")}
+		body = fmt.Sprintf(`{"set": 42, "action": "%s", "key": {"passphrase": "not-sekrit"}}`, action)
+		req, err = http.NewRequest("POST", "/v2/snapshots", strings.NewReader(body))
+		c.Assert(err, check.IsNil, comm)
+		rspe = s.errorReq(c, req, nil)
+		c.Check(rspe.Status, check.Equals, 400, comm)
+		c.Check(rspe.Kind, check.Equals, client.ErrorKind(""), comm)
+		c.Check(rspe.Message, check.Equals, `cannot use key for snapshot of "foo" in set #42: wrong passphrase`, comm)
+	}
+}
+
+func (s *snapshotSuite) TestChangeSnapshotsWithKey(c *check.C) {
+	var keys []*snapshotstate.EncryptionKey
+	defer daemon.MockSnapshotCheck(func(_ *state.State, _ uint64, _ []string, _ []string, key *snapshotstate.EncryptionKey) ([]string, *state.TaskSet, error) {
+		keys = append(keys, key)
+		return []string{"foo"}, state.NewTaskSet(), nil
+	})()
+	defer daemon.MockSnapshotRestore(func(_ *state.State, _ uint64, _ []string, _ []string, key *snapshotstate.EncryptionKey) ([]string, *state.TaskSet, error) {
+		keys = append(keys, key)
+		return []string{"foo"}, state.NewTaskSet(), nil
+	})()
+
+	privateKey := bytes.Repeat([]byte{7}, 32)
+	for _, body := range []string{
+		`{"set": 42, "action": "check", "key": {"passphrase": "sekrit"}}`,
+		fmt.Sprintf(`{"set": 42, "action": "restore", "key": {"private-key": %q}}`, base64.StdEncoding.EncodeToString(privateKey)),
+	} {
+		req, err := http.NewRequest("POST", "/v2/snapshots", strings.NewReader(body))
+		c.Assert(err, check.IsNil)
+		s.asyncReq(c, req, nil)
+	}
+	c.Check(keys, check.DeepEquals, []*snapshotstate.EncryptionKey{
+		{Passphrase: "sekrit"},
+		{PrivateKey: privateKey},
+	})
+}
+
 func (s *snapshotSuite) TestChangeSnapshot(c *check.C) {
 	var done string
-	defer daemon.MockSnapshotCheck(func(*state.State, uint64, []string, []string) ([]string, *state.TaskSet, error) {
+	defer daemon.MockSnapshotCheck(func(*state.State, uint64, []string, []string, *snapshotstate.EncryptionKey) ([]string, *state.TaskSet, error) {
 		done = "check"
 		return []string{"foo"}, state.NewTaskSet(), nil
 	})()
-	defer daemon.MockSnapshotRestore(func(*state.State, uint64, []string, []string) ([]string, *state.TaskSet, error) {
+	defer daemon.MockSnapshotRestore(func(*state.State, uint64, []string, []string, *snapshotstate.EncryptionKey) ([]string, *state.TaskSet, error) {
 		done = "restore"
 		return []string{"foo"}, state.NewTaskSet(), nil
 	})()
@@ -356,6 +476,52 @@ func (s *snapshotSuite) TestChangeSnapshot(c *check.C) {
 	}
 }
 
+func (s *snapshotSuite) TestChangeSnapshotRestoreInto(c *check.C) {
+	defer daemon.MockSnapshotRestore(func(*state.State, uint64, []string, []string, *snapshotstate.EncryptionKey) ([]string, *state.TaskSet, error) {
+		c.Fatal("unexpected call to restore")
+		return nil, nil, nil
+	})()
+	defer daemon.MockSnapshotRestoreInto(func(_ *state.State, setID uint64, snapName, instanceName string, users []string, key *snapshotstate.EncryptionKey) (*state.TaskSet, error) {
+		c.Check(setID, check.Equals, uint64(42))
+		c.Check(snapName, check.Equals, "foo")
+		c.Check(instanceName, check.Equals, "foo_test")
+		c.Check(users, check.DeepEquals, []string{"bar"})
+		c.Check(key, check.DeepEquals, &snapshotstate.EncryptionKey{Passphrase: "sekrit"})
+		return state.NewTaskSet(), nil
+	})()
+
+	body := `{"set": 42, "action": "restore", "snaps": ["foo"], "target": "foo_test", "users": ["bar"], "key": {"passphrase": "sekrit"}}`
+	req, err := http.NewRequest("POST", "/v2/snapshots", strings.NewReader(body))
+	c.Assert(err, check.IsNil)
+	rsp := s.asyncReq(c, req, nil)
+	c.Check(rsp.Status, check.Equals, 202)
+
+	st := s.d.Overlord().State()
+	st.Lock()
+	defer st.Unlock()
+	chg := st.Change(rsp.Change)
+	c.Assert(chg, check.NotNil)
+	c.Check(chg.Kind(), check.Equals, "restore-snapshot")
+	c.Check(chg.Summary(), check.Equals, `Restore of snapshot set #42 for snaps "foo" into "foo_test" for users "bar"`)
+	var apiData map[string]interface{}
+	c.Assert(chg.Get("api-data", &apiData), check.IsNil)
+	c.Check(apiData, check.DeepEquals, map[string]interface{}{
+		"snap-names": []interface{}{"foo_test"},
+	})
+}
+
+func (s *snapshotSuite) TestChangeSnapshotRestoreIntoError(c *check.C) {
+	defer daemon.MockSnapshotRestoreInto(func(*state.State, uint64, string, string, []string, *snapshotstate.EncryptionKey) (*state.TaskSet, error) {
+		return nil, client.ErrSnapshotSnapsNotFound
+	})()
+
+	body := `{"set": 42, "action": "restore", "snaps": ["foo"], "target": "foo_test"}`
+	req, err := http.NewRequest("POST", "/v2/snapshots", strings.NewReader(body))
+	c.Assert(err, check.IsNil)
+	rspe := s.errorReq(c, req, nil)
+	c.Check(rspe.Status, check.Equals, 404)
+}
+
 func (s *snapshotSuite) TestExportSnapshots(c *check.C) {
 	var snapshotExportCalled int
 
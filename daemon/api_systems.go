@@ -20,16 +20,28 @@
 package daemon
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
 
 	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/asserts/snapasserts"
 	"github.com/snapcore/snapd/client"
 	"github.com/snapcore/snapd/gadget"
+	"github.com/snapcore/snapd/overlord/assertstate"
 	"github.com/snapcore/snapd/overlord/auth"
 	"github.com/snapcore/snapd/overlord/devicestate"
 	"github.com/snapcore/snapd/overlord/install"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/release"
 	"github.com/snapcore/snapd/snap"
 )
 
@@ -86,8 +98,9 @@ func getAllSystems(c *Command, r *http.Request, user *auth.UserState) Response {
 		}
 
 		rsp.Systems = append(rsp.Systems, client.System{
-			Current: ss.Current,
-			Label:   ss.Label,
+			Current:               ss.Current,
+			DefaultRecoverySystem: ss.DefaultRecoverySystem,
+			Label:                 ss.Label,
 			Model: client.SystemModelData{
 				Model:       ss.Model.Model(),
 				BrandID:     ss.Model.BrandID(),
@@ -179,12 +192,22 @@ type systemActionRequest struct {
 
 	client.SystemAction
 	client.InstallSystemOptions
+	client.CreateSystemOptions
 }
 
 func postSystemsAction(c *Command, r *http.Request, user *auth.UserState) Response {
 	var req systemActionRequest
 	systemLabel := muxVars(r)["label"]
 
+	contentType := r.Header.Get("Content-Type")
+	if strings.HasPrefix(contentType, "multipart/") {
+		_, params, err := mime.ParseMediaType(contentType)
+		if err != nil {
+			return BadRequest("cannot parse content type: %v", err)
+		}
+		return postSystemActionCreateOffline(c, systemLabel, r.Body, params["boundary"])
+	}
+
 	decoder := json.NewDecoder(r.Body)
 	if err := decoder.Decode(&req); err != nil {
 		return BadRequest("cannot decode request body into system action: %v", err)
@@ -199,6 +222,12 @@ func postSystemsAction(c *Command, r *http.Request, user *auth.UserState) Respon
 		return postSystemActionReboot(c, systemLabel, &req)
 	case "install":
 		return postSystemActionInstall(c, systemLabel, &req)
+	case "create":
+		return postSystemActionCreate(c, systemLabel, &req)
+	case "remove":
+		return postSystemActionRemove(c, systemLabel)
+	case "set-default":
+		return postSystemActionSetDefault(c, systemLabel)
 	default:
 		return BadRequest("unsupported action %q", req.Action)
 	}
@@ -271,3 +300,224 @@ func postSystemActionInstall(c *Command, systemLabel string, req *systemActionRe
 		return BadRequest("unsupported install step %q", req.Step)
 	}
 }
+
+var (
+	devicestateCreateRecoverySystem     = devicestate.CreateRecoverySystem
+	devicestateRemoveRecoverySystem     = devicestate.RemoveRecoverySystem
+	devicestateSetDefaultRecoverySystem = devicestate.SetDefaultRecoverySystem
+)
+
+// handleRecoverySystemErr maps errors from managing the recovery system
+// with the given label to responses, the format is passed the label and the
+// error.
+func handleRecoverySystemErr(err error, systemLabel, format string) Response {
+	if os.IsNotExist(err) {
+		return NotFound("requested seed system %q does not exist", systemLabel)
+	}
+	var conflErr *snapstate.ChangeConflictError
+	if errors.As(err, &conflErr) {
+		return SnapChangeConflict(conflErr)
+	}
+	return BadRequest(format, systemLabel, err)
+}
+
+// recoverySystemLabel returns the label of the recovery system to create,
+// which can be provided either in the URL or in the request.
+func recoverySystemLabel(systemLabel, label string) (string, *apiError) {
+	switch {
+	case systemLabel == "" && label == "":
+		return "", BadRequest("cannot create a recovery system with no label")
+	case systemLabel != "" && label != "" && systemLabel != label:
+		return "", BadRequest("cannot create a recovery system with mismatched labels %q and %q", systemLabel, label)
+	case systemLabel != "":
+		return systemLabel, nil
+	}
+	return label, nil
+}
+
+// recoverySystemValidationSets finds the given validation sets in the
+// assertions database. Validation sets without a sequence resolve to the
+// latest one known locally.
+func recoverySystemValidationSets(st *state.State, validationSets []string) ([]*asserts.ValidationSet, *apiError) {
+	db := assertstate.DB(st)
+	valsets := make([]*asserts.ValidationSet, 0, len(validationSets))
+	for _, vs := range validationSets {
+		accountID, name, seq, err := snapasserts.ParseValidationSet(vs)
+		if err != nil {
+			return nil, BadRequest(err.Error())
+		}
+		headers := map[string]string{
+			"series":     release.Series,
+			"account-id": accountID,
+			"name":       name,
+		}
+		var a asserts.Assertion
+		if seq > 0 {
+			headers["sequence"] = strconv.Itoa(seq)
+			a, err = db.Find(asserts.ValidationSetType, headers)
+		} else {
+			a, err = db.FindSequence(asserts.ValidationSetType, headers, -1, -1)
+		}
+		if err != nil {
+			if errors.Is(err, &asserts.NotFoundError{}) {
+				return nil, BadRequest("cannot find validation set %q", vs)
+			}
+			return nil, InternalError(err.Error())
+		}
+		valsets = append(valsets, a.(*asserts.ValidationSet))
+	}
+	return valsets, nil
+}
+
+func postSystemActionCreate(c *Command, systemLabel string, req *systemActionRequest) Response {
+	label, errRsp := recoverySystemLabel(systemLabel, req.Label)
+	if errRsp != nil {
+		return errRsp
+	}
+
+	st := c.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+
+	valsets, errRsp := recoverySystemValidationSets(st, req.ValidationSets)
+	if errRsp != nil {
+		return errRsp
+	}
+
+	chg, err := devicestateCreateRecoverySystem(st, label, devicestate.CreateRecoverySystemOptions{
+		ValidationSets: valsets,
+		MarkDefault:    req.MarkDefault,
+	})
+	if err != nil {
+		return handleRecoverySystemErr(err, label, "cannot create recovery system %q: %v")
+	}
+	ensureStateSoon(st)
+	return AsyncResponse(nil, chg.ID())
+}
+
+// postSystemActionCreateOffline creates a recovery system using the snap
+// files and assertions uploaded in a multipart/form-data request. Besides the
+// "snap" file parts and "assertion" values, the form carries the "action",
+// "label", "validation-sets" and "mark-default" values of a regular request.
+func postSystemActionCreateOffline(c *Command, systemLabel string, body io.Reader, boundary string) Response {
+	form, errRsp := readForm(multipart.NewReader(body, boundary))
+	if errRsp != nil {
+		return errRsp
+	}
+
+	// we are in charge of the temp files, until they're handed off to the change
+	var pathsToNotRemove []string
+	defer func() {
+		form.RemoveAllExcept(pathsToNotRemove)
+	}()
+
+	if len(form.Values["action"]) != 1 || form.Values["action"][0] != "create" {
+		return BadRequest(`multipart/form-data payload is only supported for the "create" action`)
+	}
+	var formLabel string
+	if len(form.Values["label"]) > 0 {
+		formLabel = form.Values["label"][0]
+	}
+	label, errRsp := recoverySystemLabel(systemLabel, formLabel)
+	if errRsp != nil {
+		return errRsp
+	}
+	var markDefault bool
+	if len(form.Values["mark-default"]) > 0 {
+		var err error
+		markDefault, err = strconv.ParseBool(form.Values["mark-default"][0])
+		if err != nil {
+			return BadRequest(`cannot parse "mark-default" value: %v`, err)
+		}
+	}
+
+	batch := asserts.NewBatch(nil)
+	for _, stream := range form.Values["assertion"] {
+		if _, err := batch.AddStream(bytes.NewBufferString(stream)); err != nil {
+			return BadRequest("cannot decode assertions: %v", err)
+		}
+	}
+
+	st := c.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+
+	if err := assertstate.AddBatch(st, batch, &asserts.CommitOptions{
+		Precheck: true,
+	}); err != nil {
+		return BadRequest("cannot add assertions: %v", err)
+	}
+
+	valsets, errRsp := recoverySystemValidationSets(st, form.Values["validation-sets"])
+	if errRsp != nil {
+		return errRsp
+	}
+
+	model, err := c.d.overlord.DeviceManager().Model()
+	if err != nil {
+		return InternalError("cannot get device model: %v", err)
+	}
+
+	refs := form.FileRefs["snap"]
+	sideInfos := make([]*snap.SideInfo, 0, len(refs))
+	paths := make([]string, 0, len(refs))
+	for _, ref := range refs {
+		si, err := snapasserts.DeriveSideInfo(ref.TmpPath, model, assertstate.DB(st))
+		if err != nil {
+			if errors.Is(err, &asserts.NotFoundError{}) {
+				return BadRequest("cannot find signatures with metadata for snap %q", ref.Filename)
+			}
+			return BadRequest(err.Error())
+		}
+		sideInfos = append(sideInfos, si)
+		paths = append(paths, ref.TmpPath)
+	}
+
+	chg, err := devicestateCreateRecoverySystem(st, label, devicestate.CreateRecoverySystemOptions{
+		ValidationSets:     valsets,
+		LocalSnapSideInfos: sideInfos,
+		LocalSnapPaths:     paths,
+		MarkDefault:        markDefault,
+	})
+	if err != nil {
+		return handleRecoverySystemErr(err, label, "cannot create recovery system %q: %v")
+	}
+	ensureStateSoon(st)
+
+	// the snap files are removed by the change once it is done
+	pathsToNotRemove = paths
+
+	return AsyncResponse(nil, chg.ID())
+}
+
+func postSystemActionRemove(c *Command, systemLabel string) Response {
+	if systemLabel == "" {
+		return BadRequest("system action requires the system label to be provided")
+	}
+
+	st := c.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+
+	chg, err := devicestateRemoveRecoverySystem(st, systemLabel)
+	if err != nil {
+		return handleRecoverySystemErr(err, systemLabel, "cannot remove recovery system %q: %v")
+	}
+	ensureStateSoon(st)
+	return AsyncResponse(nil, chg.ID())
+}
+
+func postSystemActionSetDefault(c *Command, systemLabel string) Response {
+	if systemLabel == "" {
+		return BadRequest("system action requires the system label to be provided")
+	}
+
+	st := c.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+
+	if err := devicestateSetDefaultRecoverySystem(st, systemLabel); err != nil {
+		return handleRecoverySystemErr(err, systemLabel, "cannot set default recovery system %q: %v")
+	}
+	return SyncResponse(nil)
+}
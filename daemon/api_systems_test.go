@@ -49,6 +49,7 @@ import (
 	"github.com/snapcore/snapd/overlord/hookstate"
 	"github.com/snapcore/snapd/overlord/install"
 	"github.com/snapcore/snapd/overlord/restart"
+	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/release"
 	"github.com/snapcore/snapd/secboot"
@@ -1215,3 +1216,318 @@ func (s *systemsSuite) TestSystemInstallActionError(c *check.C) {
 	rspe := s.errorReq(c, req, nil)
 	c.Check(rspe.Error(), check.Equals, `unsupported install step "unknown-install-step" (api)`)
 }
+
+func (s *systemsSuite) mockValidationSet(c *check.C, sequence string) *asserts.ValidationSet {
+	headers := map[string]interface{}{
+		"authority-id": "my-brand",
+		"account-id":   "my-brand",
+		"name":         "my-set",
+		"series":       "16",
+		"sequence":     sequence,
+		"revision":     "1",
+		"timestamp":    "2030-11-06T09:16:26Z",
+		"snaps": []interface{}{map[string]interface{}{
+			"id":       "yOqKhntON3vR7kwEbVPsILm7bUViPDzz",
+			"name":     "snap-b",
+			"presence": "required",
+			"revision": "1",
+		}},
+	}
+	vs, err := s.Brands.Signing("my-brand").Sign(asserts.ValidationSetType, headers, nil, "")
+	c.Assert(err, check.IsNil)
+	return vs.(*asserts.ValidationSet)
+}
+
+func (s *systemsSuite) TestSystemActionCreate(c *check.C) {
+	d := s.daemon(c)
+	st := d.Overlord().State()
+
+	soon := 0
+	_, restore := daemon.MockEnsureStateSoon(func(st *state.State) {
+		soon++
+	})
+	defer restore()
+
+	vs1 := s.mockValidationSet(c, "1")
+	vs2 := s.mockValidationSet(c, "2")
+	st.Lock()
+	assertstatetest.AddMany(st, s.StoreSigning.StoreAccountKey(""))
+	assertstatetest.AddMany(st, s.Brands.AccountsAndKeys("my-brand")...)
+	assertstatetest.AddMany(st, vs1, vs2)
+	st.Unlock()
+
+	var gotLabel string
+	var gotOpts devicestate.CreateRecoverySystemOptions
+	defer daemon.MockDevicestateCreateRecoverySystem(func(st *state.State, label string, opts devicestate.CreateRecoverySystemOptions) (*state.Change, error) {
+		gotLabel = label
+		gotOpts = opts
+		return st.NewChange("create-recovery-system", "..."), nil
+	})()
+
+	for _, tc := range []struct {
+		url      string
+		body     map[string]interface{}
+		label    string
+		valsets  []*asserts.ValidationSet
+		markDflt bool
+	}{{
+		url:   "/v2/systems",
+		body:  map[string]interface{}{"action": "create", "label": "1234"},
+		label: "1234",
+	}, {
+		url:   "/v2/systems/1234",
+		body:  map[string]interface{}{"action": "create"},
+		label: "1234",
+	}, {
+		url: "/v2/systems",
+		body: map[string]interface{}{
+			"action":          "create",
+			"label":           "1234",
+			"validation-sets": []string{"my-brand/my-set=1"},
+			"mark-default":    true,
+		},
+		label:    "1234",
+		valsets:  []*asserts.ValidationSet{vs1},
+		markDflt: true,
+	}, {
+		// latest known sequence
+		url: "/v2/systems/1234",
+		body: map[string]interface{}{
+			"action":          "create",
+			"validation-sets": []string{"my-brand/my-set"},
+		},
+		label:   "1234",
+		valsets: []*asserts.ValidationSet{vs2},
+	}} {
+		soon = 0
+		b, err := json.Marshal(tc.body)
+		c.Assert(err, check.IsNil)
+		req, err := http.NewRequest("POST", tc.url, bytes.NewBuffer(b))
+		c.Assert(err, check.IsNil)
+
+		rsp := s.asyncReq(c, req, nil)
+
+		st.Lock()
+		chg := st.Change(rsp.Change)
+		st.Unlock()
+		c.Check(chg, check.NotNil)
+		c.Check(gotLabel, check.Equals, tc.label)
+		c.Check(gotOpts.MarkDefault, check.Equals, tc.markDflt)
+		c.Assert(gotOpts.ValidationSets, check.HasLen, len(tc.valsets))
+		for i, vs := range tc.valsets {
+			c.Check(gotOpts.ValidationSets[i].Sequence(), check.Equals, vs.Sequence())
+		}
+		c.Check(gotOpts.LocalSnapPaths, check.HasLen, 0)
+		c.Check(soon, check.Equals, 1)
+	}
+}
+
+func (s *systemsSuite) TestSystemActionCreateErrors(c *check.C) {
+	s.daemon(c)
+
+	defer daemon.MockDevicestateCreateRecoverySystem(func(st *state.State, label string, opts devicestate.CreateRecoverySystemOptions) (*state.Change, error) {
+		if label == "conflict" {
+			return nil, &snapstate.ChangeConflictError{Message: "conflict", ChangeKind: "remodel"}
+		}
+		return nil, errors.New("mocked error")
+	})()
+
+	for _, tc := range []struct {
+		url    string
+		body   map[string]interface{}
+		status int
+		err    string
+	}{{
+		url:    "/v2/systems",
+		body:   map[string]interface{}{"action": "create"},
+		status: 400,
+		err:    `cannot create a recovery system with no label \(api\)`,
+	}, {
+		url:    "/v2/systems/1234",
+		body:   map[string]interface{}{"action": "create", "label": "4567"},
+		status: 400,
+		err:    `cannot create a recovery system with mismatched labels "1234" and "4567" \(api\)`,
+	}, {
+		url:    "/v2/systems/1234",
+		body:   map[string]interface{}{"action": "create", "validation-sets": []string{"foo/bar/baz"}},
+		status: 400,
+		err:    `cannot parse validation set "foo/bar/baz".*`,
+	}, {
+		url:    "/v2/systems/1234",
+		body:   map[string]interface{}{"action": "create", "validation-sets": []string{"my-brand/unknown=2"}},
+		status: 400,
+		err:    `cannot find validation set "my-brand/unknown=2" \(api\)`,
+	}, {
+		url:    "/v2/systems/1234",
+		body:   map[string]interface{}{"action": "create"},
+		status: 400,
+		err:    `cannot create recovery system "1234": mocked error \(api\)`,
+	}, {
+		url:    "/v2/systems/conflict",
+		body:   map[string]interface{}{"action": "create"},
+		status: 409,
+		err:    `conflict \(api: snap-change-conflict\)`,
+	}} {
+		b, err := json.Marshal(tc.body)
+		c.Assert(err, check.IsNil)
+		req, err := http.NewRequest("POST", tc.url, bytes.NewBuffer(b))
+		c.Assert(err, check.IsNil)
+
+		rspe := s.errorReq(c, req, nil)
+		c.Check(rspe.Status, check.Equals, tc.status, check.Commentf("%v", tc.body))
+		c.Check(rspe.Error(), check.Matches, tc.err)
+	}
+}
+
+func (s *systemsSuite) TestSystemActionCreateMultipartOnlyForCreate(c *check.C) {
+	s.daemon(c)
+
+	body := "--foo\r\n" +
+		"Content-Disposition: form-data; name=\"action\"\r\n" +
+		"\r\n" +
+		"remove\r\n" +
+		"--foo--\r\n"
+	req, err := http.NewRequest("POST", "/v2/systems/1234", bytes.NewBufferString(body))
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", "multipart/thing; boundary=foo")
+
+	rspe := s.errorReq(c, req, nil)
+	c.Check(rspe.Status, check.Equals, 400)
+	c.Check(rspe.Message, check.Equals, `multipart/form-data payload is only supported for the "create" action`)
+}
+
+func (s *systemsSuite) TestSystemActionCreateMultipartNoSnaps(c *check.C) {
+	d := s.daemon(c)
+	st := d.Overlord().State()
+
+	_, restore := daemon.MockEnsureStateSoon(func(st *state.State) {})
+	defer restore()
+
+	st.Lock()
+	s.mockModel(st, nil)
+	st.Unlock()
+
+	var gotLabel string
+	var gotOpts devicestate.CreateRecoverySystemOptions
+	defer daemon.MockDevicestateCreateRecoverySystem(func(st *state.State, label string, opts devicestate.CreateRecoverySystemOptions) (*state.Change, error) {
+		gotLabel = label
+		gotOpts = opts
+		return st.NewChange("create-recovery-system", "..."), nil
+	})()
+
+	body := "--foo\r\n" +
+		"Content-Disposition: form-data; name=\"action\"\r\n" +
+		"\r\n" +
+		"create\r\n" +
+		"--foo\r\n" +
+		"Content-Disposition: form-data; name=\"label\"\r\n" +
+		"\r\n" +
+		"1234\r\n" +
+		"--foo\r\n" +
+		"Content-Disposition: form-data; name=\"mark-default\"\r\n" +
+		"\r\n" +
+		"true\r\n" +
+		"--foo--\r\n"
+	req, err := http.NewRequest("POST", "/v2/systems", bytes.NewBufferString(body))
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", "multipart/thing; boundary=foo")
+
+	rsp := s.asyncReq(c, req, nil)
+	st.Lock()
+	chg := st.Change(rsp.Change)
+	st.Unlock()
+	c.Check(chg, check.NotNil)
+	c.Check(gotLabel, check.Equals, "1234")
+	c.Check(gotOpts.MarkDefault, check.Equals, true)
+	c.Check(gotOpts.LocalSnapSideInfos, check.HasLen, 0)
+}
+
+func (s *systemsSuite) TestSystemActionRemove(c *check.C) {
+	d := s.daemon(c)
+	st := d.Overlord().State()
+
+	soon := 0
+	_, restore := daemon.MockEnsureStateSoon(func(st *state.State) {
+		soon++
+	})
+	defer restore()
+
+	var gotLabel string
+	defer daemon.MockDevicestateRemoveRecoverySystem(func(st *state.State, label string) (*state.Change, error) {
+		gotLabel = label
+		return st.NewChange("remove-recovery-system", "..."), nil
+	})()
+
+	req, err := http.NewRequest("POST", "/v2/systems/1234", strings.NewReader(`{"action": "remove"}`))
+	c.Assert(err, check.IsNil)
+	rsp := s.asyncReq(c, req, nil)
+
+	st.Lock()
+	chg := st.Change(rsp.Change)
+	st.Unlock()
+	c.Check(chg, check.NotNil)
+	c.Check(gotLabel, check.Equals, "1234")
+	c.Check(soon, check.Equals, 1)
+}
+
+func (s *systemsSuite) TestSystemActionRemoveErrors(c *check.C) {
+	s.daemon(c)
+
+	var mockErr error
+	defer daemon.MockDevicestateRemoveRecoverySystem(func(st *state.State, label string) (*state.Change, error) {
+		return nil, mockErr
+	})()
+
+	for _, tc := range []struct {
+		url     string
+		mockErr error
+		status  int
+		err     string
+	}{{
+		url:    "/v2/systems",
+		status: 400,
+		err:    `system action requires the system label to be provided \(api\)`,
+	}, {
+		url:     "/v2/systems/1234",
+		mockErr: os.ErrNotExist,
+		status:  404,
+		err:     `requested seed system "1234" does not exist \(api 404\)`,
+	}, {
+		url:     "/v2/systems/1234",
+		mockErr: errors.New(`cannot remove the current recovery system "1234"`),
+		status:  400,
+		err:     `cannot remove recovery system "1234": cannot remove the current recovery system "1234" \(api\)`,
+	}} {
+		mockErr = tc.mockErr
+		req, err := http.NewRequest("POST", tc.url, strings.NewReader(`{"action": "remove"}`))
+		c.Assert(err, check.IsNil)
+
+		rspe := s.errorReq(c, req, nil)
+		c.Check(rspe.Status, check.Equals, tc.status)
+		c.Check(rspe.Error(), check.Matches, tc.err)
+	}
+}
+
+func (s *systemsSuite) TestSystemActionSetDefault(c *check.C) {
+	s.daemon(c)
+
+	var gotLabel string
+	var mockErr error
+	defer daemon.MockDevicestateSetDefaultRecoverySystem(func(st *state.State, label string) error {
+		gotLabel = label
+		return mockErr
+	})()
+
+	req, err := http.NewRequest("POST", "/v2/systems/1234", strings.NewReader(`{"action": "set-default"}`))
+	c.Assert(err, check.IsNil)
+	rsp := s.syncReq(c, req, nil)
+	c.Check(rsp.Status, check.Equals, 200)
+	c.Check(gotLabel, check.Equals, "1234")
+
+	mockErr = errors.New("system is not known to be good")
+	req, err = http.NewRequest("POST", "/v2/systems/1234", strings.NewReader(`{"action": "set-default"}`))
+	c.Assert(err, check.IsNil)
+	rspe := s.errorReq(c, req, nil)
+	c.Check(rspe.Status, check.Equals, 400)
+	c.Check(rspe.Message, check.Equals, `cannot set default recovery system "1234": system is not known to be good`)
+}
@@ -20,6 +20,7 @@
 package daemon
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
@@ -206,6 +207,15 @@ func (w *wrappedWriter) Flush() {
 	}
 }
 
+func (w *wrappedWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := w.w.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("internal error: response writer cannot be hijacked")
+	}
+	w.s = http.StatusSwitchingProtocols
+	return hj.Hijack()
+}
+
 func logit(handler http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		ww := &wrappedWriter{w: w}
@@ -299,8 +309,8 @@ func (ct *connTracker) CanStandby() bool {
 func (ct *connTracker) trackConn(conn net.Conn, state http.ConnState) {
 	ct.mu.Lock()
 	defer ct.mu.Unlock()
-	// we ignore hijacked connections, if we do things with websockets
-	// we'll need custom shutdown handling for them
+	// hijacked connections are forgotten here; the exec API tracks
+	// them itself while commands run
 	if state == http.StateNew || state == http.StateActive {
 		ct.conns[conn] = struct{}{}
 	} else {
@@ -315,6 +315,8 @@ func errToResponse(err error, snaps []string, fallback errorResponder, format st
 		case *snap.NotInstalledError:
 			kind = client.ErrorKindSnapNotInstalled
 			snapName = err.Snap
+		case *snap.ComponentNotInstalledError:
+			snapName = err.Snap
 		case *servicestate.QuotaChangeConflictError:
 			return QuotaChangeConflict(err)
 		case *snapstate.SnapNeedsDevModeError:
@@ -23,6 +23,7 @@ import (
 	"github.com/snapcore/snapd/asserts"
 	"github.com/snapcore/snapd/overlord/devicestate"
 	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
 )
 
 func MockDevicestateRemodel(mock func(*state.State, *asserts.Model) (*state.Change, error)) (restore func()) {
@@ -33,6 +34,14 @@ func MockDevicestateRemodel(mock func(*state.State, *asserts.Model) (*state.Chan
 	}
 }
 
+func MockDevicestateRemodelOffline(mock func(*state.State, *asserts.Model, []*snap.SideInfo, []string) (*state.Change, error)) (restore func()) {
+	oldDevicestateRemodelOffline := devicestateRemodelOffline
+	devicestateRemodelOffline = mock
+	return func() {
+		devicestateRemodelOffline = oldDevicestateRemodelOffline
+	}
+}
+
 func MockDevicestateDeviceManagerUnregister(mock func(*devicestate.DeviceManager, *devicestate.UnregisterOptions) error) (restore func()) {
 	oldDevicestateDeviceManagerUnregister := devicestateDeviceManagerUnregister
 	devicestateDeviceManagerUnregister = mock
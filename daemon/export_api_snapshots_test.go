@@ -32,7 +32,7 @@ import (
 	"github.com/snapcore/snapd/snap"
 )
 
-func MockSnapshotSave(newSave func(*state.State, []string, []string, map[string]*snap.SnapshotOptions) (uint64, []string, *state.TaskSet, error)) (restore func()) {
+func MockSnapshotSave(newSave func(*state.State, []string, []string, map[string]*snap.SnapshotOptions, *snapshotstate.EncryptionKey) (uint64, []string, *state.TaskSet, error)) (restore func()) {
 	oldSave := snapshotSave
 	snapshotSave = newSave
 	return func() {
@@ -56,7 +56,7 @@ func MockSnapshotExport(newExport func(context.Context, *state.State, uint64) (*
 	}
 }
 
-func MockSnapshotCheck(newCheck func(*state.State, uint64, []string, []string) ([]string, *state.TaskSet, error)) (restore func()) {
+func MockSnapshotCheck(newCheck func(*state.State, uint64, []string, []string, *snapshotstate.EncryptionKey) ([]string, *state.TaskSet, error)) (restore func()) {
 	oldCheck := snapshotCheck
 	snapshotCheck = newCheck
 	return func() {
@@ -64,7 +64,7 @@ func MockSnapshotCheck(newCheck func(*state.State, uint64, []string, []string) (
 	}
 }
 
-func MockSnapshotRestore(newRestore func(*state.State, uint64, []string, []string) ([]string, *state.TaskSet, error)) (restore func()) {
+func MockSnapshotRestore(newRestore func(*state.State, uint64, []string, []string, *snapshotstate.EncryptionKey) ([]string, *state.TaskSet, error)) (restore func()) {
 	oldRestore := snapshotRestore
 	snapshotRestore = newRestore
 	return func() {
@@ -72,6 +72,14 @@ func MockSnapshotRestore(newRestore func(*state.State, uint64, []string, []strin
 	}
 }
 
+func MockSnapshotRestoreInto(newRestoreInto func(*state.State, uint64, string, string, []string, *snapshotstate.EncryptionKey) (*state.TaskSet, error)) (restore func()) {
+	oldRestoreInto := snapshotRestoreInto
+	snapshotRestoreInto = newRestoreInto
+	return func() {
+		snapshotRestoreInto = oldRestoreInto
+	}
+}
+
 func MockSnapshotForget(newForget func(*state.State, uint64, []string) ([]string, *state.TaskSet, error)) (restore func()) {
 	oldForget := snapshotForget
 	snapshotForget = newForget
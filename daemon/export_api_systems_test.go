@@ -56,3 +56,21 @@ func MockDevicestateInstallSetupStorageEncryption(f func(*state.State, string, m
 	devicestateInstallSetupStorageEncryption = f
 	return restore
 }
+
+func MockDevicestateCreateRecoverySystem(f func(*state.State, string, devicestate.CreateRecoverySystemOptions) (*state.Change, error)) (restore func()) {
+	restore = testutil.Backup(&devicestateCreateRecoverySystem)
+	devicestateCreateRecoverySystem = f
+	return restore
+}
+
+func MockDevicestateRemoveRecoverySystem(f func(*state.State, string) (*state.Change, error)) (restore func()) {
+	restore = testutil.Backup(&devicestateRemoveRecoverySystem)
+	devicestateRemoveRecoverySystem = f
+	return restore
+}
+
+func MockDevicestateSetDefaultRecoverySystem(f func(*state.State, string) error) (restore func()) {
+	restore = testutil.Backup(&devicestateSetDefaultRecoverySystem)
+	devicestateSetDefaultRecoverySystem = f
+	return restore
+}
@@ -112,6 +112,30 @@ func MockUnsafeReadSnapInfo(mock func(string) (*snap.Info, error)) (restore func
 	}
 }
 
+func MockUnsafeReadComponentInfo(mock func(string) (*snap.ComponentInfo, error)) (restore func()) {
+	old := unsafeReadComponentInfo
+	unsafeReadComponentInfo = mock
+	return func() {
+		unsafeReadComponentInfo = old
+	}
+}
+
+func MockSnapstateInstallComponentPath(mock func(*state.State, *snap.ComponentSideInfo, *snap.Info, string, snapstate.Flags) (*state.TaskSet, error)) (restore func()) {
+	old := snapstateInstallComponentPath
+	snapstateInstallComponentPath = mock
+	return func() {
+		snapstateInstallComponentPath = old
+	}
+}
+
+func MockSnapstateRemoveComponent(mock func(*state.State, string, string) (*state.TaskSet, error)) (restore func()) {
+	old := snapstateRemoveComponent
+	snapstateRemoveComponent = mock
+	return func() {
+		snapstateRemoveComponent = old
+	}
+}
+
 func MockAssertstateRefreshSnapAssertions(mock func(*state.State, int, *assertstate.RefreshAssertionsOptions) error) (restore func()) {
 	oldAssertstateRefreshSnapAssertions := assertstateRefreshSnapAssertions
 	assertstateRefreshSnapAssertions = mock
@@ -287,6 +311,10 @@ func (inst *snapInstruction) Dispatch() snapActionFunc {
 	return inst.dispatch()
 }
 
+func (inst *snapInstruction) Validate() error {
+	return inst.validate()
+}
+
 func (inst *snapInstruction) DispatchForMany() snapManyActionFunc {
 	return inst.dispatchForMany()
 }
@@ -21,6 +21,8 @@ package daemon
 
 import (
 	"bufio"
+	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -332,6 +334,70 @@ func (rr *journalLineReaderSeqResponse) ServeHTTP(w http.ResponseWriter, r *http
 	rr.Close()
 }
 
+// A changeSeqResponse's ServeHTTP method outputs the json dump of the
+// change every time the change or any of its tasks is updated, each padded
+// with RS and LF to make it a valid json-seq response. The response ends
+// once the change is ready, the client goes away or the daemon is stopped.
+type changeSeqResponse struct {
+	st    *state.State
+	chg   *state.Change
+	dying <-chan struct{}
+}
+
+func (rr *changeSeqResponse) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json-seq")
+
+	flusher, hasFlusher := w.(http.Flusher)
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	go func() {
+		select {
+		case <-rr.dying:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	writer := bufio.NewWriter(w)
+	var last []byte
+	var seen uint64
+	for {
+		rr.st.Lock()
+		var err error
+		seen, err = rr.chg.WaitUpdate(ctx, seen)
+		if err != nil {
+			rr.st.Unlock()
+			return
+		}
+		info := change2changeInfo(rr.chg)
+		rr.st.Unlock()
+
+		buf, err := json.Marshal(info)
+		if err != nil {
+			logger.Noticef("cannot stream response; problem encoding change: %v", err)
+			return
+		}
+		// only send the change when something visible changed
+		if !bytes.Equal(buf, last) {
+			writer.WriteByte(0x1E) // RS -- see ascii(7), and RFC7464
+			writer.Write(buf)
+			writer.WriteByte('\n')
+			if err := writer.Flush(); err != nil {
+				logger.Noticef("cannot stream response; problem writing: %v", err)
+				return
+			}
+			if hasFlusher {
+				flusher.Flush()
+			}
+			last = buf
+		}
+		if info.Ready {
+			return
+		}
+	}
+}
+
 type assertResponse struct {
 	assertions []asserts.Assertion
 	bundle     bool
@@ -68,6 +68,10 @@ const (
 	//  * journal quotas are still experimental
 	// while guota groups creation and management and memory, cpu, quotas are no longer experimental.
 	QuotaGroups
+	// JournaledState controls whether snapd persists its state incrementally via a journal.
+	JournaledState
+	// DeduplicatedSnapshots controls whether snapshots share unchanged data via a content-addressed store.
+	DeduplicatedSnapshots
 
 	// lastFeature is the final known feature, it is only used for testing.
 	lastFeature
@@ -108,6 +112,10 @@ var featureNames = map[SnapdFeature]string{
 	GateAutoRefreshHook: "gate-auto-refresh-hook",
 
 	QuotaGroups: "quota-groups",
+
+	JournaledState: "journaled-state",
+
+	DeduplicatedSnapshots: "deduplicated-snapshots",
 }
 
 // featuresEnabledWhenUnset contains a set of features that are enabled when not explicitly configured.
@@ -129,6 +137,8 @@ var featuresExported = map[SnapdFeature]bool{
 	RobustMountNamespaceUpdates:   true,
 	HiddenSnapDataHomeDir:         true,
 	MoveSnapHomeDir:               true,
+
+	JournaledState: true,
 }
 
 // String returns the name of a snapd feature.
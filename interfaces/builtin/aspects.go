@@ -0,0 +1,91 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package builtin
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/snap"
+)
+
+const aspectsSummary = `allows access to aspects of configuration`
+
+// The plug side is super-privileged: it grants access to configuration
+// owned by the account named in the plug attributes.
+const aspectsBaseDeclarationPlugs = `
+  aspects:
+    allow-installation: false
+    deny-auto-connection: true
+`
+
+const aspectsBaseDeclarationSlots = `
+  aspects:
+    allow-installation:
+      slot-snap-type:
+        - core
+    deny-auto-connection: true
+`
+
+var validAspectAttrName = regexp.MustCompile("^[a-z0-9](?:-?[a-z0-9])*$")
+
+type aspectsInterface struct {
+	commonInterface
+}
+
+func (iface *aspectsInterface) BeforePreparePlug(plug *snap.PlugInfo) error {
+	account, ok := plug.Attrs["account"].(string)
+	if !ok || account == "" {
+		return fmt.Errorf(`aspects plug must have a valid "account" attribute`)
+	}
+	if !asserts.IsValidAccountID(account) {
+		return fmt.Errorf(`aspects plug must have a valid "account" attribute: format mismatch`)
+	}
+
+	for _, attr := range []string{"bundle", "aspect"} {
+		value, ok := plug.Attrs[attr].(string)
+		if !ok || value == "" {
+			return fmt.Errorf("aspects plug must have a valid %q attribute", attr)
+		}
+		if !validAspectAttrName.MatchString(value) {
+			return fmt.Errorf("aspects plug must have a valid %q attribute: format mismatch", attr)
+		}
+	}
+
+	if role, ok := plug.Attrs["role"]; ok {
+		if role != "manager" {
+			return fmt.Errorf(`aspects plug has invalid "role" attribute: expected "manager" but got %v`, role)
+		}
+	}
+
+	return nil
+}
+
+func init() {
+	registerIface(&aspectsInterface{commonInterface{
+		name:                 "aspects",
+		summary:              aspectsSummary,
+		implicitOnCore:       true,
+		implicitOnClassic:    true,
+		baseDeclarationPlugs: aspectsBaseDeclarationPlugs,
+		baseDeclarationSlots: aspectsBaseDeclarationSlots,
+	}})
+}
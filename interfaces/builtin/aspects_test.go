@@ -0,0 +1,153 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package builtin_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/apparmor"
+	"github.com/snapcore/snapd/interfaces/builtin"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snaptest"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type AspectsInterfaceSuite struct {
+	iface    interfaces.Interface
+	slotInfo *snap.SlotInfo
+	slot     *interfaces.ConnectedSlot
+	plugInfo *snap.PlugInfo
+	plug     *interfaces.ConnectedPlug
+}
+
+var _ = Suite(&AspectsInterfaceSuite{
+	iface: builtin.MustInterface("aspects"),
+})
+
+const aspectsConsumerYaml = `name: consumer
+version: 0
+plugs:
+ wifi-setup:
+  interface: aspects
+  account: system
+  bundle: network
+  aspect: wifi-setup
+apps:
+ app:
+  command: foo
+  plugs: [wifi-setup]
+`
+
+func (s *AspectsInterfaceSuite) SetUpTest(c *C) {
+	consumingSnapInfo := snaptest.MockInfo(c, aspectsConsumerYaml, nil)
+	s.slotInfo = &snap.SlotInfo{
+		Snap:      &snap.Info{SuggestedName: "core", SnapType: snap.TypeOS},
+		Name:      "aspects",
+		Interface: "aspects",
+	}
+	s.slot = interfaces.NewConnectedSlot(s.slotInfo, nil, nil)
+	s.plugInfo = consumingSnapInfo.Plugs["wifi-setup"]
+	s.plug = interfaces.NewConnectedPlug(s.plugInfo, nil, nil)
+}
+
+func (s *AspectsInterfaceSuite) TestName(c *C) {
+	c.Assert(s.iface.Name(), Equals, "aspects")
+}
+
+func (s *AspectsInterfaceSuite) TestSanitizeSlot(c *C) {
+	c.Assert(interfaces.BeforePrepareSlot(s.iface, s.slotInfo), IsNil)
+}
+
+func (s *AspectsInterfaceSuite) TestSanitizePlug(c *C) {
+	c.Assert(interfaces.BeforePreparePlug(s.iface, s.plugInfo), IsNil)
+}
+
+func (s *AspectsInterfaceSuite) TestSanitizePlugWithManagerRole(c *C) {
+	const mockSnapYaml = `name: consumer
+version: 0
+plugs:
+ wifi-setup:
+  interface: aspects
+  account: system
+  bundle: network
+  aspect: wifi-setup
+  role: manager
+`
+	info := snaptest.MockInfo(c, mockSnapYaml, nil)
+	c.Assert(interfaces.BeforePreparePlug(s.iface, info.Plugs["wifi-setup"]), IsNil)
+}
+
+func (s *AspectsInterfaceSuite) TestSanitizePlugInvalidAttrs(c *C) {
+	type testcase struct {
+		attrs string
+		err   string
+	}
+
+	for _, tc := range []testcase{
+		{
+			attrs: "  bundle: network\n  aspect: wifi-setup\n",
+			err:   `aspects plug must have a valid "account" attribute`,
+		},
+		{
+			attrs: "  account: _\n  bundle: network\n  aspect: wifi-setup\n",
+			err:   `aspects plug must have a valid "account" attribute: format mismatch`,
+		},
+		{
+			attrs: "  account: system\n  aspect: wifi-setup\n",
+			err:   `aspects plug must have a valid "bundle" attribute`,
+		},
+		{
+			attrs: "  account: system\n  bundle: Network\n  aspect: wifi-setup\n",
+			err:   `aspects plug must have a valid "bundle" attribute: format mismatch`,
+		},
+		{
+			attrs: "  account: system\n  bundle: network\n  aspect: [wifi-setup]\n",
+			err:   `aspects plug must have a valid "aspect" attribute`,
+		},
+		{
+			attrs: "  account: system\n  bundle: network\n  aspect: wifi-setup\n  role: owner\n",
+			err:   `aspects plug has invalid "role" attribute: expected "manager" but got owner`,
+		},
+	} {
+		info := snaptest.MockInfo(c, "name: consumer\nversion: 0\nplugs:\n wifi-setup:\n  interface: aspects\n"+tc.attrs, nil)
+		err := interfaces.BeforePreparePlug(s.iface, info.Plugs["wifi-setup"])
+		c.Check(err, ErrorMatches, tc.err, Commentf("attrs: %s", tc.attrs))
+	}
+}
+
+func (s *AspectsInterfaceSuite) TestNoAppArmorRules(c *C) {
+	apparmorSpec := &apparmor.Specification{}
+	err := apparmorSpec.AddConnectedPlug(s.iface, s.plug, s.slot)
+	c.Assert(err, IsNil)
+	c.Assert(apparmorSpec.SecurityTags(), HasLen, 0)
+}
+
+func (s *AspectsInterfaceSuite) TestStaticInfo(c *C) {
+	si := interfaces.StaticInfoOf(s.iface)
+	c.Assert(si.ImplicitOnCore, Equals, true)
+	c.Assert(si.ImplicitOnClassic, Equals, true)
+	c.Assert(si.Summary, Equals, `allows access to aspects of configuration`)
+	c.Assert(si.BaseDeclarationSlots, testutil.Contains, "aspects")
+}
+
+func (s *AspectsInterfaceSuite) TestInterfaces(c *C) {
+	c.Check(builtin.Interfaces(), testutil.DeepContains, s.iface)
+}
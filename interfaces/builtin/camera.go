@@ -19,6 +19,18 @@
 
 package builtin
 
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/apparmor"
+	"github.com/snapcore/snapd/interfaces/hotplug"
+	"github.com/snapcore/snapd/interfaces/udev"
+	"github.com/snapcore/snapd/snap"
+)
+
 const cameraSummary = `allows access to all cameras`
 
 const cameraBaseDeclarationSlots = `
@@ -35,7 +47,9 @@ const cameraConnectedPlugAppArmor = `
 
 # VideoCore cameras (shared device with VideoCore/EGL)
 /dev/vchiq rw,
+` + cameraConnectedPlugDetectionAppArmor
 
+const cameraConnectedPlugDetectionAppArmor = `
 # Allow detection of cameras. Leaks plugged in USB device info
 /sys/bus/usb/devices/ r,
 /sys/devices/pci**/usb*/**/busnum r,
@@ -57,8 +71,79 @@ var cameraConnectedPlugUDev = []string{
 	`KERNEL=="vchiq"`,
 }
 
+// cameraDeviceNodePattern matches the video4linux device nodes of cameras
+// proposed as hotplug slots.
+var cameraDeviceNodePattern = regexp.MustCompile(`^/dev/video[0-9]+$`)
+
+// cameraInterface grants access to all cameras through the implicit slot
+// of the system snap, and to a single camera through the slots created for
+// hotplugged cameras, which carry the path of the camera device node.
+type cameraInterface struct {
+	commonInterface
+}
+
+// BeforePrepareSlot checks the device node of the slots of hotplugged
+// cameras, the implicit slot has none.
+func (iface *cameraInterface) BeforePrepareSlot(slot *snap.SlotInfo) error {
+	if _, ok := slot.Lookup("path"); !ok {
+		return nil
+	}
+	_, err := verifySlotPathAttribute(&interfaces.SlotRef{Snap: slot.Snap.InstanceName(), Name: slot.Name}, slot, cameraDeviceNodePattern, invalidDeviceNodeSlotPathErrFmt)
+	return err
+}
+
+func (iface *cameraInterface) AppArmorConnectedPlug(spec *apparmor.Specification, plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) error {
+	if _, ok := slot.Lookup("path"); !ok {
+		return iface.commonInterface.AppArmorConnectedPlug(spec, plug, slot)
+	}
+	path, err := verifySlotPathAttribute(slot.Ref(), slot, cameraDeviceNodePattern, invalidDeviceNodeSlotPathErrFmt)
+	if err != nil {
+		return nil
+	}
+	spec.AddSnippet(fmt.Sprintf("%s rw,\n", path))
+	spec.AddSnippet(cameraConnectedPlugDetectionAppArmor)
+	return nil
+}
+
+func (iface *cameraInterface) UDevConnectedPlug(spec *udev.Specification, plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) error {
+	if _, ok := slot.Lookup("path"); !ok {
+		return iface.commonInterface.UDevConnectedPlug(spec, plug, slot)
+	}
+	path, err := verifySlotPathAttribute(slot.Ref(), slot, cameraDeviceNodePattern, invalidDeviceNodeSlotPathErrFmt)
+	if err != nil {
+		return nil
+	}
+	spec.TagDevice(fmt.Sprintf(`SUBSYSTEM=="video4linux", KERNEL=="%s"`, strings.TrimPrefix(path, "/dev/")))
+	return nil
+}
+
+func (iface *cameraInterface) HotplugDeviceDetected(di *hotplug.HotplugDeviceInfo) (*hotplug.ProposedSlot, error) {
+	if di.Subsystem() != "video4linux" || !cameraDeviceNodePattern.MatchString(di.DeviceName()) {
+		return nil, nil
+	}
+	// video4linux devices also include metadata and output nodes, only
+	// propose slots for nodes that are able to capture video
+	if caps, _ := di.Attribute("ID_V4L_CAPABILITIES"); !strings.Contains(caps, ":capture:") {
+		return nil, nil
+	}
+
+	slot := hotplug.ProposedSlot{
+		Label: di.ShortString(),
+		Attrs: map[string]interface{}{
+			"path": di.DeviceName(),
+		},
+	}
+	addUsbHotplugAttrs(di, slot.Attrs)
+	return &slot, nil
+}
+
+func (iface *cameraInterface) HotplugKey(di *hotplug.HotplugDeviceInfo) (snap.HotplugKey, error) {
+	// a single USB camera may expose several capture nodes
+	return usbHotplugKey(di, "ID_USB_INTERFACE_NUM")
+}
+
 func init() {
-	registerIface(&commonInterface{
+	registerIface(&cameraInterface{commonInterface{
 		name:                  "camera",
 		summary:               cameraSummary,
 		implicitOnCore:        true,
@@ -66,5 +151,5 @@ func init() {
 		baseDeclarationSlots:  cameraBaseDeclarationSlots,
 		connectedPlugAppArmor: cameraConnectedPlugAppArmor,
 		connectedPlugUDev:     cameraConnectedPlugUDev,
-	})
+	}})
 }
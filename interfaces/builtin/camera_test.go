@@ -21,6 +21,7 @@ package builtin_test
 
 import (
 	"fmt"
+	"strings"
 
 	. "gopkg.in/check.v1"
 
@@ -28,17 +29,20 @@ import (
 	"github.com/snapcore/snapd/interfaces"
 	"github.com/snapcore/snapd/interfaces/apparmor"
 	"github.com/snapcore/snapd/interfaces/builtin"
+	"github.com/snapcore/snapd/interfaces/hotplug"
 	"github.com/snapcore/snapd/interfaces/udev"
 	"github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/testutil"
 )
 
 type CameraInterfaceSuite struct {
-	iface    interfaces.Interface
-	slot     *interfaces.ConnectedSlot
-	slotInfo *snap.SlotInfo
-	plug     *interfaces.ConnectedPlug
-	plugInfo *snap.PlugInfo
+	iface           interfaces.Interface
+	slot            *interfaces.ConnectedSlot
+	slotInfo        *snap.SlotInfo
+	hotplugSlot     *interfaces.ConnectedSlot
+	hotplugSlotInfo *snap.SlotInfo
+	plug            *interfaces.ConnectedPlug
+	plugInfo        *snap.PlugInfo
 }
 
 var _ = Suite(&CameraInterfaceSuite{
@@ -59,9 +63,21 @@ slots:
   camera:
 `
 
+const cameraHotplugCoreYaml = `name: core
+version: 0
+type: os
+slots:
+  webcam:
+    interface: camera
+    path: /dev/video2
+    usb-vendor: 0x046d
+    usb-product: 0x0825
+`
+
 func (s *CameraInterfaceSuite) SetUpTest(c *C) {
 	s.plug, s.plugInfo = MockConnectedPlug(c, cameraConsumerYaml, nil, "camera")
 	s.slot, s.slotInfo = MockConnectedSlot(c, cameraCoreYaml, nil, "camera")
+	s.hotplugSlot, s.hotplugSlotInfo = MockConnectedSlot(c, cameraHotplugCoreYaml, nil, "webcam")
 }
 
 func (s *CameraInterfaceSuite) TestName(c *C) {
@@ -70,6 +86,33 @@ func (s *CameraInterfaceSuite) TestName(c *C) {
 
 func (s *CameraInterfaceSuite) TestSanitizeSlot(c *C) {
 	c.Assert(interfaces.BeforePrepareSlot(s.iface, s.slotInfo), IsNil)
+	c.Assert(interfaces.BeforePrepareSlot(s.iface, s.hotplugSlotInfo), IsNil)
+}
+
+func (s *CameraInterfaceSuite) TestSanitizeSlotUnhappy(c *C) {
+	const mockSnapYaml = `name: core
+version: 0
+type: os
+slots:
+  webcam:
+    interface: camera
+    path: $t
+`
+
+	for _, t := range []struct {
+		path string
+		err  string
+	}{
+		{`""`, `slot "core:webcam" must have a path attribute`},
+		{"/dev/video", `slot "core:webcam" path attribute must be a valid device node`},
+		{"/dev/vchiq", `slot "core:webcam" path attribute must be a valid device node`},
+		{"/dev/video0*", `slot "core:webcam" path attribute must be a valid device node`},
+		{`"/dev/video0\" rw,\n/** rw"`, `slot "core:webcam" path attribute must be a valid device node`},
+		{"/dev/../dev/video0", `cannot use slot "core:webcam" path "/dev/../dev/video0": try ".*"`},
+	} {
+		_, slotInfo := MockConnectedSlot(c, strings.Replace(mockSnapYaml, "$t", t.path, -1), nil, "webcam")
+		c.Check(interfaces.BeforePrepareSlot(s.iface, slotInfo), ErrorMatches, t.err, Commentf("unexpected error for %q", t.path))
+	}
 }
 
 func (s *CameraInterfaceSuite) TestSanitizePlug(c *C) {
@@ -94,6 +137,106 @@ KERNEL=="vchiq", TAG+="snap_consumer_app"`)
 	c.Assert(spec.Snippets(), testutil.Contains, fmt.Sprintf(`TAG=="snap_consumer_app", RUN+="%v/snap-device-helper $env{ACTION} snap_consumer_app $devpath $major:$minor"`, dirs.DistroLibExecDir))
 }
 
+func (s *CameraInterfaceSuite) TestAppArmorSpecHotplugSlot(c *C) {
+	spec := &apparmor.Specification{}
+	c.Assert(spec.AddConnectedPlug(s.iface, s.plug, s.hotplugSlot), IsNil)
+	c.Assert(spec.SecurityTags(), DeepEquals, []string{"snap.consumer.app"})
+	c.Check(spec.SnippetForTag("snap.consumer.app"), testutil.Contains, "/dev/video2 rw,\n")
+	c.Check(spec.SnippetForTag("snap.consumer.app"), testutil.Contains, "/sys/class/video4linux/ r,\n")
+	c.Check(spec.SnippetForTag("snap.consumer.app"), Not(testutil.Contains), "/dev/video[0-9]* rw")
+	c.Check(spec.SnippetForTag("snap.consumer.app"), Not(testutil.Contains), "/dev/vchiq rw")
+}
+
+func (s *CameraInterfaceSuite) TestUDevSpecHotplugSlot(c *C) {
+	spec := &udev.Specification{}
+	c.Assert(spec.AddConnectedPlug(s.iface, s.plug, s.hotplugSlot), IsNil)
+	c.Assert(spec.Snippets(), HasLen, 2)
+	c.Assert(spec.Snippets(), testutil.Contains, `# camera
+SUBSYSTEM=="video4linux", KERNEL=="video2", TAG+="snap_consumer_app"`)
+	c.Assert(spec.Snippets(), testutil.Contains, fmt.Sprintf(`TAG=="snap_consumer_app", RUN+="%v/snap-device-helper $env{ACTION} snap_consumer_app $devpath $major:$minor"`, dirs.DistroLibExecDir))
+}
+
+func (s *CameraInterfaceSuite) TestConnectedPlugInvalidHotplugSlot(c *C) {
+	const mockSnapYaml = `name: core
+version: 0
+type: os
+slots:
+  webcam:
+    interface: camera
+    path: /dev/video0*
+`
+	slot, _ := MockConnectedSlot(c, mockSnapYaml, nil, "webcam")
+
+	apparmorSpec := &apparmor.Specification{}
+	c.Assert(apparmorSpec.AddConnectedPlug(s.iface, s.plug, slot), IsNil)
+	c.Check(apparmorSpec.SecurityTags(), HasLen, 0)
+
+	udevSpec := &udev.Specification{}
+	c.Assert(udevSpec.AddConnectedPlug(s.iface, s.plug, slot), IsNil)
+	c.Check(udevSpec.Snippets(), HasLen, 0)
+}
+
+func (s *CameraInterfaceSuite) TestHotplugDeviceDetected(c *C) {
+	hotplugIface := s.iface.(hotplug.Definer)
+	di, err := hotplug.NewHotplugDeviceInfo(map[string]string{"DEVPATH": "/sys/foo/bar", "DEVNAME": "/dev/video2", "ID_VENDOR_ID": "046d", "ID_MODEL_ID": "0825", "ID_MODEL": "Webcam_C270", "ID_SERIAL_SHORT": "2A3F1B40", "ID_V4L_CAPABILITIES": ":capture:", "ACTION": "add", "SUBSYSTEM": "video4linux"})
+	c.Assert(err, IsNil)
+	proposedSlot, err := hotplugIface.HotplugDeviceDetected(di)
+	c.Assert(err, IsNil)
+	c.Assert(proposedSlot, DeepEquals, &hotplug.ProposedSlot{
+		Label: "/dev/video2 (Webcam_C270; serial: 2A3F1B40)",
+		Attrs: map[string]interface{}{
+			"path":        "/dev/video2",
+			"usb-vendor":  int64(0x046d),
+			"usb-product": int64(0x0825),
+			"serial":      "2A3F1B40",
+		},
+	})
+}
+
+func (s *CameraInterfaceSuite) TestHotplugDeviceDetectedNotCamera(c *C) {
+	hotplugIface := s.iface.(hotplug.Definer)
+	for _, env := range []map[string]string{
+		// not a video4linux device
+		{"DEVPATH": "/sys/foo/bar", "DEVNAME": "/dev/ttyUSB0", "ID_V4L_CAPABILITIES": ":capture:", "ACTION": "add", "SUBSYSTEM": "tty"},
+		// not a video device node
+		{"DEVPATH": "/sys/foo/bar", "DEVNAME": "/dev/v4l-subdev0", "ID_V4L_CAPABILITIES": ":capture:", "ACTION": "add", "SUBSYSTEM": "video4linux"},
+		// metadata node of a camera
+		{"DEVPATH": "/sys/foo/bar", "DEVNAME": "/dev/video3", "ID_V4L_CAPABILITIES": ":", "ACTION": "add", "SUBSYSTEM": "video4linux"},
+	} {
+		di, err := hotplug.NewHotplugDeviceInfo(env)
+		c.Assert(err, IsNil)
+		proposedSlot, err := hotplugIface.HotplugDeviceDetected(di)
+		c.Assert(err, IsNil)
+		c.Check(proposedSlot, IsNil, Commentf("%v", env))
+	}
+}
+
+func (s *CameraInterfaceSuite) TestHotplugKey(c *C) {
+	keyHandler := s.iface.(hotplug.HotplugKeyHandler)
+	key := func(env map[string]string) snap.HotplugKey {
+		env["DEVPATH"] = "/sys/foo/bar"
+		env["ACTION"] = "add"
+		env["SUBSYSTEM"] = "video4linux"
+		di, err := hotplug.NewHotplugDeviceInfo(env)
+		c.Assert(err, IsNil)
+		key, err := keyHandler.HotplugKey(di)
+		c.Assert(err, IsNil)
+		return key
+	}
+
+	key1 := key(map[string]string{"DEVNAME": "/dev/video0", "ID_VENDOR_ID": "046d", "ID_MODEL_ID": "0825", "ID_SERIAL_SHORT": "2A3F1B40", "ID_USB_INTERFACE_NUM": "00"})
+	c.Check(key1, Not(Equals), snap.HotplugKey(""))
+	// the key does not depend on the device node
+	c.Check(key(map[string]string{"DEVNAME": "/dev/video4", "ID_VENDOR_ID": "046d", "ID_MODEL_ID": "0825", "ID_SERIAL_SHORT": "2A3F1B40", "ID_USB_INTERFACE_NUM": "00"}), Equals, key1)
+	// but on the serial and the interface number
+	c.Check(key(map[string]string{"DEVNAME": "/dev/video0", "ID_VENDOR_ID": "046d", "ID_MODEL_ID": "0825", "ID_SERIAL_SHORT": "11111111", "ID_USB_INTERFACE_NUM": "00"}), Not(Equals), key1)
+	c.Check(key(map[string]string{"DEVNAME": "/dev/video0", "ID_VENDOR_ID": "046d", "ID_MODEL_ID": "0825", "ID_SERIAL_SHORT": "2A3F1B40", "ID_USB_INTERFACE_NUM": "02"}), Not(Equals), key1)
+	// the physical path is used for devices without a serial number
+	c.Check(key(map[string]string{"DEVNAME": "/dev/video0", "ID_VENDOR_ID": "046d", "ID_MODEL_ID": "0825", "ID_PATH": "pci-0000:00:14.0-usb-0:2:1.0"}), Not(Equals), snap.HotplugKey(""))
+	// the default key is used for non-USB cameras
+	c.Check(key(map[string]string{"DEVNAME": "/dev/video0"}), Equals, snap.HotplugKey(""))
+}
+
 func (s *CameraInterfaceSuite) TestStaticInfo(c *C) {
 	si := interfaces.StaticInfoOf(s.iface)
 	c.Assert(si.ImplicitOnCore, Equals, true)
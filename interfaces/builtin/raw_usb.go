@@ -19,6 +19,18 @@
 
 package builtin
 
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/apparmor"
+	"github.com/snapcore/snapd/interfaces/hotplug"
+	"github.com/snapcore/snapd/interfaces/udev"
+	"github.com/snapcore/snapd/snap"
+)
+
 const rawusbSummary = `allows raw access to all USB devices`
 
 const rawusbBaseDeclarationSlots = `
@@ -40,7 +52,9 @@ const rawusbConnectedPlugAppArmor = `
 
 # Allow raw access to USB printers (i.e. for receipt printers in POS systems).
 /dev/usb/lp[0-9]* rwk,
+` + rawusbConnectedPlugDetectionAppArmor
 
+const rawusbConnectedPlugDetectionAppArmor = `
 # Allow detection of usb devices. Leaks plugged in USB device info
 /sys/bus/usb/devices/ r,
 /sys/devices/pci**/usb[0-9]** r,
@@ -67,8 +81,104 @@ var rawusbConnectedPlugUDev = []string{
 	`SUBSYSTEM=="tty", ENV{ID_BUS}=="usb"`,
 }
 
+// rawusbDeviceNodePattern matches the usbfs device nodes of USB devices
+// proposed as hotplug slots.
+var rawusbDeviceNodePattern = regexp.MustCompile(`^/dev/bus/usb/[0-9]{3}/[0-9]{3}$`)
+
+// rawUsbInterface grants raw access to all USB devices through the implicit
+// slot of the system snap, and to a single USB device through the slots
+// created for hotplugged devices, which carry the path of the usbfs device
+// node along with the vendor and product IDs of the device.
+type rawUsbInterface struct {
+	commonInterface
+}
+
+// BeforePrepareSlot checks the device node and the serial number of the
+// slots of hotplugged USB devices, the implicit slot has neither.
+func (iface *rawUsbInterface) BeforePrepareSlot(slot *snap.SlotInfo) error {
+	if _, ok := slot.Lookup("path"); !ok {
+		return nil
+	}
+	slotRef := &interfaces.SlotRef{Snap: slot.Snap.InstanceName(), Name: slot.Name}
+	if _, err := verifySlotPathAttribute(slotRef, slot, rawusbDeviceNodePattern, invalidDeviceNodeSlotPathErrFmt); err != nil {
+		return err
+	}
+	_, err := verifySlotSerialAttribute(slotRef, slot)
+	return err
+}
+
+func (iface *rawUsbInterface) AppArmorConnectedPlug(spec *apparmor.Specification, plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) error {
+	if _, ok := slot.Lookup("path"); !ok {
+		return iface.commonInterface.AppArmorConnectedPlug(spec, plug, slot)
+	}
+	path, err := verifySlotPathAttribute(slot.Ref(), slot, rawusbDeviceNodePattern, invalidDeviceNodeSlotPathErrFmt)
+	if err != nil {
+		return nil
+	}
+	spec.AddSnippet(fmt.Sprintf("%s rw,\n", path))
+	spec.AddSnippet(rawusbConnectedPlugDetectionAppArmor)
+	return nil
+}
+
+func (iface *rawUsbInterface) UDevConnectedPlug(spec *udev.Specification, plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) error {
+	if _, ok := slot.Lookup("path"); !ok {
+		return iface.commonInterface.UDevConnectedPlug(spec, plug, slot)
+	}
+	if _, err := verifySlotPathAttribute(slot.Ref(), slot, rawusbDeviceNodePattern, invalidDeviceNodeSlotPathErrFmt); err != nil {
+		return nil
+	}
+	serial, err := verifySlotSerialAttribute(slot.Ref(), slot)
+	if err != nil {
+		return nil
+	}
+	var usbVendor, usbProduct int64
+	if err := slot.Attr("usb-vendor", &usbVendor); err != nil {
+		return nil
+	}
+	if err := slot.Attr("usb-product", &usbProduct); err != nil {
+		return nil
+	}
+	rule := fmt.Sprintf(`SUBSYSTEM=="usb", ENV{DEVTYPE}=="usb_device", ATTR{idVendor}=="%04x", ATTR{idProduct}=="%04x"`, usbVendor, usbProduct)
+	if serial != "" {
+		rule += fmt.Sprintf(`, ATTR{serial}=="%s"`, serial)
+	}
+	spec.TagDevice(rule)
+	return nil
+}
+
+func (iface *rawUsbInterface) HotplugDeviceDetected(di *hotplug.HotplugDeviceInfo) (*hotplug.ProposedSlot, error) {
+	devtype, _ := di.Attribute("DEVTYPE")
+	if di.Subsystem() != "usb" || devtype != "usb_device" || !rawusbDeviceNodePattern.MatchString(di.DeviceName()) {
+		return nil, nil
+	}
+	// hubs are not interesting on their own, and the root hubs are
+	// always present
+	if ifaces, _ := di.Attribute("ID_USB_INTERFACES"); strings.Contains(ifaces, ":09") {
+		return nil, nil
+	}
+
+	slot := hotplug.ProposedSlot{
+		Label: di.ShortString(),
+		Attrs: map[string]interface{}{
+			"path": di.DeviceName(),
+		},
+	}
+	addUsbHotplugAttrs(di, slot.Attrs)
+	if _, ok := slot.Attrs["usb-vendor"]; !ok {
+		return nil, nil
+	}
+	if _, ok := slot.Attrs["usb-product"]; !ok {
+		return nil, nil
+	}
+	return &slot, nil
+}
+
+func (iface *rawUsbInterface) HotplugKey(di *hotplug.HotplugDeviceInfo) (snap.HotplugKey, error) {
+	return usbHotplugKey(di)
+}
+
 func init() {
-	registerIface(&commonInterface{
+	registerIface(&rawUsbInterface{commonInterface{
 		name:                  "raw-usb",
 		summary:               rawusbSummary,
 		implicitOnCore:        true,
@@ -77,5 +187,5 @@ func init() {
 		connectedPlugAppArmor: rawusbConnectedPlugAppArmor,
 		connectedPlugSecComp:  rawusbConnectedPlugSecComp,
 		connectedPlugUDev:     rawusbConnectedPlugUDev,
-	})
+	}})
 }
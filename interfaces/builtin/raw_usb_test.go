@@ -21,6 +21,7 @@ package builtin_test
 
 import (
 	"fmt"
+	"strings"
 
 	. "gopkg.in/check.v1"
 
@@ -28,6 +29,7 @@ import (
 	"github.com/snapcore/snapd/interfaces"
 	"github.com/snapcore/snapd/interfaces/apparmor"
 	"github.com/snapcore/snapd/interfaces/builtin"
+	"github.com/snapcore/snapd/interfaces/hotplug"
 	"github.com/snapcore/snapd/interfaces/seccomp"
 	"github.com/snapcore/snapd/interfaces/udev"
 	"github.com/snapcore/snapd/snap"
@@ -35,11 +37,13 @@ import (
 )
 
 type RawUsbInterfaceSuite struct {
-	iface    interfaces.Interface
-	slotInfo *snap.SlotInfo
-	slot     *interfaces.ConnectedSlot
-	plugInfo *snap.PlugInfo
-	plug     *interfaces.ConnectedPlug
+	iface           interfaces.Interface
+	slotInfo        *snap.SlotInfo
+	slot            *interfaces.ConnectedSlot
+	hotplugSlotInfo *snap.SlotInfo
+	hotplugSlot     *interfaces.ConnectedSlot
+	plugInfo        *snap.PlugInfo
+	plug            *interfaces.ConnectedPlug
 }
 
 var _ = Suite(&RawUsbInterfaceSuite{
@@ -60,9 +64,27 @@ slots:
   raw-usb:
 `
 
+const rawusbHotplugCoreYaml = `name: core
+version: 0
+type: os
+slots:
+  token:
+    interface: raw-usb
+    path: /dev/bus/usb/001/004
+    usb-vendor: 0x1050
+    usb-product: 0x0407
+    serial: "0001234567"
+  token-no-serial:
+    interface: raw-usb
+    path: /dev/bus/usb/001/005
+    usb-vendor: 0x1050
+    usb-product: 0x0407
+`
+
 func (s *RawUsbInterfaceSuite) SetUpTest(c *C) {
 	s.plug, s.plugInfo = MockConnectedPlug(c, rawusbConsumerYaml, nil, "raw-usb")
 	s.slot, s.slotInfo = MockConnectedSlot(c, rawusbCoreYaml, nil, "raw-usb")
+	s.hotplugSlot, s.hotplugSlotInfo = MockConnectedSlot(c, rawusbHotplugCoreYaml, nil, "token")
 }
 
 func (s *RawUsbInterfaceSuite) TestName(c *C) {
@@ -71,6 +93,41 @@ func (s *RawUsbInterfaceSuite) TestName(c *C) {
 
 func (s *RawUsbInterfaceSuite) TestSanitizeSlot(c *C) {
 	c.Assert(interfaces.BeforePrepareSlot(s.iface, s.slotInfo), IsNil)
+	c.Assert(interfaces.BeforePrepareSlot(s.iface, s.hotplugSlotInfo), IsNil)
+}
+
+func (s *RawUsbInterfaceSuite) TestSanitizeSlotUnhappy(c *C) {
+	const mockSnapYaml = `name: core
+version: 0
+type: os
+slots:
+  token:
+    interface: raw-usb
+    path: $path
+    usb-vendor: 0x1050
+    usb-product: 0x0407
+    serial: $serial
+`
+
+	for _, t := range []struct {
+		path   string
+		serial string
+		err    string
+	}{
+		{`""`, "abc", `slot "core:token" must have a path attribute`},
+		{"/dev/bus/usb/1/4", "abc", `slot "core:token" path attribute must be a valid device node`},
+		{"/dev/bus/usb/001/004/", "abc", `cannot use slot "core:token" path "/dev/bus/usb/001/004/": try ".*"`},
+		{"/dev/bus/usb/001/*", "abc", `slot "core:token" path attribute must be a valid device node`},
+		{"/dev/ttyUSB0", "abc", `slot "core:token" path attribute must be a valid device node`},
+		{"/dev/bus/usb/001/004", `""`, `slot "core:token" serial attribute must only contain letters, digits, dots, dashes and underscores`},
+		{"/dev/bus/usb/001/004", `"abc\", RUN+=\"/bin/sh"`, `slot "core:token" serial attribute must only contain letters, digits, dots, dashes and underscores`},
+		{"/dev/bus/usb/001/004", `"a b"`, `slot "core:token" serial attribute must only contain letters, digits, dots, dashes and underscores`},
+		{"/dev/bus/usb/001/004", "[1, 2]", `slot "core:token" serial attribute must only contain letters, digits, dots, dashes and underscores`},
+	} {
+		yaml := strings.NewReplacer("$path", t.path, "$serial", t.serial).Replace(mockSnapYaml)
+		_, slotInfo := MockConnectedSlot(c, yaml, nil, "token")
+		c.Check(interfaces.BeforePrepareSlot(s.iface, slotInfo), ErrorMatches, t.err, Commentf("unexpected error for %q %q", t.path, t.serial))
+	}
 }
 
 func (s *RawUsbInterfaceSuite) TestSanitizePlug(c *C) {
@@ -104,6 +161,112 @@ SUBSYSTEM=="tty", ENV{ID_BUS}=="usb", TAG+="snap_consumer_app"`)
 	c.Assert(spec.Snippets(), testutil.Contains, fmt.Sprintf(`TAG=="snap_consumer_app", RUN+="%v/snap-device-helper $env{ACTION} snap_consumer_app $devpath $major:$minor"`, dirs.DistroLibExecDir))
 }
 
+func (s *RawUsbInterfaceSuite) TestAppArmorSpecHotplugSlot(c *C) {
+	spec := &apparmor.Specification{}
+	c.Assert(spec.AddConnectedPlug(s.iface, s.plug, s.hotplugSlot), IsNil)
+	c.Assert(spec.SecurityTags(), DeepEquals, []string{"snap.consumer.app"})
+	c.Check(spec.SnippetForTag("snap.consumer.app"), testutil.Contains, "/dev/bus/usb/001/004 rw,\n")
+	c.Check(spec.SnippetForTag("snap.consumer.app"), testutil.Contains, `/sys/bus/usb/devices/`)
+	c.Check(spec.SnippetForTag("snap.consumer.app"), Not(testutil.Contains), "/dev/bus/usb/[0-9][0-9][0-9]/[0-9][0-9][0-9] rw")
+	c.Check(spec.SnippetForTag("snap.consumer.app"), Not(testutil.Contains), "/dev/tty{USB,ACM}[0-9]* rwk")
+}
+
+func (s *RawUsbInterfaceSuite) TestUDevSpecHotplugSlot(c *C) {
+	spec := &udev.Specification{}
+	c.Assert(spec.AddConnectedPlug(s.iface, s.plug, s.hotplugSlot), IsNil)
+	c.Assert(spec.Snippets(), HasLen, 2)
+	c.Assert(spec.Snippets(), testutil.Contains, `# raw-usb
+SUBSYSTEM=="usb", ENV{DEVTYPE}=="usb_device", ATTR{idVendor}=="1050", ATTR{idProduct}=="0407", ATTR{serial}=="0001234567", TAG+="snap_consumer_app"`)
+	c.Assert(spec.Snippets(), testutil.Contains, fmt.Sprintf(`TAG=="snap_consumer_app", RUN+="%v/snap-device-helper $env{ACTION} snap_consumer_app $devpath $major:$minor"`, dirs.DistroLibExecDir))
+
+	slot, _ := MockConnectedSlot(c, rawusbHotplugCoreYaml, nil, "token-no-serial")
+	spec = &udev.Specification{}
+	c.Assert(spec.AddConnectedPlug(s.iface, s.plug, slot), IsNil)
+	c.Assert(spec.Snippets(), testutil.Contains, `# raw-usb
+SUBSYSTEM=="usb", ENV{DEVTYPE}=="usb_device", ATTR{idVendor}=="1050", ATTR{idProduct}=="0407", TAG+="snap_consumer_app"`)
+}
+
+func (s *RawUsbInterfaceSuite) TestUDevSpecInvalidHotplugSlot(c *C) {
+	const mockSnapYaml = `name: core
+version: 0
+type: os
+slots:
+  token:
+    interface: raw-usb
+    path: /dev/bus/usb/001/004
+    usb-vendor: 0x1050
+    usb-product: 0x0407
+    serial: "abc\", RUN+=\"/bin/sh"
+`
+	slot, _ := MockConnectedSlot(c, mockSnapYaml, nil, "token")
+
+	spec := &udev.Specification{}
+	c.Assert(spec.AddConnectedPlug(s.iface, s.plug, slot), IsNil)
+	c.Check(spec.Snippets(), HasLen, 0)
+}
+
+func (s *RawUsbInterfaceSuite) TestHotplugDeviceDetectedUnsafeSerial(c *C) {
+	hotplugIface := s.iface.(hotplug.Definer)
+	di, err := hotplug.NewHotplugDeviceInfo(map[string]string{"DEVPATH": "/sys/foo/bar", "DEVNAME": "/dev/bus/usb/001/004", "DEVTYPE": "usb_device", "ID_VENDOR_ID": "1050", "ID_MODEL_ID": "0407", "ID_MODEL": "YubiKey", "ID_SERIAL_SHORT": `abc", RUN+="/bin/sh`, "ID_USB_INTERFACES": ":030000:0b0000:", "ACTION": "add", "SUBSYSTEM": "usb"})
+	c.Assert(err, IsNil)
+	proposedSlot, err := hotplugIface.HotplugDeviceDetected(di)
+	c.Assert(err, IsNil)
+	c.Assert(proposedSlot, NotNil)
+	c.Check(proposedSlot.Attrs["serial"], IsNil)
+}
+
+func (s *RawUsbInterfaceSuite) TestHotplugDeviceDetected(c *C) {
+	hotplugIface := s.iface.(hotplug.Definer)
+	di, err := hotplug.NewHotplugDeviceInfo(map[string]string{"DEVPATH": "/sys/foo/bar", "DEVNAME": "/dev/bus/usb/001/004", "DEVTYPE": "usb_device", "ID_VENDOR_ID": "1050", "ID_MODEL_ID": "0407", "ID_MODEL": "YubiKey", "ID_USB_INTERFACES": ":030000:0b0000:", "ACTION": "add", "SUBSYSTEM": "usb"})
+	c.Assert(err, IsNil)
+	proposedSlot, err := hotplugIface.HotplugDeviceDetected(di)
+	c.Assert(err, IsNil)
+	c.Assert(proposedSlot, DeepEquals, &hotplug.ProposedSlot{
+		Label: "/dev/bus/usb/001/004 (YubiKey)",
+		Attrs: map[string]interface{}{
+			"path":        "/dev/bus/usb/001/004",
+			"usb-vendor":  int64(0x1050),
+			"usb-product": int64(0x0407),
+		},
+	})
+}
+
+func (s *RawUsbInterfaceSuite) TestHotplugDeviceDetectedNotUsbDevice(c *C) {
+	hotplugIface := s.iface.(hotplug.Definer)
+	for _, env := range []map[string]string{
+		// an interface of an usb device
+		{"DEVPATH": "/sys/foo/bar", "DEVTYPE": "usb_interface", "ID_VENDOR_ID": "1050", "ID_MODEL_ID": "0407", "ACTION": "add", "SUBSYSTEM": "usb"},
+		// not an usbfs device node
+		{"DEVPATH": "/sys/foo/bar", "DEVNAME": "/dev/ttyUSB0", "DEVTYPE": "usb_device", "ID_VENDOR_ID": "1050", "ID_MODEL_ID": "0407", "ACTION": "add", "SUBSYSTEM": "usb"},
+		// an usb hub
+		{"DEVPATH": "/sys/foo/bar", "DEVNAME": "/dev/bus/usb/001/001", "DEVTYPE": "usb_device", "ID_VENDOR_ID": "1d6b", "ID_MODEL_ID": "0002", "ID_USB_INTERFACES": ":090000:", "ACTION": "add", "SUBSYSTEM": "usb"},
+		// no vendor and product IDs
+		{"DEVPATH": "/sys/foo/bar", "DEVNAME": "/dev/bus/usb/001/004", "DEVTYPE": "usb_device", "ACTION": "add", "SUBSYSTEM": "usb"},
+	} {
+		di, err := hotplug.NewHotplugDeviceInfo(env)
+		c.Assert(err, IsNil)
+		proposedSlot, err := hotplugIface.HotplugDeviceDetected(di)
+		c.Assert(err, IsNil)
+		c.Check(proposedSlot, IsNil, Commentf("%v", env))
+	}
+}
+
+func (s *RawUsbInterfaceSuite) TestHotplugKey(c *C) {
+	keyHandler := s.iface.(hotplug.HotplugKeyHandler)
+	di, err := hotplug.NewHotplugDeviceInfo(map[string]string{"DEVPATH": "/sys/foo/bar", "DEVNAME": "/dev/bus/usb/001/004", "DEVTYPE": "usb_device", "ID_VENDOR_ID": "1050", "ID_MODEL_ID": "0407", "ID_SERIAL_SHORT": "0001234567", "ACTION": "add", "SUBSYSTEM": "usb"})
+	c.Assert(err, IsNil)
+	key1, err := keyHandler.HotplugKey(di)
+	c.Assert(err, IsNil)
+	c.Check(key1, Not(Equals), snap.HotplugKey(""))
+
+	// the same device plugged in again gets a new device node
+	di, err = hotplug.NewHotplugDeviceInfo(map[string]string{"DEVPATH": "/sys/foo/baz", "DEVNAME": "/dev/bus/usb/001/009", "DEVTYPE": "usb_device", "ID_VENDOR_ID": "1050", "ID_MODEL_ID": "0407", "ID_SERIAL_SHORT": "0001234567", "ACTION": "add", "SUBSYSTEM": "usb"})
+	c.Assert(err, IsNil)
+	key2, err := keyHandler.HotplugKey(di)
+	c.Assert(err, IsNil)
+	c.Check(key2, Equals, key1)
+}
+
 func (s *RawUsbInterfaceSuite) TestStaticInfo(c *C) {
 	si := interfaces.StaticInfoOf(s.iface)
 	c.Assert(si.ImplicitOnCore, Equals, true)
@@ -0,0 +1,140 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package builtin
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/apparmor"
+	"github.com/snapcore/snapd/interfaces/hotplug"
+	"github.com/snapcore/snapd/interfaces/udev"
+	"github.com/snapcore/snapd/snap"
+)
+
+const removableBlockDeviceSummary = `allows access to a specific removable block device`
+
+// removable-block-device grants full access to a whole removable disk, such
+// as an USB stick, along with all its partitions. Slots are only created by
+// the system when such a device is plugged in and connecting them must be
+// done manually.
+const removableBlockDeviceBaseDeclarationSlots = `
+  removable-block-device:
+    allow-installation:
+      slot-snap-type:
+        - core
+    deny-auto-connection: true
+`
+
+const removableBlockDeviceConnectedPlugAppArmorPath = `
+# Description: can access a removable disk and its partitions read/write
+%[1]s rw,
+%[1]s[0-9]* rw,
+
+# allow read access to sysfs and udev for block devices
+@{PROC}/devices r,
+/run/udev/data/b[0-9]*:[0-9]* r,
+/sys/block/ r,
+/sys/devices/**/block/** r,
+`
+
+// Whole SCSI disks sda-sdiv, which includes USB mass storage devices.
+var removableBlockDevicePattern = regexp.MustCompile(`^/dev/sd([a-z]|[a-h][a-z]|i[a-v])$`)
+
+// The type for this interface
+type removableBlockDeviceInterface struct{}
+
+// Getter for the name of this interface
+func (iface *removableBlockDeviceInterface) Name() string {
+	return "removable-block-device"
+}
+
+func (iface *removableBlockDeviceInterface) StaticInfo() interfaces.StaticInfo {
+	return interfaces.StaticInfo{
+		Summary:              removableBlockDeviceSummary,
+		BaseDeclarationSlots: removableBlockDeviceBaseDeclarationSlots,
+	}
+}
+
+func (iface *removableBlockDeviceInterface) String() string {
+	return iface.Name()
+}
+
+// Check validity of the defined slot
+func (iface *removableBlockDeviceInterface) BeforePrepareSlot(slot *snap.SlotInfo) error {
+	_, err := verifySlotPathAttribute(&interfaces.SlotRef{Snap: slot.Snap.InstanceName(), Name: slot.Name}, slot, removableBlockDevicePattern, invalidDeviceNodeSlotPathErrFmt)
+	return err
+}
+
+func (iface *removableBlockDeviceInterface) AppArmorConnectedPlug(spec *apparmor.Specification, plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) error {
+	cleanedPath, err := verifySlotPathAttribute(slot.Ref(), slot, removableBlockDevicePattern, invalidDeviceNodeSlotPathErrFmt)
+	if err != nil {
+		return nil
+	}
+
+	spec.AddSnippet(fmt.Sprintf(removableBlockDeviceConnectedPlugAppArmorPath, cleanedPath))
+
+	return nil
+}
+
+func (iface *removableBlockDeviceInterface) UDevConnectedPlug(spec *udev.Specification, plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) error {
+	cleanedPath, err := verifySlotPathAttribute(slot.Ref(), slot, removableBlockDevicePattern, invalidDeviceNodeSlotPathErrFmt)
+	if err != nil {
+		return nil
+	}
+
+	kernel := strings.TrimPrefix(cleanedPath, "/dev/")
+	spec.TagDevice(fmt.Sprintf(`SUBSYSTEM=="block", KERNEL=="%s"`, kernel))
+	spec.TagDevice(fmt.Sprintf(`SUBSYSTEM=="block", KERNEL=="%s[0-9]*"`, kernel))
+
+	return nil
+}
+
+func (iface *removableBlockDeviceInterface) AutoConnect(*snap.PlugInfo, *snap.SlotInfo) bool {
+	// Allow what is allowed in the declarations
+	return true
+}
+
+func (iface *removableBlockDeviceInterface) HotplugDeviceDetected(di *hotplug.HotplugDeviceInfo) (*hotplug.ProposedSlot, error) {
+	devtype, _ := di.Attribute("DEVTYPE")
+	bus, _ := di.Attribute("ID_BUS")
+	if di.Subsystem() != "block" || devtype != "disk" || bus != "usb" || !removableBlockDevicePattern.MatchString(di.DeviceName()) {
+		return nil, nil
+	}
+
+	slot := hotplug.ProposedSlot{
+		Label: di.ShortString(),
+		Attrs: map[string]interface{}{
+			"path": di.DeviceName(),
+		},
+	}
+	addUsbHotplugAttrs(di, slot.Attrs)
+	return &slot, nil
+}
+
+func (iface *removableBlockDeviceInterface) HotplugKey(di *hotplug.HotplugDeviceInfo) (snap.HotplugKey, error) {
+	return usbHotplugKey(di)
+}
+
+func init() {
+	registerIface(&removableBlockDeviceInterface{})
+}
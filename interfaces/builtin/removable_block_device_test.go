@@ -0,0 +1,198 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package builtin_test
+
+import (
+	"fmt"
+	"strings"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/apparmor"
+	"github.com/snapcore/snapd/interfaces/builtin"
+	"github.com/snapcore/snapd/interfaces/hotplug"
+	"github.com/snapcore/snapd/interfaces/udev"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type removableBlockDeviceInterfaceSuite struct {
+	iface    interfaces.Interface
+	slot     *interfaces.ConnectedSlot
+	slotInfo *snap.SlotInfo
+	plug     *interfaces.ConnectedPlug
+	plugInfo *snap.PlugInfo
+}
+
+var _ = Suite(&removableBlockDeviceInterfaceSuite{
+	iface: builtin.MustInterface("removable-block-device"),
+})
+
+const removableBlockDeviceConsumerYaml = `name: consumer
+version: 0
+apps:
+ app:
+  plugs: [removable-block-device]
+`
+
+const removableBlockDeviceCoreYaml = `name: core
+version: 0
+type: os
+slots:
+  usb-stick:
+    interface: removable-block-device
+    path: /dev/sdb
+    usb-vendor: 0x0781
+    usb-product: 0x5581
+    serial: "4C530001071205117433"
+`
+
+func (s *removableBlockDeviceInterfaceSuite) SetUpTest(c *C) {
+	s.plug, s.plugInfo = MockConnectedPlug(c, removableBlockDeviceConsumerYaml, nil, "removable-block-device")
+	s.slot, s.slotInfo = MockConnectedSlot(c, removableBlockDeviceCoreYaml, nil, "usb-stick")
+}
+
+func (s *removableBlockDeviceInterfaceSuite) TestName(c *C) {
+	c.Assert(s.iface.Name(), Equals, "removable-block-device")
+}
+
+func (s *removableBlockDeviceInterfaceSuite) TestSanitizeSlot(c *C) {
+	c.Assert(interfaces.BeforePrepareSlot(s.iface, s.slotInfo), IsNil)
+}
+
+func (s *removableBlockDeviceInterfaceSuite) TestSanitizeSlotUnhappy(c *C) {
+	const mockSnapYaml = `name: core
+version: 0
+type: os
+slots:
+  usb-stick:
+    interface: removable-block-device
+    path: $t
+`
+
+	for _, t := range []struct {
+		path string
+		err  string
+	}{
+		{`""`, `slot "core:usb-stick" must have a path attribute`},
+		{"/dev/sdb1", `slot "core:usb-stick" path attribute must be a valid device node`},
+		{"/dev/sdiw", `slot "core:usb-stick" path attribute must be a valid device node`},
+		{"/dev/vda", `slot "core:usb-stick" path attribute must be a valid device node`},
+		{"/dev/./sdb", `cannot use slot "core:usb-stick" path "/dev/./sdb": try ".*"`},
+	} {
+		_, slotInfo := MockConnectedSlot(c, strings.Replace(mockSnapYaml, "$t", t.path, -1), nil, "usb-stick")
+		c.Check(interfaces.BeforePrepareSlot(s.iface, slotInfo), ErrorMatches, t.err, Commentf("unexpected error for %q", t.path))
+	}
+}
+
+func (s *removableBlockDeviceInterfaceSuite) TestSanitizePlug(c *C) {
+	c.Assert(interfaces.BeforePreparePlug(s.iface, s.plugInfo), IsNil)
+}
+
+func (s *removableBlockDeviceInterfaceSuite) TestAppArmorSpec(c *C) {
+	spec := &apparmor.Specification{}
+	c.Assert(spec.AddConnectedPlug(s.iface, s.plug, s.slot), IsNil)
+	c.Assert(spec.SecurityTags(), DeepEquals, []string{"snap.consumer.app"})
+	c.Check(spec.SnippetForTag("snap.consumer.app"), testutil.Contains, "/dev/sdb rw,\n")
+	c.Check(spec.SnippetForTag("snap.consumer.app"), testutil.Contains, "/dev/sdb[0-9]* rw,\n")
+	c.Check(spec.SnippetForTag("snap.consumer.app"), testutil.Contains, "/sys/devices/**/block/** r,\n")
+}
+
+func (s *removableBlockDeviceInterfaceSuite) TestUDevSpec(c *C) {
+	spec := &udev.Specification{}
+	c.Assert(spec.AddConnectedPlug(s.iface, s.plug, s.slot), IsNil)
+	c.Assert(spec.Snippets(), HasLen, 3)
+	c.Check(spec.Snippets(), testutil.Contains, `# removable-block-device
+SUBSYSTEM=="block", KERNEL=="sdb", TAG+="snap_consumer_app"`)
+	c.Check(spec.Snippets(), testutil.Contains, `# removable-block-device
+SUBSYSTEM=="block", KERNEL=="sdb[0-9]*", TAG+="snap_consumer_app"`)
+	c.Check(spec.Snippets(), testutil.Contains, fmt.Sprintf(`TAG=="snap_consumer_app", RUN+="%v/snap-device-helper $env{ACTION} snap_consumer_app $devpath $major:$minor"`, dirs.DistroLibExecDir))
+}
+
+func (s *removableBlockDeviceInterfaceSuite) TestHotplugDeviceDetected(c *C) {
+	hotplugIface := s.iface.(hotplug.Definer)
+	di, err := hotplug.NewHotplugDeviceInfo(map[string]string{"DEVPATH": "/sys/foo/bar", "DEVNAME": "/dev/sdb", "DEVTYPE": "disk", "ID_BUS": "usb", "ID_VENDOR_ID": "0781", "ID_MODEL_ID": "5581", "ID_MODEL": "Ultra", "ID_SERIAL_SHORT": "4C530001071205117433", "ACTION": "add", "SUBSYSTEM": "block"})
+	c.Assert(err, IsNil)
+	proposedSlot, err := hotplugIface.HotplugDeviceDetected(di)
+	c.Assert(err, IsNil)
+	c.Assert(proposedSlot, DeepEquals, &hotplug.ProposedSlot{
+		Label: "/dev/sdb (Ultra; serial: 4C530001071205117433)",
+		Attrs: map[string]interface{}{
+			"path":        "/dev/sdb",
+			"usb-vendor":  int64(0x0781),
+			"usb-product": int64(0x5581),
+			"serial":      "4C530001071205117433",
+		},
+	})
+}
+
+func (s *removableBlockDeviceInterfaceSuite) TestHotplugDeviceDetectedNotRemovable(c *C) {
+	hotplugIface := s.iface.(hotplug.Definer)
+	for _, env := range []map[string]string{
+		// a partition
+		{"DEVPATH": "/sys/foo/bar", "DEVNAME": "/dev/sdb1", "DEVTYPE": "partition", "ID_BUS": "usb", "ACTION": "add", "SUBSYSTEM": "block"},
+		// an internal disk
+		{"DEVPATH": "/sys/foo/bar", "DEVNAME": "/dev/sda", "DEVTYPE": "disk", "ID_BUS": "ata", "ACTION": "add", "SUBSYSTEM": "block"},
+		// not a SCSI disk
+		{"DEVPATH": "/sys/foo/bar", "DEVNAME": "/dev/mmcblk0", "DEVTYPE": "disk", "ID_BUS": "usb", "ACTION": "add", "SUBSYSTEM": "block"},
+		// not a block device
+		{"DEVPATH": "/sys/foo/bar", "DEVNAME": "/dev/ttyUSB0", "ID_BUS": "usb", "ACTION": "add", "SUBSYSTEM": "tty"},
+	} {
+		di, err := hotplug.NewHotplugDeviceInfo(env)
+		c.Assert(err, IsNil)
+		proposedSlot, err := hotplugIface.HotplugDeviceDetected(di)
+		c.Assert(err, IsNil)
+		c.Check(proposedSlot, IsNil, Commentf("%v", env))
+	}
+}
+
+func (s *removableBlockDeviceInterfaceSuite) TestHotplugKey(c *C) {
+	keyHandler := s.iface.(hotplug.HotplugKeyHandler)
+	di, err := hotplug.NewHotplugDeviceInfo(map[string]string{"DEVPATH": "/sys/foo/bar", "DEVNAME": "/dev/sdb", "DEVTYPE": "disk", "ID_BUS": "usb", "ID_VENDOR_ID": "0781", "ID_MODEL_ID": "5581", "ID_SERIAL_SHORT": "4C530001071205117433", "ACTION": "add", "SUBSYSTEM": "block"})
+	c.Assert(err, IsNil)
+	key1, err := keyHandler.HotplugKey(di)
+	c.Assert(err, IsNil)
+	c.Check(key1, Not(Equals), snap.HotplugKey(""))
+
+	// the same stick plugged in again shows up under another name
+	di, err = hotplug.NewHotplugDeviceInfo(map[string]string{"DEVPATH": "/sys/foo/baz", "DEVNAME": "/dev/sdc", "DEVTYPE": "disk", "ID_BUS": "usb", "ID_VENDOR_ID": "0781", "ID_MODEL_ID": "5581", "ID_SERIAL_SHORT": "4C530001071205117433", "ACTION": "add", "SUBSYSTEM": "block"})
+	c.Assert(err, IsNil)
+	key2, err := keyHandler.HotplugKey(di)
+	c.Assert(err, IsNil)
+	c.Check(key2, Equals, key1)
+}
+
+func (s *removableBlockDeviceInterfaceSuite) TestStaticInfo(c *C) {
+	si := interfaces.StaticInfoOf(s.iface)
+	c.Assert(si.ImplicitOnCore, Equals, false)
+	c.Assert(si.ImplicitOnClassic, Equals, false)
+	c.Assert(si.Summary, Equals, `allows access to a specific removable block device`)
+	c.Assert(si.BaseDeclarationSlots, testutil.Contains, "removable-block-device")
+}
+
+func (s *removableBlockDeviceInterfaceSuite) TestAutoConnect(c *C) {
+	c.Check(s.iface.AutoConnect(s.plugInfo, s.slotInfo), Equals, true)
+}
+
+func (s *removableBlockDeviceInterfaceSuite) TestInterfaces(c *C) {
+	c.Check(builtin.Interfaces(), testutil.DeepContains, s.iface)
+}
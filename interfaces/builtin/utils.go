@@ -21,13 +21,16 @@ package builtin
 
 import (
 	"bytes"
+	"crypto/sha256"
 	"fmt"
 	"path/filepath"
 	"regexp"
 	"sort"
+	"strconv"
 
 	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/hotplug"
 	"github.com/snapcore/snapd/release"
 	"github.com/snapcore/snapd/snap"
 )
@@ -182,3 +185,72 @@ func getDesktopFileRules(snapInstanceName string) []string {
 
 	return rules
 }
+
+// usbHotplugKey returns a hotplug key for a device node of an USB device,
+// derived from the vendor and product IDs of the device and its serial
+// number, or the physical path of the device if it has no serial number, so
+// that the key stays the same when the device is plugged in again. The given
+// attributes are added to the key to tell apart multiple device nodes of
+// the same USB device. An empty key is returned for devices lacking those
+// properties, in which case the hotplug subsystem uses its default key.
+func usbHotplugKey(di *hotplug.HotplugDeviceInfo, attrs ...string) (snap.HotplugKey, error) {
+	vendor, _ := di.Attribute("ID_VENDOR_ID")
+	product, _ := di.Attribute("ID_MODEL_ID")
+	if vendor == "" || product == "" {
+		return "", nil
+	}
+	id, _ := di.Attribute("ID_SERIAL_SHORT")
+	if id == "" {
+		if id, _ = di.Attribute("ID_PATH"); id == "" {
+			return "", nil
+		}
+	}
+	key := sha256.New()
+	for _, val := range []string{vendor, product, id} {
+		key.Write([]byte(val))
+		key.Write([]byte{0})
+	}
+	for _, attr := range attrs {
+		val, _ := di.Attribute(attr)
+		key.Write([]byte(attr))
+		key.Write([]byte{0})
+		key.Write([]byte(val))
+		key.Write([]byte{0})
+	}
+	return snap.HotplugKey(fmt.Sprintf("%x", key.Sum(nil))), nil
+}
+
+// addUsbHotplugAttrs adds the usb-vendor, usb-product and serial attributes
+// of the given USB device to the attributes of a proposed hotplug slot.
+func addUsbHotplugAttrs(di *hotplug.HotplugDeviceInfo, attrs map[string]interface{}) {
+	if vendor, ok := di.Attribute("ID_VENDOR_ID"); ok {
+		if v, err := strconv.ParseInt(vendor, 16, 64); err == nil {
+			attrs["usb-vendor"] = v
+		}
+	}
+	if product, ok := di.Attribute("ID_MODEL_ID"); ok {
+		if v, err := strconv.ParseInt(product, 16, 64); err == nil {
+			attrs["usb-product"] = v
+		}
+	}
+	if serial, ok := di.Attribute("ID_SERIAL_SHORT"); ok && usbSerialPattern.MatchString(serial) {
+		attrs["serial"] = serial
+	}
+}
+
+// usbSerialPattern matches the USB serial numbers that can be used as is in
+// udev rules.
+var usbSerialPattern = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+// verifySlotSerialAttribute returns the USB serial number of the slot, if
+// it has one, making sure it can be used in udev rules.
+func verifySlotSerialAttribute(slotRef *interfaces.SlotRef, attrs interfaces.Attrer) (string, error) {
+	if _, ok := attrs.Lookup("serial"); !ok {
+		return "", nil
+	}
+	var serial string
+	if err := attrs.Attr("serial", &serial); err != nil || !usbSerialPattern.MatchString(serial) {
+		return "", fmt.Errorf("slot %q serial attribute must only contain letters, digits, dots, dashes and underscores", slotRef)
+	}
+	return serial, nil
+}
@@ -813,6 +813,7 @@ var (
 		"pwm":                       {"core", "gadget"},
 		"qualcomm-ipc-router":       {"core"},
 		"raw-volume":                {"core", "gadget"},
+		"removable-block-device":    {"core"},
 		"scsi-generic":              {"core"},
 		"sd-control":                {"core"},
 		"serial-port":               {"core", "gadget"},
@@ -955,6 +956,7 @@ func (s *baseDeclSuite) TestPlugInstallation(c *C) {
 	all := builtin.Interfaces()
 
 	restricted := map[string]bool{
+		"aspects":                true,
 		"block-devices":          true,
 		"classic-support":        true,
 		"desktop-launch":         true,
@@ -1204,6 +1206,7 @@ func (s *baseDeclSuite) TestValidity(c *C) {
 	// given how the rules work this can be delicate,
 	// listed here to make sure that was a conscious decision
 	bothSides := map[string]bool{
+		"aspects":                true,
 		"block-devices":          true,
 		"audio-playback":         true,
 		"classic-support":        true,
@@ -0,0 +1,185 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package prompting
+
+import (
+	"path"
+	"strings"
+)
+
+// globToken is a single character matcher of a path component pattern: a
+// literal character, "?", a character class or "*".
+type globToken struct {
+	text    string
+	literal bool
+	star    bool
+}
+
+// tokenize splits a valid path component pattern into its tokens.
+func tokenize(component string) []globToken {
+	var tokens []globToken
+	for i := 0; i < len(component); {
+		switch component[i] {
+		case '*':
+			tokens = append(tokens, globToken{text: "*", star: true})
+			i++
+		case '?':
+			tokens = append(tokens, globToken{text: "?"})
+			i++
+		case '[':
+			end := i + 1
+			if end < len(component) && component[end] == '^' {
+				end++
+			}
+			// the pattern is valid, so the class is terminated
+			for ; component[end] != ']'; end++ {
+				if component[end] == '\\' {
+					end++
+				}
+			}
+			tokens = append(tokens, globToken{text: component[i : end+1]})
+			i = end + 1
+		case '\\':
+			tokens = append(tokens, globToken{text: component[i+1 : i+2], literal: true})
+			i += 2
+		default:
+			tokens = append(tokens, globToken{text: component[i : i+1], literal: true})
+			i++
+		}
+	}
+	return tokens
+}
+
+// tokensIntersect returns whether the single character matchers a and b
+// can match the same character. Two character classes are assumed to
+// intersect.
+func tokensIntersect(a, b globToken) bool {
+	switch {
+	case a.literal && b.literal:
+		return a.text == b.text
+	case a.literal:
+		ok, _ := path.Match(b.text, a.text)
+		return ok
+	case b.literal:
+		ok, _ := path.Match(a.text, b.text)
+		return ok
+	}
+	return true
+}
+
+func tokensOverlap(a, b []globToken) bool {
+	switch {
+	case len(a) == 0 && len(b) == 0:
+		return true
+	case len(a) > 0 && a[0].star:
+		// the star matches nothing, or it eats a character
+		// matched by b
+		return tokensOverlap(a[1:], b) || (len(b) > 0 && tokensOverlap(a, b[1:]))
+	case len(b) > 0 && b[0].star:
+		return tokensOverlap(a, b[1:]) || (len(a) > 0 && tokensOverlap(a[1:], b))
+	case len(a) == 0 || len(b) == 0:
+		return false
+	}
+	return tokensIntersect(a[0], b[0]) && tokensOverlap(a[1:], b[1:])
+}
+
+func componentsOverlap(a, b []string) bool {
+	switch {
+	case len(a) == 0 && len(b) == 0:
+		return true
+	case len(a) > 0 && a[0] == "**":
+		return componentsOverlap(a[1:], b) || (len(b) > 0 && componentsOverlap(a, b[1:]))
+	case len(b) > 0 && b[0] == "**":
+		return componentsOverlap(a, b[1:]) || (len(a) > 0 && componentsOverlap(a[1:], b))
+	case len(a) == 0 || len(b) == 0:
+		return false
+	}
+	return tokensOverlap(tokenize(a[0]), tokenize(b[0])) && componentsOverlap(a[1:], b[1:])
+}
+
+// PathPatternsOverlap returns whether some path is matched by both the
+// given path patterns, which must be valid. Character classes are assumed
+// to share characters, so the result may be a false positive for patterns
+// using them.
+func PathPatternsOverlap(a, b string) (bool, error) {
+	if err := ValidatePathPattern(a); err != nil {
+		return false, err
+	}
+	if err := ValidatePathPattern(b); err != nil {
+		return false, err
+	}
+	return componentsOverlap(strings.Split(a, "/"), strings.Split(b, "/")), nil
+}
+
+const (
+	componentDoubleStar = iota
+	componentWildcard
+	componentLiteral
+)
+
+// componentSpecificity returns the class of the component, and for
+// components with wildcards, the number of their literal characters.
+func componentSpecificity(component string) (class, literals int) {
+	if component == "**" {
+		return componentDoubleStar, 0
+	}
+	tokens := tokenize(component)
+	for _, t := range tokens {
+		if t.literal {
+			literals++
+		}
+	}
+	if literals == len(tokens) {
+		return componentLiteral, literals
+	}
+	return componentWildcard, literals
+}
+
+// ComparePathPatterns returns a positive number if the path pattern a is
+// more specific than b, a negative one if b is more specific than a, and
+// zero if neither is. The patterns must be valid.
+//
+// Patterns are compared component by component, the first differing one
+// deciding: a literal component is more specific than one with wildcards,
+// which is more specific than "**", and between components with wildcards
+// the one with more literal characters is more specific. If one pattern is
+// a prefix of the other, the longer one is more specific unless it
+// continues with "**".
+func ComparePathPatterns(a, b string) int {
+	ac, bc := strings.Split(a, "/"), strings.Split(b, "/")
+	for len(ac) > 0 && len(bc) > 0 {
+		aClass, aLiterals := componentSpecificity(ac[0])
+		bClass, bLiterals := componentSpecificity(bc[0])
+		if aClass != bClass {
+			return aClass - bClass
+		}
+		if aLiterals != bLiterals {
+			return aLiterals - bLiterals
+		}
+		ac, bc = ac[1:], bc[1:]
+	}
+	switch {
+	case len(ac) > 0 && ac[0] == "**":
+		return -1
+	case len(bc) > 0 && bc[0] == "**":
+		return 1
+	}
+	return len(ac) - len(bc)
+}
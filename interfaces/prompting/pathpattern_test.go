@@ -0,0 +1,101 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package prompting_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/interfaces/prompting"
+)
+
+type pathPatternSuite struct{}
+
+var _ = Suite(&pathPatternSuite{})
+
+func (s *pathPatternSuite) TestPathPatternsOverlap(c *C) {
+	for _, t := range []struct {
+		a, b    string
+		overlap bool
+	}{
+		{"/home/test/file.txt", "/home/test/file.txt", true},
+		{"/home/test/file.txt", "/home/test/other.txt", false},
+		{"/home/test/*", "/home/test/file.txt", true},
+		{"/home/test/*", "/home/test/dir/file.txt", false},
+		{"/home/test/**", "/home/test/dir/file.txt", true},
+		{"/home/test/**", "/home/test", true},
+		{"/home/*/Documents", "/home/test/*", true},
+		{"/home/a*", "/home/*b", true},
+		{"/home/a*", "/home/b*", false},
+		{"/home/*.txt", "/home/*.pdf", false},
+		{"/home/?", "/home/ab", false},
+		{"/home/??", "/home/a*", true},
+		{"/home/file[0-9]", "/home/file1", true},
+		{"/home/file[0-9]", "/home/filea", false},
+		{"/home/file[^0-9]", "/home/file[a-z]", true},
+		{`/home/\*`, "/home/*", true},
+		{`/home/\*`, "/home/a", false},
+		{"/**/*.pdf", "/home/test/**", true},
+		{"/**/*.pdf", "/home/test/**/*.txt", false},
+		{"/", "/**", true},
+		{"/", "/home", false},
+	} {
+		overlap, err := prompting.PathPatternsOverlap(t.a, t.b)
+		c.Check(err, IsNil)
+		c.Check(overlap, Equals, t.overlap, Commentf("%s %s", t.a, t.b))
+		overlap, err = prompting.PathPatternsOverlap(t.b, t.a)
+		c.Check(err, IsNil)
+		c.Check(overlap, Equals, t.overlap, Commentf("%s %s", t.b, t.a))
+	}
+
+	_, err := prompting.PathPatternsOverlap("/foo", "foo")
+	c.Check(err, ErrorMatches, `invalid path pattern "foo": must be absolute`)
+	_, err = prompting.PathPatternsOverlap("/foo/", "/foo")
+	c.Check(err, ErrorMatches, `invalid path pattern "/foo/": must be clean`)
+}
+
+func (s *pathPatternSuite) TestComparePathPatterns(c *C) {
+	for _, t := range []struct {
+		more, less string
+	}{
+		{"/home/test/file.txt", "/home/test/*"},
+		{"/home/test/file.txt", "/home/test/**"},
+		{"/home/test/*", "/home/test/**"},
+		{"/home/test/*.txt", "/home/test/*"},
+		{"/home/test/Documents/**", "/home/test/**"},
+		{"/home/test/**", "/home/*/Documents/**"},
+		{"/home/test", "/home/test/**"},
+		{"/home/test/**/*.pdf", "/home/test/**"},
+		{"/home/test/file.txt", "/**"},
+	} {
+		c.Check(prompting.ComparePathPatterns(t.more, t.less) > 0, Equals, true, Commentf("%s %s", t.more, t.less))
+		c.Check(prompting.ComparePathPatterns(t.less, t.more) < 0, Equals, true, Commentf("%s %s", t.less, t.more))
+	}
+
+	for _, t := range []struct {
+		a, b string
+	}{
+		{"/home/test/file.txt", "/home/test/file.txt"},
+		{"/home/test/*", "/home/test/?"},
+		{"/home/a*", "/home/*b"},
+		{"/home/test/**", "/home/test/**"},
+	} {
+		c.Check(prompting.ComparePathPatterns(t.a, t.b), Equals, 0, Commentf("%s %s", t.a, t.b))
+	}
+}
@@ -0,0 +1,193 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package prompting holds the types and helpers shared by the components of
+// interactive prompting, through which the user decides on accesses of snaps
+// which are not covered by their policy.
+package prompting
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/snapcore/snapd/sandbox/apparmor/notify"
+	"github.com/snapcore/snapd/strutil"
+)
+
+// OutcomeType is the decision of the user on a request.
+type OutcomeType string
+
+const (
+	OutcomeAllow OutcomeType = "allow"
+	OutcomeDeny  OutcomeType = "deny"
+)
+
+// ValidateOutcome returns an error if the outcome is not known.
+func ValidateOutcome(outcome OutcomeType) error {
+	switch outcome {
+	case OutcomeAllow, OutcomeDeny:
+		return nil
+	}
+	return fmt.Errorf(`invalid outcome %q, must be %q or %q`, outcome, OutcomeAllow, OutcomeDeny)
+}
+
+// LifespanType is for how long a decision of the user applies.
+type LifespanType string
+
+const (
+	// LifespanOnce applies the decision only to the request replied to.
+	LifespanOnce LifespanType = "once"
+	// LifespanSession applies the decision to matching requests until
+	// snapd is restarted.
+	LifespanSession LifespanType = "session"
+	// LifespanForever applies the decision to all future matching
+	// requests.
+	LifespanForever LifespanType = "forever"
+	// LifespanTimespan applies the decision to matching requests for a
+	// given duration.
+	LifespanTimespan LifespanType = "timespan"
+)
+
+// ValidateLifespan returns an error if the lifespan is not known.
+func ValidateLifespan(lifespan LifespanType) error {
+	switch lifespan {
+	case LifespanOnce, LifespanSession, LifespanForever, LifespanTimespan:
+		return nil
+	}
+	return fmt.Errorf(`invalid lifespan %q, must be %q, %q, %q or %q`, lifespan, LifespanOnce, LifespanSession, LifespanForever, LifespanTimespan)
+}
+
+// The permissions requested from and granted by the user, which abstract
+// the finer grained permissions of the kernel.
+const (
+	PermissionRead    = "read"
+	PermissionWrite   = "write"
+	PermissionExecute = "execute"
+)
+
+var permissionsOrder = []string{PermissionRead, PermissionWrite, PermissionExecute}
+
+var filePermissionsByPermission = map[string]notify.FilePermission{
+	PermissionRead: notify.AA_MAY_READ | notify.AA_MAY_GETATTR | notify.AA_MAY_OPEN |
+		notify.AA_MAY_GETCRED | notify.AA_MAY_LOCK,
+	PermissionWrite: notify.AA_MAY_WRITE | notify.AA_MAY_APPEND | notify.AA_MAY_CREATE |
+		notify.AA_MAY_DELETE | notify.AA_MAY_RENAME | notify.AA_MAY_SETATTR |
+		notify.AA_MAY_SETCRED | notify.AA_MAY_CHMOD | notify.AA_MAY_CHOWN |
+		notify.AA_MAY_CHGRP | notify.AA_MAY_LINK,
+	PermissionExecute: notify.AA_MAY_EXEC | notify.AA_EXEC_MMAP | notify.AA_MAY_ONEXEC |
+		notify.AA_MAY_CHANGE_PROFILE,
+}
+
+// PermissionsFromFilePermission returns the permissions covering the given
+// kernel file permissions.
+func PermissionsFromFilePermission(perm notify.FilePermission) ([]string, error) {
+	if !perm.IsValid() {
+		return nil, fmt.Errorf("cannot map unknown file permissions: %v", perm)
+	}
+
+	var perms []string
+	for _, p := range permissionsOrder {
+		if perm&filePermissionsByPermission[p] != 0 {
+			perms = append(perms, p)
+		}
+	}
+	if len(perms) == 0 {
+		return nil, fmt.Errorf("cannot map empty file permissions")
+	}
+	return perms, nil
+}
+
+// ValidatePermissions returns an error if the list of permissions is empty,
+// holds unknown permissions or duplicates.
+func ValidatePermissions(perms []string) error {
+	if len(perms) == 0 {
+		return fmt.Errorf("permissions must not be empty")
+	}
+	seen := make(map[string]bool, len(perms))
+	for _, p := range perms {
+		if !strutil.ListContains(permissionsOrder, p) {
+			return fmt.Errorf("invalid permission %q, must be one of %s", p, strutil.Quoted(permissionsOrder))
+		}
+		if seen[p] {
+			return fmt.Errorf("duplicate permission %q", p)
+		}
+		seen[p] = true
+	}
+	return nil
+}
+
+// ValidatePathPattern returns an error if the path pattern is not valid.
+//
+// A path pattern is an absolute path in which each component may use the
+// wildcards supported by path.Match, and a component "**" matches any number
+// of components, including none.
+func ValidatePathPattern(pattern string) error {
+	if !strings.HasPrefix(pattern, "/") {
+		return fmt.Errorf("invalid path pattern %q: must be absolute", pattern)
+	}
+	if pattern != "/" && path.Clean(pattern) != pattern {
+		return fmt.Errorf("invalid path pattern %q: must be clean", pattern)
+	}
+	for _, component := range strings.Split(pattern[1:], "/") {
+		if component == "**" {
+			continue
+		}
+		if strings.Contains(component, "**") {
+			return fmt.Errorf(`invalid path pattern %q: "**" must be a whole path component`, pattern)
+		}
+		if _, err := path.Match(component, ""); err != nil {
+			return fmt.Errorf("invalid path pattern %q: %v", pattern, err)
+		}
+	}
+	return nil
+}
+
+// PathPatternMatches returns whether the path matches the pattern, which
+// must be valid.
+func PathPatternMatches(pattern, p string) (bool, error) {
+	if err := ValidatePathPattern(pattern); err != nil {
+		return false, err
+	}
+	return componentsMatch(strings.Split(pattern, "/"), strings.Split(p, "/")), nil
+}
+
+func componentsMatch(pattern, components []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			// try to match the rest of the pattern against every
+			// suffix of the components
+			for i := 0; i <= len(components); i++ {
+				if componentsMatch(pattern[1:], components[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(components) == 0 {
+			return false
+		}
+		// the pattern was validated
+		if ok, _ := path.Match(pattern[0], components[0]); !ok {
+			return false
+		}
+		pattern, components = pattern[1:], components[1:]
+	}
+	return len(components) == 0
+}
@@ -0,0 +1,137 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package prompting_test
+
+import (
+	"testing"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/interfaces/prompting"
+	"github.com/snapcore/snapd/sandbox/apparmor/notify"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type promptingSuite struct{}
+
+var _ = Suite(&promptingSuite{})
+
+func (s *promptingSuite) TestValidateOutcome(c *C) {
+	c.Check(prompting.ValidateOutcome(prompting.OutcomeAllow), IsNil)
+	c.Check(prompting.ValidateOutcome(prompting.OutcomeDeny), IsNil)
+	c.Check(prompting.ValidateOutcome("maybe"), ErrorMatches, `invalid outcome "maybe", must be "allow" or "deny"`)
+}
+
+func (s *promptingSuite) TestValidateLifespan(c *C) {
+	for _, l := range []prompting.LifespanType{prompting.LifespanOnce, prompting.LifespanSession, prompting.LifespanForever, prompting.LifespanTimespan} {
+		c.Check(prompting.ValidateLifespan(l), IsNil)
+	}
+	c.Check(prompting.ValidateLifespan(""), ErrorMatches, `invalid lifespan "", must be "once", "session", "forever" or "timespan"`)
+}
+
+func (s *promptingSuite) TestPermissionsFromFilePermission(c *C) {
+	for _, t := range []struct {
+		perm  notify.FilePermission
+		perms []string
+	}{
+		{notify.AA_MAY_READ, []string{"read"}},
+		{notify.AA_MAY_OPEN | notify.AA_MAY_GETATTR, []string{"read"}},
+		{notify.AA_MAY_WRITE | notify.AA_MAY_CREATE, []string{"write"}},
+		{notify.AA_MAY_EXEC | notify.AA_MAY_READ, []string{"read", "execute"}},
+		{notify.AA_MAY_LINK | notify.AA_EXEC_MMAP | notify.AA_MAY_LOCK, []string{"read", "write", "execute"}},
+	} {
+		perms, err := prompting.PermissionsFromFilePermission(t.perm)
+		c.Check(err, IsNil)
+		c.Check(perms, DeepEquals, t.perms, Commentf("%v", t.perm))
+	}
+
+	_, err := prompting.PermissionsFromFilePermission(0)
+	c.Check(err, ErrorMatches, `cannot map empty file permissions`)
+	_, err = prompting.PermissionsFromFilePermission(notify.AA_MAY_READ | 1<<20)
+	c.Check(err, ErrorMatches, `cannot map unknown file permissions: read\|0x100000`)
+}
+
+func (s *promptingSuite) TestValidatePermissions(c *C) {
+	c.Check(prompting.ValidatePermissions([]string{"read"}), IsNil)
+	c.Check(prompting.ValidatePermissions([]string{"execute", "read", "write"}), IsNil)
+
+	c.Check(prompting.ValidatePermissions(nil), ErrorMatches, `permissions must not be empty`)
+	c.Check(prompting.ValidatePermissions([]string{"read", "delete"}), ErrorMatches, `invalid permission "delete", must be one of "read", "write", "execute"`)
+	c.Check(prompting.ValidatePermissions([]string{"read", "read"}), ErrorMatches, `duplicate permission "read"`)
+}
+
+func (s *promptingSuite) TestValidatePathPattern(c *C) {
+	for _, pattern := range []string{
+		"/",
+		"/home/test/file.txt",
+		"/home/test/*",
+		"/home/test/**",
+		"/home/*/Documents/**/*.pdf",
+		"/home/test/file[0-9].txt",
+		"/home/test/?",
+	} {
+		c.Check(prompting.ValidatePathPattern(pattern), IsNil, Commentf(pattern))
+	}
+
+	for _, t := range []struct {
+		pattern string
+		err     string
+	}{
+		{"", `invalid path pattern "": must be absolute`},
+		{"home/test", `invalid path pattern "home/test": must be absolute`},
+		{"/home/test/", `invalid path pattern "/home/test/": must be clean`},
+		{"/home/../etc", `invalid path pattern "/home/../etc": must be clean`},
+		{"/home/test**", `invalid path pattern "/home/test\*\*": "\*\*" must be a whole path component`},
+		{"/home/[test", `invalid path pattern "/home/\[test": syntax error in pattern`},
+	} {
+		c.Check(prompting.ValidatePathPattern(t.pattern), ErrorMatches, t.err)
+	}
+}
+
+func (s *promptingSuite) TestPathPatternMatches(c *C) {
+	for _, t := range []struct {
+		pattern string
+		path    string
+		matches bool
+	}{
+		{"/home/test/file.txt", "/home/test/file.txt", true},
+		{"/home/test/file.txt", "/home/test/other.txt", false},
+		{"/home/test/*", "/home/test/file.txt", true},
+		{"/home/test/*", "/home/test/dir/file.txt", false},
+		{"/home/test/*", "/home/test", false},
+		{"/home/test/**", "/home/test/dir/file.txt", true},
+		{"/home/test/**", "/home/test", true},
+		{"/home/test/**", "/home/other/file.txt", false},
+		{"/home/*/Documents/**/*.pdf", "/home/test/Documents/a/b/c.pdf", true},
+		{"/home/*/Documents/**/*.pdf", "/home/test/Documents/c.pdf", true},
+		{"/home/*/Documents/**/*.pdf", "/home/test/Documents/c.txt", false},
+		{"/**", "/etc/passwd", true},
+		{"/", "/", true},
+		{"/", "/etc", false},
+	} {
+		matches, err := prompting.PathPatternMatches(t.pattern, t.path)
+		c.Check(err, IsNil)
+		c.Check(matches, Equals, t.matches, Commentf("%s %s", t.pattern, t.path))
+	}
+
+	_, err := prompting.PathPatternMatches("foo", "/foo")
+	c.Check(err, ErrorMatches, `invalid path pattern "foo": must be absolute`)
+}
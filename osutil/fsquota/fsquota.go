@@ -0,0 +1,153 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package fsquota provides helpers to manage filesystem project quotas, which
+// limit the disk space used by a set of directory trees sharing a project ID.
+package fsquota
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/snapcore/snapd/osutil"
+)
+
+// projectQuotaOptions are the mount options indicating that project quotas
+// are being accounted and enforced on a filesystem (ext4 and xfs variants).
+var projectQuotaOptions = []string{"prjquota", "pquota", "prjjquota"}
+
+// mountEntryForPath returns the mount entry of the filesystem holding the
+// given path.
+func mountEntryForPath(path string) (*osutil.MountInfoEntry, error) {
+	entries, err := osutil.LoadMountInfo()
+	if err != nil {
+		return nil, err
+	}
+	path = filepath.Clean(path)
+	var best *osutil.MountInfoEntry
+	for _, entry := range entries {
+		mountDir := entry.MountDir
+		if mountDir != "/" && path != mountDir && !strings.HasPrefix(path, mountDir+"/") {
+			continue
+		}
+		// the last matching entry wins, as it is mounted over earlier ones
+		if best == nil || len(mountDir) >= len(best.MountDir) {
+			best = entry
+		}
+	}
+	if best == nil {
+		return nil, fmt.Errorf("cannot find mount point of %q", path)
+	}
+	return best, nil
+}
+
+// MountPoint returns the mount point of the filesystem holding the given
+// path.
+func MountPoint(path string) (string, error) {
+	entry, err := mountEntryForPath(path)
+	if err != nil {
+		return "", err
+	}
+	return entry.MountDir, nil
+}
+
+// CheckProjectQuota returns an error if the filesystem holding the given
+// path is not mounted with project quotas enabled.
+func CheckProjectQuota(path string) error {
+	entry, err := mountEntryForPath(path)
+	if err != nil {
+		return err
+	}
+	for _, opt := range projectQuotaOptions {
+		if _, ok := entry.MountOptions[opt]; ok {
+			return nil
+		}
+		if _, ok := entry.SuperOptions[opt]; ok {
+			return nil
+		}
+	}
+	return fmt.Errorf("project quotas are not enabled on %s filesystem mounted at %q", entry.FsType, entry.MountDir)
+}
+
+// SetProjectID recursively assigns the given project ID to the directory
+// tree at path, and marks directories so that any newly created files
+// inherit it. Using a project ID of 0 removes the tree from any project.
+func SetProjectID(path string, id uint32) error {
+	inherit := "+P"
+	if id == 0 {
+		inherit = "-P"
+	}
+	output, err := exec.Command("chattr", "-R", "-p", fmt.Sprint(id), inherit, path).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("cannot set project ID of %q: %v", path, osutil.OutputErr(output, err))
+	}
+	return nil
+}
+
+// SetProjectLimit sets the hard block limit, in bytes, for the given project
+// ID on the filesystem mounted at mountPoint. A limit of 0 removes the limit.
+func SetProjectLimit(mountPoint string, id uint32, limit uint64) error {
+	// setquota takes block limits in units of 1KiB, round up so that small
+	// limits are not turned into no limit at all
+	limitKiB := (limit + 1023) / 1024
+	output, err := exec.Command("setquota", "-P", fmt.Sprint(id), "0", fmt.Sprint(limitKiB), "0", "0", mountPoint).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("cannot set limit of project %d on %q: %v", id, mountPoint, osutil.OutputErr(output, err))
+	}
+	return nil
+}
+
+// Usage returns the disk space, in bytes, allocated by the files in the
+// directory trees at the given paths. Paths that do not exist are ignored.
+func Usage(paths ...string) (uint64, error) {
+	var total uint64
+	seen := make(map[uint64]bool)
+	for _, path := range paths {
+		err := filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) {
+					return nil
+				}
+				return err
+			}
+			st, ok := info.Sys().(*syscall.Stat_t)
+			if !ok {
+				total += uint64(info.Size())
+				return nil
+			}
+			// do not count hard links more than once
+			if st.Nlink > 1 && !info.IsDir() {
+				if seen[st.Ino] {
+					return nil
+				}
+				seen[st.Ino] = true
+			}
+			total += uint64(st.Blocks) * 512
+			return nil
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+	return total, nil
+}
@@ -0,0 +1,149 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package fsquota_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/osutil/fsquota"
+	"github.com/snapcore/snapd/testutil"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type fsquotaSuite struct {
+	testutil.BaseTest
+}
+
+var _ = Suite(&fsquotaSuite{})
+
+const mockMountInfo = `27 0 8:2 / / rw,relatime shared:1 - ext4 /dev/sda2 rw
+28 27 8:3 / /var rw,relatime shared:2 - ext4 /dev/sda3 rw,prjquota
+29 28 8:4 / /var/snap/other rw,relatime shared:3 - xfs /dev/sda4 rw,attr2,pquota
+30 27 8:5 / /home rw,relatime shared:4 - btrfs /dev/sda5 rw
+`
+
+func (s *fsquotaSuite) SetUpTest(c *C) {
+	s.BaseTest.SetUpTest(c)
+	s.AddCleanup(osutil.MockMountInfo(mockMountInfo))
+}
+
+func (s *fsquotaSuite) TestMountPoint(c *C) {
+	for _, t := range []struct {
+		path, mountPoint string
+	}{
+		{"/", "/"},
+		{"/etc/foo", "/"},
+		{"/var", "/var"},
+		{"/var/snap/foo", "/var"},
+		{"/var/snap/other", "/var/snap/other"},
+		{"/var/snap/other/x1/common", "/var/snap/other"},
+		{"/var/snap/otherthing", "/var"},
+		{"/home/user/snap/foo/", "/home"},
+	} {
+		mountPoint, err := fsquota.MountPoint(t.path)
+		c.Assert(err, IsNil, Commentf("%s", t.path))
+		c.Check(mountPoint, Equals, t.mountPoint, Commentf("%s", t.path))
+	}
+}
+
+func (s *fsquotaSuite) TestCheckProjectQuota(c *C) {
+	c.Check(fsquota.CheckProjectQuota("/var/snap/foo"), IsNil)
+	c.Check(fsquota.CheckProjectQuota("/var/snap/other/common"), IsNil)
+	c.Check(fsquota.CheckProjectQuota("/home/user/snap/foo"), ErrorMatches,
+		`project quotas are not enabled on btrfs filesystem mounted at "/home"`)
+	c.Check(fsquota.CheckProjectQuota("/srv"), ErrorMatches,
+		`project quotas are not enabled on ext4 filesystem mounted at "/"`)
+}
+
+func (s *fsquotaSuite) TestCheckProjectQuotaNoMounts(c *C) {
+	restore := osutil.MockMountInfo("")
+	defer restore()
+
+	c.Check(fsquota.CheckProjectQuota("/var/snap/foo"), ErrorMatches, `cannot find mount point of "/var/snap/foo"`)
+}
+
+func (s *fsquotaSuite) TestSetProjectID(c *C) {
+	cmd := testutil.MockCommand(c, "chattr", "")
+	defer cmd.Restore()
+
+	c.Assert(fsquota.SetProjectID("/var/snap/foo", 1048577), IsNil)
+	c.Assert(fsquota.SetProjectID("/var/snap/bar", 0), IsNil)
+	c.Check(cmd.Calls(), DeepEquals, [][]string{
+		{"chattr", "-R", "-p", "1048577", "+P", "/var/snap/foo"},
+		{"chattr", "-R", "-p", "0", "-P", "/var/snap/bar"},
+	})
+}
+
+func (s *fsquotaSuite) TestSetProjectIDError(c *C) {
+	cmd := testutil.MockCommand(c, "chattr", "echo 'Operation not supported'; exit 1")
+	defer cmd.Restore()
+
+	err := fsquota.SetProjectID("/var/snap/foo", 1048577)
+	c.Check(err, ErrorMatches, `cannot set project ID of "/var/snap/foo": Operation not supported`)
+}
+
+func (s *fsquotaSuite) TestSetProjectLimit(c *C) {
+	cmd := testutil.MockCommand(c, "setquota", "")
+	defer cmd.Restore()
+
+	c.Assert(fsquota.SetProjectLimit("/var", 1048577, 1024*1024+1), IsNil)
+	c.Assert(fsquota.SetProjectLimit("/var", 1048577, 0), IsNil)
+	c.Check(cmd.Calls(), DeepEquals, [][]string{
+		{"setquota", "-P", "1048577", "0", "1025", "0", "0", "/var"},
+		{"setquota", "-P", "1048577", "0", "0", "0", "0", "/var"},
+	})
+}
+
+func (s *fsquotaSuite) TestSetProjectLimitError(c *C) {
+	cmd := testutil.MockCommand(c, "setquota", "echo 'Cannot find filesystem'; exit 1")
+	defer cmd.Restore()
+
+	err := fsquota.SetProjectLimit("/var", 1048577, 4096)
+	c.Check(err, ErrorMatches, `cannot set limit of project 1048577 on "/var": Cannot find filesystem`)
+}
+
+func (s *fsquotaSuite) TestUsage(c *C) {
+	d := c.MkDir()
+	c.Assert(os.MkdirAll(filepath.Join(d, "a", "sub"), 0755), IsNil)
+	c.Assert(os.MkdirAll(filepath.Join(d, "b"), 0755), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(d, "a", "sub", "file"), make([]byte, 64*1024), 0644), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(d, "b", "file"), make([]byte, 64*1024), 0644), IsNil)
+	// hard links are only counted once
+	c.Assert(os.Link(filepath.Join(d, "b", "file"), filepath.Join(d, "b", "link")), IsNil)
+
+	usageA, err := fsquota.Usage(filepath.Join(d, "a"))
+	c.Assert(err, IsNil)
+	usageB, err := fsquota.Usage(filepath.Join(d, "b"))
+	c.Assert(err, IsNil)
+	c.Check(usageA >= 64*1024, Equals, true)
+	c.Check(usageB >= 64*1024, Equals, true)
+	c.Check(usageB < 2*64*1024, Equals, true)
+
+	total, err := fsquota.Usage(filepath.Join(d, "a"), filepath.Join(d, "b"), filepath.Join(d, "missing"))
+	c.Assert(err, IsNil)
+	c.Check(total, Equals, usageA+usageB)
+}
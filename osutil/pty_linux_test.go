@@ -0,0 +1,54 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package osutil_test
+
+import (
+	"golang.org/x/sys/unix"
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/osutil"
+)
+
+type ptySuite struct{}
+
+var _ = check.Suite(&ptySuite{})
+
+func (s *ptySuite) TestOpenPty(c *check.C) {
+	ptmx, pts, err := osutil.OpenPty()
+	if err != nil {
+		c.Skip(err.Error())
+	}
+	defer ptmx.Close()
+	defer pts.Close()
+
+	c.Assert(osutil.SetTerminalSize(ptmx, 100, 40), check.IsNil)
+	ws, err := unix.IoctlGetWinsize(int(pts.Fd()), unix.TIOCGWINSZ)
+	c.Assert(err, check.IsNil)
+	c.Check(ws.Col, check.Equals, uint16(100))
+	c.Check(ws.Row, check.Equals, uint16(40))
+
+	// what is written to the slave side can be read from the master
+	_, err = pts.Write([]byte("hello"))
+	c.Assert(err, check.IsNil)
+	buf := make([]byte, 5)
+	n, err := ptmx.Read(buf)
+	c.Assert(err, check.IsNil)
+	c.Check(string(buf[:n]), check.Equals, "hello")
+}
@@ -0,0 +1,247 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package aspectstate
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+
+	"gopkg.in/tomb.v2"
+
+	"github.com/snapcore/snapd/aspects"
+	"github.com/snapcore/snapd/i18n"
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/overlord/hookstate"
+	"github.com/snapcore/snapd/overlord/ifacestate"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+// AspectManager is responsible for committing the transactions of changes
+// that write to aspect bundles.
+type AspectManager struct{}
+
+// Manager returns a new AspectManager.
+func Manager(st *state.State, hookMgr *hookstate.HookManager, runner *state.TaskRunner) *AspectManager {
+	runner.AddHandler("commit-aspect-transaction", doCommitTransaction, nil)
+
+	hookMgr.Register(regexp.MustCompile("^change-view-[-a-z0-9]+$"), newAspectHookHandler)
+	hookMgr.Register(regexp.MustCompile("^save-view-[-a-z0-9]+$"), newAspectHookHandler)
+	hookMgr.Register(regexp.MustCompile("^observe-view-[-a-z0-9]+$"), newAspectHookHandler)
+
+	return &AspectManager{}
+}
+
+// Ensure is part of the overlord.StateManager interface.
+func (m *AspectManager) Ensure() error {
+	return nil
+}
+
+// transactionState is the state of an ongoing transaction as kept in the
+// change that commits it.
+type transactionState struct {
+	Account    string               `json:"account"`
+	BundleName string               `json:"bundle-name"`
+	Tx         *aspects.Transaction `json:"transaction"`
+}
+
+func getTransactionState(chg *state.Change) (*transactionState, error) {
+	var txState transactionState
+	if err := chg.Get("aspect-transaction", &txState); err != nil {
+		return nil, err
+	}
+	return &txState, nil
+}
+
+func doCommitTransaction(t *state.Task, _ *tomb.Tomb) error {
+	st := t.State()
+	st.Lock()
+	defer st.Unlock()
+
+	txState, err := getTransactionState(t.Change())
+	if err != nil {
+		return fmt.Errorf("internal error: cannot get aspect transaction: %v", err)
+	}
+
+	return commitTransaction(st, txState.Account, txState.BundleName, txState.Tx)
+}
+
+// checkOngoingTransaction returns an error if a change is already writing to
+// the databag of the aspect bundle.
+func checkOngoingTransaction(st *state.State, account, bundleName string) error {
+	for _, chg := range st.Changes() {
+		if chg.IsReady() {
+			continue
+		}
+
+		txState, err := getTransactionState(chg)
+		if err != nil {
+			// not an aspect change
+			continue
+		}
+
+		if txState.Account == account && txState.BundleName == bundleName {
+			return &snapstate.ChangeConflictError{
+				Message:    fmt.Sprintf("cannot write aspect bundle %s/%s: change %s in progress", account, bundleName, chg.ID()),
+				ChangeKind: chg.Kind(),
+				ChangeID:   chg.ID(),
+			}
+		}
+	}
+
+	return nil
+}
+
+// aspectPlug is a connected "aspects" plug referencing an aspect bundle.
+type aspectPlug struct {
+	snap    string
+	plug    string
+	manager bool
+}
+
+// bundlePlugs returns the connected "aspects" plugs that reference aspects in
+// the aspect bundle, sorted by snap and plug name.
+func bundlePlugs(st *state.State, account, bundleName string) ([]aspectPlug, error) {
+	conns, err := ifacestate.ConnectionStates(st)
+	if err != nil {
+		return nil, err
+	}
+
+	var plugs []aspectPlug
+	for refStr, connState := range conns {
+		if connState.Interface != "aspects" || !connState.Active() {
+			continue
+		}
+
+		attrs := connState.StaticPlugAttrs
+		if attrs["account"] != account || attrs["bundle"] != bundleName {
+			continue
+		}
+
+		connRef, err := interfaces.ParseConnRef(refStr)
+		if err != nil {
+			return nil, err
+		}
+
+		plugs = append(plugs, aspectPlug{
+			snap:    connRef.PlugRef.Snap,
+			plug:    connRef.PlugRef.Name,
+			manager: attrs["role"] == "manager",
+		})
+	}
+
+	sort.Slice(plugs, func(i, j int) bool {
+		if plugs[i].snap != plugs[j].snap {
+			return plugs[i].snap < plugs[j].snap
+		}
+		return plugs[i].plug < plugs[j].plug
+	})
+
+	return plugs, nil
+}
+
+// createChange creates a change to commit the transaction. The change runs the
+// "change-view-<plug>" hooks of the custodian snaps (connected with the
+// "manager" role), which can validate or modify the pending data, and then
+// their "save-view-<plug>" hooks. If these succeed, the transaction is
+// committed and the "observe-view-<plug>" hooks of the other connected snaps
+// are run. If a hook fails, the already run "save-view-<plug>" hooks are run
+// again with the pristine data and the transaction is discarded.
+func createChange(st *state.State, summary, account, bundleName string, tx *aspects.Transaction) (*state.Change, error) {
+	plugs, err := bundlePlugs(st, account, bundleName)
+	if err != nil {
+		return nil, err
+	}
+
+	hasHook := func(snapName, hookName string) (bool, error) {
+		info, err := snapstate.CurrentInfo(st, snapName)
+		if err != nil {
+			return false, err
+		}
+		return info.Hooks[hookName] != nil, nil
+	}
+
+	var tasks []*state.Task
+	addHookTask := func(p aspectPlug, prefix string, withUndo, ignoreError bool) error {
+		hookName := prefix + p.plug
+		ok, err := hasHook(p.snap, hookName)
+		if err != nil || !ok {
+			return err
+		}
+
+		hooksup := &hookstate.HookSetup{
+			Snap:        p.snap,
+			Hook:        hookName,
+			IgnoreError: ignoreError,
+		}
+		taskSummary := fmt.Sprintf(i18n.G("Run hook %s of snap %q"), hookName, p.snap)
+
+		var undo *hookstate.HookSetup
+		if withUndo {
+			undo = hooksup
+		}
+		tasks = append(tasks, hookstate.HookTaskWithUndo(st, taskSummary, hooksup, undo, nil))
+		return nil
+	}
+
+	for _, p := range plugs {
+		if p.manager {
+			if err := addHookTask(p, "change-view-", false, false); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for _, p := range plugs {
+		if p.manager {
+			if err := addHookTask(p, "save-view-", true, false); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	commitSummary := fmt.Sprintf(i18n.G("Commit changes to aspect bundle %s/%s"), account, bundleName)
+	tasks = append(tasks, st.NewTask("commit-aspect-transaction", commitSummary))
+
+	for _, p := range plugs {
+		if !p.manager {
+			if err := addHookTask(p, "observe-view-", false, true); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	chg := st.NewChange("set-aspect", summary)
+	for i, t := range tasks {
+		if i > 0 {
+			t.WaitFor(tasks[i-1])
+		}
+		chg.AddTask(t)
+	}
+
+	chg.Set("aspect-transaction", &transactionState{
+		Account:    account,
+		BundleName: bundleName,
+		Tx:         tx,
+	})
+
+	return chg, nil
+}
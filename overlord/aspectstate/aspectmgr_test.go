@@ -0,0 +1,296 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package aspectstate_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/aspects"
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/overlord/aspectstate"
+	"github.com/snapcore/snapd/overlord/hookstate"
+	"github.com/snapcore/snapd/overlord/hookstate/hooktest"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snaptest"
+)
+
+type aspectChangeSuite struct {
+	aspectTestSuite
+}
+
+var _ = Suite(&aspectChangeSuite{})
+
+func (s *aspectChangeSuite) SetUpTest(c *C) {
+	s.aspectTestSuite.SetUpTest(c)
+	dirs.SetRootDir(c.MkDir())
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	mockInstalledSnap(c, s.state, `name: custodian-snap
+plugs:
+  manage-wifi:
+    interface: aspects
+    account: system
+    bundle: network
+    aspect: wifi-setup
+    role: manager
+hooks:
+  change-view-manage-wifi:
+  save-view-manage-wifi:
+`)
+	mockInstalledSnap(c, s.state, `name: observer-snap
+plugs:
+  watch-wifi:
+    interface: aspects
+    account: system
+    bundle: network
+    aspect: wifi-setup
+  other-bundle:
+    interface: aspects
+    account: system
+    bundle: other
+    aspect: foo
+hooks:
+  observe-view-watch-wifi:
+  observe-view-other-bundle:
+`)
+	mockInstalledSnap(c, s.state, `name: no-hooks-snap
+plugs:
+  wifi:
+    interface: aspects
+    account: system
+    bundle: network
+    aspect: wifi-setup
+`)
+
+	plugAttrs := func(bundle, role string) map[string]interface{} {
+		attrs := map[string]interface{}{"account": "system", "bundle": bundle, "aspect": "wifi-setup"}
+		if role != "" {
+			attrs["role"] = role
+		}
+		return attrs
+	}
+	s.state.Set("conns", map[string]interface{}{
+		"custodian-snap:manage-wifi core:aspects": map[string]interface{}{
+			"interface": "aspects", "plug-static": plugAttrs("network", "manager"),
+		},
+		"observer-snap:watch-wifi core:aspects": map[string]interface{}{
+			"interface": "aspects", "plug-static": plugAttrs("network", ""),
+		},
+		"observer-snap:other-bundle core:aspects": map[string]interface{}{
+			"interface": "aspects", "plug-static": plugAttrs("other", ""),
+		},
+		"no-hooks-snap:wifi core:aspects": map[string]interface{}{
+			"interface": "aspects", "plug-static": plugAttrs("network", ""),
+		},
+	})
+}
+
+func (s *aspectChangeSuite) TearDownTest(c *C) {
+	dirs.SetRootDir("/")
+}
+
+func mockInstalledSnap(c *C, st *state.State, snapYaml string) {
+	info := snaptest.MockSnapCurrent(c, snapYaml, &snap.SideInfo{Revision: snap.R(1)})
+	snapstate.Set(st, info.InstanceName(), &snapstate.SnapState{
+		Active: true,
+		Sequence: []*snap.SideInfo{
+			{
+				RealName: info.SnapName(),
+				Revision: info.Revision,
+				SnapID:   info.InstanceName() + "-id",
+			},
+		},
+		Current:  info.Revision,
+		SnapType: string(info.Type()),
+	})
+}
+
+func (s *aspectChangeSuite) TestSetCreatesChangeWithHooks(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	chg, err := aspectstate.Set(s.state, "system", "network", "wifi-setup", map[string]interface{}{"ssid": "foo"})
+	c.Assert(err, IsNil)
+
+	tasks := chg.Tasks()
+	c.Assert(tasks, HasLen, 4)
+
+	type hookInfo struct {
+		snap, hook  string
+		ignoreError bool
+	}
+	var hooks []hookInfo
+	for i, t := range tasks {
+		if i > 0 {
+			c.Check(t.WaitTasks(), DeepEquals, []*state.Task{tasks[i-1]})
+		}
+
+		if t.Kind() != "run-hook" {
+			c.Check(t.Kind(), Equals, "commit-aspect-transaction")
+			c.Check(t.Summary(), Equals, "Commit changes to aspect bundle system/network")
+			hooks = append(hooks, hookInfo{})
+			continue
+		}
+
+		var hooksup hookstate.HookSetup
+		c.Assert(t.Get("hook-setup", &hooksup), IsNil)
+		hooks = append(hooks, hookInfo{hooksup.Snap, hooksup.Hook, hooksup.IgnoreError})
+	}
+
+	c.Check(hooks, DeepEquals, []hookInfo{
+		{"custodian-snap", "change-view-manage-wifi", false},
+		{"custodian-snap", "save-view-manage-wifi", false},
+		{},
+		{"observer-snap", "observe-view-watch-wifi", true},
+	})
+
+	// only the save-view hook is re-run on undo
+	c.Check(tasks[0].Has("undo-hook-setup"), Equals, false)
+	c.Check(tasks[1].Has("undo-hook-setup"), Equals, true)
+}
+
+func (s *aspectChangeSuite) TestHookHandler(c *C) {
+	s.state.Lock()
+	task := s.state.NewTask("run-hook", "")
+	setup := &hookstate.HookSetup{Snap: "custodian-snap", Revision: snap.R(1), Hook: "change-view-manage-wifi"}
+	context, err := hookstate.NewContext(task, s.state, setup, hooktest.NewMockHandler(), "")
+	s.state.Unlock()
+	c.Assert(err, IsNil)
+
+	handler := aspectstate.NewAspectHookHandler(context)
+	c.Check(handler.Before(), IsNil)
+	c.Check(handler.Done(), IsNil)
+	ignore, err := handler.Error(nil)
+	c.Check(ignore, Equals, false)
+	c.Check(err, IsNil)
+}
+
+func (s *aspectChangeSuite) hookContext(c *C, chg *state.Change, hookName string) *hookstate.Context {
+	var hookTask *state.Task
+	for _, t := range chg.Tasks() {
+		var hooksup hookstate.HookSetup
+		if t.Get("hook-setup", &hooksup) == nil && hooksup.Hook == hookName {
+			hookTask = t
+		}
+	}
+	c.Assert(hookTask, NotNil)
+
+	setup := &hookstate.HookSetup{Snap: "custodian-snap", Revision: snap.R(1), Hook: hookName}
+	context, err := hookstate.NewContext(hookTask, s.state, setup, hooktest.NewMockHandler(), "")
+	c.Assert(err, IsNil)
+	return context
+}
+
+func (s *aspectChangeSuite) TestContextTransactionChangeViewHook(c *C) {
+	s.state.Lock()
+	chg, err := aspectstate.Set(s.state, "system", "network", "wifi-setup", map[string]interface{}{"ssid": "foo"})
+	c.Assert(err, IsNil)
+	context := s.hookContext(c, chg, "change-view-manage-wifi")
+	s.state.Unlock()
+
+	context.Lock()
+	defer context.Unlock()
+
+	tx, ok, err := aspectstate.ContextTransaction(context, "system", "network")
+	c.Assert(err, IsNil)
+	c.Assert(ok, Equals, true)
+
+	var ssid string
+	c.Assert(tx.Get("wifi.ssid", &ssid), IsNil)
+	c.Check(ssid, Equals, "foo")
+
+	// the same transaction is returned while the hook runs
+	otherTx, ok, err := aspectstate.ContextTransaction(context, "system", "network")
+	c.Assert(err, IsNil)
+	c.Assert(ok, Equals, true)
+	c.Check(otherTx, Equals, tx)
+
+	c.Assert(tx.Set("wifi.ssid", "bar"), IsNil)
+	c.Assert(context.Done(), IsNil)
+
+	// the modification is saved into the change
+	var txState struct {
+		Tx *aspects.Transaction `json:"transaction"`
+	}
+	c.Assert(chg.Get("aspect-transaction", &txState), IsNil)
+	c.Assert(txState.Tx.Get("wifi.ssid", &ssid), IsNil)
+	c.Check(ssid, Equals, "bar")
+}
+
+func (s *aspectChangeSuite) TestContextTransactionUndo(c *C) {
+	s.state.Lock()
+	chg, err := aspectstate.Set(s.state, "system", "network", "wifi-setup", map[string]interface{}{"ssid": "foo"})
+	c.Assert(err, IsNil)
+	context := s.hookContext(c, chg, "save-view-manage-wifi")
+	task, _ := context.Task()
+	task.SetStatus(state.UndoingStatus)
+	s.state.Unlock()
+
+	context.Lock()
+	defer context.Unlock()
+
+	tx, ok, err := aspectstate.ContextTransaction(context, "system", "network")
+	c.Assert(err, IsNil)
+	c.Assert(ok, Equals, true)
+
+	// the hook sees the pristine data when undoing
+	var ssid string
+	c.Assert(tx.Get("wifi.ssid", &ssid), FitsTypeOf, &aspects.FieldNotFoundError{})
+	c.Check(tx.Modified(), Equals, false)
+}
+
+func (s *aspectChangeSuite) TestContextTransactionNoTransaction(c *C) {
+	s.state.Lock()
+	chg, err := aspectstate.Set(s.state, "system", "network", "wifi-setup", map[string]interface{}{"ssid": "foo"})
+	c.Assert(err, IsNil)
+	context := s.hookContext(c, chg, "change-view-manage-wifi")
+
+	otherTask := s.state.NewTask("run-hook", "")
+	s.state.NewChange("other", "").AddTask(otherTask)
+	setup := &hookstate.HookSetup{Snap: "custodian-snap", Revision: snap.R(1), Hook: "configure"}
+	otherContext, err := hookstate.NewContext(otherTask, s.state, setup, hooktest.NewMockHandler(), "")
+	c.Assert(err, IsNil)
+
+	ephemeralContext, err := hookstate.NewContext(nil, s.state, setup, nil, "")
+	c.Assert(err, IsNil)
+	s.state.Unlock()
+
+	for _, t := range []struct {
+		context *hookstate.Context
+		bundle  string
+	}{
+		// the change writes to another bundle
+		{context, "other"},
+		// not an aspect change
+		{otherContext, "network"},
+		{ephemeralContext, "network"},
+	} {
+		t.context.Lock()
+		tx, ok, err := aspectstate.ContextTransaction(t.context, "system", t.bundle)
+		t.context.Unlock()
+		c.Assert(err, IsNil)
+		c.Check(ok, Equals, false)
+		c.Check(tx, IsNil)
+	}
+}
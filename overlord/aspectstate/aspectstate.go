@@ -20,80 +20,132 @@ package aspectstate
 
 import (
 	"errors"
+	"fmt"
+	"sort"
 
 	"github.com/snapcore/snapd/aspects"
-	"github.com/snapcore/snapd/overlord/aspectstate/aspecttest"
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/overlord/assertstate"
 	"github.com/snapcore/snapd/overlord/state"
 )
 
-// Set finds the aspect identified by the account, bundleName and aspect and sets
-// the specified field to the supplied value.
-func Set(st *state.State, account, bundleName, aspect, field string, value interface{}) error {
-	st.Lock()
-	defer st.Unlock()
-
+// Get finds the aspect identified by the account, bundleName and aspect and
+// returns the specified field's value through the "value" output parameter.
+// It requires the state to be locked.
+func Get(st *state.State, account, bundleName, aspect, field string, value interface{}) error {
 	databag, err := getDatabag(st, account, bundleName)
 	if err != nil {
-		if !errors.Is(err, state.ErrNoState) {
-			return err
+		if errors.Is(err, state.ErrNoState) {
+			return &aspects.AspectNotFoundError{Account: account, BundleName: bundleName, Aspect: aspect}
 		}
 
-		databag = aspects.NewJSONDataBag()
+		return err
 	}
 
-	accPatterns := aspecttest.MockWifiSetupAspect()
-	aspectBundle, err := aspects.NewAspectBundle(bundleName, accPatterns, aspects.NewJSONSchema())
+	asp, err := getAspect(st, account, bundleName, aspect)
 	if err != nil {
 		return err
 	}
 
-	asp := aspectBundle.Aspect(aspect)
-	if asp == nil {
-		return &aspects.AspectNotFoundError{Account: account, BundleName: bundleName, Aspect: aspect}
+	if err := asp.Get(databag, field, value); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// NewTransaction returns a transaction on top of the current databag of the
+// aspect bundle. It requires the state to be locked.
+func NewTransaction(st *state.State, account, bundleName string) (*aspects.Transaction, error) {
+	databag, err := getDatabag(st, account, bundleName)
+	if err != nil && !errors.Is(err, state.ErrNoState) {
+		return nil, err
 	}
 
-	if err := asp.Set(databag, field, value); err != nil {
+	return aspects.NewTransaction(databag)
+}
+
+// GetViaAspect finds the aspect identified by the account, bundleName and
+// aspect and uses it to read the specified field from the transaction into
+// the "value" output parameter. It requires the state to be locked.
+func GetViaAspect(st *state.State, tx *aspects.Transaction, account, bundleName, aspect, field string, value interface{}) error {
+	asp, err := getAspect(st, account, bundleName, aspect)
+	if err != nil {
 		return err
 	}
 
-	if err := updateDatabags(st, account, bundleName, databag); err != nil {
+	return asp.Get(tx, field, value)
+}
+
+// SetViaAspect finds the aspect identified by the account, bundleName and
+// aspect and uses it to write the value of the specified field into the
+// transaction. It requires the state to be locked.
+func SetViaAspect(st *state.State, tx *aspects.Transaction, account, bundleName, aspect, field string, value interface{}) error {
+	asp, err := getAspect(st, account, bundleName, aspect)
+	if err != nil {
 		return err
 	}
 
-	return nil
+	return asp.Set(tx, field, value)
 }
 
-// Get finds the aspect identified by the account, bundleName and aspect and
-// returns the specified field's value through the "value" output parameter.
-func Get(st *state.State, account, bundleName, aspect, field string, value interface{}) error {
-	st.Lock()
-	defer st.Unlock()
+// Set writes the values of the fields of the aspect identified by the account,
+// bundleName and aspect into a new transaction. It returns a change which runs
+// the hooks of the snaps involved with the bundle and commits the transaction.
+// It requires the state to be locked.
+func Set(st *state.State, account, bundleName, aspect string, values map[string]interface{}) (*state.Change, error) {
+	if err := checkOngoingTransaction(st, account, bundleName); err != nil {
+		return nil, err
+	}
 
-	databag, err := getDatabag(st, account, bundleName)
+	tx, err := NewTransaction(st, account, bundleName)
 	if err != nil {
-		if errors.Is(err, state.ErrNoState) {
-			return &aspects.AspectNotFoundError{Account: account, BundleName: bundleName, Aspect: aspect}
-		}
+		return nil, err
+	}
 
-		return err
+	fields := make([]string, 0, len(values))
+	for field := range values {
+		fields = append(fields, field)
 	}
+	sort.Strings(fields)
 
-	accPatterns := aspecttest.MockWifiSetupAspect()
-	aspectBundle, err := aspects.NewAspectBundle(bundleName, accPatterns, aspects.NewJSONSchema())
+	for _, field := range fields {
+		if err := SetViaAspect(st, tx, account, bundleName, aspect, field, values[field]); err != nil {
+			return nil, err
+		}
+	}
+
+	// reject invalid values before creating the change, the data is
+	// validated again when the transaction is committed
+	bundleAssert, err := assertstate.AspectBundle(st, account, bundleName)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if _, err := tx.Commit(bundleAssert.Bundle().Schema()); err != nil {
+		return nil, err
 	}
 
-	asp := aspectBundle.Aspect(aspect)
-	if asp == nil {
-		return &aspects.AspectNotFoundError{Account: account, BundleName: bundleName, Aspect: aspect}
+	summary := fmt.Sprintf("Set aspect %s/%s/%s", account, bundleName, aspect)
+	return createChange(st, summary, account, bundleName, tx)
+}
+
+// getAspect finds the aspect identified by the account, bundleName and aspect
+// in the aspect-bundle assertion found in the system assertion database.
+func getAspect(st *state.State, account, bundleName, aspect string) (*aspects.Aspect, error) {
+	bundleAssert, err := assertstate.AspectBundle(st, account, bundleName)
+	if err != nil {
+		if errors.Is(err, &asserts.NotFoundError{}) {
+			return nil, &aspects.AspectNotFoundError{Account: account, BundleName: bundleName, Aspect: aspect}
+		}
+		return nil, err
 	}
 
-	if err := asp.Get(databag, field, value); err != nil {
-		return err
+	asp := bundleAssert.Bundle().Aspect(aspect)
+	if asp == nil {
+		return nil, &aspects.AspectNotFoundError{Account: account, BundleName: bundleName, Aspect: aspect}
 	}
 
-	return nil
+	return asp, nil
 }
 
 func updateDatabags(st *state.State, account, bundleName string, databag aspects.JSONDataBag) error {
@@ -103,16 +155,50 @@ func updateDatabags(st *state.State, account, bundleName string, databag aspects
 			return err
 		}
 
-		databags = map[string]map[string]aspects.JSONDataBag{
-			account: {bundleName: aspects.NewJSONDataBag()},
-		}
+		databags = make(map[string]map[string]aspects.JSONDataBag)
 	}
 
+	if databags[account] == nil {
+		databags[account] = make(map[string]aspects.JSONDataBag)
+	}
 	databags[account][bundleName] = databag
 	st.Set("aspect-databags", databags)
 	return nil
 }
 
+// commitTransaction validates and writes the transaction's changes into the
+// bundle's databag. The transaction conflicts with any change to the databag
+// made since it was started.
+func commitTransaction(st *state.State, account, bundleName string, tx *aspects.Transaction) error {
+	bundleAssert, err := assertstate.AspectBundle(st, account, bundleName)
+	if err != nil {
+		return err
+	}
+
+	current, err := getDatabag(st, account, bundleName)
+	if err != nil && !errors.Is(err, state.ErrNoState) {
+		return err
+	}
+	if current == nil {
+		current = aspects.NewJSONDataBag()
+	}
+
+	unchanged, err := current.Equal(tx.Pristine())
+	if err != nil {
+		return err
+	}
+	if !unchanged {
+		return fmt.Errorf("cannot commit changes to aspect bundle %s/%s: databag was modified concurrently", account, bundleName)
+	}
+
+	databag, err := tx.Commit(bundleAssert.Bundle().Schema())
+	if err != nil {
+		return err
+	}
+
+	return updateDatabags(st, account, bundleName, databag)
+}
+
 func getDatabag(st *state.State, account, bundleName string) (aspects.JSONDataBag, error) {
 	var databags map[string]map[string]aspects.JSONDataBag
 	if err := st.Get("aspect-databags", &databags); err != nil {
@@ -19,14 +19,21 @@
 package aspectstate_test
 
 import (
+	"fmt"
 	"testing"
+	"time"
 
 	. "gopkg.in/check.v1"
 
 	"github.com/snapcore/snapd/aspects"
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/asserts/assertstest"
 	"github.com/snapcore/snapd/overlord"
 	"github.com/snapcore/snapd/overlord/aspectstate"
+	"github.com/snapcore/snapd/overlord/assertstate"
+	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/testutil"
 )
 
 type aspectTestSuite struct {
@@ -37,8 +44,66 @@ var _ = Suite(&aspectTestSuite{})
 
 func Test(t *testing.T) { TestingT(t) }
 
-func (s *aspectTestSuite) SetUpTest(_ *C) {
+func (s *aspectTestSuite) SetUpTest(c *C) {
 	s.state = overlord.Mock().State()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	storeSigning := assertstest.NewStoreStack("can0nical", nil)
+	db, err := asserts.OpenDatabase(&asserts.DatabaseConfig{
+		Backstore: asserts.NewMemoryBackstore(),
+		Trusted:   storeSigning.Trusted,
+	})
+	c.Assert(err, IsNil)
+	c.Assert(db.Add(storeSigning.StoreAccountKey("")), IsNil)
+	assertstate.ReplaceDB(s.state, db)
+
+	// the "system" account signs the aspect-bundle used in the tests
+	devAcct := assertstest.NewAccount(storeSigning, "system", map[string]interface{}{
+		"account-id": "system",
+	}, "")
+	devPrivKey, _ := assertstest.GenerateKey(752)
+	devAcctKey := assertstest.NewAccountKey(storeSigning, devAcct, nil, devPrivKey.PublicKey(), "")
+	c.Assert(assertstate.Add(s.state, devAcct), IsNil)
+	c.Assert(assertstate.Add(s.state, devAcctKey), IsNil)
+
+	devSigning := assertstest.NewSigningDB("system", devPrivKey)
+	headers := map[string]interface{}{
+		"authority-id": "system",
+		"account-id":   "system",
+		"name":         "network",
+		"aspects": map[string]interface{}{
+			"wifi-setup": []interface{}{
+				map[string]interface{}{"name": "ssids", "path": "wifi.ssids"},
+				map[string]interface{}{"name": "ssid", "path": "wifi.ssid", "access": "read-write"},
+				map[string]interface{}{"name": "password", "path": "wifi.psk", "access": "write"},
+				map[string]interface{}{"name": "status", "path": "wifi.status", "access": "read"},
+			},
+		},
+		"timestamp": time.Now().Format(time.RFC3339),
+	}
+	body := []byte(`{
+  "storage": {
+    "schema": {
+      "wifi": {
+        "type": "map",
+        "schema": {
+          "ssids": {
+            "type": "array",
+            "values": "string"
+          },
+          "ssid": "string",
+          "psk": "string",
+          "status": "string"
+        }
+      }
+    }
+  }
+}`)
+	bundle, err := devSigning.Sign(asserts.AspectBundleType, headers, body, "")
+	c.Assert(err, IsNil)
+	c.Assert(assertstate.Add(s.state, bundle), IsNil)
 }
 
 func (s *aspectTestSuite) TestGetAspect(c *C) {
@@ -47,10 +112,10 @@ func (s *aspectTestSuite) TestGetAspect(c *C) {
 	c.Assert(err, IsNil)
 
 	s.state.Lock()
+	defer s.state.Unlock()
 	s.state.Set("aspect-databags", map[string]map[string]aspects.JSONDataBag{
 		"system": {"network": databag},
 	})
-	s.state.Unlock()
 
 	var res interface{}
 	err = aspectstate.Get(s.state, "system", "network", "wifi-setup", "ssid", &res)
@@ -59,17 +124,18 @@ func (s *aspectTestSuite) TestGetAspect(c *C) {
 }
 
 func (s *aspectTestSuite) TestGetNotFound(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
 	var res interface{}
 	err := aspectstate.Get(s.state, "system", "network", "wifi-setup", "ssid", &res)
 	c.Assert(err, FitsTypeOf, &aspects.AspectNotFoundError{})
 	c.Assert(err, ErrorMatches, `aspect system/network/wifi-setup not found`)
 	c.Check(res, IsNil)
 
-	s.state.Lock()
 	s.state.Set("aspect-databags", map[string]map[string]aspects.JSONDataBag{
 		"system": {"network": aspects.NewJSONDataBag()},
 	})
-	s.state.Unlock()
 
 	err = aspectstate.Get(s.state, "system", "network", "other-aspect", "ssid", &res)
 	c.Assert(err, FitsTypeOf, &aspects.AspectNotFoundError{})
@@ -82,14 +148,37 @@ func (s *aspectTestSuite) TestGetNotFound(c *C) {
 	c.Check(res, IsNil)
 }
 
+func (s *aspectTestSuite) commitChange(c *C, chg *state.Change) error {
+	var commitTask *state.Task
+	for _, t := range chg.Tasks() {
+		if t.Kind() == "commit-aspect-transaction" {
+			commitTask = t
+		}
+	}
+	c.Assert(commitTask, NotNil)
+
+	s.state.Unlock()
+	defer s.state.Lock()
+	return aspectstate.DoCommitTransaction(commitTask, nil)
+}
+
 func (s *aspectTestSuite) TestSetAspect(c *C) {
-	err := aspectstate.Set(s.state, "system", "network", "wifi-setup", "ssid", "foo")
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	chg, err := aspectstate.Set(s.state, "system", "network", "wifi-setup", map[string]interface{}{"ssid": "foo"})
 	c.Assert(err, IsNil)
+	c.Check(chg.Kind(), Equals, "set-aspect")
+	c.Check(chg.Summary(), Equals, "Set aspect system/network/wifi-setup")
 
+	// nothing is written until the change commits the transaction
 	var databags map[string]map[string]aspects.JSONDataBag
-	s.state.Lock()
 	err = s.state.Get("aspect-databags", &databags)
-	s.state.Unlock()
+	c.Assert(err, testutil.ErrorIs, state.ErrNoState)
+
+	c.Assert(s.commitChange(c, chg), IsNil)
+
+	err = s.state.Get("aspect-databags", &databags)
 	c.Assert(err, IsNil)
 
 	databag := databags["system"]["network"]
@@ -102,29 +191,61 @@ func (s *aspectTestSuite) TestSetAspect(c *C) {
 }
 
 func (s *aspectTestSuite) TestSetNotFound(c *C) {
-	err := aspectstate.Set(s.state, "system", "other-bundle", "other-aspect", "foo", "bar")
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	_, err := aspectstate.Set(s.state, "system", "other-bundle", "other-aspect", map[string]interface{}{"foo": "bar"})
+	c.Assert(err, FitsTypeOf, &aspects.AspectNotFoundError{})
+
+	_, err = aspectstate.Set(s.state, "system", "network", "other-aspect", map[string]interface{}{"foo": "bar"})
 	c.Assert(err, FitsTypeOf, &aspects.AspectNotFoundError{})
+	c.Check(s.state.Changes(), HasLen, 0)
+}
+
+func (s *aspectTestSuite) TestSetUnknownAccount(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
 
-	err = aspectstate.Set(s.state, "system", "network", "other-aspect", "foo", "bar")
+	_, err := aspectstate.Set(s.state, "other-account", "network", "wifi-setup", map[string]interface{}{"ssid": "foo"})
 	c.Assert(err, FitsTypeOf, &aspects.AspectNotFoundError{})
+	c.Assert(err, ErrorMatches, `aspect other-account/network/wifi-setup not found`)
+}
+
+func (s *aspectTestSuite) TestSetInvalidValue(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	_, err := aspectstate.Set(s.state, "system", "network", "wifi-setup", map[string]interface{}{
+		"ssid":  "foo",
+		"ssids": []interface{}{"foo", 1},
+	})
+	c.Assert(err, ErrorMatches, `cannot accept element in "wifi.ssids\[1\]": expected string type but value was number`)
+	c.Check(s.state.Changes(), HasLen, 0)
 }
 
 func (s *aspectTestSuite) TestSetAccessError(c *C) {
-	err := aspectstate.Set(s.state, "system", "network", "wifi-setup", "status", "foo")
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	_, err := aspectstate.Set(s.state, "system", "network", "wifi-setup", map[string]interface{}{"status": "foo"})
 	c.Assert(err, ErrorMatches, `cannot set field "status": path is not writeable`)
 }
 
 func (s *aspectTestSuite) TestUnsetAspect(c *C) {
-	err := aspectstate.Set(s.state, "system", "network", "wifi-setup", "ssid", "foo")
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	chg, err := aspectstate.Set(s.state, "system", "network", "wifi-setup", map[string]interface{}{"ssid": "foo"})
 	c.Assert(err, IsNil)
+	c.Assert(s.commitChange(c, chg), IsNil)
+	chg.SetStatus(state.DoneStatus)
 
-	err = aspectstate.Set(s.state, "system", "network", "wifi-setup", "ssid", nil)
+	chg, err = aspectstate.Set(s.state, "system", "network", "wifi-setup", map[string]interface{}{"ssid": nil})
 	c.Assert(err, IsNil)
+	c.Assert(s.commitChange(c, chg), IsNil)
 
 	var databags map[string]map[string]aspects.JSONDataBag
-	s.state.Lock()
 	err = s.state.Get("aspect-databags", &databags)
-	s.state.Unlock()
 	c.Assert(err, IsNil)
 
 	databag := databags["system"]["network"]
@@ -135,3 +256,62 @@ func (s *aspectTestSuite) TestUnsetAspect(c *C) {
 	c.Assert(err, FitsTypeOf, &aspects.FieldNotFoundError{})
 	c.Assert(val, Equals, "")
 }
+
+func (s *aspectTestSuite) TestSetConflict(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	chg, err := aspectstate.Set(s.state, "system", "network", "wifi-setup", map[string]interface{}{"ssid": "foo"})
+	c.Assert(err, IsNil)
+
+	_, err = aspectstate.Set(s.state, "system", "network", "wifi-setup", map[string]interface{}{"ssid": "bar"})
+	c.Assert(err, FitsTypeOf, &snapstate.ChangeConflictError{})
+	c.Assert(err, ErrorMatches, fmt.Sprintf(`cannot write aspect bundle system/network: change %s in progress`, chg.ID()))
+
+	chg.SetStatus(state.DoneStatus)
+	_, err = aspectstate.Set(s.state, "system", "network", "wifi-setup", map[string]interface{}{"ssid": "bar"})
+	c.Assert(err, IsNil)
+}
+
+func (s *aspectTestSuite) TestCommitConcurrentModification(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	tx, err := aspectstate.NewTransaction(s.state, "system", "network")
+	c.Assert(err, IsNil)
+	err = aspectstate.SetViaAspect(s.state, tx, "system", "network", "wifi-setup", "ssid", "foo")
+	c.Assert(err, IsNil)
+
+	// the databag is written to after the transaction started
+	databag := aspects.NewJSONDataBag()
+	c.Assert(databag.Set("wifi.ssid", "bar"), IsNil)
+	s.state.Set("aspect-databags", map[string]map[string]aspects.JSONDataBag{
+		"system": {"network": databag},
+	})
+
+	err = aspectstate.CommitTransaction(s.state, "system", "network", tx)
+	c.Assert(err, ErrorMatches, `cannot commit changes to aspect bundle system/network: databag was modified concurrently`)
+
+	var ssid string
+	err = aspectstate.Get(s.state, "system", "network", "wifi-setup", "ssid", &ssid)
+	c.Assert(err, IsNil)
+	c.Check(ssid, Equals, "bar")
+}
+
+func (s *aspectTestSuite) TestGetViaAspectSeesPendingWrites(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	tx, err := aspectstate.NewTransaction(s.state, "system", "network")
+	c.Assert(err, IsNil)
+	err = aspectstate.SetViaAspect(s.state, tx, "system", "network", "wifi-setup", "ssid", "foo")
+	c.Assert(err, IsNil)
+
+	var ssid string
+	err = aspectstate.GetViaAspect(s.state, tx, "system", "network", "wifi-setup", "ssid", &ssid)
+	c.Assert(err, IsNil)
+	c.Check(ssid, Equals, "foo")
+
+	err = aspectstate.Get(s.state, "system", "network", "wifi-setup", "ssid", &ssid)
+	c.Assert(err, FitsTypeOf, &aspects.AspectNotFoundError{})
+}
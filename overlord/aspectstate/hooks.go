@@ -0,0 +1,109 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package aspectstate
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/snapcore/snapd/aspects"
+	"github.com/snapcore/snapd/overlord/hookstate"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+// aspectHookHandler is the handler for the change-view, save-view and
+// observe-view hooks.
+type aspectHookHandler struct {
+	context *hookstate.Context
+}
+
+func newAspectHookHandler(context *hookstate.Context) hookstate.Handler {
+	return &aspectHookHandler{context: context}
+}
+
+// Before is called by the HookManager before the hook is run.
+func (h *aspectHookHandler) Before() error {
+	return nil
+}
+
+// Done is called by the HookManager after the hook has exited successfully.
+func (h *aspectHookHandler) Done() error {
+	return nil
+}
+
+// Error is called by the HookManager after the hook has exited non-zero, and
+// includes the error.
+func (h *aspectHookHandler) Error(err error) (bool, error) {
+	return false, nil
+}
+
+// cachedTransaction is the index into the context cache where the
+// transaction of the change the hook runs in is stored.
+type cachedTransaction struct{}
+
+// ContextTransaction returns the transaction of the aspect change in which the
+// hook is running, if that change writes to the given aspect bundle. The
+// returned boolean is false if that's not the case. Modifications made from a
+// "change-view-<plug>" hook are saved into the change once the hook succeeds.
+// Hooks run while undoing the change see the transaction's pristine data.
+// It requires the context to be locked.
+func ContextTransaction(context *hookstate.Context, account, bundleName string) (*aspects.Transaction, bool, error) {
+	task, ok := context.Task()
+	if !ok || task.Change() == nil {
+		return nil, false, nil
+	}
+
+	chg := task.Change()
+	txState, err := getTransactionState(chg)
+	if err != nil {
+		if errors.Is(err, state.ErrNoState) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+
+	if txState.Account != account || txState.BundleName != bundleName {
+		return nil, false, nil
+	}
+
+	if tx, ok := context.Cached(cachedTransaction{}).(*aspects.Transaction); ok {
+		return tx, true, nil
+	}
+
+	tx := txState.Tx
+	if task.Status() == state.UndoingStatus {
+		tx.Clear()
+	} else if IsChangeViewHook(context.HookName()) {
+		context.OnDone(func() error {
+			txState.Tx = tx
+			chg.Set("aspect-transaction", txState)
+			return nil
+		})
+	}
+
+	context.Cache(cachedTransaction{}, tx)
+	return tx, true, nil
+}
+
+// IsChangeViewHook returns whether the hook is allowed to modify the
+// transaction of the change it runs in.
+func IsChangeViewHook(hookName string) bool {
+	return strings.HasPrefix(hookName, "change-view-")
+}
@@ -270,6 +270,25 @@ func SnapDeclaration(s *state.State, snapID string) (*asserts.SnapDeclaration, e
 	return a.(*asserts.SnapDeclaration), nil
 }
 
+// SnapRevisionIntegrity returns the integrity data recorded in the
+// snap-revision assertion for the given snap-id and revision if it is present
+// in the system assertion database. It returns nil if the assertion is not
+// present or records no integrity data.
+func SnapRevisionIntegrity(s *state.State, snapID string, rev snap.Revision) (*asserts.SnapIntegrity, error) {
+	db := DB(s)
+	as, err := db.FindMany(asserts.SnapRevisionType, map[string]string{
+		"snap-id":       snapID,
+		"snap-revision": rev.String(),
+	})
+	if errors.Is(err, &asserts.NotFoundError{}) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return as[0].(*asserts.SnapRevision).SnapIntegrity(), nil
+}
+
 // Publisher returns the account assertion for publisher of the given snap-id if it is present in the system assertion database.
 func Publisher(s *state.State, snapID string) (*asserts.Account, error) {
 	db := DB(s)
@@ -321,6 +340,20 @@ func Store(s *state.State, store string) (*asserts.Store, error) {
 	return a.(*asserts.Store), nil
 }
 
+// AspectBundle returns the aspect-bundle for the given account and bundle name,
+// if it's present in the system assertion database.
+func AspectBundle(s *state.State, account, bundleName string) (*asserts.AspectBundle, error) {
+	db := DB(s)
+	a, err := db.Find(asserts.AspectBundleType, map[string]string{
+		"account-id": account,
+		"name":       bundleName,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return a.(*asserts.AspectBundle), nil
+}
+
 // AutoAliases returns the explicit automatic aliases alias=>app mapping for the given installed snap.
 func AutoAliases(s *state.State, info *snap.Info) (map[string]string, error) {
 	if info.SnapID == "" {
@@ -377,6 +410,8 @@ func delayedCrossMgrInit() {
 	snapstate.EnforceValidationSets = ApplyEnforcedValidationSets
 	// hook helper for enforcing already existing validation set assertions
 	snapstate.EnforceLocalValidationSets = ApplyLocalEnforcedValidationSets
+	// hook retrieving the asserted integrity data of snap revisions
+	snapstate.SnapRevisionIntegrity = SnapRevisionIntegrity
 }
 
 // AutoRefreshAssertions tries to refresh all assertions
@@ -2255,6 +2255,60 @@ func (s *assertMgrSuite) TestSnapDeclaration(c *C) {
 	c.Check(snapDecl.SnapName(), Equals, "foo")
 }
 
+func (s *assertMgrSuite) TestSnapRevisionIntegrity(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	err := assertstate.Add(s.state, s.storeSigning.StoreAccountKey(""))
+	c.Assert(err, IsNil)
+	err = assertstate.Add(s.state, s.dev1Acct)
+	c.Assert(err, IsNil)
+	err = assertstate.Add(s.state, s.snapDecl(c, "foo", nil))
+	c.Assert(err, IsNil)
+
+	integrityDigest := makeDigest(3)
+	for rev, integrity := range map[int]interface{}{
+		1: nil,
+		2: map[string]interface{}{
+			"sha3-384": integrityDigest,
+			"size":     "8192",
+		},
+	} {
+		headers := map[string]interface{}{
+			"snap-id":       "foo-id",
+			"snap-sha3-384": makeDigest(rev),
+			"snap-size":     "1000",
+			"snap-revision": fmt.Sprintf("%d", rev),
+			"developer-id":  s.dev1Acct.AccountID(),
+			"timestamp":     time.Now().Format(time.RFC3339),
+		}
+		if integrity != nil {
+			headers["integrity"] = integrity
+		}
+		snapRev, err := s.storeSigning.Sign(asserts.SnapRevisionType, headers, nil, "")
+		c.Assert(err, IsNil)
+		err = assertstate.Add(s.state, snapRev)
+		c.Assert(err, IsNil)
+	}
+
+	// no assertion
+	snapIntegrity, err := assertstate.SnapRevisionIntegrity(s.state, "foo-id", snap.R(3))
+	c.Assert(err, IsNil)
+	c.Check(snapIntegrity, IsNil)
+
+	// no integrity data asserted
+	snapIntegrity, err = assertstate.SnapRevisionIntegrity(s.state, "foo-id", snap.R(1))
+	c.Assert(err, IsNil)
+	c.Check(snapIntegrity, IsNil)
+
+	snapIntegrity, err = assertstate.SnapRevisionIntegrity(s.state, "foo-id", snap.R(2))
+	c.Assert(err, IsNil)
+	c.Check(snapIntegrity, DeepEquals, &asserts.SnapIntegrity{
+		SHA3_384: integrityDigest,
+		Size:     8192,
+	})
+}
+
 func (s *assertMgrSuite) TestAutoAliasesTemporaryFallback(c *C) {
 	s.state.Lock()
 	defer s.state.Unlock()
@@ -2464,6 +2518,44 @@ func (s *assertMgrSuite) TestStore(c *C) {
 	c.Check(store.Store(), Equals, "foo")
 }
 
+func (s *assertMgrSuite) TestAspectBundle(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	err := assertstate.Add(s.state, s.storeSigning.StoreAccountKey(""))
+	c.Assert(err, IsNil)
+	err = assertstate.Add(s.state, s.dev1Acct)
+	c.Assert(err, IsNil)
+	err = assertstate.Add(s.state, s.dev1AcctKey)
+	c.Assert(err, IsNil)
+
+	headers := map[string]interface{}{
+		"authority-id": s.dev1Acct.AccountID(),
+		"account-id":   s.dev1Acct.AccountID(),
+		"name":         "foo",
+		"aspects": map[string]interface{}{
+			"bar": []interface{}{
+				map[string]interface{}{"name": "baz", "path": "baz"},
+			},
+		},
+		"timestamp": time.Now().Format(time.RFC3339),
+	}
+	body := []byte(`{"storage": {"schema": {"baz": "any"}}}`)
+	bundleAs, err := s.dev1Signing.Sign(asserts.AspectBundleType, headers, body, "")
+	c.Assert(err, IsNil)
+	err = assertstate.Add(s.state, bundleAs)
+	c.Assert(err, IsNil)
+
+	_, err = assertstate.AspectBundle(s.state, s.dev1Acct.AccountID(), "other")
+	c.Check(errors.Is(err, &asserts.NotFoundError{}), Equals, true)
+
+	bundle, err := assertstate.AspectBundle(s.state, s.dev1Acct.AccountID(), "foo")
+	c.Assert(err, IsNil)
+	c.Check(bundle.Name(), Equals, "foo")
+	c.Check(bundle.AccountID(), Equals, s.dev1Acct.AccountID())
+	c.Check(bundle.Bundle().Aspect("bar"), NotNil)
+}
+
 // validation-sets related tests
 
 func (s *assertMgrSuite) TestRefreshValidationSetAssertionsNop(c *C) {
@@ -549,6 +549,7 @@ var (
 // make sense to store it the snapd state. Examples are:
 // - system.timezone
 // - system.hostname
+// - system.timesync.synchronized
 func RegisterExternalConfig(snapName, key string, vf ExternalCfgFunc) error {
 	externalConfigMu.Lock()
 	defer externalConfigMu.Unlock()
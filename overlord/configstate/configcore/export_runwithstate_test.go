@@ -57,3 +57,9 @@ func MockDevicestateResetSession(f func(*state.State) error) (restore func()) {
 	devicestateResetSession = f
 	return restore
 }
+
+func MockDevicestateUseOfflineStore(f func(st *state.State, dir string)) (restore func()) {
+	restore = testutil.Backup(&devicestateUseOfflineStore)
+	devicestateUseOfflineStore = f
+	return restore
+}
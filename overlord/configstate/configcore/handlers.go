@@ -102,6 +102,15 @@ func init() {
 	// system.timezone
 	addFSOnlyHandler(validateTimezoneSettings, handleTimezoneConfiguration, coreOnly)
 
+	// system.locale
+	addFSOnlyHandler(validateLocaleSettings, handleLocaleConfiguration, coreOnly)
+
+	// system.keyboard.{layout,variant,model,options}
+	addFSOnlyHandler(validateKeyboardSettings, handleKeyboardConfiguration, coreOnly)
+
+	// system.timesync.{servers,fallback-servers,disable}
+	addFSOnlyHandler(validateTimesyncSettings, handleTimesyncConfiguration, coreOnly)
+
 	// system.hostname - note that the validation is done via hostnamectl
 	// when applying so there is no validation handler, see LP:1952740
 	addFSOnlyHandler(nil, handleHostnameConfiguration, coreOnly)
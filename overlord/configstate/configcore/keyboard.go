@@ -0,0 +1,221 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configcore
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/strutil"
+	"github.com/snapcore/snapd/sysconfig"
+)
+
+var keyboardOptions = []string{"layout", "variant", "model", "options"}
+
+func init() {
+	// add supported configuration of this module
+	for _, opt := range keyboardOptions {
+		supportedConfigurations["core.system.keyboard."+opt] = true
+	}
+}
+
+const (
+	// keyboardFile is the file read by (Ubuntu's) systemd-localed and
+	// by console-setup for the keymap, see keyboard(5)
+	keyboardFile = "/etc/default/keyboard"
+	// xkbSymbolsDir is where the available layouts are defined
+	xkbSymbolsDir = "/usr/share/X11/xkb/symbols"
+
+	defaultKeyboardModel = "pc105"
+
+	keyboardFileHeader = `# This file was generated by snapd from the system.keyboard.* options,
+# see keyboard(5)
+`
+)
+
+// knownKeyboardLayouts are the layouts defined by xkeyboard-config, which
+// the layouts are checked against when the keymap data is not available,
+// as is the case on Ubuntu Core where the base does not ship it.
+var knownKeyboardLayouts = []string{
+	"af", "al", "am", "ara", "at", "au", "az", "ba", "bd", "be", "bg",
+	"br", "brai", "bt", "bw", "by", "ca", "cd", "ch", "cm", "cn", "cz",
+	"de", "dk", "dz", "ee", "epo", "es", "et", "eu", "fi", "fo", "fr",
+	"gb", "ge", "gh", "gn", "gr", "hr", "hu", "id", "ie", "il", "in",
+	"iq", "ir", "is", "it", "jp", "ke", "kg", "kh", "kr", "kz", "la",
+	"latam", "lk", "lt", "lv", "ma", "mao", "md", "me", "mk", "ml", "mm",
+	"mn", "mt", "mv", "my", "ng", "nl", "no", "np", "ph", "pk", "pl",
+	"pt", "ro", "rs", "ru", "se", "si", "sk", "sn", "sy", "tg", "th",
+	"tj", "tm", "tr", "tw", "tz", "ua", "us", "uz", "vn", "za",
+}
+
+var (
+	validKeyboardName    = regexp.MustCompile(`^[a-zA-Z0-9_-]*$`).MatchString
+	validKeyboardOptions = regexp.MustCompile(`^([a-zA-Z0-9_-]+:[a-zA-Z0-9_-]+(,[a-zA-Z0-9_-]+:[a-zA-Z0-9_-]+)*)?$`).MatchString
+)
+
+type keyboardSettings struct {
+	layouts  []string
+	variants []string
+	model    string
+	options  string
+}
+
+func keyboardSettingsFromConfig(tr ConfGetter) (*keyboardSettings, error) {
+	values := make(map[string]string, len(keyboardOptions))
+	for _, opt := range keyboardOptions {
+		value, err := coreCfg(tr, "system.keyboard."+opt)
+		if err != nil {
+			return nil, err
+		}
+		values[opt] = value
+	}
+
+	kbd := &keyboardSettings{
+		model:   values["model"],
+		options: values["options"],
+	}
+	if values["layout"] != "" {
+		kbd.layouts = strings.Split(values["layout"], ",")
+	}
+	if values["variant"] != "" {
+		kbd.variants = strings.Split(values["variant"], ",")
+	}
+	return kbd, nil
+}
+
+func (kbd *keyboardSettings) isSet() bool {
+	return len(kbd.layouts) > 0 || len(kbd.variants) > 0 || kbd.model != "" || kbd.options != ""
+}
+
+func validateKeyboardSettings(tr ConfGetter) error {
+	kbd, err := keyboardSettingsFromConfig(tr)
+	if err != nil {
+		return err
+	}
+	if !kbd.isSet() {
+		return nil
+	}
+	if len(kbd.layouts) == 0 {
+		return fmt.Errorf("cannot set keyboard configuration without system.keyboard.layout")
+	}
+	for _, layout := range kbd.layouts {
+		if layout == "" || !validKeyboardName(layout) {
+			return fmt.Errorf("cannot set keyboard layout %q: name not valid", layout)
+		}
+	}
+	if len(kbd.variants) > len(kbd.layouts) {
+		return fmt.Errorf("cannot set keyboard variant %q: more variants than layouts", strings.Join(kbd.variants, ","))
+	}
+	for _, variant := range kbd.variants {
+		if !validKeyboardName(variant) {
+			return fmt.Errorf("cannot set keyboard variant %q: name not valid", variant)
+		}
+	}
+	if !validKeyboardName(kbd.model) {
+		return fmt.Errorf("cannot set keyboard model %q: name not valid", kbd.model)
+	}
+	if !validKeyboardOptions(kbd.options) {
+		return fmt.Errorf("cannot set keyboard options %q: options not valid", kbd.options)
+	}
+	return nil
+}
+
+// checkKeyboardAvailable checks that the layouts, and their variants if
+// any, are defined under the given root. When the root carries no keymap
+// data at all, as is the case on Ubuntu Core or when preparing an image,
+// the layouts are checked against the ones known to xkeyboard-config
+// instead, and the variants cannot be checked.
+func checkKeyboardAvailable(rootDir string, kbd *keyboardSettings) error {
+	symbolsDir := filepath.Join(rootDir, xkbSymbolsDir)
+	if !osutil.IsDirectory(symbolsDir) {
+		for _, layout := range kbd.layouts {
+			if !strutil.ListContains(knownKeyboardLayouts, layout) {
+				return fmt.Errorf("cannot set keyboard layout %q: layout not available", layout)
+			}
+		}
+		return nil
+	}
+	for i, layout := range kbd.layouts {
+		symbols, err := ioutil.ReadFile(filepath.Join(symbolsDir, layout))
+		if os.IsNotExist(err) {
+			return fmt.Errorf("cannot set keyboard layout %q: layout not available", layout)
+		}
+		if err != nil {
+			return err
+		}
+		if i >= len(kbd.variants) || kbd.variants[i] == "" {
+			continue
+		}
+		variant := kbd.variants[i]
+		if !bytes.Contains(symbols, []byte(fmt.Sprintf("xkb_symbols %q", variant))) {
+			return fmt.Errorf("cannot set keyboard variant %q: variant not available for layout %q", variant, layout)
+		}
+	}
+	return nil
+}
+
+func handleKeyboardConfiguration(_ sysconfig.Device, tr ConfGetter, opts *fsOnlyContext) error {
+	kbd, err := keyboardSettingsFromConfig(tr)
+	if err != nil {
+		return err
+	}
+
+	rootDir := dirs.GlobalRootDir
+	if opts != nil {
+		rootDir = opts.RootDir
+	}
+	keyboardPath := filepath.Join(rootDir, keyboardFile)
+	if !kbd.isSet() {
+		// go back to the keymap of the base
+		if err := removeGeneratedFile(keyboardPath, keyboardFileHeader); err != nil {
+			return fmt.Errorf("cannot remove keyboard configuration: %v", err)
+		}
+		return nil
+	}
+
+	if err := checkKeyboardAvailable(rootDir, kbd); err != nil {
+		return err
+	}
+
+	model := kbd.model
+	if model == "" {
+		model = defaultKeyboardModel
+	}
+	content := fmt.Sprintf(`%sXKBMODEL="%s"
+XKBLAYOUT="%s"
+XKBVARIANT="%s"
+XKBOPTIONS="%s"
+`, keyboardFileHeader, model, strings.Join(kbd.layouts, ","), strings.Join(kbd.variants, ","), kbd.options)
+
+	if err := os.MkdirAll(filepath.Dir(keyboardPath), 0755); err != nil {
+		return err
+	}
+	if err := osutil.AtomicWriteFile(keyboardPath, []byte(content), 0644, 0); err != nil {
+		return fmt.Errorf("cannot write keyboard configuration: %v", err)
+	}
+	return nil
+}
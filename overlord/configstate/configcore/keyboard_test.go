@@ -0,0 +1,240 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configcore_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/overlord/configstate/configcore"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type keyboardSuite struct {
+	configcoreSuite
+
+	keyboardPath string
+}
+
+var _ = Suite(&keyboardSuite{})
+
+const mockXkbSymbolsDe = `default
+xkb_symbols "basic" {
+    include "latin(type4)"
+};
+
+partial alphanumeric_keys
+xkb_symbols "nodeadkeys" {
+    include "de(basic)"
+};
+`
+
+func mockXkbSymbols(c *C, rootDir string) {
+	symbolsDir := filepath.Join(rootDir, "/usr/share/X11/xkb/symbols")
+	c.Assert(os.MkdirAll(symbolsDir, 0755), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(symbolsDir, "us"), []byte(`default
+xkb_symbols "basic" {
+};
+`), 0644), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(symbolsDir, "de"), []byte(mockXkbSymbolsDe), 0644), IsNil)
+}
+
+func (s *keyboardSuite) SetUpTest(c *C) {
+	s.configcoreSuite.SetUpTest(c)
+
+	mockXkbSymbols(c, dirs.GlobalRootDir)
+	s.keyboardPath = filepath.Join(dirs.GlobalRootDir, "/etc/default/keyboard")
+}
+
+func (s *keyboardSuite) TestConfigureKeyboardInvalid(c *C) {
+	for _, tc := range []struct {
+		changes map[string]interface{}
+		err     string
+	}{
+		{map[string]interface{}{"system.keyboard.variant": "nodeadkeys"}, `cannot set keyboard configuration without system.keyboard.layout`},
+		{map[string]interface{}{"system.keyboard.layout": "../us"}, `cannot set keyboard layout "../us": name not valid`},
+		{map[string]interface{}{"system.keyboard.layout": "us,"}, `cannot set keyboard layout "": name not valid`},
+		{map[string]interface{}{"system.keyboard.layout": "us", "system.keyboard.variant": "a,b"}, `cannot set keyboard variant "a,b": more variants than layouts`},
+		{map[string]interface{}{"system.keyboard.layout": "us", "system.keyboard.variant": "a b"}, `cannot set keyboard variant "a b": name not valid`},
+		{map[string]interface{}{"system.keyboard.layout": "us", "system.keyboard.model": "pc\"105"}, `cannot set keyboard model "pc\\"105": name not valid`},
+		{map[string]interface{}{"system.keyboard.layout": "us", "system.keyboard.options": "nocaps"}, `cannot set keyboard options "nocaps": options not valid`},
+	} {
+		err := configcore.FilesystemOnlyRun(coreDev, &mockConf{
+			state:   s.state,
+			changes: tc.changes,
+		})
+		c.Check(err, ErrorMatches, tc.err, Commentf("%v", tc.changes))
+	}
+	c.Check(s.keyboardPath, testutil.FileAbsent)
+}
+
+func (s *keyboardSuite) TestConfigureKeyboardNotAvailable(c *C) {
+	for _, tc := range []struct {
+		changes map[string]interface{}
+		err     string
+	}{
+		{map[string]interface{}{"system.keyboard.layout": "fr"}, `cannot set keyboard layout "fr": layout not available`},
+		{map[string]interface{}{"system.keyboard.layout": "us,fr"}, `cannot set keyboard layout "fr": layout not available`},
+		{map[string]interface{}{"system.keyboard.layout": "de", "system.keyboard.variant": "dvorak"}, `cannot set keyboard variant "dvorak": variant not available for layout "de"`},
+		{map[string]interface{}{"system.keyboard.layout": "us,de", "system.keyboard.variant": ",dvorak"}, `cannot set keyboard variant "dvorak": variant not available for layout "de"`},
+	} {
+		err := configcore.FilesystemOnlyRun(coreDev, &mockConf{
+			state:   s.state,
+			changes: tc.changes,
+		})
+		c.Check(err, ErrorMatches, tc.err, Commentf("%v", tc.changes))
+	}
+	c.Check(s.keyboardPath, testutil.FileAbsent)
+}
+
+func (s *keyboardSuite) TestConfigureKeyboardLayoutOnly(c *C) {
+	err := configcore.FilesystemOnlyRun(coreDev, &mockConf{
+		state: s.state,
+		changes: map[string]interface{}{
+			"system.keyboard.layout": "de",
+		},
+	})
+	c.Assert(err, IsNil)
+	c.Check(s.keyboardPath, testutil.FileEquals, `# This file was generated by snapd from the system.keyboard.* options,
+# see keyboard(5)
+XKBMODEL="pc105"
+XKBLAYOUT="de"
+XKBVARIANT=""
+XKBOPTIONS=""
+`)
+}
+
+func (s *keyboardSuite) TestConfigureKeyboardAll(c *C) {
+	err := configcore.FilesystemOnlyRun(coreDev, &mockConf{
+		state: s.state,
+		changes: map[string]interface{}{
+			"system.keyboard.layout":  "us,de",
+			"system.keyboard.variant": ",nodeadkeys",
+			"system.keyboard.model":   "pc104",
+			"system.keyboard.options": "grp:alt_shift_toggle,ctrl:nocaps",
+		},
+	})
+	c.Assert(err, IsNil)
+	c.Check(s.keyboardPath, testutil.FileEquals, `# This file was generated by snapd from the system.keyboard.* options,
+# see keyboard(5)
+XKBMODEL="pc104"
+XKBLAYOUT="us,de"
+XKBVARIANT=",nodeadkeys"
+XKBOPTIONS="grp:alt_shift_toggle,ctrl:nocaps"
+`)
+}
+
+func (s *keyboardSuite) TestConfigureKeyboardUnsetNoop(c *C) {
+	err := configcore.FilesystemOnlyRun(coreDev, &mockConf{
+		state: s.state,
+		changes: map[string]interface{}{
+			"system.keyboard.layout": "",
+		},
+	})
+	c.Assert(err, IsNil)
+	c.Check(s.keyboardPath, testutil.FileAbsent)
+}
+
+func (s *keyboardSuite) TestConfigureKeyboardUnsetRemovesFile(c *C) {
+	for _, layout := range []string{"de", ""} {
+		err := configcore.FilesystemOnlyRun(coreDev, &mockConf{
+			state: s.state,
+			changes: map[string]interface{}{
+				"system.keyboard.layout": layout,
+			},
+		})
+		c.Assert(err, IsNil)
+	}
+	c.Check(s.keyboardPath, testutil.FileAbsent)
+
+	// the keymap of the base is left alone
+	c.Assert(ioutil.WriteFile(s.keyboardPath, []byte("XKBLAYOUT=\"us\"\n"), 0644), IsNil)
+	err := configcore.FilesystemOnlyRun(coreDev, &mockConf{
+		state: s.state,
+		changes: map[string]interface{}{
+			"system.keyboard.layout": "",
+		},
+	})
+	c.Assert(err, IsNil)
+	c.Check(s.keyboardPath, testutil.FileEquals, "XKBLAYOUT=\"us\"\n")
+}
+
+func (s *keyboardSuite) TestConfigureKeyboardClassicNoop(c *C) {
+	err := configcore.FilesystemOnlyRun(classicDev, &mockConf{
+		state: s.state,
+		changes: map[string]interface{}{
+			"system.keyboard.layout": "de",
+		},
+	})
+	c.Assert(err, IsNil)
+	c.Check(s.keyboardPath, testutil.FileAbsent)
+}
+
+func (s *keyboardSuite) TestFilesystemOnlyApply(c *C) {
+	conf := configcore.PlainCoreConfig(map[string]interface{}{
+		"system.keyboard.layout":  "de",
+		"system.keyboard.variant": "nodeadkeys",
+	})
+	tmpDir := c.MkDir()
+	mockXkbSymbols(c, tmpDir)
+	c.Assert(configcore.FilesystemOnlyApply(coreDev, tmpDir, conf), IsNil)
+
+	c.Check(filepath.Join(tmpDir, "/etc/default/keyboard"), testutil.FileEquals, `# This file was generated by snapd from the system.keyboard.* options,
+# see keyboard(5)
+XKBMODEL="pc105"
+XKBLAYOUT="de"
+XKBVARIANT="nodeadkeys"
+XKBOPTIONS=""
+`)
+}
+
+func (s *keyboardSuite) TestFilesystemOnlyApplyNotAvailable(c *C) {
+	conf := configcore.PlainCoreConfig(map[string]interface{}{
+		"system.keyboard.layout": "fr",
+	})
+	tmpDir := c.MkDir()
+	mockXkbSymbols(c, tmpDir)
+	err := configcore.FilesystemOnlyApply(coreDev, tmpDir, conf)
+	c.Assert(err, ErrorMatches, `cannot set keyboard layout "fr": layout not available`)
+}
+
+func (s *keyboardSuite) TestFilesystemOnlyApplyNoKeymapData(c *C) {
+	// the base of Ubuntu Core, like the root when preparing an image,
+	// carries no keymap data so the layouts of xkeyboard-config are
+	// used instead
+	conf := configcore.PlainCoreConfig(map[string]interface{}{
+		"system.keyboard.layout":  "fr,latam",
+		"system.keyboard.variant": "bepo",
+	})
+	tmpDir := c.MkDir()
+	c.Assert(configcore.FilesystemOnlyApply(coreDev, tmpDir, conf), IsNil)
+
+	c.Check(filepath.Join(tmpDir, "/etc/default/keyboard"), testutil.FileContains, `XKBLAYOUT="fr,latam"`)
+
+	conf = configcore.PlainCoreConfig(map[string]interface{}{
+		"system.keyboard.layout": "fr,xx",
+	})
+	err := configcore.FilesystemOnlyApply(coreDev, tmpDir, conf)
+	c.Assert(err, ErrorMatches, `cannot set keyboard layout "xx": layout not available`)
+}
@@ -0,0 +1,268 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configcore
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/sysconfig"
+)
+
+func init() {
+	// add supported configuration of this module
+	supportedConfigurations["core.system.locale"] = true
+}
+
+const (
+	// localeFile is the file read by (Ubuntu's) systemd-localed and
+	// by pam_env for the system locale.
+	localeFile = "/etc/default/locale"
+	// localeArchive is where localedef adds the compiled locales by
+	// default, instead of a directory per locale.
+	localeArchive = "locale-archive"
+
+	localeFileHeader = "# This file was generated by snapd from the system.locale option\n"
+)
+
+// language[_territory][.codeset][@modifier], see locale(7)
+var validLocale = regexp.MustCompile(`^(C|POSIX|[a-zA-Z]{2,3}(_[a-zA-Z0-9]{2,3})?)(\.[a-zA-Z0-9-]+)?(@[a-zA-Z0-9]+)?$`).MatchString
+
+func validateLocaleSettings(tr ConfGetter) error {
+	locale, err := coreCfg(tr, "system.locale")
+	if err != nil {
+		return err
+	}
+	if locale == "" {
+		return nil
+	}
+	if !validLocale(locale) {
+		return fmt.Errorf("cannot set locale %q: name not valid", locale)
+	}
+	return nil
+}
+
+// normalizeLocaleCodeset normalizes the codeset of the locale the same way
+// glibc does when looking up the compiled locale, e.g. en_US.UTF-8 becomes
+// en_US.utf8.
+func normalizeLocaleCodeset(locale string) string {
+	idx := strings.IndexRune(locale, '.')
+	if idx < 0 {
+		return locale
+	}
+	codeset := locale[idx+1:]
+	modifier := ""
+	if i := strings.IndexRune(codeset, '@'); i >= 0 {
+		codeset, modifier = codeset[:i], codeset[i:]
+	}
+	normalized := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'A' && r <= 'Z':
+			return r - 'A' + 'a'
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			return r
+		}
+		return -1
+	}, codeset)
+	return locale[:idx+1] + normalized + modifier
+}
+
+// localeArchiveMagic starts the header of a locale archive, see
+// locarchive.h in glibc.
+const localeArchiveMagic = 0xde020109
+
+// localeArchiveHeader is the start of the header of a locale archive,
+// up to the part describing the table of the names of the locales.
+type localeArchiveHeader struct {
+	Magic          uint32
+	Serial         uint32
+	NamehashOffset uint32
+	NamehashUsed   uint32
+	NamehashSize   uint32
+}
+
+// localeArchiveNamehashEntry is an entry of the table of the names of the
+// locales in a locale archive; empty entries have no name.
+type localeArchiveNamehashEntry struct {
+	Hashval      uint32
+	NameOffset   uint32
+	LocrecOffset uint32
+}
+
+// localeArchiveContains reports whether the locale archive holds any of
+// the given locales. The archive is written in the byte order of the
+// system it was compiled on, which is found out from its magic.
+func localeArchiveContains(archivePath string, locales ...string) (bool, error) {
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	var header localeArchiveHeader
+	var order binary.ByteOrder
+	for _, order = range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return false, err
+		}
+		if err := binary.Read(f, order, &header); err != nil {
+			return false, fmt.Errorf("cannot read locale archive header: %v", err)
+		}
+		if header.Magic == localeArchiveMagic {
+			break
+		}
+	}
+	if header.Magic != localeArchiveMagic {
+		return false, fmt.Errorf("cannot read locale archive: invalid magic %#x", header.Magic)
+	}
+
+	fi, err := f.Stat()
+	if err != nil {
+		return false, err
+	}
+	namehashLen := int64(header.NamehashSize) * int64(binary.Size(localeArchiveNamehashEntry{}))
+	if int64(header.NamehashOffset)+namehashLen > fi.Size() {
+		return false, fmt.Errorf("cannot read locale archive: invalid names table")
+	}
+	entries := make([]localeArchiveNamehashEntry, header.NamehashSize)
+	namehash := io.NewSectionReader(f, int64(header.NamehashOffset), namehashLen)
+	if err := binary.Read(namehash, order, entries); err != nil {
+		return false, fmt.Errorf("cannot read locale archive names: %v", err)
+	}
+	// the longest name we are looking for, and its terminator
+	maxLen := 0
+	for _, locale := range locales {
+		if len(locale) > maxLen {
+			maxLen = len(locale)
+		}
+	}
+	name := make([]byte, maxLen+1)
+	for _, entry := range entries {
+		if entry.NameOffset == 0 {
+			continue
+		}
+		n, err := f.ReadAt(name, int64(entry.NameOffset))
+		if err != nil && err != io.EOF {
+			return false, fmt.Errorf("cannot read locale archive names: %v", err)
+		}
+		end := bytes.IndexByte(name[:n], 0)
+		if end < 0 {
+			// longer than any of the locales, or truncated
+			continue
+		}
+		for _, locale := range locales {
+			if string(name[:end]) == locale {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// checkLocaleAvailable checks that the locale has been compiled under the
+// given root, either into a directory of its own or into the locale
+// archive. When the root carries no locale data at all, as is the case
+// when preparing an image, there is nothing to check against.
+func checkLocaleAvailable(rootDir, locale string) error {
+	switch locale {
+	case "C", "POSIX":
+		// built into the C library
+		return nil
+	}
+	localeDir := filepath.Join(rootDir, "/usr/lib/locale")
+	if !osutil.IsDirectory(localeDir) {
+		return nil
+	}
+	names := []string{locale, normalizeLocaleCodeset(locale)}
+	for _, name := range names {
+		if osutil.IsDirectory(filepath.Join(localeDir, name)) {
+			return nil
+		}
+	}
+	archivePath := filepath.Join(localeDir, localeArchive)
+	if osutil.FileExists(archivePath) {
+		found, err := localeArchiveContains(archivePath, names...)
+		if err != nil {
+			return fmt.Errorf("cannot set locale %q: %v", locale, err)
+		}
+		if found {
+			return nil
+		}
+	}
+	return fmt.Errorf("cannot set locale %q: locale not available", locale)
+}
+
+// removeGeneratedFile removes the file if it was generated by snapd, as
+// told by its header, leaving alone the one shipped by the base.
+func removeGeneratedFile(path, header string) error {
+	content, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !bytes.HasPrefix(content, []byte(header)) {
+		return nil
+	}
+	return os.Remove(path)
+}
+
+func handleLocaleConfiguration(_ sysconfig.Device, tr ConfGetter, opts *fsOnlyContext) error {
+	locale, err := coreCfg(tr, "system.locale")
+	if err != nil {
+		return err
+	}
+
+	rootDir := dirs.GlobalRootDir
+	if opts != nil {
+		rootDir = opts.RootDir
+	}
+	localePath := filepath.Join(rootDir, localeFile)
+	if locale == "" {
+		// go back to the locale of the base
+		if err := removeGeneratedFile(localePath, localeFileHeader); err != nil {
+			return fmt.Errorf("cannot remove locale: %v", err)
+		}
+		return nil
+	}
+
+	if err := checkLocaleAvailable(rootDir, locale); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(localePath), 0755); err != nil {
+		return err
+	}
+	content := fmt.Sprintf("%sLANG=%s\n", localeFileHeader, locale)
+	if err := osutil.AtomicWriteFile(localePath, []byte(content), 0644, 0); err != nil {
+		return fmt.Errorf("cannot write locale: %v", err)
+	}
+	return nil
+}
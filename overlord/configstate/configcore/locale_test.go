@@ -0,0 +1,238 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configcore_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/overlord/configstate/configcore"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type localeSuite struct {
+	configcoreSuite
+}
+
+var _ = Suite(&localeSuite{})
+
+func mockLocales(c *C, rootDir string, locales ...string) {
+	for _, locale := range locales {
+		c.Assert(os.MkdirAll(filepath.Join(rootDir, "/usr/lib/locale", locale), 0755), IsNil)
+	}
+}
+
+// mockLocaleArchive writes a locale archive holding the given locales,
+// with just enough of the format of glibc to list them.
+func mockLocaleArchive(c *C, rootDir string, order binary.ByteOrder, locales ...string) {
+	const namehashOffset = 64
+	// leave an empty entry, as in a real archive
+	namehashSize := len(locales) + 1
+	stringOffset := namehashOffset + 12*namehashSize
+
+	buf := new(bytes.Buffer)
+	header := []uint32{0xde020109, 0, namehashOffset, uint32(len(locales)), uint32(namehashSize)}
+	c.Assert(binary.Write(buf, order, header), IsNil)
+	buf.Write(make([]byte, namehashOffset-buf.Len()))
+	var names []byte
+	for _, locale := range locales {
+		entry := []uint32{0, uint32(stringOffset + len(names)), 0}
+		c.Assert(binary.Write(buf, order, entry), IsNil)
+		names = append(names, locale...)
+		names = append(names, 0)
+	}
+	c.Assert(binary.Write(buf, order, []uint32{0, 0, 0}), IsNil)
+	buf.Write(names)
+
+	localeDir := filepath.Join(rootDir, "/usr/lib/locale")
+	c.Assert(os.MkdirAll(localeDir, 0755), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(localeDir, "locale-archive"), buf.Bytes(), 0644), IsNil)
+}
+
+const localeFileHeader = "# This file was generated by snapd from the system.locale option\n"
+
+func (s *localeSuite) SetUpTest(c *C) {
+	s.configcoreSuite.SetUpTest(c)
+
+	mockLocales(c, dirs.GlobalRootDir, "C.utf8", "en_US.utf8", "de_DE.utf8@euro")
+}
+
+func (s *localeSuite) TestConfigureLocaleInvalid(c *C) {
+	for _, locale := range []string{
+		"no spaces", "en_US.UTF-8;rm", "../../etc", "e", "en_US.",
+	} {
+		err := configcore.FilesystemOnlyRun(coreDev, &mockConf{
+			state: s.state,
+			changes: map[string]interface{}{
+				"system.locale": locale,
+			},
+		})
+		c.Check(err, ErrorMatches, `cannot set locale ".*": name not valid`, Commentf("%s", locale))
+	}
+	c.Check(filepath.Join(dirs.GlobalRootDir, "/etc/default/locale"), testutil.FileAbsent)
+}
+
+func (s *localeSuite) TestConfigureLocaleNotAvailable(c *C) {
+	err := configcore.FilesystemOnlyRun(coreDev, &mockConf{
+		state: s.state,
+		changes: map[string]interface{}{
+			"system.locale": "fr_FR.UTF-8",
+		},
+	})
+	c.Assert(err, ErrorMatches, `cannot set locale "fr_FR.UTF-8": locale not available`)
+	c.Check(filepath.Join(dirs.GlobalRootDir, "/etc/default/locale"), testutil.FileAbsent)
+}
+
+func (s *localeSuite) TestConfigureLocaleIntegration(c *C) {
+	for _, locale := range []string{
+		"C", "POSIX", "C.UTF-8", "en_US.UTF-8", "en_US.utf8", "de_DE.UTF-8@euro",
+	} {
+		err := configcore.FilesystemOnlyRun(coreDev, &mockConf{
+			state: s.state,
+			changes: map[string]interface{}{
+				"system.locale": locale,
+			},
+		})
+		c.Assert(err, IsNil, Commentf("%s", locale))
+		c.Check(filepath.Join(dirs.GlobalRootDir, "/etc/default/locale"), testutil.FileEquals, localeFileHeader+"LANG="+locale+"\n")
+	}
+}
+
+func (s *localeSuite) TestConfigureLocaleUnsetNoop(c *C) {
+	err := configcore.FilesystemOnlyRun(coreDev, &mockConf{
+		state: s.state,
+		changes: map[string]interface{}{
+			"system.locale": "",
+		},
+	})
+	c.Assert(err, IsNil)
+	c.Check(filepath.Join(dirs.GlobalRootDir, "/etc/default/locale"), testutil.FileAbsent)
+}
+
+func (s *localeSuite) TestConfigureLocaleUnsetRemovesFile(c *C) {
+	localePath := filepath.Join(dirs.GlobalRootDir, "/etc/default/locale")
+	for _, locale := range []string{"en_US.UTF-8", ""} {
+		err := configcore.FilesystemOnlyRun(coreDev, &mockConf{
+			state: s.state,
+			changes: map[string]interface{}{
+				"system.locale": locale,
+			},
+		})
+		c.Assert(err, IsNil)
+	}
+	c.Check(localePath, testutil.FileAbsent)
+
+	// the locale of the base is left alone
+	c.Assert(ioutil.WriteFile(localePath, []byte("LANG=C.UTF-8\n"), 0644), IsNil)
+	err := configcore.FilesystemOnlyRun(coreDev, &mockConf{
+		state: s.state,
+		changes: map[string]interface{}{
+			"system.locale": "",
+		},
+	})
+	c.Assert(err, IsNil)
+	c.Check(localePath, testutil.FileEquals, "LANG=C.UTF-8\n")
+}
+
+func (s *localeSuite) TestConfigureLocaleClassicNoop(c *C) {
+	err := configcore.FilesystemOnlyRun(classicDev, &mockConf{
+		state: s.state,
+		changes: map[string]interface{}{
+			"system.locale": "en_US.UTF-8",
+		},
+	})
+	c.Assert(err, IsNil)
+	c.Check(filepath.Join(dirs.GlobalRootDir, "/etc/default/locale"), testutil.FileAbsent)
+}
+
+func (s *localeSuite) TestFilesystemOnlyApply(c *C) {
+	conf := configcore.PlainCoreConfig(map[string]interface{}{
+		"system.locale": "en_US.UTF-8",
+	})
+	tmpDir := c.MkDir()
+	mockLocales(c, tmpDir, "en_US.utf8")
+	c.Assert(configcore.FilesystemOnlyApply(coreDev, tmpDir, conf), IsNil)
+
+	c.Check(filepath.Join(tmpDir, "/etc/default/locale"), testutil.FileEquals, localeFileHeader+"LANG=en_US.UTF-8\n")
+}
+
+func (s *localeSuite) TestFilesystemOnlyApplyNotAvailable(c *C) {
+	conf := configcore.PlainCoreConfig(map[string]interface{}{
+		"system.locale": "fr_FR.UTF-8",
+	})
+	tmpDir := c.MkDir()
+	mockLocales(c, tmpDir, "en_US.utf8")
+	err := configcore.FilesystemOnlyApply(coreDev, tmpDir, conf)
+	c.Assert(err, ErrorMatches, `cannot set locale "fr_FR.UTF-8": locale not available`)
+}
+
+func (s *localeSuite) TestFilesystemOnlyApplyNoLocaleData(c *C) {
+	// when preparing an image the base is not unpacked under the root
+	conf := configcore.PlainCoreConfig(map[string]interface{}{
+		"system.locale": "fr_FR.UTF-8",
+	})
+	tmpDir := c.MkDir()
+	c.Assert(configcore.FilesystemOnlyApply(coreDev, tmpDir, conf), IsNil)
+
+	c.Check(filepath.Join(tmpDir, "/etc/default/locale"), testutil.FileEquals, localeFileHeader+"LANG=fr_FR.UTF-8\n")
+}
+
+func (s *localeSuite) TestFilesystemOnlyApplyLocaleArchive(c *C) {
+	// locales compiled into the archive only, as on Ubuntu
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		tmpDir := c.MkDir()
+		mockLocaleArchive(c, tmpDir, order, "C.utf8", "en_US.utf8", "de_DE.utf8@euro")
+
+		for _, locale := range []string{"en_US.UTF-8", "de_DE.UTF-8@euro"} {
+			conf := configcore.PlainCoreConfig(map[string]interface{}{
+				"system.locale": locale,
+			})
+			c.Assert(configcore.FilesystemOnlyApply(coreDev, tmpDir, conf), IsNil, Commentf("%s", locale))
+			c.Check(filepath.Join(tmpDir, "/etc/default/locale"), testutil.FileEquals, localeFileHeader+"LANG="+locale+"\n")
+		}
+
+		for _, locale := range []string{"fr_FR.UTF-8", "en_US"} {
+			conf := configcore.PlainCoreConfig(map[string]interface{}{
+				"system.locale": locale,
+			})
+			err := configcore.FilesystemOnlyApply(coreDev, tmpDir, conf)
+			c.Check(err, ErrorMatches, `cannot set locale ".*": locale not available`, Commentf("%s", locale))
+		}
+	}
+}
+
+func (s *localeSuite) TestFilesystemOnlyApplyLocaleArchiveInvalid(c *C) {
+	tmpDir := c.MkDir()
+	localeDir := filepath.Join(tmpDir, "/usr/lib/locale")
+	c.Assert(os.MkdirAll(localeDir, 0755), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(localeDir, "locale-archive"), bytes.Repeat([]byte{1}, 64), 0644), IsNil)
+
+	conf := configcore.PlainCoreConfig(map[string]interface{}{
+		"system.locale": "en_US.UTF-8",
+	})
+	err := configcore.FilesystemOnlyApply(coreDev, tmpDir, conf)
+	c.Assert(err, ErrorMatches, `cannot set locale "en_US.UTF-8": cannot read locale archive: invalid magic 0x1010101`)
+}
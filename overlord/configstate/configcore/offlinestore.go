@@ -0,0 +1,82 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configcore
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/devicestate"
+)
+
+var devicestateUseOfflineStore = devicestate.UseOfflineStore
+
+func init() {
+	// add supported configuration of this module
+	supportedConfigurations["core.store.offline-dir"] = true
+}
+
+func validateOfflineStore(tr RunTransaction) error {
+	dir, err := coreCfg(tr, "store.offline-dir")
+	if err != nil {
+		return err
+	}
+	if dir == "" {
+		return nil
+	}
+	// the directory may be on removable media that is not there yet
+	if !filepath.IsAbs(dir) || filepath.Clean(dir) != dir {
+		return fmt.Errorf("store.offline-dir must be a clean absolute path, not %q", dir)
+	}
+	return nil
+}
+
+func handleOfflineStore(tr RunTransaction, opts *fsOnlyContext) error {
+	// is store.offline-dir being modified?
+	inChanges := false
+	for _, name := range tr.Changes() {
+		if name == "core.store.offline-dir" {
+			inChanges = true
+			break
+		}
+	}
+	if !inChanges {
+		return nil
+	}
+
+	dir, err := coreCfg(tr, "store.offline-dir")
+	if err != nil {
+		return err
+	}
+	var prevDir string
+	if err := tr.GetPristine("core", "store.offline-dir", &prevDir); err != nil && !config.IsNoOption(err) {
+		return err
+	}
+	if dir != prevDir {
+		// XXX as for proxy.store, ideally this would happen only
+		// when committing
+		st := tr.State()
+		st.Lock()
+		defer st.Unlock()
+		devicestateUseOfflineStore(st, dir)
+	}
+	return nil
+}
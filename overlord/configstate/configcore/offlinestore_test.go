@@ -0,0 +1,105 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configcore_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/configstate/configcore"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+type offlineStoreSuite struct {
+	configcoreSuite
+
+	used []string
+}
+
+var _ = Suite(&offlineStoreSuite{})
+
+func (s *offlineStoreSuite) SetUpTest(c *C) {
+	s.configcoreSuite.SetUpTest(c)
+
+	s.used = nil
+	s.AddCleanup(configcore.MockDevicestateUseOfflineStore(func(st *state.State, dir string) {
+		c.Check(st, Equals, s.state)
+		s.used = append(s.used, dir)
+	}))
+}
+
+func (s *offlineStoreSuite) TestConfigureOfflineStore(c *C) {
+	// no related change
+	err := configcore.Run(classicDev, &mockConf{
+		state: s.state,
+		changes: map[string]interface{}{
+			"refresh.rate-limit": "1MB",
+		},
+	})
+	c.Assert(err, IsNil)
+	c.Check(s.used, HasLen, 0)
+
+	err = configcore.Run(classicDev, &mockConf{
+		state: s.state,
+		changes: map[string]interface{}{
+			"store.offline-dir": "/media/usb/store",
+		},
+	})
+	c.Assert(err, IsNil)
+	c.Check(s.used, DeepEquals, []string{"/media/usb/store"})
+
+	// unchanged
+	err = configcore.Run(classicDev, &mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"store.offline-dir": "/media/usb/store",
+		},
+		changes: map[string]interface{}{
+			"store.offline-dir": "/media/usb/store",
+		},
+	})
+	c.Assert(err, IsNil)
+	c.Check(s.used, HasLen, 1)
+
+	// unset
+	err = configcore.Run(classicDev, &mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"store.offline-dir": "/media/usb/store",
+		},
+		changes: map[string]interface{}{
+			"store.offline-dir": "",
+		},
+	})
+	c.Assert(err, IsNil)
+	c.Check(s.used, DeepEquals, []string{"/media/usb/store", ""})
+}
+
+func (s *offlineStoreSuite) TestConfigureOfflineStoreInvalid(c *C) {
+	for _, dir := range []string{"store", "/media/usb/../store", "/media/usb/"} {
+		err := configcore.Run(classicDev, &mockConf{
+			state: s.state,
+			changes: map[string]interface{}{
+				"store.offline-dir": dir,
+			},
+		})
+		c.Check(err, ErrorMatches, `store.offline-dir must be a clean absolute path, not ".*"`)
+	}
+	c.Check(s.used, HasLen, 0)
+}
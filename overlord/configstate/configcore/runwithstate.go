@@ -45,6 +45,8 @@ func init() {
 	addWithStateHandler(nil, handleProxyConfiguration, coreOnly)
 	// proxy.store
 	addWithStateHandler(validateProxyStore, handleProxyStore, nil)
+	// store.offline-dir
+	addWithStateHandler(validateOfflineStore, handleOfflineStore, nil)
 
 	// resilience.vitality-hint
 	addWithStateHandler(validateVitalitySettings, handleVitalityConfiguration, nil)
@@ -61,6 +63,8 @@ func init() {
 	addWithStateHandler(validateRefreshSchedule, nil, validateOnly)
 	addWithStateHandler(validateRefreshRateLimit, nil, validateOnly)
 	addWithStateHandler(validateAutomaticSnapshotsExpiration, nil, validateOnly)
+	addWithStateHandler(validateScheduledSnapshots, nil, validateOnly)
+	addWithStateHandler(validateTimesyncReadOnly, nil, validateOnly)
 
 	// netplan.*
 	addWithStateHandler(validateNetplanSettings, handleNetplanConfiguration, coreOnly)
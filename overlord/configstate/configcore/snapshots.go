@@ -23,12 +23,21 @@ package configcore
 
 import (
 	"fmt"
+	"strconv"
 	"time"
+
+	"github.com/snapcore/snapd/snap/naming"
+	"github.com/snapcore/snapd/strutil"
+	"github.com/snapcore/snapd/timeutil"
 )
 
 func init() {
 	// add supported configuration of this module
 	supportedConfigurations["core.snapshots.automatic.retention"] = true
+	supportedConfigurations["core.snapshots.schedule.timer"] = true
+	supportedConfigurations["core.snapshots.schedule.snaps"] = true
+	supportedConfigurations["core.snapshots.schedule.retention.count"] = true
+	supportedConfigurations["core.snapshots.schedule.retention.age"] = true
 }
 
 func validateAutomaticSnapshotsExpiration(tr RunTransaction) error {
@@ -47,3 +56,51 @@ func validateAutomaticSnapshotsExpiration(tr RunTransaction) error {
 	}
 	return nil
 }
+
+func validateScheduledSnapshots(tr RunTransaction) error {
+	timerStr, err := coreCfg(tr, "snapshots.schedule.timer")
+	if err != nil {
+		return err
+	}
+	if timerStr != "" {
+		if _, err := timeutil.ParseSchedule(timerStr); err != nil {
+			return fmt.Errorf("snapshots.schedule.timer cannot be parsed: %v", err)
+		}
+	}
+
+	snapsStr, err := coreCfg(tr, "snapshots.schedule.snaps")
+	if err != nil {
+		return err
+	}
+	for _, name := range strutil.CommaSeparatedList(snapsStr) {
+		if err := naming.ValidateInstance(name); err != nil {
+			return fmt.Errorf("snapshots.schedule.snaps is invalid: %v", err)
+		}
+	}
+
+	countStr, err := coreCfg(tr, "snapshots.schedule.retention.count")
+	if err != nil {
+		return err
+	}
+	if countStr != "" {
+		count, err := strconv.Atoi(countStr)
+		if err != nil || count < 1 {
+			return fmt.Errorf("snapshots.schedule.retention.count must be a positive number")
+		}
+	}
+
+	ageStr, err := coreCfg(tr, "snapshots.schedule.retention.age")
+	if err != nil {
+		return err
+	}
+	if ageStr != "" && ageStr != "no" {
+		dur, err := time.ParseDuration(ageStr)
+		if err != nil {
+			return fmt.Errorf("snapshots.schedule.retention.age cannot be parsed: %v", err)
+		}
+		if dur < time.Hour {
+			return fmt.Errorf("snapshots.schedule.retention.age must be a value greater than 1 hour, or \"no\" to disable")
+		}
+	}
+	return nil
+}
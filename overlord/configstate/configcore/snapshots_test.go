@@ -72,3 +72,37 @@ func (s *refreshSuite) TestConfigureAutomaticSnapshotsExpirationInvalid(c *C) {
 	})
 	c.Assert(err, ErrorMatches, `snapshots.automatic.retention cannot be parsed:.*`)
 }
+
+func (s *snapshotsSuite) TestConfigureScheduledSnapshotsHappy(c *C) {
+	err := configcore.Run(classicDev, &mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"snapshots.schedule.timer":           "00:00-04:00",
+			"snapshots.schedule.snaps":           "foo,bar_baz",
+			"snapshots.schedule.retention.count": 7,
+			"snapshots.schedule.retention.age":   "168h",
+		},
+	})
+	c.Assert(err, IsNil)
+}
+
+func (s *snapshotsSuite) TestConfigureScheduledSnapshotsInvalid(c *C) {
+	for _, t := range []struct {
+		key   string
+		value interface{}
+		err   string
+	}{
+		{"snapshots.schedule.timer", "invalid", `snapshots.schedule.timer cannot be parsed: .*`},
+		{"snapshots.schedule.snaps", "foo,Bar", `snapshots.schedule.snaps is invalid: invalid snap name: "Bar"`},
+		{"snapshots.schedule.retention.count", 0, `snapshots.schedule.retention.count must be a positive number`},
+		{"snapshots.schedule.retention.count", "many", `snapshots.schedule.retention.count must be a positive number`},
+		{"snapshots.schedule.retention.age", "invalid", `snapshots.schedule.retention.age cannot be parsed:.*`},
+		{"snapshots.schedule.retention.age", "10m", `snapshots.schedule.retention.age must be a value greater than 1 hour, or "no" to disable`},
+	} {
+		err := configcore.Run(classicDev, &mockConf{
+			state: s.state,
+			conf:  map[string]interface{}{t.key: t.value},
+		})
+		c.Check(err, ErrorMatches, t.err, Commentf("%s=%v", t.key, t.value))
+	}
+}
@@ -0,0 +1,199 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configcore
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/sysconfig"
+	"github.com/snapcore/snapd/systemd"
+)
+
+const (
+	timesyncServersOpt         = "system.timesync.servers"
+	timesyncFallbackServersOpt = "system.timesync.fallback-servers"
+	timesyncDisableOpt         = "system.timesync.disable"
+	timesyncSynchronizedOpt    = "system.timesync.synchronized"
+
+	timesyncdService  = "systemd-timesyncd.service"
+	timesyncdConfsDir = "/etc/systemd/timesyncd.conf.d"
+	snapdTimesyncConf = "00-snapd.conf"
+)
+
+func init() {
+	// add supported configuration of this module
+	supportedConfigurations["core."+timesyncServersOpt] = true
+	supportedConfigurations["core."+timesyncFallbackServersOpt] = true
+	supportedConfigurations["core."+timesyncDisableOpt] = true
+	supportedConfigurations["core."+timesyncSynchronizedOpt] = true
+	// the synchronization status is read-only and only ever comes
+	// from the system
+	config.RegisterExternalConfig("core", timesyncSynchronizedOpt, getTimesyncSynchronizedFromSystem)
+}
+
+// maximum length of a fully qualified domain name
+const maxDomainNameLen = 253
+
+// parseTimesyncServers splits a list of NTP servers separated by spaces
+// or commas and validates each of them.
+func parseTimesyncServers(option, value string) ([]string, error) {
+	servers := strings.FieldsFunc(value, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t'
+	})
+	for _, server := range servers {
+		if net.ParseIP(server) != nil {
+			continue
+		}
+		if len(server) > maxDomainNameLen || !validHostnameRegexp(server) {
+			return nil, fmt.Errorf("cannot set %s: %q is not a valid hostname or address", option, server)
+		}
+	}
+	return servers, nil
+}
+
+func validateTimesyncSettings(tr ConfGetter) error {
+	for _, option := range []string{timesyncServersOpt, timesyncFallbackServersOpt} {
+		value, err := coreCfg(tr, option)
+		if err != nil {
+			return err
+		}
+		if _, err := parseTimesyncServers(option, value); err != nil {
+			return err
+		}
+	}
+	return validateBoolFlag(tr, timesyncDisableOpt)
+}
+
+// validateTimesyncReadOnly prevents the synchronization status from being
+// set, it is only reported from the system.
+func validateTimesyncReadOnly(tr RunTransaction) error {
+	for _, name := range tr.Changes() {
+		if name == "core."+timesyncSynchronizedOpt || strings.HasPrefix(name, "core."+timesyncSynchronizedOpt+".") {
+			return fmt.Errorf("cannot set %q: option is read-only", timesyncSynchronizedOpt)
+		}
+	}
+	return nil
+}
+
+func handleTimesyncConfiguration(_ sysconfig.Device, tr ConfGetter, opts *fsOnlyContext) error {
+	rootDir := dirs.GlobalRootDir
+	if opts != nil {
+		rootDir = opts.RootDir
+	}
+
+	serversStr, err := coreCfg(tr, timesyncServersOpt)
+	if err != nil {
+		return err
+	}
+	servers, err := parseTimesyncServers(timesyncServersOpt, serversStr)
+	if err != nil {
+		return err
+	}
+	fallbackServersStr, err := coreCfg(tr, timesyncFallbackServersOpt)
+	if err != nil {
+		return err
+	}
+	fallbackServers, err := parseTimesyncServers(timesyncFallbackServersOpt, fallbackServersStr)
+	if err != nil {
+		return err
+	}
+	disable, err := coreCfg(tr, timesyncDisableOpt)
+	if err != nil {
+		return err
+	}
+
+	content := bytes.NewBuffer(nil)
+	if len(servers) > 0 || len(fallbackServers) > 0 {
+		content.WriteString("[Time]\n")
+		if len(servers) > 0 {
+			fmt.Fprintf(content, "NTP=%s\n", strings.Join(servers, " "))
+		}
+		if len(fallbackServers) > 0 {
+			fmt.Fprintf(content, "FallbackNTP=%s\n", strings.Join(fallbackServers, " "))
+		}
+	}
+	dirContent := map[string]osutil.FileState{}
+	if content.Len() > 0 {
+		dirContent[snapdTimesyncConf] = &osutil.MemoryFileState{
+			Content: content.Bytes(),
+			Mode:    0644,
+		}
+	}
+
+	dir := filepath.Join(rootDir, timesyncdConfsDir)
+	if len(dirContent) > 0 {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	changed, removed, err := osutil.EnsureDirState(dir, snapdTimesyncConf, dirContent)
+	if err != nil {
+		return err
+	}
+
+	switch disable {
+	case "":
+		// nothing to do
+	case "true", "false":
+		if err := switchDisableService(timesyncdService, disable == "true", opts); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("option %q has invalid value %q", timesyncDisableOpt, disable)
+	}
+
+	if opts == nil && disable != "true" && (len(changed) > 0 || len(removed) > 0) {
+		// pick up the new servers
+		sysd := systemd.New(systemd.SystemMode, &sysdLogger{})
+		if err := sysd.Restart([]string{timesyncdService}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func getTimesyncSynchronizedFromSystem(key string) (interface{}, error) {
+	output, err := exec.Command("timedatectl", "status").CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("cannot get time synchronization status: %v", osutil.OutputErr(output, err))
+	}
+	for _, line := range strings.Split(string(output), "\n") {
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		switch strings.TrimSpace(name) {
+		// older versions of systemd call it "NTP synchronized"
+		case "System clock synchronized", "NTP synchronized":
+			return strings.TrimSpace(value) == "yes", nil
+		}
+	}
+	return nil, fmt.Errorf("cannot get time synchronization status: unexpected timedatectl output")
+}
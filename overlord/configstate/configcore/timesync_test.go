@@ -0,0 +1,267 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configcore_test
+
+import (
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/configstate/configcore"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type timesyncSuite struct {
+	configcoreSuite
+
+	confPath string
+}
+
+var _ = Suite(&timesyncSuite{})
+
+func (s *timesyncSuite) SetUpTest(c *C) {
+	s.configcoreSuite.SetUpTest(c)
+
+	s.systemctlOutput = func(args ...string) []byte {
+		if args[0] == "show" && args[1] != "--property=ActiveState" {
+			return []byte("Id=systemd-timesyncd.service\nType=notify\nActiveState=inactive\nUnitFileState=enabled\nNames=systemd-timesyncd.service\nNeedDaemonReload=no\n")
+		}
+		return []byte("ActiveState=inactive")
+	}
+	s.confPath = filepath.Join(dirs.GlobalRootDir, "/etc/systemd/timesyncd.conf.d/00-snapd.conf")
+}
+
+func (s *timesyncSuite) TestConfigureTimesyncServers(c *C) {
+	err := configcore.FilesystemOnlyRun(coreDev, &mockConf{
+		state: s.state,
+		changes: map[string]interface{}{
+			"system.timesync.servers":          "ntp.example.com, 192.168.1.1",
+			"system.timesync.fallback-servers": "2001:db8::1 pool.ntp.org",
+		},
+	})
+	c.Assert(err, IsNil)
+	c.Check(s.confPath, testutil.FileEquals, `[Time]
+NTP=ntp.example.com 192.168.1.1
+FallbackNTP=2001:db8::1 pool.ntp.org
+`)
+	// timesyncd is restarted to pick up the new servers
+	c.Check(s.systemctlArgs, DeepEquals, [][]string{
+		{"stop", "systemd-timesyncd.service"},
+		{"show", "--property=ActiveState", "systemd-timesyncd.service"},
+		{"start", "systemd-timesyncd.service"},
+	})
+
+	// applying the same configuration again does not restart timesyncd
+	s.systemctlArgs = nil
+	err = configcore.FilesystemOnlyRun(coreDev, &mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"system.timesync.servers":          "ntp.example.com 192.168.1.1",
+			"system.timesync.fallback-servers": "2001:db8::1,pool.ntp.org",
+		},
+	})
+	c.Assert(err, IsNil)
+	c.Check(s.systemctlArgs, HasLen, 0)
+}
+
+func (s *timesyncSuite) TestConfigureTimesyncServersUnset(c *C) {
+	c.Assert(os.MkdirAll(filepath.Dir(s.confPath), 0755), IsNil)
+	c.Assert(os.WriteFile(s.confPath, []byte("[Time]\nNTP=ntp.example.com\n"), 0644), IsNil)
+
+	err := configcore.FilesystemOnlyRun(coreDev, &mockConf{
+		state: s.state,
+		changes: map[string]interface{}{
+			"system.timesync.servers": "",
+		},
+	})
+	c.Assert(err, IsNil)
+	c.Check(s.confPath, testutil.FileAbsent)
+	c.Check(s.systemctlArgs, DeepEquals, [][]string{
+		{"stop", "systemd-timesyncd.service"},
+		{"show", "--property=ActiveState", "systemd-timesyncd.service"},
+		{"start", "systemd-timesyncd.service"},
+	})
+}
+
+func (s *timesyncSuite) TestConfigureTimesyncNothingSet(c *C) {
+	err := configcore.FilesystemOnlyRun(coreDev, &mockConf{
+		state: s.state,
+	})
+	c.Assert(err, IsNil)
+	c.Check(filepath.Dir(s.confPath), testutil.FileAbsent)
+	c.Check(s.systemctlArgs, HasLen, 0)
+}
+
+func (s *timesyncSuite) TestConfigureTimesyncInvalid(c *C) {
+	for _, tc := range []struct {
+		option, value, err string
+	}{
+		{"system.timesync.servers", "ntp.example.com -bad", `cannot set system.timesync.servers: "-bad" is not a valid hostname or address`},
+		{"system.timesync.servers", "ntp_server", `cannot set system.timesync.servers: "ntp_server" is not a valid hostname or address`},
+		{"system.timesync.fallback-servers", "ntp.example.com;rm", `cannot set system.timesync.fallback-servers: "ntp.example.com;rm" is not a valid hostname or address`},
+		{"system.timesync.fallback-servers", "a..b", `cannot set system.timesync.fallback-servers: "a..b" is not a valid hostname or address`},
+		{"system.timesync.disable", "maybe", `system.timesync.disable can only be set to 'true' or 'false'`},
+	} {
+		err := configcore.FilesystemOnlyRun(coreDev, &mockConf{
+			state: s.state,
+			changes: map[string]interface{}{
+				tc.option: tc.value,
+			},
+		})
+		c.Check(err, ErrorMatches, tc.err, Commentf("%s=%s", tc.option, tc.value))
+	}
+	c.Check(s.confPath, testutil.FileAbsent)
+}
+
+func (s *timesyncSuite) TestConfigureTimesyncDisable(c *C) {
+	err := configcore.FilesystemOnlyRun(coreDev, &mockConf{
+		state: s.state,
+		changes: map[string]interface{}{
+			"system.timesync.servers": "ntp.example.com",
+			"system.timesync.disable": true,
+		},
+	})
+	c.Assert(err, IsNil)
+	c.Check(s.confPath, testutil.FileEquals, "[Time]\nNTP=ntp.example.com\n")
+	// disabled, so not restarted
+	c.Check(s.systemctlArgs, DeepEquals, [][]string{
+		{"show", "--property=Id,ActiveState,UnitFileState,Type,Names,NeedDaemonReload", "systemd-timesyncd.service"},
+		{"--no-reload", "disable", "systemd-timesyncd.service"},
+		{"mask", "systemd-timesyncd.service"},
+		{"stop", "systemd-timesyncd.service"},
+		{"show", "--property=ActiveState", "systemd-timesyncd.service"},
+	})
+}
+
+func (s *timesyncSuite) TestConfigureTimesyncEnable(c *C) {
+	err := configcore.FilesystemOnlyRun(coreDev, &mockConf{
+		state: s.state,
+		changes: map[string]interface{}{
+			"system.timesync.disable": false,
+		},
+	})
+	c.Assert(err, IsNil)
+	c.Check(s.systemctlArgs, DeepEquals, [][]string{
+		{"show", "--property=Id,ActiveState,UnitFileState,Type,Names,NeedDaemonReload", "systemd-timesyncd.service"},
+		{"unmask", "systemd-timesyncd.service"},
+		{"--no-reload", "enable", "systemd-timesyncd.service"},
+		{"daemon-reload"},
+		{"start", "systemd-timesyncd.service"},
+	})
+}
+
+func (s *timesyncSuite) TestConfigureTimesyncClassicNoop(c *C) {
+	err := configcore.FilesystemOnlyRun(classicDev, &mockConf{
+		state: s.state,
+		changes: map[string]interface{}{
+			"system.timesync.servers": "ntp.example.com",
+			"system.timesync.disable": true,
+		},
+	})
+	c.Assert(err, IsNil)
+	c.Check(s.confPath, testutil.FileAbsent)
+	c.Check(s.systemctlArgs, HasLen, 0)
+}
+
+func (s *timesyncSuite) TestFilesystemOnlyApply(c *C) {
+	conf := configcore.PlainCoreConfig(map[string]interface{}{
+		"system.timesync.servers":          "ntp.example.com",
+		"system.timesync.fallback-servers": "10.0.0.1",
+		"system.timesync.disable":          "true",
+	})
+	tmpDir := c.MkDir()
+	c.Assert(configcore.FilesystemOnlyApply(coreDev, tmpDir, conf), IsNil)
+
+	c.Check(filepath.Join(tmpDir, "/etc/systemd/timesyncd.conf.d/00-snapd.conf"), testutil.FileEquals, `[Time]
+NTP=ntp.example.com
+FallbackNTP=10.0.0.1
+`)
+	// masked in emulation mode
+	c.Check(s.systemctlArgs, DeepEquals, [][]string{
+		{"--root", tmpDir, "mask", "systemd-timesyncd.service"},
+	})
+}
+
+func (s *timesyncSuite) TestFilesystemOnlyApplyInvalid(c *C) {
+	conf := configcore.PlainCoreConfig(map[string]interface{}{
+		"system.timesync.servers": "not a/valid server",
+	})
+	tmpDir := c.MkDir()
+	err := configcore.FilesystemOnlyApply(coreDev, tmpDir, conf)
+	c.Assert(err, ErrorMatches, `cannot set system.timesync.servers: "a/valid" is not a valid hostname or address`)
+}
+
+func (s *timesyncSuite) TestSynchronizedIsReadOnly(c *C) {
+	err := configcore.Run(coreDev, &mockConf{
+		state: s.state,
+		changes: map[string]interface{}{
+			"system.timesync.synchronized": true,
+		},
+	})
+	c.Assert(err, ErrorMatches, `cannot set "system.timesync.synchronized": option is read-only`)
+}
+
+func (s *timesyncSuite) TestSynchronizedFromSystem(c *C) {
+	for _, tc := range []struct {
+		output   string
+		expected bool
+	}{
+		{"System clock synchronized: yes", true},
+		{"System clock synchronized: no", false},
+		// older systemd
+		{"NTP synchronized: yes", true},
+		{"NTP synchronized: no", false},
+	} {
+		mockedTimedatectl := testutil.MockCommand(c, "timedatectl", `
+echo "               Local time: Mon 2023-03-20 10:00:00 UTC"
+echo "           Universal time: Mon 2023-03-20 10:00:00 UTC"
+echo "                Time zone: Etc/UTC (UTC, +0000)"
+echo "`+tc.output+`"
+echo "              NTP service: active"
+`)
+
+		s.state.Lock()
+		tr := config.NewTransaction(s.state)
+		var synchronized bool
+		err := tr.Get("core", "system.timesync.synchronized", &synchronized)
+		s.state.Unlock()
+		c.Assert(err, IsNil)
+		c.Check(synchronized, Equals, tc.expected, Commentf("%s", tc.output))
+		c.Check(mockedTimedatectl.Calls(), DeepEquals, [][]string{
+			{"timedatectl", "status"},
+		})
+		mockedTimedatectl.Restore()
+	}
+}
+
+func (s *timesyncSuite) TestSynchronizedFromSystemError(c *C) {
+	mockedTimedatectl := testutil.MockCommand(c, "timedatectl", "echo unexpected")
+	defer mockedTimedatectl.Restore()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	tr := config.NewTransaction(s.state)
+	var synchronized bool
+	err := tr.Get("core", "system.timesync.synchronized", &synchronized)
+	c.Assert(err, ErrorMatches, `cannot get time synchronization status: unexpected timedatectl output`)
+}
@@ -59,6 +59,7 @@ import (
 	"github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/snap/snapfile"
 	"github.com/snapcore/snapd/snapdenv"
+	"github.com/snapcore/snapd/store/offline"
 	"github.com/snapcore/snapd/strutil"
 	"github.com/snapcore/snapd/sysconfig"
 	"github.com/snapcore/snapd/systemd"
@@ -207,6 +208,7 @@ func Manager(s *state.State, hookManager *hookstate.HookManager, runner *state.T
 	runner.AddHandler("create-recovery-system", m.doCreateRecoverySystem, m.undoCreateRecoverySystem)
 	runner.AddHandler("finalize-recovery-system", m.doFinalizeTriedRecoverySystem, m.undoFinalizeTriedRecoverySystem)
 	runner.AddCleanup("finalize-recovery-system", m.cleanupRecoverySystem)
+	runner.AddHandler("remove-recovery-system", m.doRemoveRecoverySystem, nil)
 
 	// used from the install API
 	// TODO: use better task names that are close to our usual pattern
@@ -2005,6 +2007,9 @@ type System struct {
 	// Current is true when the system running now was installed from that
 	// seed
 	Current bool
+	// DefaultRecoverySystem is true when the system is the default
+	// recovery system
+	DefaultRecoverySystem bool
 	// Label of the seed system
 	Label string
 	// Model assertion of the system
@@ -2039,6 +2044,13 @@ func (m *DeviceManager) Systems() ([]*System, error) {
 	systemMode := m.SystemMode(SysAny)
 	currentSys, _ := currentSystemForMode(m.state, systemMode)
 
+	m.state.Lock()
+	defaultSys, err := defaultRecoverySystem(m.state)
+	m.state.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
 	systemLabels, err := filepath.Glob(filepath.Join(dirs.SnapSeedDir, "systems", "*"))
 	if err != nil && !os.IsNotExist(err) {
 		return nil, fmt.Errorf("cannot list available systems: %v", err)
@@ -2058,6 +2070,7 @@ func (m *DeviceManager) Systems() ([]*System, error) {
 			logger.Noticef("cannot load system %q seed: %v", label, err)
 			continue
 		}
+		system.DefaultRecoverySystem = defaultSys != nil && defaultSys.System == label
 		systems = append(systems, system)
 	}
 	return systems, nil
@@ -2182,6 +2195,20 @@ func (m *DeviceManager) Reboot(systemLabel, mode string) error {
 		return nil
 	}
 
+	// reinstalling without a systemLabel uses the default recovery
+	// system, if one was set
+	if systemLabel == "" && mode == "install" {
+		m.state.Lock()
+		defaultSys, err := defaultRecoverySystem(m.state)
+		m.state.Unlock()
+		if err != nil {
+			return err
+		}
+		if defaultSys != nil {
+			systemLabel = defaultSys.System
+		}
+	}
+
 	// no systemLabel means "current" so get the current system label
 	if systemLabel == "" {
 		systemMode := m.SystemMode(SysAny)
@@ -2299,6 +2326,24 @@ func (m *DeviceManager) switchToSystemAndMode(systemLabel, mode string, sameSyst
 	return nil
 }
 
+// newStoreFor returns a new store for the device backend, or one serving
+// from the offline store directory if one is configured.
+func (m *DeviceManager) newStoreFor(devBE storecontext.DeviceBackend) snapstate.StoreService {
+	dir, err := offlineStoreDir(config.NewTransaction(m.state))
+	if err != nil {
+		logger.Noticef("cannot get offline store configuration, using the regular store: %v", err)
+		dir = ""
+	}
+	return m.storeFor(dir, devBE)
+}
+
+func (m *DeviceManager) storeFor(offlineDir string, devBE storecontext.DeviceBackend) snapstate.StoreService {
+	if offlineDir != "" {
+		return offline.New(offlineDir)
+	}
+	return m.newStore(devBE)
+}
+
 // implement storecontext.Backend
 
 type storeContextBackend struct {
@@ -25,6 +25,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"os"
 	"path/filepath"
 	"sort"
 	"strconv"
@@ -32,6 +33,7 @@ import (
 	"time"
 
 	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/asserts/snapasserts"
 	"github.com/snapcore/snapd/boot"
 	"github.com/snapcore/snapd/gadget"
 	"github.com/snapcore/snapd/i18n"
@@ -53,8 +55,10 @@ import (
 )
 
 var (
-	snapstateInstallWithDeviceContext = snapstate.InstallWithDeviceContext
-	snapstateUpdateWithDeviceContext  = snapstate.UpdateWithDeviceContext
+	snapstateInstallWithDeviceContext     = snapstate.InstallWithDeviceContext
+	snapstateUpdateWithDeviceContext      = snapstate.UpdateWithDeviceContext
+	snapstateInstallPathWithDeviceContext = snapstate.InstallPathWithDeviceContext
+	snapstateSwitch                       = snapstate.Switch
 )
 
 // findModel returns the device model assertion.
@@ -275,6 +279,29 @@ func proxyStore(st *state.State, tr *config.Transaction) (*asserts.Store, error)
 	return a.(*asserts.Store), nil
 }
 
+// offlineStoreDir returns the directory of the offline store if one is set.
+func offlineStoreDir(tr *config.Transaction) (string, error) {
+	var dir string
+	if err := tr.GetMaybe("core", "store.offline-dir", &dir); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// ResetStore replaces the store used by snapstate with a new one, which
+// serves from the offline store directory if one is configured.
+func ResetStore(st *state.State) {
+	devMgr := deviceMgr(st)
+	snapstate.ReplaceStore(st, devMgr.newStoreFor(devMgr.StoreContextBackend()))
+}
+
+// UseOfflineStore replaces the store used by snapstate with one serving from
+// the given directory, or with the regular store if dir is empty.
+func UseOfflineStore(st *state.State, dir string) {
+	devMgr := deviceMgr(st)
+	snapstate.ReplaceStore(st, devMgr.storeFor(dir, devMgr.StoreContextBackend()))
+}
+
 // interfaceConnected returns true if the given snap/interface names
 // are connected
 func interfaceConnected(st *state.State, snapName, ifName string) bool {
@@ -433,7 +460,7 @@ func (ms *modelSnapsForRemodel) canHaveUC18PinnedTrack() bool {
 		(ms.newModelSnap.SnapType == "kernel" || ms.newModelSnap.SnapType == "gadget")
 }
 
-func remodelEssentialSnapTasks(ctx context.Context, st *state.State, ms modelSnapsForRemodel, deviceCtx snapstate.DeviceContext, fromChange string) (*state.TaskSet, error) {
+func remodelEssentialSnapTasks(ctx context.Context, st *state.State, ms modelSnapsForRemodel, deviceCtx snapstate.DeviceContext, fromChange string, local *remodelLocalSnaps) (*state.TaskSet, error) {
 	userID := 0
 	newModelSnapChannel, err := modelSnapChannelFromDefaultOrPinnedTrack(ms.new, ms.newModelSnap)
 	if err != nil {
@@ -460,11 +487,16 @@ func remodelEssentialSnapTasks(ctx context.Context, st *state.State, ms modelSna
 			// and gadget snaps
 			changed = ms.currentModelSnap.PinnedTrack != ms.newModelSnap.PinnedTrack
 		}
-		if changed {
+		updated, err := local.updates(st, ms.newSnap)
+		if err != nil {
+			return nil, err
+		}
+		if changed || updated {
 			// new modes specifies the same snap, but with a new channel
-			return snapstateUpdateWithDeviceContext(st, ms.newSnap,
+			// or a new revision of it was provided locally
+			return remodelUpdateSnap(st, ms.newSnap,
 				&snapstate.RevisionOptions{Channel: newModelSnapChannel},
-				userID, snapstate.Flags{NoReRefresh: true}, deviceCtx, fromChange)
+				userID, snapstate.Flags{NoReRefresh: true}, deviceCtx, fromChange, local)
 		}
 		return nil, nil
 	}
@@ -476,9 +508,9 @@ func remodelEssentialSnapTasks(ctx context.Context, st *state.State, ms modelSna
 	}
 	if needsInstall {
 		// which needs to be installed
-		return snapstateInstallWithDeviceContext(ctx, st, ms.newSnap,
+		return remodelInstallSnap(ctx, st, ms.newSnap,
 			&snapstate.RevisionOptions{Channel: newModelSnapChannel},
-			userID, snapstate.Flags{}, deviceCtx, fromChange)
+			userID, snapstate.Flags{}, deviceCtx, fromChange, local)
 	}
 
 	if ms.new.Grade() != asserts.ModelGradeUnset {
@@ -489,10 +521,14 @@ func remodelEssentialSnapTasks(ctx context.Context, st *state.State, ms modelSna
 		if err != nil {
 			return nil, err
 		}
-		if changed {
-			ts, err := snapstateUpdateWithDeviceContext(st, ms.newSnap,
+		updated, err := local.updates(st, ms.newSnap)
+		if err != nil {
+			return nil, err
+		}
+		if changed || updated {
+			ts, err := remodelUpdateSnap(st, ms.newSnap,
 				&snapstate.RevisionOptions{Channel: newModelSnapChannel},
-				userID, snapstate.Flags{NoReRefresh: true}, deviceCtx, fromChange)
+				userID, snapstate.Flags{NoReRefresh: true}, deviceCtx, fromChange, local)
 			if err != nil {
 				return nil, err
 			}
@@ -532,6 +568,151 @@ func remodelEssentialSnapTasks(ctx context.Context, st *state.State, ms modelSna
 	return addExistingSnapTasks(st, ms.newSnap, fromChange)
 }
 
+// remodelLocalSnaps holds the snap files provided for an offline
+// remodel, keyed by snap name, and tracks which of them were used.
+type remodelLocalSnaps struct {
+	sideInfos map[string]*snap.SideInfo
+	paths     map[string]string
+	used      map[string]bool
+}
+
+func newRemodelLocalSnaps(st *state.State, new *asserts.Model, sideInfos []*snap.SideInfo, paths []string) (*remodelLocalSnaps, error) {
+	if len(sideInfos) != len(paths) {
+		return nil, fmt.Errorf("internal error: number of snap side infos and paths do not match")
+	}
+
+	modelSnaps := make(map[string]*asserts.ModelSnap)
+	for _, modelSnap := range append(new.EssentialSnaps(), new.SnapsWithoutEssential()...) {
+		modelSnaps[modelSnap.SnapName()] = modelSnap
+	}
+
+	vsets, err := assertstate.TrackedEnforcedValidationSets(st)
+	if err != nil {
+		return nil, err
+	}
+
+	local := &remodelLocalSnaps{
+		sideInfos: make(map[string]*snap.SideInfo, len(sideInfos)),
+		paths:     make(map[string]string, len(paths)),
+		used:      make(map[string]bool, len(sideInfos)),
+	}
+	for i, si := range sideInfos {
+		name := si.RealName
+		modelSnap := modelSnaps[name]
+		if modelSnap == nil {
+			return nil, fmt.Errorf("cannot remodel offline: snap %q is not part of the new model", name)
+		}
+		if si.SnapID == "" || si.Revision.Unset() {
+			return nil, fmt.Errorf("cannot remodel offline: snap %q is not asserted", name)
+		}
+		if modelSnap.SnapID != "" && modelSnap.SnapID != si.SnapID {
+			return nil, fmt.Errorf("cannot remodel offline: snap %q does not have the snap id %q required by the new model", name, modelSnap.SnapID)
+		}
+		if _, ok := local.sideInfos[name]; ok {
+			return nil, fmt.Errorf("cannot remodel offline: snap %q was provided more than once", name)
+		}
+		if err := checkLocalSnapValidationSets(vsets, si); err != nil {
+			return nil, err
+		}
+		local.sideInfos[name] = si
+		local.paths[name] = paths[i]
+	}
+	return local, nil
+}
+
+// checkLocalSnapValidationSets checks that the given locally provided snap
+// is allowed by the enforced validation sets.
+func checkLocalSnapValidationSets(vsets *snapasserts.ValidationSets, si *snap.SideInfo) error {
+	if vsets == nil {
+		return nil
+	}
+	keys, rev, err := vsets.CheckPresenceRequired(naming.NewSnapRef(si.RealName, si.SnapID))
+	if err != nil {
+		if _, ok := err.(*snapasserts.PresenceConstraintError); ok {
+			return fmt.Errorf("cannot remodel offline: snap %q is invalid in the enforced validation sets", si.RealName)
+		}
+		return err
+	}
+	if !rev.Unset() && rev != si.Revision {
+		return fmt.Errorf("cannot remodel offline: snap %q revision %s does not match revision %s required by validation sets: %s",
+			si.RealName, si.Revision, rev, snapasserts.ValidationSetKeySlice(keys).CommaSeparated())
+	}
+	return nil
+}
+
+// updates returns whether a snap file with a revision other than the
+// current one was provided for the given installed snap.
+func (l *remodelLocalSnaps) updates(st *state.State, name string) (bool, error) {
+	if l == nil {
+		return false, nil
+	}
+	si, ok := l.sideInfos[name]
+	if !ok {
+		return false, nil
+	}
+	var snapst snapstate.SnapState
+	if err := snapstate.Get(st, name, &snapst); err != nil {
+		if errors.Is(err, state.ErrNoState) {
+			return false, nil
+		}
+		return false, err
+	}
+	if snapst.Current == si.Revision {
+		// nothing to do with it
+		l.used[name] = true
+		return false, nil
+	}
+	return true, nil
+}
+
+// unused returns the sorted names of the provided snaps that were not used.
+func (l *remodelLocalSnaps) unused() []string {
+	if l == nil {
+		return nil
+	}
+	var names []string
+	for name := range l.sideInfos {
+		if !l.used[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// remodelInstallSnap returns the tasks installing a snap needed by the new
+// model, either from the store or, for offline remodels, from the provided
+// snap file.
+func remodelInstallSnap(ctx context.Context, st *state.State, name string, opts *snapstate.RevisionOptions, userID int, flags snapstate.Flags, deviceCtx snapstate.DeviceContext, fromChange string, local *remodelLocalSnaps) (*state.TaskSet, error) {
+	if local == nil {
+		return snapstateInstallWithDeviceContext(ctx, st, name, opts, userID, flags, deviceCtx, fromChange)
+	}
+	si, ok := local.sideInfos[name]
+	if !ok {
+		return nil, fmt.Errorf("cannot remodel offline: snap %q is needed by the new model but was not provided", name)
+	}
+	local.used[name] = true
+	flags.RemoveSnapPath = true
+	return snapstateInstallPathWithDeviceContext(st, si, local.paths[name], name, opts.Channel, flags, deviceCtx, fromChange)
+}
+
+// remodelUpdateSnap returns the tasks updating an installed snap to the
+// channel from the new model, either from the store or, for offline
+// remodels, from the provided snap file. Without a provided snap file an
+// offline remodel only switches the tracked channel.
+func remodelUpdateSnap(st *state.State, name string, opts *snapstate.RevisionOptions, userID int, flags snapstate.Flags, deviceCtx snapstate.DeviceContext, fromChange string, local *remodelLocalSnaps) (*state.TaskSet, error) {
+	if local == nil {
+		return snapstateUpdateWithDeviceContext(st, name, opts, userID, flags, deviceCtx, fromChange)
+	}
+	si, ok := local.sideInfos[name]
+	if !ok {
+		return snapstateSwitch(st, name, opts)
+	}
+	local.used[name] = true
+	flags.RemoveSnapPath = true
+	return snapstateInstallPathWithDeviceContext(st, si, local.paths[name], name, opts.Channel, flags, deviceCtx, fromChange)
+}
+
 // collect all prerequisites of a given snap from its task set
 func prereqsFromSnapTaskSet(ts *state.TaskSet) ([]string, error) {
 	for _, t := range ts.Tasks() {
@@ -556,7 +737,7 @@ func prereqsFromSnapTaskSet(ts *state.TaskSet) ([]string, error) {
 	return nil, fmt.Errorf("internal error: cannot identify task-snap-setup in taskset")
 }
 
-func remodelTasks(ctx context.Context, st *state.State, current, new *asserts.Model, deviceCtx snapstate.DeviceContext, fromChange string) ([]*state.TaskSet, error) {
+func remodelTasks(ctx context.Context, st *state.State, current, new *asserts.Model, deviceCtx snapstate.DeviceContext, fromChange string, local *remodelLocalSnaps) ([]*state.TaskSet, error) {
 	userID := 0
 	var tss []*state.TaskSet
 
@@ -582,7 +763,7 @@ func remodelTasks(ctx context.Context, st *state.State, current, new *asserts.Mo
 		newSnap:          new.Kernel(),
 		newModelSnap:     new.KernelSnap(),
 	}
-	ts, err := remodelEssentialSnapTasks(ctx, st, kms, deviceCtx, fromChange)
+	ts, err := remodelEssentialSnapTasks(ctx, st, kms, deviceCtx, fromChange, local)
 	if err != nil {
 		return nil, err
 	}
@@ -598,7 +779,7 @@ func remodelTasks(ctx context.Context, st *state.State, current, new *asserts.Mo
 		newSnap:          new.Base(),
 		newModelSnap:     new.BaseSnap(),
 	}
-	ts, err = remodelEssentialSnapTasks(ctx, st, bms, deviceCtx, fromChange)
+	ts, err = remodelEssentialSnapTasks(ctx, st, bms, deviceCtx, fromChange, local)
 	if err != nil {
 		return nil, err
 	}
@@ -614,7 +795,7 @@ func remodelTasks(ctx context.Context, st *state.State, current, new *asserts.Mo
 		newSnap:          new.Gadget(),
 		newModelSnap:     new.GadgetSnap(),
 	}
-	ts, err = remodelEssentialSnapTasks(ctx, st, gms, deviceCtx, fromChange)
+	ts, err = remodelEssentialSnapTasks(ctx, st, gms, deviceCtx, fromChange, local)
 	if err != nil {
 		return nil, err
 	}
@@ -649,27 +830,32 @@ func remodelTasks(ctx context.Context, st *state.State, current, new *asserts.Mo
 		var ts *state.TaskSet
 		if needsInstall {
 			// If the snap is not installed we need to install it now.
-			ts, err = snapstateInstallWithDeviceContext(ctx, st, modelSnap.SnapName(),
+			ts, err = remodelInstallSnap(ctx, st, modelSnap.SnapName(),
 				&snapstate.RevisionOptions{Channel: newModelSnapChannel},
 				userID,
-				snapstate.Flags{Required: true}, deviceCtx, fromChange)
+				snapstate.Flags{Required: true}, deviceCtx, fromChange, local)
 			if err != nil {
 				return nil, err
 			}
 			tss = append(tss, ts)
-		} else if currentInfo != nil && newModelSnapChannel != "" {
-			// the snap is already installed and has its default
-			// channel declared in the model, but the local install
-			// may be tracking a different channel
+		} else if currentInfo != nil {
+			// the snap is already installed and may have its
+			// default channel declared in the model, but the local
+			// install may be tracking a different channel
 			changed, err := installedSnapChannelChanged(st, modelSnap.SnapName(), newModelSnapChannel)
 			if err != nil {
 				return nil, err
 			}
-			if changed {
-				ts, err = snapstateUpdateWithDeviceContext(st, modelSnap.SnapName(),
+			// or a new revision of it was provided locally
+			updated, err := local.updates(st, modelSnap.SnapName())
+			if err != nil {
+				return nil, err
+			}
+			if changed || updated {
+				ts, err = remodelUpdateSnap(st, modelSnap.SnapName(),
 					&snapstate.RevisionOptions{Channel: newModelSnapChannel},
 					userID, snapstate.Flags{NoReRefresh: true},
-					deviceCtx, fromChange)
+					deviceCtx, fromChange, local)
 				if err != nil {
 					return nil, err
 				}
@@ -721,6 +907,9 @@ func remodelTasks(ctx context.Context, st *state.State, current, new *asserts.Mo
 		sort.Strings(missingSnaps)
 		return nil, fmt.Errorf("cannot remodel with incomplete model, the following snaps are required but not listed: %s", strutil.Quoted(missingSnaps))
 	}
+	if unused := local.unused(); len(unused) != 0 {
+		return nil, fmt.Errorf("cannot remodel offline, the following provided snaps are not needed: %s", strutil.Quoted(unused))
+	}
 
 	// TODO: fix the prerequisite task getting stuck during a remodel by
 	// making it a NOP, but ensure that the dependencies of new snaps are
@@ -863,6 +1052,25 @@ func remodelTasks(ctx context.Context, st *state.State, current, new *asserts.Mo
 //   - Make sure this works with Core 20 as well, in the Core 20 case
 //     we must enforce the default-channels from the model as well
 func Remodel(st *state.State, new *asserts.Model) (*state.Change, error) {
+	return remodel(st, new, nil)
+}
+
+// RemodelOffline is like Remodel but takes the snaps needed by the new
+// model from the given local snap files instead of the store. Each side
+// info describes the snap file at the same index in paths and must carry
+// the snap id and revision from the snap assertions, which are expected to
+// be in the assertion database already. Installed snaps for which no file
+// is provided keep their current revision. As with sideloading, the snap
+// files used by the change are removed once they have been installed.
+func RemodelOffline(st *state.State, new *asserts.Model, sideInfos []*snap.SideInfo, paths []string) (*state.Change, error) {
+	local, err := newRemodelLocalSnaps(st, new, sideInfos, paths)
+	if err != nil {
+		return nil, err
+	}
+	return remodel(st, new, local)
+}
+
+func remodel(st *state.State, new *asserts.Model, local *remodelLocalSnaps) (*state.Change, error) {
 	var seeded bool
 	err := st.Get("seeded", &seeded)
 	if err != nil && !errors.Is(err, state.ErrNoState) {
@@ -941,6 +1149,11 @@ func Remodel(st *state.State, new *asserts.Model) (*state.Change, error) {
 	var tss []*state.TaskSet
 	switch remodelKind {
 	case ReregRemodel:
+		if local != nil {
+			// a new serial needs to be requested from the device
+			// service
+			return nil, fmt.Errorf("cannot remodel offline to a different brand or model")
+		}
 		requestSerial := st.NewTask("request-serial", i18n.G("Request new device serial"))
 
 		prepare := st.NewTask("prepare-remodeling", i18n.G("Prepare remodeling"))
@@ -952,17 +1165,19 @@ func Remodel(st *state.State, new *asserts.Model) (*state.Change, error) {
 		if sto == nil {
 			return nil, fmt.Errorf("internal error: a store switch remodeling should have built a store")
 		}
-		// ensure a new session accounting for the new brand store
-		st.Unlock()
-		err := sto.EnsureDeviceSession()
-		st.Lock()
-		if err != nil {
-			return nil, fmt.Errorf("cannot get a store session based on the new model assertion: %v", err)
+		if local == nil {
+			// ensure a new session accounting for the new brand store
+			st.Unlock()
+			err := sto.EnsureDeviceSession()
+			st.Lock()
+			if err != nil {
+				return nil, fmt.Errorf("cannot get a store session based on the new model assertion: %v", err)
+			}
 		}
 		fallthrough
 	case UpdateRemodel:
 		var err error
-		tss, err = remodelTasks(context.TODO(), st, current, new, remodCtx, "")
+		tss, err = remodelTasks(context.TODO(), st, current, new, remodCtx, "", local)
 		if err != nil {
 			return nil, err
 		}
@@ -1023,6 +1238,19 @@ type recoverySystemSetup struct {
 	// SnapSetupTasks is a list of task IDs that carry snap setup
 	// information, relevant only during remodel, set when tasks are created
 	SnapSetupTasks []string `json:"snap-setup-tasks"`
+	// LocalSnaps is a list of snaps provided locally which are used
+	// instead of the installed ones, set when tasks are created
+	LocalSnaps []recoverySystemLocalSnap `json:"local-snaps,omitempty"`
+	// MarkDefault is set when the recovery system should become the
+	// default one once it has been successfully created
+	MarkDefault bool `json:"mark-default,omitempty"`
+}
+
+// recoverySystemLocalSnap describes a snap file provided locally for
+// creating a recovery system.
+type recoverySystemLocalSnap struct {
+	SideInfo *snap.SideInfo `json:"side-info"`
+	Path     string         `json:"path"`
 }
 
 func pickRecoverySystemLabel(labelBase string) (string, error) {
@@ -1055,6 +1283,15 @@ func pickRecoverySystemLabel(labelBase string) (string, error) {
 }
 
 func createRecoverySystemTasks(st *state.State, label string, snapSetupTasks []string) (*state.TaskSet, error) {
+	return recoverySystemTasks(st, &recoverySystemSetup{
+		Label: label,
+		// IDs of the tasks carrying snap-setup
+		SnapSetupTasks: snapSetupTasks,
+	})
+}
+
+func recoverySystemTasks(st *state.State, setup *recoverySystemSetup) (*state.TaskSet, error) {
+	label := setup.Label
 	// precondition check, the directory should not exist yet
 	systemDirectory := filepath.Join(boot.InitramfsUbuntuSeedDir, "systems", label)
 	exists, _, err := osutil.DirExists(systemDirectory)
@@ -1064,15 +1301,11 @@ func createRecoverySystemTasks(st *state.State, label string, snapSetupTasks []s
 	if exists {
 		return nil, fmt.Errorf("recovery system %q already exists", label)
 	}
+	setup.Directory = systemDirectory
 
 	create := st.NewTask("create-recovery-system", fmt.Sprintf("Create recovery system with label %q", label))
 	// the label we want
-	create.Set("recovery-system-setup", &recoverySystemSetup{
-		Label:     label,
-		Directory: systemDirectory,
-		// IDs of the tasks carrying snap-setup
-		SnapSetupTasks: snapSetupTasks,
-	})
+	create.Set("recovery-system-setup", setup)
 
 	finalize := st.NewTask("finalize-recovery-system", fmt.Sprintf("Finalize recovery system with label %q", label))
 	finalize.WaitFor(create)
@@ -1081,7 +1314,133 @@ func createRecoverySystemTasks(st *state.State, label string, snapSetupTasks []s
 	return state.NewTaskSet(create, finalize), nil
 }
 
-func CreateRecoverySystem(st *state.State, label string) (*state.Change, error) {
+// CreateRecoverySystemOptions holds the options for creating a new
+// recovery system.
+type CreateRecoverySystemOptions struct {
+	// ValidationSets is a list of validation sets the snaps of the new
+	// recovery system must be consistent with.
+	ValidationSets []*asserts.ValidationSet
+	// LocalSnapSideInfos and LocalSnapPaths describe snap files provided
+	// locally, which are used for the new recovery system instead of the
+	// installed snaps. The files are removed once the change is done.
+	LocalSnapSideInfos []*snap.SideInfo
+	LocalSnapPaths     []string
+	// MarkDefault is set when the new recovery system should become the
+	// default one once created.
+	MarkDefault bool
+}
+
+// checkRecoverySystemChangeConflict returns an error if a change which
+// manipulates recovery systems is in progress.
+func checkRecoverySystemChangeConflict(st *state.State) error {
+	for _, chg := range st.Changes() {
+		if chg.IsReady() {
+			continue
+		}
+		switch chg.Kind() {
+		case "create-recovery-system", "remove-recovery-system", "remodel":
+			return &snapstate.ChangeConflictError{
+				Message:    fmt.Sprintf("recovery systems are being modified by change %s", chg.ID()),
+				ChangeKind: chg.Kind(),
+				ChangeID:   chg.ID(),
+			}
+		}
+	}
+	return nil
+}
+
+// modelSnapRefs returns the snaps that end up in a recovery system created
+// for the given model, indexed by name.
+func modelSnapRefs(model *asserts.Model) map[string]naming.SnapRef {
+	refs := make(map[string]naming.SnapRef)
+	for _, sn := range model.EssentialSnaps() {
+		refs[sn.SnapName()] = sn
+	}
+	for _, sn := range model.SnapsWithoutEssential() {
+		refs[sn.SnapName()] = sn
+	}
+	if _, ok := refs["snapd"]; !ok {
+		// snapd is implicitly needed
+		refs["snapd"] = naming.Snap("snapd")
+	}
+	return refs
+}
+
+func recoverySystemLocalSnaps(model *asserts.Model, sideInfos []*snap.SideInfo, paths []string) ([]recoverySystemLocalSnap, error) {
+	if len(sideInfos) != len(paths) {
+		return nil, fmt.Errorf("internal error: side infos and paths of local snaps do not match")
+	}
+	modelSnaps := modelSnapRefs(model)
+	var localSnaps []recoverySystemLocalSnap
+	seen := make(map[string]bool, len(sideInfos))
+	for i, si := range sideInfos {
+		name := si.RealName
+		ref, ok := modelSnaps[name]
+		if !ok {
+			return nil, fmt.Errorf("cannot create recovery system: snap %q is not part of the model", name)
+		}
+		if si.SnapID == "" {
+			return nil, fmt.Errorf("cannot create recovery system: snap %q is not asserted", name)
+		}
+		if id := ref.ID(); id != "" && id != si.SnapID {
+			return nil, fmt.Errorf("cannot create recovery system: snap %q has snap ID %q which does not match the model", name, si.SnapID)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("cannot create recovery system: snap %q provided more than once", name)
+		}
+		seen[name] = true
+		localSnaps = append(localSnaps, recoverySystemLocalSnap{
+			SideInfo: si,
+			Path:     paths[i],
+		})
+	}
+	return localSnaps, nil
+}
+
+// checkRecoverySystemValidationSets checks that the snaps which would be
+// used for a recovery system of the given model, that is the provided local
+// snaps or otherwise the installed ones, are consistent with the given
+// validation sets.
+func checkRecoverySystemValidationSets(st *state.State, model *asserts.Model, valsets []*asserts.ValidationSet, localSnaps []recoverySystemLocalSnap) error {
+	if len(valsets) == 0 {
+		return nil
+	}
+	vsets := snapasserts.NewValidationSets()
+	for _, vs := range valsets {
+		if err := vsets.Add(vs); err != nil {
+			return err
+		}
+	}
+	if err := vsets.Conflict(); err != nil {
+		return err
+	}
+
+	local := make(map[string]*snap.SideInfo, len(localSnaps))
+	for _, ls := range localSnaps {
+		local[ls.SideInfo.RealName] = ls.SideInfo
+	}
+	var snaps []*snapasserts.InstalledSnap
+	for name := range modelSnapRefs(model) {
+		if si, ok := local[name]; ok {
+			snaps = append(snaps, snapasserts.NewInstalledSnap(si.RealName, si.SnapID, si.Revision))
+			continue
+		}
+		var snapst snapstate.SnapState
+		if err := snapstate.Get(st, name, &snapst); err != nil {
+			if errors.Is(err, state.ErrNoState) {
+				continue
+			}
+			return err
+		}
+		si := snapst.CurrentSideInfo()
+		snaps = append(snaps, snapasserts.NewInstalledSnap(si.RealName, si.SnapID, si.Revision))
+	}
+	return vsets.CheckInstalledSnaps(snaps, nil)
+}
+
+// CreateRecoverySystem creates a change that creates a new recovery system
+// with the given label and tests it by rebooting into it.
+func CreateRecoverySystem(st *state.State, label string, opts CreateRecoverySystemOptions) (*state.Change, error) {
 	var seeded bool
 	err := st.Get("seeded", &seeded)
 	if err != nil && !errors.Is(err, state.ErrNoState) {
@@ -1090,8 +1449,30 @@ func CreateRecoverySystem(st *state.State, label string) (*state.Change, error)
 	if !seeded {
 		return nil, fmt.Errorf("cannot create new recovery systems until fully seeded")
 	}
+	if err := checkRecoverySystemChangeConflict(st); err != nil {
+		return nil, err
+	}
+	var localSnaps []recoverySystemLocalSnap
+	if len(opts.LocalSnapSideInfos) != 0 || len(opts.ValidationSets) != 0 {
+		deviceCtx, err := DeviceCtx(st, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		model := deviceCtx.Model()
+		localSnaps, err = recoverySystemLocalSnaps(model, opts.LocalSnapSideInfos, opts.LocalSnapPaths)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkRecoverySystemValidationSets(st, model, opts.ValidationSets, localSnaps); err != nil {
+			return nil, fmt.Errorf("cannot create recovery system: %v", err)
+		}
+	}
 	chg := st.NewChange("create-recovery-system", fmt.Sprintf("Create new recovery system with label %q", label))
-	ts, err := createRecoverySystemTasks(st, label, nil)
+	ts, err := recoverySystemTasks(st, &recoverySystemSetup{
+		Label:       label,
+		LocalSnaps:  localSnaps,
+		MarkDefault: opts.MarkDefault,
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -1099,6 +1480,135 @@ func CreateRecoverySystem(st *state.State, label string) (*state.Change, error)
 	return chg, nil
 }
 
+// RemoveRecoverySystem creates a change that removes the recovery system
+// with the given label, together with the seed snaps that are not used by
+// any other recovery system. The recovery system the device was seeded from
+// and the default recovery system cannot be removed.
+func RemoveRecoverySystem(st *state.State, label string) (*state.Change, error) {
+	if label == "" {
+		return nil, fmt.Errorf("cannot remove a recovery system without a label")
+	}
+	if err := asserts.IsValidSystemLabel(label); err != nil {
+		return nil, fmt.Errorf("cannot remove recovery system: %v", err)
+	}
+	systemDirectory := filepath.Join(boot.InitramfsUbuntuSeedDir, "systems", label)
+	if _, err := os.Stat(systemDirectory); err != nil {
+		return nil, err
+	}
+	if err := checkRecoverySystemChangeConflict(st); err != nil {
+		return nil, err
+	}
+
+	var whatseeded []seededSystem
+	if err := st.Get("seeded-systems", &whatseeded); err != nil && !errors.Is(err, state.ErrNoState) {
+		return nil, err
+	}
+	// seeded systems are prepended to the list
+	if len(whatseeded) > 0 && whatseeded[0].System == label {
+		return nil, fmt.Errorf("cannot remove the current recovery system %q", label)
+	}
+	defaultSys, err := defaultRecoverySystem(st)
+	if err != nil {
+		return nil, err
+	}
+	if defaultSys != nil && defaultSys.System == label {
+		return nil, fmt.Errorf("cannot remove the default recovery system %q", label)
+	}
+
+	chg := st.NewChange("remove-recovery-system", fmt.Sprintf("Remove recovery system with label %q", label))
+	remove := st.NewTask("remove-recovery-system", fmt.Sprintf("Remove recovery system with label %q", label))
+	remove.Set("recovery-system-setup", &recoverySystemSetup{
+		Label:     label,
+		Directory: systemDirectory,
+	})
+	chg.AddTask(remove)
+	return chg, nil
+}
+
+// DefaultRecoverySystem describes the recovery system used when no system
+// is explicitly requested, for instance when reinstalling the device.
+type DefaultRecoverySystem struct {
+	// System is the label of the recovery system.
+	System string `json:"system"`
+	// Model, BrandID, Revision and Timestamp identify the model
+	// assertion of the recovery system.
+	Model     string    `json:"model"`
+	BrandID   string    `json:"brand-id"`
+	Revision  int       `json:"revision"`
+	Timestamp time.Time `json:"timestamp"`
+	// TimeMadeDefault is when the system was made the default one.
+	TimeMadeDefault time.Time `json:"time-made-default"`
+}
+
+func defaultRecoverySystem(st *state.State) (*DefaultRecoverySystem, error) {
+	var defaultSys DefaultRecoverySystem
+	if err := st.Get("default-recovery-system", &defaultSys); err != nil {
+		if errors.Is(err, state.ErrNoState) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &defaultSys, nil
+}
+
+// setDefaultRecoverySystem records the given recovery system as the default
+// one, both in the state and in the recovery bootloader environment where
+// the last of the good recovery systems is picked by default.
+func setDefaultRecoverySystem(st *state.State, label string) error {
+	system, err := systemFromSeed(label, nil)
+	if err != nil {
+		return fmt.Errorf("cannot load recovery system %q: %v", label, err)
+	}
+	return markDefaultRecoverySystem(st, label, system.Model)
+}
+
+// markDefaultRecoverySystem makes the given recovery system, with the
+// given model, the default one. Marking the system as recovery capable
+// moves it to the end of the good recovery systems, which is what the
+// recovery bootloader picks by default, so the state must follow.
+func markDefaultRecoverySystem(st *state.State, label string, model *asserts.Model) error {
+	if err := boot.MarkRecoveryCapableSystem(label); err != nil {
+		return fmt.Errorf("cannot mark recovery system %q as default: %v", label, err)
+	}
+	st.Set("default-recovery-system", &DefaultRecoverySystem{
+		System:          label,
+		Model:           model.Model(),
+		BrandID:         model.BrandID(),
+		Revision:        model.Revision(),
+		Timestamp:       model.Timestamp(),
+		TimeMadeDefault: timeNow(),
+	})
+	return nil
+}
+
+// SetDefaultRecoverySystem makes the recovery system with the given label
+// the default one. Only systems known to be good can become the default.
+func SetDefaultRecoverySystem(st *state.State, label string) error {
+	if label == "" {
+		return fmt.Errorf("cannot set the default recovery system without a label")
+	}
+	if err := asserts.IsValidSystemLabel(label); err != nil {
+		return fmt.Errorf("cannot set the default recovery system: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(boot.InitramfsUbuntuSeedDir, "systems", label)); err != nil {
+		return err
+	}
+	if err := checkRecoverySystemChangeConflict(st); err != nil {
+		return err
+	}
+	modeEnv, err := maybeReadModeenv()
+	if err != nil {
+		return err
+	}
+	if modeEnv == nil {
+		return fmt.Errorf("cannot set the default recovery system on a system without modeenv")
+	}
+	if !strutil.ListContains(modeEnv.GoodRecoverySystems, label) {
+		return fmt.Errorf("cannot set %q as the default recovery system: system is not known to be good", label)
+	}
+	return setDefaultRecoverySystem(st, label)
+}
+
 // InstallFinish creates a change that will finish the install for the given
 // label and volumes. This includes writing missing volume content, seting
 // up the bootloader and installing the kernel.
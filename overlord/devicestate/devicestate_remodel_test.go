@@ -573,6 +573,241 @@ func (s *deviceMgrRemodelSuite) TestRemodelRequiredSnaps(c *C) {
 	c.Assert(tSetModel.WaitTasks(), DeepEquals, []*state.Task{tDownloadSnap1, tValidateSnap1, tInstallSnap1, tDownloadSnap2, tValidateSnap2, tInstallSnap2})
 }
 
+func (s *deviceMgrRemodelSuite) setupOfflineRemodel(c *C) {
+	s.state.Set("seeded", true)
+	s.state.Set("refresh-privacy-key", "some-privacy-key")
+
+	// set a model assertion
+	s.makeModelAssertionInState(c, "canonical", "pc-model", map[string]interface{}{
+		"architecture": "amd64",
+		"kernel":       "pc-kernel",
+		"gadget":       "pc",
+		"base":         "core18",
+	})
+	s.makeSerialAssertionInState(c, "canonical", "pc-model", "1234")
+	devicestatetest.SetDevice(s.state, &auth.DeviceState{
+		Brand:  "canonical",
+		Model:  "pc-model",
+		Serial: "1234",
+	})
+}
+
+func (s *deviceMgrRemodelSuite) TestRemodelOfflineRequiredSnaps(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+	s.setupOfflineRemodel(c)
+
+	restore := devicestate.MockSnapstateInstallWithDeviceContext(func(ctx context.Context, st *state.State, name string, opts *snapstate.RevisionOptions, userID int, flags snapstate.Flags, deviceCtx snapstate.DeviceContext, fromChange string) (*state.TaskSet, error) {
+		c.Fatalf("unexpected install from the store of %q", name)
+		return nil, nil
+	})
+	defer restore()
+
+	var installed []string
+	restore = devicestate.MockSnapstateInstallPathWithDeviceContext(func(st *state.State, si *snap.SideInfo, path, name, channel string, flags snapstate.Flags, deviceCtx snapstate.DeviceContext, fromChange string) (*state.TaskSet, error) {
+		c.Check(flags.Required, Equals, true)
+		c.Check(flags.RemoveSnapPath, Equals, true)
+		c.Check(deviceCtx, NotNil)
+		c.Check(deviceCtx.ForRemodeling(), Equals, true)
+		c.Check(path, Equals, "/path/to/"+name+".snap")
+		c.Check(si.RealName, Equals, name)
+		installed = append(installed, name)
+
+		tPrepare := s.state.NewTask("fake-prepare", fmt.Sprintf("Prepare %s", name))
+		tPrepare.Set("snap-setup", &snapstate.SnapSetup{
+			SideInfo: si,
+			SnapPath: path,
+		})
+		tInstall := s.state.NewTask("fake-install", fmt.Sprintf("Install %s", name))
+		tInstall.WaitFor(tPrepare)
+		ts := state.NewTaskSet(tPrepare, tInstall)
+		ts.MarkEdge(tPrepare, snapstate.LastBeforeLocalModificationsEdge)
+		return ts, nil
+	})
+	defer restore()
+
+	new := s.brands.Model("canonical", "pc-model", map[string]interface{}{
+		"architecture":   "amd64",
+		"kernel":         "pc-kernel",
+		"gadget":         "pc",
+		"base":           "core18",
+		"required-snaps": []interface{}{"new-required-snap-1", "new-required-snap-2"},
+		"revision":       "1",
+	})
+	sideInfos := []*snap.SideInfo{
+		{RealName: "new-required-snap-1", SnapID: "snap-1-id", Revision: snap.R(1)},
+		{RealName: "new-required-snap-2", SnapID: "snap-2-id", Revision: snap.R(2)},
+	}
+	paths := []string{"/path/to/new-required-snap-1.snap", "/path/to/new-required-snap-2.snap"}
+	chg, err := devicestate.RemodelOffline(s.state, new, sideInfos, paths)
+	c.Assert(err, IsNil)
+	c.Assert(chg.Summary(), Equals, "Refresh model assertion from revision 0 to 1")
+	c.Check(installed, DeepEquals, []string{"new-required-snap-1", "new-required-snap-2"})
+
+	tl := chg.Tasks()
+	// 2 snaps,
+	c.Assert(tl, HasLen, 2*2+1)
+	tPrepareSnap1 := tl[0]
+	tInstallSnap1 := tl[1]
+	tPrepareSnap2 := tl[2]
+	tInstallSnap2 := tl[3]
+	tSetModel := tl[4]
+
+	// everything is prepared first, then installed
+	c.Check(tPrepareSnap2.WaitTasks(), DeepEquals, []*state.Task{tPrepareSnap1})
+	c.Check(tInstallSnap1.WaitTasks(), DeepEquals, []*state.Task{tPrepareSnap1, tPrepareSnap2})
+	c.Check(tInstallSnap2.WaitTasks(), DeepEquals, []*state.Task{tPrepareSnap2, tInstallSnap1})
+	c.Check(tSetModel.Kind(), Equals, "set-model")
+}
+
+func (s *deviceMgrRemodelSuite) TestRemodelOfflineSwitchChannelWithoutSnapFile(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+	s.setupOfflineRemodel(c)
+
+	restore := devicestate.MockSnapstateUpdateWithDeviceContext(func(st *state.State, name string, opts *snapstate.RevisionOptions, userID int, flags snapstate.Flags, deviceCtx snapstate.DeviceContext, fromChange string) (*state.TaskSet, error) {
+		c.Fatalf("unexpected update from the store of %q", name)
+		return nil, nil
+	})
+	defer restore()
+
+	var switched []string
+	restore = devicestate.MockSnapstateSwitch(func(st *state.State, name string, opts *snapstate.RevisionOptions) (*state.TaskSet, error) {
+		c.Check(opts.Channel, Equals, "18")
+		switched = append(switched, name)
+		tSwitch := s.state.NewTask("switch-snap", fmt.Sprintf("Switch %s", name))
+		tSwitch.Set("snap-setup", &snapstate.SnapSetup{
+			SideInfo: &snap.SideInfo{RealName: name},
+		})
+		return state.NewTaskSet(tSwitch), nil
+	})
+	defer restore()
+
+	new := s.brands.Model("canonical", "pc-model", map[string]interface{}{
+		"architecture": "amd64",
+		"kernel":       "pc-kernel=18",
+		"gadget":       "pc",
+		"base":         "core18",
+		"revision":     "1",
+	})
+	chg, err := devicestate.RemodelOffline(s.state, new, nil, nil)
+	c.Assert(err, IsNil)
+	c.Check(switched, DeepEquals, []string{"pc-kernel"})
+
+	tl := chg.Tasks()
+	c.Assert(tl, HasLen, 2)
+	c.Check(tl[0].Kind(), Equals, "switch-snap")
+	c.Check(tl[1].Kind(), Equals, "set-model")
+}
+
+func (s *deviceMgrRemodelSuite) TestRemodelOfflineErrors(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+	s.setupOfflineRemodel(c)
+
+	restore := devicestate.MockSnapstateInstallPathWithDeviceContext(func(st *state.State, si *snap.SideInfo, path, name, channel string, flags snapstate.Flags, deviceCtx snapstate.DeviceContext, fromChange string) (*state.TaskSet, error) {
+		tPrepare := s.state.NewTask("fake-prepare", fmt.Sprintf("Prepare %s", name))
+		tPrepare.Set("snap-setup", &snapstate.SnapSetup{SideInfo: si, SnapPath: path})
+		tInstall := s.state.NewTask("fake-install", fmt.Sprintf("Install %s", name))
+		tInstall.WaitFor(tPrepare)
+		ts := state.NewTaskSet(tPrepare, tInstall)
+		ts.MarkEdge(tPrepare, snapstate.LastBeforeLocalModificationsEdge)
+		return ts, nil
+	})
+	defer restore()
+
+	new := s.brands.Model("canonical", "pc-model", map[string]interface{}{
+		"architecture":   "amd64",
+		"kernel":         "pc-kernel",
+		"gadget":         "pc",
+		"base":           "core18",
+		"required-snaps": []interface{}{"new-required-snap-1"},
+		"revision":       "1",
+	})
+	snap1 := &snap.SideInfo{RealName: "new-required-snap-1", SnapID: "snap-1-id", Revision: snap.R(1)}
+
+	for _, tc := range []struct {
+		sideInfos []*snap.SideInfo
+		err       string
+	}{
+		{nil, `cannot remodel offline: snap "new-required-snap-1" is needed by the new model but was not provided`},
+		{[]*snap.SideInfo{snap1, {RealName: "other-snap", SnapID: "other-id", Revision: snap.R(1)}},
+			`cannot remodel offline: snap "other-snap" is not part of the new model`},
+		{[]*snap.SideInfo{{RealName: "new-required-snap-1"}},
+			`cannot remodel offline: snap "new-required-snap-1" is not asserted`},
+		{[]*snap.SideInfo{snap1, snap1},
+			`cannot remodel offline: snap "new-required-snap-1" was provided more than once`},
+		// the kernel is not installed and not being changed
+		{[]*snap.SideInfo{snap1, {RealName: "pc-kernel", SnapID: "pc-kernel-id", Revision: snap.R(1)}},
+			`cannot remodel offline, the following provided snaps are not needed: "pc-kernel"`},
+	} {
+		paths := make([]string, len(tc.sideInfos))
+		for i, si := range tc.sideInfos {
+			paths[i] = "/path/to/" + si.RealName + ".snap"
+		}
+		_, err := devicestate.RemodelOffline(s.state, new, tc.sideInfos, paths)
+		c.Check(err, ErrorMatches, tc.err)
+	}
+
+	// remodels needing a new serial cannot be done offline
+	rereg := s.brands.Model("canonical", "rereg-model", map[string]interface{}{
+		"architecture": "amd64",
+		"kernel":       "pc-kernel",
+		"gadget":       "pc",
+		"base":         "core18",
+	})
+	s.newFakeStore = func(devBE storecontext.DeviceBackend) snapstate.StoreService {
+		return nil
+	}
+	_, err := devicestate.RemodelOffline(s.state, rereg, nil, nil)
+	c.Check(err, ErrorMatches, `cannot remodel offline to a different brand or model`)
+}
+
+func (s *deviceMgrRemodelSuite) TestRemodelOfflineValidationSets(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+	s.setupOfflineRemodel(c)
+
+	snapID := "snap1idsnap1idsnap1idsnap1idsnap"
+	vs, err := s.storeSigning.Sign(asserts.ValidationSetType, map[string]interface{}{
+		"type":         "validation-set",
+		"authority-id": "canonical",
+		"series":       "16",
+		"account-id":   "canonical",
+		"name":         "base-set",
+		"sequence":     "1",
+		"snaps": []interface{}{
+			map[string]interface{}{
+				"name":     "new-required-snap-1",
+				"id":       snapID,
+				"presence": "required",
+				"revision": "3",
+			},
+		},
+		"timestamp": time.Now().UTC().Format(time.RFC3339),
+	}, nil, "")
+	c.Assert(err, IsNil)
+	c.Assert(assertstate.Add(s.state, vs), IsNil)
+	assertstate.UpdateValidationSet(s.state, &assertstate.ValidationSetTracking{
+		AccountID: "canonical",
+		Name:      "base-set",
+		Mode:      assertstate.Enforce,
+		Current:   1,
+	})
+
+	new := s.brands.Model("canonical", "pc-model", map[string]interface{}{
+		"architecture":   "amd64",
+		"kernel":         "pc-kernel",
+		"gadget":         "pc",
+		"base":           "core18",
+		"required-snaps": []interface{}{"new-required-snap-1"},
+		"revision":       "1",
+	})
+	sideInfos := []*snap.SideInfo{{RealName: "new-required-snap-1", SnapID: snapID, Revision: snap.R(2)}}
+	_, err = devicestate.RemodelOffline(s.state, new, sideInfos, []string{"/path/to/new-required-snap-1.snap"})
+	c.Check(err, ErrorMatches, `cannot remodel offline: snap "new-required-snap-1" revision 2 does not match revision 3 required by validation sets: 16/canonical/base-set/1`)
+}
+
 func (s *deviceMgrRemodelSuite) TestRemodelSwitchKernelTrack(c *C) {
 	s.state.Lock()
 	defer s.state.Unlock()
@@ -26,6 +26,8 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"time"
 
 	. "gopkg.in/check.v1"
@@ -1320,7 +1322,7 @@ func (s *deviceMgrSystemsCreateSuite) TestDeviceManagerCreateRecoverySystemTasks
 
 	s.state.Lock()
 	defer s.state.Unlock()
-	chg, err := devicestate.CreateRecoverySystem(s.state, "1234")
+	chg, err := devicestate.CreateRecoverySystem(s.state, "1234", devicestate.CreateRecoverySystemOptions{})
 	c.Assert(err, IsNil)
 	c.Assert(chg, NotNil)
 	tsks := chg.Tasks()
@@ -1351,7 +1353,7 @@ func (s *deviceMgrSystemsCreateSuite) TestDeviceManagerCreateRecoverySystemTasks
 
 	s.state.Lock()
 	defer s.state.Unlock()
-	chg, err := devicestate.CreateRecoverySystem(s.state, "1234")
+	chg, err := devicestate.CreateRecoverySystem(s.state, "1234", devicestate.CreateRecoverySystemOptions{})
 	c.Assert(err, ErrorMatches, `recovery system "1234" already exists`)
 	c.Check(chg, IsNil)
 }
@@ -1363,7 +1365,7 @@ func (s *deviceMgrSystemsCreateSuite) TestDeviceManagerCreateRecoverySystemNotSe
 	defer s.state.Unlock()
 	s.state.Set("seeded", nil)
 
-	chg, err := devicestate.CreateRecoverySystem(s.state, "1234")
+	chg, err := devicestate.CreateRecoverySystem(s.state, "1234", devicestate.CreateRecoverySystemOptions{})
 	c.Assert(err, ErrorMatches, `cannot create new recovery systems until fully seeded`)
 	c.Check(chg, IsNil)
 }
@@ -1425,7 +1427,7 @@ func (s *deviceMgrSystemsCreateSuite) TestDeviceManagerCreateRecoverySystemHappy
 	devicestate.SetBootOkRan(s.mgr, true)
 
 	s.state.Lock()
-	chg, err := devicestate.CreateRecoverySystem(s.state, "1234")
+	chg, err := devicestate.CreateRecoverySystem(s.state, "1234", devicestate.CreateRecoverySystemOptions{})
 	c.Assert(err, IsNil)
 	c.Assert(chg, NotNil)
 	tsks := chg.Tasks()
@@ -1795,7 +1797,7 @@ func (s *deviceMgrSystemsCreateSuite) TestDeviceManagerCreateRecoverySystemUndo(
 	devicestate.SetBootOkRan(s.mgr, true)
 
 	s.state.Lock()
-	chg, err := devicestate.CreateRecoverySystem(s.state, "1234undo")
+	chg, err := devicestate.CreateRecoverySystem(s.state, "1234undo", devicestate.CreateRecoverySystemOptions{})
 	c.Assert(err, IsNil)
 	c.Assert(chg, NotNil)
 	tsks := chg.Tasks()
@@ -1911,7 +1913,7 @@ func (s *deviceMgrSystemsCreateSuite) TestDeviceManagerCreateRecoverySystemFinal
 	devicestate.SetBootOkRan(s.mgr, true)
 
 	s.state.Lock()
-	chg, err := devicestate.CreateRecoverySystem(s.state, "1234")
+	chg, err := devicestate.CreateRecoverySystem(s.state, "1234", devicestate.CreateRecoverySystemOptions{})
 	c.Assert(err, IsNil)
 	c.Assert(chg, NotNil)
 	tsks := chg.Tasks()
@@ -2010,7 +2012,7 @@ func (s *deviceMgrSystemsCreateSuite) TestDeviceManagerCreateRecoverySystemErrCl
 	devicestate.SetBootOkRan(s.mgr, true)
 
 	s.state.Lock()
-	chg, err := devicestate.CreateRecoverySystem(s.state, "1234error")
+	chg, err := devicestate.CreateRecoverySystem(s.state, "1234error", devicestate.CreateRecoverySystemOptions{})
 	c.Assert(err, IsNil)
 	c.Assert(chg, NotNil)
 	tsks := chg.Tasks()
@@ -2081,7 +2083,7 @@ func (s *deviceMgrSystemsCreateSuite) TestDeviceManagerCreateRecoverySystemReboo
 	devicestate.SetBootOkRan(s.mgr, true)
 
 	s.state.Lock()
-	chg, err := devicestate.CreateRecoverySystem(s.state, "1234reboot")
+	chg, err := devicestate.CreateRecoverySystem(s.state, "1234reboot", devicestate.CreateRecoverySystemOptions{})
 	c.Assert(err, IsNil)
 	c.Assert(chg, NotNil)
 	tsks := chg.Tasks()
@@ -2179,6 +2181,372 @@ func (s *deviceMgrSystemsCreateSuite) TestDeviceManagerCreateRecoverySystemReboo
 	c.Check(triedSystems, HasLen, 0)
 }
 
+func (s *deviceMgrSystemsCreateSuite) TestDeviceManagerCreateRecoverySystemWithOptionsTasks(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	sideInfos := []*snap.SideInfo{{RealName: "pc", SnapID: s.ss.AssertedSnapID("pc"), Revision: snap.R(5)}}
+	chg, err := devicestate.CreateRecoverySystem(s.state, "1234", devicestate.CreateRecoverySystemOptions{
+		LocalSnapSideInfos: sideInfos,
+		LocalSnapPaths:     []string{"/path/to/pc.snap"},
+		MarkDefault:        true,
+	})
+	c.Assert(err, IsNil)
+	tsks := chg.Tasks()
+	c.Assert(tsks, HasLen, 2)
+	var systemSetupData map[string]interface{}
+	err = tsks[0].Get("recovery-system-setup", &systemSetupData)
+	c.Assert(err, IsNil)
+	c.Check(systemSetupData["label"], Equals, "1234")
+	c.Check(systemSetupData["mark-default"], Equals, true)
+	c.Check(systemSetupData["local-snaps"], DeepEquals, []interface{}{
+		map[string]interface{}{
+			"side-info": map[string]interface{}{
+				"name":     "pc",
+				"snap-id":  s.ss.AssertedSnapID("pc"),
+				"revision": "5",
+			},
+			"path": "/path/to/pc.snap",
+		},
+	})
+}
+
+func (s *deviceMgrSystemsCreateSuite) TestDeviceManagerCreateRecoverySystemLocalSnapsErrors(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	for _, tc := range []struct {
+		sideInfos []*snap.SideInfo
+		paths     []string
+		err       string
+	}{{
+		sideInfos: []*snap.SideInfo{{RealName: "foo", SnapID: "fooidfooidfooidfooidfooidfooidfo", Revision: snap.R(1)}},
+		paths:     []string{"/path/to/foo.snap"},
+		err:       `cannot create recovery system: snap "foo" is not part of the model`,
+	}, {
+		sideInfos: []*snap.SideInfo{{RealName: "pc", Revision: snap.R(-1)}},
+		paths:     []string{"/path/to/pc.snap"},
+		err:       `cannot create recovery system: snap "pc" is not asserted`,
+	}, {
+		sideInfos: []*snap.SideInfo{{RealName: "pc", SnapID: "fooidfooidfooidfooidfooidfooidfo", Revision: snap.R(1)}},
+		paths:     []string{"/path/to/pc.snap"},
+		err:       `cannot create recovery system: snap "pc" has snap ID "fooidfooidfooidfooidfooidfooidfo" which does not match the model`,
+	}, {
+		sideInfos: []*snap.SideInfo{
+			{RealName: "pc", SnapID: s.ss.AssertedSnapID("pc"), Revision: snap.R(1)},
+			{RealName: "pc", SnapID: s.ss.AssertedSnapID("pc"), Revision: snap.R(2)},
+		},
+		paths: []string{"/path/to/pc_1.snap", "/path/to/pc_2.snap"},
+		err:   `cannot create recovery system: snap "pc" provided more than once`,
+	}, {
+		sideInfos: []*snap.SideInfo{{RealName: "pc", SnapID: s.ss.AssertedSnapID("pc"), Revision: snap.R(1)}},
+		err:       `internal error: side infos and paths of local snaps do not match`,
+	}} {
+		chg, err := devicestate.CreateRecoverySystem(s.state, "1234", devicestate.CreateRecoverySystemOptions{
+			LocalSnapSideInfos: tc.sideInfos,
+			LocalSnapPaths:     tc.paths,
+		})
+		c.Check(err, ErrorMatches, tc.err)
+		c.Check(chg, IsNil)
+	}
+	c.Check(s.state.Changes(), HasLen, 0)
+}
+
+func (s *deviceMgrSystemsCreateSuite) TestDeviceManagerCreateRecoverySystemValidationSets(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	for name, rev := range map[string]int{"pc": 1, "pc-kernel": 2, "core20": 3, "snapd": 4} {
+		si := &snap.SideInfo{RealName: name, SnapID: s.ss.AssertedSnapID(name), Revision: snap.R(rev)}
+		snapstate.Set(s.state, name, &snapstate.SnapState{
+			Active:   true,
+			Sequence: []*snap.SideInfo{si},
+			Current:  si.Revision,
+		})
+	}
+
+	vs, err := s.storeSigning.Sign(asserts.ValidationSetType, map[string]interface{}{
+		"type":         "validation-set",
+		"authority-id": "canonical",
+		"series":       "16",
+		"account-id":   "canonical",
+		"name":         "base-set",
+		"sequence":     "1",
+		"snaps": []interface{}{
+			map[string]interface{}{
+				"name":     "pc",
+				"id":       s.ss.AssertedSnapID("pc"),
+				"presence": "required",
+				"revision": "5",
+			},
+		},
+		"timestamp": time.Now().UTC().Format(time.RFC3339),
+	}, nil, "")
+	c.Assert(err, IsNil)
+	valsets := []*asserts.ValidationSet{vs.(*asserts.ValidationSet)}
+
+	// the installed revision of pc does not match
+	chg, err := devicestate.CreateRecoverySystem(s.state, "1234", devicestate.CreateRecoverySystemOptions{
+		ValidationSets: valsets,
+	})
+	c.Assert(err, ErrorMatches, `(?s)cannot create recovery system: validation sets assertions are not met:.*- pc \(required at revision 5 by sets canonical/base-set\)`)
+	c.Check(chg, IsNil)
+
+	// but the provided one does
+	chg, err = devicestate.CreateRecoverySystem(s.state, "1234", devicestate.CreateRecoverySystemOptions{
+		ValidationSets:     valsets,
+		LocalSnapSideInfos: []*snap.SideInfo{{RealName: "pc", SnapID: s.ss.AssertedSnapID("pc"), Revision: snap.R(5)}},
+		LocalSnapPaths:     []string{"/path/to/pc.snap"},
+	})
+	c.Assert(err, IsNil)
+	c.Check(chg, NotNil)
+}
+
+func (s *deviceMgrSystemsCreateSuite) TestDeviceManagerCreateRecoverySystemConflict(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	chg := s.state.NewChange("remodel", "...")
+	chg.AddTask(s.state.NewTask("nop", ""))
+
+	_, err := devicestate.CreateRecoverySystem(s.state, "1234", devicestate.CreateRecoverySystemOptions{})
+	c.Assert(err, ErrorMatches, `recovery systems are being modified by change 1`)
+	c.Check(err, FitsTypeOf, &snapstate.ChangeConflictError{})
+
+	_, err = devicestate.RemoveRecoverySystem(s.state, "1234")
+	// the system does not exist
+	c.Check(os.IsNotExist(err), Equals, true)
+	c.Assert(os.MkdirAll(filepath.Join(boot.InitramfsUbuntuSeedDir, "systems/1234"), 0755), IsNil)
+	_, err = devicestate.RemoveRecoverySystem(s.state, "1234")
+	c.Assert(err, ErrorMatches, `recovery systems are being modified by change 1`)
+}
+
+type fakeSeedWithSnaps struct {
+	fakeSeed
+	snaps []*seed.Snap
+}
+
+func (fs *fakeSeedWithSnaps) Iter(f func(sn *seed.Snap) error) error {
+	for _, sn := range fs.snaps {
+		if err := f(sn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *deviceMgrSystemsCreateSuite) mockRecoverySystems(c *C, systemSnaps map[string][]string) {
+	var labels []string
+	for label, snapFiles := range systemSnaps {
+		labels = append(labels, label)
+		for _, fn := range snapFiles {
+			c.Assert(os.MkdirAll(filepath.Dir(fn), 0755), IsNil)
+			c.Assert(ioutil.WriteFile(fn, nil, 0644), IsNil)
+		}
+		c.Assert(os.MkdirAll(filepath.Join(boot.InitramfsUbuntuSeedDir, "systems", label), 0755), IsNil)
+	}
+	sort.Strings(labels)
+
+	restore := devicestate.MockSeedOpen(func(seedDir, label string) (seed.Seed, error) {
+		c.Check(seedDir, Equals, boot.InitramfsUbuntuSeedDir)
+		snapFiles, ok := systemSnaps[label]
+		if !ok {
+			return nil, fmt.Errorf("unexpected system %q", label)
+		}
+		fs := &fakeSeedWithSnaps{fakeSeed: fakeSeed{model: s.model}}
+		for _, fn := range snapFiles {
+			fs.snaps = append(fs.snaps, &seed.Snap{Path: fn})
+		}
+		return fs, nil
+	})
+	s.AddCleanup(restore)
+
+	modeenv := boot.Modeenv{
+		Mode:                   "run",
+		CurrentRecoverySystems: labels,
+		GoodRecoverySystems:    labels,
+
+		Model:          s.model.Model(),
+		BrandID:        s.model.BrandID(),
+		Grade:          string(s.model.Grade()),
+		ModelSignKeyID: s.model.SignKeyID(),
+	}
+	c.Assert(modeenv.WriteTo(""), IsNil)
+	err := s.bootloader.SetBootVars(map[string]string{
+		"snapd_good_recovery_systems": strings.Join(labels, ","),
+	})
+	c.Assert(err, IsNil)
+}
+
+func (s *deviceMgrSystemsCreateSuite) TestDeviceManagerRemoveRecoverySystemHappy(c *C) {
+	devicestate.SetBootOkRan(s.mgr, true)
+
+	seedSnapsDir := filepath.Join(boot.InitramfsUbuntuSeedDir, "snaps")
+	s.mockRecoverySystems(c, map[string][]string{
+		"1234": {
+			filepath.Join(seedSnapsDir, "pc_1.snap"),
+			filepath.Join(seedSnapsDir, "core20_3.snap"),
+			filepath.Join(boot.InitramfsUbuntuSeedDir, "systems/1234/snaps/local_x1.snap"),
+		},
+		"5678": {
+			filepath.Join(seedSnapsDir, "pc_2.snap"),
+			filepath.Join(seedSnapsDir, "core20_3.snap"),
+		},
+	})
+
+	s.state.Lock()
+	s.state.Set("seeded-systems", []devicestate.SeededSystem{{System: "5678"}, {System: "1234"}})
+	chg, err := devicestate.RemoveRecoverySystem(s.state, "1234")
+	c.Assert(err, IsNil)
+	tsks := chg.Tasks()
+	c.Assert(tsks, HasLen, 1)
+	c.Check(tsks[0].Summary(), Equals, `Remove recovery system with label "1234"`)
+
+	s.state.Unlock()
+	s.settle(c)
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	c.Assert(chg.Err(), IsNil)
+	c.Check(filepath.Join(boot.InitramfsUbuntuSeedDir, "systems/1234"), testutil.FileAbsent)
+	c.Check(filepath.Join(seedSnapsDir, "pc_1.snap"), testutil.FileAbsent)
+	// still used by the other system
+	c.Check(filepath.Join(seedSnapsDir, "core20_3.snap"), testutil.FilePresent)
+	c.Check(filepath.Join(seedSnapsDir, "pc_2.snap"), testutil.FilePresent)
+	c.Check(filepath.Join(boot.InitramfsUbuntuSeedDir, "systems/5678"), testutil.FilePresent)
+
+	var seeded []devicestate.SeededSystem
+	c.Assert(s.state.Get("seeded-systems", &seeded), IsNil)
+	c.Check(seeded, DeepEquals, []devicestate.SeededSystem{{System: "5678"}})
+
+	modeenvAfter, err := boot.ReadModeenv("")
+	c.Assert(err, IsNil)
+	c.Check(modeenvAfter.CurrentRecoverySystems, DeepEquals, []string{"5678"})
+	c.Check(modeenvAfter.GoodRecoverySystems, DeepEquals, []string{"5678"})
+	m, err := s.bootloader.GetBootVars("snapd_good_recovery_systems")
+	c.Assert(err, IsNil)
+	c.Check(m, DeepEquals, map[string]string{
+		"snapd_good_recovery_systems": "5678",
+	})
+}
+
+func (s *deviceMgrSystemsCreateSuite) TestDeviceManagerRemoveRecoverySystemRerunAfterDirectoryRemoved(c *C) {
+	devicestate.SetBootOkRan(s.mgr, true)
+
+	seedSnapsDir := filepath.Join(boot.InitramfsUbuntuSeedDir, "snaps")
+	s.mockRecoverySystems(c, map[string][]string{
+		"5678": {filepath.Join(seedSnapsDir, "pc_2.snap")},
+	})
+	// the system directory is gone, but not all its seed snaps
+	leftover := filepath.Join(seedSnapsDir, "pc_1.snap")
+	c.Assert(ioutil.WriteFile(leftover, nil, 0644), IsNil)
+
+	s.state.Lock()
+	chg := s.state.NewChange("remove-recovery-system", "...")
+	remove := s.state.NewTask("remove-recovery-system", "...")
+	remove.Set("recovery-system-setup", map[string]interface{}{
+		"label":     "1234",
+		"directory": filepath.Join(boot.InitramfsUbuntuSeedDir, "systems/1234"),
+	})
+	remove.Set("seed-snaps-to-remove", []string{leftover, filepath.Join(seedSnapsDir, "core20_3.snap")})
+	chg.AddTask(remove)
+	s.state.Unlock()
+
+	s.settle(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Assert(chg.Err(), IsNil)
+	c.Check(leftover, testutil.FileAbsent)
+	c.Check(filepath.Join(seedSnapsDir, "pc_2.snap"), testutil.FilePresent)
+}
+
+func (s *deviceMgrSystemsCreateSuite) TestDeviceManagerRemoveRecoverySystemErrors(c *C) {
+	s.mockRecoverySystems(c, map[string][]string{
+		"1234": nil,
+		"5678": nil,
+	})
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	s.state.Set("seeded-systems", []devicestate.SeededSystem{{System: "5678"}})
+	s.state.Set("default-recovery-system", &devicestate.DefaultRecoverySystem{System: "1234"})
+
+	_, err := devicestate.RemoveRecoverySystem(s.state, "")
+	c.Check(err, ErrorMatches, `cannot remove a recovery system without a label`)
+	for _, label := range []string{".", "..", "../1234", "1234/"} {
+		_, err = devicestate.RemoveRecoverySystem(s.state, label)
+		c.Check(err, ErrorMatches, `cannot remove recovery system: invalid seed system label: ".*"`, Commentf("%s", label))
+	}
+	_, err = devicestate.RemoveRecoverySystem(s.state, "9999")
+	c.Check(os.IsNotExist(err), Equals, true)
+	_, err = devicestate.RemoveRecoverySystem(s.state, "5678")
+	c.Check(err, ErrorMatches, `cannot remove the current recovery system "5678"`)
+	_, err = devicestate.RemoveRecoverySystem(s.state, "1234")
+	c.Check(err, ErrorMatches, `cannot remove the default recovery system "1234"`)
+	c.Check(s.state.Changes(), HasLen, 0)
+}
+
+func (s *deviceMgrSystemsCreateSuite) TestDeviceManagerSetDefaultRecoverySystem(c *C) {
+	s.mockRecoverySystems(c, map[string][]string{
+		"1234": nil,
+		"5678": nil,
+	})
+	c.Assert(os.MkdirAll(filepath.Join(dirs.SnapSeedDir, "systems/1234"), 0755), IsNil)
+	restore := devicestate.MockSeedOpen(func(seedDir, label string) (seed.Seed, error) {
+		return &fakeSeed{model: s.model}, nil
+	})
+	defer restore()
+	now := time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC)
+	defer devicestate.MockTimeNow(func() time.Time { return now })()
+
+	s.state.Lock()
+	err := devicestate.SetDefaultRecoverySystem(s.state, "1234")
+	c.Assert(err, IsNil)
+	var defaultSys devicestate.DefaultRecoverySystem
+	c.Assert(s.state.Get("default-recovery-system", &defaultSys), IsNil)
+	s.state.Unlock()
+
+	c.Check(defaultSys, DeepEquals, devicestate.DefaultRecoverySystem{
+		System:          "1234",
+		Model:           s.model.Model(),
+		BrandID:         s.model.BrandID(),
+		Revision:        s.model.Revision(),
+		Timestamp:       s.model.Timestamp(),
+		TimeMadeDefault: now,
+	})
+	// the default system comes last
+	m, err := s.bootloader.GetBootVars("snapd_good_recovery_systems")
+	c.Assert(err, IsNil)
+	c.Check(m, DeepEquals, map[string]string{
+		"snapd_good_recovery_systems": "5678,1234",
+	})
+
+	systems, err := s.mgr.Systems()
+	c.Assert(err, IsNil)
+	c.Assert(systems, HasLen, 1)
+	c.Check(systems[0].Label, Equals, "1234")
+	c.Check(systems[0].DefaultRecoverySystem, Equals, true)
+}
+
+func (s *deviceMgrSystemsCreateSuite) TestDeviceManagerSetDefaultRecoverySystemErrors(c *C) {
+	s.mockRecoverySystems(c, map[string][]string{
+		"1234": nil,
+	})
+	// not known to be good
+	c.Assert(os.MkdirAll(filepath.Join(boot.InitramfsUbuntuSeedDir, "systems/5678"), 0755), IsNil)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	err := devicestate.SetDefaultRecoverySystem(s.state, "")
+	c.Check(err, ErrorMatches, `cannot set the default recovery system without a label`)
+	err = devicestate.SetDefaultRecoverySystem(s.state, "9999")
+	c.Check(os.IsNotExist(err), Equals, true)
+	err = devicestate.SetDefaultRecoverySystem(s.state, "5678")
+	c.Check(err, ErrorMatches, `cannot set "5678" as the default recovery system: system is not known to be good`)
+}
+
 type systemSnapTrackingSuite struct {
 	deviceMgrSystemsBaseSuite
 }
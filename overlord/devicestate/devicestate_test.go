@@ -67,6 +67,7 @@ import (
 	"github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/snap/snaptest"
 	"github.com/snapcore/snapd/snapdenv"
+	"github.com/snapcore/snapd/store/offline"
 	"github.com/snapcore/snapd/store/storetest"
 	"github.com/snapcore/snapd/sysconfig"
 	"github.com/snapcore/snapd/testutil"
@@ -2654,3 +2655,46 @@ func (s *deviceMgrSuite) TestHandleAutoImportAssertionHappy(c *C) {
 	c.Assert(err, IsNil)
 	c.Assert(autoImported, Equals, true)
 }
+
+func (s *deviceMgrSuite) TestResetStore(c *C) {
+	regularStore := &storetest.Store{}
+	var capturedDevBE storecontext.DeviceBackend
+	s.newFakeStore = func(devBE storecontext.DeviceBackend) snapstate.StoreService {
+		capturedDevBE = devBE
+		return regularStore
+	}
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	devicestate.ResetStore(s.state)
+	c.Check(snapstate.Store(s.state, nil), Equals, regularStore)
+	c.Check(capturedDevBE, NotNil)
+
+	tr := config.NewTransaction(s.state)
+	c.Assert(tr.Set("core", "store.offline-dir", "/media/usb/store"), IsNil)
+	tr.Commit()
+
+	devicestate.ResetStore(s.state)
+	sto, ok := snapstate.Store(s.state, nil).(*offline.Store)
+	c.Assert(ok, Equals, true)
+	c.Check(sto.Dir(), Equals, "/media/usb/store")
+}
+
+func (s *deviceMgrSuite) TestUseOfflineStore(c *C) {
+	regularStore := &storetest.Store{}
+	s.newFakeStore = func(devBE storecontext.DeviceBackend) snapstate.StoreService {
+		return regularStore
+	}
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	devicestate.UseOfflineStore(s.state, "/media/usb/store")
+	sto, ok := snapstate.Store(s.state, nil).(*offline.Store)
+	c.Assert(ok, Equals, true)
+	c.Check(sto.Dir(), Equals, "/media/usb/store")
+
+	devicestate.UseOfflineStore(s.state, "")
+	c.Check(snapstate.Store(s.state, nil), Equals, regularStore)
+}
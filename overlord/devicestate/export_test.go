@@ -176,6 +176,22 @@ func MockSnapstateUpdateWithDeviceContext(f func(st *state.State, name string, o
 	}
 }
 
+func MockSnapstateInstallPathWithDeviceContext(f func(st *state.State, si *snap.SideInfo, path, name, channel string, flags snapstate.Flags, deviceCtx snapstate.DeviceContext, fromChange string) (*state.TaskSet, error)) (restore func()) {
+	old := snapstateInstallPathWithDeviceContext
+	snapstateInstallPathWithDeviceContext = f
+	return func() {
+		snapstateInstallPathWithDeviceContext = old
+	}
+}
+
+func MockSnapstateSwitch(f func(st *state.State, name string, opts *snapstate.RevisionOptions) (*state.TaskSet, error)) (restore func()) {
+	old := snapstateSwitch
+	snapstateSwitch = f
+	return func() {
+		snapstateSwitch = old
+	}
+}
+
 func EnsureSeeded(m *DeviceManager) error {
 	return m.ensureSeeded()
 }
@@ -286,7 +302,9 @@ var (
 	IncEnsureOperationalAttempts = incEnsureOperationalAttempts
 	EnsureOperationalAttempts    = ensureOperationalAttempts
 
-	RemodelTasks = remodelTasks
+	RemodelTasks = func(ctx context.Context, st *state.State, current, new *asserts.Model, deviceCtx snapstate.DeviceContext, fromChange string) ([]*state.TaskSet, error) {
+		return remodelTasks(ctx, st, current, new, deviceCtx, fromChange, nil)
+	}
 
 	RemodelCtx        = remodelCtx
 	CleanupRemodelCtx = cleanupRemodelCtx
@@ -39,9 +39,11 @@ import (
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/release"
+	"github.com/snapcore/snapd/seed"
 	"github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/snap/snapfile"
 	"github.com/snapcore/snapd/strutil"
+	"github.com/snapcore/snapd/timings"
 )
 
 func taskRecoverySystemSetup(t *state.Task) (*recoverySystemSetup, error) {
@@ -145,8 +147,30 @@ func (m *DeviceManager) doCreateRecoverySystem(t *state.Task, _ *tomb.Tomb) (err
 	systemDirectory := setup.Directory
 
 	// get all infos
-	infoGetter := func(name string) (info *snap.Info, present bool, err error) {
-		// snaps are either being fetched or present in the system
+	infoGetter := func(name string) (info *snap.Info, path string, present bool, err error) {
+		// snaps are either being fetched, provided locally or present
+		// in the system
+
+		for _, ls := range setup.LocalSnaps {
+			if ls.SideInfo.RealName != name {
+				continue
+			}
+			logger.Debugf("requested info for locally provided snap %q", name)
+			snapFile, err := snapfile.Open(ls.Path)
+			if err != nil {
+				return nil, "", false, err
+			}
+			info, err = snap.ReadInfoFromSnapFile(snapFile, ls.SideInfo)
+			if err != nil {
+				return nil, "", false, err
+			}
+			hash, _, err := asserts.SnapFileSHA3_384(ls.Path)
+			if err != nil {
+				return nil, "", true, fmt.Errorf("cannot compute SHA3 of snap file: %v", err)
+			}
+			info.Sha3_384 = hash
+			return info, ls.Path, true, nil
+		}
 
 		if isRemodel {
 			// in a remodel scenario, the snaps may need to be
@@ -159,23 +183,30 @@ func (m *DeviceManager) doCreateRecoverySystem(t *state.Task, _ *tomb.Tomb) (err
 				taskWithSnapSetup := st.Task(tskID)
 				snapsup, err := snapstate.TaskSnapSetup(taskWithSnapSetup)
 				if err != nil {
-					return nil, false, err
+					return nil, "", false, err
 				}
 				if snapsup.SnapName() != name {
 					continue
 				}
 				// by the time this task runs, the file has already been
 				// downloaded and validated
-				snapFile, err := snapfile.Open(snapsup.MountFile())
+				snapPath := snapsup.MountFile()
+				if snapsup.SnapPath != "" {
+					// snaps provided locally, as in an
+					// offline remodel, are only copied
+					// into place when installed
+					snapPath = snapsup.SnapPath
+				}
+				snapFile, err := snapfile.Open(snapPath)
 				if err != nil {
-					return nil, false, err
+					return nil, "", false, err
 				}
 				info, err = snap.ReadInfoFromSnapFile(snapFile, snapsup.SideInfo)
 				if err != nil {
-					return nil, false, err
+					return nil, "", false, err
 				}
 
-				return info, true, nil
+				return info, snapPath, true, nil
 			}
 		}
 
@@ -188,15 +219,15 @@ func (m *DeviceManager) doCreateRecoverySystem(t *state.Task, _ *tomb.Tomb) (err
 		if err == nil {
 			hash, _, err := asserts.SnapFileSHA3_384(info.MountFile())
 			if err != nil {
-				return nil, true, fmt.Errorf("cannot compute SHA3 of snap file: %v", err)
+				return nil, "", true, fmt.Errorf("cannot compute SHA3 of snap file: %v", err)
 			}
 			info.Sha3_384 = hash
-			return info, true, nil
+			return info, info.MountFile(), true, nil
 		}
 		if _, ok := err.(*snap.NotInstalledError); !ok {
-			return nil, false, err
+			return nil, "", false, err
 		}
-		return nil, false, nil
+		return nil, "", false, nil
 	}
 
 	observeSnapFileWrite := func(recoverySystemDir, where string) error {
@@ -372,6 +403,12 @@ func (m *DeviceManager) doFinalizeTriedRecoverySystem(t *state.Task, _ *tomb.Tom
 
 		// tried systems should be a one item list, we can clear it now
 		st.Set("tried-systems", nil)
+
+		if setup.MarkDefault {
+			if err := setDefaultRecoverySystem(st, label); err != nil {
+				return err
+			}
+		}
 	}
 
 	// we are done
@@ -415,5 +452,153 @@ func (m *DeviceManager) cleanupRecoverySystem(t *state.Task, _ *tomb.Tomb) error
 	if os.Remove(filepath.Join(setup.Directory, "snapd-new-file-log")); err != nil && !os.IsNotExist(err) {
 		return err
 	}
+	// locally provided snap files are owned by the change
+	for _, ls := range setup.LocalSnaps {
+		if err := os.Remove(ls.Path); err != nil && !os.IsNotExist(err) {
+			logger.Noticef("cannot remove local snap file %q: %v", ls.Path, err)
+		}
+	}
+	return nil
+}
+
+// seedSnapFiles returns the paths of the snap files used by the recovery
+// system with the given label.
+func seedSnapFiles(seedDir, label string) ([]string, error) {
+	sysSeed, err := seedOpen(seedDir, label)
+	if err != nil {
+		return nil, err
+	}
+	if err := sysSeed.LoadAssertions(nil, nil); err != nil {
+		return nil, err
+	}
+	if err := sysSeed.LoadMeta(seed.AllModes, nil, timings.New(nil)); err != nil {
+		return nil, err
+	}
+	var paths []string
+	err = sysSeed.Iter(func(sn *seed.Snap) error {
+		paths = append(paths, sn.Path)
+		return nil
+	})
+	return paths, err
+}
+
+func (m *DeviceManager) doRemoveRecoverySystem(t *state.Task, _ *tomb.Tomb) error {
+	if release.OnClassic {
+		// TODO: this may need to be lifted in the future
+		return fmt.Errorf("cannot remove recovery systems on a classic system")
+	}
+
+	st := t.State()
+	st.Lock()
+	defer st.Unlock()
+
+	deviceCtx, err := DeviceCtx(st, t, nil)
+	if err != nil {
+		return err
+	}
+
+	setup, err := taskRecoverySystemSetup(t)
+	if err != nil {
+		return fmt.Errorf("internal error: cannot obtain recovery system setup information")
+	}
+	label := setup.Label
+
+	exists, _, err := osutil.DirExists(setup.Directory)
+	if err != nil {
+		return err
+	}
+	if exists {
+		if err := removeRecoverySystemDirectory(t, deviceCtx, label, setup.Directory); err != nil {
+			return err
+		}
+	}
+	// when the task is re-run after the system directory was removed,
+	// only the seed snaps it left behind remain to be removed
+
+	var toRemove []string
+	if err := t.Get("seed-snaps-to-remove", &toRemove); err != nil && !errors.Is(err, state.ErrNoState) {
+		return err
+	}
+	for _, p := range toRemove {
+		if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+			t.Logf("cannot remove seed snap %q: %v", p, err)
+		}
+	}
+
+	// do not keep pointing to the removed system
+	var seeded []seededSystem
+	if err := st.Get("seeded-systems", &seeded); err != nil && !errors.Is(err, state.ErrNoState) {
+		return err
+	}
+	kept := seeded[:0]
+	for _, sys := range seeded {
+		if sys.System != label {
+			kept = append(kept, sys)
+		}
+	}
+	if len(kept) != len(seeded) {
+		st.Set("seeded-systems", kept)
+	}
+	return nil
+}
+
+// removeRecoverySystemDirectory stops considering the recovery system for
+// recovery and removes its directory, recording in the task the seed snaps
+// that are then no longer used by any recovery system. The directory goes
+// first as the seed snaps of the system can no longer be found once some of
+// them are removed.
+func removeRecoverySystemDirectory(t *state.Task, deviceCtx snapstate.DeviceContext, label, systemDirectory string) error {
+	systemLabels, err := filepath.Glob(filepath.Join(boot.InitramfsUbuntuSeedDir, "systems", "*"))
+	if err != nil {
+		return fmt.Errorf("cannot list recovery systems: %v", err)
+	}
+	systemSnaps, err := seedSnapFiles(boot.InitramfsUbuntuSeedDir, label)
+	if err != nil {
+		return fmt.Errorf("cannot load recovery system %q: %v", label, err)
+	}
+	// snaps in the common snaps directory may be shared with other
+	// recovery systems
+	inUse := make(map[string]bool)
+	for _, fpLabel := range systemLabels {
+		other := filepath.Base(fpLabel)
+		if other == label {
+			continue
+		}
+		paths, err := seedSnapFiles(boot.InitramfsUbuntuSeedDir, other)
+		if err != nil {
+			return fmt.Errorf("cannot load recovery system %q: %v", other, err)
+		}
+		for _, p := range paths {
+			inUse[p] = true
+		}
+	}
+	var toRemove []string
+	for _, p := range systemSnaps {
+		if inUse[p] || strings.HasPrefix(p, systemDirectory+"/") {
+			continue
+		}
+		toRemove = append(toRemove, p)
+	}
+	t.Set("seed-snaps-to-remove", toRemove)
+
+	// no longer consider the system for recovery before its files are
+	// gone
+	if err := boot.UnmarkRecoveryCapableSystem(label); err != nil {
+		return fmt.Errorf("cannot unmark recovery system %q: %v", label, err)
+	}
+	if err := boot.DropRecoverySystem(deviceCtx, label); err != nil {
+		return fmt.Errorf("cannot drop recovery system %q: %v", label, err)
+	}
+
+	// unlocking writes the state, so that the seed snaps to remove are
+	// known if the task is re-run once the directory is gone
+	st := t.State()
+	st.Unlock()
+	err = os.RemoveAll(systemDirectory)
+	st.Lock()
+	if err != nil {
+		return fmt.Errorf("cannot remove recovery system %q: %v", label, err)
+	}
+	t.Logf("removed recovery system directory %v", systemDirectory)
 	return nil
 }
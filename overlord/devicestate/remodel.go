@@ -286,8 +286,9 @@ func (rc *baseRemodelContext) updateRunModeSystem() error {
 	}); err != nil {
 		return fmt.Errorf("cannot record a new seeded system: %v", err)
 	}
-	if err := boot.MarkRecoveryCapableSystem(rc.recoverySystemLabel); err != nil {
-		return fmt.Errorf("cannot mark system %q as recovery capable", rc.recoverySystemLabel)
+	// the new recovery system becomes the default one
+	if err := markDefaultRecoverySystem(rc.st, rc.recoverySystemLabel, rc.model); err != nil {
+		return err
 	}
 	return nil
 }
@@ -348,7 +349,7 @@ func newNewStoreRemodelContext(st *state.State, devMgr *DeviceManager, newModel,
 		deviceMgr: devMgr,
 		st:        st,
 	}
-	rc.store = devMgr.newStore(rc.deviceBackend())
+	rc.store = devMgr.newStoreFor(rc.deviceBackend())
 	return rc
 }
 
@@ -39,6 +39,7 @@ import (
 	"github.com/snapcore/snapd/overlord/assertstate"
 	"github.com/snapcore/snapd/overlord/assertstate/assertstatetest"
 	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/overlord/configstate/config"
 	"github.com/snapcore/snapd/overlord/devicestate"
 	"github.com/snapcore/snapd/overlord/devicestate/devicestatetest"
 	"github.com/snapcore/snapd/overlord/hookstate"
@@ -46,6 +47,7 @@ import (
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/overlord/storecontext"
 	"github.com/snapcore/snapd/snap/snaptest"
+	"github.com/snapcore/snapd/store/offline"
 	"github.com/snapcore/snapd/store/storetest"
 	"github.com/snapcore/snapd/testutil"
 )
@@ -464,6 +466,36 @@ func (s *remodelLogicSuite) TestNewStoreRemodelContextStore(c *C) {
 	c.Check(sto1, Equals, sto)
 }
 
+func (s *remodelLogicSuite) TestNewStoreRemodelContextOfflineStore(c *C) {
+	oldModel := fakeRemodelingModel(nil)
+	newModel := fakeRemodelingModel(map[string]interface{}{
+		"store":    "my-other-store",
+		"revision": "1",
+	})
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	devicestatetest.SetDevice(s.state, &auth.DeviceState{
+		Brand:  "my-brand",
+		Model:  "my-model",
+		Serial: "serialserialserial",
+	})
+	tr := config.NewTransaction(s.state)
+	c.Assert(tr.Set("core", "store.offline-dir", "/media/usb/store"), IsNil)
+	tr.Commit()
+
+	s.capturedDevBE = nil
+	remodCtx, err := devicestate.RemodelCtx(s.state, oldModel, newModel)
+	c.Assert(err, IsNil)
+
+	// the offline store is used instead of a new store
+	c.Check(s.capturedDevBE, IsNil)
+	sto, ok := remodCtx.Store().(*offline.Store)
+	c.Assert(ok, Equals, true)
+	c.Check(sto.Dir(), Equals, "/media/usb/store")
+}
+
 func (s *remodelLogicSuite) TestNewStoreRemodelContextFinish(c *C) {
 	oldModel := fakeRemodelingModel(nil)
 	newModel := fakeRemodelingModel(map[string]interface{}{
@@ -1159,6 +1191,14 @@ func (s *uc20RemodelLogicSuite) TestReregRemodelContextUC20(c *C) {
 	c.Assert(env, DeepEquals, map[string]string{
 		"snapd_good_recovery_systems": "0000,1234",
 	})
+	// the new system, picked by default by the bootloader, is also the
+	// default one in the state
+	var defaultSys devicestate.DefaultRecoverySystem
+	c.Assert(s.state.Get("default-recovery-system", &defaultSys), IsNil)
+	c.Check(defaultSys.System, Equals, "1234")
+	c.Check(defaultSys.Model, Equals, "other-model")
+	c.Check(defaultSys.BrandID, Equals, "my-brand")
+	c.Check(defaultSys.Timestamp.Equal(newModel.Timestamp()), Equals, true)
 }
 
 func (s *uc20RemodelLogicSuite) TestUpdateRemodelContext(c *C) {
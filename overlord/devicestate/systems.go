@@ -195,10 +195,11 @@ func seededSystemFromModeenv() (*seededSystem, error) {
 }
 
 // getInfoFunc is expected to return for a given snap name a snap.Info for that
-// snap and whether the snap is present is present. The second bit is relevant
-// for non-essential snaps mentioned in the model, which if present and having
-// an 'optional' presence in the model, will be added to the recovery system.
-type getSnapInfoFunc func(name string) (info *snap.Info, snapIsPresent bool, err error)
+// snap, the path to the snap file and whether the snap is present is present.
+// The last bit is relevant for non-essential snaps mentioned in the model,
+// which if present and having an 'optional' presence in the model, will be
+// added to the recovery system.
+type getSnapInfoFunc func(name string) (info *snap.Info, path string, snapIsPresent bool, err error)
 
 // snapWriteObserveFunc is called with the recovery system directory and the
 // path to a snap file being written. The snap file may be written to a location
@@ -248,7 +249,7 @@ func createSystemForModelFromValidatedSnaps(model *asserts.Model, label string,
 				kind = fmt.Sprintf("non-essential but %v", nonEssentialPresence)
 			}
 		}
-		info, present, err := getInfo(name)
+		info, path, present, err := getInfo(name)
 		if err != nil {
 			return fmt.Errorf("cannot obtain %v snap information: %v", kind, err)
 		}
@@ -262,7 +263,7 @@ func createSystemForModelFromValidatedSnaps(model *asserts.Model, label string,
 		if !present {
 			return fmt.Errorf("internal error: %v snap %q not present", kind, name)
 		}
-		if _, ok := modelSnaps[info.MountFile()]; ok {
+		if _, ok := modelSnaps[path]; ok {
 			// we've already seen this snap
 			return nil
 		}
@@ -270,9 +271,9 @@ func createSystemForModelFromValidatedSnaps(model *asserts.Model, label string,
 		// TODO: for grade dangerous we could have a channel here which is not
 		//       the model channel, handle that here
 		optsSnaps = append(optsSnaps, &seedwriter.OptionsSnap{
-			Path: info.MountFile(),
+			Path: path,
 		})
-		modelSnaps[info.MountFile()] = info
+		modelSnaps[path] = info
 		return nil
 	}
 
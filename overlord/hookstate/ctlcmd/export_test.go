@@ -22,6 +22,7 @@ package ctlcmd
 import (
 	"fmt"
 
+	"github.com/snapcore/snapd/aspects"
 	"github.com/snapcore/snapd/asserts"
 	"github.com/snapcore/snapd/overlord/devicestate"
 	"github.com/snapcore/snapd/overlord/hookstate"
@@ -155,3 +156,21 @@ func MockAutoRefreshForGatingSnap(f func(st *state.State, gatingSnap string) err
 		autoRefreshForGatingSnap = old
 	}
 }
+
+func MockAspectstateGetViaAspect(f func(st *state.State, tx *aspects.Transaction, account, bundleName, aspect, field string, value interface{}) error) (restore func()) {
+	r := testutil.Backup(&aspectstateGetViaAspect)
+	aspectstateGetViaAspect = f
+	return r
+}
+
+func MockAspectstateSet(f func(st *state.State, account, bundleName, aspect string, values map[string]interface{}) (*state.Change, error)) (restore func()) {
+	r := testutil.Backup(&aspectstateSet)
+	aspectstateSet = f
+	return r
+}
+
+func MockAspectstateSetViaAspect(f func(st *state.State, tx *aspects.Transaction, account, bundleName, aspect, field string, value interface{}) error) (restore func()) {
+	r := testutil.Backup(&aspectstateSetViaAspect)
+	aspectstateSetViaAspect = f
+	return r
+}
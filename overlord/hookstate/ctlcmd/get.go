@@ -21,17 +21,22 @@ package ctlcmd
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
 
+	"github.com/snapcore/snapd/aspects"
 	"github.com/snapcore/snapd/i18n"
 	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/overlord/aspectstate"
 	"github.com/snapcore/snapd/overlord/configstate"
 	"github.com/snapcore/snapd/overlord/configstate/config"
 	"github.com/snapcore/snapd/overlord/hookstate"
 	"github.com/snapcore/snapd/overlord/state"
 )
 
+var aspectstateGetViaAspect = aspectstate.GetViaAspect
+
 type getCommand struct {
 	baseCommand
 
@@ -39,6 +44,8 @@ type getCommand struct {
 	ForceSlotSide bool `long:"slot" description:"return attribute values from the slot side of the connection"`
 	ForcePlugSide bool `long:"plug" description:"return attribute values from the plug side of the connection"`
 
+	View bool `long:"view" description:"return values of the aspect referenced by the plug"`
+
 	Positional struct {
 		PlugOrSlotSpec string   `positional-args:"true" positional-arg-name:":<plug|slot>"`
 		Keys           []string `positional-arg-name:"<keys>" description:"option keys"`
@@ -82,6 +89,11 @@ with optional --plug and --slot command options:
 
 This requests the "usb-vendor" setting from the slot that is connected to
 "myplug".
+
+Fields of the aspect referenced by a connected "aspects" plug may be printed
+with the --view option:
+
+    $ snapctl get --view :wifi-setup ssid
 `)
 
 func init() {
@@ -146,6 +158,21 @@ func (c *getCommand) Execute(args []string) error {
 		return fmt.Errorf("cannot use -d and -t together")
 	}
 
+	if c.View {
+		if c.ForcePlugSide || c.ForceSlotSide {
+			return fmt.Errorf("cannot use --plug or --slot with --view")
+		}
+		plugName, err := aspectPlugName(c.Positional.PlugOrSlotSpec)
+		if err != nil {
+			return err
+		}
+		if len(c.Positional.Keys) == 0 {
+			return fmt.Errorf(i18n.G("get which aspect field?"))
+		}
+
+		return c.getAspectValues(context, plugName)
+	}
+
 	if strings.Contains(c.Positional.PlugOrSlotSpec, ":") {
 		parts := strings.SplitN(c.Positional.PlugOrSlotSpec, ":", 2)
 		snap, name := parts[0], parts[1]
@@ -194,6 +221,42 @@ func (c *getCommand) getConfigSetting(context *hookstate.Context) error {
 	})
 }
 
+func (c *getCommand) getAspectValues(context *hookstate.Context, plugName string) error {
+	context.Lock()
+	defer context.Unlock()
+
+	st := context.State()
+	aspectRef, err := getAspectPlugRef(st, context.InstanceName(), plugName)
+	if err != nil {
+		return err
+	}
+
+	// hooks running in a change that writes to the bundle read the pending
+	// values, everything else reads the committed ones
+	tx, ok, err := aspectstate.ContextTransaction(context, aspectRef.Account, aspectRef.Bundle)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		tx, err = aspectstate.NewTransaction(st, aspectRef.Account, aspectRef.Bundle)
+		if err != nil {
+			return err
+		}
+	}
+
+	return c.printValues(func(field string) (interface{}, bool, error) {
+		var value interface{}
+		err := aspectstateGetViaAspect(st, tx, aspectRef.Account, aspectRef.Bundle, aspectRef.Aspect, field, &value)
+		if err == nil {
+			return value, true, nil
+		}
+		if errors.Is(err, &aspects.FieldNotFoundError{}) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	})
+}
+
 type ifaceHookType int
 
 const (
@@ -24,6 +24,8 @@ import (
 
 	. "gopkg.in/check.v1"
 
+	"github.com/snapcore/snapd/aspects"
+	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/interfaces"
 	"github.com/snapcore/snapd/overlord/configstate"
 	"github.com/snapcore/snapd/overlord/configstate/config"
@@ -32,6 +34,7 @@ import (
 	"github.com/snapcore/snapd/overlord/hookstate/hooktest"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/testutil"
 )
 
 type getSuite struct {
@@ -436,3 +439,156 @@ func (s *getAttrSuite) TestSlotHookTests(c *C) {
 		}
 	}
 }
+
+type aspectSuiteBase struct {
+	testutil.BaseTest
+
+	st          *state.State
+	mockHandler *hooktest.MockHandler
+	mockContext *hookstate.Context
+}
+
+type getAspectSuite struct {
+	aspectSuiteBase
+}
+
+var _ = Suite(&getAspectSuite{})
+
+func (s *aspectSuiteBase) SetUpTest(c *C) {
+	s.BaseTest.SetUpTest(c)
+	dirs.SetRootDir(c.MkDir())
+	s.AddCleanup(func() { dirs.SetRootDir("/") })
+
+	s.st = state.New(nil)
+	s.mockHandler = hooktest.NewMockHandler()
+
+	s.st.Lock()
+	defer s.st.Unlock()
+
+	mockInstalledSnap(c, s.st, `name: test-snap
+plugs:
+  wifi-setup:
+    interface: aspects
+    account: system
+    bundle: network
+    aspect: wifi-setup
+  disconnected:
+    interface: aspects
+    account: system
+    bundle: network
+    aspect: wifi-setup
+  other:
+    interface: x11
+`, "")
+	s.st.Set("conns", map[string]interface{}{
+		"test-snap:wifi-setup core:aspects": map[string]interface{}{"interface": "aspects"},
+		"test-snap:other core:x11":          map[string]interface{}{"interface": "x11"},
+	})
+
+	task := s.st.NewTask("test-task", "my test task")
+	setup := &hookstate.HookSetup{Snap: "test-snap", Revision: snap.R(1), Hook: "test-hook"}
+
+	var err error
+	s.mockContext, err = hookstate.NewContext(task, s.st, setup, s.mockHandler, "")
+	c.Assert(err, IsNil)
+}
+
+func (s *getAspectSuite) TestGetAspectValues(c *C) {
+	restore := ctlcmd.MockAspectstateGetViaAspect(func(st *state.State, _ *aspects.Transaction, account, bundleName, aspect, field string, value interface{}) error {
+		c.Check(account, Equals, "system")
+		c.Check(bundleName, Equals, "network")
+		c.Check(aspect, Equals, "wifi-setup")
+
+		switch field {
+		case "ssid":
+			*value.(*interface{}) = "my-ssid"
+		case "ssids":
+			*value.(*interface{}) = []interface{}{"one", "two"}
+		default:
+			return &aspects.FieldNotFoundError{Message: "not found"}
+		}
+		return nil
+	})
+	defer restore()
+
+	stdout, stderr, err := ctlcmd.Run(s.mockContext, []string{"get", "--view", ":wifi-setup", "ssid"}, 0)
+	c.Assert(err, IsNil)
+	c.Check(string(stdout), Equals, "my-ssid\n")
+	c.Check(string(stderr), Equals, "")
+
+	stdout, _, err = ctlcmd.Run(s.mockContext, []string{"get", "--view", ":wifi-setup", "ssid", "ssids", "password"}, 0)
+	c.Assert(err, IsNil)
+	c.Check(string(stdout), Equals, "{\n\t\"ssid\": \"my-ssid\",\n\t\"ssids\": [\n\t\t\"one\",\n\t\t\"two\"\n\t]\n}\n")
+
+	stdout, _, err = ctlcmd.Run(s.mockContext, []string{"get", "--view", "-t", ":wifi-setup", "password"}, 0)
+	c.Assert(err, IsNil)
+	c.Check(string(stdout), Equals, "null\n")
+}
+
+func (s *getAspectSuite) TestGetAspectAccessError(c *C) {
+	restore := ctlcmd.MockAspectstateGetViaAspect(func(_ *state.State, _ *aspects.Transaction, _, _, _, field string, _ interface{}) error {
+		// the field is write-only and a read was requested
+		return &aspects.InvalidAccessError{RequestedAccess: 1, FieldAccess: 2, Field: field}
+	})
+	defer restore()
+
+	_, _, err := ctlcmd.Run(s.mockContext, []string{"get", "--view", ":wifi-setup", "password"}, 0)
+	c.Assert(err, ErrorMatches, `cannot get field "password": path is not readable`)
+}
+
+func (s *getAspectSuite) TestGetAspectErrors(c *C) {
+	restore := ctlcmd.MockAspectstateGetViaAspect(func(*state.State, *aspects.Transaction, string, string, string, string, interface{}) error {
+		c.Fatalf("unexpected call to aspectstate.GetViaAspect")
+		return nil
+	})
+	defer restore()
+
+	for _, t := range []struct {
+		args []string
+		err  string
+	}{
+		{args: []string{"get", "--view", "wifi-setup", "ssid"}, err: `cannot use --view without a :<plug> argument`},
+		{args: []string{"get", "--view", ":", "ssid"}, err: `plug name not provided`},
+		{args: []string{"get", "--view", ":wifi-setup"}, err: `get which aspect field\?`},
+		{args: []string{"get", "--view", "--plug", ":wifi-setup", "ssid"}, err: `cannot use --plug or --slot with --view`},
+		{args: []string{"get", "--view", ":missing", "ssid"}, err: `cannot find plug :missing for snap "test-snap"`},
+		{args: []string{"get", "--view", ":other", "ssid"}, err: `cannot use --view with non-aspects plug :other`},
+		{args: []string{"get", "--view", ":disconnected", "ssid"}, err: `cannot access aspect: plug :disconnected is not connected`},
+	} {
+		_, _, err := ctlcmd.Run(s.mockContext, t.args, 0)
+		c.Check(err, ErrorMatches, t.err, Commentf("%v", t.args))
+	}
+}
+
+func (s *getAspectSuite) TestGetAspectPendingValuesInChange(c *C) {
+	restore := ctlcmd.MockAspectstateGetViaAspect(func(_ *state.State, tx *aspects.Transaction, _, _, _, field string, value interface{}) error {
+		return tx.Get("wifi."+field, value)
+	})
+	defer restore()
+
+	s.st.Lock()
+	chg := s.st.NewChange("set-aspect", "")
+	chg.Set("aspect-transaction", map[string]interface{}{
+		"account":     "system",
+		"bundle-name": "network",
+		"transaction": map[string]interface{}{
+			"pristine": map[string]interface{}{"wifi": map[string]interface{}{"ssid": "old"}},
+			"deltas":   []interface{}{map[string]interface{}{"path": "wifi.ssid", "value": "new"}},
+		},
+	})
+	task := s.st.NewTask("run-hook", "")
+	chg.AddTask(task)
+	setup := &hookstate.HookSetup{Snap: "test-snap", Revision: snap.R(1), Hook: "save-view-wifi-setup"}
+	mockContext, err := hookstate.NewContext(task, s.st, setup, s.mockHandler, "")
+	c.Assert(err, IsNil)
+	s.st.Unlock()
+
+	stdout, _, err := ctlcmd.Run(mockContext, []string{"get", "--view", ":wifi-setup", "ssid"}, 0)
+	c.Assert(err, IsNil)
+	c.Check(string(stdout), Equals, "new\n")
+
+	// outside of the change, the committed values are read
+	stdout, _, err = ctlcmd.Run(s.mockContext, []string{"get", "--view", ":wifi-setup", "ssid"}, 0)
+	c.Assert(err, IsNil)
+	c.Check(string(stdout), Equals, "\n")
+}
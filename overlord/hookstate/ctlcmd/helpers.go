@@ -27,9 +27,11 @@ import (
 	"time"
 
 	"github.com/snapcore/snapd/i18n"
+	"github.com/snapcore/snapd/interfaces"
 	"github.com/snapcore/snapd/jsonutil"
 	"github.com/snapcore/snapd/overlord/configstate"
 	"github.com/snapcore/snapd/overlord/hookstate"
+	"github.com/snapcore/snapd/overlord/ifacestate"
 	"github.com/snapcore/snapd/overlord/servicestate"
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
@@ -232,3 +234,70 @@ func getAttribute(snapName string, subkeys []string, pos int, attrs map[string]i
 	}
 	return getAttribute(snapName, subkeys, pos+1, attrsm, result)
 }
+
+// aspectPlugRef holds the aspect referenced by an "aspects" plug.
+type aspectPlugRef struct {
+	Account string
+	Bundle  string
+	Aspect  string
+}
+
+// getAspectPlugRef returns the aspect referenced by the given "aspects" plug
+// of the snap, checking that the plug is connected. It requires the state to
+// be locked.
+func getAspectPlugRef(st *state.State, snapName, plugName string) (*aspectPlugRef, error) {
+	info, err := snapstate.CurrentInfo(st, snapName)
+	if err != nil {
+		return nil, fmt.Errorf("internal error: cannot get snap info: %s", err)
+	}
+
+	plug := info.Plugs[plugName]
+	if plug == nil {
+		return nil, fmt.Errorf(i18n.G("cannot find plug :%s for snap %q"), plugName, snapName)
+	}
+	if plug.Interface != "aspects" {
+		return nil, fmt.Errorf(i18n.G("cannot use --view with non-aspects plug :%s"), plugName)
+	}
+
+	conns, err := ifacestate.ConnectionStates(st)
+	if err != nil {
+		return nil, fmt.Errorf("internal error: cannot get connections: %s", err)
+	}
+
+	var connected bool
+	for refStr, connState := range conns {
+		if !connState.Active() {
+			continue
+		}
+		connRef, err := interfaces.ParseConnRef(refStr)
+		if err != nil {
+			return nil, fmt.Errorf("internal error: %s", err)
+		}
+		if connRef.PlugRef.Snap == snapName && connRef.PlugRef.Name == plugName {
+			connected = true
+			break
+		}
+	}
+	if !connected {
+		return nil, fmt.Errorf(i18n.G("cannot access aspect: plug :%s is not connected"), plugName)
+	}
+
+	// the attributes are validated when the plug is prepared
+	account, _ := plug.Attrs["account"].(string)
+	bundle, _ := plug.Attrs["bundle"].(string)
+	aspect, _ := plug.Attrs["aspect"].(string)
+
+	return &aspectPlugRef{Account: account, Bundle: bundle, Aspect: aspect}, nil
+}
+
+// aspectPlugName extracts the plug name from a ":<plug>" argument.
+func aspectPlugName(plugSpec string) (string, error) {
+	if !strings.HasPrefix(plugSpec, ":") {
+		return "", fmt.Errorf(i18n.G("cannot use --view without a :<plug> argument"))
+	}
+	name := strings.TrimPrefix(plugSpec, ":")
+	if name == "" {
+		return "", fmt.Errorf("plug name not provided")
+	}
+	return name, nil
+}
@@ -0,0 +1,103 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ctlcmd
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/snapcore/snapd/i18n"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+type notifyCommand struct {
+	baseCommand
+
+	RepeatAfter string `long:"repeat-after" value-name:"<duration>" description:"Only repeat the notice if this much time passed since it was last repeated"`
+	Positional  struct {
+		Key  string   `positional-arg-name:"<key>" required:"yes"`
+		Data []string `positional-arg-name:"<name>=<value>"`
+	} `positional-args:"yes"`
+}
+
+var shortNotifyHelp = i18n.G("Record a custom notice")
+var longNotifyHelp = i18n.G(`
+The notify command records an occurrence of a custom notice of the snap with
+the given key, optionally along with data given as name=value pairs.
+
+$ snapctl notify backup-done path=/var/snap/foo/common/backup.tar
+
+Notices are recorded with the "snap-notify" type and a key prefixed with the
+snap instance name, and can be queried with "snap notices".
+`)
+
+func init() {
+	addCommand("notify", shortNotifyHelp, longNotifyHelp, func() command { return &notifyCommand{} })
+}
+
+var validNotifyKey = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._/-]*$`)
+
+func (c *notifyCommand) Execute(args []string) error {
+	context, err := c.ensureContext()
+	if err != nil {
+		return err
+	}
+
+	key := c.Positional.Key
+	if !validNotifyKey.MatchString(key) {
+		return fmt.Errorf(i18n.G("invalid notice key %q"), key)
+	}
+
+	var data map[string]string
+	for _, kv := range c.Positional.Data {
+		name, value, ok := strings.Cut(kv, "=")
+		if !ok || name == "" {
+			return fmt.Errorf(i18n.G("invalid notice data %q: must be of the form <name>=<value>"), kv)
+		}
+		if data == nil {
+			data = make(map[string]string)
+		}
+		data[name] = value
+	}
+
+	var repeatAfter time.Duration
+	if c.RepeatAfter != "" {
+		repeatAfter, err = time.ParseDuration(c.RepeatAfter)
+		if err != nil || repeatAfter < 0 {
+			return fmt.Errorf(i18n.G("invalid repeat-after duration %q"), c.RepeatAfter)
+		}
+	}
+
+	st := context.State()
+	st.Lock()
+	defer st.Unlock()
+
+	opts := &state.AddNoticeOptions{
+		Data:        data,
+		RepeatAfter: repeatAfter,
+	}
+	noticeKey := context.InstanceName() + "/" + key
+	if _, err := st.AddNotice(nil, state.SnapNotifyNotice, noticeKey, opts); err != nil {
+		return fmt.Errorf("cannot record notice: %v", err)
+	}
+	return nil
+}
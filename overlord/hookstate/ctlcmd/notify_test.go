@@ -0,0 +1,111 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ctlcmd_test
+
+import (
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/overlord/hookstate"
+	"github.com/snapcore/snapd/overlord/hookstate/ctlcmd"
+	"github.com/snapcore/snapd/overlord/hookstate/hooktest"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type notifySuite struct {
+	testutil.BaseTest
+	state       *state.State
+	mockContext *hookstate.Context
+}
+
+var _ = check.Suite(&notifySuite{})
+
+func (s *notifySuite) SetUpTest(c *check.C) {
+	s.BaseTest.SetUpTest(c)
+	dirs.SetRootDir(c.MkDir())
+	s.AddCleanup(func() { dirs.SetRootDir("/") })
+
+	s.state = state.New(nil)
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	// ephemeral context
+	setup := &hookstate.HookSetup{Snap: "test-snap", Revision: snap.R(42)}
+	ctx, err := hookstate.NewContext(nil, s.state, setup, hooktest.NewMockHandler(), "")
+	c.Assert(err, check.IsNil)
+	s.mockContext = ctx
+}
+
+func (s *notifySuite) notices() []*state.Notice {
+	s.state.Lock()
+	defer s.state.Unlock()
+	return s.state.Notices(&state.NoticeFilter{Types: []state.NoticeType{state.SnapNotifyNotice}})
+}
+
+func (s *notifySuite) TestNotify(c *check.C) {
+	stdout, stderr, err := ctlcmd.Run(s.mockContext, []string{"notify", "backup/done", "path=/foo", "empty="}, 0)
+	c.Assert(err, check.IsNil)
+	c.Check(string(stdout), check.Equals, "")
+	c.Check(string(stderr), check.Equals, "")
+
+	notices := s.notices()
+	c.Assert(notices, check.HasLen, 1)
+	c.Check(notices[0].Key(), check.Equals, "test-snap/backup/done")
+	c.Check(notices[0].LastData(), check.DeepEquals, map[string]string{"path": "/foo", "empty": ""})
+	_, hasUser := notices[0].UserID()
+	c.Check(hasUser, check.Equals, false)
+
+	_, _, err = ctlcmd.Run(s.mockContext, []string{"notify", "--repeat-after=1h", "backup/done"}, 0)
+	c.Assert(err, check.IsNil)
+	notices = s.notices()
+	c.Assert(notices, check.HasLen, 1)
+	c.Check(notices[0].Occurrences(), check.Equals, 2)
+	c.Check(notices[0].LastData(), check.HasLen, 0)
+}
+
+func (s *notifySuite) TestNotifyErrors(c *check.C) {
+	for _, t := range []struct {
+		args []string
+		err  string
+	}{
+		{[]string{"notify"}, "the required argument `<key>` was not provided"},
+		{[]string{"notify", "-bad"}, `unknown flag .*`},
+		{[]string{"notify", "bad key"}, `invalid notice key "bad key"`},
+		{[]string{"notify", "key", "novalue"}, `invalid notice data "novalue": must be of the form <name>=<value>`},
+		{[]string{"notify", "key", "=value"}, `invalid notice data "=value": must be of the form <name>=<value>`},
+		{[]string{"notify", "--repeat-after=soon", "key"}, `invalid repeat-after duration "soon"`},
+	} {
+		_, _, err := ctlcmd.Run(s.mockContext, t.args, 0)
+		c.Check(err, check.ErrorMatches, t.err, check.Commentf("%v", t.args))
+	}
+	c.Check(s.notices(), check.HasLen, 0)
+}
+
+func (s *notifySuite) TestNotifyNonRoot(c *check.C) {
+	_, _, err := ctlcmd.Run(s.mockContext, []string{"notify", "key"}, 1000)
+	c.Check(err, check.ErrorMatches, `cannot use "notify" with uid 1000, try with sudo`)
+}
+
+func (s *notifySuite) TestNoContextError(c *check.C) {
+	_, _, err := ctlcmd.Run(nil, []string{"notify", "key"}, 0)
+	c.Check(err, check.ErrorMatches, `cannot invoke snapctl operation commands \(here "notify"\) from outside of a snap`)
+}
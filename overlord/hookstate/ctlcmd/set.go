@@ -26,6 +26,7 @@ import (
 
 	"github.com/snapcore/snapd/i18n"
 	"github.com/snapcore/snapd/jsonutil"
+	"github.com/snapcore/snapd/overlord/aspectstate"
 	"github.com/snapcore/snapd/overlord/configstate"
 	"github.com/snapcore/snapd/overlord/configstate/config"
 	"github.com/snapcore/snapd/overlord/hookstate"
@@ -41,8 +42,14 @@ type setCommand struct {
 
 	String bool `short:"s" description:"parse the value as a string"`
 	Typed  bool `short:"t" description:"parse the value strictly as JSON document"`
+	View   bool `long:"view" description:"set fields of the aspect referenced by the plug"`
 }
 
+var (
+	aspectstateSet          = aspectstate.Set
+	aspectstateSetViaAspect = aspectstate.SetViaAspect
+)
+
 var shortSetHelp = i18n.G("Set either configuration options or interface connection settings")
 var longSetHelp = i18n.G(`
 The set command sets the provided configuration options as requested.
@@ -63,6 +70,11 @@ Plug and slot attributes may be set in the respective prepare and connect hooks
 by naming the respective plug or slot:
 
     $ snapctl set :myplug path=/dev/ttyS0
+
+Fields of the aspect referenced by a connected "aspects" plug may be set with
+the --view option:
+
+    $ snapctl set --view :wifi-setup ssid=my-network
 `)
 
 func init() {
@@ -83,6 +95,18 @@ func (s *setCommand) Execute(args []string) error {
 		return fmt.Errorf("cannot use -t and -s together")
 	}
 
+	if s.View {
+		plugName, err := aspectPlugName(s.Positional.PlugOrSlotSpec)
+		if err != nil {
+			return err
+		}
+		if len(s.Positional.ConfValues) == 0 {
+			return fmt.Errorf(i18n.G("set which aspect field?"))
+		}
+
+		return s.setAspectValues(context, plugName)
+	}
+
 	// treat PlugOrSlotSpec argument as key=value if it contains '=' or doesn't contain ':' - this is to support
 	// values such as "device-service.url=192.168.0.1:5555" and error out on invalid key=value if only "key" is given.
 	if strings.Contains(s.Positional.PlugOrSlotSpec, "=") || !strings.Contains(s.Positional.PlugOrSlotSpec, ":") {
@@ -108,37 +132,96 @@ func (s *setCommand) setConfigSetting(context *hookstate.Context) error {
 	context.Unlock()
 
 	for _, patchValue := range s.Positional.ConfValues {
-		parts := strings.SplitN(patchValue, "=", 2)
-		if len(parts) == 1 && strings.HasSuffix(patchValue, "!") {
-			key := strings.TrimSuffix(patchValue, "!")
-			tr.Set(s.context().InstanceName(), key, nil)
-			continue
+		key, value, err := s.parseConfigValue(patchValue)
+		if err != nil {
+			return err
 		}
-		if len(parts) != 2 {
-			return fmt.Errorf(i18n.G("invalid parameter: %q (want key=value)"), patchValue)
+
+		tr.Set(s.context().InstanceName(), key, value)
+	}
+
+	return nil
+}
+
+func (s *setCommand) setAspectValues(context *hookstate.Context, plugName string) error {
+	fields := make([]string, 0, len(s.Positional.ConfValues))
+	values := make(map[string]interface{}, len(s.Positional.ConfValues))
+	for _, patchValue := range s.Positional.ConfValues {
+		field, value, err := s.parseConfigValue(patchValue)
+		if err != nil {
+			return err
+		}
+		if _, ok := values[field]; !ok {
+			fields = append(fields, field)
 		}
-		key := parts[0]
+		values[field] = value
+	}
 
-		var value interface{}
-		if s.String {
-			value = parts[1]
-		} else {
-			if err := jsonutil.DecodeWithNumber(strings.NewReader(parts[1]), &value); err != nil {
-				if s.Typed {
-					return fmt.Errorf("failed to parse JSON: %w", err)
-				}
-
-				// Not valid JSON-- just save the string as-is.
-				value = parts[1]
+	context.Lock()
+	defer context.Unlock()
+
+	st := context.State()
+	aspectRef, err := getAspectPlugRef(st, context.InstanceName(), plugName)
+	if err != nil {
+		return err
+	}
+
+	tx, ok, err := aspectstate.ContextTransaction(context, aspectRef.Account, aspectRef.Bundle)
+	if err != nil {
+		return err
+	}
+
+	if ok {
+		// the hook runs in a change that writes to the bundle, only
+		// the custodians' change-view hooks can modify the pending values
+		if !aspectstate.IsChangeViewHook(context.HookName()) {
+			return fmt.Errorf(i18n.G("cannot modify aspect %s/%s/%s from %q hook"), aspectRef.Account, aspectRef.Bundle, aspectRef.Aspect, context.HookName())
+		}
+
+		for _, field := range fields {
+			if err := aspectstateSetViaAspect(st, tx, aspectRef.Account, aspectRef.Bundle, aspectRef.Aspect, field, values[field]); err != nil {
+				return err
 			}
 		}
+		return nil
+	}
 
-		tr.Set(s.context().InstanceName(), key, value)
+	if _, err := aspectstateSet(st, aspectRef.Account, aspectRef.Bundle, aspectRef.Aspect, values); err != nil {
+		return err
 	}
+	st.EnsureBefore(0)
 
 	return nil
 }
 
+// parseConfigValue parses a "key=value" or "key!" parameter, returning the
+// key and its value, which is nil if the key is to be unset.
+func (s *setCommand) parseConfigValue(patchValue string) (key string, value interface{}, err error) {
+	parts := strings.SplitN(patchValue, "=", 2)
+	if len(parts) == 1 && strings.HasSuffix(patchValue, "!") {
+		return strings.TrimSuffix(patchValue, "!"), nil, nil
+	}
+	if len(parts) != 2 {
+		return "", nil, fmt.Errorf(i18n.G("invalid parameter: %q (want key=value)"), patchValue)
+	}
+	key = parts[0]
+
+	if s.String {
+		return key, parts[1], nil
+	}
+
+	if err := jsonutil.DecodeWithNumber(strings.NewReader(parts[1]), &value); err != nil {
+		if s.Typed {
+			return "", nil, fmt.Errorf("failed to parse JSON: %w", err)
+		}
+
+		// Not valid JSON-- just save the string as-is.
+		value = parts[1]
+	}
+
+	return key, value, nil
+}
+
 func setInterfaceAttribute(context *hookstate.Context, staticAttrs map[string]interface{}, dynamicAttrs map[string]interface{}, key string, value interface{}) error {
 	data, err := json.Marshal(value)
 	if err != nil {
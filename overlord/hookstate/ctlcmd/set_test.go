@@ -26,6 +26,7 @@ import (
 
 	. "gopkg.in/check.v1"
 
+	"github.com/snapcore/snapd/aspects"
 	"github.com/snapcore/snapd/interfaces"
 	"github.com/snapcore/snapd/overlord/configstate/config"
 	"github.com/snapcore/snapd/overlord/hookstate"
@@ -402,3 +403,143 @@ func (s *setAttrSuite) TestSetCommandFailsOutsideOfValidContext(c *C) {
 	c.Check(string(stdout), Equals, "")
 	c.Check(string(stderr), Equals, "")
 }
+
+type setAspectSuite struct {
+	aspectSuiteBase
+}
+
+var _ = Suite(&setAspectSuite{})
+
+func (s *setAspectSuite) TestSetAspectValues(c *C) {
+	var calls int
+	restore := ctlcmd.MockAspectstateSet(func(st *state.State, account, bundleName, aspect string, values map[string]interface{}) (*state.Change, error) {
+		calls++
+		c.Check(account, Equals, "system")
+		c.Check(bundleName, Equals, "network")
+		c.Check(aspect, Equals, "wifi-setup")
+		c.Check(values, DeepEquals, map[string]interface{}{
+			"ssid":     "my-ssid",
+			"password": nil,
+			"ssids":    []interface{}{"one", "two"},
+		})
+		return st.NewChange("set-aspect", ""), nil
+	})
+	defer restore()
+
+	stdout, stderr, err := ctlcmd.Run(s.mockContext, []string{"set", "--view", ":wifi-setup", "ssid=my-ssid", "password!", `ssids=["one", "two"]`}, 0)
+	c.Assert(err, IsNil)
+	c.Check(string(stdout), Equals, "")
+	c.Check(string(stderr), Equals, "")
+	c.Check(calls, Equals, 1)
+}
+
+func (s *setAspectSuite) TestSetAspectValuesString(c *C) {
+	restore := ctlcmd.MockAspectstateSet(func(st *state.State, _, _, _ string, values map[string]interface{}) (*state.Change, error) {
+		c.Check(values, DeepEquals, map[string]interface{}{"ssid": "1"})
+		return st.NewChange("set-aspect", ""), nil
+	})
+	defer restore()
+
+	_, _, err := ctlcmd.Run(s.mockContext, []string{"set", "--view", "-s", ":wifi-setup", "ssid=1"}, 0)
+	c.Assert(err, IsNil)
+}
+
+func (s *setAspectSuite) TestSetAspectErrors(c *C) {
+	restore := ctlcmd.MockAspectstateSet(func(*state.State, string, string, string, map[string]interface{}) (*state.Change, error) {
+		c.Fatalf("unexpected call to aspectstate.Set")
+		return nil, nil
+	})
+	defer restore()
+
+	for _, t := range []struct {
+		args []string
+		err  string
+	}{
+		{args: []string{"set", "--view", "wifi-setup", "ssid=foo"}, err: `cannot use --view without a :<plug> argument`},
+		{args: []string{"set", "--view", ":wifi-setup"}, err: `set which aspect field\?`},
+		{args: []string{"set", "--view", ":wifi-setup", "ssid"}, err: `invalid parameter: "ssid" \(want key=value\)`},
+		{args: []string{"set", "--view", "-t", ":wifi-setup", "ssid=foo"}, err: `failed to parse JSON: .*`},
+		{args: []string{"set", "--view", ":other", "ssid=foo"}, err: `cannot use --view with non-aspects plug :other`},
+		{args: []string{"set", "--view", ":disconnected", "ssid=foo"}, err: `cannot access aspect: plug :disconnected is not connected`},
+	} {
+		_, _, err := ctlcmd.Run(s.mockContext, t.args, 0)
+		c.Check(err, ErrorMatches, t.err, Commentf("%v", t.args))
+	}
+}
+
+func (s *setAspectSuite) TestSetAspectAccessError(c *C) {
+	restore := ctlcmd.MockAspectstateSet(func(*state.State, string, string, string, map[string]interface{}) (*state.Change, error) {
+		// the field is read-only and a write was requested
+		return nil, &aspects.InvalidAccessError{RequestedAccess: 2, FieldAccess: 1, Field: "status"}
+	})
+	defer restore()
+
+	_, _, err := ctlcmd.Run(s.mockContext, []string{"set", "--view", ":wifi-setup", "status=foo"}, 0)
+	c.Assert(err, ErrorMatches, `cannot set field "status": path is not writeable`)
+}
+
+func (s *setAspectSuite) mockAspectChangeContext(c *C, hookName string) (*hookstate.Context, *state.Change) {
+	s.st.Lock()
+	defer s.st.Unlock()
+
+	chg := s.st.NewChange("set-aspect", "")
+	chg.Set("aspect-transaction", map[string]interface{}{
+		"account":     "system",
+		"bundle-name": "network",
+		"transaction": map[string]interface{}{"pristine": map[string]interface{}{}},
+	})
+	task := s.st.NewTask("run-hook", "")
+	chg.AddTask(task)
+
+	setup := &hookstate.HookSetup{Snap: "test-snap", Revision: snap.R(1), Hook: hookName}
+	mockContext, err := hookstate.NewContext(task, s.st, setup, s.mockHandler, "")
+	c.Assert(err, IsNil)
+	return mockContext, chg
+}
+
+func (s *setAspectSuite) TestSetAspectFromChangeViewHook(c *C) {
+	restore := ctlcmd.MockAspectstateSet(func(*state.State, string, string, string, map[string]interface{}) (*state.Change, error) {
+		c.Fatalf("unexpected call to aspectstate.Set")
+		return nil, nil
+	})
+	defer restore()
+
+	restore = ctlcmd.MockAspectstateSetViaAspect(func(_ *state.State, tx *aspects.Transaction, account, bundleName, aspect, field string, value interface{}) error {
+		c.Check(account, Equals, "system")
+		c.Check(bundleName, Equals, "network")
+		c.Check(aspect, Equals, "wifi-setup")
+		return tx.Set("wifi."+field, value)
+	})
+	defer restore()
+
+	mockContext, chg := s.mockAspectChangeContext(c, "change-view-wifi-setup")
+
+	_, _, err := ctlcmd.Run(mockContext, []string{"set", "--view", ":wifi-setup", "ssid=my-ssid"}, 0)
+	c.Assert(err, IsNil)
+
+	mockContext.Lock()
+	defer mockContext.Unlock()
+	c.Assert(mockContext.Done(), IsNil)
+
+	var txState struct {
+		Tx *aspects.Transaction `json:"transaction"`
+	}
+	c.Assert(chg.Get("aspect-transaction", &txState), IsNil)
+
+	var ssid string
+	c.Assert(txState.Tx.Get("wifi.ssid", &ssid), IsNil)
+	c.Check(ssid, Equals, "my-ssid")
+}
+
+func (s *setAspectSuite) TestSetAspectFromOtherHookInChange(c *C) {
+	restore := ctlcmd.MockAspectstateSetViaAspect(func(*state.State, *aspects.Transaction, string, string, string, string, interface{}) error {
+		c.Fatalf("unexpected call to aspectstate.SetViaAspect")
+		return nil
+	})
+	defer restore()
+
+	mockContext, _ := s.mockAspectChangeContext(c, "save-view-wifi-setup")
+
+	_, _, err := ctlcmd.Run(mockContext, []string{"set", "--view", ":wifi-setup", "ssid=my-ssid"}, 0)
+	c.Assert(err, ErrorMatches, `cannot modify aspect system/network/wifi-setup from "save-view-wifi-setup" hook`)
+}
@@ -64,6 +64,17 @@ func SetupPostRefreshHook(st *state.State, snapName string) *state.Task {
 	return HookTask(st, summary, hooksup, nil)
 }
 
+func SetupPostRestoreHook(st *state.State, snapName string) *state.Task {
+	hooksup := &HookSetup{
+		Snap:     snapName,
+		Hook:     "post-restore",
+		Optional: true,
+	}
+
+	summary := fmt.Sprintf(i18n.G("Run post-restore hook of %q snap if present"), hooksup.Snap)
+	return HookTask(st, summary, hooksup, nil)
+}
+
 func SetupPreRefreshHook(st *state.State, snapName string) *state.Task {
 	hooksup := &HookSetup{
 		Snap:     snapName,
@@ -316,6 +327,7 @@ func setupHooks(hookMgr *HookManager) {
 
 	hookMgr.Register(regexp.MustCompile("^install$"), handlerGenerator)
 	hookMgr.Register(regexp.MustCompile("^post-refresh$"), handlerGenerator)
+	hookMgr.Register(regexp.MustCompile("^post-restore$"), handlerGenerator)
 	hookMgr.Register(regexp.MustCompile("^pre-refresh$"), handlerGenerator)
 	hookMgr.Register(regexp.MustCompile("^remove$"), handlerGenerator)
 	hookMgr.Register(regexp.MustCompile("^gate-auto-refresh$"), gateAutoRefreshHandlerGenerator)
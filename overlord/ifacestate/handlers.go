@@ -1585,6 +1585,19 @@ func (m *InterfaceManager) doHotplugConnect(task *state.Task, _ *tomb.Tomb) erro
 		return fmt.Errorf("cannot find hotplug slot for interface %s and hotplug key %q", ifaceName, hotplugKey)
 	}
 
+	// hotplug rules defined by the administrator for the device; tasks
+	// created by older snapd do not carry the device information
+	var rules []*HotplugRule
+	var devinfo hotplug.HotplugDeviceInfo
+	if err := task.Get("device-info", &devinfo); err == nil {
+		rules, err = hotplugRulesForDevice(st, ifaceName, &devinfo)
+		if err != nil {
+			return err
+		}
+	} else if !errors.Is(err, state.ErrNoState) {
+		return fmt.Errorf("internal error: cannot get hotplug device info from task attributes: %s", err)
+	}
+
 	// find old connections for slots of this device - note we can't ask the repository since we need
 	// to recreate old connections that are only remembered in the state.
 	connsForDevice := findConnsForHotplugKey(conns, ifaceName, hotplugKey)
@@ -1613,6 +1626,10 @@ func (m *InterfaceManager) doHotplugConnect(task *state.Task, _ *tomb.Tomb) erro
 		if err != nil {
 			return err
 		}
+		if conn.Auto && refusedByHotplugRules(rules, &connRef.PlugRef) {
+			task.Logf("hotplug rule refuses to auto-connect %s", connRef.ID())
+			continue
+		}
 
 		if err := checkAutoconnectConflicts(st, task, connRef.PlugRef.Snap, connRef.SlotRef.Snap); err != nil {
 			retry, _ := err.(*state.Retry)
@@ -1638,8 +1655,38 @@ func (m *InterfaceManager) doHotplugConnect(task *state.Task, _ *tomb.Tomb) erro
 	if err := autochecker.addAutoConnections(newconns, candidates, filterForSlot(slot), conns, cannotAutoConnectLog, conflictError); err != nil {
 		return err
 	}
+	for key, conn := range newconns {
+		if refusedByHotplugRules(rules, &conn.PlugRef) {
+			task.Logf("hotplug rule refuses to auto-connect %s", conn.ID())
+			delete(newconns, key)
+		}
+	}
 
-	if len(recreate) == 0 && len(newconns) == 0 {
+	// find connections requested by hotplug rules, these are subject to
+	// the same policy checks as manual connections
+	ruleconns := make(map[string]*interfaces.ConnRef)
+	for _, rule := range rules {
+		if rule.Action != HotplugRuleConnect {
+			continue
+		}
+		for _, plug := range m.repo.Plugs(rule.Snap) {
+			if plug.Interface != ifaceName || (rule.Plug != "" && rule.Plug != plug.Name) {
+				continue
+			}
+			plugRef := &interfaces.PlugRef{Snap: rule.Snap, Name: plug.Name}
+			if refusedByHotplugRules(rules, plugRef) {
+				continue
+			}
+			if _, ok := newconns[interfaces.NewConnRef(plug, slot).ID()]; ok {
+				continue
+			}
+			if err := addNewConnection(st, task, ruleconns, conns, plug, slot, conflictError); err != nil {
+				return err
+			}
+		}
+	}
+
+	if len(recreate) == 0 && len(newconns) == 0 && len(ruleconns) == 0 {
 		return nil
 	}
 
@@ -1661,6 +1708,14 @@ func (m *InterfaceManager) doHotplugConnect(task *state.Task, _ *tomb.Tomb) erro
 		}
 		connectTs.AddAll(ts)
 	}
+	// Create connect tasks and interface hooks for connections requested by hotplug rules
+	for _, conn := range ruleconns {
+		ts, err := connect(st, conn.PlugRef.Snap, conn.PlugRef.Name, conn.SlotRef.Snap, conn.SlotRef.Name, connectOpts{})
+		if err != nil {
+			return fmt.Errorf("internal error: connect of %q failed: %s", conn, err)
+		}
+		connectTs.AddAll(ts)
+	}
 
 	if len(connectTs.Tasks()) > 0 {
 		snapstate.InjectTasks(task, connectTs)
@@ -1865,7 +1920,7 @@ func (m *InterfaceManager) doHotplugAddSlot(task *state.Task, _ *tomb.Tomb) erro
 				Attrs:      proposedSlot.Attrs,
 				HotplugKey: hotplugKey,
 			}
-			return addHotplugSlot(st, m.repo, stateSlots, iface, newSlot)
+			return addHotplugSlot(st, m.repo, stateSlots, iface, newSlot, hotplugDeviceIdentity(&devinfo))
 		}
 
 		// else - not gone, restored already by reloadConnections, but may need updating.
@@ -1888,7 +1943,7 @@ func (m *InterfaceManager) doHotplugAddSlot(task *state.Task, _ *tomb.Tomb) erro
 		Attrs:      proposedSlot.Attrs,
 		HotplugKey: hotplugKey,
 	}
-	return addHotplugSlot(st, m.repo, stateSlots, iface, newSlot)
+	return addHotplugSlot(st, m.repo, stateSlots, iface, newSlot, hotplugDeviceIdentity(&devinfo))
 }
 
 // doHotplugSeqWait returns Retry error if there is another change for same hotplug key and a lower sequence number.
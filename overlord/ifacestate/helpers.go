@@ -501,7 +501,7 @@ func (m *InterfaceManager) removeSnapSecurity(task *state.Task, instanceName str
 	return nil
 }
 
-func addHotplugSlot(st *state.State, repo *interfaces.Repository, stateSlots map[string]*HotplugSlotInfo, iface interfaces.Interface, slot *snap.SlotInfo) error {
+func addHotplugSlot(st *state.State, repo *interfaces.Repository, stateSlots map[string]*HotplugSlotInfo, iface interfaces.Interface, slot *snap.SlotInfo, device map[string]string) error {
 	if slot.HotplugKey == "" {
 		return fmt.Errorf("internal error: cannot store slot %q, not a hotplug slot", slot.Name)
 	}
@@ -520,6 +520,7 @@ func addHotplugSlot(st *state.State, repo *interfaces.Repository, stateSlots map
 		Interface:   slot.Interface,
 		StaticAttrs: slot.Attrs,
 		HotplugKey:  slot.HotplugKey,
+		Device:      device,
 		HotplugGone: false,
 	}
 	setHotplugSlots(st, stateSlots)
@@ -1310,6 +1311,8 @@ type HotplugSlotInfo struct {
 	Interface   string                 `json:"interface"`
 	StaticAttrs map[string]interface{} `json:"static-attrs,omitempty"`
 	HotplugKey  snap.HotplugKey        `json:"hotplug-key"`
+	// udev properties identifying the device, used to derive hotplug rules
+	Device map[string]string `json:"device,omitempty"`
 
 	// device was unplugged but has connections, so slot is remembered
 	HotplugGone bool `json:"hotplug-gone"`
@@ -678,11 +678,11 @@ func (s *helpersSuite) TestAddHotplugSlot(c *C) {
 		Attrs:      map[string]interface{}{"foo": "bar"},
 		HotplugKey: "key",
 	}
-	c.Assert(ifacestate.AddHotplugSlot(s.st, repo, stateSlots, iface, slot), IsNil)
+	c.Assert(ifacestate.AddHotplugSlot(s.st, repo, stateSlots, iface, slot, nil), IsNil)
 	c.Assert(beforePrepareSlotCalled, Equals, 1)
 
 	// same slot cannot be re-added to repo
-	c.Assert(ifacestate.AddHotplugSlot(s.st, repo, stateSlots, iface, slot), ErrorMatches, `cannot add hotplug slot "slot" for interface test: snap "core" has slots conflicting on name "slot"`)
+	c.Assert(ifacestate.AddHotplugSlot(s.st, repo, stateSlots, iface, slot, nil), ErrorMatches, `cannot add hotplug slot "slot" for interface test: snap "core" has slots conflicting on name "slot"`)
 
 	stateSlots, err = ifacestate.GetHotplugSlots(s.st)
 	c.Assert(err, IsNil)
@@ -722,11 +722,11 @@ func (s *helpersSuite) TestAddHotplugSlotValidationErrors(c *C) {
 		Interface: "test",
 	}
 	// hotplug key missing
-	c.Assert(ifacestate.AddHotplugSlot(s.st, repo, stateSlots, iface, slot), ErrorMatches, `internal error: cannot store slot "slot", not a hotplug slot`)
+	c.Assert(ifacestate.AddHotplugSlot(s.st, repo, stateSlots, iface, slot, nil), ErrorMatches, `internal error: cannot store slot "slot", not a hotplug slot`)
 	slot.HotplugKey = "key"
 
 	// sanitization failure
-	c.Assert(ifacestate.AddHotplugSlot(s.st, repo, stateSlots, iface, slot), ErrorMatches, `cannot sanitize hotplug slot \"slot\" for interface test: fail`)
+	c.Assert(ifacestate.AddHotplugSlot(s.st, repo, stateSlots, iface, slot, nil), ErrorMatches, `cannot sanitize hotplug slot \"slot\" for interface test: fail`)
 }
 
 func (s *helpersSuite) TestDiscardLateBackendViaSnapstate(c *C) {
@@ -228,6 +228,7 @@ InterfacesLoop:
 
 		hotplugConnect := st.NewTask("hotplug-connect", fmt.Sprintf("Recreate connections of interface %q for device %s with hotplug key %q", iface.Name(), devinfo.ShortString(), key.ShortString()))
 		setHotplugAttrs(hotplugConnect, iface.Name(), key)
+		hotplugConnect.Set("device-info", devinfo)
 		hotplugConnect.WaitFor(hotplugAdd)
 
 		chg := st.NewChange(fmt.Sprintf("hotplug-add-slot-%s", iface), fmt.Sprintf("Add hotplug slot of interface %q for device %s with hotplug key %q", devinfo.ShortString(), iface.Name(), key.ShortString()))
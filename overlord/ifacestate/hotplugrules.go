@@ -0,0 +1,232 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ifacestate
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/hotplug"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+)
+
+const (
+	// HotplugRuleConnect makes slots of matching devices connect to the
+	// plugs of the rule, even if the snap declarations do not grant
+	// auto-connection.
+	HotplugRuleConnect = "connect"
+	// HotplugRuleRefuse prevents slots of matching devices from being
+	// auto-connected to the plugs of the rule.
+	HotplugRuleRefuse = "refuse"
+)
+
+// hotplugRuleDeviceAttrs are the udev properties identifying a device, used
+// to derive a rule from the slot of a hotplugged device.
+var hotplugRuleDeviceAttrs = []string{"ID_VENDOR_ID", "ID_MODEL_ID", "ID_SERIAL_SHORT"}
+
+// HotplugRule is a device-local rule managed by the administrator, which
+// decides whether the slot created for a hotplugged device is connected to
+// the plugs of a snap.
+type HotplugRule struct {
+	ID int `json:"id"`
+	// Interface is the interface of the hotplug slots the rule applies to.
+	Interface string `json:"interface"`
+	// Match holds the udev properties of the device, such as ID_VENDOR_ID
+	// and ID_MODEL_ID, and the values they must all have.
+	Match map[string]string `json:"match"`
+	// Snap is the snap with the plugs the rule applies to. Plug restricts
+	// the rule to a single plug of the snap.
+	Snap string `json:"snap"`
+	Plug string `json:"plug,omitempty"`
+	// Action is either HotplugRuleConnect or HotplugRuleRefuse.
+	Action string `json:"action"`
+}
+
+// NoHotplugRuleError is returned when the hotplug rule with a given ID
+// does not exist.
+type NoHotplugRuleError struct {
+	ID int
+}
+
+func (e *NoHotplugRuleError) Error() string {
+	return fmt.Sprintf("cannot find hotplug rule %d", e.ID)
+}
+
+func (r *HotplugRule) validate() error {
+	if r.Action != HotplugRuleConnect && r.Action != HotplugRuleRefuse {
+		return fmt.Errorf("invalid hotplug rule action %q", r.Action)
+	}
+	if r.Interface == "" {
+		return fmt.Errorf("hotplug rule must specify an interface")
+	}
+	if len(r.Match) == 0 {
+		return fmt.Errorf("hotplug rule must match at least one device attribute")
+	}
+	for attr := range r.Match {
+		if attr == "" {
+			return fmt.Errorf("hotplug rule cannot match an unnamed device attribute")
+		}
+	}
+	if err := snap.ValidateInstanceName(r.Snap); err != nil {
+		return fmt.Errorf("invalid hotplug rule snap: %v", err)
+	}
+	if r.Plug != "" {
+		if err := snap.ValidatePlugName(r.Plug); err != nil {
+			return fmt.Errorf("invalid hotplug rule plug: %v", err)
+		}
+	}
+	return nil
+}
+
+// matchesDevice returns whether the rule applies to the slot of the given
+// interface created for the given device.
+func (r *HotplugRule) matchesDevice(ifaceName string, devinfo *hotplug.HotplugDeviceInfo) bool {
+	if r.Interface != ifaceName {
+		return false
+	}
+	for attr, expected := range r.Match {
+		if val, ok := devinfo.Attribute(attr); !ok || val != expected {
+			return false
+		}
+	}
+	return true
+}
+
+// matchesPlug returns whether the rule applies to the given plug.
+func (r *HotplugRule) matchesPlug(plugRef *interfaces.PlugRef) bool {
+	return r.Snap == plugRef.Snap && (r.Plug == "" || r.Plug == plugRef.Name)
+}
+
+// HotplugRules returns the hotplug rules defined on the device.
+func HotplugRules(st *state.State) ([]*HotplugRule, error) {
+	var rules []*HotplugRule
+	if err := st.Get("hotplug-rules", &rules); err != nil && !errors.Is(err, state.ErrNoState) {
+		return nil, fmt.Errorf("internal error: cannot obtain hotplug rules: %v", err)
+	}
+	return rules, nil
+}
+
+// AddHotplugRule validates and stores the given hotplug rule, allocating
+// its ID. The rule is considered whenever a device is hotplugged.
+func AddHotplugRule(st *state.State, rule *HotplugRule) error {
+	if err := rule.validate(); err != nil {
+		return err
+	}
+	rules, err := HotplugRules(st)
+	if err != nil {
+		return err
+	}
+	var lastID int
+	if err := st.Get("last-hotplug-rule-id", &lastID); err != nil && !errors.Is(err, state.ErrNoState) {
+		return fmt.Errorf("internal error: cannot allocate hotplug rule id: %v", err)
+	}
+	lastID++
+	rule.ID = lastID
+	st.Set("last-hotplug-rule-id", lastID)
+	st.Set("hotplug-rules", append(rules, rule))
+	return nil
+}
+
+// RemoveHotplugRule removes the hotplug rule with the given ID.
+func RemoveHotplugRule(st *state.State, id int) error {
+	rules, err := HotplugRules(st)
+	if err != nil {
+		return err
+	}
+	for i, rule := range rules {
+		if rule.ID == id {
+			st.Set("hotplug-rules", append(rules[:i], rules[i+1:]...))
+			return nil
+		}
+	}
+	return &NoHotplugRuleError{ID: id}
+}
+
+// HotplugRuleForConnection returns a rule connecting the plug of the given
+// connection to the slots created for the device behind its hotplug slot,
+// whenever the device is plugged in again. The rule is not stored.
+func HotplugRuleForConnection(st *state.State, connRef *interfaces.ConnRef) (*HotplugRule, error) {
+	stateSlots, err := getHotplugSlots(st)
+	if err != nil {
+		return nil, err
+	}
+	slot, ok := stateSlots[connRef.SlotRef.Name]
+	if !ok || connRef.SlotRef.Snap != SystemSnapName() {
+		return nil, fmt.Errorf("cannot persist a rule for slot %s: not a hotplug slot", &connRef.SlotRef)
+	}
+	if len(slot.Device) == 0 {
+		return nil, fmt.Errorf("cannot persist a rule for slot %s: device cannot be identified", &connRef.SlotRef)
+	}
+	match := make(map[string]string, len(slot.Device))
+	for attr, val := range slot.Device {
+		match[attr] = val
+	}
+	return &HotplugRule{
+		Interface: slot.Interface,
+		Match:     match,
+		Snap:      connRef.PlugRef.Snap,
+		Plug:      connRef.PlugRef.Name,
+		Action:    HotplugRuleConnect,
+	}, nil
+}
+
+// hotplugDeviceIdentity returns the udev properties identifying the given
+// device, or nil if the device does not have a vendor and product ID.
+func hotplugDeviceIdentity(devinfo *hotplug.HotplugDeviceInfo) map[string]string {
+	identity := make(map[string]string, len(hotplugRuleDeviceAttrs))
+	for _, attr := range hotplugRuleDeviceAttrs {
+		if val, ok := devinfo.Attribute(attr); ok && val != "" {
+			identity[attr] = val
+		}
+	}
+	if identity["ID_VENDOR_ID"] == "" || identity["ID_MODEL_ID"] == "" {
+		return nil
+	}
+	return identity
+}
+
+// hotplugRulesForDevice returns the hotplug rules applying to the slot of
+// the given interface created for the given device.
+func hotplugRulesForDevice(st *state.State, ifaceName string, devinfo *hotplug.HotplugDeviceInfo) ([]*HotplugRule, error) {
+	rules, err := HotplugRules(st)
+	if err != nil {
+		return nil, err
+	}
+	var matching []*HotplugRule
+	for _, rule := range rules {
+		if rule.matchesDevice(ifaceName, devinfo) {
+			matching = append(matching, rule)
+		}
+	}
+	return matching, nil
+}
+
+// refusedByHotplugRules returns whether auto-connecting the given plug is
+// refused by any of the given rules.
+func refusedByHotplugRules(rules []*HotplugRule, plugRef *interfaces.PlugRef) bool {
+	for _, rule := range rules {
+		if rule.Action == HotplugRuleRefuse && rule.matchesPlug(plugRef) {
+			return true
+		}
+	}
+	return false
+}
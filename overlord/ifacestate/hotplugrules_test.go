@@ -0,0 +1,151 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ifacestate_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/overlord/ifacestate"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+type hotplugRulesSuite struct {
+	st *state.State
+}
+
+var _ = Suite(&hotplugRulesSuite{})
+
+func (s *hotplugRulesSuite) SetUpTest(c *C) {
+	s.st = state.New(nil)
+}
+
+func (s *hotplugRulesSuite) TestAddRemoveHotplugRules(c *C) {
+	s.st.Lock()
+	defer s.st.Unlock()
+
+	rules, err := ifacestate.HotplugRules(s.st)
+	c.Assert(err, IsNil)
+	c.Check(rules, HasLen, 0)
+
+	rule1 := &ifacestate.HotplugRule{
+		Interface: "camera",
+		Match:     map[string]string{"ID_VENDOR_ID": "046d", "ID_MODEL_ID": "0825"},
+		Snap:      "consumer",
+		Action:    ifacestate.HotplugRuleConnect,
+	}
+	c.Assert(ifacestate.AddHotplugRule(s.st, rule1), IsNil)
+	c.Check(rule1.ID, Equals, 1)
+	rule2 := &ifacestate.HotplugRule{
+		Interface: "serial-port",
+		Match:     map[string]string{"ID_VENDOR_ID": "0403"},
+		Snap:      "consumer",
+		Plug:      "serial",
+		Action:    ifacestate.HotplugRuleRefuse,
+	}
+	c.Assert(ifacestate.AddHotplugRule(s.st, rule2), IsNil)
+	c.Check(rule2.ID, Equals, 2)
+
+	rules, err = ifacestate.HotplugRules(s.st)
+	c.Assert(err, IsNil)
+	c.Check(rules, DeepEquals, []*ifacestate.HotplugRule{rule1, rule2})
+
+	c.Assert(ifacestate.RemoveHotplugRule(s.st, 1), IsNil)
+	c.Check(ifacestate.RemoveHotplugRule(s.st, 1), ErrorMatches, "cannot find hotplug rule 1")
+	rules, err = ifacestate.HotplugRules(s.st)
+	c.Assert(err, IsNil)
+	c.Check(rules, DeepEquals, []*ifacestate.HotplugRule{rule2})
+
+	// IDs are not reused
+	rule3 := &ifacestate.HotplugRule{
+		Interface: "camera",
+		Match:     map[string]string{"ID_SERIAL_SHORT": "2A3F1B40"},
+		Snap:      "other",
+		Action:    ifacestate.HotplugRuleRefuse,
+	}
+	c.Assert(ifacestate.AddHotplugRule(s.st, rule3), IsNil)
+	c.Check(rule3.ID, Equals, 3)
+}
+
+func (s *hotplugRulesSuite) TestAddHotplugRuleInvalid(c *C) {
+	s.st.Lock()
+	defer s.st.Unlock()
+
+	for _, t := range []struct {
+		rule ifacestate.HotplugRule
+		err  string
+	}{
+		{ifacestate.HotplugRule{Interface: "camera", Match: map[string]string{"ID_VENDOR_ID": "046d"}, Snap: "consumer"}, `invalid hotplug rule action ""`},
+		{ifacestate.HotplugRule{Interface: "camera", Match: map[string]string{"ID_VENDOR_ID": "046d"}, Snap: "consumer", Action: "allow"}, `invalid hotplug rule action "allow"`},
+		{ifacestate.HotplugRule{Match: map[string]string{"ID_VENDOR_ID": "046d"}, Snap: "consumer", Action: "connect"}, `hotplug rule must specify an interface`},
+		{ifacestate.HotplugRule{Interface: "camera", Snap: "consumer", Action: "connect"}, `hotplug rule must match at least one device attribute`},
+		{ifacestate.HotplugRule{Interface: "camera", Match: map[string]string{"": "046d"}, Snap: "consumer", Action: "connect"}, `hotplug rule cannot match an unnamed device attribute`},
+		{ifacestate.HotplugRule{Interface: "camera", Match: map[string]string{"ID_VENDOR_ID": "046d"}, Action: "connect"}, `invalid hotplug rule snap: invalid snap name: ""`},
+		{ifacestate.HotplugRule{Interface: "camera", Match: map[string]string{"ID_VENDOR_ID": "046d"}, Snap: "consumer", Plug: "Bad_Plug", Action: "connect"}, `invalid hotplug rule plug: invalid plug name: "Bad_Plug"`},
+	} {
+		rule := t.rule
+		c.Check(ifacestate.AddHotplugRule(s.st, &rule), ErrorMatches, t.err)
+	}
+
+	rules, err := ifacestate.HotplugRules(s.st)
+	c.Assert(err, IsNil)
+	c.Check(rules, HasLen, 0)
+}
+
+func (s *hotplugRulesSuite) TestHotplugRuleForConnection(c *C) {
+	s.st.Lock()
+	defer s.st.Unlock()
+
+	s.st.Set("hotplug-slots", map[string]*ifacestate.HotplugSlotInfo{
+		"webcam": {
+			Name:       "webcam",
+			Interface:  "camera",
+			HotplugKey: "1234",
+			Device:     map[string]string{"ID_VENDOR_ID": "046d", "ID_MODEL_ID": "0825"},
+		},
+		"unknown": {
+			Name:       "unknown",
+			Interface:  "camera",
+			HotplugKey: "5678",
+		},
+	})
+
+	connRef := &interfaces.ConnRef{
+		PlugRef: interfaces.PlugRef{Snap: "consumer", Name: "camera"},
+		SlotRef: interfaces.SlotRef{Snap: "core", Name: "webcam"},
+	}
+	rule, err := ifacestate.HotplugRuleForConnection(s.st, connRef)
+	c.Assert(err, IsNil)
+	c.Check(rule, DeepEquals, &ifacestate.HotplugRule{
+		Interface: "camera",
+		Match:     map[string]string{"ID_VENDOR_ID": "046d", "ID_MODEL_ID": "0825"},
+		Snap:      "consumer",
+		Plug:      "camera",
+		Action:    ifacestate.HotplugRuleConnect,
+	})
+
+	connRef.SlotRef.Name = "unknown"
+	_, err = ifacestate.HotplugRuleForConnection(s.st, connRef)
+	c.Check(err, ErrorMatches, `cannot persist a rule for slot core:unknown: device cannot be identified`)
+
+	connRef.SlotRef.Name = "camera"
+	_, err = ifacestate.HotplugRuleForConnection(s.st, connRef)
+	c.Check(err, ErrorMatches, `cannot persist a rule for slot core:camera: not a hotplug slot`)
+}
@@ -7296,6 +7296,104 @@ func (s *interfaceManagerSuite) TestHotplugConnectAndAutoconnect(c *C) {
 		}})
 }
 
+func (s *interfaceManagerSuite) TestHotplugConnectRefusedByRule(c *C) {
+	s.MockModel(c, nil)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	chg := s.setupHotplugConnectTestData(c)
+	devinfo, err := hotplug.NewHotplugDeviceInfo(map[string]string{"DEVPATH": "/a", "ID_VENDOR_ID": "1234", "ID_MODEL_ID": "5678"})
+	c.Assert(err, IsNil)
+	chg.Tasks()[0].Set("device-info", devinfo)
+
+	// the device was auto-connected before
+	s.state.Set("conns", map[string]interface{}{
+		"consumer:plug core:hotplugslot": map[string]interface{}{
+			"interface":    "test",
+			"hotplug-key":  "1234",
+			"auto":         true,
+			"hotplug-gone": true,
+		}})
+	c.Assert(ifacestate.AddHotplugRule(s.state, &ifacestate.HotplugRule{
+		Interface: "test",
+		Match:     map[string]string{"ID_VENDOR_ID": "1234"},
+		Snap:      "consumer",
+		Action:    ifacestate.HotplugRuleRefuse,
+	}), IsNil)
+
+	s.state.Unlock()
+	s.settle(c)
+	s.state.Lock()
+
+	c.Assert(chg.Err(), IsNil)
+	c.Check(chg.Tasks(), HasLen, 1)
+	c.Check(strings.Join(chg.Tasks()[0].Log(), "\n"), Matches, `.*hotplug rule refuses to auto-connect consumer:plug core:hotplugslot`)
+
+	// the connection is neither restored nor auto-connected
+	var conns map[string]interface{}
+	c.Assert(s.state.Get("conns", &conns), IsNil)
+	c.Assert(conns, DeepEquals, map[string]interface{}{
+		"consumer:plug core:hotplugslot": map[string]interface{}{
+			"interface":    "test",
+			"hotplug-key":  "1234",
+			"auto":         true,
+			"hotplug-gone": true,
+		}})
+}
+
+func (s *interfaceManagerSuite) TestHotplugConnectByRule(c *C) {
+	s.MockModel(c, nil)
+
+	coreInfo := s.mockSnap(c, coreSnapYaml)
+	repo := s.manager(c).Repository()
+	// the interface does not allow auto-connection
+	c.Assert(repo.AddInterface(&ifacetest.TestInterface{
+		InterfaceName:       "test",
+		AutoConnectCallback: func(*snap.PlugInfo, *snap.SlotInfo) bool { return false },
+	}), IsNil)
+	c.Assert(repo.AddSlot(&snap.SlotInfo{Snap: coreInfo, Name: "hotplugslot", Interface: "test", HotplugKey: "1234"}), IsNil)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	s.state.Set("hotplug-slots", map[string]interface{}{
+		"hotplugslot": map[string]interface{}{"name": "hotplugslot", "interface": "test", "hotplug-key": "1234"},
+	})
+
+	mockConsumer(c, s.state, repo, consumerYaml, "consumer", "plug")
+	mockConsumer(c, s.state, repo, consumer2Yaml, "consumer2", "plug")
+
+	for _, rule := range []*ifacestate.HotplugRule{
+		{Interface: "test", Match: map[string]string{"ID_VENDOR_ID": "1234", "ID_MODEL_ID": "5678"}, Snap: "consumer", Plug: "plug", Action: ifacestate.HotplugRuleConnect},
+		// the rule does not match the device
+		{Interface: "test", Match: map[string]string{"ID_VENDOR_ID": "abcd"}, Snap: "consumer2", Action: ifacestate.HotplugRuleConnect},
+	} {
+		c.Assert(ifacestate.AddHotplugRule(s.state, rule), IsNil)
+	}
+
+	chg := s.state.NewChange("hotplug change", "")
+	t := s.state.NewTask("hotplug-connect", "")
+	ifacestate.SetHotplugAttrs(t, "test", "1234")
+	devinfo, err := hotplug.NewHotplugDeviceInfo(map[string]string{"DEVPATH": "/a", "ID_VENDOR_ID": "1234", "ID_MODEL_ID": "5678"})
+	c.Assert(err, IsNil)
+	t.Set("device-info", devinfo)
+	chg.AddTask(t)
+
+	s.state.Unlock()
+	s.settle(c)
+	s.state.Lock()
+
+	c.Assert(chg.Err(), IsNil)
+
+	var conns map[string]interface{}
+	c.Assert(s.state.Get("conns", &conns), IsNil)
+	c.Assert(conns, DeepEquals, map[string]interface{}{
+		"consumer:plug core:hotplugslot": map[string]interface{}{
+			"interface":   "test",
+			"hotplug-key": "1234",
+			"plug-static": map[string]interface{}{"attr1": "value1"},
+		}})
+}
+
 func (s *interfaceManagerSuite) TestHotplugDisconnect(c *C) {
 	coreInfo := s.mockSnap(c, coreSnapYaml)
 	repo := s.manager(c).Repository()
@@ -7619,6 +7717,45 @@ func (s *interfaceManagerSuite) TestHotplugAddNewSlotWithNameFromInterface(c *C)
 	s.testHotplugAddNewSlot(c, map[string]string{"DEVPATH": "/a"}, "", "test")
 }
 
+func (s *interfaceManagerSuite) TestHotplugAddNewSlotRecordsDevice(c *C) {
+	_ = s.mockSnap(c, coreSnapYaml)
+	repo := s.manager(c).Repository()
+	c.Assert(repo.AddInterface(&ifacetest.TestInterface{InterfaceName: "test"}), IsNil)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	chg := s.state.NewChange("hotplug change", "")
+	t := s.state.NewTask("hotplug-add-slot", "")
+	ifacestate.SetHotplugAttrs(t, "test", "1234")
+	t.Set("proposed-slot", hotplug.ProposedSlot{Name: "webcam"})
+	devinfo, err := hotplug.NewHotplugDeviceInfo(map[string]string{"DEVPATH": "/a", "ID_VENDOR_ID": "046d", "ID_MODEL_ID": "0825", "ID_SERIAL_SHORT": "2A3F1B40", "ID_PATH": "pci-0000:00:14.0-usb-0:2:1.0"})
+	c.Assert(err, IsNil)
+	t.Set("device-info", devinfo)
+	chg.AddTask(t)
+
+	s.state.Unlock()
+	s.se.Ensure()
+	s.se.Wait()
+	s.state.Lock()
+
+	c.Assert(chg.Err(), IsNil)
+
+	var hotplugSlots map[string]interface{}
+	c.Assert(s.state.Get("hotplug-slots", &hotplugSlots), IsNil)
+	c.Check(hotplugSlots["webcam"], DeepEquals, map[string]interface{}{
+		"name":        "webcam",
+		"interface":   "test",
+		"hotplug-key": "1234",
+		"device": map[string]interface{}{
+			"ID_VENDOR_ID":    "046d",
+			"ID_MODEL_ID":     "0825",
+			"ID_SERIAL_SHORT": "2A3F1B40",
+		},
+		"hotplug-gone": false,
+	})
+}
+
 func (s *interfaceManagerSuite) TestHotplugAddGoneSlot(c *C) {
 	_ = s.mockSnap(c, coreSnapYaml)
 	repo := s.manager(c).Repository()
@@ -36,8 +36,10 @@ import (
 	"gopkg.in/tomb.v2"
 
 	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/features"
 	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/overlord/aspectstate"
 	"github.com/snapcore/snapd/overlord/assertstate"
 	"github.com/snapcore/snapd/overlord/cmdstate"
 	"github.com/snapcore/snapd/overlord/configstate"
@@ -47,6 +49,7 @@ import (
 	"github.com/snapcore/snapd/overlord/hookstate"
 	"github.com/snapcore/snapd/overlord/ifacestate"
 	"github.com/snapcore/snapd/overlord/patch"
+	"github.com/snapcore/snapd/overlord/promptingstate"
 	"github.com/snapcore/snapd/overlord/restart"
 	"github.com/snapcore/snapd/overlord/servicestate"
 	"github.com/snapcore/snapd/overlord/snapshotstate"
@@ -112,6 +115,7 @@ type Overlord struct {
 	deviceMgr  *devicestate.DeviceManager
 	cmdMgr     *cmdstate.CommandManager
 	shotMgr    *snapshotstate.SnapshotManager
+	promptMgr  *promptingstate.PromptingManager
 	// proxyConf mediates the http proxy config
 	proxyConf func(req *http.Request) (*url.URL, error)
 }
@@ -125,10 +129,13 @@ func New(restartHandler restart.Handler) (*Overlord, error) {
 		inited: true,
 	}
 
-	backend := &overlordStateBackend{
+	var backend state.Backend = &overlordStateBackend{
 		path:         dirs.SnapStateFile,
 		ensureBefore: o.ensureBefore,
 	}
+	if features.JournaledState.IsEnabled() {
+		backend = state.NewJournaledBackend(dirs.SnapStateFile, o.ensureBefore)
+	}
 	s, restartMgr, err := o.loadState(backend, restartHandler)
 	if err != nil {
 		return nil, err
@@ -180,6 +187,13 @@ func New(restartHandler restart.Handler) (*Overlord, error) {
 
 	o.addManager(cmdstate.Manager(s, o.runner))
 	o.addManager(snapshotstate.Manager(s, o.runner))
+	o.addManager(aspectstate.Manager(s, hookMgr, o.runner))
+
+	promptMgr, err := promptingstate.Manager(s)
+	if err != nil {
+		return nil, err
+	}
+	o.addManager(promptMgr)
 
 	if err := configstateInit(s, hookMgr); err != nil {
 		return nil, err
@@ -191,12 +205,10 @@ func New(restartHandler restart.Handler) (*Overlord, error) {
 
 	s.Lock()
 	defer s.Unlock()
-	// setting up the store
+	// setting up the store, the device manager picks an offline one
+	// if configured
 	o.proxyConf = proxyconf.New(s).Conf
-	storeCtx := storecontext.New(s, o.deviceMgr.StoreContextBackend())
-	sto := o.newStoreWithContext(storeCtx)
-
-	snapstate.ReplaceStore(s, sto)
+	devicestate.ResetStore(s)
 
 	return o, nil
 }
@@ -219,6 +231,8 @@ func (o *Overlord) addManager(mgr StateManager) {
 		o.cmdMgr = x
 	case *snapshotstate.SnapshotManager:
 		o.shotMgr = x
+	case *promptingstate.PromptingManager:
+		o.promptMgr = x
 	case *restart.RestartManager:
 		o.restartMgr = x
 	}
@@ -299,15 +313,9 @@ func (o *Overlord) loadState(backend state.Backend, restartHandler restart.Handl
 		return s, restartMgr, nil
 	}
 
-	r, err := os.Open(dirs.SnapStateFile)
-	if err != nil {
-		return nil, nil, fmt.Errorf("cannot read the state file: %s", err)
-	}
-	defer r.Close()
-
 	var s *state.State
 	timings.Run(perfTimings, "read-state", "read snapd state from disk", func(tm timings.Measurer) {
-		s, err = state.ReadState(backend, r)
+		s, err = state.ReadStateFile(backend, dirs.SnapStateFile)
 	})
 	if err != nil {
 		return nil, nil, err
@@ -677,6 +685,12 @@ func (o *Overlord) SnapshotManager() *snapshotstate.SnapshotManager {
 	return o.shotMgr
 }
 
+// PromptingManager returns the manager responsible for the requests and
+// rules of interactive prompting.
+func (o *Overlord) PromptingManager() *promptingstate.PromptingManager {
+	return o.promptMgr
+}
+
 // Mock creates an Overlord without any managers and with a backend
 // not using disk. Managers can be added with AddManager. For testing.
 func Mock() *Overlord {
@@ -38,6 +38,7 @@ import (
 
 	"github.com/snapcore/snapd/boot"
 	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/features"
 	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/overlord"
 	"github.com/snapcore/snapd/overlord/devicestate/devicestatetest"
@@ -124,6 +125,7 @@ func (ovs *overlordSuite) TestNew(c *C) {
 	c.Check(o.DeviceManager(), NotNil)
 	c.Check(o.CommandManager(), NotNil)
 	c.Check(o.SnapshotManager(), NotNil)
+	c.Check(o.PromptingManager(), NotNil)
 	c.Check(configstateInitCalled, Equals, true)
 
 	o.InterfaceManager().DisableUDevMonitor()
@@ -224,6 +226,33 @@ func (ovs *overlordSuite) TestNewWithInvalidState(c *C) {
 	c.Assert(err, ErrorMatches, "cannot read state: EOF")
 }
 
+func (ovs *overlordSuite) TestNewWithJournaledState(c *C) {
+	c.Assert(os.MkdirAll(dirs.FeaturesDir, 0755), IsNil)
+	c.Assert(ioutil.WriteFile(features.JournaledState.ControlFile(), nil, 0644), IsNil)
+
+	o, err := overlord.New(nil)
+	c.Assert(err, IsNil)
+	st := o.State()
+	st.Lock()
+	st.Set("some", "data")
+	st.Unlock()
+	c.Check(state.JournalPath(dirs.SnapStateFile), testutil.FilePresent)
+	c.Assert(o.Stop(), IsNil)
+
+	// disabling the feature drops the journal on the next start
+	c.Assert(os.Remove(features.JournaledState.ControlFile()), IsNil)
+	o, err = overlord.New(nil)
+	c.Assert(err, IsNil)
+	c.Check(state.JournalPath(dirs.SnapStateFile), testutil.FileAbsent)
+
+	st = o.State()
+	st.Lock()
+	defer st.Unlock()
+	var v string
+	c.Assert(st.Get("some", &v), IsNil)
+	c.Check(v, Equals, "data")
+}
+
 func (ovs *overlordSuite) TestNewWithPatches(c *C) {
 	p := func(s *state.State) error {
 		s.Set("patched", true)
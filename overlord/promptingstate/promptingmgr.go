@@ -0,0 +1,408 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package promptingstate implements the manager keeping track of the access
+// requests of snaps waiting for a decision of the user, and of the rules
+// created from these decisions.
+package promptingstate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/snapcore/snapd/interfaces/prompting"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/strutil"
+)
+
+var (
+	// ErrRequestNotFound is returned when there's no pending request with
+	// the given ID for the user.
+	ErrRequestNotFound = errors.New("no request with the given ID is pending")
+	// ErrStopped is returned when handling requests after the manager was
+	// stopped.
+	ErrStopped = errors.New("prompting manager is stopped")
+)
+
+var timeNow = time.Now
+
+// Request is an access request of a snap waiting for a decision of the user.
+type Request struct {
+	ID          string    `json:"id"`
+	Timestamp   time.Time `json:"timestamp"`
+	User        uint32    `json:"-"`
+	Snap        string    `json:"snap"`
+	App         string    `json:"app"`
+	Interface   string    `json:"interface"`
+	Path        string    `json:"path"`
+	Permissions []string  `json:"permissions"`
+
+	// reply is used to deliver the decision to the waiting HandleRequest
+	reply chan bool
+}
+
+// Reply is the decision of the user on a request.
+type Reply struct {
+	Outcome  prompting.OutcomeType  `json:"outcome"`
+	Lifespan prompting.LifespanType `json:"lifespan"`
+	// PathPattern is the pattern of the paths the decision applies to, it
+	// defaults to the path of the request.
+	PathPattern string `json:"path-pattern,omitempty"`
+	// Permissions are the permissions the decision applies to, they
+	// default to those of the request.
+	Permissions []string `json:"permissions,omitempty"`
+	// Duration is for how long a decision with a timespan lifespan
+	// applies, in the format of time.ParseDuration.
+	Duration string `json:"duration,omitempty"`
+}
+
+// expiration returns when a rule created from the reply stops applying.
+func (reply *Reply) expiration(now time.Time) (time.Time, error) {
+	if reply.Lifespan != prompting.LifespanTimespan {
+		if reply.Duration != "" {
+			return time.Time{}, fmt.Errorf("duration must only be given with lifespan %q", prompting.LifespanTimespan)
+		}
+		return time.Time{}, nil
+	}
+	if reply.Duration == "" {
+		return time.Time{}, fmt.Errorf("duration must be given with lifespan %q", prompting.LifespanTimespan)
+	}
+	d, err := time.ParseDuration(reply.Duration)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid duration: %v", err)
+	}
+	if d <= 0 {
+		return time.Time{}, fmt.Errorf("invalid duration: %q must be positive", reply.Duration)
+	}
+	return now.Add(d), nil
+}
+
+// PromptingManager keeps track of pending requests and of the rules created
+// from the replies of the users, which are persisted in the state.
+//
+// When both are needed, the state lock must be taken before the lock of
+// the manager.
+type PromptingManager struct {
+	state *state.State
+
+	mu       sync.Mutex
+	lastID   uint64
+	requests map[string]*Request
+	rules    *ruleDB
+	stopped  bool
+}
+
+// Manager returns a new PromptingManager.
+func Manager(st *state.State) (*PromptingManager, error) {
+	st.Lock()
+	defer st.Unlock()
+
+	rules, err := loadRules(st)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &PromptingManager{
+		state:    st,
+		requests: make(map[string]*Request),
+		rules:    rules,
+	}
+
+	snapstate.RemoveSnapPromptingRules = m.removeSnapRules
+
+	return m, nil
+}
+
+// Ensure is part of the overlord.StateManager interface. It removes the
+// expired rules.
+func (m *PromptingManager) Ensure() error {
+	m.state.Lock()
+	defer m.state.Unlock()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := timeNow()
+	if expired := m.rules.removeIf(func(rule *Rule) bool { return rule.expired(now) }); len(expired) > 0 {
+		m.rules.save(m.state)
+	}
+	return nil
+}
+
+// Stop denies all the pending requests and makes the manager refuse new
+// ones. It implements the overlord.StateStopper interface.
+func (m *PromptingManager) Stop() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.stopped = true
+	for id, req := range m.requests {
+		req.reply <- false
+		delete(m.requests, id)
+	}
+}
+
+func formatID(id uint64) string {
+	// fixed width so that IDs sort as strings
+	return fmt.Sprintf("%016X", id)
+}
+
+// HandleRequest decides on the access of the snap through the interface to
+// the path. If the existing rules don't decide on it, a request is added for
+// the user to reply to and HandleRequest blocks until the reply or until the
+// context is done.
+func (m *PromptingManager) HandleRequest(ctx context.Context, user uint32, snap, app, iface, path string, permissions []string) (allow bool, err error) {
+	if err := prompting.ValidatePermissions(permissions); err != nil {
+		return false, err
+	}
+
+	m.mu.Lock()
+	if m.stopped {
+		m.mu.Unlock()
+		return false, ErrStopped
+	}
+
+	if allow, decided := m.rules.decide(user, snap, iface, path, permissions, timeNow()); decided {
+		m.mu.Unlock()
+		return allow, nil
+	}
+
+	m.lastID++
+	req := &Request{
+		ID:          formatID(m.lastID),
+		Timestamp:   timeNow(),
+		User:        user,
+		Snap:        snap,
+		App:         app,
+		Interface:   iface,
+		Path:        path,
+		Permissions: permissions,
+		reply:       make(chan bool, 1),
+	}
+	m.requests[req.ID] = req
+	m.mu.Unlock()
+
+	select {
+	case allow := <-req.reply:
+		return allow, nil
+	case <-ctx.Done():
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		delete(m.requests, req.ID)
+		// the reply may have been sent concurrently
+		select {
+		case allow := <-req.reply:
+			return allow, nil
+		default:
+		}
+		return false, ctx.Err()
+	}
+}
+
+// Requests returns the pending requests of the user, sorted by ID.
+func (m *PromptingManager) Requests(user uint32) []*Request {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var reqs []*Request
+	for _, req := range m.requests {
+		if req.User == user {
+			reqs = append(reqs, req)
+		}
+	}
+	sort.Slice(reqs, func(i, j int) bool { return reqs[i].ID < reqs[j].ID })
+	return reqs
+}
+
+// RequestWithID returns the pending request of the user with the given ID.
+func (m *PromptingManager) RequestWithID(user uint32, id string) (*Request, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	req, ok := m.requests[id]
+	if !ok || req.User != user {
+		return nil, ErrRequestNotFound
+	}
+	return req, nil
+}
+
+// Reply records the decision of the user on the request with the given ID.
+// Unless the decision applies only once, a rule is created from it and
+// returned, and the other pending requests decided by the new rule are
+// replied to as well. The request is left pending if the new rule conflicts
+// with existing ones.
+func (m *PromptingManager) Reply(user uint32, id string, reply *Reply) (*Rule, error) {
+	if err := prompting.ValidateOutcome(reply.Outcome); err != nil {
+		return nil, err
+	}
+	if err := prompting.ValidateLifespan(reply.Lifespan); err != nil {
+		return nil, err
+	}
+	now := timeNow()
+	expiration, err := reply.expiration(now)
+	if err != nil {
+		return nil, err
+	}
+
+	m.state.Lock()
+	defer m.state.Unlock()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	req, ok := m.requests[id]
+	if !ok || req.User != user {
+		return nil, ErrRequestNotFound
+	}
+
+	pathPattern := reply.PathPattern
+	if pathPattern == "" {
+		pathPattern = req.Path
+	}
+	matches, err := prompting.PathPatternMatches(pathPattern, req.Path)
+	if err != nil {
+		return nil, err
+	}
+	if !matches {
+		return nil, fmt.Errorf("path pattern %q does not match the requested path %q", pathPattern, req.Path)
+	}
+
+	permissions := reply.Permissions
+	if len(permissions) == 0 {
+		permissions = req.Permissions
+	}
+	if err := prompting.ValidatePermissions(permissions); err != nil {
+		return nil, err
+	}
+	for _, perm := range req.Permissions {
+		if !strutil.ListContains(permissions, perm) {
+			return nil, fmt.Errorf("permissions must include the requested permission %q", perm)
+		}
+	}
+
+	if reply.Lifespan == prompting.LifespanOnce {
+		m.resolve(req, reply.Outcome == prompting.OutcomeAllow)
+		return nil, nil
+	}
+
+	rule := &Rule{
+		User:        user,
+		Snap:        req.Snap,
+		Interface:   req.Interface,
+		PathPattern: pathPattern,
+		Permissions: permissions,
+		Outcome:     reply.Outcome,
+		Lifespan:    reply.Lifespan,
+		Expiration:  expiration,
+	}
+	if err := m.rules.add(rule, now); err != nil {
+		return nil, err
+	}
+	m.rules.save(m.state)
+
+	m.resolve(req, reply.Outcome == prompting.OutcomeAllow)
+
+	// reply to the other requests now decided by the rules
+	for _, other := range m.requests {
+		if other.User != user || other.Snap != req.Snap || other.Interface != req.Interface {
+			continue
+		}
+		if allow, decided := m.rules.decide(user, other.Snap, other.Interface, other.Path, other.Permissions, now); decided {
+			m.resolve(other, allow)
+		}
+	}
+
+	return rule, nil
+}
+
+// resolve delivers the decision on the request and removes it from the
+// pending ones. It must be called with the lock held.
+func (m *PromptingManager) resolve(req *Request, allow bool) {
+	req.reply <- allow
+	delete(m.requests, req.ID)
+}
+
+// Rules returns the rules of the user, optionally only those of the given
+// snap and interface, sorted by ID.
+func (m *PromptingManager) Rules(user uint32, snap, iface string) []*Rule {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.rules.rulesFor(user, snap, iface, timeNow())
+}
+
+// RuleWithID returns the rule of the user with the given ID.
+func (m *PromptingManager) RuleWithID(user uint32, id string) (*Rule, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.rules.ruleWithID(user, id, timeNow())
+}
+
+// RemoveRule removes the rule of the user with the given ID and returns it.
+func (m *PromptingManager) RemoveRule(user uint32, id string) (*Rule, error) {
+	m.state.Lock()
+	defer m.state.Unlock()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rule, err := m.rules.ruleWithID(user, id, timeNow())
+	if err != nil {
+		return nil, err
+	}
+	m.rules.removeIf(func(r *Rule) bool { return r == rule })
+	m.rules.save(m.state)
+	return rule, nil
+}
+
+// RemoveRules removes the rules of the user for the given snap, optionally
+// only those for the given interface, and returns them.
+func (m *PromptingManager) RemoveRules(user uint32, snap, iface string) ([]*Rule, error) {
+	if snap == "" {
+		return nil, fmt.Errorf("snap must be given to remove rules")
+	}
+
+	m.state.Lock()
+	defer m.state.Unlock()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	removed := m.rules.removeIf(func(rule *Rule) bool {
+		return rule.User == user && rule.Snap == snap && (iface == "" || rule.Interface == iface)
+	})
+	if len(removed) > 0 {
+		m.rules.save(m.state)
+	}
+	return removed, nil
+}
+
+// removeSnapRules removes the rules of all users for the snap, it is called
+// with the state locked when the snap is removed.
+func (m *PromptingManager) removeSnapRules(st *state.State, snapName string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if removed := m.rules.removeIf(func(rule *Rule) bool { return rule.Snap == snapName }); len(removed) > 0 {
+		m.rules.save(st)
+	}
+	return nil
+}
@@ -0,0 +1,446 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package promptingstate_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/interfaces/prompting"
+	"github.com/snapcore/snapd/overlord/promptingstate"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/testutil"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type promptingSuite struct {
+	testutil.BaseTest
+
+	state *state.State
+	mgr   *promptingstate.PromptingManager
+	now   time.Time
+}
+
+var _ = Suite(&promptingSuite{})
+
+func (s *promptingSuite) SetUpTest(c *C) {
+	s.BaseTest.SetUpTest(c)
+
+	s.now = time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+	s.AddCleanup(promptingstate.MockTimeNow(func() time.Time { return s.now }))
+
+	s.state = state.New(nil)
+	mgr, err := promptingstate.Manager(s.state)
+	c.Assert(err, IsNil)
+	s.mgr = mgr
+	c.Assert(s.mgr.Ensure(), IsNil)
+}
+
+type result struct {
+	allow bool
+	err   error
+}
+
+// handle runs HandleRequest in the background and waits for the request to
+// be pending, or for the result if the rules decide on it.
+func (s *promptingSuite) handle(c *C, ctx context.Context, user uint32, snap, path string, perms ...string) (*promptingstate.Request, <-chan result) {
+	before := len(s.mgr.Requests(user))
+
+	resCh := make(chan result, 1)
+	go func() {
+		allow, err := s.mgr.HandleRequest(ctx, user, snap, "app", "home", path, perms)
+		resCh <- result{allow, err}
+	}()
+
+	for i := 0; i < 500; i++ {
+		if reqs := s.mgr.Requests(user); len(reqs) > before {
+			return reqs[len(reqs)-1], resCh
+		}
+		if len(resCh) > 0 {
+			return nil, resCh
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	c.Fatal("timeout waiting for the request")
+	return nil, nil
+}
+
+func waitResult(c *C, resCh <-chan result) result {
+	select {
+	case res := <-resCh:
+		return res
+	case <-time.After(5 * time.Second):
+		c.Fatal("timeout waiting for the result")
+	}
+	return result{}
+}
+
+func (s *promptingSuite) TestReplyOnce(c *C) {
+	req, resCh := s.handle(c, context.Background(), 1000, "foo", "/home/test/file.txt", "read")
+	c.Assert(req, NotNil)
+	c.Check(req.ID, Equals, "0000000000000001")
+	c.Check(req.Timestamp.Equal(s.now), Equals, true)
+	c.Check(req.Snap, Equals, "foo")
+	c.Check(req.App, Equals, "app")
+	c.Check(req.Interface, Equals, "home")
+	c.Check(req.Path, Equals, "/home/test/file.txt")
+	c.Check(req.Permissions, DeepEquals, []string{"read"})
+
+	// the request is only visible to its user
+	c.Check(s.mgr.Requests(1001), HasLen, 0)
+	_, err := s.mgr.RequestWithID(1001, req.ID)
+	c.Check(err, Equals, promptingstate.ErrRequestNotFound)
+	_, err = s.mgr.Reply(1001, req.ID, &promptingstate.Reply{Outcome: "allow", Lifespan: "once"})
+	c.Check(err, Equals, promptingstate.ErrRequestNotFound)
+
+	found, err := s.mgr.RequestWithID(1000, req.ID)
+	c.Assert(err, IsNil)
+	c.Check(found, Equals, req)
+
+	rule, err := s.mgr.Reply(1000, req.ID, &promptingstate.Reply{Outcome: "allow", Lifespan: "once"})
+	c.Assert(err, IsNil)
+	c.Check(rule, IsNil)
+
+	c.Check(waitResult(c, resCh), Equals, result{allow: true})
+	c.Check(s.mgr.Requests(1000), HasLen, 0)
+	c.Check(s.mgr.Rules(1000, "", ""), HasLen, 0)
+
+	// the next request is prompted again
+	req, resCh = s.handle(c, context.Background(), 1000, "foo", "/home/test/file.txt", "read")
+	c.Assert(req, NotNil)
+	_, err = s.mgr.Reply(1000, req.ID, &promptingstate.Reply{Outcome: "deny", Lifespan: "once"})
+	c.Assert(err, IsNil)
+	c.Check(waitResult(c, resCh), Equals, result{allow: false})
+}
+
+func (s *promptingSuite) TestReplyCreatesRule(c *C) {
+	req, resCh := s.handle(c, context.Background(), 1000, "foo", "/home/test/Documents/a.txt", "read")
+	c.Assert(req, NotNil)
+	// another pending request covered by the future rule
+	other, otherResCh := s.handle(c, context.Background(), 1000, "foo", "/home/test/Documents/b.txt", "read")
+	c.Assert(other, NotNil)
+	// and others which aren't
+	notCovered, notCoveredResCh := s.handle(c, context.Background(), 1000, "foo", "/home/test/Documents/c.txt", "read", "write")
+	c.Assert(notCovered, NotNil)
+	otherSnap, _ := s.handle(c, context.Background(), 1000, "bar", "/home/test/Documents/b.txt", "read")
+	c.Assert(otherSnap, NotNil)
+
+	rule, err := s.mgr.Reply(1000, req.ID, &promptingstate.Reply{
+		Outcome:     "allow",
+		Lifespan:    "forever",
+		PathPattern: "/home/test/Documents/*",
+	})
+	c.Assert(err, IsNil)
+	c.Check(rule, DeepEquals, &promptingstate.Rule{
+		ID:          "0000000000000001",
+		Timestamp:   s.now,
+		User:        1000,
+		Snap:        "foo",
+		Interface:   "home",
+		PathPattern: "/home/test/Documents/*",
+		Permissions: []string{"read"},
+		Outcome:     prompting.OutcomeAllow,
+		Lifespan:    prompting.LifespanForever,
+	})
+
+	c.Check(waitResult(c, resCh), Equals, result{allow: true})
+	c.Check(waitResult(c, otherResCh), Equals, result{allow: true})
+
+	reqs := s.mgr.Requests(1000)
+	c.Assert(reqs, HasLen, 2)
+	c.Check(reqs[0].ID, Equals, notCovered.ID)
+	c.Check(reqs[1].ID, Equals, otherSnap.ID)
+	c.Check(notCoveredResCh, HasLen, 0)
+
+	// new matching requests are decided by the rule
+	req, resCh = s.handle(c, context.Background(), 1000, "foo", "/home/test/Documents/d.txt", "read")
+	c.Check(req, IsNil)
+	c.Check(waitResult(c, resCh), Equals, result{allow: true})
+
+	c.Check(s.mgr.Rules(1000, "", ""), DeepEquals, []*promptingstate.Rule{rule})
+	c.Check(s.mgr.Rules(1000, "foo", ""), DeepEquals, []*promptingstate.Rule{rule})
+	c.Check(s.mgr.Rules(1000, "foo", "home"), DeepEquals, []*promptingstate.Rule{rule})
+	c.Check(s.mgr.Rules(1000, "foo", "camera"), HasLen, 0)
+	c.Check(s.mgr.Rules(1000, "bar", ""), HasLen, 0)
+	c.Check(s.mgr.Rules(1001, "", ""), HasLen, 0)
+}
+
+func (s *promptingSuite) TestMoreSpecificRuleTakesPrecedence(c *C) {
+	req, _ := s.handle(c, context.Background(), 1000, "foo", "/home/test/file.txt", "read")
+	_, err := s.mgr.Reply(1000, req.ID, &promptingstate.Reply{Outcome: "allow", Lifespan: "session", PathPattern: "/home/test/**"})
+	c.Assert(err, IsNil)
+
+	req, _ = s.handle(c, context.Background(), 1000, "foo", "/home/test/secret/public.pem", "write")
+	_, err = s.mgr.Reply(1000, req.ID, &promptingstate.Reply{Outcome: "allow", Lifespan: "session", Permissions: []string{"read", "write"}})
+	c.Assert(err, IsNil)
+
+	req, _ = s.handle(c, context.Background(), 1000, "foo", "/home/test/secret/key", "write")
+	_, err = s.mgr.Reply(1000, req.ID, &promptingstate.Reply{Outcome: "deny", Lifespan: "session", PathPattern: "/home/test/secret/*", Permissions: []string{"read", "write"}})
+	c.Assert(err, IsNil)
+
+	_, resCh := s.handle(c, context.Background(), 1000, "foo", "/home/test/secret/key", "read")
+	c.Check(waitResult(c, resCh), Equals, result{allow: false})
+
+	_, resCh = s.handle(c, context.Background(), 1000, "foo", "/home/test/secret/public.pem", "read")
+	c.Check(waitResult(c, resCh), Equals, result{allow: true})
+
+	_, resCh = s.handle(c, context.Background(), 1000, "foo", "/home/test/other", "read")
+	c.Check(waitResult(c, resCh), Equals, result{allow: true})
+
+	// a rule denying any of the permissions denies the access
+	_, resCh = s.handle(c, context.Background(), 1000, "foo", "/home/test/secret/key", "read", "write")
+	c.Check(waitResult(c, resCh), Equals, result{allow: false})
+}
+
+func (s *promptingSuite) TestReplyConflict(c *C) {
+	req, _ := s.handle(c, context.Background(), 1000, "foo", "/home/test/file.txt", "read")
+	rule, err := s.mgr.Reply(1000, req.ID, &promptingstate.Reply{Outcome: "deny", Lifespan: "forever", PathPattern: "/home/test/*.txt"})
+	c.Assert(err, IsNil)
+
+	req, resCh := s.handle(c, context.Background(), 1000, "foo", "/home/test/file.txt", "write")
+	c.Assert(req, NotNil)
+	_, err = s.mgr.Reply(1000, req.ID, &promptingstate.Reply{Outcome: "allow", Lifespan: "forever", PathPattern: "/home/test/file*", Permissions: []string{"read", "write"}})
+	c.Assert(err, FitsTypeOf, &promptingstate.RuleConflictError{})
+	c.Check(err.(*promptingstate.RuleConflictError).Conflicts, DeepEquals, []promptingstate.RuleConflict{
+		{Permission: "read", ConflictingID: rule.ID},
+	})
+	c.Check(err, ErrorMatches, `cannot add rule: conflicts with existing rules on "read" with rule 0000000000000001`)
+
+	// the request is still pending
+	c.Check(s.mgr.Requests(1000), HasLen, 1)
+	c.Check(resCh, HasLen, 0)
+
+	// rules of other precedences, outcomes or permissions don't conflict
+	for _, reply := range []*promptingstate.Reply{
+		{Outcome: "allow", Lifespan: "session", PathPattern: "/home/test/file*"},
+		{Outcome: "allow", Lifespan: "session", PathPattern: "/home/test/**", Permissions: []string{"read", "write"}},
+		{Outcome: "deny", Lifespan: "session", PathPattern: "/home/test/file*", Permissions: []string{"read", "write"}},
+	} {
+		req, _ := s.handle(c, context.Background(), 1000, "foo", "/home/test/file.txt", "write")
+		c.Assert(req, NotNil)
+		newRule, err := s.mgr.Reply(1000, req.ID, reply)
+		c.Assert(err, IsNil)
+		_, err = s.mgr.RemoveRule(1000, newRule.ID)
+		c.Assert(err, IsNil)
+	}
+}
+
+func (s *promptingSuite) TestRulesArePersisted(c *C) {
+	var rules []*promptingstate.Rule
+	for _, lifespan := range []string{"session", "forever", "timespan"} {
+		req, _ := s.handle(c, context.Background(), 1000, "foo", "/home/test/"+lifespan, "read")
+		c.Assert(req, NotNil)
+		reply := &promptingstate.Reply{Outcome: "allow", Lifespan: prompting.LifespanType(lifespan)}
+		if lifespan == "timespan" {
+			reply.Duration = "1h"
+		}
+		rule, err := s.mgr.Reply(1000, req.ID, reply)
+		c.Assert(err, IsNil)
+		rules = append(rules, rule)
+	}
+	c.Check(rules[2].Expiration.Equal(s.now.Add(time.Hour)), Equals, true)
+
+	// session rules don't outlive a restart
+	mgr, err := promptingstate.Manager(s.state)
+	c.Assert(err, IsNil)
+	loaded := mgr.Rules(1000, "", "")
+	c.Assert(loaded, HasLen, 2)
+	for i, rule := range loaded {
+		c.Check(rule.ID, Equals, rules[i+1].ID)
+		c.Check(rule.PathPattern, Equals, rules[i+1].PathPattern)
+		c.Check(rule.Lifespan, Equals, rules[i+1].Lifespan)
+		c.Check(rule.Expiration.Equal(rules[i+1].Expiration), Equals, true)
+	}
+
+	// IDs are not reused
+	s.mgr = mgr
+	req, _ := s.handle(c, context.Background(), 1000, "foo", "/home/test/other", "read")
+	c.Check(req.ID, Equals, "0000000000000001")
+	rule, err := s.mgr.Reply(1000, req.ID, &promptingstate.Reply{Outcome: "allow", Lifespan: "forever"})
+	c.Assert(err, IsNil)
+	c.Check(rule.ID, Equals, "0000000000000004")
+}
+
+func (s *promptingSuite) TestTimespanRuleExpires(c *C) {
+	req, _ := s.handle(c, context.Background(), 1000, "foo", "/home/test/file.txt", "read")
+	rule, err := s.mgr.Reply(1000, req.ID, &promptingstate.Reply{Outcome: "allow", Lifespan: "timespan", Duration: "10m"})
+	c.Assert(err, IsNil)
+	c.Check(rule.Lifespan, Equals, prompting.LifespanTimespan)
+	c.Check(rule.Expiration.Equal(s.now.Add(10*time.Minute)), Equals, true)
+
+	_, resCh := s.handle(c, context.Background(), 1000, "foo", "/home/test/file.txt", "read")
+	c.Check(waitResult(c, resCh), Equals, result{allow: true})
+
+	s.now = s.now.Add(10 * time.Minute)
+	c.Check(s.mgr.Rules(1000, "", ""), HasLen, 0)
+	req, _ = s.handle(c, context.Background(), 1000, "foo", "/home/test/file.txt", "read")
+	c.Check(req, NotNil)
+
+	// expired rules are removed from the state
+	c.Assert(s.mgr.Ensure(), IsNil)
+	mgr, err := promptingstate.Manager(s.state)
+	c.Assert(err, IsNil)
+	s.now = s.now.Add(-time.Hour)
+	c.Check(mgr.Rules(1000, "", ""), HasLen, 0)
+}
+
+func (s *promptingSuite) addRule(c *C, user uint32, snap, path string) *promptingstate.Rule {
+	req, _ := s.handle(c, context.Background(), user, snap, path, "read")
+	c.Assert(req, NotNil)
+	rule, err := s.mgr.Reply(user, req.ID, &promptingstate.Reply{Outcome: "allow", Lifespan: "forever"})
+	c.Assert(err, IsNil)
+	return rule
+}
+
+func (s *promptingSuite) TestRemoveRule(c *C) {
+	rule1 := s.addRule(c, 1000, "foo", "/home/test/a")
+	rule2 := s.addRule(c, 1000, "foo", "/home/test/b")
+
+	found, err := s.mgr.RuleWithID(1000, rule1.ID)
+	c.Assert(err, IsNil)
+	c.Check(found, DeepEquals, rule1)
+
+	// rules are only visible to their user
+	_, err = s.mgr.RuleWithID(1001, rule1.ID)
+	c.Check(err, Equals, promptingstate.ErrRuleNotFound)
+	_, err = s.mgr.RemoveRule(1001, rule1.ID)
+	c.Check(err, Equals, promptingstate.ErrRuleNotFound)
+
+	removed, err := s.mgr.RemoveRule(1000, rule1.ID)
+	c.Assert(err, IsNil)
+	c.Check(removed, DeepEquals, rule1)
+	c.Check(s.mgr.Rules(1000, "", ""), DeepEquals, []*promptingstate.Rule{rule2})
+	_, err = s.mgr.RemoveRule(1000, rule1.ID)
+	c.Check(err, Equals, promptingstate.ErrRuleNotFound)
+
+	// the removal is persisted
+	mgr, err := promptingstate.Manager(s.state)
+	c.Assert(err, IsNil)
+	c.Assert(mgr.Rules(1000, "", ""), HasLen, 1)
+	c.Check(mgr.Rules(1000, "", "")[0].ID, Equals, rule2.ID)
+}
+
+func (s *promptingSuite) TestRemoveRules(c *C) {
+	rule1 := s.addRule(c, 1000, "foo", "/home/test/a")
+	rule2 := s.addRule(c, 1000, "bar", "/home/test/a")
+	rule3 := s.addRule(c, 1001, "foo", "/home/other/a")
+	rule4 := s.addRule(c, 1000, "foo", "/home/test/b")
+
+	_, err := s.mgr.RemoveRules(1000, "", "")
+	c.Check(err, ErrorMatches, "snap must be given to remove rules")
+
+	removed, err := s.mgr.RemoveRules(1000, "foo", "camera")
+	c.Assert(err, IsNil)
+	c.Check(removed, HasLen, 0)
+
+	removed, err = s.mgr.RemoveRules(1000, "foo", "home")
+	c.Assert(err, IsNil)
+	c.Check(removed, DeepEquals, []*promptingstate.Rule{rule1, rule4})
+	c.Check(s.mgr.Rules(1000, "", ""), DeepEquals, []*promptingstate.Rule{rule2})
+	c.Check(s.mgr.Rules(1001, "", ""), DeepEquals, []*promptingstate.Rule{rule3})
+}
+
+func (s *promptingSuite) TestRemoveSnapRules(c *C) {
+	s.addRule(c, 1000, "foo", "/home/test/a")
+	rule2 := s.addRule(c, 1000, "bar", "/home/test/a")
+	s.addRule(c, 1001, "foo", "/home/other/a")
+
+	s.state.Lock()
+	err := snapstate.RemoveSnapPromptingRules(s.state, "foo")
+	s.state.Unlock()
+	c.Assert(err, IsNil)
+
+	c.Check(s.mgr.Rules(1000, "", ""), DeepEquals, []*promptingstate.Rule{rule2})
+	c.Check(s.mgr.Rules(1001, "", ""), HasLen, 0)
+
+	mgr, err := promptingstate.Manager(s.state)
+	c.Assert(err, IsNil)
+	c.Check(mgr.Rules(1000, "", ""), HasLen, 1)
+	c.Check(mgr.Rules(1001, "", ""), HasLen, 0)
+}
+
+func (s *promptingSuite) TestManagerInvalidState(c *C) {
+	s.state.Lock()
+	s.state.Set("prompting-rules", "not-rules")
+	s.state.Unlock()
+
+	_, err := promptingstate.Manager(s.state)
+	c.Check(err, ErrorMatches, "cannot load prompting rules: .*")
+}
+
+func (s *promptingSuite) TestReplyErrors(c *C) {
+	req, _ := s.handle(c, context.Background(), 1000, "foo", "/home/test/file.txt", "read", "write")
+
+	for _, t := range []struct {
+		reply *promptingstate.Reply
+		err   string
+	}{
+		{&promptingstate.Reply{Outcome: "maybe", Lifespan: "once"}, `invalid outcome "maybe", must be "allow" or "deny"`},
+		{&promptingstate.Reply{Outcome: "allow", Lifespan: "always"}, `invalid lifespan "always", must be "once", "session", "forever" or "timespan"`},
+		{&promptingstate.Reply{Outcome: "allow", Lifespan: "timespan"}, `duration must be given with lifespan "timespan"`},
+		{&promptingstate.Reply{Outcome: "allow", Lifespan: "forever", Duration: "1h"}, `duration must only be given with lifespan "timespan"`},
+		{&promptingstate.Reply{Outcome: "allow", Lifespan: "timespan", Duration: "soon"}, `invalid duration: time: invalid duration "soon"`},
+		{&promptingstate.Reply{Outcome: "allow", Lifespan: "timespan", Duration: "-1h"}, `invalid duration: "-1h" must be positive`},
+		{&promptingstate.Reply{Outcome: "allow", Lifespan: "once", PathPattern: "/home/other/*"}, `path pattern "/home/other/\*" does not match the requested path "/home/test/file.txt"`},
+		{&promptingstate.Reply{Outcome: "allow", Lifespan: "once", PathPattern: "home"}, `invalid path pattern "home": must be absolute`},
+		{&promptingstate.Reply{Outcome: "allow", Lifespan: "once", Permissions: []string{"read"}}, `permissions must include the requested permission "write"`},
+		{&promptingstate.Reply{Outcome: "allow", Lifespan: "once", Permissions: []string{"read", "fly"}}, `invalid permission "fly", must be one of .*`},
+	} {
+		_, err := s.mgr.Reply(1000, req.ID, t.reply)
+		c.Check(err, ErrorMatches, t.err)
+	}
+
+	// the request is still pending
+	c.Check(s.mgr.Requests(1000), HasLen, 1)
+}
+
+func (s *promptingSuite) TestHandleRequestInvalidPermissions(c *C) {
+	_, err := s.mgr.HandleRequest(context.Background(), 1000, "foo", "app", "home", "/home/test/file.txt", nil)
+	c.Check(err, ErrorMatches, `permissions must not be empty`)
+}
+
+func (s *promptingSuite) TestHandleRequestContextDone(c *C) {
+	ctx, cancel := context.WithCancel(context.Background())
+	req, resCh := s.handle(c, ctx, 1000, "foo", "/home/test/file.txt", "read")
+	c.Assert(req, NotNil)
+
+	cancel()
+	c.Check(waitResult(c, resCh), Equals, result{err: context.Canceled})
+	c.Check(s.mgr.Requests(1000), HasLen, 0)
+
+	_, err := s.mgr.Reply(1000, req.ID, &promptingstate.Reply{Outcome: "allow", Lifespan: "once"})
+	c.Check(err, Equals, promptingstate.ErrRequestNotFound)
+}
+
+func (s *promptingSuite) TestStop(c *C) {
+	_, resCh := s.handle(c, context.Background(), 1000, "foo", "/home/test/file.txt", "read")
+
+	s.mgr.Stop()
+	c.Check(waitResult(c, resCh), Equals, result{allow: false})
+	c.Check(s.mgr.Requests(1000), HasLen, 0)
+
+	_, err := s.mgr.HandleRequest(context.Background(), 1000, "foo", "app", "home", "/home/test/file.txt", []string{"read"})
+	c.Check(err, Equals, promptingstate.ErrStopped)
+}
@@ -0,0 +1,231 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package promptingstate
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/snapcore/snapd/interfaces/prompting"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/strutil"
+)
+
+// ErrRuleNotFound is returned when the user has no rule with the given ID.
+var ErrRuleNotFound = errors.New("no rule with the given ID exists")
+
+// Rule is a decision of the user which applies to the future requests of a
+// snap through an interface for paths matching its path pattern.
+type Rule struct {
+	ID          string                 `json:"id"`
+	Timestamp   time.Time              `json:"timestamp"`
+	User        uint32                 `json:"user"`
+	Snap        string                 `json:"snap"`
+	Interface   string                 `json:"interface"`
+	PathPattern string                 `json:"path-pattern"`
+	Permissions []string               `json:"permissions"`
+	Outcome     prompting.OutcomeType  `json:"outcome"`
+	Lifespan    prompting.LifespanType `json:"lifespan"`
+	// Expiration is when a rule with a timespan lifespan stops applying.
+	Expiration time.Time `json:"expiration,omitempty"`
+}
+
+func (rule *Rule) expired(now time.Time) bool {
+	return rule.Lifespan == prompting.LifespanTimespan && !now.Before(rule.Expiration)
+}
+
+// RuleConflict describes a permission on which a new rule conflicts with
+// an existing one.
+type RuleConflict struct {
+	Permission    string `json:"permission"`
+	ConflictingID string `json:"conflicting-id"`
+}
+
+// RuleConflictError is returned when a new rule has the same precedence as
+// existing rules with overlapping path patterns, but a different outcome for
+// some of the same permissions.
+type RuleConflictError struct {
+	Conflicts []RuleConflict
+}
+
+func (e *RuleConflictError) Error() string {
+	descs := make([]string, 0, len(e.Conflicts))
+	for _, conflict := range e.Conflicts {
+		descs = append(descs, fmt.Sprintf("%q with rule %s", conflict.Permission, conflict.ConflictingID))
+	}
+	return fmt.Sprintf("cannot add rule: conflicts with existing rules on %s", strings.Join(descs, ", "))
+}
+
+// ruleDB keeps the rules in memory, the rules which outlive a restart of
+// snapd are persisted in the state.
+type ruleDB struct {
+	lastID uint64
+	rules  []*Rule
+}
+
+// rulesState is how the rules are persisted in the state.
+type rulesState struct {
+	LastID uint64  `json:"last-id"`
+	Rules  []*Rule `json:"rules"`
+}
+
+// loadRules returns the rules persisted in the state.
+func loadRules(st *state.State) (*ruleDB, error) {
+	var rs rulesState
+	if err := st.Get("prompting-rules", &rs); err != nil && !errors.Is(err, state.ErrNoState) {
+		return nil, fmt.Errorf("cannot load prompting rules: %v", err)
+	}
+	return &ruleDB{lastID: rs.LastID, rules: rs.Rules}, nil
+}
+
+// save persists the rules in the state, except for those with a session
+// lifespan, which only apply until snapd is restarted.
+func (db *ruleDB) save(st *state.State) {
+	rs := rulesState{LastID: db.lastID, Rules: []*Rule{}}
+	for _, rule := range db.rules {
+		if rule.Lifespan == prompting.LifespanSession {
+			continue
+		}
+		rs.Rules = append(rs.Rules, rule)
+	}
+	st.Set("prompting-rules", rs)
+}
+
+// conflicts returns an error if the rule conflicts with existing rules.
+func (db *ruleDB) conflicts(rule *Rule, now time.Time) error {
+	var conflicts []RuleConflict
+	for _, other := range db.rulesFor(rule.User, rule.Snap, rule.Interface, now) {
+		if other.Outcome == rule.Outcome || prompting.ComparePathPatterns(other.PathPattern, rule.PathPattern) != 0 {
+			continue
+		}
+		// rules are validated when added
+		if overlap, _ := prompting.PathPatternsOverlap(other.PathPattern, rule.PathPattern); !overlap {
+			continue
+		}
+		for _, perm := range rule.Permissions {
+			if strutil.ListContains(other.Permissions, perm) {
+				conflicts = append(conflicts, RuleConflict{Permission: perm, ConflictingID: other.ID})
+			}
+		}
+	}
+	if len(conflicts) > 0 {
+		return &RuleConflictError{Conflicts: conflicts}
+	}
+	return nil
+}
+
+// add adds the validated rule after checking it doesn't conflict with the
+// existing rules, giving it its ID and timestamp.
+func (db *ruleDB) add(rule *Rule, now time.Time) error {
+	if err := db.conflicts(rule, now); err != nil {
+		return err
+	}
+	db.lastID++
+	rule.ID = formatID(db.lastID)
+	rule.Timestamp = now
+	db.rules = append(db.rules, rule)
+	return nil
+}
+
+// rulesFor returns the rules of the user which are not expired, optionally
+// only those of the given snap and interface.
+func (db *ruleDB) rulesFor(user uint32, snap, iface string, now time.Time) []*Rule {
+	var rules []*Rule
+	for _, rule := range db.rules {
+		if rule.User != user || (snap != "" && rule.Snap != snap) || (iface != "" && rule.Interface != iface) {
+			continue
+		}
+		if rule.expired(now) {
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// ruleWithID returns the rule of the user with the given ID.
+func (db *ruleDB) ruleWithID(user uint32, id string, now time.Time) (*Rule, error) {
+	for _, rule := range db.rulesFor(user, "", "", now) {
+		if rule.ID == id {
+			return rule, nil
+		}
+	}
+	return nil, ErrRuleNotFound
+}
+
+// removeIf removes the rules for which the function returns true, and
+// returns them.
+func (db *ruleDB) removeIf(f func(rule *Rule) bool) []*Rule {
+	var removed []*Rule
+	kept := db.rules[:0]
+	for _, rule := range db.rules {
+		if f(rule) {
+			removed = append(removed, rule)
+			continue
+		}
+		kept = append(kept, rule)
+	}
+	// don't keep references to the removed rules around
+	for i := len(kept); i < len(db.rules); i++ {
+		db.rules[i] = nil
+	}
+	db.rules = kept
+	return removed
+}
+
+// decide returns whether the rules allow the access of the snap through the
+// interface to the path with all the given permissions. The returned
+// boolean is false if the rules don't decide on all the permissions.
+//
+// For each permission, the matching rule with the most specific path
+// pattern applies, a deny outcome winning between rules of the same
+// precedence. A rule denying any of the permissions denies the access.
+func (db *ruleDB) decide(user uint32, snap, iface, path string, permissions []string, now time.Time) (allow, decided bool) {
+	applying := make(map[string]*Rule, len(permissions))
+	for _, rule := range db.rulesFor(user, snap, iface, now) {
+		// rules are validated when added
+		if matches, _ := prompting.PathPatternMatches(rule.PathPattern, path); !matches {
+			continue
+		}
+		for _, perm := range permissions {
+			if !strutil.ListContains(rule.Permissions, perm) {
+				continue
+			}
+			current := applying[perm]
+			if current == nil {
+				applying[perm] = rule
+				continue
+			}
+			cmp := prompting.ComparePathPatterns(rule.PathPattern, current.PathPattern)
+			if cmp > 0 || (cmp == 0 && rule.Outcome == prompting.OutcomeDeny) {
+				applying[perm] = rule
+			}
+		}
+	}
+
+	for _, rule := range applying {
+		if rule.Outcome == prompting.OutcomeDeny {
+			return false, true
+		}
+	}
+	return true, len(applying) == len(permissions)
+}
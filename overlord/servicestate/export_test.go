@@ -34,6 +34,7 @@ var (
 	ServiceControlTs                     = serviceControlTs
 	ValidateSnapServicesForAddingToGroup = validateSnapServicesForAddingToGroup
 	AffectedSnapServices                 = affectedSnapServices
+	QuotaUpdateGroupLimits               = quotaUpdateGroupLimits
 )
 
 type QuotaStateUpdated = quotaStateUpdated
@@ -76,3 +77,17 @@ func MockResourcesCheckFeatureRequirements(f func(*quota.Resources) error) (rest
 	resourcesCheckFeatureRequirements = f
 	return r
 }
+
+func MockFsquota(mountPoint func(string) (string, error), setProjectID func(string, uint32) error, setProjectLimit func(string, uint32, uint64) error) (restore func()) {
+	r1 := testutil.Backup(&fsquotaMountPoint)
+	r2 := testutil.Backup(&fsquotaSetProjectID)
+	r3 := testutil.Backup(&fsquotaSetProjectLimit)
+	fsquotaMountPoint = mountPoint
+	fsquotaSetProjectID = setProjectID
+	fsquotaSetProjectLimit = setProjectLimit
+	return func() {
+		r1()
+		r2()
+		r3()
+	}
+}
@@ -112,10 +112,39 @@ func PatchQuotas(st *state.State, grps ...*quota.Group) (map[string]*quota.Group
 		}
 	}
 
+	// allocate filesystem project IDs for groups getting a disk limit
+	for _, grp := range grps {
+		assignDiskProjectID(grp, allGrps)
+	}
+
 	st.Set("quotas", allGrps)
 	return allGrps, nil
 }
 
+// diskProjectIDBase is the first filesystem project ID used for quota groups,
+// it is chosen high enough to stay clear of IDs commonly set up by hand by
+// administrators in /etc/projid.
+const diskProjectIDBase = 0x100000
+
+// assignDiskProjectID allocates the lowest free filesystem project ID for the
+// group, if it has a disk limit without a project ID assigned yet.
+func assignDiskProjectID(grp *quota.Group, allGrps map[string]*quota.Group) {
+	if grp.DiskLimit == nil || grp.DiskLimit.ProjectID != 0 {
+		return
+	}
+	used := make(map[uint32]bool, len(allGrps))
+	for _, g := range allGrps {
+		if g.DiskLimit != nil {
+			used[g.DiskLimit.ProjectID] = true
+		}
+	}
+	id := uint32(diskProjectIDBase)
+	for used[id] {
+		id++
+	}
+	grp.DiskLimit.ProjectID = id
+}
+
 // CreateQuotaInState creates a quota group with the given parameters
 // in the state.  It takes the current map of all quota groups.
 func CreateQuotaInState(st *state.State, quotaName string, parentGrp *quota.Group, snaps, services []string, resourceLimits quota.Resources, allGrps map[string]*quota.Group) (*quota.Group, map[string]*quota.Group, error) {
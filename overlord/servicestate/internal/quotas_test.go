@@ -199,3 +199,37 @@ func (s *servicestateQuotasSuite) TestCreateQuotaInState(c *C) {
 		"group-2":  grp3,
 	})
 }
+
+func (s *servicestateQuotasSuite) TestPatchQuotasAssignsDiskProjectID(c *C) {
+	st := s.state
+	st.Lock()
+	defer st.Unlock()
+
+	grp1, err := quota.NewGroup("grp1", quota.NewResourcesBuilder().WithDiskLimit(quantity.SizeGiB).Build())
+	c.Assert(err, IsNil)
+	grp2, err := quota.NewGroup("grp2", quota.NewResourcesBuilder().WithMemoryLimit(quantity.SizeGiB).Build())
+	c.Assert(err, IsNil)
+	grp3, err := quota.NewGroup("grp3", quota.NewResourcesBuilder().WithDiskLimit(quantity.SizeGiB).Build())
+	c.Assert(err, IsNil)
+
+	_, err = internal.PatchQuotas(st, grp1, grp2, grp3)
+	c.Assert(err, IsNil)
+	c.Check(grp1.DiskLimit.ProjectID, Equals, uint32(0x100000))
+	c.Check(grp2.DiskLimit, IsNil)
+	c.Check(grp3.DiskLimit.ProjectID, Equals, uint32(0x100001))
+
+	// project IDs are kept, and freed ones get reused
+	grp1.DiskLimit.ProjectID = 0x100005
+	err = grp2.UpdateQuotaLimits(quota.NewResourcesBuilder().WithDiskLimit(quantity.SizeGiB).Build())
+	c.Assert(err, IsNil)
+	allGrps, err := internal.PatchQuotas(st, grp1, grp2)
+	c.Assert(err, IsNil)
+	c.Check(allGrps["grp1"].DiskLimit.ProjectID, Equals, uint32(0x100005))
+	c.Check(allGrps["grp2"].DiskLimit.ProjectID, Equals, uint32(0x100000))
+	c.Check(allGrps["grp3"].DiskLimit.ProjectID, Equals, uint32(0x100001))
+
+	// and they are persisted in the state
+	allGrps, err = internal.AllQuotas(st)
+	c.Assert(err, IsNil)
+	c.Check(allGrps["grp2"].DiskLimit, DeepEquals, &quota.GroupQuotaDisk{Size: quantity.SizeGiB, ProjectID: 0x100000})
+}
@@ -117,6 +117,14 @@ func verifyQuotaRequirements(st *state.State, resourceLimits quota.Resources) er
 			return err
 		}
 	}
+
+	// Disk quotas are experimental as well, the filesystem support is checked
+	// separately as part of CheckFeatureRequirements
+	if resourceLimits.Disk != nil {
+		if err := isExperimentalQuotasAvailable(st, "disk"); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -379,6 +379,30 @@ func (s *quotaControlSuite) TestCreateQuotaJournalEnabled(c *C) {
 	c.Assert(err, IsNil)
 }
 
+func (s *quotaControlSuite) TestCreateQuotaDiskNotEnabled(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	tr := config.NewTransaction(s.state)
+	tr.Set("core", "experimental.quota-groups", false)
+	tr.Commit()
+
+	quotaConstraits := quota.NewResourcesBuilder().WithDiskLimit(quantity.SizeGiB).Build()
+	_, err := servicestate.CreateQuota(s.state, "foo", servicestate.CreateQuotaOptions{
+		ResourceLimits: quotaConstraits,
+	})
+	c.Assert(err, ErrorMatches, `disk quota options are experimental - test it by setting 'experimental.quota-groups' to true`)
+
+	tr = config.NewTransaction(s.state)
+	tr.Set("core", "experimental.quota-groups", true)
+	tr.Commit()
+
+	_, err = servicestate.CreateQuota(s.state, "foo", servicestate.CreateQuotaOptions{
+		ResourceLimits: quotaConstraits,
+	})
+	c.Assert(err, IsNil)
+}
+
 func (s *quotaControlSuite) TestCreateQuotaPrecond(c *C) {
 	st := s.state
 	st.Lock()
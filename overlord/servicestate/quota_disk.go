@@ -0,0 +1,118 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package servicestate
+
+import (
+	"os"
+	"sort"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/osutil/fsquota"
+	"github.com/snapcore/snapd/snap/quota"
+	"github.com/snapcore/snapd/snapdenv"
+)
+
+var (
+	fsquotaMountPoint      = fsquota.MountPoint
+	fsquotaSetProjectID    = fsquota.SetProjectID
+	fsquotaSetProjectLimit = fsquota.SetProjectLimit
+)
+
+// ensureDiskQuotaForGroup applies the disk quota affecting the given group to
+// the system. The data directories of all snaps subject to the quota are
+// assigned to the filesystem project of the group owning the quota, and the
+// limit is set for that project on each filesystem involved. If the group was
+// removed, its snaps are released from the project instead, and the limit is
+// cleared if the group was the owner of the quota.
+// Like ensureSnapServicesForGroup this is idempotent and can be called
+// multiple times for the same change.
+func ensureDiskQuotaForGroup(grp *quota.Group, allGrps map[string]*quota.Group) error {
+	if snapdenv.Preseeding() {
+		// the data directories of snaps are only created on first boot
+		return nil
+	}
+
+	diskGrp := grp.DiskQuotaGroup()
+	if diskGrp == nil {
+		return nil
+	}
+
+	_, exists := allGrps[grp.Name]
+	mountPoints := make(map[string]bool)
+	mountPoint, err := fsquotaMountPoint(dirs.SnapDataDir)
+	if err != nil {
+		return err
+	}
+	mountPoints[mountPoint] = true
+
+	projectID := diskGrp.DiskLimit.ProjectID
+	snaps := diskGrp.AllSnaps()
+	if !exists {
+		projectID = 0
+		snaps = grp.AllSnaps()
+	}
+	for _, snapName := range snaps {
+		paths, err := diskGrp.DiskQuotaDirs(snapName)
+		if err != nil {
+			return err
+		}
+		if exists {
+			// create the snap data directory if the snap was never
+			// run, so that new revisions inherit the project
+			if err := os.MkdirAll(paths[0], 0755); err != nil {
+				return err
+			}
+		}
+		for _, path := range paths {
+			if !osutil.IsDirectory(path) {
+				continue
+			}
+			if err := fsquotaSetProjectID(path, projectID); err != nil {
+				return err
+			}
+			mountPoint, err := fsquotaMountPoint(path)
+			if err != nil {
+				return err
+			}
+			mountPoints[mountPoint] = true
+		}
+	}
+
+	var limit uint64
+	switch {
+	case exists:
+		limit = uint64(diskGrp.DiskLimit.Size)
+	case diskGrp != grp:
+		// only a sub-group was removed, the limit of its parent stays
+		return nil
+	}
+	sortedMountPoints := make([]string, 0, len(mountPoints))
+	for mountPoint := range mountPoints {
+		sortedMountPoints = append(sortedMountPoints, mountPoint)
+	}
+	sort.Strings(sortedMountPoints)
+	for _, mountPoint := range sortedMountPoints {
+		if err := fsquotaSetProjectLimit(mountPoint, diskGrp.DiskLimit.ProjectID, limit); err != nil {
+			return err
+		}
+	}
+	return nil
+}
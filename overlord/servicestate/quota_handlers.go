@@ -135,6 +135,15 @@ func (m *ServiceManager) doQuotaControl(t *state.Task, _ *tomb.Tomb) error {
 			return err
 		}
 
+		// assigning data directories to a project is expensive for
+		// snaps with lots of data, only do so if anything changed
+		// that affects the disk quota
+		if qc.Action != "update" || qc.ResourceLimits.Disk != nil || len(qc.AddSnaps) > 0 {
+			if err := ensureDiskQuotaForGroup(grp, allGrps); err != nil {
+				return err
+			}
+		}
+
 		// All persistent modifications to disk are made and the
 		// modifications to state will be committed by the
 		// unlocking at the end of this task. If snapd gets
@@ -270,6 +279,10 @@ func (m *ServiceManager) doQuotaAddSnap(t *state.Task, _ *tomb.Tomb) error {
 		return err
 	}
 
+	if err := ensureDiskQuotaForGroup(grp, allGrps); err != nil {
+		return err
+	}
+
 	// ensure that if any services are affected, they get their profiles
 	// refreshed immediately as a part of the install change.
 	if len(servicesAffected) > 0 && grp.JournalLimit != nil {
@@ -497,6 +510,11 @@ func validateQuotaLimitsChange(grp *quota.Group, oldLimits, newLimits quota.Reso
 	if newLimits.Journal != nil && len(grp.Services) > 0 {
 		return fmt.Errorf("journal quotas are not supported for individual services")
 	}
+	// Services share the data directories of their snap, so the disk
+	// quota can only be set on the group of the snap.
+	if newLimits.Disk != nil && len(grp.Services) > 0 {
+		return fmt.Errorf("disk quotas are not supported for individual services")
+	}
 	if err := oldLimits.ValidateChange(newLimits); err != nil {
 		return err
 	}
@@ -24,6 +24,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"gopkg.in/check.v1"
@@ -32,6 +33,7 @@ import (
 
 	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/gadget/quantity"
+	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/overlord/servicestate"
 	"github.com/snapcore/snapd/overlord/servicestate/servicestatetest"
 	"github.com/snapcore/snapd/overlord/snapstate"
@@ -419,6 +421,147 @@ func (s *quotaHandlersSuite) TestDoQuotaControlRemove(c *C) {
 	checkQuotaState(c, st, nil)
 }
 
+type fsquotaCall struct {
+	op    string
+	path  string
+	id    uint32
+	limit uint64
+}
+
+func (s *quotaHandlersSuite) mockFsquota(c *C) *[]fsquotaCall {
+	var calls []fsquotaCall
+	restore := servicestate.MockFsquota(func(path string) (string, error) {
+		if strings.HasPrefix(path, filepath.Join(dirs.GlobalRootDir, "home")) {
+			return "/home", nil
+		}
+		return "/", nil
+	}, func(path string, id uint32) error {
+		calls = append(calls, fsquotaCall{op: "set-project", path: path, id: id})
+		return nil
+	}, func(mountPoint string, id uint32, limit uint64) error {
+		calls = append(calls, fsquotaCall{op: "set-limit", path: mountPoint, id: id, limit: limit})
+		return nil
+	})
+	s.AddCleanup(restore)
+	return &calls
+}
+
+func (s *quotaHandlersSuite) TestDoQuotaControlCreateRemoveDisk(c *C) {
+	r := s.mockSystemctlCalls(c, join(
+		// CreateQuota for foo-group
+		systemctlCallsForCreateQuota("foo-group", "test-snap"),
+
+		// doQuotaControl handler which removes the group
+		[]expectedSystemctl{{expArgs: []string{"daemon-reload"}}},
+		systemctlCallsForSliceStop("foo-group"),
+		[]expectedSystemctl{{expArgs: []string{"daemon-reload"}}},
+		systemctlCallsForServiceRestart("test-snap"),
+	))
+	defer r()
+	calls := s.mockFsquota(c)
+
+	userDataDir := filepath.Join(dirs.GlobalRootDir, "home/user/snap/test-snap")
+	c.Assert(os.MkdirAll(userDataDir, 0755), IsNil)
+
+	st := s.state
+	st.Lock()
+	defer st.Unlock()
+
+	// setup the snap so it exists
+	snapstate.Set(s.state, "test-snap", s.testSnapState)
+	snaptest.MockSnapCurrent(c, testYaml, s.testSnapSideInfo)
+
+	limits := quota.NewResourcesBuilder().WithMemoryLimit(quantity.SizeGiB).WithDiskLimit(quantity.SizeGiB).WithDiskUserData().Build()
+	qcs := servicestate.QuotaControlAction{
+		Action:         "create",
+		QuotaName:      "foo-group",
+		ResourceLimits: limits,
+		AddSnaps:       []string{"test-snap"},
+	}
+
+	err := s.callDoQuotaControl(&qcs)
+	c.Assert(err, IsNil)
+
+	checkQuotaState(c, st, map[string]quotaGroupState{
+		"foo-group": {
+			ResourceLimits: limits,
+			Snaps:          []string{"test-snap"},
+		},
+	})
+	grp, err := servicestate.GetQuota(st, "foo-group")
+	c.Assert(err, IsNil)
+	c.Check(grp.DiskLimit.ProjectID, Equals, uint32(0x100000))
+
+	// the snap data directory is created so new revisions inherit the project
+	snapDataDir := filepath.Join(dirs.SnapDataDir, "test-snap")
+	c.Check(osutil.IsDirectory(snapDataDir), Equals, true)
+	c.Check(*calls, DeepEquals, []fsquotaCall{
+		{op: "set-project", path: snapDataDir, id: 0x100000},
+		{op: "set-project", path: userDataDir, id: 0x100000},
+		{op: "set-limit", path: "/", id: 0x100000, limit: uint64(quantity.SizeGiB)},
+		{op: "set-limit", path: "/home", id: 0x100000, limit: uint64(quantity.SizeGiB)},
+	})
+	*calls = nil
+
+	// remove quota group
+	qcs = servicestate.QuotaControlAction{
+		Action:    "remove",
+		QuotaName: "foo-group",
+	}
+
+	err = s.callDoQuotaControl(&qcs)
+	c.Assert(err, IsNil)
+
+	checkQuotaState(c, st, nil)
+	c.Check(*calls, DeepEquals, []fsquotaCall{
+		{op: "set-project", path: snapDataDir, id: 0},
+		{op: "set-project", path: userDataDir, id: 0},
+		{op: "set-limit", path: "/", id: 0x100000, limit: 0},
+		{op: "set-limit", path: "/home", id: 0x100000, limit: 0},
+	})
+}
+
+func (s *quotaHandlersSuite) TestDoQuotaControlDiskQuotaError(c *C) {
+	r := s.mockSystemctlCalls(c, systemctlCallsForCreateQuota("foo-group"))
+	defer r()
+	restore := servicestate.MockFsquota(func(path string) (string, error) {
+		return "/", nil
+	}, func(path string, id uint32) error {
+		return fmt.Errorf("cannot set project ID of %q: Operation not supported", path)
+	}, nil)
+	defer restore()
+
+	st := s.state
+	st.Lock()
+	defer st.Unlock()
+
+	snapstate.Set(s.state, "test-snap", s.testSnapState)
+	snaptest.MockSnapCurrent(c, testYaml, s.testSnapSideInfo)
+
+	qcs := servicestate.QuotaControlAction{
+		Action:         "create",
+		QuotaName:      "foo-group",
+		ResourceLimits: quota.NewResourcesBuilder().WithMemoryLimit(quantity.SizeGiB).WithDiskLimit(quantity.SizeGiB).Build(),
+		AddSnaps:       []string{"test-snap"},
+	}
+
+	err := s.callDoQuotaControl(&qcs)
+	c.Assert(err, ErrorMatches, `cannot set project ID of ".*/var/snap/test-snap": Operation not supported`)
+}
+
+func (s *quotaHandlersSuite) TestQuotaSnapFailToAddDiskQuotaToGroupWithServices(c *C) {
+	st := s.state
+	st.Lock()
+	defer st.Unlock()
+
+	grp, err := quota.NewGroup("foo", quota.NewResourcesBuilder().WithMemoryLimit(quantity.SizeGiB).Build())
+	c.Assert(err, IsNil)
+	grp.Services = []string{"test-snap.svc1"}
+
+	err = servicestate.QuotaUpdateGroupLimits(grp, quota.NewResourcesBuilder().WithDiskLimit(quantity.SizeGiB).Build())
+	c.Assert(err, ErrorMatches, `cannot update limits for group "foo": disk quotas are not supported for individual services`)
+}
+
 func (s *quotaHandlersSuite) TestDoQuotaControlRemoveRestartOK(c *C) {
 	// test a situation where because of restart the task is reentered
 	r := s.mockSystemctlCalls(c, join(
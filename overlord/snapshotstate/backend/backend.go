@@ -31,6 +31,7 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
+	"os/exec"
 	"path"
 	"path/filepath"
 	"regexp"
@@ -302,12 +303,42 @@ func EstimateSnapshotSize(si *snap.Info, usernames []string, dirOpts *dirs.SnapD
 	return total, nil
 }
 
+// SaveOptions holds options for Save.
+type SaveOptions struct {
+	// Deduplicate stores the snapshot archives in the chunk store shared by
+	// all snapshots, instead of in the snapshot file itself.
+	Deduplicate bool
+	// Encryption, if set, is the key the snapshot archives are encrypted
+	// with. Encrypted snapshots are never deduplicated.
+	Encryption *EncryptionKey
+}
+
+// archiveOptions control how archives are added to a snapshot.
+type archiveOptions struct {
+	deduplicate bool
+	// dataKey, if set, is the key to encrypt the archives with
+	dataKey []byte
+}
+
 // Save a snapshot
-func Save(ctx context.Context, id uint64, si *snap.Info, cfg map[string]interface{}, usernames []string, dynSnapshotOpts *snap.SnapshotOptions, dirOpts *dirs.SnapDirOptions) (*client.Snapshot, error) {
+func Save(ctx context.Context, id uint64, si *snap.Info, cfg map[string]interface{}, usernames []string, dynSnapshotOpts *snap.SnapshotOptions, dirOpts *dirs.SnapDirOptions, saveOpts *SaveOptions) (*client.Snapshot, error) {
 	if err := os.MkdirAll(dirs.SnapshotsDir, 0700); err != nil {
 		return nil, err
 	}
 
+	if saveOpts == nil {
+		saveOpts = &SaveOptions{}
+	}
+	archiveOpts := &archiveOptions{
+		deduplicate: saveOpts.Deduplicate && saveOpts.Encryption == nil,
+	}
+	if archiveOpts.deduplicate {
+		// keep the chunks we add from being garbage collected until the
+		// snapshot referencing them is in place
+		chunkStoreMu.RLock()
+		defer chunkStoreMu.RUnlock()
+	}
+
 	snapshot := &client.Snapshot{
 		SetID:    id,
 		Snap:     si.InstanceName(),
@@ -336,6 +367,13 @@ func Save(ctx context.Context, id uint64, si *snap.Info, cfg map[string]interfac
 		}
 	}
 
+	if saveOpts.Encryption != nil {
+		snapshot.Encryption, archiveOpts.dataKey, err = newEncryption(saveOpts.Encryption)
+		if err != nil {
+			return nil, fmt.Errorf("cannot encrypt snapshot: %v", err)
+		}
+	}
+
 	aw, err := osutil.NewAtomicFile(Filename(snapshot), 0600, 0, osutil.NoChown, osutil.NoChown)
 	if err != nil {
 		return nil, err
@@ -347,7 +385,7 @@ func Save(ctx context.Context, id uint64, si *snap.Info, cfg map[string]interfac
 	defer w.Close() // note this does not close the file descriptor (that's done by hand on the atomic writer, above)
 	savingUserData := false
 	baseDataDir := snap.BaseDataDir(si.InstanceName())
-	if err := addSnapDirToZip(ctx, snapshot, w, "root", archiveName, baseDataDir, savingUserData, snapshotOptions.Exclude); err != nil {
+	if err := addSnapDirToZip(ctx, snapshot, w, "root", archiveName, baseDataDir, savingUserData, snapshotOptions.Exclude, archiveOpts); err != nil {
 		return nil, err
 	}
 
@@ -359,7 +397,7 @@ func Save(ctx context.Context, id uint64, si *snap.Info, cfg map[string]interfac
 	savingUserData = true
 	for _, usr := range users {
 		snapDataDir := filepath.Dir(si.UserDataDir(usr.HomeDir, dirOpts))
-		if err := addSnapDirToZip(ctx, snapshot, w, usr.Username, userArchiveName(usr), snapDataDir, savingUserData, snapshotOptions.Exclude); err != nil {
+		if err := addSnapDirToZip(ctx, snapshot, w, usr.Username, userArchiveName(usr), snapDataDir, savingUserData, snapshotOptions.Exclude, archiveOpts); err != nil {
 			return nil, err
 		}
 	}
@@ -400,7 +438,7 @@ var isTesting = snapdenv.Testing()
 // addSnapDirToZip adds the 'common' and the 'rev' revisioned dir under 'snapDir'
 // to the snapshot. If one doesn't exist, it's ignored. If none exists, the
 // operation is skipped.
-func addSnapDirToZip(ctx context.Context, snapshot *client.Snapshot, w *zip.Writer, username, entry, snapDir string, savingUserData bool, excludePaths []string) error {
+func addSnapDirToZip(ctx context.Context, snapshot *client.Snapshot, w *zip.Writer, username, entry, snapDir string, savingUserData bool, excludePaths []string, opts *archiveOptions) error {
 	paths, err := pathsForSnapshot(snapDir, snapshot)
 	if err != nil {
 		return err
@@ -443,24 +481,31 @@ func addSnapDirToZip(ctx context.Context, snapshot *client.Snapshot, w *zip.Writ
 		expExcludePaths = append(expExcludePaths, expandedPath)
 	}
 
-	return addToZip(ctx, snapshot, w, username, entry, paths, expExcludePaths)
-}
-
-// addToZip adds 'paths' to the snapshot. tar will change into the paths' parent
-// directory before creating the archive so that parent dirs are not added.
-func addToZip(ctx context.Context, snapshot *client.Snapshot, w *zip.Writer, username, entry string, paths []string, excludePaths []string) error {
-	archiveWriter, err := w.CreateHeader(&zip.FileHeader{Name: entry})
-	if err != nil {
-		return err
+	if opts == nil {
+		opts = &archiveOptions{}
+	}
+	if opts.deduplicate {
+		return addToChunkStore(ctx, snapshot, w, username, entry, paths, expExcludePaths)
 	}
+	return addToZip(ctx, snapshot, w, username, entry, paths, expExcludePaths, opts.dataKey)
+}
 
+// tarCreateArgs returns the arguments for tar to create an archive of 'paths'.
+// tar will change into the paths' parent directory before creating the
+// archive so that parent dirs are not added.
+func tarCreateArgs(paths []string, excludePaths []string, compress bool) []string {
 	tarArgs := []string{
 		"--create",
-		"--sparse", "--gzip",
+		"--sparse",
+	}
+	if compress {
+		tarArgs = append(tarArgs, "--gzip")
+	}
+	tarArgs = append(tarArgs,
 		"--format", "gnu",
 		"--anchored",
 		"--no-wildcards-match-slash",
-	}
+	)
 
 	for _, path := range excludePaths {
 		tarArgs = append(tarArgs, fmt.Sprintf("--exclude=%s", path))
@@ -472,12 +517,79 @@ func addToZip(ctx context.Context, snapshot *client.Snapshot, w *zip.Writer, use
 		tarArgs = append(tarArgs, "--directory", parent, dir)
 	}
 
+	return tarArgs
+}
+
+// addToZip adds 'paths' to the snapshot, encrypting them with dataKey if set.
+func addToZip(ctx context.Context, snapshot *client.Snapshot, w *zip.Writer, username, entry string, paths []string, excludePaths []string, dataKey []byte) error {
+	archiveWriter, err := w.CreateHeader(&zip.FileHeader{Name: entry})
+	if err != nil {
+		return err
+	}
+
 	var sz osutil.Sizer
 	hasher := crypto.SHA3_384.New()
 
-	cmd := tarAsUser(username, tarArgs...)
-	cmd.Stdout = io.MultiWriter(archiveWriter, hasher, &sz)
+	// note the hash and size are those of the archive as stored
+	out := io.MultiWriter(archiveWriter, hasher, &sz)
+	var encWriter io.WriteCloser
+	if dataKey != nil {
+		encWriter, err = newEncryptingWriter(out, dataKey)
+		if err != nil {
+			return err
+		}
+		out = encWriter
+	}
+
+	cmd := tarAsUser(username, tarCreateArgs(paths, excludePaths, true)...)
+	cmd.Stdout = out
+	if err := runTar(ctx, cmd); err != nil {
+		return err
+	}
+	if encWriter != nil {
+		if err := encWriter.Close(); err != nil {
+			return err
+		}
+	}
+
+	snapshot.SHA3_384[entry] = fmt.Sprintf("%x", hasher.Sum(nil))
+	snapshot.Size += sz.Size()
+
+	return nil
+}
+
+// addToChunkStore adds 'paths' to the chunk store, and the list of the chunks
+// making up the archive to the snapshot.
+func addToChunkStore(ctx context.Context, snapshot *client.Snapshot, w *zip.Writer, username, entry string, paths []string, excludePaths []string) error {
+	cw := newChunkWriter(ctx)
 
+	// the chunks are compressed individually
+	cmd := tarAsUser(username, tarCreateArgs(paths, excludePaths, false)...)
+	cmd.Stdout = cw
+	if err := runTar(ctx, cmd); err != nil {
+		return err
+	}
+	if err := cw.Close(); err != nil {
+		return err
+	}
+
+	listWriter, err := w.Create(entry + chunkListSuffix)
+	if err != nil {
+		return err
+	}
+	if err := json.NewEncoder(listWriter).Encode(&cw.list); err != nil {
+		return err
+	}
+
+	snapshot.SHA3_384[entry] = cw.Sum()
+	snapshot.Size += cw.list.Size
+
+	return nil
+}
+
+// runTar runs the given tar command, turning what it writes to stderr into
+// the error if it fails.
+func runTar(ctx context.Context, cmd *exec.Cmd) error {
 	// keep (at most) the last 5 non-empty lines of what 'tar' writes to stderr
 	// (those are the most likely contain the reason for fatal errors)
 	matchCounter := &strutil.MatchCounter{
@@ -505,9 +617,6 @@ func addToZip(ctx context.Context, snapshot *client.Snapshot, w *zip.Writer, use
 		return fmt.Errorf("tar failed: %v", err)
 	}
 
-	snapshot.SHA3_384[entry] = fmt.Sprintf("%x", hasher.Sum(nil))
-	snapshot.Size += sz.Size()
-
 	return nil
 }
 
@@ -972,6 +1081,21 @@ func NewSnapshotExport(ctx context.Context, setID uint64) (se *SnapshotExport, e
 			if f == nil {
 				return fmt.Errorf("cannot open file from descriptor %d", fd)
 			}
+			// the export needs to be self-contained, so bring
+			// the archives of deduplicated snapshots back in
+			deduplicated, err := isDeduplicated(f)
+			if err != nil {
+				f.Close()
+				return err
+			}
+			if deduplicated {
+				rf, err := rehydrate(f)
+				f.Close()
+				if err != nil {
+					return fmt.Errorf("cannot rehydrate snapshot %q: %v", reader.Name(), err)
+				}
+				f = rf
+			}
 			snapshotFiles = append(snapshotFiles, f)
 		}
 		return nil
@@ -31,6 +31,7 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"math/rand"
 	"os"
 	"os/exec"
 	"os/user"
@@ -41,6 +42,7 @@ import (
 	"testing"
 	"time"
 
+	"golang.org/x/crypto/curve25519"
 	"gopkg.in/check.v1"
 
 	"github.com/snapcore/snapd/client"
@@ -451,7 +453,7 @@ func (s *snapshotSuite) TestIterSetIDoverride(c *check.C) {
 	info := &snap.Info{SideInfo: snap.SideInfo{RealName: "hello-snap", Revision: snap.R(42), SnapID: "hello-id"}, Version: "v1.33", Epoch: epoch}
 	cfg := map[string]interface{}{"some-setting": false}
 
-	shw, err := backend.Save(context.TODO(), 12, info, cfg, []string{"snapuser"}, nil, nil)
+	shw, err := backend.Save(context.TODO(), 12, info, cfg, []string{"snapuser"}, nil, nil, nil)
 	c.Assert(err, check.IsNil)
 	c.Check(shw.SetID, check.Equals, uint64(12))
 
@@ -569,8 +571,8 @@ func (s *snapshotSuite) TestAddDirToZipBails(c *check.C) {
 	defer restore()
 	savingUserData := false
 	// note as the zip is nil this would panic if it didn't bail
-	c.Check(backend.AddSnapDirToZip(nil, snapshot, nil, "", "an/entry", filepath.Join(s.root, "nonexistent"), savingUserData, nil), check.IsNil)
-	c.Check(backend.AddSnapDirToZip(nil, snapshot, nil, "", "an/entry", "/etc/passwd", savingUserData, nil), check.IsNil)
+	c.Check(backend.AddSnapDirToZip(nil, snapshot, nil, "", "an/entry", filepath.Join(s.root, "nonexistent"), savingUserData, nil, nil), check.IsNil)
+	c.Check(backend.AddSnapDirToZip(nil, snapshot, nil, "", "an/entry", "/etc/passwd", savingUserData, nil, nil), check.IsNil)
 	c.Check(buf.String(), check.Matches, "(?m).* is does not exist.*")
 }
 
@@ -586,7 +588,7 @@ func (s *snapshotSuite) TestAddDirToZipTarFails(c *check.C) {
 	var buf bytes.Buffer
 	z := zip.NewWriter(&buf)
 	savingUserData := false
-	c.Assert(backend.AddSnapDirToZip(ctx, &client.Snapshot{Revision: rev}, z, "", "an/entry", s.root, savingUserData, nil), check.ErrorMatches, ".* context canceled")
+	c.Assert(backend.AddSnapDirToZip(ctx, &client.Snapshot{Revision: rev}, z, "", "an/entry", s.root, savingUserData, nil, nil), check.ErrorMatches, ".* context canceled")
 }
 
 func (s *snapshotSuite) TestAddDirToZip(c *check.C) {
@@ -603,7 +605,7 @@ func (s *snapshotSuite) TestAddDirToZip(c *check.C) {
 		Revision: rev,
 	}
 	savingUserData := false
-	c.Assert(backend.AddSnapDirToZip(context.Background(), snapshot, z, "", "an/entry", s.root, savingUserData, nil), check.IsNil)
+	c.Assert(backend.AddSnapDirToZip(context.Background(), snapshot, z, "", "an/entry", s.root, savingUserData, nil, nil), check.IsNil)
 	z.Close() // write out the central directory
 
 	c.Check(snapshot.SHA3_384, check.HasLen, 1)
@@ -681,7 +683,7 @@ func (s *snapshotSuite) TestAddDirToZipExclusions(c *check.C) {
 	} {
 		testLabel := check.Commentf("%s/%v", testData.excludes, testData.savingUserData)
 
-		err := backend.AddSnapDirToZip(context.Background(), snapshot, z, "", "an/entry", s.root, testData.savingUserData, testData.excludes)
+		err := backend.AddSnapDirToZip(context.Background(), snapshot, z, "", "an/entry", s.root, testData.savingUserData, testData.excludes, nil)
 		c.Check(err, check.ErrorMatches, "tar failed.*")
 		c.Check(tarArgs, check.DeepEquals, testData.expectedArgs, testLabel)
 	}
@@ -733,7 +735,7 @@ func (s *snapshotSuite) testHappyRoundtrip(c *check.C, marker string) {
 		return statSnapshotOpts, nil
 	})()
 
-	shw, err := backend.Save(context.TODO(), shID, info, cfg, []string{"snapuser"}, dynSnapshotOpts, nil)
+	shw, err := backend.Save(context.TODO(), shID, info, cfg, []string{"snapuser"}, dynSnapshotOpts, nil, nil)
 	c.Assert(err, check.IsNil)
 	c.Check(shw.SetID, check.Equals, shID)
 	c.Check(shw.Snap, check.Equals, info.InstanceName())
@@ -811,7 +813,7 @@ func (s *snapshotSuite) TestOpenSetIDoverride(c *check.C) {
 	info := &snap.Info{SideInfo: snap.SideInfo{RealName: "hello-snap", Revision: snap.R(42), SnapID: "hello-id"}, Version: "v1.33", Epoch: epoch}
 	cfg := map[string]interface{}{"some-setting": false}
 
-	shw, err := backend.Save(context.TODO(), 12, info, cfg, []string{"snapuser"}, nil, nil)
+	shw, err := backend.Save(context.TODO(), 12, info, cfg, []string{"snapuser"}, nil, nil, nil)
 	c.Assert(err, check.IsNil)
 	c.Check(shw.SetID, check.Equals, uint64(12))
 
@@ -835,7 +837,7 @@ func (s *snapshotSuite) TestRestoreRoundtripDifferentRevision(c *check.C) {
 	info := &snap.Info{SideInfo: snap.SideInfo{RealName: "hello-snap", Revision: snap.R(42), SnapID: "hello-id"}, Version: "v1.33", Epoch: epoch}
 	shID := uint64(12)
 
-	shw, err := backend.Save(context.TODO(), shID, info, nil, []string{"snapuser"}, nil, nil)
+	shw, err := backend.Save(context.TODO(), shID, info, nil, []string{"snapuser"}, nil, nil, nil)
 	c.Assert(err, check.IsNil)
 	c.Check(shw.Revision, check.Equals, info.Revision)
 
@@ -875,6 +877,35 @@ func (s *snapshotSuite) TestRestoreRoundtripDifferentRevision(c *check.C) {
 	c.Check(diff().Run(), check.IsNil)
 }
 
+func (s *snapshotSuite) TestRestoreIntoOtherInstance(c *check.C) {
+	defer backend.MockUsersForUsernames(func([]string, *dirs.SnapDirOptions) ([]*user.User, error) {
+		return nil, nil
+	})()
+	logger.SimpleSetup()
+
+	info := &snap.Info{SideInfo: snap.SideInfo{RealName: "hello-snap", Revision: snap.R(42), SnapID: "hello-id"}, Version: "v1.33"}
+	shw, err := backend.Save(context.TODO(), 12, info, nil, nil, nil, nil, nil)
+	c.Assert(err, check.IsNil)
+
+	shr, err := backend.Open(backend.Filename(shw), backend.ExtractFnameSetID)
+	c.Assert(err, check.IsNil)
+	defer shr.Close()
+
+	rs, err := shr.RestoreInto(context.TODO(), "hello-snap_test", snap.R(17), nil, logger.Debugf, nil)
+	c.Assert(err, check.IsNil)
+	rs.Cleanup()
+
+	// the data is in the other instance, at its current revision
+	si := snap.MinimalPlaceInfo("hello-snap_test", snap.R(17))
+	c.Check(filepath.Join(si.DataDir(), "foo"), testutil.FileEquals, "versioned system canary\n")
+	c.Check(filepath.Join(si.CommonDataDir(), "bar"), testutil.FileEquals, "common system canary\n")
+
+	// and the original instance is untouched
+	orig := snap.MinimalPlaceInfo("hello-snap", snap.R(42))
+	c.Check(filepath.Join(orig.DataDir(), "foo"), testutil.FileEquals, "versioned system canary\n")
+	c.Check(filepath.Join(dirs.SnapDataDir, "hello-snap", "17"), testutil.FileAbsent)
+}
+
 func (s *snapshotSuite) TestPickUserWrapperRunuser(c *check.C) {
 	n := 0
 	defer backend.MockExecLookPath(func(s string) (string, error) {
@@ -1110,7 +1141,7 @@ func (s *snapshotSuite) TestImportDuplicated(c *check.C) {
 	info := &snap.Info{SideInfo: snap.SideInfo{RealName: "hello-snap", Revision: snap.R(42), SnapID: "hello-id"}, Version: "v1.33", Epoch: epoch}
 	shID := uint64(12)
 
-	shw, err := backend.Save(ctx, shID, info, nil, []string{"snapuser"}, nil, nil)
+	shw, err := backend.Save(ctx, shID, info, nil, []string{"snapuser"}, nil, nil, nil)
 	c.Assert(err, check.IsNil)
 
 	export, err := backend.NewSnapshotExport(ctx, shw.SetID)
@@ -1140,7 +1171,7 @@ func (s *snapshotSuite) TestImportExportRoundtrip(c *check.C) {
 	cfg := map[string]interface{}{"some-setting": false}
 	shID := uint64(12)
 
-	shw, err := backend.Save(ctx, shID, info, cfg, []string{"snapuser"}, nil, nil)
+	shw, err := backend.Save(ctx, shID, info, cfg, []string{"snapuser"}, nil, nil, nil)
 	c.Assert(err, check.IsNil)
 	c.Check(shw.SetID, check.Equals, shID)
 
@@ -1301,7 +1332,7 @@ func (s *snapshotSuite) TestExportTwice(c *check.C) {
 	}
 	// create a snapshot
 	shID := uint64(12)
-	_, err := backend.Save(context.TODO(), shID, info, nil, []string{"snapuser"}, nil, nil)
+	_, err := backend.Save(context.TODO(), shID, info, nil, []string{"snapuser"}, nil, nil, nil)
 	c.Check(err, check.IsNil)
 
 	// content.json + num_files + export.json + footer
@@ -1648,7 +1679,7 @@ func (s *snapshotSuite) TestSnapshotExportContentHash(c *check.C) {
 		Version: "v1.33",
 	}
 	shID := uint64(12)
-	shw, err := backend.Save(ctx, shID, info, nil, []string{"snapuser"}, nil, nil)
+	shw, err := backend.Save(ctx, shID, info, nil, []string{"snapuser"}, nil, nil, nil)
 	c.Check(err, check.IsNil)
 
 	// now export it
@@ -1670,10 +1701,375 @@ func (s *snapshotSuite) TestSnapshotExportContentHash(c *check.C) {
 		},
 		Version: "v1.33",
 	}
-	shw, err = backend.Save(ctx, shID, info, nil, []string{"snapuser"}, nil, nil)
+	shw, err = backend.Save(ctx, shID, info, nil, []string{"snapuser"}, nil, nil, nil)
 	c.Check(err, check.IsNil)
 
 	export3, err := backend.NewSnapshotExport(ctx, shw.SetID)
 	c.Assert(err, check.IsNil)
 	c.Check(export.ContentHash(), check.Not(check.DeepEquals), export3.ContentHash())
 }
+
+func (s *snapshotSuite) saveDeduplicated(c *check.C, shID uint64, rev snap.Revision) *client.Snapshot {
+	info := &snap.Info{SideInfo: snap.SideInfo{RealName: "hello-snap", Revision: rev, SnapID: "hello-id"}, Version: "v1.33"}
+	shw, err := backend.Save(context.TODO(), shID, info, nil, nil, nil, nil, &backend.SaveOptions{Deduplicate: true})
+	c.Assert(err, check.IsNil)
+	return shw
+}
+
+func zipMemberNames(c *check.C, fn string) []string {
+	arch, err := zip.OpenReader(fn)
+	c.Assert(err, check.IsNil)
+	defer arch.Close()
+	var names []string
+	for _, fh := range arch.File {
+		names = append(names, fh.Name)
+	}
+	return names
+}
+
+func chunkFiles(c *check.C) []string {
+	chunks, err := filepath.Glob(filepath.Join(dirs.SnapshotsDir, "chunks", "*", "*"))
+	c.Assert(err, check.IsNil)
+	return chunks
+}
+
+func (s *snapshotSuite) TestSaveDeduplicatedRoundtrip(c *check.C) {
+	defer backend.MockUsersForUsernames(func([]string, *dirs.SnapDirOptions) ([]*user.User, error) {
+		return nil, nil
+	})()
+
+	shw := s.saveDeduplicated(c, 12, snap.R(42))
+	c.Check(hashkeys(shw), check.DeepEquals, []string{"archive.tgz"})
+	c.Check(shw.Size > 0, check.Equals, true)
+
+	// the archive is not in the snapshot file itself
+	fn := backend.Filename(shw)
+	c.Check(zipMemberNames(c, fn), check.DeepEquals, []string{"archive.tgz.chunks", "meta.json", "meta.sha3_384"})
+	c.Check(chunkFiles(c), check.HasLen, 1)
+
+	shr, err := backend.Open(fn, backend.ExtractFnameSetID)
+	c.Assert(err, check.IsNil)
+	defer shr.Close()
+	c.Check(shr.SHA3_384, check.DeepEquals, shw.SHA3_384)
+	c.Check(shr.Check(context.TODO(), nil), check.IsNil)
+
+	si := snap.MinimalPlaceInfo("hello-snap", snap.R(42))
+	c.Assert(os.RemoveAll(si.DataDir()), check.IsNil)
+	c.Assert(os.RemoveAll(si.CommonDataDir()), check.IsNil)
+
+	logger.SimpleSetup()
+	rs, err := shr.Restore(context.TODO(), snap.R(0), nil, logger.Debugf, nil)
+	c.Assert(err, check.IsNil)
+	rs.Cleanup()
+
+	c.Check(filepath.Join(si.DataDir(), "foo"), testutil.FileEquals, "versioned system canary\n")
+	c.Check(filepath.Join(si.CommonDataDir(), "bar"), testutil.FileEquals, "common system canary\n")
+}
+
+func (s *snapshotSuite) TestSaveDeduplicatedSharesChunks(c *check.C) {
+	defer backend.MockUsersForUsernames(func([]string, *dirs.SnapDirOptions) ([]*user.User, error) {
+		return nil, nil
+	})()
+
+	shw1 := s.saveDeduplicated(c, 12, snap.R(42))
+	chunks := chunkFiles(c)
+	c.Assert(chunks, check.HasLen, 1)
+
+	// nothing changed, so nothing new is stored
+	shw2 := s.saveDeduplicated(c, 13, snap.R(42))
+	c.Check(shw2.SHA3_384, check.DeepEquals, shw1.SHA3_384)
+	c.Check(chunkFiles(c), check.DeepEquals, chunks)
+
+	// but changes are
+	si := snap.MinimalPlaceInfo("hello-snap", snap.R(42))
+	c.Assert(ioutil.WriteFile(filepath.Join(si.DataDir(), "foo"), []byte("changed"), 0644), check.IsNil)
+	shw3 := s.saveDeduplicated(c, 14, snap.R(42))
+	c.Check(shw3.SHA3_384, check.Not(check.DeepEquals), shw1.SHA3_384)
+	c.Check(chunkFiles(c), check.HasLen, 2)
+}
+
+func (s *snapshotSuite) TestGarbageCollectChunks(c *check.C) {
+	defer backend.MockUsersForUsernames(func([]string, *dirs.SnapDirOptions) ([]*user.User, error) {
+		return nil, nil
+	})()
+
+	// no chunk store, nothing to do
+	removed, err := backend.GarbageCollectChunks(context.TODO())
+	c.Assert(err, check.IsNil)
+	c.Check(removed, check.Equals, 0)
+
+	shw1 := s.saveDeduplicated(c, 12, snap.R(42))
+	si := snap.MinimalPlaceInfo("hello-snap", snap.R(42))
+	c.Assert(ioutil.WriteFile(filepath.Join(si.DataDir(), "foo"), []byte("changed"), 0644), check.IsNil)
+	shw2 := s.saveDeduplicated(c, 13, snap.R(42))
+	c.Assert(chunkFiles(c), check.HasLen, 2)
+
+	// all chunks are in use
+	removed, err = backend.GarbageCollectChunks(context.TODO())
+	c.Assert(err, check.IsNil)
+	c.Check(removed, check.Equals, 0)
+
+	c.Assert(os.Remove(backend.Filename(shw1)), check.IsNil)
+	removed, err = backend.GarbageCollectChunks(context.TODO())
+	c.Assert(err, check.IsNil)
+	c.Check(removed, check.Equals, 1)
+	c.Check(chunkFiles(c), check.HasLen, 1)
+
+	// and the remaining snapshot is intact
+	shr, err := backend.Open(backend.Filename(shw2), backend.ExtractFnameSetID)
+	c.Assert(err, check.IsNil)
+	defer shr.Close()
+	c.Check(shr.Check(context.TODO(), nil), check.IsNil)
+}
+
+func (s *snapshotSuite) TestGarbageCollectChunksKeepsChunksOfUnreadableSnapshots(c *check.C) {
+	defer backend.MockUsersForUsernames(func([]string, *dirs.SnapDirOptions) ([]*user.User, error) {
+		return nil, nil
+	})()
+
+	s.saveDeduplicated(c, 12, snap.R(42))
+	c.Assert(ioutil.WriteFile(filepath.Join(dirs.SnapshotsDir, "13_foo_1.0_1.zip"), []byte("not a zip"), 0600), check.IsNil)
+
+	_, err := backend.GarbageCollectChunks(context.TODO())
+	c.Assert(err, check.ErrorMatches, `cannot read snapshot ".*/13_foo_1.0_1.zip": .*`)
+	c.Check(chunkFiles(c), check.HasLen, 1)
+}
+
+func (s *snapshotSuite) TestExportDeduplicatedIsSelfContained(c *check.C) {
+	defer backend.MockUsersForUsernames(func([]string, *dirs.SnapDirOptions) ([]*user.User, error) {
+		return nil, nil
+	})()
+	ctx := context.TODO()
+
+	shw := s.saveDeduplicated(c, 12, snap.R(42))
+
+	export, err := backend.NewSnapshotExport(ctx, shw.SetID)
+	c.Assert(err, check.IsNil)
+	defer export.Close()
+	c.Assert(export.Init(), check.IsNil)
+
+	buf := bytes.NewBuffer(nil)
+	c.Assert(export.StreamTo(buf), check.IsNil)
+	c.Check(buf.Len(), check.Equals, int(export.Size()))
+
+	// forgetting the snapshot and its chunks doesn't matter to the export
+	c.Assert(os.Remove(backend.Filename(shw)), check.IsNil)
+	_, err = backend.GarbageCollectChunks(ctx)
+	c.Assert(err, check.IsNil)
+	c.Check(chunkFiles(c), check.HasLen, 0)
+
+	names, err := backend.Import(ctx, 123, buf, nil)
+	c.Assert(err, check.IsNil)
+	c.Check(names, check.DeepEquals, []string{"hello-snap"})
+
+	fn := filepath.Join(dirs.SnapshotsDir, "123_hello-snap_v1.33_42.zip")
+	c.Check(zipMemberNames(c, fn), check.DeepEquals, []string{"archive.tgz", "meta.json", "meta.sha3_384"})
+	rdr, err := backend.Open(fn, backend.ExtractFnameSetID)
+	c.Assert(err, check.IsNil)
+	defer rdr.Close()
+	c.Check(rdr.SHA3_384, check.DeepEquals, shw.SHA3_384)
+	c.Check(rdr.Check(ctx, nil), check.IsNil)
+}
+
+func (s *snapshotSuite) TestChunkBoundariesFollowContent(c *check.C) {
+	data := make([]byte, 24*1024*1024)
+	rand.New(rand.NewSource(42)).Read(data)
+
+	sums, err := backend.ChunkData(context.TODO(), data)
+	c.Assert(err, check.IsNil)
+	c.Assert(len(sums) > 2, check.Equals, true)
+
+	// inserting data at the start only changes the first chunk
+	sums2, err := backend.ChunkData(context.TODO(), append([]byte("some more data"), data...))
+	c.Assert(err, check.IsNil)
+	c.Check(sums2[0], check.Not(check.Equals), sums[0])
+	c.Check(sums2[1:], check.DeepEquals, sums[1:])
+}
+
+func (s *snapshotSuite) TestEncryptedStreamRoundtrip(c *check.C) {
+	dataKey := bytes.Repeat([]byte{42}, 32)
+	for _, size := range []int{0, 1, 64*1024 - 1, 64 * 1024, 64*1024 + 1, 3 * 64 * 1024, 200000} {
+		comm := check.Commentf("size %d", size)
+		data := make([]byte, size)
+		rand.New(rand.NewSource(int64(size))).Read(data)
+
+		var buf bytes.Buffer
+		w, err := backend.NewEncryptingWriter(&buf, dataKey)
+		c.Assert(err, check.IsNil, comm)
+		// write in odd sized bits
+		for p := data; len(p) > 0; {
+			n := 1000
+			if n > len(p) {
+				n = len(p)
+			}
+			_, err := w.Write(p[:n])
+			c.Assert(err, check.IsNil, comm)
+			p = p[n:]
+		}
+		c.Assert(w.Close(), check.IsNil, comm)
+		encrypted := buf.Bytes()
+		if size > 64 {
+			c.Check(bytes.Contains(encrypted, data), check.Equals, false, comm)
+		}
+
+		r, err := backend.NewDecryptingReader(bytes.NewReader(encrypted), dataKey)
+		c.Assert(err, check.IsNil, comm)
+		decrypted, err := ioutil.ReadAll(r)
+		c.Assert(err, check.IsNil, comm)
+		c.Check(decrypted, check.DeepEquals, data, comm)
+
+		// the wrong key is noticed
+		r, err = backend.NewDecryptingReader(bytes.NewReader(encrypted), bytes.Repeat([]byte{1}, 32))
+		c.Assert(err, check.IsNil, comm)
+		_, err = ioutil.ReadAll(r)
+		c.Check(err, check.ErrorMatches, "cannot decrypt archive: data is corrupted or was tampered with", comm)
+
+		if size <= 64*1024 {
+			continue
+		}
+		// and so is truncation on a segment boundary
+		r, err = backend.NewDecryptingReader(bytes.NewReader(encrypted[:16+64*1024+16]), dataKey)
+		c.Assert(err, check.IsNil, comm)
+		_, err = ioutil.ReadAll(r)
+		c.Check(err, check.ErrorMatches, "cannot decrypt archive: data is corrupted or was tampered with", comm)
+	}
+}
+
+func (s *snapshotSuite) saveEncrypted(c *check.C, shID uint64, key *backend.EncryptionKey, dedup bool) *client.Snapshot {
+	info := &snap.Info{SideInfo: snap.SideInfo{RealName: "hello-snap", Revision: snap.R(42), SnapID: "hello-id"}, Version: "v1.33"}
+	shw, err := backend.Save(context.TODO(), shID, info, nil, nil, nil, nil, &backend.SaveOptions{Encryption: key, Deduplicate: dedup})
+	c.Assert(err, check.IsNil)
+	return shw
+}
+
+func (s *snapshotSuite) testEncryptedRoundtrip(c *check.C, encKey, decKey *backend.EncryptionKey, method string) {
+	defer backend.MockUsersForUsernames(func([]string, *dirs.SnapDirOptions) ([]*user.User, error) {
+		return nil, nil
+	})()
+
+	// encrypted snapshots are not deduplicated
+	shw := s.saveEncrypted(c, 12, encKey, true)
+	c.Assert(shw.Encryption, check.NotNil)
+	c.Check(shw.Encryption.Method, check.Equals, method)
+	c.Check(hashkeys(shw), check.DeepEquals, []string{"archive.tgz"})
+	c.Check(zipMemberNames(c, backend.Filename(shw)), check.DeepEquals, []string{"archive.tgz", "meta.json", "meta.sha3_384"})
+	c.Check(chunkFiles(c), check.HasLen, 0)
+
+	shr, err := backend.Open(backend.Filename(shw), backend.ExtractFnameSetID)
+	c.Assert(err, check.IsNil)
+	defer shr.Close()
+	c.Check(shr.Encryption, check.DeepEquals, shw.Encryption)
+
+	// the stored data can be checked without the key
+	c.Check(shr.Check(context.TODO(), nil), check.IsNil)
+	// but not restored
+	logger.SimpleSetup()
+	_, err = shr.Restore(context.TODO(), snap.R(0), nil, logger.Debugf, nil)
+	c.Assert(err, check.Equals, backend.ErrEncryptionKeyRequired)
+
+	c.Assert(shr.Unlock(decKey), check.IsNil)
+	c.Check(shr.Check(context.TODO(), nil), check.IsNil)
+
+	si := snap.MinimalPlaceInfo("hello-snap", snap.R(42))
+	c.Assert(os.RemoveAll(si.DataDir()), check.IsNil)
+	c.Assert(os.RemoveAll(si.CommonDataDir()), check.IsNil)
+
+	rs, err := shr.Restore(context.TODO(), snap.R(0), nil, logger.Debugf, nil)
+	c.Assert(err, check.IsNil)
+	rs.Cleanup()
+
+	c.Check(filepath.Join(si.DataDir(), "foo"), testutil.FileEquals, "versioned system canary\n")
+	c.Check(filepath.Join(si.CommonDataDir(), "bar"), testutil.FileEquals, "common system canary\n")
+}
+
+func (s *snapshotSuite) TestEncryptedRoundtripPassphrase(c *check.C) {
+	key := &backend.EncryptionKey{Passphrase: "sekrit"}
+	s.testEncryptedRoundtrip(c, key, key, "passphrase")
+}
+
+func (s *snapshotSuite) TestEncryptedRoundtripPublicKey(c *check.C) {
+	privateKey := bytes.Repeat([]byte{7}, 32)
+	publicKey, err := curve25519.X25519(privateKey, curve25519.Basepoint)
+	c.Assert(err, check.IsNil)
+	s.testEncryptedRoundtrip(c, &backend.EncryptionKey{PublicKey: publicKey}, &backend.EncryptionKey{PrivateKey: privateKey}, "public-key")
+}
+
+func (s *snapshotSuite) TestEncryptedWrongKey(c *check.C) {
+	defer backend.MockUsersForUsernames(func([]string, *dirs.SnapDirOptions) ([]*user.User, error) {
+		return nil, nil
+	})()
+	privateKey := bytes.Repeat([]byte{7}, 32)
+	publicKey, err := curve25519.X25519(privateKey, curve25519.Basepoint)
+	c.Assert(err, check.IsNil)
+
+	shw := s.saveEncrypted(c, 12, &backend.EncryptionKey{Passphrase: "sekrit"}, false)
+	shr, err := backend.Open(backend.Filename(shw), backend.ExtractFnameSetID)
+	c.Assert(err, check.IsNil)
+	defer shr.Close()
+	c.Check(shr.Unlock(&backend.EncryptionKey{Passphrase: "not-sekrit"}), check.Equals, backend.ErrWrongEncryptionKey)
+	c.Check(shr.Unlock(&backend.EncryptionKey{PrivateKey: privateKey}), check.ErrorMatches, "snapshot is encrypted with a passphrase")
+
+	shw = s.saveEncrypted(c, 13, &backend.EncryptionKey{PublicKey: publicKey}, false)
+	shr2, err := backend.Open(backend.Filename(shw), backend.ExtractFnameSetID)
+	c.Assert(err, check.IsNil)
+	defer shr2.Close()
+	c.Check(shr2.Unlock(&backend.EncryptionKey{PrivateKey: bytes.Repeat([]byte{8}, 32)}), check.Equals, backend.ErrWrongEncryptionKey)
+	c.Check(shr2.Unlock(&backend.EncryptionKey{Passphrase: "sekrit"}), check.ErrorMatches, "snapshot is encrypted with a public key")
+}
+
+func (s *snapshotSuite) TestEncryptionKeyValidate(c *check.C) {
+	for _, t := range []struct {
+		key *backend.EncryptionKey
+		err string
+	}{
+		{&backend.EncryptionKey{Passphrase: "x"}, ""},
+		{&backend.EncryptionKey{PublicKey: make([]byte, 32)}, ""},
+		{&backend.EncryptionKey{PrivateKey: make([]byte, 32)}, ""},
+		{&backend.EncryptionKey{}, "exactly one of passphrase, public key or private key must be given"},
+		{&backend.EncryptionKey{Passphrase: "x", PrivateKey: make([]byte, 32)}, "exactly one of passphrase, public key or private key must be given"},
+		{&backend.EncryptionKey{PublicKey: make([]byte, 31)}, "invalid public key size 31"},
+		{&backend.EncryptionKey{PrivateKey: make([]byte, 33)}, "invalid private key size 33"},
+	} {
+		err := t.key.Validate()
+		if t.err == "" {
+			c.Check(err, check.IsNil)
+		} else {
+			c.Check(err, check.ErrorMatches, t.err)
+		}
+	}
+}
+
+func (s *snapshotSuite) TestSaveEncryptedWithPrivateKeyFails(c *check.C) {
+	info := &snap.Info{SideInfo: snap.SideInfo{RealName: "hello-snap", Revision: snap.R(42), SnapID: "hello-id"}, Version: "v1.33"}
+	_, err := backend.Save(context.TODO(), 12, info, nil, nil, nil, nil, &backend.SaveOptions{Encryption: &backend.EncryptionKey{PrivateKey: make([]byte, 32)}})
+	c.Assert(err, check.ErrorMatches, "cannot encrypt snapshot: cannot encrypt a snapshot with a private key")
+}
+
+func (s *snapshotSuite) TestExportImportEncrypted(c *check.C) {
+	defer backend.MockUsersForUsernames(func([]string, *dirs.SnapDirOptions) ([]*user.User, error) {
+		return nil, nil
+	})()
+	ctx := context.TODO()
+	key := &backend.EncryptionKey{Passphrase: "sekrit"}
+
+	shw := s.saveEncrypted(c, 12, key, false)
+
+	export, err := backend.NewSnapshotExport(ctx, shw.SetID)
+	c.Assert(err, check.IsNil)
+	defer export.Close()
+	c.Assert(export.Init(), check.IsNil)
+	buf := bytes.NewBuffer(nil)
+	c.Assert(export.StreamTo(buf), check.IsNil)
+	c.Assert(os.Remove(backend.Filename(shw)), check.IsNil)
+
+	// importing does not need the key
+	_, err = backend.Import(ctx, 123, buf, nil)
+	c.Assert(err, check.IsNil)
+
+	rdr, err := backend.Open(filepath.Join(dirs.SnapshotsDir, "123_hello-snap_v1.33_42.zip"), backend.ExtractFnameSetID)
+	c.Assert(err, check.IsNil)
+	defer rdr.Close()
+	c.Check(rdr.Encryption, check.DeepEquals, shw.Encryption)
+	c.Assert(rdr.Unlock(key), check.IsNil)
+	c.Check(rdr.Check(ctx, nil), check.IsNil)
+}
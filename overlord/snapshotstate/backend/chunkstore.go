@@ -0,0 +1,483 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package backend
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/osutil"
+)
+
+// Deduplicated snapshots don't store their archives in the snapshot file
+// itself. Instead the uncompressed tar stream is cut into content-defined
+// chunks, each chunk is compressed as a gzip member of its own and stored
+// in a content-addressed chunk store shared by all snapshots. The snapshot
+// file then only holds the list of chunks for every archive, under the
+// archive name with chunkListSuffix appended.
+//
+// As a sequence of gzip members is itself a valid gzip stream, the archive
+// is recovered by concatenating its chunks; the hashes and sizes recorded in
+// the snapshot metadata are those of the concatenated stream, so they are the
+// same whether or not the archive is deduplicated.
+const (
+	chunkListSuffix = ".chunks"
+
+	minChunkSize = 512 * 1024
+	maxChunkSize = 8 * 1024 * 1024
+	// chunkMask gives an average chunk size of about 2MiB (plus minChunkSize)
+	chunkMask = 1<<21 - 1
+)
+
+var (
+	// chunkGear is the table used by the rolling hash that finds chunk
+	// boundaries. It must never change: doing so would stop new snapshots
+	// from sharing chunks with existing ones.
+	chunkGear = func() (gear [256]uint64) {
+		// splitmix64
+		var x uint64 = 0x736e617073686f74
+		for i := range gear {
+			x += 0x9e3779b97f4a7c15
+			z := x
+			z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+			z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+			gear[i] = z ^ (z >> 31)
+		}
+		return gear
+	}()
+
+	// chunkStoreMu is held for reading while chunks are being added to the
+	// store, and for writing while the store is garbage collected.
+	chunkStoreMu sync.RWMutex
+
+	// ErrChunkStoreBusy is returned by GarbageCollectChunks if the chunk
+	// store is in use.
+	ErrChunkStoreBusy = errors.New("chunk store is busy")
+)
+
+// chunksDir returns the directory of the snapshot chunk store.
+func chunksDir() string {
+	return filepath.Join(dirs.SnapshotsDir, "chunks")
+}
+
+func chunkPath(sum string) string {
+	return filepath.Join(chunksDir(), sum[:2], sum)
+}
+
+// chunkRef identifies a chunk in the chunk store.
+type chunkRef struct {
+	// SHA3_384 is the hash of the uncompressed chunk data.
+	SHA3_384 string `json:"sha3-384"`
+	// Size is the size of the stored (compressed) chunk.
+	Size int64 `json:"size"`
+}
+
+// chunkList is what is stored in a deduplicated snapshot in lieu of an archive.
+type chunkList struct {
+	Size   int64      `json:"size"`
+	Chunks []chunkRef `json:"chunks"`
+}
+
+// chunkWriter is an io.Writer that cuts what is written to it into chunks and
+// adds them to the chunk store.
+type chunkWriter struct {
+	ctx    context.Context
+	buf    []byte
+	fp     uint64
+	list   chunkList
+	hasher hash.Hash
+}
+
+func newChunkWriter(ctx context.Context) *chunkWriter {
+	return &chunkWriter{
+		ctx:    ctx,
+		hasher: crypto.SHA3_384.New(),
+	}
+}
+
+// cutPoint returns the offset in p just after the next chunk boundary, or -1
+// if there is no boundary in p.
+func (cw *chunkWriter) cutPoint(p []byte) int {
+	sz := len(cw.buf)
+	for i, b := range p {
+		sz++
+		cw.fp = (cw.fp << 1) + chunkGear[b]
+		if sz >= maxChunkSize || (sz >= minChunkSize && cw.fp&chunkMask == 0) {
+			return i + 1
+		}
+	}
+	return -1
+}
+
+func (cw *chunkWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		cut := cw.cutPoint(p)
+		if cut < 0 {
+			cw.buf = append(cw.buf, p...)
+			break
+		}
+		cw.buf = append(cw.buf, p[:cut]...)
+		p = p[cut:]
+		if err := cw.flush(); err != nil {
+			return 0, err
+		}
+	}
+	return n, nil
+}
+
+// Close stores whatever is left over as the last chunk.
+func (cw *chunkWriter) Close() error {
+	if len(cw.buf) == 0 {
+		return nil
+	}
+	return cw.flush()
+}
+
+func (cw *chunkWriter) flush() error {
+	if err := cw.ctx.Err(); err != nil {
+		return err
+	}
+	ref, err := storeChunk(cw.buf, cw.hasher)
+	if err != nil {
+		return err
+	}
+	cw.list.Chunks = append(cw.list.Chunks, ref)
+	cw.list.Size += ref.Size
+	cw.buf = cw.buf[:0]
+	cw.fp = 0
+	return nil
+}
+
+// Sum returns the hash of the archive made up of the chunks written so far.
+func (cw *chunkWriter) Sum() string {
+	return fmt.Sprintf("%x", cw.hasher.Sum(nil))
+}
+
+// storeChunk adds the given data to the chunk store, unless it's there
+// already, and feeds the stored chunk to the hasher.
+func storeChunk(data []byte, hasher hash.Hash) (chunkRef, error) {
+	dataHasher := crypto.SHA3_384.New()
+	dataHasher.Write(data)
+	ref := chunkRef{SHA3_384: fmt.Sprintf("%x", dataHasher.Sum(nil))}
+	p := chunkPath(ref.SHA3_384)
+
+	if f, err := os.Open(p); err == nil {
+		defer f.Close()
+		ref.Size, err = io.Copy(hasher, f)
+		if err != nil {
+			return chunkRef{}, fmt.Errorf("cannot read snapshot chunk %s: %v", ref.SHA3_384, err)
+		}
+		return ref, nil
+	} else if !os.IsNotExist(err) {
+		return chunkRef{}, err
+	}
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if _, err := gz.Write(data); err != nil {
+		return chunkRef{}, err
+	}
+	if err := gz.Close(); err != nil {
+		return chunkRef{}, err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0700); err != nil {
+		return chunkRef{}, err
+	}
+	if err := osutil.AtomicWriteFile(p, buf.Bytes(), 0600, 0); err != nil {
+		return chunkRef{}, fmt.Errorf("cannot store snapshot chunk %s: %v", ref.SHA3_384, err)
+	}
+	hasher.Write(buf.Bytes())
+	ref.Size = int64(buf.Len())
+
+	return ref, nil
+}
+
+// chunkReader is an io.ReadCloser that reads the concatenation of the chunks
+// of a chunkList.
+type chunkReader struct {
+	chunks []chunkRef
+	cur    *os.File
+	left   int64
+}
+
+func (cr *chunkReader) Read(p []byte) (int, error) {
+	for cr.cur == nil {
+		if len(cr.chunks) == 0 {
+			return 0, io.EOF
+		}
+		ref := cr.chunks[0]
+		cr.chunks = cr.chunks[1:]
+		f, err := os.Open(chunkPath(ref.SHA3_384))
+		if err != nil {
+			return 0, fmt.Errorf("cannot open snapshot chunk %s: %v", ref.SHA3_384, err)
+		}
+		cr.cur = f
+		cr.left = ref.Size
+	}
+	n, err := cr.cur.Read(p)
+	cr.left -= int64(n)
+	if err == io.EOF {
+		cr.cur.Close()
+		cr.cur = nil
+		if cr.left != 0 {
+			return n, fmt.Errorf("snapshot chunk has unexpected size")
+		}
+		err = nil
+	}
+	return n, err
+}
+
+func (cr *chunkReader) Close() error {
+	if cr.cur != nil {
+		err := cr.cur.Close()
+		cr.cur = nil
+		return err
+	}
+	return nil
+}
+
+func readChunkList(fh *zip.File) (*chunkList, error) {
+	r, err := fh.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	var list chunkList
+	if err := json.NewDecoder(r).Decode(&list); err != nil {
+		return nil, fmt.Errorf("cannot decode chunk list %q: %v", fh.Name, err)
+	}
+	return &list, nil
+}
+
+// snapshotMember returns an io.ReadCloser for the 'entry' archive in the 'f'
+// snapshot file, reassembling it from the chunk store if the snapshot is
+// deduplicated.
+func snapshotMember(f *os.File, entry string) (r io.ReadCloser, sz int64, err error) {
+	if _, err := f.Seek(0, 0); err != nil {
+		return nil, -1, err
+	}
+
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, -1, err
+	}
+
+	arch, err := zip.NewReader(f, fi.Size())
+	if err != nil {
+		return nil, -1, err
+	}
+
+	for _, fh := range arch.File {
+		switch fh.Name {
+		case entry:
+			r, err = fh.Open()
+			return r, int64(fh.UncompressedSize64), err
+		case entry + chunkListSuffix:
+			list, err := readChunkList(fh)
+			if err != nil {
+				return nil, -1, err
+			}
+			return &chunkReader{chunks: list.Chunks}, list.Size, nil
+		}
+	}
+
+	return nil, -1, fmt.Errorf("missing archive member %q", entry)
+}
+
+// isDeduplicated returns whether the given snapshot file keeps its archives
+// in the chunk store.
+func isDeduplicated(f *os.File) (bool, error) {
+	fi, err := f.Stat()
+	if err != nil {
+		return false, err
+	}
+	arch, err := zip.NewReader(f, fi.Size())
+	if err != nil {
+		return false, err
+	}
+	for _, fh := range arch.File {
+		if strings.HasSuffix(fh.Name, chunkListSuffix) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// rehydrate returns a self-contained copy of the given deduplicated snapshot
+// file, with the archives read back from the chunk store. The copy is an
+// unlinked temporary file, with the same name as the original.
+func rehydrate(f *os.File) (rf *os.File, e error) {
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	arch, err := zip.NewReader(f, fi.Size())
+	if err != nil {
+		return nil, err
+	}
+
+	tmp, err := ioutil.TempFile(dirs.SnapshotsDir, ".rehydrate-")
+	if err != nil {
+		return nil, err
+	}
+	defer tmp.Close()
+	// only the descriptor is needed from here on
+	if err := os.Remove(tmp.Name()); err != nil {
+		return nil, err
+	}
+
+	w := zip.NewWriter(tmp)
+	for _, fh := range arch.File {
+		if !strings.HasSuffix(fh.Name, chunkListSuffix) {
+			if err := w.Copy(fh); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		list, err := readChunkList(fh)
+		if err != nil {
+			return nil, err
+		}
+		archiveWriter, err := w.CreateHeader(&zip.FileHeader{Name: strings.TrimSuffix(fh.Name, chunkListSuffix)})
+		if err != nil {
+			return nil, err
+		}
+		cr := &chunkReader{chunks: list.Chunks}
+		_, err = io.Copy(archiveWriter, cr)
+		cr.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	fd, err := syscall.Dup(int(tmp.Fd()))
+	if err != nil {
+		return nil, fmt.Errorf("cannot duplicate descriptor: %v", err)
+	}
+	rf = os.NewFile(uintptr(fd), f.Name())
+	if rf == nil {
+		return nil, fmt.Errorf("cannot open file from descriptor %d", fd)
+	}
+	return rf, nil
+}
+
+// referencedChunks returns the set of chunks used by the snapshot files in
+// the snapshots directory.
+func referencedChunks(ctx context.Context) (map[string]bool, error) {
+	names, err := filepath.Glob(filepath.Join(dirs.SnapshotsDir, "*.zip"))
+	if err != nil {
+		return nil, err
+	}
+
+	refs := make(map[string]bool)
+	for _, name := range names {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if ok, _ := isSnapshotFilename(name); !ok {
+			continue
+		}
+		arch, err := zip.OpenReader(name)
+		if err != nil {
+			// better to keep chunks around than to lose data
+			return nil, fmt.Errorf("cannot read snapshot %q: %v", name, err)
+		}
+		for _, fh := range arch.File {
+			if !strings.HasSuffix(fh.Name, chunkListSuffix) {
+				continue
+			}
+			list, err := readChunkList(fh)
+			if err != nil {
+				arch.Close()
+				return nil, err
+			}
+			for _, ref := range list.Chunks {
+				refs[ref.SHA3_384] = true
+			}
+		}
+		arch.Close()
+	}
+	return refs, nil
+}
+
+// GarbageCollectChunks removes the chunks that are not used by any snapshot
+// from the chunk store, returning the number of chunks removed. If chunks are
+// being added to the store it gives up and returns ErrChunkStoreBusy.
+func GarbageCollectChunks(ctx context.Context) (removed int, err error) {
+	if !osutil.IsDirectory(chunksDir()) {
+		// nothing to do
+		return 0, nil
+	}
+
+	if !chunkStoreMu.TryLock() {
+		return 0, ErrChunkStoreBusy
+	}
+	defer chunkStoreMu.Unlock()
+
+	refs, err := referencedChunks(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	chunks, err := filepath.Glob(filepath.Join(chunksDir(), "*", "*"))
+	if err != nil {
+		return 0, err
+	}
+	var errs []error
+	for _, p := range chunks {
+		if refs[filepath.Base(p)] {
+			continue
+		}
+		if err := os.Remove(p); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		removed++
+	}
+	if removed > 0 {
+		logger.Debugf("Removed %d unused snapshot chunks.", removed)
+	}
+	if len(errs) > 0 {
+		return removed, newMultiError("cannot remove unused snapshot chunks", errs)
+	}
+
+	return removed, nil
+}
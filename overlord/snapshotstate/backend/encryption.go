@@ -0,0 +1,391 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package backend
+
+import (
+	"bufio"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/hkdf"
+	"golang.org/x/crypto/scrypt"
+
+	"github.com/snapcore/snapd/client"
+)
+
+// Encrypted snapshots have each of their archives encrypted with a random
+// data key, unique to the snapshot. The data key itself is stored in the
+// snapshot metadata, encrypted with a key derived either from a passphrase
+// or, for public key encryption, from an X25519 key agreement with an
+// ephemeral key.
+//
+// Each archive is encrypted in segments with ChaCha20-Poly1305, under a key
+// derived from the data key and a random salt that starts the archive. The
+// nonce of a segment is its index, with the last byte flagging the final
+// segment, so that segments cannot be reordered nor the archive truncated
+// without it being noticed.
+const (
+	EncryptionPassphrase = "passphrase"
+	EncryptionPublicKey  = "public-key"
+
+	dataKeySize     = chacha20poly1305.KeySize
+	archiveSaltSize = 16
+	segmentSize     = 64 * 1024
+
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+)
+
+var (
+	// ErrEncryptionKeyRequired is returned when trying to read the data of
+	// an encrypted snapshot that wasn't unlocked.
+	ErrEncryptionKeyRequired = errors.New("snapshot is encrypted: passphrase or private key required")
+	// ErrWrongEncryptionKey is returned by Unlock when the key does not
+	// decrypt the snapshot.
+	ErrWrongEncryptionKey = errors.New("cannot decrypt snapshot: wrong passphrase or private key")
+
+	randReader io.Reader = rand.Reader
+)
+
+// EncryptionKey holds what is needed to encrypt or decrypt a snapshot: either
+// a passphrase, or an X25519 public key to encrypt and the matching private
+// key to decrypt.
+type EncryptionKey struct {
+	Passphrase string
+	PublicKey  []byte
+	PrivateKey []byte
+}
+
+// Validate checks that the key is usable.
+func (key *EncryptionKey) Validate() error {
+	n := 0
+	if key.Passphrase != "" {
+		n++
+	}
+	if len(key.PublicKey) != 0 {
+		if len(key.PublicKey) != curve25519.PointSize {
+			return fmt.Errorf("invalid public key size %d", len(key.PublicKey))
+		}
+		n++
+	}
+	if len(key.PrivateKey) != 0 {
+		if len(key.PrivateKey) != curve25519.ScalarSize {
+			return fmt.Errorf("invalid private key size %d", len(key.PrivateKey))
+		}
+		n++
+	}
+	if n != 1 {
+		return errors.New("exactly one of passphrase, public key or private key must be given")
+	}
+	return nil
+}
+
+func randomBytes(n int) ([]byte, error) {
+	b := make([]byte, n)
+	if _, err := io.ReadFull(randReader, b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func passphraseKey(passphrase string, salt []byte) ([]byte, error) {
+	return scrypt.Key([]byte(passphrase), salt, scryptN, scryptR, scryptP, dataKeySize)
+}
+
+func agreementKey(shared, ephemeralKey, publicKey []byte) ([]byte, error) {
+	salt := append(append([]byte(nil), ephemeralKey...), publicKey...)
+	kek := make([]byte, dataKeySize)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, shared, salt, []byte("snapd snapshot key")), kek); err != nil {
+		return nil, err
+	}
+	return kek, nil
+}
+
+// newEncryption returns a new random data key, along with the description of
+// how it is stored in the snapshot.
+func newEncryption(key *EncryptionKey) (enc *client.SnapshotEncryption, dataKey []byte, err error) {
+	if err := key.Validate(); err != nil {
+		return nil, nil, err
+	}
+	dataKey, err = randomBytes(dataKeySize)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var kek []byte
+	switch {
+	case key.Passphrase != "":
+		salt, err := randomBytes(archiveSaltSize)
+		if err != nil {
+			return nil, nil, err
+		}
+		enc = &client.SnapshotEncryption{Method: EncryptionPassphrase, Salt: salt}
+		kek, err = passphraseKey(key.Passphrase, salt)
+		if err != nil {
+			return nil, nil, err
+		}
+	case len(key.PublicKey) != 0:
+		ephemeral, err := randomBytes(curve25519.ScalarSize)
+		if err != nil {
+			return nil, nil, err
+		}
+		ephemeralKey, err := curve25519.X25519(ephemeral, curve25519.Basepoint)
+		if err != nil {
+			return nil, nil, err
+		}
+		shared, err := curve25519.X25519(ephemeral, key.PublicKey)
+		if err != nil {
+			return nil, nil, fmt.Errorf("cannot use public key: %v", err)
+		}
+		enc = &client.SnapshotEncryption{Method: EncryptionPublicKey, EphemeralKey: ephemeralKey}
+		kek, err = agreementKey(shared, ephemeralKey, key.PublicKey)
+		if err != nil {
+			return nil, nil, err
+		}
+	default:
+		return nil, nil, errors.New("cannot encrypt a snapshot with a private key")
+	}
+
+	aead, err := chacha20poly1305.New(kek)
+	if err != nil {
+		return nil, nil, err
+	}
+	nonce, err := randomBytes(aead.NonceSize())
+	if err != nil {
+		return nil, nil, err
+	}
+	enc.WrappedKey = aead.Seal(nonce, nonce, dataKey, nil)
+
+	return enc, dataKey, nil
+}
+
+// CheckEncryptionKey checks whether key can decrypt a snapshot encrypted as
+// described by enc.
+func CheckEncryptionKey(enc *client.SnapshotEncryption, key *EncryptionKey) error {
+	_, err := unwrapDataKey(enc, key)
+	return err
+}
+
+// unwrapDataKey returns the data key of a snapshot encrypted as described by
+// enc, using the given key.
+func unwrapDataKey(enc *client.SnapshotEncryption, key *EncryptionKey) ([]byte, error) {
+	if err := key.Validate(); err != nil {
+		return nil, err
+	}
+
+	var kek []byte
+	var err error
+	switch enc.Method {
+	case EncryptionPassphrase:
+		if key.Passphrase == "" {
+			return nil, errors.New("snapshot is encrypted with a passphrase")
+		}
+		kek, err = passphraseKey(key.Passphrase, enc.Salt)
+	case EncryptionPublicKey:
+		if len(key.PrivateKey) == 0 {
+			return nil, errors.New("snapshot is encrypted with a public key")
+		}
+		var publicKey, shared []byte
+		publicKey, err = curve25519.X25519(key.PrivateKey, curve25519.Basepoint)
+		if err != nil {
+			return nil, err
+		}
+		shared, err = curve25519.X25519(key.PrivateKey, enc.EphemeralKey)
+		if err != nil {
+			return nil, ErrWrongEncryptionKey
+		}
+		kek, err = agreementKey(shared, enc.EphemeralKey, publicKey)
+	default:
+		return nil, fmt.Errorf("unsupported snapshot encryption method %q", enc.Method)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := chacha20poly1305.New(kek)
+	if err != nil {
+		return nil, err
+	}
+	if len(enc.WrappedKey) < aead.NonceSize() {
+		return nil, errors.New("invalid snapshot encryption metadata")
+	}
+	nonce, wrapped := enc.WrappedKey[:aead.NonceSize()], enc.WrappedKey[aead.NonceSize():]
+	dataKey, err := aead.Open(nil, nonce, wrapped, nil)
+	if err != nil {
+		return nil, ErrWrongEncryptionKey
+	}
+	return dataKey, nil
+}
+
+func archiveKey(dataKey, salt []byte) ([]byte, error) {
+	k := make([]byte, dataKeySize)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, dataKey, salt, []byte("snapd snapshot archive")), k); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+func segmentNonce(nonce []byte, counter uint64, last bool) {
+	for i := range nonce {
+		nonce[i] = 0
+	}
+	binary.BigEndian.PutUint64(nonce[len(nonce)-9:], counter)
+	if last {
+		nonce[len(nonce)-1] = 1
+	}
+}
+
+// encryptingWriter encrypts what is written to it onto the underlying writer.
+// It must be closed to write out the final segment.
+type encryptingWriter struct {
+	w       io.Writer
+	aead    cipher.AEAD
+	buf     []byte
+	nonce   []byte
+	counter uint64
+}
+
+func newEncryptingWriter(w io.Writer, dataKey []byte) (io.WriteCloser, error) {
+	salt, err := randomBytes(archiveSaltSize)
+	if err != nil {
+		return nil, err
+	}
+	k, err := archiveKey(dataKey, salt)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := chacha20poly1305.New(k)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(salt); err != nil {
+		return nil, err
+	}
+	return &encryptingWriter{
+		w:     w,
+		aead:  aead,
+		buf:   make([]byte, 0, segmentSize+aead.Overhead()),
+		nonce: make([]byte, aead.NonceSize()),
+	}, nil
+}
+
+func (ew *encryptingWriter) seal(last bool) error {
+	segmentNonce(ew.nonce, ew.counter, last)
+	ew.counter++
+	out := ew.aead.Seal(ew.buf[:0], ew.nonce, ew.buf, nil)
+	if _, err := ew.w.Write(out); err != nil {
+		return err
+	}
+	ew.buf = ew.buf[:0]
+	return nil
+}
+
+func (ew *encryptingWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		if len(ew.buf) == segmentSize {
+			// only seal full segments once more data comes in, so
+			// that the final segment is never empty unless the
+			// whole archive is
+			if err := ew.seal(false); err != nil {
+				return 0, err
+			}
+		}
+		m := copy(ew.buf[len(ew.buf):segmentSize], p)
+		ew.buf = ew.buf[:len(ew.buf)+m]
+		p = p[m:]
+	}
+	return n, nil
+}
+
+func (ew *encryptingWriter) Close() error {
+	return ew.seal(true)
+}
+
+// decryptingReader decrypts what it reads from the underlying reader.
+type decryptingReader struct {
+	r       *bufio.Reader
+	aead    cipher.AEAD
+	buf     []byte
+	out     []byte
+	nonce   []byte
+	counter uint64
+	done    bool
+}
+
+func newDecryptingReader(r io.Reader, dataKey []byte) (io.Reader, error) {
+	salt := make([]byte, archiveSaltSize)
+	if _, err := io.ReadFull(r, salt); err != nil {
+		return nil, fmt.Errorf("cannot read encrypted archive: %v", err)
+	}
+	k, err := archiveKey(dataKey, salt)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := chacha20poly1305.New(k)
+	if err != nil {
+		return nil, err
+	}
+	return &decryptingReader{
+		r:     bufio.NewReader(r),
+		aead:  aead,
+		buf:   make([]byte, segmentSize+aead.Overhead()),
+		nonce: make([]byte, aead.NonceSize()),
+	}, nil
+}
+
+func (dr *decryptingReader) Read(p []byte) (int, error) {
+	for len(dr.out) == 0 {
+		if dr.done {
+			return 0, io.EOF
+		}
+		n, err := io.ReadFull(dr.r, dr.buf)
+		switch err {
+		case nil:
+			// the segment is the last one only if nothing follows
+			_, err := dr.r.Peek(1)
+			dr.done = err == io.EOF
+		case io.ErrUnexpectedEOF:
+			dr.done = true
+		case io.EOF:
+			return 0, errors.New("cannot decrypt archive: unexpected end of data")
+		default:
+			return 0, err
+		}
+		segmentNonce(dr.nonce, dr.counter, dr.done)
+		dr.counter++
+		out, err := dr.aead.Open(dr.buf[:0], dr.nonce, dr.buf[:n], nil)
+		if err != nil {
+			return 0, errors.New("cannot decrypt archive: data is corrupted or was tampered with")
+		}
+		dr.out = out
+	}
+	n := copy(p, dr.out)
+	dr.out = dr.out[n:]
+	return n, nil
+}
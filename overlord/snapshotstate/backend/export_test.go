@@ -20,6 +20,7 @@
 package backend
 
 import (
+	"context"
 	"os"
 	"os/exec"
 	"os/user"
@@ -41,6 +42,9 @@ var (
 	NewMultiError = newMultiError
 
 	AddSnapDirToZip = addSnapDirToZip
+
+	NewEncryptingWriter = newEncryptingWriter
+	NewDecryptingReader = newDecryptingReader
 )
 
 func MockIsTesting(newIsTesting bool) func() {
@@ -155,3 +159,20 @@ func MockReadSnapshotYaml(f func(si *snap.Info) (*snap.SnapshotOptions, error))
 		snapReadSnapshotYaml = oldReadSnapshotYaml
 	}
 }
+
+// ChunkData stores data in the chunk store and returns the hashes of the
+// chunks it was cut into.
+func ChunkData(ctx context.Context, data []byte) ([]string, error) {
+	cw := newChunkWriter(ctx)
+	if _, err := cw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := cw.Close(); err != nil {
+		return nil, err
+	}
+	sums := make([]string, len(cw.list.Chunks))
+	for i, ref := range cw.list.Chunks {
+		sums[i] = ref.SHA3_384
+	}
+	return sums, nil
+}
@@ -51,6 +51,9 @@ const ExtractFnameSetID = 0
 type Reader struct {
 	*os.File
 	client.Snapshot
+
+	// dataKey is set once an encrypted snapshot is unlocked
+	dataKey []byte
 }
 
 // Open a Snapshot given its full filename.
@@ -143,15 +146,42 @@ func Open(fn string, setID uint64) (reader *Reader, e error) {
 	return reader, nil
 }
 
+// Unlock makes the data of an encrypted snapshot readable, given the
+// passphrase or private key it was encrypted for. It does nothing if the
+// snapshot is not encrypted.
+func (r *Reader) Unlock(key *EncryptionKey) error {
+	if r.Encryption == nil {
+		return nil
+	}
+	dataKey, err := unwrapDataKey(r.Encryption, key)
+	if err != nil {
+		return err
+	}
+	r.dataKey = dataKey
+	return nil
+}
+
 func (r *Reader) checkOne(ctx context.Context, entry string, hasher hash.Hash) error {
-	body, reportedSize, err := zipMember(r.File, entry)
+	body, reportedSize, err := snapshotMember(r.File, entry)
 	if err != nil {
 		return err
 	}
 	defer body.Close()
 
 	expectedHash := r.SHA3_384[entry]
-	readSize, err := io.Copy(io.MultiWriter(osutil.ContextWriter(ctx), hasher), body)
+	var readSize int64
+	if r.dataKey == nil {
+		readSize, err = io.Copy(io.MultiWriter(osutil.ContextWriter(ctx), hasher), body)
+	} else {
+		// also check that the archive decrypts
+		var sz osutil.Sizer
+		var dec io.Reader
+		dec, err = newDecryptingReader(io.TeeReader(body, io.MultiWriter(hasher, &sz)), r.dataKey)
+		if err == nil {
+			_, err = io.Copy(osutil.ContextWriter(ctx), dec)
+		}
+		readSize = sz.Size()
+	}
 	if err != nil {
 		return err
 	}
@@ -166,7 +196,8 @@ func (r *Reader) checkOne(ctx context.Context, entry string, hasher hash.Hash) e
 	return nil
 }
 
-// Check that the data contained in the snapshot matches its hashsums.
+// Check that the data contained in the snapshot matches its hashsums. If the
+// snapshot is encrypted and was unlocked, also check that its data decrypts.
 func (r *Reader) Check(ctx context.Context, usernames []string) error {
 	sort.Strings(usernames)
 
@@ -198,6 +229,13 @@ type Logf func(format string, args ...interface{})
 // or the one in the snapshot) with that contained in the snapshot. It keeps
 // track of the old data in the task so it can be undone (or cleaned up).
 func (r *Reader) Restore(ctx context.Context, current snap.Revision, usernames []string, logf Logf, opts *dirs.SnapDirOptions) (rs *RestoreState, e error) {
+	return r.RestoreInto(ctx, r.Snap, current, usernames, logf, opts)
+}
+
+// RestoreInto is like Restore, but places the data into the directories of
+// the given snap instance instead of the one the snapshot was taken of. It
+// is up to the caller to check that the instance can use the data.
+func (r *Reader) RestoreInto(ctx context.Context, instanceName string, current snap.Revision, usernames []string, logf Logf, opts *dirs.SnapDirOptions) (rs *RestoreState, e error) {
 	rs = &RestoreState{}
 	defer func() {
 		if e != nil {
@@ -207,9 +245,13 @@ func (r *Reader) Restore(ctx context.Context, current snap.Revision, usernames [
 		}
 	}()
 
+	if r.Encryption != nil && r.dataKey == nil {
+		return rs, ErrEncryptionKeyRequired
+	}
+
 	sort.Strings(usernames)
 	isRoot := sys.Geteuid() == 0
-	si := snap.MinimalPlaceInfo(r.Snap, r.Revision)
+	si := snap.MinimalPlaceInfo(instanceName, r.Revision)
 	hasher := crypto.SHA3_384.New()
 	var sz osutil.Sizer
 
@@ -311,14 +353,20 @@ func (r *Reader) Restore(ctx context.Context, current snap.Revision, usernames [
 
 		logger.Debugf("Restoring %q from %q into %q.", entry, r.Name(), tempdir)
 
-		body, expectedSize, err := zipMember(r.File, entry)
+		body, expectedSize, err := snapshotMember(r.File, entry)
 		if err != nil {
 			return rs, err
 		}
 
 		expectedHash := r.SHA3_384[entry]
 
-		tr := io.TeeReader(body, io.MultiWriter(hasher, &sz))
+		var tr io.Reader = io.TeeReader(body, io.MultiWriter(hasher, &sz))
+		if r.dataKey != nil {
+			tr, err = newDecryptingReader(tr, r.dataKey)
+			if err != nil {
+				return rs, err
+			}
+		}
 
 		// resist the temptation of using archive/tar unless it's proven
 		// that calling out to tar has issues -- there are a lot of
@@ -51,6 +51,9 @@ var (
 
 	SetSnapshotOpInProgress = setSnapshotOpInProgress
 
+	CacheSnapshotKey  = cacheSnapshotKey
+	CachedSnapshotKey = cachedSnapshotKey
+
 	DefaultAutomaticSnapshotExpiration = defaultAutomaticSnapshotExpiration
 )
 
@@ -123,7 +126,7 @@ func MockBackendList(f func(ctx context.Context, setID uint64, snapNames []strin
 	}
 }
 
-func MockBackendRestore(f func(*backend.Reader, context.Context, snap.Revision, []string, backend.Logf, *dirs.SnapDirOptions) (*backend.RestoreState, error)) (restore func()) {
+func MockBackendRestore(f func(*backend.Reader, context.Context, string, snap.Revision, []string, backend.Logf, *dirs.SnapDirOptions) (*backend.RestoreState, error)) (restore func()) {
 	old := backendRestore
 	backendRestore = f
 	return func() {
@@ -171,6 +174,38 @@ func MockBackenCleanupAbandondedImports(f func() (int, error)) (restore func())
 	}
 }
 
+func MockBackendGarbageCollectChunks(f func(context.Context) (int, error)) (restore func()) {
+	old := backendGarbageCollectChunks
+	backendGarbageCollectChunks = f
+	return func() {
+		backendGarbageCollectChunks = old
+	}
+}
+
+func MockBackendCheckEncryptionKey(f func(*client.SnapshotEncryption, *backend.EncryptionKey) error) (restore func()) {
+	old := backendCheckEncryptionKey
+	backendCheckEncryptionKey = f
+	return func() {
+		backendCheckEncryptionKey = old
+	}
+}
+
+func MockTimeNow(f func() time.Time) (restore func()) {
+	old := timeNow
+	timeNow = f
+	return func() {
+		timeNow = old
+	}
+}
+
+func MockOsutilCheckFreeSpace(f func(string, uint64) error) (restore func()) {
+	old := osutilCheckFreeSpace
+	osutilCheckFreeSpace = f
+	return func() {
+		osutilCheckFreeSpace = old
+	}
+}
+
 func MockBackendEstimateSnapshotSize(f func(*snap.Info, []string, *dirs.SnapDirOptions) (uint64, error)) (restore func()) {
 	old := backendEstimateSnapshotSize
 	backendEstimateSnapshotSize = f
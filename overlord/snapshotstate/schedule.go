@@ -0,0 +1,412 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapshotstate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/snapshotstate/backend"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/strutil"
+	"github.com/snapcore/snapd/timeutil"
+)
+
+const (
+	// scheduled snapshot sets kept if snapshots.schedule.retention.count
+	// is not set
+	defaultScheduledSnapshotRetentionCount = 7
+	// the schedule grammar goes up to monthly events, make sure those
+	// are never postponed
+	maxScheduledSnapshotPostponement = 32 * 24 * time.Hour
+	// extra space required on top of the estimated size of a
+	// scheduled snapshot set
+	scheduledSnapshotSpaceMargin = 5 * 1024 * 1024
+)
+
+var (
+	timeNow              = time.Now
+	osutilCheckFreeSpace = osutil.CheckFreeSpace
+)
+
+// scheduledSnapshotPolicy is the snapshots.schedule.* configuration.
+type scheduledSnapshotPolicy struct {
+	timer    []*timeutil.Schedule
+	timerStr string
+	// snaps is empty for all snaps
+	snaps []string
+	count int
+	// age is zero if sets are not pruned by age
+	age time.Duration
+}
+
+func coreConfigString(tr *config.Transaction, key string) (string, error) {
+	var v interface{}
+	if err := tr.Get("core", key, &v); err != nil {
+		if config.IsNoOption(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	if v == nil {
+		return "", nil
+	}
+	return fmt.Sprintf("%v", v), nil
+}
+
+// scheduledSnapshotsPolicy returns the configured snapshot schedule, or nil
+// if snapshots are not scheduled.
+// The state needs to be locked by the caller.
+func scheduledSnapshotsPolicy(st *state.State) (*scheduledSnapshotPolicy, error) {
+	tr := config.NewTransaction(st)
+	timerStr, err := coreConfigString(tr, "snapshots.schedule.timer")
+	if err != nil || timerStr == "" {
+		return nil, err
+	}
+	timer, err := timeutil.ParseSchedule(timerStr)
+	if err != nil {
+		return nil, fmt.Errorf("snapshots.schedule.timer cannot be parsed: %v", err)
+	}
+	policy := &scheduledSnapshotPolicy{
+		timer:    timer,
+		timerStr: timerStr,
+		count:    defaultScheduledSnapshotRetentionCount,
+	}
+
+	snapsStr, err := coreConfigString(tr, "snapshots.schedule.snaps")
+	if err != nil {
+		return nil, err
+	}
+	policy.snaps = strutil.CommaSeparatedList(snapsStr)
+
+	countStr, err := coreConfigString(tr, "snapshots.schedule.retention.count")
+	if err != nil {
+		return nil, err
+	}
+	if countStr != "" {
+		count, err := strconv.Atoi(countStr)
+		if err != nil || count < 1 {
+			return nil, fmt.Errorf("snapshots.schedule.retention.count must be a positive number")
+		}
+		policy.count = count
+	}
+
+	ageStr, err := coreConfigString(tr, "snapshots.schedule.retention.age")
+	if err != nil {
+		return nil, err
+	}
+	if ageStr != "" && ageStr != "no" {
+		policy.age, err = time.ParseDuration(ageStr)
+		if err != nil {
+			return nil, fmt.Errorf("snapshots.schedule.retention.age cannot be parsed: %v", err)
+		}
+	}
+
+	return policy, nil
+}
+
+// markScheduled records in the state that the given snapshot set was taken
+// by the snapshot schedule.
+// The state needs to be locked by the caller.
+func markScheduled(st *state.State, setID uint64) error {
+	var snapshots map[uint64]*snapshotState
+	err := st.Get("snapshots", &snapshots)
+	if err != nil && !errors.Is(err, state.ErrNoState) {
+		return err
+	}
+	if snapshots == nil {
+		snapshots = make(map[uint64]*snapshotState)
+	}
+	snapshots[setID] = &snapshotState{Scheduled: true}
+	st.Set("snapshots", snapshots)
+	return nil
+}
+
+// scheduledSnapshotSets returns the IDs of the snapshot sets taken by the
+// snapshot schedule.
+// The state needs to be locked by the caller.
+func scheduledSnapshotSets(st *state.State) (map[uint64]bool, error) {
+	var snapshots map[uint64]*snapshotState
+	err := st.Get("snapshots", &snapshots)
+	if err != nil && !errors.Is(err, state.ErrNoState) {
+		return nil, err
+	}
+	scheduled := make(map[uint64]bool)
+	for setID, snapshotSet := range snapshots {
+		if snapshotSet.Scheduled {
+			scheduled[setID] = true
+		}
+	}
+	return scheduled, nil
+}
+
+func scheduledSnapshotInFlight(st *state.State) bool {
+	for _, chg := range st.Changes() {
+		if chg.Kind() == "scheduled-snapshot" && !chg.Status().Ready() {
+			return true
+		}
+	}
+	return false
+}
+
+// reportScheduledSnapshots warns about scheduled snapshots that failed,
+// returning whether any completed since the last report.
+// The state needs to be locked by the caller.
+func reportScheduledSnapshots(st *state.State) (completed bool) {
+	for _, chg := range st.Changes() {
+		if chg.Kind() != "scheduled-snapshot" || !chg.Status().Ready() {
+			continue
+		}
+		var reported bool
+		if err := chg.Get("reported", &reported); err != nil && !errors.Is(err, state.ErrNoState) {
+			logger.Noticef("cannot get state of scheduled snapshot change %s: %v", chg.ID(), err)
+			continue
+		}
+		if reported {
+			continue
+		}
+		if err := chg.Err(); err != nil {
+			var setID uint64
+			chg.Get("snapshot-set-id", &setID)
+			st.Warnf("scheduled snapshot set #%d failed: %v", setID, err)
+		} else if chg.Status() == state.DoneStatus {
+			completed = true
+		}
+		chg.Set("reported", true)
+	}
+	return completed
+}
+
+// pruneScheduledSnapshots removes the scheduled snapshot sets older than the
+// configured age, and all but the configured count of the newest ones.
+// The state needs to be locked by the caller.
+func (mgr *SnapshotManager) pruneScheduledSnapshots(policy *scheduledSnapshotPolicy) error {
+	st := mgr.state
+	scheduled, err := scheduledSnapshotSets(st)
+	if err != nil {
+		return fmt.Errorf("internal error: cannot determine scheduled snapshots: %v", err)
+	}
+	if len(scheduled) == 0 {
+		return nil
+	}
+
+	setTimes := make(map[uint64]time.Time, len(scheduled))
+	files := make(map[uint64][]string, len(scheduled))
+	err = backendIter(context.TODO(), func(r *backend.Reader) error {
+		if !scheduled[r.SetID] {
+			return nil
+		}
+		if t, ok := setTimes[r.SetID]; !ok || r.Time.Before(t) {
+			setTimes[r.SetID] = r.Time
+		}
+		files[r.SetID] = append(files[r.SetID], r.Name())
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("cannot process scheduled snapshots: %v", err)
+	}
+
+	setIDs := make([]uint64, 0, len(scheduled))
+	for setID := range scheduled {
+		if _, ok := files[setID]; !ok {
+			if scheduledSnapshotInFlight(st) {
+				// the set may still be being saved
+				continue
+			}
+			// the files are gone, probably forgotten by hand
+			if err := removeSnapshotState(st, setID); err != nil {
+				return fmt.Errorf("internal error: cannot remove state of snapshot set %d: %v", setID, err)
+			}
+			continue
+		}
+		setIDs = append(setIDs, setID)
+	}
+	// newest first
+	sort.Slice(setIDs, func(i, j int) bool { return setIDs[i] > setIDs[j] })
+
+	cutoff := time.Time{}
+	if policy.age > 0 {
+		cutoff = timeNow().Add(-policy.age)
+	}
+	for i, setID := range setIDs {
+		if i < policy.count && !setTimes[setID].Before(cutoff) {
+			continue
+		}
+		// forget needs to conflict with check, restore and export
+		if err := checkSnapshotConflict(st, setID, "export-snapshot",
+			"check-snapshot", "restore-snapshot"); err != nil {
+			// try again next time
+			continue
+		}
+		// see forgetExpiredSnapshots about the order of things
+		if err := removeSnapshotState(st, setID); err != nil {
+			return fmt.Errorf("internal error: cannot remove state of snapshot set %d: %v", setID, err)
+		}
+		for _, fn := range files[setID] {
+			if err := osRemove(fn); err != nil {
+				return fmt.Errorf("cannot remove snapshot file %q: %v", fn, err)
+			}
+		}
+		logger.Debugf("Pruned scheduled snapshot set #%d.", setID)
+		mgr.chunksMayBeUnused = true
+	}
+	return nil
+}
+
+// scheduledSnapshotSnaps returns the installed and active snaps to include
+// in a scheduled snapshot set, warning about the configured ones that are
+// not installed.
+// The state needs to be locked by the caller.
+func scheduledSnapshotSnaps(st *state.State, policy *scheduledSnapshotPolicy) ([]string, error) {
+	active, err := allActiveSnapNames(st)
+	if err != nil || len(policy.snaps) == 0 {
+		return active, err
+	}
+	var snaps, missing []string
+	for _, name := range policy.snaps {
+		if strutil.SortedListContains(active, name) {
+			snaps = append(snaps, name)
+		} else {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		st.Warnf("cannot include snaps %s in scheduled snapshot: not installed or not active", strutil.Quoted(missing))
+	}
+	return snaps, nil
+}
+
+// checkScheduledSnapshotSpace checks that there is enough disk space to
+// take a snapshot of the given snaps.
+// The state needs to be locked by the caller.
+func checkScheduledSnapshotSpace(st *state.State, snaps []string) error {
+	var total uint64
+	for _, name := range snaps {
+		sz, err := EstimateSnapshotSize(st, name, nil)
+		if err != nil {
+			return fmt.Errorf("cannot estimate size of snapshot of %q: %v", name, err)
+		}
+		total += sz
+	}
+	return osutilCheckFreeSpace(dirs.SnapdStateDir(dirs.GlobalRootDir), total+scheduledSnapshotSpaceMargin)
+}
+
+// ensureScheduledSnapshots takes a snapshot of the configured snaps when
+// the snapshot schedule says so, pruning old scheduled snapshot sets once
+// the new one was taken.
+func (mgr *SnapshotManager) ensureScheduledSnapshots() error {
+	st := mgr.state
+	st.Lock()
+	defer st.Unlock()
+
+	completed := reportScheduledSnapshots(st)
+
+	policy, err := scheduledSnapshotsPolicy(st)
+	if err != nil {
+		return err
+	}
+	if policy == nil {
+		mgr.nextScheduledSnapshot = time.Time{}
+		return nil
+	}
+	if completed {
+		// only now that the new set is there, so that a skipped or
+		// failed snapshot does not cost a retained one
+		if err := mgr.pruneScheduledSnapshots(policy); err != nil {
+			st.Warnf("cannot prune scheduled snapshots: %v", err)
+		}
+	}
+	if policy.timerStr != mgr.lastSnapshotSchedule {
+		logger.Debugf("Snapshot timer changed.")
+		mgr.nextScheduledSnapshot = time.Time{}
+		mgr.lastSnapshotSchedule = policy.timerStr
+	}
+
+	now := timeNow()
+	if mgr.nextScheduledSnapshot.IsZero() {
+		var last time.Time
+		if err := st.Get("last-scheduled-snapshot", &last); err != nil && !errors.Is(err, state.ErrNoState) {
+			return err
+		}
+		if last.IsZero() {
+			// start counting from when the schedule was first seen,
+			// rather than taking a snapshot right away
+			last = now
+			st.Set("last-scheduled-snapshot", last)
+		}
+		mgr.nextScheduledSnapshot = now.Add(timeutil.Next(policy.timer, last, maxScheduledSnapshotPostponement))
+		logger.Debugf("Next scheduled snapshot at %s.", mgr.nextScheduledSnapshot.Format(time.RFC3339))
+	}
+	if mgr.nextScheduledSnapshot.After(now) || scheduledSnapshotInFlight(st) {
+		return nil
+	}
+
+	skip := func(format string, args ...interface{}) error {
+		st.Warnf("skipping scheduled snapshot: "+format, args...)
+		st.Set("last-scheduled-snapshot", now)
+		mgr.nextScheduledSnapshot = time.Time{}
+		return nil
+	}
+
+	snaps, err := scheduledSnapshotSnaps(st, policy)
+	if err != nil {
+		return err
+	}
+	if len(snaps) == 0 {
+		return skip("no snaps to snapshot")
+	}
+	if err := checkScheduledSnapshotSpace(st, snaps); err != nil {
+		return skip("%v", err)
+	}
+
+	setID, snaps, ts, err := Save(st, snaps, nil, nil, nil)
+	if err != nil {
+		var conflictErr *snapstate.ChangeConflictError
+		if errors.As(err, &conflictErr) {
+			// try again on the next Ensure
+			logger.Debugf("Postponing scheduled snapshot: %v", err)
+			return nil
+		}
+		return skip("%v", err)
+	}
+	if err := markScheduled(st, setID); err != nil {
+		return err
+	}
+
+	chg := st.NewChange("scheduled-snapshot", fmt.Sprintf("Scheduled snapshot of snaps %s", strutil.Quoted(snaps)))
+	chg.AddAll(ts)
+	chg.Set("snapshot-set-id", setID)
+	chg.Set("api-data", map[string]interface{}{"snap-names": snaps})
+
+	st.Set("last-scheduled-snapshot", now)
+	mgr.nextScheduledSnapshot = time.Time{}
+	st.EnsureBefore(0)
+
+	return nil
+}
@@ -0,0 +1,369 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapshotstate_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/snapshotstate"
+	"github.com/snapcore/snapd/overlord/snapshotstate/backend"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/testutil"
+	"github.com/snapcore/snapd/timeutil"
+)
+
+func (s *snapshotSuite) mockScheduledSnapshotEnv(c *check.C) {
+	s.AddCleanup(snapshotstate.MockSnapstateCurrentInfo(func(_ *state.State, name string) (*snap.Info, error) {
+		return &snap.Info{SideInfo: snap.SideInfo{RealName: name, Revision: snap.R(1)}}, nil
+	}))
+	s.AddCleanup(snapshotstate.MockConfigGetSnapConfig(func(*state.State, string) (*json.RawMessage, error) {
+		return nil, nil
+	}))
+	s.AddCleanup(snapshotstate.MockBackendEstimateSnapshotSize(func(*snap.Info, []string, *dirs.SnapDirOptions) (uint64, error) {
+		return 100, nil
+	}))
+	s.AddCleanup(snapshotstate.MockBackendGarbageCollectChunks(func(context.Context) (int, error) {
+		return 0, nil
+	}))
+	s.AddCleanup(snapshotstate.MockOsutilCheckFreeSpace(func(string, uint64) error {
+		return nil
+	}))
+}
+
+func newScheduleTestManager(c *check.C) (*state.State, *snapshotstate.SnapshotManager) {
+	st := state.New(nil)
+	mgr := snapshotstate.Manager(st, state.NewTaskRunner(st))
+
+	st.Lock()
+	defer st.Unlock()
+	for name, active := range map[string]bool{"a-snap": true, "b-snap": true, "c-snap": false} {
+		snapstate.Set(st, name, &snapstate.SnapState{
+			Active: active,
+			Sequence: []*snap.SideInfo{
+				{RealName: name, Revision: snap.R(1)},
+			},
+			Current: snap.R(1),
+		})
+	}
+	return st, mgr
+}
+
+func setScheduleConfig(st *state.State, conf map[string]interface{}) {
+	tr := config.NewTransaction(st)
+	for k, v := range conf {
+		tr.Set("core", k, v)
+	}
+	tr.Commit()
+}
+
+func scheduledChanges(st *state.State) []*state.Change {
+	var chgs []*state.Change
+	for _, chg := range st.Changes() {
+		if chg.Kind() == "scheduled-snapshot" {
+			chgs = append(chgs, chg)
+		}
+	}
+	return chgs
+}
+
+func (s *snapshotSuite) TestEnsureScheduledSnapshotsNoTimer(c *check.C) {
+	s.mockScheduledSnapshotEnv(c)
+
+	st, mgr := newScheduleTestManager(c)
+	c.Assert(mgr.Ensure(), check.IsNil)
+
+	st.Lock()
+	defer st.Unlock()
+	c.Check(scheduledChanges(st), check.HasLen, 0)
+	var last time.Time
+	c.Check(st.Get("last-scheduled-snapshot", &last), testutil.ErrorIs, state.ErrNoState)
+}
+
+func (s *snapshotSuite) TestEnsureScheduledSnapshotsFirstTimeWaits(c *check.C) {
+	s.mockScheduledSnapshotEnv(c)
+	now := time.Date(2023, 3, 1, 12, 0, 0, 0, time.Local)
+	defer snapshotstate.MockTimeNow(func() time.Time { return now })()
+	defer timeutil.MockTimeNow(func() time.Time { return now })()
+
+	st, mgr := newScheduleTestManager(c)
+	st.Lock()
+	setScheduleConfig(st, map[string]interface{}{"snapshots.schedule.timer": "6:00"})
+	st.Unlock()
+
+	c.Assert(mgr.Ensure(), check.IsNil)
+
+	st.Lock()
+	defer st.Unlock()
+	c.Check(scheduledChanges(st), check.HasLen, 0)
+	var last time.Time
+	c.Assert(st.Get("last-scheduled-snapshot", &last), check.IsNil)
+	c.Check(last.Equal(now), check.Equals, true)
+}
+
+func (s *snapshotSuite) TestEnsureScheduledSnapshots(c *check.C) {
+	s.mockScheduledSnapshotEnv(c)
+	now := time.Now()
+	defer snapshotstate.MockTimeNow(func() time.Time { return now })()
+
+	st, mgr := newScheduleTestManager(c)
+	st.Lock()
+	setScheduleConfig(st, map[string]interface{}{
+		"snapshots.schedule.timer": "0:00-23:59",
+		"snapshots.schedule.snaps": "a-snap,c-snap",
+	})
+	st.Set("last-scheduled-snapshot", now.Add(-48*time.Hour))
+	st.Unlock()
+
+	c.Assert(mgr.Ensure(), check.IsNil)
+
+	st.Lock()
+	defer st.Unlock()
+	chgs := scheduledChanges(st)
+	c.Assert(chgs, check.HasLen, 1)
+	c.Check(chgs[0].Summary(), check.Equals, `Scheduled snapshot of snaps "a-snap"`)
+	tasks := chgs[0].Tasks()
+	c.Assert(tasks, check.HasLen, 1)
+	c.Check(tasks[0].Kind(), check.Equals, "save-snapshot")
+	var setID uint64
+	c.Assert(chgs[0].Get("snapshot-set-id", &setID), check.IsNil)
+
+	var snapshots map[uint64]map[string]interface{}
+	c.Assert(st.Get("snapshots", &snapshots), check.IsNil)
+	c.Check(snapshots[setID]["scheduled"], check.Equals, true)
+
+	var last time.Time
+	c.Assert(st.Get("last-scheduled-snapshot", &last), check.IsNil)
+	c.Check(last.Equal(now), check.Equals, true)
+
+	// c-snap is not active
+	warns := st.AllWarnings()
+	c.Assert(warns, check.HasLen, 1)
+	c.Check(warns[0].String(), check.Equals, `cannot include snaps "c-snap" in scheduled snapshot: not installed or not active`)
+
+	// nothing else happens while the change is in flight, or until the
+	// next window
+	st.Unlock()
+	c.Assert(mgr.Ensure(), check.IsNil)
+	st.Lock()
+	c.Check(scheduledChanges(st), check.HasLen, 1)
+
+	// failures are reported once
+	tasks[0].SetStatus(state.ErrorStatus)
+	tasks[0].Errorf("boom")
+	st.Unlock()
+	c.Assert(mgr.Ensure(), check.IsNil)
+	c.Assert(mgr.Ensure(), check.IsNil)
+	st.Lock()
+	warns = st.AllWarnings()
+	c.Assert(warns, check.HasLen, 2)
+	c.Check(warns[1].String(), check.Matches, `(?s)scheduled snapshot set #1 failed: cannot perform the following tasks:.*boom.*`)
+}
+
+func (s *snapshotSuite) TestEnsureScheduledSnapshotsLowDiskSpace(c *check.C) {
+	s.mockScheduledSnapshotEnv(c)
+	var required uint64
+	defer snapshotstate.MockOsutilCheckFreeSpace(func(_ string, sz uint64) error {
+		required = sz
+		return &osutil.NotEnoughDiskSpaceError{Path: "/var/lib/snapd", Delta: 10}
+	})()
+	now := time.Now()
+	defer snapshotstate.MockTimeNow(func() time.Time { return now })()
+
+	var removed []string
+	defer snapshotstate.MockOsRemove(func(fn string) error {
+		removed = append(removed, fn)
+		return nil
+	})()
+	shotfile, err := os.Create(filepath.Join(c.MkDir(), "1_a-snap.zip"))
+	c.Assert(err, check.IsNil)
+	defer shotfile.Close()
+	defer snapshotstate.MockBackendIter(func(_ context.Context, f func(*backend.Reader) error) error {
+		return f(&backend.Reader{
+			Snapshot: client.Snapshot{SetID: 1, Snap: "a-snap", Time: now.Add(-24 * time.Hour)},
+			File:     shotfile,
+		})
+	})()
+
+	st, mgr := newScheduleTestManager(c)
+	st.Lock()
+	setScheduleConfig(st, map[string]interface{}{
+		"snapshots.schedule.timer":           "0:00-23:59",
+		"snapshots.schedule.retention.count": 1,
+	})
+	st.Set("last-scheduled-snapshot", now.Add(-48*time.Hour))
+	st.Set("snapshots", map[uint64]interface{}{
+		1: map[string]interface{}{"scheduled": true},
+	})
+	st.Unlock()
+
+	c.Assert(mgr.Ensure(), check.IsNil)
+
+	st.Lock()
+	defer st.Unlock()
+	c.Check(scheduledChanges(st), check.HasLen, 0)
+	// the retained set is kept as there is no new one
+	c.Check(removed, check.HasLen, 0)
+	// two snaps of 100 bytes plus the margin
+	c.Check(required, check.Equals, uint64(200+5*1024*1024))
+	warns := st.AllWarnings()
+	c.Assert(warns, check.HasLen, 1)
+	c.Check(warns[0].String(), check.Matches, `skipping scheduled snapshot: insufficient space in "/var/lib/snapd".*`)
+	// and it is not retried until the next window
+	var last time.Time
+	c.Assert(st.Get("last-scheduled-snapshot", &last), check.IsNil)
+	c.Check(last.Equal(now), check.Equals, true)
+}
+
+func (s *snapshotSuite) TestEnsureScheduledSnapshotsPrunes(c *check.C) {
+	s.mockScheduledSnapshotEnv(c)
+	now := time.Now()
+	defer snapshotstate.MockTimeNow(func() time.Time { return now })()
+
+	var removed []string
+	defer snapshotstate.MockOsRemove(func(fn string) error {
+		removed = append(removed, fn)
+		return nil
+	})()
+	setTimes := map[uint64]time.Time{
+		// not scheduled
+		1: now.Add(-100 * time.Hour),
+		2: now.Add(-72 * time.Hour),
+		3: now.Add(-48 * time.Hour),
+		4: now.Add(-24 * time.Hour),
+		5: now.Add(-1 * time.Hour),
+	}
+	dir := c.MkDir()
+	defer snapshotstate.MockBackendIter(func(_ context.Context, f func(*backend.Reader) error) error {
+		setIDs := make([]uint64, 0, len(setTimes))
+		for setID := range setTimes {
+			setIDs = append(setIDs, setID)
+		}
+		sort.Slice(setIDs, func(i, j int) bool { return setIDs[i] < setIDs[j] })
+		for _, setID := range setIDs {
+			for _, name := range []string{"a-snap", "b-snap"} {
+				file, err := os.Create(filepath.Join(dir, fmt.Sprintf("%d_%s", setID, name)))
+				c.Assert(err, check.IsNil)
+				defer file.Close()
+				r := &backend.Reader{
+					Snapshot: client.Snapshot{SetID: setID, Snap: name, Time: setTimes[setID]},
+					File:     file,
+				}
+				if err := f(r); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})()
+
+	st, mgr := newScheduleTestManager(c)
+	st.Lock()
+	setScheduleConfig(st, map[string]interface{}{
+		"snapshots.schedule.timer":           "0:00-23:59",
+		"snapshots.schedule.retention.count": 3,
+		"snapshots.schedule.retention.age":   "30h",
+	})
+	st.Set("last-scheduled-snapshot", now.Add(-48*time.Hour))
+	st.Set("snapshots", map[uint64]interface{}{
+		2: map[string]interface{}{"scheduled": true},
+		3: map[string]interface{}{"scheduled": true},
+		4: map[string]interface{}{"scheduled": true},
+		5: map[string]interface{}{"scheduled": true},
+		// files are gone
+		6: map[string]interface{}{"scheduled": true},
+	})
+	st.Set("last-snapshot-set-id", 6)
+	st.Unlock()
+
+	c.Assert(mgr.Ensure(), check.IsNil)
+
+	// nothing is pruned before the new set is taken
+	c.Check(removed, check.HasLen, 0)
+	st.Lock()
+	chgs := scheduledChanges(st)
+	c.Assert(chgs, check.HasLen, 1)
+	var setID uint64
+	c.Assert(chgs[0].Get("snapshot-set-id", &setID), check.IsNil)
+	for _, t := range chgs[0].Tasks() {
+		t.SetStatus(state.DoneStatus)
+	}
+	setTimes[setID] = now
+	st.Unlock()
+
+	c.Assert(mgr.Ensure(), check.IsNil)
+
+	st.Lock()
+	defer st.Unlock()
+	// the new set and the 2 newest ones are kept, and 3 is too old anyway
+	c.Check(removed, check.DeepEquals, []string{
+		filepath.Join(dir, "3_a-snap"), filepath.Join(dir, "3_b-snap"),
+		filepath.Join(dir, "2_a-snap"), filepath.Join(dir, "2_b-snap"),
+	})
+
+	var snapshots map[uint64]map[string]interface{}
+	c.Assert(st.Get("snapshots", &snapshots), check.IsNil)
+	c.Check(snapshots, check.HasLen, 3)
+	c.Check(snapshots[4], check.NotNil)
+	c.Check(snapshots[5], check.NotNil)
+	c.Check(snapshots[setID], check.NotNil)
+	c.Check(st.AllWarnings(), check.HasLen, 0)
+}
+
+func (s *snapshotSuite) TestListDecoratesScheduled(c *check.C) {
+	defer snapshotstate.MockBackendList(func(context.Context, uint64, []string) ([]client.SnapshotSet, error) {
+		return []client.SnapshotSet{
+			{ID: 1, Snapshots: []*client.Snapshot{{SetID: 1, Snap: "a-snap"}}},
+			{ID: 2, Snapshots: []*client.Snapshot{{SetID: 2, Snap: "a-snap"}}},
+			{ID: 3, Snapshots: []*client.Snapshot{{SetID: 3, Snap: "a-snap"}}},
+		}, nil
+	})()
+
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+	st.Set("snapshots", map[uint64]interface{}{
+		1: map[string]interface{}{"expiry-time": "2101-03-11T11:24:00Z"},
+		2: map[string]interface{}{"scheduled": true},
+	})
+
+	sets, err := snapshotstate.List(context.TODO(), st, 0, nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(sets, check.HasLen, 3)
+	c.Check(sets[0].Snapshots[0].Auto, check.Equals, true)
+	c.Check(sets[0].Snapshots[0].Scheduled, check.Equals, false)
+	c.Check(sets[1].Snapshots[0].Auto, check.Equals, false)
+	c.Check(sets[1].Snapshots[0].Scheduled, check.Equals, true)
+	c.Check(sets[2].Snapshots[0].Auto, check.Equals, false)
+	c.Check(sets[2].Snapshots[0].Scheduled, check.Equals, false)
+}
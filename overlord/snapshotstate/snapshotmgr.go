@@ -30,6 +30,7 @@ import (
 
 	"github.com/snapcore/snapd/client"
 	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/features"
 	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/overlord/configstate/config"
 	"github.com/snapcore/snapd/overlord/snapshotstate/backend"
@@ -46,12 +47,13 @@ var (
 	backendOpen          = backend.Open
 	backendSave          = backend.Save
 	backendImport        = backend.Import
-	backendRestore       = (*backend.Reader).Restore // TODO: look into using an interface instead
+	backendRestore       = (*backend.Reader).RestoreInto // TODO: look into using an interface instead
 	backendCheck         = (*backend.Reader).Check
 	backendRevert        = (*backend.RestoreState).Revert // ditto
 	backendCleanup       = (*backend.RestoreState).Cleanup
 
 	backendCleanupAbandondedImports = backend.CleanupAbandondedImports
+	backendGarbageCollectChunks     = backend.GarbageCollectChunks
 
 	autoExpirationInterval = time.Hour * 24 // interval between forgetExpiredSnapshots runs as part of Ensure()
 
@@ -63,6 +65,14 @@ type SnapshotManager struct {
 	state *state.State
 
 	lastForgetExpiredSnapshotTime time.Time
+	// chunksMayBeUnused is set when snapshots were removed and the chunk
+	// store of deduplicated snapshots needs garbage collecting.
+	chunksMayBeUnused bool
+
+	// the snapshots.schedule.timer the next scheduled snapshot was
+	// computed for
+	lastSnapshotSchedule  string
+	nextScheduledSnapshot time.Time
 }
 
 // Manager returns a new SnapshotManager
@@ -77,6 +87,8 @@ func Manager(st *state.State, runner *state.TaskRunner) *SnapshotManager {
 
 	manager := &SnapshotManager{
 		state: st,
+		// clean up after anything interrupted before a restart
+		chunksMayBeUnused: true,
 	}
 	snapstate.RegisterAffectedSnapsByAttr("snapshot-setup", manager.affectedSnaps)
 
@@ -87,12 +99,41 @@ func Manager(st *state.State, runner *state.TaskRunner) *SnapshotManager {
 func (mgr *SnapshotManager) Ensure() error {
 	// process expired snapshots once a day.
 	if time.Now().After(mgr.lastForgetExpiredSnapshotTime.Add(autoExpirationInterval)) {
-		return mgr.forgetExpiredSnapshots()
+		if err := mgr.forgetExpiredSnapshots(); err != nil {
+			return err
+		}
+	}
+
+	if err := mgr.ensureScheduledSnapshots(); err != nil {
+		return err
+	}
+
+	if mgr.chunksLeftByTasks() {
+		mgr.chunksMayBeUnused = true
+	}
+	if mgr.chunksMayBeUnused {
+		mgr.chunksMayBeUnused = !garbageCollectChunks(context.TODO())
 	}
 
 	return nil
 }
 
+// chunksMayBeUnusedKey is used to cache in the state that a task forgot a
+// snapshot but could not garbage collect the chunk store.
+type chunksMayBeUnusedKey struct{}
+
+// chunksLeftByTasks returns whether tasks left unused chunks behind in the
+// store of deduplicated snapshots, clearing the indication.
+func (mgr *SnapshotManager) chunksLeftByTasks() bool {
+	mgr.state.Lock()
+	defer mgr.state.Unlock()
+	if mgr.state.Cached(chunksMayBeUnusedKey{}) == nil {
+		return false
+	}
+	mgr.state.Cache(chunksMayBeUnusedKey{}, nil)
+	return true
+}
+
 func (mgr *SnapshotManager) StartUp() error {
 	if _, err := backendCleanupAbandondedImports(); err != nil {
 		logger.Noticef("cannot cleanup incomplete imports: %v", err)
@@ -132,6 +173,7 @@ func (mgr *SnapshotManager) forgetExpiredSnapshots() error {
 			if err := osRemove(r.Name()); err != nil {
 				return fmt.Errorf("cannot remove snapshot file %q: %v", r.Name(), err)
 			}
+			mgr.chunksMayBeUnused = true
 		}
 		return nil
 	})
@@ -170,6 +212,21 @@ type snapshotSetup struct {
 	Filename string                `json:"filename,omitempty"`
 	Current  snap.Revision         `json:"current"`
 	Auto     bool                  `json:"auto,omitempty"`
+	// Encrypted is set for saves of encrypted snapshots; the key
+	// itself is only ever kept in memory
+	Encrypted bool `json:"encrypted,omitempty"`
+	// Target is the instance the data is restored into, if it is
+	// not the one the snapshot was taken of
+	Target string `json:"target,omitempty"`
+}
+
+// instanceName returns the name of the snap instance whose data is
+// being operated on.
+func (snapshot *snapshotSetup) instanceName() string {
+	if snapshot.Target != "" {
+		return snapshot.Target
+	}
+	return snapshot.Snap
 }
 
 func filename(setID uint64, si *snap.Info) string {
@@ -228,12 +285,29 @@ func doSave(task *state.Task, tomb *tomb.Tomb) error {
 
 	st.Lock()
 	opts, err := getSnapDirOpts(st, snapshot.Snap)
-	st.Unlock()
 	if err != nil {
+		st.Unlock()
 		return err
 	}
+	tr := config.NewTransaction(st)
+	deduplicate, err := features.Flag(tr, features.DeduplicatedSnapshots)
+	st.Unlock()
+	if err != nil && !config.IsNoOption(err) {
+		return err
+	}
+	saveOpts := &backend.SaveOptions{Deduplicate: deduplicate}
+	if snapshot.Encrypted {
+		st.Lock()
+		saveOpts.Encryption = cachedSnapshotKey(task)
+		forgetSnapshotKey(task)
+		st.Unlock()
+		if saveOpts.Encryption == nil {
+			// most likely snapd was restarted
+			return fmt.Errorf("cannot save encrypted snapshot: encryption key is no longer available")
+		}
+	}
 
-	_, err = backendSave(tomb.Context(nil), snapshot.SetID, cur, cfg, snapshot.Users, snapshot.Options, opts)
+	_, err = backendSave(tomb.Context(nil), snapshot.SetID, cur, cfg, snapshot.Users, snapshot.Options, opts, saveOpts)
 	if err != nil {
 		st.Lock()
 		defer st.Unlock()
@@ -254,7 +328,7 @@ func prepareRestore(task *state.Task) (snapshot *snapshotSetup, oldCfg map[strin
 		return nil, nil, nil, taskGetErrMsg(task, err, "snapshot")
 	}
 
-	oldCfg, err = unmarshalSnapConfig(st, snapshot.Snap)
+	oldCfg, err = unmarshalSnapConfig(st, snapshot.instanceName())
 	if err != nil {
 		return nil, nil, nil, err
 	}
@@ -262,6 +336,18 @@ func prepareRestore(task *state.Task) (snapshot *snapshotSetup, oldCfg map[strin
 	if err != nil {
 		return nil, nil, nil, fmt.Errorf("cannot open snapshot: %v", err)
 	}
+	if reader.Encryption != nil {
+		key := cachedSnapshotKey(task)
+		if key == nil {
+			reader.Close()
+			// most likely snapd was restarted
+			return nil, nil, nil, fmt.Errorf("cannot restore encrypted snapshot: decryption key is no longer available")
+		}
+		if err := reader.Unlock(key); err != nil {
+			reader.Close()
+			return nil, nil, nil, fmt.Errorf("cannot restore encrypted snapshot: %v", err)
+		}
+	}
 	// note given the Open succeeded, caller needs to close it when done
 
 	return snapshot, oldCfg, reader, nil
@@ -312,13 +398,13 @@ func doRestore(task *state.Task, tomb *tomb.Tomb) error {
 	}
 
 	st.Lock()
-	opts, err := getSnapDirOpts(st, snapshot.Snap)
+	opts, err := getSnapDirOpts(st, snapshot.instanceName())
 	st.Unlock()
 	if err != nil {
 		return err
 	}
 
-	restoreState, err := backendRestore(reader, tomb.Context(nil), snapshot.Current, snapshot.Users, logf, opts)
+	restoreState, err := backendRestore(reader, tomb.Context(nil), snapshot.instanceName(), snapshot.Current, snapshot.Users, logf, opts)
 	if err != nil {
 		return err
 	}
@@ -332,13 +418,14 @@ func doRestore(task *state.Task, tomb *tomb.Tomb) error {
 	st.Lock()
 	defer st.Unlock()
 
-	if err := configSetSnapConfig(st, snapshot.Snap, raw); err != nil {
+	if err := configSetSnapConfig(st, snapshot.instanceName(), raw); err != nil {
 		backendRevert(restoreState)
 		return fmt.Errorf("cannot set snap config: %v", err)
 	}
 
 	restoreState.Config = oldCfg
 	task.Set("restore-state", restoreState)
+	forgetSnapshotKey(task)
 
 	return nil
 }
@@ -363,7 +450,7 @@ func undoRestore(task *state.Task, _ *tomb.Tomb) error {
 		return fmt.Errorf("cannot marshal saved config: %v", err)
 	}
 
-	if err := configSetSnapConfig(st, snapshot.Snap, raw); err != nil {
+	if err := configSetSnapConfig(st, snapshot.instanceName(), raw); err != nil {
 		return fmt.Errorf("cannot restore saved config: %v", err)
 	}
 
@@ -422,6 +509,8 @@ func doCheck(task *state.Task, tomb *tomb.Tomb) error {
 	st := task.State()
 	st.Lock()
 	err := task.Get("snapshot-setup", &snapshot)
+	key := cachedSnapshotKey(task)
+	forgetSnapshotKey(task)
 	st.Unlock()
 	if err != nil {
 		return taskGetErrMsg(task, err, "snapshot")
@@ -433,11 +522,31 @@ func doCheck(task *state.Task, tomb *tomb.Tomb) error {
 	}
 	defer reader.Close()
 
+	if reader.Encryption != nil && key != nil {
+		if err := reader.Unlock(key); err != nil {
+			return fmt.Errorf("cannot check encrypted snapshot: %v", err)
+		}
+	}
+
 	return backendCheck(reader, tomb.Context(nil), snapshot.Users)
 }
 
-func doForget(task *state.Task, _ *tomb.Tomb) error {
+func doForget(task *state.Task, tomb *tomb.Tomb) error {
 	// note this is also undoSave
+	if err := forgetSnapshot(task); err != nil {
+		return err
+	}
+	if !garbageCollectChunks(tomb.Context(nil)) {
+		// the store may be busy with a save, have Ensure try again
+		st := task.State()
+		st.Lock()
+		st.Cache(chunksMayBeUnusedKey{}, true)
+		st.Unlock()
+	}
+	return nil
+}
+
+func forgetSnapshot(task *state.Task) error {
 	st := task.State()
 	st.Lock()
 	defer st.Unlock()
@@ -461,6 +570,21 @@ func doForget(task *state.Task, _ *tomb.Tomb) error {
 	return osRemove(snapshot.Filename)
 }
 
+// garbageCollectChunks removes the chunks no longer used by any snapshot from
+// the store of deduplicated snapshots, returning whether it did. Failing to do
+// so is not fatal: the chunks will be removed by a later run.
+func garbageCollectChunks(ctx context.Context) bool {
+	if _, err := backendGarbageCollectChunks(ctx); err != nil {
+		if err == backend.ErrChunkStoreBusy {
+			logger.Debugf("Not removing unused snapshot chunks: %v.", err)
+		} else {
+			logger.Noticef("Cannot remove unused snapshot chunks: %v.", err)
+		}
+		return false
+	}
+	return true
+}
+
 func delayedCrossMgrInit() {
 	// hook automatic snapshots into snapstate logic
 	snapstate.AutomaticSnapshot = AutomaticSnapshot
@@ -468,7 +592,7 @@ func delayedCrossMgrInit() {
 	snapstate.EstimateSnapshotSize = EstimateSnapshotSize
 }
 
-func MockBackendSave(f func(context.Context, uint64, *snap.Info, map[string]interface{}, []string, *snap.SnapshotOptions, *dirs.SnapDirOptions) (*client.Snapshot, error)) (restore func()) {
+func MockBackendSave(f func(context.Context, uint64, *snap.Info, map[string]interface{}, []string, *snap.SnapshotOptions, *dirs.SnapDirOptions, *backend.SaveOptions) (*client.Snapshot, error)) (restore func()) {
 	old := backendSave
 	backendSave = f
 	return func() {
@@ -35,6 +35,7 @@ import (
 	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/overlord"
+	"github.com/snapcore/snapd/overlord/configstate/config"
 	"github.com/snapcore/snapd/overlord/snapshotstate"
 	"github.com/snapcore/snapd/overlord/snapshotstate/backend"
 	"github.com/snapcore/snapd/overlord/state"
@@ -116,6 +117,101 @@ func (snapshotSuite) TestEnsureForgetsSnapshots(c *check.C) {
 	c.Check(removedSnapshot, check.Matches, ".*/foo.zip")
 }
 
+func (snapshotSuite) TestEnsureGarbageCollectsChunks(c *check.C) {
+	restoreOsRemove := snapshotstate.MockOsRemove(func(string) error { return nil })
+	defer restoreOsRemove()
+	restore := mockFakeSnapshot(c)
+	defer restore()
+
+	var gcCalls int
+	defer snapshotstate.MockBackendGarbageCollectChunks(func(context.Context) (int, error) {
+		gcCalls++
+		return 0, nil
+	})()
+
+	st := state.New(nil)
+	runner := state.NewTaskRunner(st)
+	mgr := snapshotstate.Manager(st, runner)
+
+	// once on startup
+	c.Assert(mgr.Ensure(), check.IsNil)
+	c.Check(gcCalls, check.Equals, 1)
+	c.Assert(mgr.Ensure(), check.IsNil)
+	c.Check(gcCalls, check.Equals, 1)
+
+	// and again once expired snapshots are removed
+	st.Lock()
+	st.Set("snapshots", map[uint64]interface{}{
+		1: map[string]interface{}{"expiry-time": "2001-03-11T11:24:00Z"},
+	})
+	st.Unlock()
+	c.Assert(mgr.Ensure(), check.IsNil)
+	c.Check(gcCalls, check.Equals, 2)
+	c.Assert(mgr.Ensure(), check.IsNil)
+	c.Check(gcCalls, check.Equals, 2)
+}
+
+func (snapshotSuite) TestEnsureRetriesBusyChunkGC(c *check.C) {
+	var gcCalls int
+	defer snapshotstate.MockBackendGarbageCollectChunks(func(context.Context) (int, error) {
+		gcCalls++
+		if gcCalls == 1 {
+			return 0, backend.ErrChunkStoreBusy
+		}
+		return 0, nil
+	})()
+
+	st := state.New(nil)
+	runner := state.NewTaskRunner(st)
+	mgr := snapshotstate.Manager(st, runner)
+
+	for i := 0; i < 3; i++ {
+		c.Assert(mgr.Ensure(), check.IsNil)
+	}
+	c.Check(gcCalls, check.Equals, 2)
+}
+
+func (snapshotSuite) TestEnsureCollectsChunksLeftByBusyForget(c *check.C) {
+	defer snapshotstate.MockOsRemove(func(string) error { return nil })()
+	var gcCalls int
+	storeBusy := false
+	defer snapshotstate.MockBackendGarbageCollectChunks(func(context.Context) (int, error) {
+		gcCalls++
+		if storeBusy {
+			return 0, backend.ErrChunkStoreBusy
+		}
+		return 0, nil
+	})()
+
+	st := state.New(nil)
+	runner := state.NewTaskRunner(st)
+	mgr := snapshotstate.Manager(st, runner)
+	c.Assert(mgr.Ensure(), check.IsNil)
+	c.Check(gcCalls, check.Equals, 1)
+
+	// a deduplicated save is running while the snapshot is forgotten
+	st.Lock()
+	task := st.NewTask("forget-snapshot", "...")
+	task.Set("snapshot-setup", map[string]interface{}{
+		"set-id":   1,
+		"filename": "a-file",
+		"snap":     "a-snap",
+	})
+	st.Unlock()
+	storeBusy = true
+	c.Assert(snapshotstate.DoForget(task, &tomb.Tomb{}), check.IsNil)
+	c.Check(gcCalls, check.Equals, 2)
+
+	// the chunks are collected once the store is no longer busy
+	c.Assert(mgr.Ensure(), check.IsNil)
+	c.Check(gcCalls, check.Equals, 3)
+	storeBusy = false
+	c.Assert(mgr.Ensure(), check.IsNil)
+	c.Check(gcCalls, check.Equals, 4)
+	c.Assert(mgr.Ensure(), check.IsNil)
+	c.Check(gcCalls, check.Equals, 4)
+}
+
 func (snapshotSuite) TestEnsureForgetsSnapshotsRunsRegularly(c *check.C) {
 	var backendIterCalls int
 	shotfile, err := os.Create(filepath.Join(c.MkDir(), "foo.zip"))
@@ -282,7 +378,7 @@ func (snapshotSuite) TestDoSave(c *check.C) {
 
 	expectedOptions := &snap.SnapshotOptions{}
 	defer snapshotstate.MockBackendSave(func(_ context.Context, id uint64, si *snap.Info, cfg map[string]interface{}, usernames []string,
-		options *snap.SnapshotOptions, _ *dirs.SnapDirOptions) (*client.Snapshot, error) {
+		options *snap.SnapshotOptions, _ *dirs.SnapDirOptions, _ *backend.SaveOptions) (*client.Snapshot, error) {
 		c.Check(id, check.Equals, uint64(42))
 		c.Check(si, check.DeepEquals, &snapInfo)
 		c.Check(cfg, check.DeepEquals, map[string]interface{}{"hello": "there"})
@@ -319,6 +415,88 @@ func (snapshotSuite) TestDoSave(c *check.C) {
 	}
 }
 
+func (snapshotSuite) TestDoSaveDeduplicated(c *check.C) {
+	snapInfo := snap.Info{
+		SideInfo: snap.SideInfo{
+			RealName: "a-snap",
+			Revision: snap.R(-1),
+		},
+		Version: "1.33",
+	}
+	defer snapshotstate.MockSnapstateCurrentInfo(func(_ *state.State, snapname string) (*snap.Info, error) {
+		return &snapInfo, nil
+	})()
+	defer snapshotstate.MockConfigGetSnapConfig(func(*state.State, string) (*json.RawMessage, error) { return nil, nil })()
+
+	var deduplicate []bool
+	defer snapshotstate.MockBackendSave(func(_ context.Context, id uint64, si *snap.Info, cfg map[string]interface{}, usernames []string, _ *snap.SnapshotOptions, _ *dirs.SnapDirOptions, saveOpts *backend.SaveOptions) (*client.Snapshot, error) {
+		deduplicate = append(deduplicate, saveOpts.Deduplicate)
+		return nil, nil
+	})()
+
+	st := state.New(nil)
+	for _, enabled := range []bool{false, true} {
+		st.Lock()
+		tr := config.NewTransaction(st)
+		tr.Set("core", "experimental.deduplicated-snapshots", enabled)
+		tr.Commit()
+		task := st.NewTask("save-snapshot", "...")
+		task.Set("snapshot-setup", map[string]interface{}{
+			"snap": "a-snap",
+		})
+		st.Unlock()
+
+		err := snapshotstate.DoSave(task, &tomb.Tomb{})
+		c.Assert(err, check.IsNil)
+	}
+	c.Check(deduplicate, check.DeepEquals, []bool{false, true})
+}
+
+func (snapshotSuite) TestDoSaveEncrypted(c *check.C) {
+	snapInfo := snap.Info{
+		SideInfo: snap.SideInfo{
+			RealName: "a-snap",
+			Revision: snap.R(-1),
+		},
+		Version: "1.33",
+	}
+	defer snapshotstate.MockSnapstateCurrentInfo(func(_ *state.State, snapname string) (*snap.Info, error) {
+		return &snapInfo, nil
+	})()
+	defer snapshotstate.MockConfigGetSnapConfig(func(*state.State, string) (*json.RawMessage, error) { return nil, nil })()
+
+	var keys []*backend.EncryptionKey
+	defer snapshotstate.MockBackendSave(func(_ context.Context, id uint64, si *snap.Info, cfg map[string]interface{}, usernames []string, _ *snap.SnapshotOptions, _ *dirs.SnapDirOptions, saveOpts *backend.SaveOptions) (*client.Snapshot, error) {
+		keys = append(keys, saveOpts.Encryption)
+		return nil, nil
+	})()
+
+	key := &backend.EncryptionKey{Passphrase: "sekrit"}
+	st := state.New(nil)
+	st.Lock()
+	task := st.NewTask("save-snapshot", "...")
+	task.Set("snapshot-setup", map[string]interface{}{
+		"snap":      "a-snap",
+		"encrypted": true,
+	})
+	snapshotstate.CacheSnapshotKey(task, key)
+	st.Unlock()
+
+	err := snapshotstate.DoSave(task, &tomb.Tomb{})
+	c.Assert(err, check.IsNil)
+	c.Check(keys, check.DeepEquals, []*backend.EncryptionKey{key})
+
+	// the key is dropped once used
+	st.Lock()
+	c.Check(snapshotstate.CachedSnapshotKey(task), check.IsNil)
+	st.Unlock()
+
+	// so a second run, as if after a restart, fails
+	err = snapshotstate.DoSave(task, &tomb.Tomb{})
+	c.Assert(err, check.ErrorMatches, "cannot save encrypted snapshot: encryption key is no longer available")
+	c.Check(keys, check.HasLen, 1)
+}
+
 func (snapshotSuite) TestDoSaveGetsSnapDirOpts(c *check.C) {
 	restore := snapshotstate.MockGetSnapDirOptions(func(*state.State, string) (*dirs.SnapDirOptions, error) {
 		return &dirs.SnapDirOptions{HiddenSnapDataDir: true}, nil
@@ -338,7 +516,7 @@ func (snapshotSuite) TestDoSaveGetsSnapDirOpts(c *check.C) {
 	})()
 
 	var checkOpts bool
-	defer snapshotstate.MockBackendSave(func(_ context.Context, id uint64, si *snap.Info, cfg map[string]interface{}, usernames []string, _ *snap.SnapshotOptions, opts *dirs.SnapDirOptions) (*client.Snapshot, error) {
+	defer snapshotstate.MockBackendSave(func(_ context.Context, id uint64, si *snap.Info, cfg map[string]interface{}, usernames []string, _ *snap.SnapshotOptions, opts *dirs.SnapDirOptions, _ *backend.SaveOptions) (*client.Snapshot, error) {
 		c.Check(opts.HiddenSnapDataDir, check.Equals, true)
 		checkOpts = true
 		return nil, nil
@@ -362,7 +540,7 @@ func (snapshotSuite) TestDoSaveFailsWithNoSnap(c *check.C) {
 		return nil, errors.New("bzzt")
 	})()
 	defer snapshotstate.MockConfigGetSnapConfig(func(*state.State, string) (*json.RawMessage, error) { return nil, nil })()
-	defer snapshotstate.MockBackendSave(func(_ context.Context, id uint64, si *snap.Info, cfg map[string]interface{}, usernames []string, _ *snap.SnapshotOptions, options *dirs.SnapDirOptions) (*client.Snapshot, error) {
+	defer snapshotstate.MockBackendSave(func(_ context.Context, id uint64, si *snap.Info, cfg map[string]interface{}, usernames []string, _ *snap.SnapshotOptions, options *dirs.SnapDirOptions, _ *backend.SaveOptions) (*client.Snapshot, error) {
 		return nil, nil
 	})()
 
@@ -389,7 +567,7 @@ func (snapshotSuite) TestDoSaveFailsWithNoSnapshot(c *check.C) {
 	}
 	defer snapshotstate.MockSnapstateCurrentInfo(func(*state.State, string) (*snap.Info, error) { return &snapInfo, nil })()
 	defer snapshotstate.MockConfigGetSnapConfig(func(*state.State, string) (*json.RawMessage, error) { return nil, nil })()
-	defer snapshotstate.MockBackendSave(func(_ context.Context, id uint64, si *snap.Info, cfg map[string]interface{}, usernames []string, _ *snap.SnapshotOptions, options *dirs.SnapDirOptions) (*client.Snapshot, error) {
+	defer snapshotstate.MockBackendSave(func(_ context.Context, id uint64, si *snap.Info, cfg map[string]interface{}, usernames []string, _ *snap.SnapshotOptions, options *dirs.SnapDirOptions, _ *backend.SaveOptions) (*client.Snapshot, error) {
 		return nil, nil
 	})()
 
@@ -413,7 +591,7 @@ func (snapshotSuite) TestDoSaveFailsBackendError(c *check.C) {
 	}
 	defer snapshotstate.MockSnapstateCurrentInfo(func(*state.State, string) (*snap.Info, error) { return &snapInfo, nil })()
 	defer snapshotstate.MockConfigGetSnapConfig(func(*state.State, string) (*json.RawMessage, error) { return nil, nil })()
-	defer snapshotstate.MockBackendSave(func(_ context.Context, id uint64, si *snap.Info, cfg map[string]interface{}, usernames []string, _ *snap.SnapshotOptions, options *dirs.SnapDirOptions) (*client.Snapshot, error) {
+	defer snapshotstate.MockBackendSave(func(_ context.Context, id uint64, si *snap.Info, cfg map[string]interface{}, usernames []string, _ *snap.SnapshotOptions, options *dirs.SnapDirOptions, _ *backend.SaveOptions) (*client.Snapshot, error) {
 		return nil, errors.New("bzzt")
 	})()
 
@@ -442,7 +620,7 @@ func (snapshotSuite) TestDoSaveFailsConfigError(c *check.C) {
 	defer snapshotstate.MockConfigGetSnapConfig(func(*state.State, string) (*json.RawMessage, error) {
 		return nil, errors.New("bzzt")
 	})()
-	defer snapshotstate.MockBackendSave(func(_ context.Context, id uint64, si *snap.Info, cfg map[string]interface{}, usernames []string, _ *snap.SnapshotOptions, options *dirs.SnapDirOptions) (*client.Snapshot, error) {
+	defer snapshotstate.MockBackendSave(func(_ context.Context, id uint64, si *snap.Info, cfg map[string]interface{}, usernames []string, _ *snap.SnapshotOptions, options *dirs.SnapDirOptions, _ *backend.SaveOptions) (*client.Snapshot, error) {
 		return nil, nil
 	})()
 
@@ -473,7 +651,7 @@ func (snapshotSuite) TestDoSaveFailsBadConfig(c *check.C) {
 		buf := json.RawMessage(`"hello-there"`)
 		return &buf, nil
 	})()
-	defer snapshotstate.MockBackendSave(func(_ context.Context, id uint64, si *snap.Info, cfg map[string]interface{}, usernames []string, _ *snap.SnapshotOptions, options *dirs.SnapDirOptions) (*client.Snapshot, error) {
+	defer snapshotstate.MockBackendSave(func(_ context.Context, id uint64, si *snap.Info, cfg map[string]interface{}, usernames []string, _ *snap.SnapshotOptions, options *dirs.SnapDirOptions, _ *backend.SaveOptions) (*client.Snapshot, error) {
 		return nil, nil
 	})()
 
@@ -506,7 +684,7 @@ func (snapshotSuite) TestDoSaveFailureRemovesStateEntry(c *check.C) {
 	defer snapshotstate.MockConfigGetSnapConfig(func(_ *state.State, snapname string) (*json.RawMessage, error) {
 		return nil, nil
 	})()
-	defer snapshotstate.MockBackendSave(func(_ context.Context, id uint64, si *snap.Info, cfg map[string]interface{}, usernames []string, _ *snap.SnapshotOptions, options *dirs.SnapDirOptions) (*client.Snapshot, error) {
+	defer snapshotstate.MockBackendSave(func(_ context.Context, id uint64, si *snap.Info, cfg map[string]interface{}, usernames []string, _ *snap.SnapshotOptions, options *dirs.SnapDirOptions, _ *backend.SaveOptions) (*client.Snapshot, error) {
 		var expirations map[uint64]interface{}
 		st.Lock()
 		defer st.Unlock()
@@ -564,6 +742,10 @@ func (rs *readerSuite) SetUpTest(c *check.C) {
 			rs.calls = append(rs.calls, "remove")
 			return nil
 		}),
+		snapshotstate.MockBackendGarbageCollectChunks(func(context.Context) (int, error) {
+			rs.calls = append(rs.calls, "gc chunks")
+			return 0, nil
+		}),
 		snapshotstate.MockConfigGetSnapConfig(func(*state.State, string) (*json.RawMessage, error) {
 			rs.calls = append(rs.calls, "get config")
 			return nil, nil
@@ -576,7 +758,7 @@ func (rs *readerSuite) SetUpTest(c *check.C) {
 			rs.calls = append(rs.calls, "open")
 			return &backend.Reader{}, nil
 		}),
-		snapshotstate.MockBackendRestore(func(*backend.Reader, context.Context, snap.Revision, []string, backend.Logf, *dirs.SnapDirOptions) (*backend.RestoreState, error) {
+		snapshotstate.MockBackendRestore(func(*backend.Reader, context.Context, string, snap.Revision, []string, backend.Logf, *dirs.SnapDirOptions) (*backend.RestoreState, error) {
 			rs.calls = append(rs.calls, "restore")
 			return &backend.RestoreState{}, nil
 		}),
@@ -599,6 +781,57 @@ func (rs *readerSuite) TearDownTest(c *check.C) {
 	}
 }
 
+func (rs *readerSuite) TestDoRestoreEncryptedNeedsKey(c *check.C) {
+	defer snapshotstate.MockBackendOpen(func(string, uint64) (*backend.Reader, error) {
+		rs.calls = append(rs.calls, "open")
+		return &backend.Reader{
+			Snapshot: client.Snapshot{Encryption: &client.SnapshotEncryption{Method: "passphrase"}},
+		}, nil
+	})()
+
+	err := snapshotstate.DoRestore(rs.task, &tomb.Tomb{})
+	c.Assert(err, check.ErrorMatches, "cannot restore encrypted snapshot: decryption key is no longer available")
+	c.Check(rs.calls, check.DeepEquals, []string{"get config", "open"})
+}
+
+func (rs *readerSuite) TestDoRestoreEncryptedWrongKey(c *check.C) {
+	defer snapshotstate.MockBackendOpen(func(string, uint64) (*backend.Reader, error) {
+		rs.calls = append(rs.calls, "open")
+		return &backend.Reader{
+			Snapshot: client.Snapshot{Encryption: &client.SnapshotEncryption{
+				Method:     "passphrase",
+				Salt:       make([]byte, 16),
+				WrappedKey: make([]byte, 60),
+			}},
+		}, nil
+	})()
+
+	st := rs.task.State()
+	st.Lock()
+	snapshotstate.CacheSnapshotKey(rs.task, &backend.EncryptionKey{Passphrase: "sekrit"})
+	st.Unlock()
+
+	err := snapshotstate.DoRestore(rs.task, &tomb.Tomb{})
+	c.Assert(err, check.ErrorMatches, "cannot restore encrypted snapshot: cannot decrypt snapshot: wrong passphrase or private key")
+	c.Check(rs.calls, check.DeepEquals, []string{"get config", "open"})
+}
+
+func (rs *readerSuite) TestDoCheckDropsKey(c *check.C) {
+	st := rs.task.State()
+	st.Lock()
+	snapshotstate.CacheSnapshotKey(rs.task, &backend.EncryptionKey{Passphrase: "sekrit"})
+	st.Unlock()
+
+	// the snapshot is not actually encrypted, so the key is not used
+	err := snapshotstate.DoCheck(rs.task, &tomb.Tomb{})
+	c.Assert(err, check.IsNil)
+	c.Check(rs.calls, check.DeepEquals, []string{"open", "check"})
+
+	st.Lock()
+	c.Check(snapshotstate.CachedSnapshotKey(rs.task), check.IsNil)
+	st.Unlock()
+}
+
 func (rs *readerSuite) TestDoRestore(c *check.C) {
 	defer snapshotstate.MockConfigGetSnapConfig(func(_ *state.State, snapname string) (*json.RawMessage, error) {
 		rs.calls = append(rs.calls, "get config")
@@ -615,7 +848,7 @@ func (rs *readerSuite) TestDoRestore(c *check.C) {
 			Snapshot: client.Snapshot{Conf: map[string]interface{}{"hello": "there"}},
 		}, nil
 	})()
-	defer snapshotstate.MockBackendRestore(func(_ *backend.Reader, _ context.Context, _ snap.Revision, users []string, _ backend.Logf, options *dirs.SnapDirOptions) (*backend.RestoreState, error) {
+	defer snapshotstate.MockBackendRestore(func(_ *backend.Reader, _ context.Context, _ string, _ snap.Revision, users []string, _ backend.Logf, options *dirs.SnapDirOptions) (*backend.RestoreState, error) {
 		rs.calls = append(rs.calls, "restore")
 		c.Check(users, check.DeepEquals, []string{"a-user", "b-user"})
 		return &backend.RestoreState{}, nil
@@ -639,6 +872,48 @@ func (rs *readerSuite) TestDoRestore(c *check.C) {
 	c.Check(v, check.DeepEquals, map[string]interface{}{"config": map[string]interface{}{"old": "conf"}})
 }
 
+func (rs *readerSuite) TestDoRestoreIntoTarget(c *check.C) {
+	st := rs.task.State()
+	st.Lock()
+	rs.task.Set("snapshot-setup", map[string]interface{}{
+		"snap":     "a-snap",
+		"target":   "a-snap_test",
+		"filename": "/some/1_file.zip",
+		"current":  "3",
+	})
+	st.Unlock()
+
+	defer snapshotstate.MockConfigGetSnapConfig(func(_ *state.State, snapname string) (*json.RawMessage, error) {
+		rs.calls = append(rs.calls, "get config")
+		c.Check(snapname, check.Equals, "a-snap_test")
+		return nil, nil
+	})()
+	defer snapshotstate.MockGetSnapDirOptions(func(_ *state.State, snapname string) (*dirs.SnapDirOptions, error) {
+		c.Check(snapname, check.Equals, "a-snap_test")
+		return &dirs.SnapDirOptions{}, nil
+	})()
+	defer snapshotstate.MockBackendRestore(func(_ *backend.Reader, _ context.Context, instanceName string, current snap.Revision, _ []string, _ backend.Logf, _ *dirs.SnapDirOptions) (*backend.RestoreState, error) {
+		rs.calls = append(rs.calls, "restore")
+		c.Check(instanceName, check.Equals, "a-snap_test")
+		c.Check(current, check.Equals, snap.R(3))
+		return &backend.RestoreState{}, nil
+	})()
+	defer snapshotstate.MockConfigSetSnapConfig(func(_ *state.State, snapname string, _ *json.RawMessage) error {
+		rs.calls = append(rs.calls, "set config")
+		c.Check(snapname, check.Equals, "a-snap_test")
+		return nil
+	})()
+
+	err := snapshotstate.DoRestore(rs.task, &tomb.Tomb{})
+	c.Assert(err, check.IsNil)
+	c.Check(rs.calls, check.DeepEquals, []string{"get config", "open", "restore", "set config"})
+
+	rs.calls = nil
+	err = snapshotstate.UndoRestore(rs.task, &tomb.Tomb{})
+	c.Assert(err, check.IsNil)
+	c.Check(rs.calls, check.DeepEquals, []string{"set config", "revert"})
+}
+
 func (rs *readerSuite) TestDoRestoreNoConfig(c *check.C) {
 	defer snapshotstate.MockConfigGetSnapConfig(func(_ *state.State, snapname string) (*json.RawMessage, error) {
 		rs.calls = append(rs.calls, "get config")
@@ -657,7 +932,7 @@ func (rs *readerSuite) TestDoRestoreNoConfig(c *check.C) {
 			Snapshot: client.Snapshot{Snap: "a-snap", Conf: nil},
 		}, nil
 	})()
-	defer snapshotstate.MockBackendRestore(func(_ *backend.Reader, _ context.Context, _ snap.Revision, users []string, _ backend.Logf, options *dirs.SnapDirOptions) (*backend.RestoreState, error) {
+	defer snapshotstate.MockBackendRestore(func(_ *backend.Reader, _ context.Context, _ string, _ snap.Revision, users []string, _ backend.Logf, options *dirs.SnapDirOptions) (*backend.RestoreState, error) {
 		rs.calls = append(rs.calls, "restore")
 		c.Check(users, check.DeepEquals, []string{"a-user", "b-user"})
 		return &backend.RestoreState{}, nil
@@ -740,7 +1015,7 @@ func (rs *readerSuite) TestDoRestoreFailsUnserialisableSnapshotConfigError(c *ch
 }
 
 func (rs *readerSuite) TestDoRestoreFailsOnRestoreError(c *check.C) {
-	defer snapshotstate.MockBackendRestore(func(*backend.Reader, context.Context, snap.Revision, []string, backend.Logf, *dirs.SnapDirOptions) (*backend.RestoreState, error) {
+	defer snapshotstate.MockBackendRestore(func(*backend.Reader, context.Context, string, snap.Revision, []string, backend.Logf, *dirs.SnapDirOptions) (*backend.RestoreState, error) {
 		rs.calls = append(rs.calls, "restore")
 		return nil, errors.New("bzzt")
 	})()
@@ -846,7 +1121,21 @@ func (rs *readerSuite) TestDoRemove(c *check.C) {
 	})()
 	err := snapshotstate.DoForget(rs.task, &tomb.Tomb{})
 	c.Assert(err, check.IsNil)
-	c.Check(rs.calls, check.DeepEquals, []string{"remove"})
+	c.Check(rs.calls, check.DeepEquals, []string{"remove", "gc chunks"})
+}
+
+func (rs *readerSuite) TestDoForgetChunkGCErrorIsNotFatal(c *check.C) {
+	defer snapshotstate.MockBackendGarbageCollectChunks(func(context.Context) (int, error) {
+		rs.calls = append(rs.calls, "gc chunks")
+		return 0, errors.New("bzzt")
+	})()
+	logbuf, restore := logger.MockLogger()
+	defer restore()
+
+	err := snapshotstate.DoForget(rs.task, &tomb.Tomb{})
+	c.Assert(err, check.IsNil)
+	c.Check(rs.calls, check.DeepEquals, []string{"remove", "gc chunks"})
+	c.Check(logbuf.String(), testutil.Contains, "Cannot remove unused snapshot chunks: bzzt.")
 }
 
 func (rs *readerSuite) TestDoForgetRemovesAutomaticSnapshotExpiry(c *check.C) {
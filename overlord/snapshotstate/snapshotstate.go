@@ -31,6 +31,7 @@ import (
 	"github.com/snapcore/snapd/client"
 	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/hookstate"
 	"github.com/snapcore/snapd/overlord/snapshotstate/backend"
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
@@ -46,6 +47,7 @@ var (
 	backendEstimateSnapshotSize      = backend.EstimateSnapshotSize
 	backendList                      = backend.List
 	backendNewSnapshotExport         = backend.NewSnapshotExport
+	backendCheckEncryptionKey        = backend.CheckEncryptionKey
 
 	// Default expiration time for automatic snapshots, if not set by the user
 	defaultAutomaticSnapshotExpiration = time.Hour * 24 * 31
@@ -53,6 +55,10 @@ var (
 
 type snapshotState struct {
 	ExpiryTime time.Time `json:"expiry-time"`
+	// Scheduled is set for the snapshot sets taken by the snapshot
+	// schedule; they are pruned according to the schedule retention
+	// settings instead of expiring
+	Scheduled bool `json:"scheduled,omitempty"`
 }
 
 func newSnapshotSetID(st *state.State) (uint64, error) {
@@ -207,6 +213,9 @@ func expiredSnapshotSets(st *state.State, cutoffTime time.Time) (map[uint64]bool
 
 	expired := make(map[uint64]bool)
 	for setID, snapshotSet := range snapshots {
+		if snapshotSet.ExpiryTime.IsZero() {
+			continue
+		}
 		if snapshotSet.ExpiryTime.Before(cutoffTime) {
 			expired[setID] = true
 		}
@@ -232,6 +241,8 @@ type snapshotSnapSummary struct {
 	snapID   string
 	filename string
 	epoch    snap.Epoch
+
+	encryption *client.SnapshotEncryption
 }
 
 // snapSummariesInSnapshotSet goes looking for the requested snaps in the
@@ -248,6 +259,8 @@ func snapSummariesInSnapshotSet(setID uint64, requested []string) (summaries sna
 					snap:     r.Snap,
 					snapID:   r.SnapID,
 					epoch:    r.Epoch,
+
+					encryption: r.Encryption,
 				})
 			}
 		}
@@ -267,6 +280,84 @@ func snapSummariesInSnapshotSet(setID uint64, requested []string) (summaries sna
 	return summaries, nil
 }
 
+// checkReadableBy checks that the given snap, installed as instanceName,
+// can use the data in the snapshot.
+func (summary *snapshotSnapSummary) checkReadableBy(instanceName string, info *snap.Info) error {
+	if !info.Epoch.CanRead(summary.epoch) {
+		const tpl = "cannot restore snapshot for %q: current snap (epoch %s) cannot read snapshot data (epoch %s)"
+		return fmt.Errorf(tpl, instanceName, &info.Epoch, &summary.epoch)
+	}
+	return summary.checkSameSnap(instanceName, info)
+}
+
+func (summary *snapshotSnapSummary) checkSameSnap(instanceName string, info *snap.Info) error {
+	if summary.snapID != "" && info.SnapID != "" && info.SnapID != summary.snapID {
+		const tpl = "cannot restore snapshot for %q: current snap (ID %.7s…) does not match snapshot (ID %.7s…)"
+		return fmt.Errorf(tpl, instanceName, info.SnapID, summary.snapID)
+	}
+	return nil
+}
+
+// A KeyError is returned when the key given for an encrypted snapshot set
+// cannot be used to decrypt it.
+type KeyError struct {
+	SetID uint64
+	Snap  string
+	Err   error
+}
+
+func (e *KeyError) Error() string {
+	return fmt.Sprintf("cannot use key for snapshot of %q in set #%d: %v", e.Snap, e.SetID, e.Err)
+}
+
+func (e *KeyError) Unwrap() error {
+	return e.Err
+}
+
+// checkKey checks that the given key, if any, can decrypt the encrypted
+// snapshots in summaries. If required is true a key must be given if any
+// snapshot is encrypted.
+func (summaries snapshotSnapSummaries) checkKey(setID uint64, key *EncryptionKey, required bool) error {
+	for _, summary := range summaries {
+		if summary.encryption == nil {
+			continue
+		}
+		if key == nil {
+			if required {
+				return client.ErrSnapshotKeyRequired
+			}
+			continue
+		}
+		if err := backendCheckEncryptionKey(summary.encryption, key); err != nil {
+			return &KeyError{SetID: setID, Snap: summary.snap, Err: err}
+		}
+	}
+	return nil
+}
+
+type snapshotKeyKey struct {
+	taskID string
+}
+
+// cacheSnapshotKey keeps the key for the given task in memory; keys are
+// never written to the state.
+func cacheSnapshotKey(task *state.Task, key *EncryptionKey) {
+	task.State().Cache(snapshotKeyKey{task.ID()}, key)
+}
+
+// cachedSnapshotKey returns the key for the given task, if any. Note that
+// the state must be locked by the caller.
+func cachedSnapshotKey(task *state.Task) *EncryptionKey {
+	key, _ := task.State().Cached(snapshotKeyKey{task.ID()}).(*EncryptionKey)
+	return key
+}
+
+// forgetSnapshotKey drops the key for the given task from memory. Note that
+// the state must be locked by the caller.
+func forgetSnapshotKey(task *state.Task) {
+	task.State().Cache(snapshotKeyKey{task.ID()}, nil)
+}
+
 func taskGetErrMsg(task *state.Task, err error, what string) error {
 	if errors.Is(err, state.ErrNoState) {
 		return fmt.Errorf("internal error: task %s (%s) is missing %s information", task.ID(), task.Kind(), what)
@@ -320,15 +411,20 @@ func List(ctx context.Context, st *state.State, setID uint64, snapNames []string
 		return nil, err
 	}
 
-	// decorate all snapshots with "auto" flag if we have expiry time set for them.
+	// decorate all snapshots with "auto" flag if we have expiry time set for
+	// them, and with "scheduled" if they were taken by the snapshot schedule.
 	for _, sset := range sets {
-		// at the moment we only keep records with expiry time so checking non-zero
-		// expiry-time is not strictly necessary, but it makes it future-proof in case
-		// we add more attributes to these entries.
-		if snapshotState, ok := snapshots[sset.ID]; ok && !snapshotState.ExpiryTime.IsZero() {
-			for _, snapshot := range sset.Snapshots {
+		snapshotState, ok := snapshots[sset.ID]
+		if !ok {
+			continue
+		}
+		for _, snapshot := range sset.Snapshots {
+			if !snapshotState.ExpiryTime.IsZero() {
 				snapshot.Auto = true
 			}
+			if snapshotState.Scheduled {
+				snapshot.Scheduled = true
+			}
 		}
 	}
 
@@ -378,9 +474,18 @@ func Import(ctx context.Context, st *state.State, r io.Reader) (setID uint64, sn
 	return setID, snapNames, nil
 }
 
-// Save creates a taskset for taking snapshots of snaps' data.
+// Save creates a taskset for taking snapshots of snaps' data. If key is not
+// nil the snapshots are encrypted with it.
 // Note that the state must be locked by the caller.
-func Save(st *state.State, instanceNames []string, users []string, options map[string]*snap.SnapshotOptions) (setID uint64, snapsSaved []string, ts *state.TaskSet, err error) {
+func Save(st *state.State, instanceNames []string, users []string, options map[string]*snap.SnapshotOptions, key *EncryptionKey) (setID uint64, snapsSaved []string, ts *state.TaskSet, err error) {
+	if key != nil {
+		if err := key.Validate(); err != nil {
+			return 0, nil, nil, err
+		}
+		if len(key.PrivateKey) != 0 {
+			return 0, nil, nil, fmt.Errorf("cannot encrypt snapshot with a private key")
+		}
+	}
 	if len(instanceNames) == 0 {
 		instanceNames, err = allActiveSnapNames(st)
 		if err != nil {
@@ -416,13 +521,17 @@ func Save(st *state.State, instanceNames []string, users []string, options map[s
 		task := st.NewTask("save-snapshot", desc)
 
 		snapshot := snapshotSetup{
-			SetID:   setID,
-			Snap:    name,
-			Users:   users,
-			Options: options[name],
+			SetID:     setID,
+			Snap:      name,
+			Users:     users,
+			Options:   options[name],
+			Encrypted: key != nil,
 		}
 
 		task.Set("snapshot-setup", &snapshot)
+		if key != nil {
+			cacheSnapshotKey(task, key)
+		}
 		// Here, note that a snapshot set behaves as a unit: it either
 		// succeeds, or fails, as a whole; we don't use lanes, to have
 		// some snaps' snapshot succeed and not others in a single set.
@@ -467,13 +576,17 @@ func AutomaticSnapshot(st *state.State, snapName string) (ts *state.TaskSet, err
 	return ts, nil
 }
 
-// Restore creates a taskset for restoring a snapshot's data.
+// Restore creates a taskset for restoring a snapshot's data. The key is
+// required if the snapshot set is encrypted.
 // Note that the state must be locked by the caller.
-func Restore(st *state.State, setID uint64, snapNames []string, users []string) (snapsFound []string, ts *state.TaskSet, err error) {
+func Restore(st *state.State, setID uint64, snapNames []string, users []string, key *EncryptionKey) (snapsFound []string, ts *state.TaskSet, err error) {
 	summaries, err := snapSummariesInSnapshotSet(setID, snapNames)
 	if err != nil {
 		return nil, nil, err
 	}
+	if err := summaries.checkKey(setID, key, true); err != nil {
+		return nil, nil, err
+	}
 	all, err := snapstateAll(st)
 	if err != nil {
 		return nil, nil, err
@@ -500,13 +613,8 @@ func Restore(st *state.State, setID uint64, snapNames []string, users []string)
 				// how?
 				return nil, nil, fmt.Errorf("unexpected error while reading snap info: %v", err)
 			}
-			if !info.Epoch.CanRead(summary.epoch) {
-				const tpl = "cannot restore snapshot for %q: current snap (epoch %s) cannot read snapshot data (epoch %s)"
-				return nil, nil, fmt.Errorf(tpl, summary.snap, &info.Epoch, &summary.epoch)
-			}
-			if summary.snapID != "" && info.SnapID != "" && info.SnapID != summary.snapID {
-				const tpl = "cannot restore snapshot for %q: current snap (ID %.7s…) does not match snapshot (ID %.7s…)"
-				return nil, nil, fmt.Errorf(tpl, summary.snap, info.SnapID, summary.snapID)
+			if err := summary.checkReadableBy(summary.snap, info); err != nil {
+				return nil, nil, err
 			}
 			current = snapst.Current
 		}
@@ -521,26 +629,126 @@ func Restore(st *state.State, setID uint64, snapNames []string, users []string)
 			Current:  current,
 		}
 		task.Set("snapshot-setup", &snapshot)
+		if summary.encryption != nil {
+			cacheSnapshotKey(task, key)
+		}
 		// see the note about snapshots not using lanes, above.
 		ts.AddTask(task)
 	}
 
 	if len(summaries) > 0 {
-		// take care of cleaning up all restore working state if all the
-		// restore tasks succeeded; if they didn't, the undo logic will take
-		// care of this
-		desc := fmt.Sprintf("Cleanup after restore from snapshot set #%d", setID)
-		task := st.NewTask("cleanup-after-restore", desc)
-		task.WaitAll(ts)
-		ts.AddTask(task)
+		addCleanupAfterRestore(st, setID, ts)
 	}
 
 	return snapsFound, ts, nil
 }
 
-// Check creates a taskset for checking a snapshot's data.
+// RestoreInto creates a taskset for restoring the data of the given snap in
+// a snapshot set into another, installed, instance of the same snap; for
+// example, to restore the data of "foo" into "foo_test". The current
+// revision of the target instance must be able to read the snapshot's epoch,
+// unless it has a post-restore hook: the hook runs once the data is restored
+// and is then responsible for migrating it, failing the change if it
+// cannot. The key is required if the snapshot set is encrypted.
+// Note that the state must be locked by the caller.
+func RestoreInto(st *state.State, setID uint64, snapName, instanceName string, users []string, key *EncryptionKey) (*state.TaskSet, error) {
+	if err := snap.ValidateInstanceName(instanceName); err != nil {
+		return nil, err
+	}
+	summaries, err := snapSummariesInSnapshotSet(setID, []string{snapName})
+	if err != nil {
+		return nil, err
+	}
+	if err := summaries.checkKey(setID, key, true); err != nil {
+		return nil, err
+	}
+	summary := summaries[0]
+
+	if snap.InstanceSnap(instanceName) != snap.InstanceSnap(summary.snap) {
+		return nil, fmt.Errorf("cannot restore snapshot of %q into %q: not an instance of the same snap", summary.snap, instanceName)
+	}
+
+	all, err := snapstateAll(st)
+	if err != nil {
+		return nil, err
+	}
+	snapst, ok := all[instanceName]
+	if !ok {
+		return nil, fmt.Errorf("cannot restore snapshot of %q into %q: snap is not installed", summary.snap, instanceName)
+	}
+	info, err := snapst.CurrentInfo()
+	if err != nil {
+		return nil, fmt.Errorf("unexpected error while reading snap info: %v", err)
+	}
+	if info.Hooks["post-restore"] != nil {
+		// the hook migrates the data from the epoch of the snapshot
+		if err := summary.checkSameSnap(instanceName, info); err != nil {
+			return nil, err
+		}
+	} else if err := summary.checkReadableBy(instanceName, info); err != nil {
+		return nil, err
+	}
+
+	if err := snapstateCheckChangeConflictMany(st, []string{instanceName}, ""); err != nil {
+		return nil, err
+	}
+	// restore needs to conflict with forget of itself
+	if err := checkSnapshotConflict(st, setID, "forget-snapshot"); err != nil {
+		return nil, err
+	}
+
+	ts := state.NewTaskSet()
+	desc := fmt.Sprintf("Restore data of snap %q from snapshot set #%d into %q", summary.snap, setID, instanceName)
+	task := st.NewTask("restore-snapshot", desc)
+	snapshot := snapshotSetup{
+		SetID:    setID,
+		Snap:     summary.snap,
+		Users:    users,
+		Filename: summary.filename,
+		Current:  snapst.Current,
+	}
+	if instanceName != summary.snap {
+		snapshot.Target = instanceName
+	}
+	task.Set("snapshot-setup", &snapshot)
+	if summary.encryption != nil {
+		cacheSnapshotKey(task, key)
+	}
+	ts.AddTask(task)
+	addPostRestoreHook(st, info, task, ts)
+	addCleanupAfterRestore(st, setID, ts)
+
+	return ts, nil
+}
+
+// addPostRestoreHook adds to ts a task running the post-restore hook of the
+// snap, if it has one, after its data was restored by the given restore
+// task. This lets the snap migrate data restored from a snapshot of an
+// epoch it cannot read; if the hook fails the data is restored back.
+func addPostRestoreHook(st *state.State, info *snap.Info, restoreTask *state.Task, ts *state.TaskSet) {
+	if info.Hooks["post-restore"] == nil {
+		return
+	}
+	task := hookstate.SetupPostRestoreHook(st, info.InstanceName())
+	task.WaitFor(restoreTask)
+	ts.AddTask(task)
+}
+
+// addCleanupAfterRestore adds to ts a task that takes care of cleaning up
+// all restore working state if all the restore tasks succeeded; if they
+// didn't, the undo logic will take care of this
+func addCleanupAfterRestore(st *state.State, setID uint64, ts *state.TaskSet) {
+	desc := fmt.Sprintf("Cleanup after restore from snapshot set #%d", setID)
+	task := st.NewTask("cleanup-after-restore", desc)
+	task.WaitAll(ts)
+	ts.AddTask(task)
+}
+
+// Check creates a taskset for checking a snapshot's data. Without a key
+// only the integrity of the stored data of encrypted snapshots is checked;
+// with a key, it is also checked that the data decrypts.
 // Note that the state must be locked by the caller.
-func Check(st *state.State, setID uint64, snapNames []string, users []string) (snapsFound []string, ts *state.TaskSet, err error) {
+func Check(st *state.State, setID uint64, snapNames []string, users []string, key *EncryptionKey) (snapsFound []string, ts *state.TaskSet, err error) {
 	// check needs to conflict with forget of itself
 	if err := checkSnapshotConflict(st, setID, "forget-snapshot"); err != nil {
 		return nil, nil, err
@@ -550,6 +758,9 @@ func Check(st *state.State, setID uint64, snapNames []string, users []string) (s
 	if err != nil {
 		return nil, nil, err
 	}
+	if err := summaries.checkKey(setID, key, false); err != nil {
+		return nil, nil, err
+	}
 
 	ts = state.NewTaskSet()
 
@@ -563,6 +774,9 @@ func Check(st *state.State, setID uint64, snapNames []string, users []string) (s
 			Filename: summary.filename,
 		}
 		task.Set("snapshot-setup", &snapshot)
+		if summary.encryption != nil && key != nil {
+			cacheSnapshotKey(task, key)
+		}
 		ts.AddTask(task)
 	}
 
@@ -645,3 +859,6 @@ func Export(ctx context.Context, st *state.State, setID uint64) (se *backend.Sna
 
 // SnapshotExport provides a snapshot export that can be streamed out
 type SnapshotExport = backend.SnapshotExport
+
+// EncryptionKey is the key used to encrypt or decrypt a snapshot set.
+type EncryptionKey = backend.EncryptionKey
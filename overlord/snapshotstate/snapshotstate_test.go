@@ -45,6 +45,7 @@ import (
 	"github.com/snapcore/snapd/osutil/sys"
 	"github.com/snapcore/snapd/overlord"
 	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/hookstate"
 	"github.com/snapcore/snapd/overlord/snapshotstate"
 	"github.com/snapcore/snapd/overlord/snapshotstate/backend"
 	"github.com/snapcore/snapd/overlord/snapstate"
@@ -359,7 +360,7 @@ func (snapshotSuite) TestSaveChecksSnapnamesError(c *check.C) {
 	st := state.New(nil)
 	st.Lock()
 	defer st.Unlock()
-	_, _, _, err := snapshotstate.Save(st, nil, nil, nil)
+	_, _, _, err := snapshotstate.Save(st, nil, nil, nil, nil)
 	c.Check(err, check.ErrorMatches, "bzzt")
 }
 
@@ -424,7 +425,7 @@ func (s snapshotSuite) TestSaveChecksSnapstateConflict(c *check.C) {
 	st, restore := s.createConflictingChange(c)
 	defer restore()
 
-	_, _, _, err := snapshotstate.Save(st, []string{"foo"}, nil, nil)
+	_, _, _, err := snapshotstate.Save(st, []string{"foo"}, nil, nil, nil)
 	c.Assert(err, check.NotNil)
 	c.Check(err, check.FitsTypeOf, &snapstate.ChangeConflictError{})
 }
@@ -460,7 +461,7 @@ func (snapshotSuite) TestSaveConflictsWithSnapstate(c *check.C) {
 	})
 
 	chg := st.NewChange("snapshot-save", "...")
-	_, _, saveTasks, err := snapshotstate.Save(st, nil, nil, nil)
+	_, _, saveTasks, err := snapshotstate.Save(st, nil, nil, nil, nil)
 	c.Assert(err, check.IsNil)
 	chg.AddAll(saveTasks)
 
@@ -476,7 +477,7 @@ func (snapshotSuite) TestSaveChecksSnapstateConflictError(c *check.C) {
 	st := state.New(nil)
 	st.Lock()
 	defer st.Unlock()
-	_, _, _, err := snapshotstate.Save(st, nil, nil, nil)
+	_, _, _, err := snapshotstate.Save(st, nil, nil, nil, nil)
 	c.Check(err, check.ErrorMatches, "bzzt")
 }
 
@@ -487,7 +488,7 @@ func (snapshotSuite) TestSaveChecksSetIDError(c *check.C) {
 
 	st.Set("last-snapshot-set-id", "3/4")
 
-	_, _, _, err := snapshotstate.Save(st, nil, nil, nil)
+	_, _, _, err := snapshotstate.Save(st, nil, nil, nil, nil)
 	c.Check(err, check.ErrorMatches, ".* could not unmarshal .*")
 }
 
@@ -496,7 +497,7 @@ func (snapshotSuite) TestSaveNoSnapsInState(c *check.C) {
 	st.Lock()
 	defer st.Unlock()
 
-	setID, saved, taskset, err := snapshotstate.Save(st, nil, nil, nil)
+	setID, saved, taskset, err := snapshotstate.Save(st, nil, nil, nil, nil)
 	c.Assert(err, check.IsNil)
 	c.Check(setID, check.Equals, uint64(1))
 	c.Check(saved, check.HasLen, 0)
@@ -508,7 +509,7 @@ func (snapshotSuite) TestSaveSnapNotInstalled(c *check.C) {
 	st.Lock()
 	defer st.Unlock()
 
-	setID, saved, taskset, err := snapshotstate.Save(st, []string{"foo"}, nil, nil)
+	setID, saved, taskset, err := snapshotstate.Save(st, []string{"foo"}, nil, nil, nil)
 	c.Assert(err, check.ErrorMatches, `snap "foo" is not installed`)
 	c.Check(setID, check.Equals, uint64(0))
 	c.Check(saved, check.HasLen, 0)
@@ -534,7 +535,7 @@ func (snapshotSuite) TestSaveSomeSnaps(c *check.C) {
 		"a-snap": {Exclude: []string{"$SNAP_COMMON/exclude", "$SNAP_DATA/exclude"}},
 	}
 
-	setID, saved, taskset, err := snapshotstate.Save(st, nil, nil, snapshotOptions)
+	setID, saved, taskset, err := snapshotstate.Save(st, nil, nil, snapshotOptions, nil)
 	c.Assert(err, check.IsNil)
 	c.Check(setID, check.Equals, uint64(1))
 	c.Check(saved, check.DeepEquals, []string{"a-snap", "c-snap"})
@@ -580,7 +581,7 @@ func (s snapshotSuite) TestSaveOneSnap(c *check.C) {
 		Current: snap.R(1),
 	})
 
-	setID, saved, taskset, err := snapshotstate.Save(st, []string{"a-snap"}, []string{"a-user"}, nil)
+	setID, saved, taskset, err := snapshotstate.Save(st, []string{"a-snap"}, []string{"a-user"}, nil, nil)
 	c.Assert(err, check.IsNil)
 	c.Check(setID, check.Equals, uint64(1))
 	c.Check(saved, check.DeepEquals, []string{"a-snap"})
@@ -659,7 +660,7 @@ func (snapshotSuite) TestSaveIntegration(c *check.C) {
 		}
 	}
 
-	setID, saved, taskset, err := snapshotstate.Save(st, nil, []string{"a-user"}, snapshotOptions)
+	setID, saved, taskset, err := snapshotstate.Save(st, nil, []string{"a-user"}, snapshotOptions, nil)
 	c.Assert(err, check.IsNil)
 	c.Check(setID, check.Equals, uint64(1))
 	c.Check(saved, check.DeepEquals, []string{"one-snap", "too-snap", "tri-snap"})
@@ -766,7 +767,7 @@ exec /bin/tar "$@"
 		c.Assert(os.Mkdir(filepath.Join(homedir, "snap", name, "common", "common-"+name), mode), check.IsNil)
 	}
 
-	setID, saved, taskset, err := snapshotstate.Save(st, nil, []string{"a-user"}, nil)
+	setID, saved, taskset, err := snapshotstate.Save(st, nil, []string{"a-user"}, nil, nil)
 	c.Assert(err, check.IsNil)
 	c.Check(setID, check.Equals, uint64(1))
 	c.Check(saved, check.DeepEquals, []string{"one-snap", "too-snap", "tri-snap"})
@@ -866,7 +867,7 @@ exec /bin/tar "$@"
 	// these dir permissions (000) make tar unhappy
 	c.Assert(os.Mkdir(filepath.Join(homedir, "snap/tar-fail-snap/common/common-tar-fail-snap"), 00), check.IsNil)
 
-	setID, saved, taskset, err := snapshotstate.Save(st, nil, []string{"a-user"}, nil)
+	setID, saved, taskset, err := snapshotstate.Save(st, nil, []string{"a-user"}, nil, nil)
 	c.Assert(err, check.IsNil)
 	c.Check(setID, check.Equals, uint64(1))
 	c.Check(saved, check.DeepEquals, []string{"tar-fail-snap"})
@@ -929,7 +930,7 @@ func (snapshotSuite) TestRestoreChecksIterError(c *check.C) {
 	st.Lock()
 	defer st.Unlock()
 
-	_, _, err := snapshotstate.Restore(st, 42, nil, nil)
+	_, _, err := snapshotstate.Restore(st, 42, nil, nil, nil)
 	c.Assert(err, check.ErrorMatches, "bzzt")
 }
 
@@ -937,7 +938,7 @@ func (s snapshotSuite) TestRestoreChecksSnapstateConflicts(c *check.C) {
 	st, restore := s.createConflictingChange(c)
 	defer restore()
 
-	_, _, err := snapshotstate.Restore(st, 42, nil, nil)
+	_, _, err := snapshotstate.Restore(st, 42, nil, nil, nil)
 	c.Assert(err, check.NotNil)
 	c.Check(err, check.FitsTypeOf, &snapstate.ChangeConflictError{})
 
@@ -994,7 +995,7 @@ func (snapshotSuite) TestRestoreConflictsWithSnapstate(c *check.C) {
 	})
 
 	chg := st.NewChange("snapshot-restore", "...")
-	_, restoreTasks, err := snapshotstate.Restore(st, 42, nil, nil)
+	_, restoreTasks, err := snapshotstate.Restore(st, 42, nil, nil, nil)
 	c.Assert(err, check.IsNil)
 	chg.AddAll(restoreTasks)
 
@@ -1026,7 +1027,7 @@ func (snapshotSuite) TestRestoreChecksForgetConflicts(c *check.C) {
 	tsk.Set("snapshot-setup", map[string]int{"set-id": 42})
 	chg.AddTask(tsk)
 
-	_, _, err = snapshotstate.Restore(st, 42, nil, nil)
+	_, _, err = snapshotstate.Restore(st, 42, nil, nil, nil)
 	c.Assert(err, check.ErrorMatches, `cannot operate on snapshot set #42 while change \"1\" is in progress`)
 }
 
@@ -1061,7 +1062,7 @@ func (snapshotSuite) TestRestoreChecksChangesToSnapID(c *check.C) {
 	st.Lock()
 	defer st.Unlock()
 
-	_, _, err = snapshotstate.Restore(st, 42, nil, nil)
+	_, _, err = snapshotstate.Restore(st, 42, nil, nil, nil)
 	c.Assert(err, check.ErrorMatches, `cannot restore snapshot for "a-snap": current snap \(ID 1234567…\) does not match snapshot \(ID 0987654…\)`)
 }
 
@@ -1102,7 +1103,7 @@ func (snapshotSuite) TestRestoreChecksChangesToEpoch(c *check.C) {
 	st.Lock()
 	defer st.Unlock()
 
-	_, _, err = snapshotstate.Restore(st, 42, nil, nil)
+	_, _, err = snapshotstate.Restore(st, 42, nil, nil, nil)
 	c.Assert(err, check.ErrorMatches, `cannot restore snapshot for "a-snap": current snap \(epoch 17\) cannot read snapshot data \(epoch 42\)`)
 }
 
@@ -1143,7 +1144,7 @@ func (snapshotSuite) TestRestoreWorksWithCompatibleEpoch(c *check.C) {
 	st.Lock()
 	defer st.Unlock()
 
-	found, taskset, err := snapshotstate.Restore(st, 42, nil, nil)
+	found, taskset, err := snapshotstate.Restore(st, 42, nil, nil, nil)
 	c.Assert(err, check.IsNil)
 	c.Check(found, check.DeepEquals, []string{"a-snap"})
 	tasks := taskset.Tasks()
@@ -1180,7 +1181,7 @@ func (snapshotSuite) TestRestore(c *check.C) {
 	st.Lock()
 	defer st.Unlock()
 
-	found, taskset, err := snapshotstate.Restore(st, 42, []string{"a-snap", "b-snap"}, []string{"a-user"})
+	found, taskset, err := snapshotstate.Restore(st, 42, []string{"a-snap", "b-snap"}, []string{"a-user"}, nil)
 	c.Assert(err, check.IsNil)
 	c.Check(found, check.DeepEquals, []string{"a-snap"})
 	tasks := taskset.Tasks()
@@ -1199,6 +1200,178 @@ func (snapshotSuite) TestRestore(c *check.C) {
 	})
 }
 
+func mockRestoreIntoTarget(c *check.C, epoch string) (restore func()) {
+	sideInfo := &snap.SideInfo{RealName: "a-snap", Revision: snap.R(3)}
+	restore = snapshotstate.MockSnapstateAll(func(*state.State) (map[string]*snapstate.SnapState, error) {
+		return map[string]*snapstate.SnapState{
+			"a-snap_test": {
+				Active:      true,
+				Sequence:    []*snap.SideInfo{sideInfo},
+				Current:     sideInfo.Revision,
+				InstanceKey: "test",
+			},
+		}, nil
+	})
+	snaptest.MockSnapInstance(c, "a-snap_test", "{name: a-snap, version: v1, epoch: "+epoch+"}", sideInfo)
+	return restore
+}
+
+func mockRestoreIntoIter(c *check.C, snapName string) (filename string, restore func()) {
+	shotfile, err := os.Create(filepath.Join(c.MkDir(), "yadda.zip"))
+	c.Assert(err, check.IsNil)
+	shotfile.Close()
+	return shotfile.Name(), snapshotstate.MockBackendIter(func(_ context.Context, f func(*backend.Reader) error) error {
+		c.Assert(f(&backend.Reader{
+			Snapshot: client.Snapshot{SetID: 42, Snap: snapName, Epoch: snap.E("17")},
+			File:     shotfile,
+		}), check.IsNil)
+		return nil
+	})
+}
+
+func (snapshotSuite) TestRestoreInto(c *check.C) {
+	defer mockRestoreIntoTarget(c, "{read: [17, 42], write: [42]}")()
+	filename, restore := mockRestoreIntoIter(c, "a-snap")
+	defer restore()
+
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	taskset, err := snapshotstate.RestoreInto(st, 42, "a-snap", "a-snap_test", []string{"a-user"}, nil)
+	c.Assert(err, check.IsNil)
+	tasks := taskset.Tasks()
+	c.Assert(tasks, check.HasLen, 2)
+	c.Check(tasks[0].Kind(), check.Equals, "restore-snapshot")
+	c.Check(tasks[1].Kind(), check.Equals, "cleanup-after-restore")
+	c.Check(tasks[1].WaitTasks(), check.DeepEquals, []*state.Task{tasks[0]})
+	c.Check(tasks[0].Summary(), check.Equals, `Restore data of snap "a-snap" from snapshot set #42 into "a-snap_test"`)
+	var snapshot map[string]interface{}
+	c.Check(tasks[0].Get("snapshot-setup", &snapshot), check.IsNil)
+	c.Check(snapshot, check.DeepEquals, map[string]interface{}{
+		"set-id":   42.,
+		"snap":     "a-snap",
+		"target":   "a-snap_test",
+		"filename": filename,
+		"users":    []interface{}{"a-user"},
+		"current":  "3",
+	})
+}
+
+func (snapshotSuite) TestRestoreIntoRunsPostRestoreHook(c *check.C) {
+	defer mockRestoreIntoTarget(c, "{read: [17, 42], write: [42]}")()
+	snaptest.MockSnapInstance(c, "a-snap_test", `name: a-snap
+version: v1
+epoch: {read: [17, 42], write: [42]}
+hooks:
+  post-restore:
+`, &snap.SideInfo{RealName: "a-snap", Revision: snap.R(3)})
+	_, restore := mockRestoreIntoIter(c, "a-snap")
+	defer restore()
+
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	taskset, err := snapshotstate.RestoreInto(st, 42, "a-snap", "a-snap_test", nil, nil)
+	c.Assert(err, check.IsNil)
+	tasks := taskset.Tasks()
+	c.Assert(tasks, check.HasLen, 3)
+	c.Check(tasks[0].Kind(), check.Equals, "restore-snapshot")
+	c.Check(tasks[1].Kind(), check.Equals, "run-hook")
+	c.Check(tasks[2].Kind(), check.Equals, "cleanup-after-restore")
+	c.Check(tasks[1].Summary(), check.Equals, `Run post-restore hook of "a-snap_test" snap if present`)
+	c.Check(tasks[1].WaitTasks(), check.DeepEquals, []*state.Task{tasks[0]})
+	c.Check(tasks[2].WaitTasks(), check.DeepEquals, []*state.Task{tasks[0], tasks[1]})
+
+	var hooksup hookstate.HookSetup
+	c.Assert(tasks[1].Get("hook-setup", &hooksup), check.IsNil)
+	c.Check(hooksup, check.DeepEquals, hookstate.HookSetup{
+		Snap:     "a-snap_test",
+		Hook:     "post-restore",
+		Optional: true,
+	})
+}
+
+func (snapshotSuite) TestRestoreIntoUnreadableEpochWithPostRestoreHook(c *check.C) {
+	defer mockRestoreIntoTarget(c, "42")()
+	// the hook migrates the data from epoch 17
+	snaptest.MockSnapInstance(c, "a-snap_test", `name: a-snap
+version: v1
+epoch: 42
+hooks:
+  post-restore:
+`, &snap.SideInfo{RealName: "a-snap", Revision: snap.R(3)})
+	_, restore := mockRestoreIntoIter(c, "a-snap")
+	defer restore()
+
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	taskset, err := snapshotstate.RestoreInto(st, 42, "a-snap", "a-snap_test", nil, nil)
+	c.Assert(err, check.IsNil)
+	tasks := taskset.Tasks()
+	c.Assert(tasks, check.HasLen, 3)
+	c.Check(tasks[0].Kind(), check.Equals, "restore-snapshot")
+	c.Check(tasks[1].Kind(), check.Equals, "run-hook")
+	c.Check(tasks[1].WaitTasks(), check.DeepEquals, []*state.Task{tasks[0]})
+	c.Check(tasks[2].Kind(), check.Equals, "cleanup-after-restore")
+}
+
+func (snapshotSuite) TestRestoreIntoErrors(c *check.C) {
+	defer mockRestoreIntoTarget(c, "17")()
+	_, restore := mockRestoreIntoIter(c, "a-snap")
+	defer restore()
+
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	for _, t := range []struct {
+		snap, target string
+		err          string
+	}{
+		{"a-snap", "a-snap_", `invalid instance key: ""`},
+		{"b-snap", "a-snap_test", `no snapshot for the requested snaps found in the set with the given ID`},
+		{"a-snap", "b-snap_test", `cannot restore snapshot of "a-snap" into "b-snap_test": not an instance of the same snap`},
+		{"a-snap", "a-snap_other", `cannot restore snapshot of "a-snap" into "a-snap_other": snap is not installed`},
+	} {
+		_, err := snapshotstate.RestoreInto(st, 42, t.snap, t.target, nil, nil)
+		c.Check(err, check.ErrorMatches, t.err, check.Commentf("%s -> %s", t.snap, t.target))
+	}
+}
+
+func (snapshotSuite) TestRestoreIntoChecksEpoch(c *check.C) {
+	defer mockRestoreIntoTarget(c, "42")()
+	_, restore := mockRestoreIntoIter(c, "a-snap")
+	defer restore()
+
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	_, err := snapshotstate.RestoreInto(st, 42, "a-snap", "a-snap_test", nil, nil)
+	c.Assert(err, check.ErrorMatches, `cannot restore snapshot for "a-snap_test": current snap \(epoch 42\) cannot read snapshot data \(epoch 17\)`)
+}
+
+func (snapshotSuite) TestRestoreIntoConflicts(c *check.C) {
+	defer mockRestoreIntoTarget(c, "17")()
+	_, restore := mockRestoreIntoIter(c, "a-snap")
+	defer restore()
+	defer snapshotstate.MockSnapstateCheckChangeConflictMany(func(_ *state.State, names []string, _ string) error {
+		c.Check(names, check.DeepEquals, []string{"a-snap_test"})
+		return errors.New("conflict")
+	})()
+
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	_, err := snapshotstate.RestoreInto(st, 42, "a-snap", "a-snap_test", nil, nil)
+	c.Assert(err, check.ErrorMatches, "conflict")
+}
+
 func (snapshotSuite) TestRestoreIntegration(c *check.C) {
 	testRestoreIntegration(c, dirs.UserHomeSnapDir, nil)
 }
@@ -1262,7 +1435,7 @@ func testRestoreIntegration(c *check.C, snapDataDir string, opts *dirs.SnapDirOp
 			c.Assert(os.MkdirAll(filepath.Join(home, snapDataDir, name, "common", "common-"+name), 0755), check.IsNil)
 		}
 
-		_, err := backend.Save(context.TODO(), 42, snapInfo, nil, []string{"a-user", "b-user"}, nil, opts)
+		_, err := backend.Save(context.TODO(), 42, snapInfo, nil, []string{"a-user", "b-user"}, nil, opts, nil)
 		c.Assert(err, check.IsNil)
 	}
 
@@ -1271,7 +1444,7 @@ func testRestoreIntegration(c *check.C, snapDataDir string, opts *dirs.SnapDirOp
 	// remove b-user's home
 	c.Assert(os.RemoveAll(homedirB), check.IsNil)
 
-	found, taskset, err := snapshotstate.Restore(st, 42, nil, []string{"a-user", "b-user"})
+	found, taskset, err := snapshotstate.Restore(st, 42, nil, []string{"a-user", "b-user"}, nil)
 	c.Assert(err, check.IsNil)
 	sort.Strings(found)
 	c.Check(found, check.DeepEquals, []string{"one-snap", "too-snap", "tri-snap"})
@@ -1341,7 +1514,7 @@ func (snapshotSuite) TestRestoreIntegrationFails(c *check.C) {
 		c.Assert(os.MkdirAll(filepath.Join(homedir, "snap", name, fmt.Sprint(i+1), "canary-"+name), 0755), check.IsNil)
 		c.Assert(os.MkdirAll(filepath.Join(homedir, "snap", name, "common", "common-"+name), 0755), check.IsNil)
 
-		_, err := backend.Save(context.TODO(), 42, snapInfo, nil, []string{"a-user"}, nil, nil)
+		_, err := backend.Save(context.TODO(), 42, snapInfo, nil, []string{"a-user"}, nil, nil, nil)
 		c.Assert(err, check.IsNil)
 	}
 
@@ -1351,7 +1524,7 @@ func (snapshotSuite) TestRestoreIntegrationFails(c *check.C) {
 	c.Assert(os.MkdirAll(filepath.Join(homedir, "snap"), 0755), check.IsNil)
 	c.Assert(os.MkdirAll(filepath.Join(homedir, "snap", "too-snap"), 0), check.IsNil)
 
-	found, taskset, err := snapshotstate.Restore(st, 42, nil, []string{"a-user"})
+	found, taskset, err := snapshotstate.Restore(st, 42, nil, []string{"a-user"}, nil)
 	c.Assert(err, check.IsNil)
 	sort.Strings(found)
 	c.Check(found, check.DeepEquals, []string{"one-snap", "too-snap", "tri-snap"})
@@ -1405,7 +1578,7 @@ func (snapshotSuite) TestCheckChecksIterError(c *check.C) {
 	st.Lock()
 	defer st.Unlock()
 
-	_, _, err := snapshotstate.Check(st, 42, nil, nil)
+	_, _, err := snapshotstate.Check(st, 42, nil, nil, nil)
 	c.Assert(err, check.ErrorMatches, "bzzt")
 }
 
@@ -1413,7 +1586,7 @@ func (s snapshotSuite) TestCheckDoesNotTriggerSnapstateConflict(c *check.C) {
 	st, restore := s.createConflictingChange(c)
 	defer restore()
 
-	_, _, err := snapshotstate.Check(st, 42, nil, nil)
+	_, _, err := snapshotstate.Check(st, 42, nil, nil, nil)
 	c.Assert(err, check.IsNil)
 }
 
@@ -1441,7 +1614,7 @@ func (snapshotSuite) TestCheckChecksForgetConflicts(c *check.C) {
 	tsk.Set("snapshot-setup", map[string]int{"set-id": 42})
 	chg.AddTask(tsk)
 
-	_, _, err = snapshotstate.Check(st, 42, nil, nil)
+	_, _, err = snapshotstate.Check(st, 42, nil, nil, nil)
 	c.Assert(err, check.ErrorMatches, `cannot operate on snapshot set #42 while change \"1\" is in progress`)
 }
 
@@ -1464,7 +1637,7 @@ func (snapshotSuite) TestCheck(c *check.C) {
 	st.Lock()
 	defer st.Unlock()
 
-	found, taskset, err := snapshotstate.Check(st, 42, []string{"a-snap", "b-snap"}, []string{"a-user"})
+	found, taskset, err := snapshotstate.Check(st, 42, []string{"a-snap", "b-snap"}, []string{"a-user"}, nil)
 	c.Assert(err, check.IsNil)
 	c.Check(found, check.DeepEquals, []string{"a-snap"})
 	tasks := taskset.Tasks()
@@ -1482,6 +1655,128 @@ func (snapshotSuite) TestCheck(c *check.C) {
 	})
 }
 
+func (snapshotSuite) TestSaveEncrypted(c *check.C) {
+	fakeSnapstateAll := func(*state.State) (map[string]*snapstate.SnapState, error) {
+		return map[string]*snapstate.SnapState{
+			"a-snap": {Active: true},
+		}, nil
+	}
+	defer snapshotstate.MockSnapstateAll(fakeSnapstateAll)()
+
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	key := &snapshotstate.EncryptionKey{Passphrase: "sekrit"}
+	_, saved, taskset, err := snapshotstate.Save(st, nil, nil, nil, key)
+	c.Assert(err, check.IsNil)
+	c.Check(saved, check.DeepEquals, []string{"a-snap"})
+	tasks := taskset.Tasks()
+	c.Assert(tasks, check.HasLen, 1)
+	var snapshot map[string]interface{}
+	c.Check(tasks[0].Get("snapshot-setup", &snapshot), check.IsNil)
+	c.Check(snapshot["encrypted"], check.Equals, true)
+	c.Check(snapshotstate.CachedSnapshotKey(tasks[0]), check.Equals, key)
+
+	// the key never makes it to the state
+	chg := st.NewChange("save-snapshot", "...")
+	chg.AddAll(taskset)
+	data, err := json.Marshal(st)
+	c.Assert(err, check.IsNil)
+	c.Check(string(data), check.Not(testutil.Contains), "sekrit")
+}
+
+func (snapshotSuite) TestSaveEncryptedInvalidKey(c *check.C) {
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	_, _, _, err := snapshotstate.Save(st, nil, nil, nil, &snapshotstate.EncryptionKey{})
+	c.Check(err, check.ErrorMatches, "exactly one of passphrase, public key or private key must be given")
+	_, _, _, err = snapshotstate.Save(st, nil, nil, nil, &snapshotstate.EncryptionKey{PrivateKey: make([]byte, 32)})
+	c.Check(err, check.ErrorMatches, "cannot encrypt snapshot with a private key")
+}
+
+func (snapshotSuite) mockEncryptedSet(c *check.C) (shotfile *os.File, restore func()) {
+	shotfile, err := os.Create(filepath.Join(c.MkDir(), "yadda.zip"))
+	c.Assert(err, check.IsNil)
+	restoreIter := snapshotstate.MockBackendIter(func(_ context.Context, f func(*backend.Reader) error) error {
+		c.Assert(f(&backend.Reader{
+			Snapshot: client.Snapshot{
+				SetID:      42,
+				Snap:       "a-snap",
+				Encryption: &client.SnapshotEncryption{Method: "passphrase"},
+			},
+			File: shotfile,
+		}), check.IsNil)
+		return nil
+	})
+	restoreCheckKey := snapshotstate.MockBackendCheckEncryptionKey(func(_ *client.SnapshotEncryption, key *backend.EncryptionKey) error {
+		if key.Passphrase != "sekrit" {
+			return backend.ErrWrongEncryptionKey
+		}
+		return nil
+	})
+	return shotfile, func() {
+		restoreCheckKey()
+		restoreIter()
+		shotfile.Close()
+	}
+}
+
+func (s snapshotSuite) TestRestoreEncrypted(c *check.C) {
+	_, restore := s.mockEncryptedSet(c)
+	defer restore()
+
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	_, _, err := snapshotstate.Restore(st, 42, nil, nil, nil)
+	c.Check(err, check.Equals, client.ErrSnapshotKeyRequired)
+
+	_, _, err = snapshotstate.Restore(st, 42, nil, nil, &snapshotstate.EncryptionKey{Passphrase: "not-sekrit"})
+	c.Check(err, check.ErrorMatches, `cannot use key for snapshot of "a-snap" in set #42: cannot decrypt snapshot: wrong passphrase or private key`)
+	var keyErr *snapshotstate.KeyError
+	c.Check(errors.As(err, &keyErr), check.Equals, true)
+	c.Check(errors.Is(err, backend.ErrWrongEncryptionKey), check.Equals, true)
+
+	key := &snapshotstate.EncryptionKey{Passphrase: "sekrit"}
+	found, taskset, err := snapshotstate.Restore(st, 42, nil, nil, key)
+	c.Assert(err, check.IsNil)
+	c.Check(found, check.DeepEquals, []string{"a-snap"})
+	tasks := taskset.Tasks()
+	c.Assert(tasks, check.HasLen, 2)
+	c.Check(snapshotstate.CachedSnapshotKey(tasks[0]), check.Equals, key)
+	c.Check(snapshotstate.CachedSnapshotKey(tasks[1]), check.IsNil)
+}
+
+func (s snapshotSuite) TestCheckEncrypted(c *check.C) {
+	_, restore := s.mockEncryptedSet(c)
+	defer restore()
+
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	// the integrity of encrypted snapshots can be checked without the key
+	_, taskset, err := snapshotstate.Check(st, 42, nil, nil, nil)
+	c.Assert(err, check.IsNil)
+	tasks := taskset.Tasks()
+	c.Assert(tasks, check.HasLen, 1)
+	c.Check(snapshotstate.CachedSnapshotKey(tasks[0]), check.IsNil)
+
+	_, _, err = snapshotstate.Check(st, 42, nil, nil, &snapshotstate.EncryptionKey{Passphrase: "not-sekrit"})
+	c.Check(errors.Is(err, backend.ErrWrongEncryptionKey), check.Equals, true)
+
+	key := &snapshotstate.EncryptionKey{Passphrase: "sekrit"}
+	_, taskset, err = snapshotstate.Check(st, 42, nil, nil, key)
+	c.Assert(err, check.IsNil)
+	tasks = taskset.Tasks()
+	c.Assert(tasks, check.HasLen, 1)
+	c.Check(snapshotstate.CachedSnapshotKey(tasks[0]), check.Equals, key)
+}
+
 func (snapshotSuite) TestForgetChecksIterError(c *check.C) {
 	defer snapshotstate.MockBackendIter(func(context.Context, func(*backend.Reader) error) error {
 		return errors.New("bzzt")
@@ -781,6 +781,14 @@ func inhibitRefresh(st *state.State, snapst *SnapState, snapsup *SnapSetup, info
 		return nil
 	}
 
+	// let clients know that the refresh of the snap is being held back
+	opts := &state.AddNoticeOptions{
+		Data: map[string]string{"time-remaining": busyErr.timeRemaining.String()},
+	}
+	if _, err := st.AddNotice(nil, state.RefreshInhibitNotice, info.InstanceName(), opts); err != nil {
+		return err
+	}
+
 	return busyErr
 }
 
@@ -964,6 +964,13 @@ func (s *autoRefreshTestSuite) TestInitialInhibitRefreshWithinInhibitWindow(c *C
 	c.Assert(refreshInfo, NotNil)
 	c.Check(refreshInfo.InstanceName, Equals, "pkg")
 	c.Check(refreshInfo.TimeRemaining, Equals, time.Hour*14*24-time.Second)
+
+	notices := s.state.Notices(&state.NoticeFilter{
+		Types: []state.NoticeType{state.RefreshInhibitNotice},
+	})
+	c.Assert(notices, HasLen, 1)
+	c.Check(notices[0].Key(), Equals, "pkg")
+	c.Check(notices[0].LastData(), DeepEquals, map[string]string{"time-remaining": "335h59m59s"})
 }
 
 func (s *autoRefreshTestSuite) TestSubsequentInhibitRefreshWithinInhibitWindow(c *C) {
@@ -120,4 +120,11 @@ type managerBackend interface {
 	InitExposedSnapHome(snapName string, rev snap.Revision, opts *dirs.SnapDirOptions) (*backend.UndoInfo, error)
 	UndoInitExposedSnapHome(snapName string, undoInfo *backend.UndoInfo) error
 	InitXDGDirs(info *snap.Info) error
+
+	// component related
+	SetupComponent(compFilePath, instanceName string, csi *snap.ComponentSideInfo, dev snap.Device, meter progress.Meter) (*backend.InstallRecord, error)
+	UndoSetupComponent(instanceName string, csi *snap.ComponentSideInfo, installRecord *backend.InstallRecord, meter progress.Meter) error
+	RemoveComponentFiles(instanceName string, csi *snap.ComponentSideInfo, installRecord *backend.InstallRecord, meter progress.Meter) error
+	LinkComponent(instanceName string, snapRev snap.Revision, csi *snap.ComponentSideInfo) error
+	UnlinkComponent(instanceName string, snapRev snap.Revision, compName string) error
 }
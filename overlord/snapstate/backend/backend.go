@@ -53,6 +53,24 @@ func OpenSnapFile(snapPath string, sideInfo *snap.SideInfo) (*snap.Info, snap.Co
 	return info, snapf, nil
 }
 
+// OpenComponentFile opens a component blob returning a
+// snap.ComponentInfo and the corresponding snap.Container. The component
+// is checked against the definition in the snap.yaml of the given snap if
+// snapInfo is not nil.
+func OpenComponentFile(compPath string, snapInfo *snap.Info, csi *snap.ComponentSideInfo) (*snap.ComponentInfo, snap.Container, error) {
+	compf, err := snapfile.Open(compPath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ci, err := snap.ReadComponentInfoFromContainer(compf, snapInfo, csi)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return ci, compf, nil
+}
+
 func NewForPreseedMode() Backend {
 	return Backend{preseed: true}
 }
@@ -0,0 +1,170 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package backend
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/progress"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snapfile"
+	"github.com/snapcore/snapd/systemd"
+)
+
+// SetupComponent prepares and mounts a component for further processing.
+func (b Backend) SetupComponent(compFilePath, instanceName string, csi *snap.ComponentSideInfo, dev snap.Device, meter progress.Meter) (installRecord *InstallRecord, err error) {
+	// This assumes that the component was already verified or --dangerous was used.
+
+	compf, oErr := snapfile.Open(compFilePath)
+	if oErr != nil {
+		return nil, oErr
+	}
+
+	compName := csi.Component.ComponentName
+	mountDir := snap.ComponentMountDir(instanceName, compName, csi.Revision)
+	mountFile := snap.ComponentMountFile(instanceName, compName, csi.Revision)
+	// the component revision may already be mounted for another revision
+	// of the snap, in which case it must be kept around on failure
+	mountUnitExisted := systemd.ExistingMountUnitPath(dirs.StripRootDir(mountDir)) != ""
+
+	defer func() {
+		if err == nil || mountUnitExisted {
+			return
+		}
+
+		// this may remove the component from /var/lib/snapd/snaps
+		// depending on installRecord
+		if e := b.RemoveComponentFiles(instanceName, csi, installRecord, meter); e != nil {
+			meter.Notify(fmt.Sprintf("while trying to clean up due to previous failure: %v", e))
+		}
+	}()
+
+	if err := os.MkdirAll(mountDir, 0755); err != nil {
+		return nil, err
+	}
+
+	// in uc20+ and classic with modes run mode, all snaps and
+	// components must be on the same device
+	opts := &snap.InstallOptions{}
+	if dev.HasModeenv() && dev.RunMode() {
+		opts.MustNotCrossDevices = true
+	}
+
+	didNothing, err := compf.Install(mountFile, mountDir, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// generate the mount unit for the squashfs
+	if err := addComponentMountUnit(instanceName, csi, b.preseed, meter); err != nil {
+		return nil, err
+	}
+
+	return &InstallRecord{TargetSnapExisted: didNothing, MountUnitExisted: mountUnitExisted}, nil
+}
+
+// UndoSetupComponent undoes the work of SetupComponent using
+// RemoveComponentFiles. Nothing is removed if the component was already
+// mounted before SetupComponent was called.
+func (b Backend) UndoSetupComponent(instanceName string, csi *snap.ComponentSideInfo, installRecord *InstallRecord, meter progress.Meter) error {
+	if installRecord != nil && installRecord.MountUnitExisted {
+		return nil
+	}
+	return b.RemoveComponentFiles(instanceName, csi, installRecord, meter)
+}
+
+// RemoveComponentFiles removes the component files from the disk after
+// unmounting the component.
+func (b Backend) RemoveComponentFiles(instanceName string, csi *snap.ComponentSideInfo, installRecord *InstallRecord, meter progress.Meter) error {
+	compName := csi.Component.ComponentName
+	mountDir := snap.ComponentMountDir(instanceName, compName, csi.Revision)
+
+	// this also ensures that the mount unit stops
+	if err := removeMountUnit(mountDir, meter); err != nil {
+		return err
+	}
+
+	if err := os.RemoveAll(mountDir); err != nil {
+		return err
+	}
+	// remove the parent directories if empty, failure to remove is ok
+	// as there may be other revisions or components
+	for dir := filepath.Dir(mountDir); dir != snap.BaseDir(instanceName); dir = filepath.Dir(dir) {
+		os.Remove(dir)
+	}
+
+	// don't remove the component file if it existed before the
+	// installation was attempted
+	keepCompFile := installRecord != nil && installRecord.TargetSnapExisted
+	if !keepCompFile {
+		if err := os.RemoveAll(snap.ComponentMountFile(instanceName, compName, csi.Revision)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// LinkComponent makes the given component revision available to the given
+// revision of the snap instance, replacing the one used so far if any.
+func (b Backend) LinkComponent(instanceName string, snapRev snap.Revision, csi *snap.ComponentSideInfo) error {
+	compName := csi.Component.ComponentName
+	linkPath := snap.ComponentLinkPath(instanceName, snapRev, compName)
+	if err := os.MkdirAll(filepath.Dir(linkPath), 0755); err != nil {
+		return err
+	}
+	// the link is relative to the components directory of the snap
+	// revision so that it is also valid inside the snap mount namespace
+	target := filepath.Join("..", "mnt", compName, csi.Revision.String())
+	return osutil.AtomicSymlink(target, linkPath)
+}
+
+// UnlinkComponent makes the component unavailable to the given revision of
+// the snap instance.
+func (b Backend) UnlinkComponent(instanceName string, snapRev snap.Revision, compName string) error {
+	linkPath := snap.ComponentLinkPath(instanceName, snapRev, compName)
+	if err := os.Remove(linkPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	// failure to remove is ok, there may be other components
+	os.Remove(filepath.Dir(linkPath))
+	os.Remove(filepath.Dir(filepath.Dir(linkPath)))
+	return nil
+}
+
+func addComponentMountUnit(instanceName string, csi *snap.ComponentSideInfo, preseed bool, meter progress.Meter) error {
+	compName := csi.Component.ComponentName
+	squashfsPath := dirs.StripRootDir(snap.ComponentMountFile(instanceName, compName, csi.Revision))
+	whereDir := dirs.StripRootDir(snap.ComponentMountDir(instanceName, compName, csi.Revision))
+
+	var sysd systemd.Systemd
+	if preseed {
+		sysd = systemd.NewEmulationMode(dirs.GlobalRootDir)
+	} else {
+		sysd = systemd.New(systemd.SystemMode, meter)
+	}
+	name := instanceName + "+" + compName
+	_, err := sysd.EnsureMountUnitFile(name, csi.Revision.String(), squashfsPath, whereDir, "squashfs", nil)
+	return err
+}
@@ -0,0 +1,157 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package backend_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/overlord/snapstate/backend"
+	"github.com/snapcore/snapd/progress"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/naming"
+	"github.com/snapcore/snapd/systemd"
+	"github.com/snapcore/snapd/testutil"
+)
+
+// mockComponentData looks like a squashfs to the snap file format
+// detection, which is enough to install it.
+var mockComponentData = append([]byte("hsqs"), make([]byte, 256)...)
+
+func makeTestComponentFile(c *C) string {
+	compPath := filepath.Join(c.MkDir(), "mysnap+mycomp.comp")
+	c.Assert(os.WriteFile(compPath, mockComponentData, 0644), IsNil)
+	return compPath
+}
+
+func (s *setupSuite) TestSetupComponentDoUndo(c *C) {
+	compPath := makeTestComponentFile(c)
+	csi := snap.NewComponentSideInfo(naming.NewComponentRef("mysnap", "mycomp"), snap.R(33))
+
+	installRecord, err := s.be.SetupComponent(compPath, "mysnap_inst", csi, mockDev, progress.Null)
+	c.Assert(err, IsNil)
+	c.Check(installRecord, DeepEquals, &backend.InstallRecord{})
+
+	// the component file is in the right dir
+	compFile := filepath.Join(dirs.SnapBlobDir, "mysnap_inst+mycomp_33.comp")
+	c.Check(osutil.FileExists(compFile), Equals, true)
+
+	// the mount unit was created
+	where := filepath.Join(dirs.StripRootDir(dirs.SnapMountDir), "mysnap_inst/components/mnt/mycomp/33")
+	mup := systemd.MountUnitPath(where)
+	c.Check(mup, testutil.FileMatches, fmt.Sprintf("(?ms).*^Where=%s", where))
+	c.Check(mup, testutil.FileMatches, "(?ms).*^What=/var/lib/snapd/snaps/mysnap_inst\\+mycomp_33.comp")
+	c.Check(mup, testutil.FileMatches, "(?ms).*^Description=Mount unit for mysnap_inst\\+mycomp, revision 33")
+
+	mountDir := snap.ComponentMountDir("mysnap_inst", "mycomp", snap.R(33))
+	c.Check(osutil.IsDirectory(mountDir), Equals, true)
+
+	// undo removes everything
+	err = s.be.UndoSetupComponent("mysnap_inst", csi, installRecord, progress.Null)
+	c.Assert(err, IsNil)
+
+	l, _ := filepath.Glob(filepath.Join(dirs.SnapServicesDir, "*.mount"))
+	c.Check(l, HasLen, 0)
+	c.Check(osutil.FileExists(mountDir), Equals, false)
+	c.Check(osutil.FileExists(compFile), Equals, false)
+	c.Check(osutil.FileExists(filepath.Join(dirs.SnapMountDir, "mysnap_inst/components")), Equals, false)
+}
+
+func (s *setupSuite) TestSetupComponentKeepsExistingFile(c *C) {
+	csi := snap.NewComponentSideInfo(naming.NewComponentRef("mysnap", "mycomp"), snap.R(33))
+	compFile := snap.ComponentMountFile("mysnap", "mycomp", snap.R(33))
+	c.Assert(os.MkdirAll(filepath.Dir(compFile), 0755), IsNil)
+	c.Assert(os.WriteFile(compFile, mockComponentData, 0644), IsNil)
+
+	installRecord, err := s.be.SetupComponent(compFile, "mysnap", csi, mockDev, progress.Null)
+	c.Assert(err, IsNil)
+	c.Check(installRecord.TargetSnapExisted, Equals, true)
+
+	err = s.be.UndoSetupComponent("mysnap", csi, installRecord, progress.Null)
+	c.Assert(err, IsNil)
+	c.Check(osutil.FileExists(compFile), Equals, true)
+}
+
+func (s *setupSuite) TestSetupComponentAlreadyMounted(c *C) {
+	compPath := makeTestComponentFile(c)
+	csi := snap.NewComponentSideInfo(naming.NewComponentRef("mysnap", "mycomp"), snap.R(33))
+
+	// the component revision is mounted for another revision of the snap
+	installRecord, err := s.be.SetupComponent(compPath, "mysnap", csi, mockDev, progress.Null)
+	c.Assert(err, IsNil)
+	c.Check(installRecord.MountUnitExisted, Equals, false)
+
+	compFile := snap.ComponentMountFile("mysnap", "mycomp", snap.R(33))
+	installRecord, err = s.be.SetupComponent(compFile, "mysnap", csi, mockDev, progress.Null)
+	c.Assert(err, IsNil)
+	c.Check(installRecord, DeepEquals, &backend.InstallRecord{TargetSnapExisted: true, MountUnitExisted: true})
+
+	// undo leaves the mounted component in place
+	err = s.be.UndoSetupComponent("mysnap", csi, installRecord, progress.Null)
+	c.Assert(err, IsNil)
+
+	where := filepath.Join(dirs.StripRootDir(dirs.SnapMountDir), "mysnap/components/mnt/mycomp/33")
+	c.Check(systemd.MountUnitPath(where), testutil.FilePresent)
+	c.Check(osutil.IsDirectory(snap.ComponentMountDir("mysnap", "mycomp", snap.R(33))), Equals, true)
+	c.Check(osutil.FileExists(compFile), Equals, true)
+}
+
+func (s *setupSuite) TestSetupComponentNotSquashfs(c *C) {
+	compPath := filepath.Join(c.MkDir(), "mysnap+mycomp.comp")
+	c.Assert(os.WriteFile(compPath, []byte("not-a-squashfs"), 0644), IsNil)
+	csi := snap.NewComponentSideInfo(naming.NewComponentRef("mysnap", "mycomp"), snap.R(33))
+
+	_, err := s.be.SetupComponent(compPath, "mysnap", csi, mockDev, progress.Null)
+	c.Assert(err, ErrorMatches, `cannot process snap or snapdir: file ".*" is invalid.*`)
+}
+
+func (s *setupSuite) TestLinkUnlinkComponent(c *C) {
+	csi := snap.NewComponentSideInfo(naming.NewComponentRef("mysnap", "mycomp"), snap.R(33))
+
+	err := s.be.LinkComponent("mysnap", snap.R(11), csi)
+	c.Assert(err, IsNil)
+
+	linkPath := filepath.Join(dirs.SnapMountDir, "mysnap/components/11/mycomp")
+	target, err := os.Readlink(linkPath)
+	c.Assert(err, IsNil)
+	c.Check(target, Equals, "../mnt/mycomp/33")
+
+	// a new revision replaces the link
+	csi.Revision = snap.R(34)
+	err = s.be.LinkComponent("mysnap", snap.R(11), csi)
+	c.Assert(err, IsNil)
+	target, err = os.Readlink(linkPath)
+	c.Assert(err, IsNil)
+	c.Check(target, Equals, "../mnt/mycomp/34")
+
+	err = s.be.UnlinkComponent("mysnap", snap.R(11), "mycomp")
+	c.Assert(err, IsNil)
+	c.Check(osutil.IsSymlink(linkPath), Equals, false)
+	c.Check(osutil.FileExists(filepath.Join(dirs.SnapMountDir, "mysnap/components")), Equals, false)
+
+	// unlinking again is fine
+	err = s.be.UnlinkComponent("mysnap", snap.R(11), "mycomp")
+	c.Assert(err, IsNil)
+}
@@ -23,10 +23,25 @@ import (
 	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/progress"
 	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/integrity"
 	"github.com/snapcore/snapd/systemd"
 )
 
-func addMountUnit(s *snap.Info, preseed bool, meter progress.Meter) error {
+// DmVerityOptions returns the options to have the mount of the given snap
+// verified with dm-verity using the integrity data appended to the snap
+// file, or nil if there are none.
+func DmVerityOptions(s snap.PlaceInfo, integrityData *integrity.IntegrityData) *systemd.DmVerityOptions {
+	if integrityData == nil {
+		return nil
+	}
+	return &systemd.DmVerityOptions{
+		RootHash:   integrityData.Header.DmVerity.RootHash,
+		HashDevice: dirs.StripRootDir(s.MountFile()),
+		HashOffset: integrityData.HashOffset(),
+	}
+}
+
+func addMountUnit(s *snap.Info, integrityData *integrity.IntegrityData, preseed bool, meter progress.Meter) error {
 	squashfsPath := dirs.StripRootDir(s.MountFile())
 	whereDir := dirs.StripRootDir(s.MountDir())
 
@@ -36,7 +51,7 @@ func addMountUnit(s *snap.Info, preseed bool, meter progress.Meter) error {
 	} else {
 		sysd = systemd.New(systemd.SystemMode, meter)
 	}
-	_, err := sysd.EnsureMountUnitFile(s.InstanceName(), s.Revision.String(), squashfsPath, whereDir, "squashfs")
+	_, err := sysd.EnsureMountUnitFile(s.InstanceName(), s.Revision.String(), squashfsPath, whereDir, "squashfs", DmVerityOptions(s, integrityData))
 	return err
 }
 
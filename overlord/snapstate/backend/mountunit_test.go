@@ -29,12 +29,15 @@ import (
 	"github.com/snapcore/snapd/overlord/snapstate/backend"
 	"github.com/snapcore/snapd/progress"
 	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/integrity"
+	"github.com/snapcore/snapd/snap/integrity/dmverity"
 	"github.com/snapcore/snapd/systemd"
 	"github.com/snapcore/snapd/testutil"
 )
 
 type ParamsForEnsureMountUnitFile struct {
 	name, revision, what, where, fstype string
+	dmVerity                            *systemd.DmVerityOptions
 }
 
 type ResultForEnsureMountUnitFile struct {
@@ -55,9 +58,9 @@ type FakeSystemd struct {
 	ListMountUnitsResult ResultForListMountUnits
 }
 
-func (s *FakeSystemd) EnsureMountUnitFile(name, revision, what, where, fstype string) (string, error) {
+func (s *FakeSystemd) EnsureMountUnitFile(name, revision, what, where, fstype string, dmVerity *systemd.DmVerityOptions) (string, error) {
 	s.EnsureMountUnitFileCalls = append(s.EnsureMountUnitFileCalls,
-		ParamsForEnsureMountUnitFile{name, revision, what, where, fstype})
+		ParamsForEnsureMountUnitFile{name, revision, what, where, fstype, dmVerity})
 	return s.EnsureMountUnitFileResult.path, s.EnsureMountUnitFileResult.err
 }
 
@@ -114,7 +117,7 @@ func (s *mountunitSuite) TestAddMountUnit(c *C) {
 		Version:       "1.1",
 		Architectures: []string{"all"},
 	}
-	err := backend.AddMountUnit(info, false, progress.Null)
+	err := backend.AddMountUnit(info, nil, false, progress.Null)
 	c.Check(err, Equals, expectedErr)
 
 	// ensure correct parameters
@@ -130,6 +133,47 @@ func (s *mountunitSuite) TestAddMountUnit(c *C) {
 	})
 }
 
+func (s *mountunitSuite) TestAddMountUnitWithIntegrity(c *C) {
+	var sysd *FakeSystemd
+	restore := systemd.MockNewSystemd(func(be systemd.Backend, roodDir string, mode systemd.InstanceMode, meter systemd.Reporter) systemd.Systemd {
+		sysd = &FakeSystemd{}
+		return sysd
+	})
+	defer restore()
+
+	info := &snap.Info{
+		SideInfo: snap.SideInfo{
+			RealName: "foo",
+			Revision: snap.R(13),
+		},
+		Version:       "1.1",
+		Architectures: []string{"all"},
+	}
+	integrityData := &integrity.IntegrityData{
+		Header: integrity.IntegrityDataHeader{
+			Type:     "integrity",
+			Size:     8192,
+			DmVerity: dmverity.Info{RootHash: "abcd"},
+		},
+		Offset: 4096,
+	}
+	err := backend.AddMountUnit(info, integrityData, false, progress.Null)
+	c.Check(err, IsNil)
+
+	c.Check(sysd.EnsureMountUnitFileCalls, DeepEquals, []ParamsForEnsureMountUnitFile{{
+		name:     "foo",
+		revision: "13",
+		what:     "/var/lib/snapd/snaps/foo_13.snap",
+		where:    fmt.Sprintf("%s/foo/13", dirs.StripRootDir(dirs.SnapMountDir)),
+		fstype:   "squashfs",
+		dmVerity: &systemd.DmVerityOptions{
+			RootHash:   "abcd",
+			HashDevice: "/var/lib/snapd/snaps/foo_13.snap",
+			HashOffset: 8192,
+		},
+	}})
+}
+
 func (s *mountunitSuite) TestRemoveMountUnit(c *C) {
 	expectedErr := errors.New("removal error")
 
@@ -29,6 +29,7 @@ import (
 	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/progress"
 	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/integrity"
 )
 
 // InstallRecord keeps a record of what installation effectively did as hints
@@ -37,10 +38,17 @@ type InstallRecord struct {
 	// TargetSnapExisted indicates that the target .snap file under /var/lib/snapd/snap already existed when the
 	// backend attempted SetupSnap() through squashfs Install() and should be kept.
 	TargetSnapExisted bool `json:"target-snap-existed,omitempty"`
+	// MountUnitExisted indicates that the mount unit of the component was already there when the backend
+	// attempted SetupComponent(), as the component revision is used by another revision of the snap, and
+	// should be kept together with the mounted component.
+	MountUnitExisted bool `json:"mount-unit-existed,omitempty"`
 }
 
 type SetupSnapOptions struct {
 	SkipKernelExtraction bool
+	// Integrity holds the verified integrity data of the snap, if set the
+	// snap is mounted with dm-verity.
+	Integrity *integrity.IntegrityData
 }
 
 // SetupSnap does prepare and mount the snap for further processing.
@@ -96,7 +104,7 @@ func (b Backend) SetupSnap(snapFilePath, instanceName string, sideInfo *snap.Sid
 	}
 
 	// generate the mount unit for the squashfs
-	if err := addMountUnit(s, b.preseed, meter); err != nil {
+	if err := addMountUnit(s, setupOpts.Integrity, b.preseed, meter); err != nil {
 		return snapType, nil, err
 	}
 
@@ -43,6 +43,7 @@ import (
 	"github.com/snapcore/snapd/progress"
 	"github.com/snapcore/snapd/randutil"
 	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/integrity"
 	"github.com/snapcore/snapd/snap/snapfile"
 	"github.com/snapcore/snapd/store"
 	"github.com/snapcore/snapd/store/storetest"
@@ -72,6 +73,7 @@ type fakeOp struct {
 	otherInstances         bool
 	unlinkFirstInstallUndo bool
 	skipKernelExtraction   bool
+	integrityData          *integrity.IntegrityData
 
 	services         []string
 	disabledServices []string
@@ -84,6 +86,9 @@ type fakeOp struct {
 
 	dirOpts  *dirs.SnapDirOptions
 	undoInfo *backend.UndoInfo
+
+	compName string
+	snapRev  snap.Revision
 }
 
 type fakeOps []fakeOp
@@ -860,6 +865,10 @@ func (f *fakeSnappyBackend) SetupSnap(snapFilePath, instanceName string, si *sna
 	if si != nil {
 		revno = si.Revision
 	}
+	var integrityData *integrity.IntegrityData
+	if opts != nil {
+		integrityData = opts.Integrity
+	}
 	f.appendOp(&fakeOp{
 		op:    "setup-snap",
 		name:  instanceName,
@@ -867,6 +876,7 @@ func (f *fakeSnappyBackend) SetupSnap(snapFilePath, instanceName string, si *sna
 		revno: revno,
 
 		skipKernelExtraction: opts != nil && opts.SkipKernelExtraction,
+		integrityData:        integrityData,
 	})
 	snapType := snap.TypeApp
 	switch si.RealName {
@@ -1381,6 +1391,62 @@ func (f *fakeSnappyBackend) InitXDGDirs(info *snap.Info) error {
 	return f.maybeErrForLastOp()
 }
 
+func (f *fakeSnappyBackend) SetupComponent(compFilePath, instanceName string, csi *snap.ComponentSideInfo, dev snap.Device, meter progress.Meter) (*backend.InstallRecord, error) {
+	meter.Notify("setup-component")
+	f.appendOp(&fakeOp{
+		op:       "setup-component",
+		name:     instanceName,
+		path:     compFilePath,
+		compName: csi.Component.ComponentName,
+		revno:    csi.Revision,
+	})
+	if err := f.maybeErrForLastOp(); err != nil {
+		return nil, err
+	}
+	return &backend.InstallRecord{}, nil
+}
+
+func (f *fakeSnappyBackend) UndoSetupComponent(instanceName string, csi *snap.ComponentSideInfo, installRecord *backend.InstallRecord, meter progress.Meter) error {
+	f.appendOp(&fakeOp{
+		op:       "undo-setup-component",
+		name:     instanceName,
+		compName: csi.Component.ComponentName,
+		revno:    csi.Revision,
+	})
+	return f.maybeErrForLastOp()
+}
+
+func (f *fakeSnappyBackend) RemoveComponentFiles(instanceName string, csi *snap.ComponentSideInfo, installRecord *backend.InstallRecord, meter progress.Meter) error {
+	f.appendOp(&fakeOp{
+		op:       "remove-component-files",
+		name:     instanceName,
+		compName: csi.Component.ComponentName,
+		revno:    csi.Revision,
+	})
+	return f.maybeErrForLastOp()
+}
+
+func (f *fakeSnappyBackend) LinkComponent(instanceName string, snapRev snap.Revision, csi *snap.ComponentSideInfo) error {
+	f.appendOp(&fakeOp{
+		op:       "link-component",
+		name:     instanceName,
+		snapRev:  snapRev,
+		compName: csi.Component.ComponentName,
+		revno:    csi.Revision,
+	})
+	return f.maybeErrForLastOp()
+}
+
+func (f *fakeSnappyBackend) UnlinkComponent(instanceName string, snapRev snap.Revision, compName string) error {
+	f.appendOp(&fakeOp{
+		op:       "unlink-component",
+		name:     instanceName,
+		snapRev:  snapRev,
+		compName: compName,
+	})
+	return f.maybeErrForLastOp()
+}
+
 func (f *fakeSnappyBackend) appendOp(op *fakeOp) {
 	f.mu.Lock()
 	defer f.mu.Unlock()
@@ -0,0 +1,255 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/snapcore/snapd/i18n"
+	"github.com/snapcore/snapd/overlord/snapstate/backend"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+)
+
+// ComponentSetup holds the necessary component details to perform
+// most component manager tasks.
+type ComponentSetup struct {
+	// CompSideInfo for metadata not coming from the component
+	CompSideInfo *snap.ComponentSideInfo `json:"comp-side-info,omitempty"`
+	// CompType is the type of the component
+	CompType snap.ComponentType `json:"comp-type,omitempty"`
+	// CompPath is the path to the component file
+	CompPath string `json:"comp-path,omitempty"`
+}
+
+// ComponentName returns the name of the component.
+func (compsup *ComponentSetup) ComponentName() string {
+	return compsup.CompSideInfo.Component.ComponentName
+}
+
+// Revision returns the revision of the component.
+func (compsup *ComponentSetup) Revision() snap.Revision {
+	return compsup.CompSideInfo.Revision
+}
+
+var openComponentFile = backend.OpenComponentFile
+
+// InstallComponentPath returns a set of tasks for installing a component
+// from a file path for the current revision of an installed snap. The
+// component replaces the revision of the same component used by that
+// snap revision, if any.
+//
+// Note that the file must be verified by the caller or flags.DangerousOK
+// must be set, as only local revisions are assigned here if the
+// revision in csi is unset.
+func InstallComponentPath(st *state.State, csi *snap.ComponentSideInfo, info *snap.Info, path string, flags Flags) (*state.TaskSet, error) {
+	var snapst SnapState
+	err := Get(st, info.InstanceName(), &snapst)
+	if errors.Is(err, state.ErrNoState) {
+		return nil, &snap.NotInstalledError{Snap: info.InstanceName()}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if snapst.Current != info.Revision {
+		return nil, fmt.Errorf("cannot install component %q for snap %q: revision %s is not the current revision", csi.Component, info.InstanceName(), info.Revision)
+	}
+
+	// It is ok to open the component file here because we either have
+	// side info or the user passed --dangerous
+	compInfo, _, err := openComponentFile(path, info, csi)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := CheckChangeConflict(st, info.InstanceName(), nil); err != nil {
+		return nil, err
+	}
+
+	snapsup := &SnapSetup{
+		SideInfo:    &info.SideInfo,
+		InstanceKey: info.InstanceKey,
+		Type:        info.Type(),
+		Flags:       flags.ForSnapSetup(),
+	}
+	compsup := &ComponentSetup{
+		CompSideInfo: &compInfo.ComponentSideInfo,
+		CompType:     compInfo.Type,
+		CompPath:     path,
+	}
+
+	return doInstallComponent(st, &snapst, compsup, snapsup), nil
+}
+
+func doInstallComponent(st *state.State, snapst *SnapState, compsup *ComponentSetup, snapsup *SnapSetup) *state.TaskSet {
+	compName := compsup.CompSideInfo.Component.String()
+	revisionStr := ""
+	if !compsup.Revision().Unset() {
+		revisionStr = fmt.Sprintf(" (%s)", compsup.Revision())
+	}
+
+	prepare := st.NewTask("prepare-component", fmt.Sprintf(i18n.G("Prepare component %q%s"), compsup.CompPath, revisionStr))
+	prepare.Set("snap-setup", snapsup)
+	prepare.Set("component-setup", compsup)
+	tasks := []*state.Task{prepare}
+
+	prev := prepare
+	addTask := func(t *state.Task) {
+		t.Set("snap-setup-task", prepare.ID())
+		t.Set("component-setup-task", prepare.ID())
+		t.WaitFor(prev)
+		tasks = append(tasks, t)
+		prev = t
+	}
+
+	addTask(st.NewTask("mount-component", fmt.Sprintf(i18n.G("Mount component %q%s"), compName, revisionStr)))
+	addTask(st.NewTask("link-component", fmt.Sprintf(i18n.G("Make component %q%s available to the system"), compName, revisionStr)))
+
+	// discard the revision being replaced, if not used by another
+	// revision of the snap
+	if old := snapst.componentSideInfo(snapsup.Revision(), compsup.ComponentName()); old != nil && !old.Equal(compsup.CompSideInfo) {
+		discard := st.NewTask("discard-component", fmt.Sprintf(i18n.G("Remove component %q (%s) from the system"), compName, old.Revision))
+		discard.Set("component-setup", &ComponentSetup{CompSideInfo: old, CompType: compsup.CompType})
+		addTask(discard)
+	}
+
+	return state.NewTaskSet(tasks...)
+}
+
+// RemoveComponent returns a set of tasks for removing the given component
+// from the current revision of an installed snap.
+func RemoveComponent(st *state.State, instanceName, compName string) (*state.TaskSet, error) {
+	var snapst SnapState
+	err := Get(st, instanceName, &snapst)
+	if errors.Is(err, state.ErrNoState) {
+		return nil, &snap.NotInstalledError{Snap: instanceName}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	csi := snapst.componentSideInfo(snapst.Current, compName)
+	if csi == nil {
+		return nil, &snap.ComponentNotInstalledError{Snap: instanceName, Component: compName, Rev: snapst.Current}
+	}
+
+	if err := CheckChangeConflict(st, instanceName, nil); err != nil {
+		return nil, err
+	}
+
+	info, err := snapst.CurrentInfo()
+	if err != nil {
+		return nil, err
+	}
+	snapsup := &SnapSetup{
+		SideInfo:    &info.SideInfo,
+		InstanceKey: info.InstanceKey,
+		Type:        info.Type(),
+	}
+	compsup := &ComponentSetup{
+		CompSideInfo: csi,
+	}
+	if comp := info.Components[compName]; comp != nil {
+		compsup.CompType = comp.Type
+	}
+
+	fullName := csi.Component.String()
+	unlink := st.NewTask("unlink-component", fmt.Sprintf(i18n.G("Make component %q unavailable to the system"), fullName))
+	unlink.Set("snap-setup", snapsup)
+	unlink.Set("component-setup", compsup)
+
+	discard := st.NewTask("discard-component", fmt.Sprintf(i18n.G("Remove component %q (%s) from the system"), fullName, csi.Revision))
+	discard.Set("snap-setup-task", unlink.ID())
+	discard.Set("component-setup-task", unlink.ID())
+	discard.WaitFor(unlink)
+
+	return state.NewTaskSet(unlink, discard), nil
+}
+
+// componentSideInfo returns the side info of the given component used by
+// the given snap revision, or nil if the component is not installed for
+// it.
+func (snapst *SnapState) componentSideInfo(snapRev snap.Revision, compName string) *snap.ComponentSideInfo {
+	for _, csi := range snapst.Components[snapRev.N] {
+		if csi.Component.ComponentName == compName {
+			return csi
+		}
+	}
+	return nil
+}
+
+// setComponentSideInfo records that the given component revision is used
+// by the given snap revision, replacing the current one if any.
+func (snapst *SnapState) setComponentSideInfo(snapRev snap.Revision, csi *snap.ComponentSideInfo) {
+	snapst.removeComponentSideInfo(snapRev, csi.Component.ComponentName)
+	if snapst.Components == nil {
+		snapst.Components = make(map[int][]*snap.ComponentSideInfo)
+	}
+	snapst.Components[snapRev.N] = append(snapst.Components[snapRev.N], csi)
+}
+
+// removeComponentSideInfo drops the given component from the components
+// used by the given snap revision.
+func (snapst *SnapState) removeComponentSideInfo(snapRev snap.Revision, compName string) {
+	comps := snapst.Components[snapRev.N]
+	newComps := make([]*snap.ComponentSideInfo, 0, len(comps))
+	for _, csi := range comps {
+		if csi.Component.ComponentName != compName {
+			newComps = append(newComps, csi)
+		}
+	}
+	if len(newComps) == 0 {
+		delete(snapst.Components, snapRev.N)
+		if len(snapst.Components) == 0 {
+			snapst.Components = nil
+		}
+		return
+	}
+	snapst.Components[snapRev.N] = newComps
+}
+
+// isComponentInUse returns whether the given component revision is used
+// by any revision of the snap.
+func (snapst *SnapState) isComponentInUse(csi *snap.ComponentSideInfo) bool {
+	for _, comps := range snapst.Components {
+		for _, other := range comps {
+			if other.Equal(csi) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// localComponentRevision returns the "latest" local revision of the given
+// component across all revisions of the snap. Local revisions start at -1
+// and are counted down.
+func (snapst *SnapState) localComponentRevision(compName string) snap.Revision {
+	var local snap.Revision
+	for _, comps := range snapst.Components {
+		for _, csi := range comps {
+			if csi.Component.ComponentName == compName && csi.Revision.Local() && csi.Revision.N < local.N {
+				local = csi.Revision
+			}
+		}
+	}
+	return local
+}
@@ -0,0 +1,496 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate_test
+
+import (
+	"errors"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/naming"
+	"github.com/snapcore/snapd/snap/snaptest"
+	"github.com/snapcore/snapd/testutil"
+)
+
+const snapWithComponentsYaml = `name: some-snap
+version: 1.0
+components:
+  comp:
+    type: test
+`
+
+func (s *snapmgrTestSuite) mockSnapWithComponents(c *C, comps ...*snap.ComponentSideInfo) *snap.Info {
+	si := &snap.SideInfo{
+		SnapID:   "some-snap-id",
+		RealName: "some-snap",
+		Revision: snap.R(7),
+	}
+	snapst := &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{si},
+		Current:  si.Revision,
+		SnapType: "app",
+	}
+	if len(comps) > 0 {
+		snapst.Components = map[int][]*snap.ComponentSideInfo{7: comps}
+	}
+	snapstate.Set(s.state, "some-snap", snapst)
+
+	s.AddCleanup(snapstate.MockOpenComponentFile(func(path string, info *snap.Info, csi *snap.ComponentSideInfo) (*snap.ComponentInfo, snap.Container, error) {
+		c.Check(path, Equals, "/path/to/some-snap+comp.comp")
+		c.Check(info.InstanceName(), Equals, "some-snap")
+		return &snap.ComponentInfo{
+			Component:         csi.Component,
+			Type:              snap.TestComponent,
+			Version:           "1.0",
+			ComponentSideInfo: *csi,
+		}, nil, nil
+	}))
+
+	return snaptest.MockInfo(c, snapWithComponentsYaml, si)
+}
+
+func (s *snapmgrTestSuite) installComponentPath(c *C, info *snap.Info, csi *snap.ComponentSideInfo) *state.Change {
+	ts, err := snapstate.InstallComponentPath(s.state, csi, info, "/path/to/some-snap+comp.comp", snapstate.Flags{})
+	c.Assert(err, IsNil)
+
+	chg := s.state.NewChange("install-component", "install a component")
+	chg.AddAll(ts)
+	return chg
+}
+
+func (s *snapmgrTestSuite) TestInstallComponentPathTasks(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	info := s.mockSnapWithComponents(c)
+	csi := snap.NewComponentSideInfo(naming.NewComponentRef("some-snap", "comp"), snap.R(0))
+	ts, err := snapstate.InstallComponentPath(s.state, csi, info, "/path/to/some-snap+comp.comp", snapstate.Flags{})
+	c.Assert(err, IsNil)
+	c.Check(taskKinds(ts.Tasks()), DeepEquals, []string{
+		"prepare-component",
+		"mount-component",
+		"link-component",
+	})
+
+	chg := s.state.NewChange("install-component", "install a component")
+	chg.AddAll(ts)
+	compsup, snapsup, err := snapstate.TaskComponentSetup(ts.Tasks()[2])
+	c.Assert(err, IsNil)
+	c.Check(snapsup.InstanceName(), Equals, "some-snap")
+	c.Check(snapsup.Revision(), Equals, snap.R(7))
+	c.Check(compsup, DeepEquals, &snapstate.ComponentSetup{
+		CompSideInfo: csi,
+		CompType:     snap.TestComponent,
+		CompPath:     "/path/to/some-snap+comp.comp",
+	})
+}
+
+func (s *snapmgrTestSuite) TestInstallComponentPathRunThrough(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	info := s.mockSnapWithComponents(c)
+	csi := snap.NewComponentSideInfo(naming.NewComponentRef("some-snap", "comp"), snap.R(0))
+	chg := s.installComponentPath(c, info, csi)
+
+	defer s.se.Stop()
+	s.settle(c)
+
+	c.Assert(chg.Err(), IsNil)
+	c.Check(s.fakeBackend.ops, DeepEquals, fakeOps{
+		{
+			op:       "setup-component",
+			name:     "some-snap",
+			path:     "/path/to/some-snap+comp.comp",
+			compName: "comp",
+			revno:    snap.R(-1),
+		},
+		{
+			op:       "link-component",
+			name:     "some-snap",
+			snapRev:  snap.R(7),
+			compName: "comp",
+			revno:    snap.R(-1),
+		},
+	})
+
+	var snapst snapstate.SnapState
+	c.Assert(snapstate.Get(s.state, "some-snap", &snapst), IsNil)
+	c.Check(snapst.Components, DeepEquals, map[int][]*snap.ComponentSideInfo{
+		7: {snap.NewComponentSideInfo(naming.NewComponentRef("some-snap", "comp"), snap.R(-1))},
+	})
+}
+
+func (s *snapmgrTestSuite) TestInstallComponentPathReplacesOldRevision(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	old := snap.NewComponentSideInfo(naming.NewComponentRef("some-snap", "comp"), snap.R(-1))
+	info := s.mockSnapWithComponents(c, old)
+	csi := snap.NewComponentSideInfo(naming.NewComponentRef("some-snap", "comp"), snap.R(0))
+	chg := s.installComponentPath(c, info, csi)
+	c.Check(taskKinds(chg.Tasks()), DeepEquals, []string{
+		"prepare-component",
+		"mount-component",
+		"link-component",
+		"discard-component",
+	})
+
+	defer s.se.Stop()
+	s.settle(c)
+
+	c.Assert(chg.Err(), IsNil)
+	c.Check(s.fakeBackend.ops.Ops(), DeepEquals, []string{
+		"setup-component",
+		"link-component",
+		"remove-component-files",
+	})
+	c.Check(s.fakeBackend.ops[0].revno, Equals, snap.R(-2))
+	c.Check(s.fakeBackend.ops[2].revno, Equals, snap.R(-1))
+
+	var snapst snapstate.SnapState
+	c.Assert(snapstate.Get(s.state, "some-snap", &snapst), IsNil)
+	c.Check(snapst.Components, DeepEquals, map[int][]*snap.ComponentSideInfo{
+		7: {snap.NewComponentSideInfo(naming.NewComponentRef("some-snap", "comp"), snap.R(-2))},
+	})
+}
+
+func (s *snapmgrTestSuite) TestInstallComponentPathUndo(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	old := snap.NewComponentSideInfo(naming.NewComponentRef("some-snap", "comp"), snap.R(-1))
+	info := s.mockSnapWithComponents(c, old)
+	csi := snap.NewComponentSideInfo(naming.NewComponentRef("some-snap", "comp"), snap.R(0))
+	chg := s.installComponentPath(c, info, csi)
+
+	// make the change fail after linking
+	tasks := chg.Tasks()
+	last := tasks[len(tasks)-1]
+	terr := s.state.NewTask("error-trigger", "provoking total undo")
+	terr.WaitFor(last)
+	chg.AddTask(terr)
+
+	defer s.se.Stop()
+	s.settle(c)
+
+	c.Assert(chg.Err(), NotNil)
+	c.Check(s.fakeBackend.ops.Ops(), DeepEquals, []string{
+		"setup-component",
+		"link-component",
+		"remove-component-files",
+		"link-component",
+		"undo-setup-component",
+	})
+	// the old revision is linked again
+	c.Check(s.fakeBackend.ops[3].revno, Equals, snap.R(-1))
+
+	var snapst snapstate.SnapState
+	c.Assert(snapstate.Get(s.state, "some-snap", &snapst), IsNil)
+	c.Check(snapst.Components, DeepEquals, map[int][]*snap.ComponentSideInfo{7: {old}})
+}
+
+func (s *snapmgrTestSuite) TestInstallComponentPathErrors(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	info := s.mockSnapWithComponents(c)
+	csi := snap.NewComponentSideInfo(naming.NewComponentRef("some-snap", "comp"), snap.R(0))
+
+	// wrong revision
+	otherInfo := *info
+	otherInfo.Revision = snap.R(8)
+	_, err := snapstate.InstallComponentPath(s.state, csi, &otherInfo, "/path/to/some-snap+comp.comp", snapstate.Flags{})
+	c.Check(err, ErrorMatches, `cannot install component "some-snap\+comp" for snap "some-snap": revision 8 is not the current revision`)
+
+	// snap not installed
+	snapstate.Set(s.state, "some-snap", nil)
+	_, err = snapstate.InstallComponentPath(s.state, csi, info, "/path/to/some-snap+comp.comp", snapstate.Flags{})
+	c.Check(err, ErrorMatches, `snap "some-snap" is not installed`)
+}
+
+func (s *snapmgrTestSuite) TestInstallComponentPathInvalidComponent(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	info := s.mockSnapWithComponents(c)
+	restore := snapstate.MockOpenComponentFile(func(string, *snap.Info, *snap.ComponentSideInfo) (*snap.ComponentInfo, snap.Container, error) {
+		return nil, nil, errors.New(`component "some-snap+other" is not declared by snap "some-snap"`)
+	})
+	defer restore()
+
+	csi := snap.NewComponentSideInfo(naming.NewComponentRef("some-snap", "other"), snap.R(0))
+	_, err := snapstate.InstallComponentPath(s.state, csi, info, "/path/to/some-snap+comp.comp", snapstate.Flags{})
+	c.Check(err, ErrorMatches, `component "some-snap\+other" is not declared by snap "some-snap"`)
+}
+
+func (s *snapmgrTestSuite) TestInstallComponentPathConflict(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	info := s.mockSnapWithComponents(c)
+	csi := snap.NewComponentSideInfo(naming.NewComponentRef("some-snap", "comp"), snap.R(0))
+	s.installComponentPath(c, info, csi)
+
+	_, err := snapstate.InstallComponentPath(s.state, csi, info, "/path/to/some-snap+comp.comp", snapstate.Flags{})
+	c.Check(err, ErrorMatches, `snap "some-snap" has "install-component" change in progress`)
+}
+
+func (s *snapmgrTestSuite) TestRemoveComponentRunThrough(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	csi := snap.NewComponentSideInfo(naming.NewComponentRef("some-snap", "comp"), snap.R(3))
+	s.mockSnapWithComponents(c, csi)
+
+	ts, err := snapstate.RemoveComponent(s.state, "some-snap", "comp")
+	c.Assert(err, IsNil)
+	c.Check(taskKinds(ts.Tasks()), DeepEquals, []string{"unlink-component", "discard-component"})
+	chg := s.state.NewChange("remove-component", "remove a component")
+	chg.AddAll(ts)
+
+	defer s.se.Stop()
+	s.settle(c)
+
+	c.Assert(chg.Err(), IsNil)
+	c.Check(s.fakeBackend.ops, DeepEquals, fakeOps{
+		{
+			op:       "unlink-component",
+			name:     "some-snap",
+			snapRev:  snap.R(7),
+			compName: "comp",
+		},
+		{
+			op:       "remove-component-files",
+			name:     "some-snap",
+			compName: "comp",
+			revno:    snap.R(3),
+		},
+	})
+
+	var snapst snapstate.SnapState
+	c.Assert(snapstate.Get(s.state, "some-snap", &snapst), IsNil)
+	c.Check(snapst.Components, IsNil)
+}
+
+func (s *snapmgrTestSuite) TestRemoveComponentKeepsFilesInUse(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	csi := snap.NewComponentSideInfo(naming.NewComponentRef("some-snap", "comp"), snap.R(3))
+	s.mockSnapWithComponents(c, csi)
+
+	// the component revision is also used by a previous snap revision
+	var snapst snapstate.SnapState
+	c.Assert(snapstate.Get(s.state, "some-snap", &snapst), IsNil)
+	snapst.Sequence = append([]*snap.SideInfo{{RealName: "some-snap", SnapID: "some-snap-id", Revision: snap.R(6)}}, snapst.Sequence...)
+	snapst.Components[6] = []*snap.ComponentSideInfo{csi}
+	snapstate.Set(s.state, "some-snap", &snapst)
+
+	ts, err := snapstate.RemoveComponent(s.state, "some-snap", "comp")
+	c.Assert(err, IsNil)
+	chg := s.state.NewChange("remove-component", "remove a component")
+	chg.AddAll(ts)
+
+	defer s.se.Stop()
+	s.settle(c)
+
+	c.Assert(chg.Err(), IsNil)
+	c.Check(s.fakeBackend.ops.Ops(), DeepEquals, []string{"unlink-component"})
+
+	c.Assert(snapstate.Get(s.state, "some-snap", &snapst), IsNil)
+	c.Check(snapst.Components, DeepEquals, map[int][]*snap.ComponentSideInfo{6: {csi}})
+}
+
+func (s *snapmgrTestSuite) TestRemoveComponentNotInstalled(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.mockSnapWithComponents(c)
+
+	_, err := snapstate.RemoveComponent(s.state, "some-snap", "comp")
+	c.Check(err, ErrorMatches, `component "comp" is not installed for revision 7 of snap "some-snap"`)
+
+	_, err = snapstate.RemoveComponent(s.state, "other-snap", "comp")
+	c.Check(err, ErrorMatches, `snap "other-snap" is not installed`)
+}
+
+func (s *snapmgrTestSuite) TestRemoveSnapDiscardsComponents(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	csi := snap.NewComponentSideInfo(naming.NewComponentRef("some-snap", "comp"), snap.R(3))
+	s.mockSnapWithComponents(c, csi)
+
+	ts, err := snapstate.Remove(s.state, "some-snap", snap.R(0), nil)
+	c.Assert(err, IsNil)
+	chg := s.state.NewChange("remove", "remove a snap")
+	chg.AddAll(ts)
+
+	defer s.se.Stop()
+	s.settle(c)
+
+	c.Assert(chg.Err(), IsNil)
+	unlink := s.fakeBackend.ops.MustFindOp(c, "unlink-component")
+	c.Check(unlink.snapRev, Equals, snap.R(7))
+	c.Check(unlink.compName, Equals, "comp")
+	remove := s.fakeBackend.ops.MustFindOp(c, "remove-component-files")
+	c.Check(remove.revno, Equals, snap.R(3))
+
+	var snapst snapstate.SnapState
+	c.Assert(snapstate.Get(s.state, "some-snap", &snapst), testutil.ErrorIs, state.ErrNoState)
+}
+
+// mockReadInfoWithComponents makes the given revisions of some-snap
+// declare the given components.
+func (s *snapmgrTestSuite) mockReadInfoWithComponents(c *C, comps map[snap.Revision]map[string]*snap.Component) {
+	s.AddCleanup(snapstate.MockSnapReadInfo(func(name string, si *snap.SideInfo) (*snap.Info, error) {
+		info, err := s.fakeBackend.ReadInfo(name, si)
+		if err != nil {
+			return nil, err
+		}
+		if name == "some-snap" {
+			info.Components = comps[si.Revision]
+		}
+		return info, nil
+	}))
+}
+
+func (s *snapmgrTestSuite) TestUpdateCarriesComponentsForward(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	comp := snap.NewComponentSideInfo(naming.NewComponentRef("some-snap", "comp"), snap.R(-1))
+	other := snap.NewComponentSideInfo(naming.NewComponentRef("some-snap", "other"), snap.R(3))
+	s.mockSnapWithComponents(c, comp, other)
+	s.mockReadInfoWithComponents(c, map[snap.Revision]map[string]*snap.Component{
+		snap.R(7): {
+			"comp":  {Name: "comp", Type: snap.TestComponent},
+			"other": {Name: "other", Type: snap.TestComponent},
+		},
+		// other is not declared anymore by the new revision
+		snap.R(11): {
+			"comp": {Name: "comp", Type: snap.TestComponent},
+		},
+	})
+
+	ts, err := snapstate.Update(s.state, "some-snap", &snapstate.RevisionOptions{Channel: "some-channel"}, s.user.ID, snapstate.Flags{})
+	c.Assert(err, IsNil)
+	c.Check(taskKinds(ts.Tasks()), testutil.Contains, "link-snap-components")
+	chg := s.state.NewChange("refresh", "refresh a snap")
+	chg.AddAll(ts)
+
+	defer s.se.Stop()
+	s.settle(c)
+
+	c.Assert(chg.Err(), IsNil)
+	link := s.fakeBackend.ops.MustFindOp(c, "link-component")
+	c.Check(link.snapRev, Equals, snap.R(11))
+	c.Check(link.compName, Equals, "comp")
+	c.Check(link.revno, Equals, snap.R(-1))
+
+	var snapst snapstate.SnapState
+	c.Assert(snapstate.Get(s.state, "some-snap", &snapst), IsNil)
+	c.Check(snapst.Current, Equals, snap.R(11))
+	c.Check(snapst.Components, DeepEquals, map[int][]*snap.ComponentSideInfo{
+		7:  {comp, other},
+		11: {comp},
+	})
+}
+
+func (s *snapmgrTestSuite) TestUpdateComponentsUndo(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	comp := snap.NewComponentSideInfo(naming.NewComponentRef("some-snap", "comp"), snap.R(-1))
+	s.mockSnapWithComponents(c, comp)
+	s.mockReadInfoWithComponents(c, map[snap.Revision]map[string]*snap.Component{
+		snap.R(7):  {"comp": {Name: "comp", Type: snap.TestComponent}},
+		snap.R(11): {"comp": {Name: "comp", Type: snap.TestComponent}},
+	})
+	s.fakeBackend.linkSnapFailTrigger = filepath.Join(dirs.SnapMountDir, "some-snap/11")
+
+	ts, err := snapstate.Update(s.state, "some-snap", &snapstate.RevisionOptions{Channel: "some-channel"}, s.user.ID, snapstate.Flags{})
+	c.Assert(err, IsNil)
+	chg := s.state.NewChange("refresh", "refresh a snap")
+	chg.AddAll(ts)
+
+	defer s.se.Stop()
+	s.settle(c)
+
+	c.Assert(chg.Err(), NotNil)
+	unlink := s.fakeBackend.ops.MustFindOp(c, "unlink-component")
+	c.Check(unlink.snapRev, Equals, snap.R(11))
+	c.Check(unlink.compName, Equals, "comp")
+
+	var snapst snapstate.SnapState
+	c.Assert(snapstate.Get(s.state, "some-snap", &snapst), IsNil)
+	c.Check(snapst.Current, Equals, snap.R(7))
+	c.Check(snapst.Components, DeepEquals, map[int][]*snap.ComponentSideInfo{7: {comp}})
+}
+
+func (s *snapmgrTestSuite) TestRevertRestoresComponentsOfRevision(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	siOld := &snap.SideInfo{RealName: "some-snap", SnapID: "some-snap-id", Revision: snap.R(2)}
+	si := &snap.SideInfo{RealName: "some-snap", SnapID: "some-snap-id", Revision: snap.R(7)}
+	oldComp := snap.NewComponentSideInfo(naming.NewComponentRef("some-snap", "comp"), snap.R(1))
+	comp := snap.NewComponentSideInfo(naming.NewComponentRef("some-snap", "comp"), snap.R(2))
+	snapstate.Set(s.state, "some-snap", &snapstate.SnapState{
+		Active:   true,
+		SnapType: "app",
+		Sequence: []*snap.SideInfo{siOld, si},
+		Current:  si.Revision,
+		Components: map[int][]*snap.ComponentSideInfo{
+			2: {oldComp},
+			7: {comp},
+		},
+	})
+
+	ts, err := snapstate.Revert(s.state, "some-snap", snapstate.Flags{}, "")
+	c.Assert(err, IsNil)
+	c.Check(taskKinds(ts.Tasks()), testutil.Contains, "link-snap-components")
+	chg := s.state.NewChange("revert", "revert a snap backwards")
+	chg.AddAll(ts)
+
+	defer s.se.Stop()
+	s.settle(c)
+
+	c.Assert(chg.Err(), IsNil)
+	// the component revision used by the reverted to revision is used
+	link := s.fakeBackend.ops.MustFindOp(c, "link-component")
+	c.Check(link.snapRev, Equals, snap.R(2))
+	c.Check(link.revno, Equals, snap.R(1))
+
+	var snapst snapstate.SnapState
+	c.Assert(snapstate.Get(s.state, "some-snap", &snapst), IsNil)
+	c.Check(snapst.Current, Equals, snap.R(2))
+	c.Check(snapst.Components, DeepEquals, map[int][]*snap.ComponentSideInfo{
+		2: {oldComp},
+		7: {comp},
+	})
+}
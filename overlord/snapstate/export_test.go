@@ -88,6 +88,12 @@ func MockOpenSnapFile(mock func(path string, si *snap.SideInfo) (*snap.Info, sna
 	return func() { openSnapFile = prevOpenSnapFile }
 }
 
+func MockOpenComponentFile(mock func(path string, info *snap.Info, csi *snap.ComponentSideInfo) (*snap.ComponentInfo, snap.Container, error)) (restore func()) {
+	prev := openComponentFile
+	openComponentFile = mock
+	return func() { openComponentFile = prev }
+}
+
 func MockErrtrackerReport(mock func(string, string, string, map[string]string) (string, error)) (restore func()) {
 	prev := errtrackerReport
 	errtrackerReport = mock
@@ -57,6 +57,7 @@ import (
 	apparmor_sandbox "github.com/snapcore/snapd/sandbox/apparmor"
 	"github.com/snapcore/snapd/sandbox/cgroup"
 	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/integrity"
 	"github.com/snapcore/snapd/snap/quota"
 	"github.com/snapcore/snapd/snapdenv"
 	"github.com/snapcore/snapd/snapdtool"
@@ -80,6 +81,10 @@ var SecurityProfilesRemoveLate = func(snapName string, rev snap.Revision, typ sn
 	panic("internal error: snapstate.SecurityProfilesRemoveLate is unset")
 }
 
+// RemoveSnapPromptingRules allows to hook the removal of the prompting rules
+// of a snap into the discarding of its last revision.
+var RemoveSnapPromptingRules func(st *state.State, snapName string) error
+
 var cgroupMonitorSnapEnded = cgroup.MonitorSnapEnded
 
 // TaskSnapSetup returns the SnapSetup with task params hold by or referred to by the task.
@@ -1047,6 +1052,102 @@ func checkKernelHasUpdateAssetsTask(t *state.Task) error {
 	return ErrKernelGadgetUpdateTaskMissing
 }
 
+// SnapRevisionIntegrity allows to hook retrieving the integrity data recorded
+// in the snap-revision assertion of a snap revision, it is set by assertstate.
+var SnapRevisionIntegrity func(st *state.State, snapID string, rev snap.Revision) (*asserts.SnapIntegrity, error)
+
+// assertedSnapIntegrity returns the integrity data recorded in the
+// snap-revision assertion of the given snap revision, and whether the model
+// requires snaps to be mounted with verified integrity data. A missing
+// assertion is an error only if the model requires integrity.
+// The state must be locked by the caller.
+func assertedSnapIntegrity(st *state.State, si *snap.SideInfo, model *asserts.Model) (snapIntegrity *asserts.SnapIntegrity, required bool, err error) {
+	required = model != nil && model.SnapIntegrity() == asserts.SnapIntegrityRequired
+
+	if si.SnapID != "" && SnapRevisionIntegrity != nil {
+		snapIntegrity, err = SnapRevisionIntegrity(st, si.SnapID, si.Revision)
+		if err != nil {
+			return nil, false, err
+		}
+	}
+	if snapIntegrity == nil && required {
+		return nil, false, fmt.Errorf("cannot mount snap %q: model requires integrity data but none are asserted for revision %s", si.RealName, si.Revision)
+	}
+	return snapIntegrity, required, nil
+}
+
+// checkSnapIntegrity looks for integrity data appended to the given snap file
+// and verifies them against the asserted integrity data. It returns the
+// verified integrity data, or nil if the snap is to be mounted without
+// dm-verity. Missing or mismatched integrity data are an error only if
+// required is set.
+// This reads the whole integrity data, the state must not be locked.
+func checkSnapIntegrity(snapPath string, si *snap.SideInfo, snapIntegrity *asserts.SnapIntegrity, required bool) (*integrity.IntegrityData, error) {
+	if snapIntegrity == nil {
+		return nil, nil
+	}
+
+	integrityData, err := integrity.FindIntegrityData(snapPath)
+	if err == nil {
+		err = integrityData.Validate(snapIntegrity)
+	}
+	if err != nil {
+		if required {
+			return nil, fmt.Errorf("cannot mount snap %q: cannot verify integrity data: %v", si.RealName, err)
+		}
+		logger.Noticef("Cannot verify integrity data of snap %q, mounting it without dm-verity: %v", si.RealName, err)
+		return nil, nil
+	}
+	return integrityData, nil
+}
+
+// snapsIntegrity maps snap instance names and revisions to the integrity
+// data verified when mounting them.
+type snapsIntegrity map[string]map[string]*integrity.IntegrityData
+
+// verifiedSnapIntegrity returns the integrity data verified when the given
+// snap revision was mounted, or nil if it was mounted without dm-verity.
+func verifiedSnapIntegrity(st *state.State, instanceName string, rev snap.Revision) (*integrity.IntegrityData, error) {
+	var all snapsIntegrity
+	if err := st.Get("snaps-integrity", &all); err != nil && !errors.Is(err, state.ErrNoState) {
+		return nil, err
+	}
+	return all[instanceName][rev.String()], nil
+}
+
+// setVerifiedSnapIntegrity records the integrity data verified when mounting
+// the given snap revision, so that they can be reused without reading the
+// snap file again. A nil integrityData drops the record.
+func setVerifiedSnapIntegrity(st *state.State, instanceName string, rev snap.Revision, integrityData *integrity.IntegrityData) error {
+	var all snapsIntegrity
+	if err := st.Get("snaps-integrity", &all); err != nil && !errors.Is(err, state.ErrNoState) {
+		return err
+	}
+	if integrityData == nil {
+		if all[instanceName][rev.String()] == nil {
+			return nil
+		}
+		delete(all[instanceName], rev.String())
+		if len(all[instanceName]) == 0 {
+			delete(all, instanceName)
+		}
+	} else {
+		if all == nil {
+			all = make(snapsIntegrity)
+		}
+		if all[instanceName] == nil {
+			all[instanceName] = make(map[string]*integrity.IntegrityData)
+		}
+		all[instanceName][rev.String()] = integrityData
+	}
+	if len(all) == 0 {
+		st.Set("snaps-integrity", nil)
+	} else {
+		st.Set("snaps-integrity", all)
+	}
+	return nil
+}
+
 func (m *SnapManager) doMountSnap(t *state.Task, _ *tomb.Tomb) error {
 	st := t.State()
 	st.Lock()
@@ -1106,8 +1207,23 @@ func (m *SnapManager) doMountSnap(t *state.Task, _ *tomb.Tomb) error {
 
 	}
 
+	st.Lock()
+	snapIntegrity, integrityRequired, err := assertedSnapIntegrity(st, snapsup.SideInfo, deviceCtx.Model())
+	st.Unlock()
+	if err != nil {
+		return err
+	}
+	var integrityData *integrity.IntegrityData
+	timings.Run(perfTimings, "check-snap-integrity", fmt.Sprintf("check integrity of snap %q", snapsup.InstanceName()), func(timings.Measurer) {
+		integrityData, err = checkSnapIntegrity(snapsup.SnapPath, snapsup.SideInfo, snapIntegrity, integrityRequired)
+	})
+	if err != nil {
+		return err
+	}
+
 	setupOpts := &backend.SetupSnapOptions{
 		SkipKernelExtraction: snapsup.SkipKernelExtraction,
+		Integrity:            integrityData,
 	}
 	pb := NewTaskProgressAdapterUnlocked(t)
 	// TODO Use snapsup.Revision() to obtain the right info to mount
@@ -1161,7 +1277,12 @@ func (m *SnapManager) doMountSnap(t *state.Task, _ *tomb.Tomb) error {
 	if installRecord != nil {
 		t.Set("install-record", installRecord)
 	}
+	// keep the verified integrity data for when mount units are regenerated
+	err = setVerifiedSnapIntegrity(st, snapsup.InstanceName(), snapsup.Revision(), integrityData)
 	st.Unlock()
+	if err != nil {
+		return err
+	}
 
 	if snapsup.Flags.RemoveSnapPath {
 		if err := os.Remove(snapsup.SnapPath); err != nil {
@@ -1220,6 +1341,10 @@ func (m *SnapManager) undoMountSnap(t *state.Task, _ *tomb.Tomb) error {
 	st.Lock()
 	defer st.Unlock()
 
+	if err := setVerifiedSnapIntegrity(st, snapsup.InstanceName(), snapsup.Revision(), nil); err != nil {
+		return err
+	}
+
 	otherInstances, err := hasOtherInstances(st, snapsup.InstanceName())
 	if err != nil {
 		return err
@@ -3225,11 +3350,18 @@ func (m *SnapManager) doDiscardSnap(t *state.Task, _ *tomb.Tomb) error {
 	if err != nil {
 		return err
 	}
+	if err := m.discardComponentsForRevision(snapst, snapsup.InstanceName(), snapsup.Revision(), pb); err != nil {
+		t.Errorf("cannot remove components of snap %q, will retry in 3 mins: %s", snapsup.InstanceName(), err)
+		return &state.Retry{After: 3 * time.Minute}
+	}
 	err = m.backend.RemoveSnapFiles(snapsup.placeInfo(), typ, nil, deviceCtx, pb)
 	if err != nil {
 		t.Errorf("cannot remove snap file %q, will retry in 3 mins: %s", snapsup.InstanceName(), err)
 		return &state.Retry{After: 3 * time.Minute}
 	}
+	if err := setVerifiedSnapIntegrity(st, snapsup.InstanceName(), snapsup.Revision(), nil); err != nil {
+		return err
+	}
 	if len(snapst.Sequence) == 0 {
 		if err = m.backend.RemoveSnapMountUnits(snapsup.placeInfo(), nil); err != nil {
 			return err
@@ -3281,6 +3413,14 @@ func (m *SnapManager) doDiscardSnap(t *state.Task, _ *tomb.Tomb) error {
 		if err := EnsureSnapAbsentFromQuotaGroup(st, snapsup.InstanceName()); err != nil {
 			return err
 		}
+
+		// remove the prompting rules of the snap, otherwise they
+		// would apply to a snap installed later with the same name
+		if RemoveSnapPromptingRules != nil {
+			if err := RemoveSnapPromptingRules(st, snapsup.InstanceName()); err != nil {
+				return err
+			}
+		}
 	}
 	if err = config.DiscardRevisionConfig(st, snapsup.InstanceName(), snapsup.Revision()); err != nil {
 		return err
@@ -0,0 +1,409 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"gopkg.in/tomb.v2"
+
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/snapstate/backend"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/progress"
+	"github.com/snapcore/snapd/snap"
+)
+
+// TaskComponentSetup returns the ComponentSetup and SnapSetup with task
+// params hold by or referred to by the task.
+func TaskComponentSetup(t *state.Task) (*ComponentSetup, *SnapSetup, error) {
+	snapsup, err := TaskSnapSetup(t)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var compsup ComponentSetup
+	err = t.Get("component-setup", &compsup)
+	if err != nil && !errors.Is(err, state.ErrNoState) {
+		return nil, nil, err
+	}
+	if err == nil {
+		return &compsup, snapsup, nil
+	}
+
+	var id string
+	err = t.Get("component-setup-task", &id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ts := t.State().Task(id)
+	if ts == nil {
+		return nil, nil, fmt.Errorf("internal error: tasks are being pruned")
+	}
+	if err := ts.Get("component-setup", &compsup); err != nil {
+		return nil, nil, err
+	}
+	return &compsup, snapsup, nil
+}
+
+func compSetupAndState(t *state.Task) (*ComponentSetup, *SnapSetup, *SnapState, error) {
+	compsup, snapsup, err := TaskComponentSetup(t)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	var snapst SnapState
+	err = Get(t.State(), snapsup.InstanceName(), &snapst)
+	if err != nil && !errors.Is(err, state.ErrNoState) {
+		return nil, nil, nil, err
+	}
+	return compsup, snapsup, &snapst, nil
+}
+
+func (m *SnapManager) doPrepareComponent(t *state.Task, _ *tomb.Tomb) error {
+	st := t.State()
+	st.Lock()
+	defer st.Unlock()
+
+	compsup, _, snapst, err := compSetupAndState(t)
+	if err != nil {
+		return err
+	}
+
+	if compsup.Revision().Unset() {
+		// Assign local revision for the component, we assign
+		// the next local revision across all snap revisions
+		compsup.CompSideInfo.Revision = snapst.localComponentRevision(compsup.ComponentName())
+		compsup.CompSideInfo.Revision.N--
+		if !compsup.Revision().Local() {
+			panic("internal error: invalid local revision built: " + compsup.Revision().String())
+		}
+		t.Set("component-setup", compsup)
+	}
+
+	return nil
+}
+
+func (m *SnapManager) doMountComponent(t *state.Task, _ *tomb.Tomb) error {
+	st := t.State()
+	st.Lock()
+	compsup, snapsup, err := TaskComponentSetup(t)
+	if err != nil {
+		st.Unlock()
+		return err
+	}
+	deviceCtx, err := DeviceCtx(st, t, nil)
+	st.Unlock()
+	if err != nil {
+		return err
+	}
+
+	pb := NewTaskProgressAdapterUnlocked(t)
+	installRecord, err := m.backend.SetupComponent(compsup.CompPath, snapsup.InstanceName(), compsup.CompSideInfo, deviceCtx, pb)
+	if err != nil {
+		return err
+	}
+
+	st.Lock()
+	if installRecord != nil {
+		t.Set("install-record", installRecord)
+	}
+	st.Unlock()
+
+	if snapsup.Flags.RemoveSnapPath {
+		if err := os.Remove(compsup.CompPath); err != nil {
+			logger.Noticef("Failed to cleanup %s: %s", compsup.CompPath, err)
+		}
+	}
+
+	return nil
+}
+
+func (m *SnapManager) undoMountComponent(t *state.Task, _ *tomb.Tomb) error {
+	st := t.State()
+	st.Lock()
+	compsup, snapsup, err := TaskComponentSetup(t)
+	if err != nil {
+		st.Unlock()
+		return err
+	}
+	var installRecord backend.InstallRecord
+	err = t.Get("install-record", &installRecord)
+	st.Unlock()
+	if err != nil && !errors.Is(err, state.ErrNoState) {
+		return err
+	}
+
+	pb := NewTaskProgressAdapterUnlocked(t)
+	return m.backend.UndoSetupComponent(snapsup.InstanceName(), compsup.CompSideInfo, &installRecord, pb)
+}
+
+func (m *SnapManager) doLinkComponent(t *state.Task, _ *tomb.Tomb) error {
+	st := t.State()
+	st.Lock()
+	defer st.Unlock()
+
+	compsup, snapsup, snapst, err := compSetupAndState(t)
+	if err != nil {
+		return err
+	}
+	if !snapst.IsInstalled() {
+		return &snap.NotInstalledError{Snap: snapsup.InstanceName()}
+	}
+
+	snapRev := snapsup.Revision()
+	if old := snapst.componentSideInfo(snapRev, compsup.ComponentName()); old != nil {
+		// keep the replaced revision around for undo
+		t.Set("old-component-side-info", old)
+	}
+
+	if err := m.backend.LinkComponent(snapsup.InstanceName(), snapRev, compsup.CompSideInfo); err != nil {
+		return err
+	}
+
+	snapst.setComponentSideInfo(snapRev, compsup.CompSideInfo)
+	Set(st, snapsup.InstanceName(), snapst)
+
+	return nil
+}
+
+func (m *SnapManager) undoLinkComponent(t *state.Task, _ *tomb.Tomb) error {
+	st := t.State()
+	st.Lock()
+	defer st.Unlock()
+
+	compsup, snapsup, snapst, err := compSetupAndState(t)
+	if err != nil {
+		return err
+	}
+
+	var old snap.ComponentSideInfo
+	err = t.Get("old-component-side-info", &old)
+	if err != nil && !errors.Is(err, state.ErrNoState) {
+		return err
+	}
+	hadOld := err == nil
+
+	snapRev := snapsup.Revision()
+	if hadOld {
+		if err := m.backend.LinkComponent(snapsup.InstanceName(), snapRev, &old); err != nil {
+			return err
+		}
+		snapst.setComponentSideInfo(snapRev, &old)
+	} else {
+		if err := m.backend.UnlinkComponent(snapsup.InstanceName(), snapRev, compsup.ComponentName()); err != nil {
+			return err
+		}
+		snapst.removeComponentSideInfo(snapRev, compsup.ComponentName())
+	}
+	Set(st, snapsup.InstanceName(), snapst)
+
+	return nil
+}
+
+func (m *SnapManager) doUnlinkComponent(t *state.Task, _ *tomb.Tomb) error {
+	st := t.State()
+	st.Lock()
+	defer st.Unlock()
+
+	compsup, snapsup, snapst, err := compSetupAndState(t)
+	if err != nil {
+		return err
+	}
+
+	snapRev := snapsup.Revision()
+	if err := m.backend.UnlinkComponent(snapsup.InstanceName(), snapRev, compsup.ComponentName()); err != nil {
+		return err
+	}
+
+	snapst.removeComponentSideInfo(snapRev, compsup.ComponentName())
+	Set(st, snapsup.InstanceName(), snapst)
+
+	return nil
+}
+
+func (m *SnapManager) undoUnlinkComponent(t *state.Task, _ *tomb.Tomb) error {
+	st := t.State()
+	st.Lock()
+	defer st.Unlock()
+
+	compsup, snapsup, snapst, err := compSetupAndState(t)
+	if err != nil {
+		return err
+	}
+
+	snapRev := snapsup.Revision()
+	if err := m.backend.LinkComponent(snapsup.InstanceName(), snapRev, compsup.CompSideInfo); err != nil {
+		return err
+	}
+
+	snapst.setComponentSideInfo(snapRev, compsup.CompSideInfo)
+	Set(st, snapsup.InstanceName(), snapst)
+
+	return nil
+}
+
+func (m *SnapManager) doDiscardComponent(t *state.Task, _ *tomb.Tomb) error {
+	st := t.State()
+	st.Lock()
+	defer st.Unlock()
+
+	compsup, snapsup, snapst, err := compSetupAndState(t)
+	if err != nil {
+		return err
+	}
+
+	if snapst.isComponentInUse(compsup.CompSideInfo) {
+		// still used by another revision of the snap
+		return nil
+	}
+
+	pb := NewTaskProgressAdapterLocked(t)
+	if err := m.backend.RemoveComponentFiles(snapsup.InstanceName(), compsup.CompSideInfo, nil, pb); err != nil {
+		t.Errorf("cannot remove component %q, will retry in 3 mins: %s", compsup.CompSideInfo.Component, err)
+		return &state.Retry{After: 3 * time.Minute}
+	}
+
+	return nil
+}
+
+// discardComponentsForRevision unlinks and removes the components used by
+// the given snap revision, the files of a component revision are kept if
+// it is used by another revision of the snap.
+func (m *SnapManager) discardComponentsForRevision(snapst *SnapState, instanceName string, snapRev snap.Revision, meter progress.Meter) error {
+	comps := snapst.Components[snapRev.N]
+	delete(snapst.Components, snapRev.N)
+	if len(snapst.Components) == 0 {
+		snapst.Components = nil
+	}
+	for _, csi := range comps {
+		if err := m.backend.UnlinkComponent(instanceName, snapRev, csi.Component.ComponentName); err != nil {
+			return err
+		}
+		if snapst.isComponentInUse(csi) {
+			continue
+		}
+		if err := m.backend.RemoveComponentFiles(instanceName, csi, nil, meter); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// doLinkSnapComponents makes components available to the snap revision
+// being refreshed or reverted to. Components are tied to snap revisions:
+// a revision the snap had before (e.g. on revert) gets back the components
+// recorded for it, while a new revision inherits the components of the
+// current revision that it still declares with the same type.
+func (m *SnapManager) doLinkSnapComponents(t *state.Task, _ *tomb.Tomb) error {
+	st := t.State()
+	st.Lock()
+	defer st.Unlock()
+
+	snapsup, snapst, err := snapSetupAndState(t)
+	if err != nil {
+		return err
+	}
+
+	instanceName := snapsup.InstanceName()
+	newRev := snapsup.Revision()
+	if snapst.LastIndex(newRev) >= 0 {
+		// make sure the components recorded for the revision are
+		// still linked to it
+		for _, csi := range snapst.Components[newRev.N] {
+			if err := m.backend.LinkComponent(instanceName, newRev, csi); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	curInfo, err := snapst.CurrentInfo()
+	if err != nil {
+		return err
+	}
+	newInfo, err := readInfo(instanceName, snapsup.SideInfo, errorOnBroken)
+	if err != nil {
+		return err
+	}
+
+	var linked []*snap.ComponentSideInfo
+	for _, csi := range snapst.Components[snapst.Current.N] {
+		compName := csi.Component.ComponentName
+		comp := newInfo.Components[compName]
+		if comp == nil {
+			logger.Noticef("Component %q is not declared by revision %s of snap %q, dropping it", csi.Component, newRev, instanceName)
+			continue
+		}
+		if oldComp := curInfo.Components[compName]; oldComp != nil && oldComp.Type != comp.Type {
+			logger.Noticef("Component %q changed type in revision %s of snap %q, dropping it", csi.Component, newRev, instanceName)
+			continue
+		}
+		if err := m.backend.LinkComponent(instanceName, newRev, csi); err != nil {
+			return err
+		}
+		snapst.setComponentSideInfo(newRev, csi)
+		linked = append(linked, csi)
+	}
+	if len(linked) == 0 {
+		return nil
+	}
+
+	// keep the linked components around for undo
+	t.Set("linked-components", linked)
+	Set(st, instanceName, snapst)
+
+	return nil
+}
+
+func (m *SnapManager) undoLinkSnapComponents(t *state.Task, _ *tomb.Tomb) error {
+	st := t.State()
+	st.Lock()
+	defer st.Unlock()
+
+	snapsup, snapst, err := snapSetupAndState(t)
+	if err != nil {
+		return err
+	}
+
+	var linked []*snap.ComponentSideInfo
+	err = t.Get("linked-components", &linked)
+	if errors.Is(err, state.ErrNoState) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	newRev := snapsup.Revision()
+	for _, csi := range linked {
+		compName := csi.Component.ComponentName
+		if err := m.backend.UnlinkComponent(snapsup.InstanceName(), newRev, compName); err != nil {
+			return err
+		}
+		snapst.removeComponentSideInfo(newRev, compName)
+	}
+	Set(st, snapsup.InstanceName(), snapst)
+
+	return nil
+}
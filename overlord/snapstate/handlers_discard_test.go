@@ -135,6 +135,58 @@ func (s *discardSnapSuite) TestDoDiscardSnapInQuotaGroup(c *C) {
 	c.Check(t.Status(), Equals, state.DoneStatus)
 }
 
+func (s *discardSnapSuite) TestDoDiscardSnapRemovesPromptingRules(c *C) {
+	s.state.Lock()
+
+	old := snapstate.RemoveSnapPromptingRules
+	defer func() {
+		snapstate.RemoveSnapPromptingRules = old
+	}()
+
+	var removed []string
+	snapstate.RemoveSnapPromptingRules = func(st *state.State, snap string) error {
+		removed = append(removed, snap)
+		return nil
+	}
+
+	snapstate.Set(s.state, "foo", &snapstate.SnapState{
+		Sequence: []*snap.SideInfo{
+			{RealName: "foo", Revision: snap.R(3)},
+			{RealName: "foo", Revision: snap.R(33)},
+		},
+		Current:  snap.R(3),
+		SnapType: "app",
+	})
+	chg := s.state.NewChange("sample", "...")
+	for _, rev := range []snap.Revision{snap.R(33), snap.R(3)} {
+		t := s.state.NewTask("discard-snap", "test")
+		t.Set("snap-setup", &snapstate.SnapSetup{
+			SideInfo: &snap.SideInfo{
+				RealName: "foo",
+				Revision: rev,
+			},
+		})
+		if tasks := chg.Tasks(); len(tasks) > 0 {
+			t.WaitFor(tasks[0])
+		}
+		chg.AddTask(t)
+	}
+
+	s.state.Unlock()
+
+	for i := 0; i < 2; i++ {
+		s.se.Ensure()
+		s.se.Wait()
+	}
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	c.Check(chg.Status(), Equals, state.DoneStatus)
+	// the rules are only removed with the last revision
+	c.Check(removed, DeepEquals, []string{"foo"})
+}
+
 func (s *discardSnapSuite) TestDoDiscardSnapToEmpty(c *C) {
 	s.state.Lock()
 	snapstate.Set(s.state, "foo", &snapstate.SnapState{
@@ -20,17 +20,25 @@
 package snapstate_test
 
 import (
+	"encoding/binary"
+	"io/ioutil"
 	"path/filepath"
+	"strings"
 	"time"
 
 	. "gopkg.in/check.v1"
 
+	"github.com/snapcore/snapd/asserts"
 	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/snapstate/snapstatetest"
+	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/integrity"
+	"github.com/snapcore/snapd/snap/integrity/dmverity"
 	"github.com/snapcore/snapd/snap/snaptest"
+	"github.com/snapcore/snapd/testutil"
 )
 
 type mountSnapSuite struct {
@@ -496,3 +504,142 @@ func (s *mountSnapSuite) TestDoMountNotMountedRetryRetry(c *C) {
 		},
 	})
 }
+
+// mockSnapWithIntegrityData writes a snap file made of a fake squashfs image
+// followed by integrity data and makes it openable by checkSnap.
+func (s *mountSnapSuite) mockSnapWithIntegrityData(c *C) (snapPath string, integrityData *integrity.IntegrityData) {
+	image := make([]byte, 4096)
+	copy(image, "hsqs")
+	binary.LittleEndian.PutUint64(image[40:], 100)
+
+	header, err := integrity.IntegrityDataHeader{
+		Type:     "integrity",
+		Size:     2 * 4096,
+		DmVerity: dmverity.Info{RootHash: strings.Repeat("a", 64)},
+	}.Encode()
+	c.Assert(err, IsNil)
+
+	content := append(append(image, header...), make([]byte, 4096)...)
+	snapPath = filepath.Join(c.MkDir(), "foo_33.snap")
+	c.Assert(ioutil.WriteFile(snapPath, content, 0644), IsNil)
+
+	integrityData, err = integrity.FindIntegrityData(snapPath)
+	c.Assert(err, IsNil)
+
+	s.AddCleanup(snapstate.MockOpenSnapFile(func(path string, si *snap.SideInfo) (*snap.Info, snap.Container, error) {
+		return snaptest.MockInfo(c, "name: foo\nversion: 1.0\n", si), snaptest.MockContainer(c, nil), nil
+	}))
+	return snapPath, integrityData
+}
+
+func (s *mountSnapSuite) mockSnapRevisionIntegrity(c *C, snapIntegrity *asserts.SnapIntegrity) {
+	snapstate.SnapRevisionIntegrity = func(st *state.State, snapID string, rev snap.Revision) (*asserts.SnapIntegrity, error) {
+		c.Check(snapID, Equals, "foo-id")
+		c.Check(rev, Equals, snap.R(33))
+		return snapIntegrity, nil
+	}
+	s.AddCleanup(func() { snapstate.SnapRevisionIntegrity = nil })
+}
+
+func (s *mountSnapSuite) runMountSnap(c *C, snapPath string) *state.Task {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	t := s.state.NewTask("mount-snap", "test")
+	t.Set("snap-setup", &snapstate.SnapSetup{
+		SideInfo: &snap.SideInfo{
+			RealName: "foo",
+			SnapID:   "foo-id",
+			Revision: snap.R(33),
+		},
+		SnapPath: snapPath,
+	})
+	s.state.NewChange("sample", "...").AddTask(t)
+
+	s.state.Unlock()
+	s.se.Ensure()
+	s.se.Wait()
+	s.state.Lock()
+
+	return t
+}
+
+func (s *mountSnapSuite) TestDoMountSnapWithIntegrity(c *C) {
+	snapPath, integrityData := s.mockSnapWithIntegrityData(c)
+	s.mockSnapRevisionIntegrity(c, &asserts.SnapIntegrity{
+		SHA3_384: integrityData.SHA3_384,
+		Size:     integrityData.Header.Size,
+	})
+
+	t := s.runMountSnap(c, snapPath)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Assert(t.Status(), Equals, state.DoneStatus)
+	op := s.fakeBackend.ops.MustFindOp(c, "setup-snap")
+	c.Check(op.integrityData, DeepEquals, integrityData)
+	c.Check(op.integrityData.HashOffset(), Equals, uint64(8192))
+
+	// the verified integrity data are kept for regenerating the mount unit
+	var recorded map[string]map[string]*integrity.IntegrityData
+	c.Assert(s.state.Get("snaps-integrity", &recorded), IsNil)
+	c.Check(recorded, DeepEquals, map[string]map[string]*integrity.IntegrityData{
+		"foo": {"33": integrityData},
+	})
+}
+
+func (s *mountSnapSuite) TestDoMountSnapIntegrityMismatchNotRequired(c *C) {
+	snapPath, integrityData := s.mockSnapWithIntegrityData(c)
+	s.mockSnapRevisionIntegrity(c, &asserts.SnapIntegrity{
+		SHA3_384: "other-digest",
+		Size:     integrityData.Header.Size,
+	})
+
+	t := s.runMountSnap(c, snapPath)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	// the snap is mounted without dm-verity
+	c.Assert(t.Status(), Equals, state.DoneStatus)
+	op := s.fakeBackend.ops.MustFindOp(c, "setup-snap")
+	c.Check(op.integrityData, IsNil)
+	var recorded map[string]map[string]*integrity.IntegrityData
+	c.Check(s.state.Get("snaps-integrity", &recorded), testutil.ErrorIs, state.ErrNoState)
+}
+
+func (s *mountSnapSuite) TestDoMountSnapIntegrityRequiredMismatch(c *C) {
+	s.AddCleanup(snapstatetest.MockDeviceModel(MakeModel20("pc", map[string]interface{}{
+		"snap-integrity": "required",
+	})))
+
+	snapPath, integrityData := s.mockSnapWithIntegrityData(c)
+	s.mockSnapRevisionIntegrity(c, &asserts.SnapIntegrity{
+		SHA3_384: "other-digest",
+		Size:     integrityData.Header.Size,
+	})
+
+	t := s.runMountSnap(c, snapPath)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Assert(t.Status(), Equals, state.ErrorStatus)
+	c.Check(t.Change().Err(), ErrorMatches, `(?s).*cannot mount snap "foo": cannot verify integrity data: integrity data digest does not match the expected digest.*`)
+	c.Check(s.fakeBackend.ops.Ops(), Not(testutil.Contains), "setup-snap")
+}
+
+func (s *mountSnapSuite) TestDoMountSnapIntegrityRequiredMissing(c *C) {
+	s.AddCleanup(snapstatetest.MockDeviceModel(MakeModel20("pc", map[string]interface{}{
+		"snap-integrity": "required",
+	})))
+
+	snapPath, _ := s.mockSnapWithIntegrityData(c)
+	s.mockSnapRevisionIntegrity(c, nil)
+
+	t := s.runMountSnap(c, snapPath)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Assert(t.Status(), Equals, state.ErrorStatus)
+	c.Check(t.Change().Err(), ErrorMatches, `(?s).*cannot mount snap "foo": model requires integrity data but none are asserted for revision 33.*`)
+	c.Check(s.fakeBackend.ops.Ops(), Not(testutil.Contains), "setup-snap")
+}
@@ -97,10 +97,38 @@ func (r *refreshHints) refresh() error {
 	if err != nil {
 		return fmt.Errorf("internal error: cannot get refresh-candidates: %v", err)
 	}
+	if err := addSnapPublishedNotices(r.state, hints); err != nil {
+		return err
+	}
 	r.state.Set("refresh-candidates", hints)
 	return nil
 }
 
+// addSnapPublishedNotices records a snap-published notice for every refresh
+// candidate whose revision was not already known from the previous hints.
+func addSnapPublishedNotices(st *state.State, hints map[string]*refreshCandidate) error {
+	var oldHints map[string]*refreshCandidate
+	if err := st.Get("refresh-candidates", &oldHints); err != nil && !errors.Is(err, state.ErrNoState) {
+		return err
+	}
+	for instanceName, hint := range hints {
+		if old := oldHints[instanceName]; old != nil && old.Revision() == hint.Revision() {
+			continue
+		}
+		opts := &state.AddNoticeOptions{
+			Data: map[string]string{
+				"revision": hint.Revision().String(),
+				"version":  hint.Version,
+				"channel":  hint.Channel,
+			},
+		}
+		if _, err := st.AddNotice(nil, state.SnapPublishedNotice, instanceName, opts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // AtSeed configures hints refresh policies at end of seeding.
 func (r *refreshHints) AtSeed() error {
 	// on classic hold hints refreshes for a full 24h
@@ -331,6 +331,56 @@ func (s *refreshHintsTestSuite) TestRefreshHintsStoresRefreshCandidates(c *C) {
 	c.Check(snapst, DeepEquals, &snapst2)
 }
 
+func (s *refreshHintsTestSuite) TestRefreshHintsAddsSnapPublishedNotices(c *C) {
+	s.store.refreshedSnaps = []*snap.Info{{
+		Version:       "2",
+		Architectures: []string{"all"},
+		SnapType:      snap.TypeApp,
+		SideInfo: snap.SideInfo{
+			RealName: "some-snap",
+			Revision: snap.R(7),
+		},
+	}}
+
+	ensureHints := func() []*state.Notice {
+		s.state.Lock()
+		s.state.Set("last-refresh-hints", time.Now().Add(-48*time.Hour))
+		s.state.Unlock()
+
+		rh := snapstate.NewRefreshHints(s.state)
+		c.Assert(rh.Ensure(), IsNil)
+
+		s.state.Lock()
+		defer s.state.Unlock()
+		return s.state.Notices(&state.NoticeFilter{
+			Types: []state.NoticeType{state.SnapPublishedNotice},
+		})
+	}
+
+	notices := ensureHints()
+	c.Assert(notices, HasLen, 1)
+	c.Check(notices[0].Key(), Equals, "some-snap")
+	c.Check(notices[0].Occurrences(), Equals, 1)
+	c.Check(notices[0].LastData(), DeepEquals, map[string]string{
+		"revision": "7",
+		"version":  "2",
+		"channel":  "stable",
+	})
+
+	// the same revision is not reported again
+	notices = ensureHints()
+	c.Assert(notices, HasLen, 1)
+	c.Check(notices[0].Occurrences(), Equals, 1)
+
+	// but a new one is
+	s.store.refreshedSnaps[0].Revision = snap.R(8)
+	s.store.refreshedSnaps[0].Version = "3"
+	notices = ensureHints()
+	c.Assert(notices, HasLen, 1)
+	c.Check(notices[0].Occurrences(), Equals, 2)
+	c.Check(notices[0].LastData()["revision"], Equals, "8")
+}
+
 func (s *refreshHintsTestSuite) TestPruneRefreshCandidates(c *C) {
 	st := s.state
 	st.Lock()
@@ -31,6 +31,7 @@ import (
 
 	"gopkg.in/tomb.v2"
 
+	"github.com/snapcore/snapd/asserts"
 	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/errtracker"
 	"github.com/snapcore/snapd/i18n"
@@ -242,6 +243,10 @@ type SnapState struct {
 	// their security profiles set up but are not active.
 	// It is managed by ifacestate.
 	PendingSecurity *PendingSecurityState `json:"pending-security,omitempty"`
+
+	// Components maps snap revisions to the components installed for
+	// them, see component.go.
+	Components map[int][]*snap.ComponentSideInfo `json:"components,omitempty"`
 }
 
 // PendingSecurityState holds information about snaps that have
@@ -532,6 +537,14 @@ func Manager(st *state.State, runner *state.TaskRunner) (*SnapManager, error) {
 	runner.AddHandler("clear-snap", m.doClearSnapData, nil)
 	runner.AddHandler("discard-snap", m.doDiscardSnap, nil)
 
+	// component install/remove related
+	runner.AddHandler("prepare-component", m.doPrepareComponent, nil)
+	runner.AddHandler("mount-component", m.doMountComponent, m.undoMountComponent)
+	runner.AddHandler("link-component", m.doLinkComponent, m.undoLinkComponent)
+	runner.AddHandler("unlink-component", m.doUnlinkComponent, m.undoUnlinkComponent)
+	runner.AddHandler("discard-component", m.doDiscardComponent, nil)
+	runner.AddHandler("link-snap-components", m.doLinkSnapComponents, m.undoLinkSnapComponents)
+
 	// alias related
 	// FIXME: drop the task entirely after a while
 	runner.AddHandler("clear-aliases", func(*state.Task, *tomb.Tomb) error { return nil }, nil)
@@ -1115,16 +1128,44 @@ func (m *SnapManager) ensureMountsUpdated() error {
 	if len(allStates) != 0 {
 		sysd := getSystemD()
 
+		var model *asserts.Model
+		deviceCtx, err := DeviceCtx(m.state, nil, nil)
+		if err != nil && !errors.Is(err, state.ErrNoState) {
+			return err
+		}
+		if deviceCtx != nil {
+			model = deviceCtx.Model()
+		}
+
 		for _, snapSt := range allStates {
 			info, err := snapSt.CurrentInfo()
 			if err != nil {
 				return err
 			}
+			// reuse the integrity data verified at install time
+			// instead of reading all snap files again
+			integrityData, err := verifiedSnapIntegrity(m.state, info.InstanceName(), info.Revision)
+			if err != nil {
+				return err
+			}
+			if integrityData == nil && model != nil && model.SnapIntegrity() == asserts.SnapIntegrityRequired {
+				logger.Noticef("Cannot regenerate mount unit of snap %q: model requires integrity data but none were verified for revision %s", info.InstanceName(), info.Revision)
+				continue
+			}
 			squashfsPath := dirs.StripRootDir(info.MountFile())
 			whereDir := dirs.StripRootDir(info.MountDir())
-			if _, err = sysd.EnsureMountUnitFile(info.InstanceName(), info.Revision.String(), squashfsPath, whereDir, "squashfs"); err != nil {
+			dmVerity := backend.DmVerityOptions(info, integrityData)
+			if _, err = sysd.EnsureMountUnitFile(info.InstanceName(), info.Revision.String(), squashfsPath, whereDir, "squashfs", dmVerity); err != nil {
 				return err
 			}
+			for _, csi := range snapSt.Components[info.Revision.N] {
+				compName := csi.Component.ComponentName
+				squashfsPath := dirs.StripRootDir(snap.ComponentMountFile(info.InstanceName(), compName, csi.Revision))
+				whereDir := dirs.StripRootDir(snap.ComponentMountDir(info.InstanceName(), compName, csi.Revision))
+				if _, err = sysd.EnsureMountUnitFile(info.InstanceName()+"+"+compName, csi.Revision.String(), squashfsPath, whereDir, "squashfs", nil); err != nil {
+					return err
+				}
+			}
 		}
 	}
 
@@ -550,6 +550,14 @@ func doInstall(st *state.State, snapst *SnapState, snapsup *SnapSetup, flags int
 		prev = copyData
 	}
 
+	// components are tied to snap revisions, make them available to the
+	// revision being refreshed or reverted to
+	if snapst.IsInstalled() && (len(snapst.Components[snapst.Current.N]) > 0 || len(snapst.Components[snapsup.Revision().N]) > 0) {
+		linkComps := st.NewTask("link-snap-components", fmt.Sprintf(i18n.G("Make components of snap %q%s available to the system"), snapsup.InstanceName(), revisionStr))
+		addTask(linkComps)
+		prev = linkComps
+	}
+
 	// security
 	setupSecurity := st.NewTask("setup-profiles", fmt.Sprintf(i18n.G("Setup snap %q%s security profiles"), snapsup.InstanceName(), revisionStr))
 	addTask(setupSecurity)
@@ -1094,6 +1102,23 @@ func ensureInstallPreconditions(st *state.State, info *snap.Info, flags Flags, s
 // local revision and sideloading, or full metadata in which case it
 // the snap will appear as installed from the store.
 func InstallPath(st *state.State, si *snap.SideInfo, path, instanceName, channel string, flags Flags) (*state.TaskSet, *snap.Info, error) {
+	return installPathWithDeviceContext(st, si, path, instanceName, channel, flags, nil, "")
+}
+
+// InstallPathWithDeviceContext returns a set of tasks for installing or
+// refreshing a snap from a file path, using the given deviceCtx for any
+// model related checks.
+// Note that the state must be locked by the caller.
+//
+// The returned TaskSet will contain a LastBeforeLocalModificationsEdge
+// identifying the last task before the first task that introduces system
+// modifications.
+func InstallPathWithDeviceContext(st *state.State, si *snap.SideInfo, path, instanceName, channel string, flags Flags, deviceCtx DeviceContext, fromChange string) (*state.TaskSet, error) {
+	ts, _, err := installPathWithDeviceContext(st, si, path, instanceName, channel, flags, deviceCtx, fromChange)
+	return ts, err
+}
+
+func installPathWithDeviceContext(st *state.State, si *snap.SideInfo, path, instanceName, channel string, flags Flags, deviceCtx DeviceContext, fromChange string) (*state.TaskSet, *snap.Info, error) {
 	if si.RealName == "" {
 		return nil, nil, fmt.Errorf("internal error: snap name to install %q not provided", path)
 	}
@@ -1106,7 +1131,7 @@ func InstallPath(st *state.State, si *snap.SideInfo, path, instanceName, channel
 		instanceName = si.RealName
 	}
 
-	deviceCtx, err := DeviceCtxFromState(st, nil)
+	deviceCtx, err := DeviceCtxFromState(st, deviceCtx)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -1178,7 +1203,7 @@ func InstallPath(st *state.State, si *snap.SideInfo, path, instanceName, channel
 		InstanceKey:        info.InstanceKey,
 	}
 
-	ts, err := doInstall(st, &snapst, snapsup, instFlags, "", inUseFor(deviceCtx))
+	ts, err := doInstall(st, &snapst, snapsup, instFlags, fromChange, inUseFor(deviceCtx))
 	return ts, info, err
 }
 
@@ -3197,6 +3197,50 @@ version: 1.0`)
 	c.Assert(err, ErrorMatches, `cannot switch from gadget track "18" as specified for the \(device\) model to "some-channel"`)
 }
 
+func (s *snapmgrTestSuite) TestInstallPathWithDeviceContext(c *C) {
+	// use the real thing for this one
+	snapstate.MockOpenSnapFile(backend.OpenSnapFile)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	r := snapstatetest.MockDeviceModel(ModelWithKernelTrack("18"))
+	defer r()
+	snapstate.Set(s.state, "kernel", &snapstate.SnapState{
+		Sequence: []*snap.SideInfo{
+			{RealName: "kernel", Revision: snap.R(11)},
+		},
+		TrackingChannel: "18/stable",
+		Current:         snap.R(11),
+		Active:          true,
+	})
+
+	someSnap := makeTestSnap(c, `name: kernel
+version: 1.0`)
+	si := &snap.SideInfo{
+		RealName: "kernel",
+		SnapID:   "kernel-id",
+		Revision: snap.R(42),
+	}
+	// the track is checked against the model from the device context
+	deviceCtx := &snapstatetest.TrivialDeviceContext{
+		DeviceModel: ModelWithKernelTrack("20"),
+		Remodeling:  true,
+	}
+	ts, err := snapstate.InstallPathWithDeviceContext(s.state, si, someSnap, "", "20/stable", snapstate.Flags{Required: true}, deviceCtx, "")
+	c.Assert(err, IsNil)
+	c.Check(ts.MaybeEdge(snapstate.LastBeforeLocalModificationsEdge), NotNil)
+
+	snapsup, err := snapstate.TaskSnapSetup(ts.Tasks()[0])
+	c.Assert(err, IsNil)
+	c.Check(snapsup.SnapPath, Equals, someSnap)
+	c.Check(snapsup.Channel, Equals, "20/stable")
+	c.Check(snapsup.Revision(), Equals, snap.R(42))
+
+	_, err = snapstate.InstallPathWithDeviceContext(s.state, si, someSnap, "", "18/stable", snapstate.Flags{Required: true}, deviceCtx, "")
+	c.Assert(err, ErrorMatches, `cannot switch from kernel track "20" as specified for the \(device\) model to "18/stable"`)
+}
+
 func (s *snapmgrTestSuite) TestInstallLayoutsChecksFeatureFlag(c *C) {
 	s.state.Lock()
 	defer s.state.Unlock()
@@ -63,6 +63,8 @@ import (
 	"github.com/snapcore/snapd/release"
 	"github.com/snapcore/snapd/sandbox"
 	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/integrity"
+	"github.com/snapcore/snapd/snap/integrity/dmverity"
 	"github.com/snapcore/snapd/snap/naming"
 	"github.com/snapcore/snapd/snap/snaptest"
 	"github.com/snapcore/snapd/snapdenv"
@@ -8968,3 +8970,94 @@ WantedBy=multi-user.target
 
 	c.Assert(mountFile, testutil.FileEquals, expectedContent)
 }
+
+func (s *snapmgrTestSuite) TestEnsureSnapStateRewriteMountsComponents(c *C) {
+	testSnapSideInfo := &snap.SideInfo{RealName: "test-snap", Revision: snap.R(42)}
+	csi := snap.NewComponentSideInfo(naming.NewComponentRef("test-snap", "comp"), snap.R(7))
+	testSnapState := &snapstate.SnapState{
+		Sequence:   []*snap.SideInfo{testSnapSideInfo},
+		Current:    snap.R(42),
+		Active:     true,
+		SnapType:   "app",
+		Components: map[int][]*snap.ComponentSideInfo{42: {csi}},
+	}
+	testYaml := `name: test-snap
+version: v1
+components:
+  comp:
+    type: test
+`
+
+	s.state.Lock()
+	snapstate.Set(s.state, "test-snap", testSnapState)
+	snaptest.MockSnapCurrent(c, testYaml, testSnapSideInfo)
+	s.state.Unlock()
+
+	restore := snapstate.MockEnsuredMountsUpdated(s.snapmgr, false)
+	defer restore()
+
+	unitName := systemd.EscapeUnitNamePath("/snap/test-snap/components/mnt/comp/7.mount")
+	s.reloadOrRestarts[unitName] = 0
+
+	err := s.snapmgr.Ensure()
+	c.Assert(err, IsNil)
+
+	c.Check(s.reloadOrRestarts[unitName], Equals, 1)
+	mountFile := filepath.Join(dirs.SnapServicesDir, unitName)
+	c.Check(mountFile, testutil.FileContains, "Description=Mount unit for test-snap+comp, revision 7\n")
+	c.Check(mountFile, testutil.FileContains, "What=/var/lib/snapd/snaps/test-snap+comp_7.comp\n")
+	c.Check(mountFile, testutil.FileContains, "Where=/snap/test-snap/components/mnt/comp/7\n")
+}
+
+func (s *snapmgrTestSuite) TestEnsureSnapStateRewriteMountsIntegrityRequired(c *C) {
+	s.AddCleanup(snapstatetest.MockDeviceModel(MakeModel20("pc", map[string]interface{}{
+		"snap-integrity": "required",
+	})))
+
+	testYaml := `name: %s
+version: v1
+`
+	s.state.Lock()
+	for _, name := range []string{"verified-snap", "unverified-snap"} {
+		si := &snap.SideInfo{RealName: name, Revision: snap.R(42)}
+		snapstate.Set(s.state, name, &snapstate.SnapState{
+			Sequence: []*snap.SideInfo{si},
+			Current:  snap.R(42),
+			Active:   true,
+			SnapType: "app",
+		})
+		snaptest.MockSnapCurrent(c, fmt.Sprintf(testYaml, name), si)
+	}
+	// only the first snap was verified when mounted
+	s.state.Set("snaps-integrity", map[string]map[string]*integrity.IntegrityData{
+		"verified-snap": {
+			"42": {
+				Header: integrity.IntegrityDataHeader{
+					Type:     "integrity",
+					Size:     8192,
+					DmVerity: dmverity.Info{RootHash: "abcd"},
+				},
+				Offset: 4096,
+			},
+		},
+	})
+	s.state.Unlock()
+
+	restore := snapstate.MockEnsuredMountsUpdated(s.snapmgr, false)
+	defer restore()
+
+	err := s.snapmgr.Ensure()
+	c.Assert(err, IsNil)
+
+	verifiedUnit := filepath.Join(dirs.SnapServicesDir, systemd.EscapeUnitNamePath("/snap/verified-snap/42.mount"))
+	c.Check(verifiedUnit, testutil.FileContains, "Options=nodev,ro,x-gdu.hide,x-gvfs-hide,verity.roothash=abcd,verity.hashdevice=/var/lib/snapd/snaps/verified-snap_42.snap,verity.hashoffset=8192\n")
+	// the unit of the snap without verified integrity data is left alone
+	unverifiedUnit := filepath.Join(dirs.SnapServicesDir, systemd.EscapeUnitNamePath("/snap/unverified-snap/42.mount"))
+	c.Check(unverifiedUnit, testutil.FileAbsent)
+
+	// mount units are not regenerated again
+	c.Check(os.Remove(verifiedUnit), IsNil)
+	err = s.snapmgr.Ensure()
+	c.Assert(err, IsNil)
+	c.Check(verifiedUnit, testutil.FileAbsent)
+}
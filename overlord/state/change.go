@@ -21,6 +21,7 @@ package state
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"sort"
@@ -135,6 +136,19 @@ type Change struct {
 	taskIDs []string
 	ready   chan struct{}
 
+	// lastObservedStatus is the status of the change when the last
+	// change-update notice was recorded, it is not persisted
+	lastObservedStatus Status
+
+	// taskStatusCounts counts the tasks of the change in each status, it
+	// is kept up to date as tasks are added or change status so that the
+	// aggregate status is cheap to compute, it is not persisted
+	taskStatusCounts [nStatuses]int
+
+	// updates counts the updates to the change and its tasks, it is
+	// not persisted
+	updates uint64
+
 	spawnTime time.Time
 	readyTime time.Time
 }
@@ -153,6 +167,7 @@ func newChange(state *State, id, kind, summary string) *Change {
 		summary: summary,
 		data:    make(customData),
 		ready:   make(chan struct{}),
+		updates: 1,
 
 		spawnTime: timeNow(),
 	}
@@ -214,6 +229,7 @@ func (c *Change) UnmarshalJSON(data []byte) error {
 	c.data = custData
 	c.taskIDs = unmarshalled.TaskIDs
 	c.ready = make(chan struct{})
+	c.updates = 1
 	c.spawnTime = unmarshalled.SpawnTime
 	if unmarshalled.ReadyTime != nil {
 		c.readyTime = *unmarshalled.ReadyTime
@@ -223,11 +239,79 @@ func (c *Change) UnmarshalJSON(data []byte) error {
 
 // finishUnmarshal is called after the state and tasks are accessible.
 func (c *Change) finishUnmarshal() {
-	if c.Status().Ready() {
+	for _, tid := range c.taskIDs {
+		c.taskStatusCounts[c.state.tasks[tid].effectiveStatus()]++
+	}
+	c.lastObservedStatus = c.Status()
+	if c.lastObservedStatus.Ready() {
 		close(c.ready)
 	}
 }
 
+// addNotice records an occurrence of a change-update notice for this change.
+func (c *Change) addNotice() {
+	opts := &AddNoticeOptions{
+		Data: map[string]string{"kind": c.Kind()},
+	}
+	c.state.addNoticeOrPanic(nil, ChangeUpdateNotice, c.id, opts)
+}
+
+// notifyStatusChange records a change-update notice if the status of the
+// change differs from the one observed when the last notice was recorded.
+func (c *Change) notifyStatusChange() {
+	status := c.Status()
+	if status == c.lastObservedStatus {
+		return
+	}
+	c.lastObservedStatus = status
+	c.addNotice()
+}
+
+// updated is called when the change or any of its tasks is updated, to
+// wake up anyone waiting in WaitUpdate.
+func (c *Change) updated() {
+	c.updates++
+	c.state.changeCond.Broadcast()
+}
+
+// WaitUpdate waits until the change or any of its tasks is updated after
+// the update identified by seen, or the context is cancelled. Updates are
+// status changes, task progress and task log entries. It returns the
+// identifier of the latest update, which can be passed to a following
+// call; passing zero returns immediately.
+//
+// The state lock must be held when calling WaitUpdate; it is released
+// while waiting.
+func (c *Change) WaitUpdate(ctx context.Context, seen uint64) (uint64, error) {
+	c.state.reading()
+
+	if c.updates != seen {
+		return c.updates, nil
+	}
+
+	// wake up the waiter(s) when the context is done
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			c.state.Lock()
+			c.state.changeCond.Broadcast()
+			c.state.unlock()
+		case <-done:
+		}
+	}()
+
+	for c.updates == seen {
+		if ctx.Err() != nil {
+			return seen, ctx.Err()
+		}
+		// this releases the lock while waiting
+		c.state.changeCond.Wait()
+	}
+	return c.updates, nil
+}
+
 // ID returns the individual random key for the change.
 func (c *Change) ID() string {
 	return c.id
@@ -243,10 +327,15 @@ func (c *Change) Summary() string {
 	return c.summary
 }
 
+// writing flags the change as modified.
+func (c *Change) writing() {
+	c.state.writingEntry("changes", c.id)
+}
+
 // Set associates value with key for future consulting by managers.
 // The provided value must properly marshal and unmarshal with encoding/json.
 func (c *Change) Set(key string, value interface{}) {
-	c.state.writing()
+	c.writing()
 	c.data.set(key, value)
 }
 
@@ -296,30 +385,29 @@ func (c *Change) Status() Status {
 		if len(c.taskIDs) == 0 {
 			return HoldStatus
 		}
-		statusStats := make([]int, nStatuses)
-		for _, tid := range c.taskIDs {
-			statusStats[c.state.tasks[tid].Status()]++
-		}
 		for _, s := range statusOrder {
-			if statusStats[s] > 0 {
+			if c.taskStatusCounts[s] > 0 {
 				return s
 			}
 		}
-		panic(fmt.Sprintf("internal error: cannot process change status: %v", statusStats))
+		panic(fmt.Sprintf("internal error: cannot process change status: %v", c.taskStatusCounts))
 	}
 	return c.status
 }
 
 // SetStatus sets the change status, overriding the default behavior (see Status method).
 func (c *Change) SetStatus(s Status) {
-	c.state.writing()
+	c.writing()
 	c.status = s
 	if s.Ready() {
 		c.markReady()
 	}
+	c.notifyStatusChange()
+	c.updated()
 }
 
 func (c *Change) markReady() {
+	c.state.markDirty("changes", c.id)
 	select {
 	case <-c.ready:
 	default:
@@ -338,12 +426,24 @@ func (c *Change) Ready() <-chan struct{} {
 // taskStatusChanged is called by tasks when their status is changed,
 // to give the opportunity for the change to close its ready channel.
 func (c *Change) taskStatusChanged(t *Task, old, new Status) {
+	// tasks report DefaultStatus as DoStatus
+	if old == DefaultStatus {
+		old = DoStatus
+	}
+	if new == DefaultStatus {
+		new = DoStatus
+	}
+	if old != new {
+		c.taskStatusCounts[old]--
+		c.taskStatusCounts[new]++
+		c.notifyStatusChange()
+		c.updated()
+	}
 	if old.Ready() == new.Ready() {
 		return
 	}
-	for _, tid := range c.taskIDs {
-		task := c.state.tasks[tid]
-		if task != t && !task.status.Ready() {
+	for s, n := range c.taskStatusCounts {
+		if n > 0 && !Status(s).Ready() {
 			return
 		}
 	}
@@ -392,6 +492,7 @@ func (c *Change) taskCleanChanged() {
 		}
 	}
 	c.clean = true
+	c.state.markDirty("changes", c.id)
 }
 
 // SpawnTime returns the time when the change was created.
@@ -466,18 +567,20 @@ func (c *Change) State() *State {
 // AddTask registers a task as required for the state change to
 // be accomplished.
 func (c *Change) AddTask(t *Task) {
-	c.state.writing()
+	c.writing()
 	if t.change != "" {
 		panic(fmt.Sprintf("internal error: cannot add one %q task to multiple changes", t.Kind()))
 	}
 	t.change = c.id
+	c.state.markDirty("tasks", t.id)
 	c.taskIDs = addOnce(c.taskIDs, t.ID())
+	c.taskStatusCounts[t.effectiveStatus()]++
 }
 
 // AddAll registers all tasks in the set as required for the state
 // change to be accomplished.
 func (c *Change) AddAll(ts *TaskSet) {
-	c.state.writing()
+	c.writing()
 	for _, t := range ts.tasks {
 		c.AddTask(t)
 	}
@@ -516,7 +619,7 @@ func (c *Change) LaneTasks(lanes ...int) []*Task {
 // Abort flags the change for cancellation, whether in progress or not.
 // Cancellation will proceed at the next ensure pass.
 func (c *Change) Abort() {
-	c.state.writing()
+	c.writing()
 	tasks := make([]*Task, len(c.taskIDs))
 	for i, tid := range c.taskIDs {
 		tasks[i] = c.state.tasks[tid]
@@ -528,14 +631,14 @@ func (c *Change) Abort() {
 // except for tasks that are also in a healthy lane (not aborted, and not waiting
 // on aborted).
 func (c *Change) AbortLanes(lanes []int) {
-	c.state.writing()
+	c.writing()
 	c.abortLanes(lanes, make(map[int]bool), make(map[string]bool))
 }
 
 // AbortUnreadyLanes aborts the tasks from lanes that aren't fully ready, where
 // a ready lane is one in which all tasks are ready.
 func (c *Change) AbortUnreadyLanes() {
-	c.state.writing()
+	c.writing()
 	c.abortUnreadyLanes()
 }
 
@@ -20,6 +20,9 @@
 package state_test
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"sort"
@@ -283,6 +286,34 @@ func (cs *changeSuite) TestStatusDerivedFromTasks(c *C) {
 	}
 }
 
+func (cs *changeSuite) TestStatusDerivedFromTasksAfterReadState(c *C) {
+	st := state.New(nil)
+	st.Lock()
+	chg := st.NewChange("install", "...")
+	t1 := st.NewTask("download", "1")
+	t1.SetStatus(state.DoneStatus)
+	chg.AddTask(t1)
+	t2 := st.NewTask("download", "2")
+	chg.AddTask(t2)
+	c.Check(chg.Status(), Equals, state.DoStatus)
+	buf, err := json.Marshal(st)
+	st.Unlock()
+	c.Assert(err, IsNil)
+
+	st2, err := state.ReadState(nil, bytes.NewReader(buf))
+	c.Assert(err, IsNil)
+	st2.Lock()
+	defer st2.Unlock()
+
+	chg2 := st2.Change(chg.ID())
+	c.Check(chg2.Status(), Equals, state.DoStatus)
+	c.Check(chg2.IsReady(), Equals, false)
+
+	st2.Task(t2.ID()).SetStatus(state.ErrorStatus)
+	c.Check(chg2.Status(), Equals, state.ErrorStatus)
+	c.Check(chg2.IsReady(), Equals, true)
+}
+
 func (cs *changeSuite) TestCloseReadyOnExplicitStatus(c *C) {
 	st := state.New(nil)
 	st.Lock()
@@ -1119,3 +1150,55 @@ func (ts *taskRunnerSuite) TestCheckTaskDependencies(c *C) {
 		}
 	}
 }
+
+func (cs *changeSuite) TestWaitUpdate(c *C) {
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	chg := st.NewChange("install", "...")
+	t := st.NewTask("download", "...")
+	chg.AddTask(t)
+
+	seen, err := chg.WaitUpdate(context.Background(), 0)
+	c.Assert(err, IsNil)
+
+	for _, update := range []func(){
+		func() { t.SetProgress("downloading", 1, 10) },
+		func() { t.Logf("some message") },
+		func() { t.SetStatus(state.DoingStatus) },
+		func() { chg.SetStatus(state.ErrorStatus) },
+	} {
+		go func() {
+			st.Lock()
+			defer st.Unlock()
+			update()
+		}()
+		next, err := chg.WaitUpdate(context.Background(), seen)
+		c.Assert(err, IsNil)
+		c.Check(next > seen, Equals, true)
+		seen = next
+	}
+
+	// updates that happened while not waiting are not missed
+	t.SetProgress("downloading", 2, 10)
+	next, err := chg.WaitUpdate(context.Background(), seen)
+	c.Assert(err, IsNil)
+	c.Check(next > seen, Equals, true)
+	seen = next
+
+	// updates to other changes are not reported
+	other := st.NewChange("other", "...")
+	ot := st.NewTask("other", "...")
+	other.AddTask(ot)
+	go func() {
+		st.Lock()
+		defer st.Unlock()
+		ot.SetProgress("other", 1, 10)
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	next, err = chg.WaitUpdate(ctx, seen)
+	c.Check(err, Equals, context.DeadlineExceeded)
+	c.Check(next, Equals, seen)
+}
@@ -0,0 +1,446 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package state
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"io/ioutil"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/snapcore/snapd/osutil"
+)
+
+// The journaled backend keeps the last full state in the usual state file
+// (the base) and appends the entries modified since the previous
+// checkpoint to a journal file next to it. Entries are tracked per
+// top-level state entry, and per key of the "data", "changes" and "tasks"
+// maps, as they are modified (see State.writingEntry), so that small
+// updates to a large state only cost marshalling and appending those
+// entries. Once the journal grows larger than the base, the full state is
+// written out again as the new base and the journal is reset.
+//
+// The journal starts with a header that identifies the base it applies to,
+// followed by one record per line. Each line is prefixed with the CRC32 of
+// its content, so that a record torn by a power loss is detected and the
+// journal is only replayed up to the last complete record. A journal whose
+// header does not match the base (e.g. because snapd stopped between
+// writing the new base and resetting the journal) is ignored, the base is
+// then the most recent state.
+
+const journalFormat = 1
+
+// nestedStateEntries are the top-level state entries that are journaled
+// per key instead of as a whole.
+var nestedStateEntries = map[string]bool{
+	"data":    true,
+	"changes": true,
+	"tasks":   true,
+}
+
+// journalKey identifies a journaled state entry: a key of one of the
+// nestedStateEntries, or a top-level entry when entry is empty.
+type journalKey struct {
+	entry string
+	key   string
+}
+
+// JournalPath returns the path of the journal for the given state file.
+func JournalPath(statePath string) string {
+	return statePath + ".journal"
+}
+
+type journalHeader struct {
+	Format int    `json:"format"`
+	Base   string `json:"base"`
+}
+
+type journalOp struct {
+	// Entry is the nested top-level state entry the op applies to, or
+	// empty for ops that replace top-level entries.
+	Entry  string          `json:"entry,omitempty"`
+	Key    string          `json:"key"`
+	Value  json.RawMessage `json:"value,omitempty"`
+	Delete bool            `json:"delete,omitempty"`
+}
+
+// stateEntries is the state split into its top-level entries (under the
+// empty name) and the keys of its nested entries.
+type stateEntries map[string]map[string]json.RawMessage
+
+func splitState(data []byte) (stateEntries, error) {
+	var top map[string]json.RawMessage
+	if err := json.Unmarshal(data, &top); err != nil {
+		return nil, err
+	}
+	entries := stateEntries{"": make(map[string]json.RawMessage)}
+	for name := range nestedStateEntries {
+		entries[name] = make(map[string]json.RawMessage)
+	}
+	for name, value := range top {
+		if !nestedStateEntries[name] {
+			entries[""][name] = value
+			continue
+		}
+		nested := entries[name]
+		if err := json.Unmarshal(value, &nested); err != nil {
+			return nil, err
+		}
+	}
+	return entries, nil
+}
+
+func (entries stateEntries) join() ([]byte, error) {
+	top := make(map[string]json.RawMessage, len(entries[""])+len(nestedStateEntries))
+	for name, value := range entries[""] {
+		top[name] = value
+	}
+	for name := range nestedStateEntries {
+		value, err := json.Marshal(entries[name])
+		if err != nil {
+			return nil, err
+		}
+		top[name] = value
+	}
+	return json.Marshal(top)
+}
+
+func (entries stateEntries) apply(ops []journalOp) error {
+	for _, op := range ops {
+		if op.Entry != "" && !nestedStateEntries[op.Entry] {
+			return fmt.Errorf("unknown state entry %q", op.Entry)
+		}
+		if op.Delete {
+			delete(entries[op.Entry], op.Key)
+		} else {
+			entries[op.Entry][op.Key] = op.Value
+		}
+	}
+	return nil
+}
+
+func baseChecksum(data []byte) string {
+	h := sha256.Sum256(data)
+	return hex.EncodeToString(h[:])
+}
+
+// journalLine returns the journal line for the given record content.
+func journalLine(content []byte) []byte {
+	line := make([]byte, 0, len(content)+10)
+	line = append(line, fmt.Sprintf("%08x ", crc32.ChecksumIEEE(content))...)
+	line = append(line, content...)
+	return append(line, '\n')
+}
+
+// parseJournalLine returns the record content of the given journal line
+// (without its newline), or false if the line is torn or corrupted.
+func parseJournalLine(line []byte) ([]byte, bool) {
+	if len(line) < 10 || line[8] != ' ' {
+		return nil, false
+	}
+	var crc uint32
+	if _, err := fmt.Sscanf(string(line[:8]), "%08x", &crc); err != nil {
+		return nil, false
+	}
+	content := line[9:]
+	if crc32.ChecksumIEEE(content) != crc {
+		return nil, false
+	}
+	return content, true
+}
+
+// readStateData returns the state stored at the given path, replaying the
+// journal on top of it if there is one for it. It also returns whether
+// there was a journal.
+func readStateData(path string) (data []byte, journaled bool, err error) {
+	base, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, false, err
+	}
+
+	f, err := os.Open(JournalPath(path))
+	if os.IsNotExist(err) {
+		return base, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 64*1024*1024)
+	if !scanner.Scan() {
+		// empty or unreadable journal, the base is all we have
+		return base, true, scanner.Err()
+	}
+	var header journalHeader
+	content, ok := parseJournalLine(scanner.Bytes())
+	if !ok || json.Unmarshal(content, &header) != nil {
+		return nil, false, fmt.Errorf("cannot read state journal: invalid header")
+	}
+	if header.Format != journalFormat {
+		return nil, false, fmt.Errorf("cannot read state journal: unsupported format %d", header.Format)
+	}
+	if header.Base != baseChecksum(base) {
+		// the journal belongs to a previous base
+		return base, true, nil
+	}
+
+	entries, err := splitState(base)
+	if err != nil {
+		return nil, false, fmt.Errorf("cannot read state: %v", err)
+	}
+	for scanner.Scan() {
+		content, ok := parseJournalLine(scanner.Bytes())
+		if !ok {
+			// torn write, nothing after this was committed
+			break
+		}
+		var ops []journalOp
+		if err := json.Unmarshal(content, &ops); err != nil {
+			return nil, false, fmt.Errorf("cannot read state journal: %v", err)
+		}
+		if err := entries.apply(ops); err != nil {
+			return nil, false, fmt.Errorf("cannot read state journal: %v", err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, false, fmt.Errorf("cannot read state journal: %v", err)
+	}
+	data, err = entries.join()
+	return data, true, err
+}
+
+// ReadStateFile returns the state stored in the given state file, with the
+// journal of the state file replayed on top of it if there is one. Unless
+// the given backend is nil or was created with NewJournaledBackend, a
+// replayed journal is then folded into the state file and removed, as the
+// backend will not maintain it.
+func ReadStateFile(backend Backend, path string) (*State, error) {
+	data, journaled, err := readStateData(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read the state file: %v", err)
+	}
+	if _, ok := backend.(*journaledBackend); journaled && backend != nil && !ok {
+		if err := osutil.AtomicWriteFile(path, data, 0600, 0); err != nil {
+			return nil, fmt.Errorf("cannot write the state file: %v", err)
+		}
+		if err := os.Remove(JournalPath(path)); err != nil {
+			return nil, fmt.Errorf("cannot remove the state journal: %v", err)
+		}
+	}
+	return ReadState(backend, bytes.NewReader(data))
+}
+
+// NewJournaledBackend returns a backend that persists the state to the
+// given state file, appending the entries modified by each checkpoint to a
+// journal next to it instead of rewriting the whole state. States using it
+// must be read with ReadStateFile.
+func NewJournaledBackend(path string, ensureBefore func(d time.Duration)) Backend {
+	return &journaledBackend{path: path, ensureBefore: ensureBefore}
+}
+
+type journaledBackend struct {
+	path         string
+	ensureBefore func(d time.Duration)
+
+	// journal is nil until the first checkpoint or after a failed one,
+	// which forces writing a new base
+	journal     *os.File
+	journalSize int
+	baseSize    int
+}
+
+// Checkpoint writes the given full state as the new base.
+func (jb *journaledBackend) Checkpoint(data []byte) error {
+	return jb.compact(data)
+}
+
+func (jb *journaledBackend) EnsureBefore(d time.Duration) {
+	jb.ensureBefore(d)
+}
+
+// checkpointState appends the entries of the given state modified since
+// the last checkpoint to the journal, or writes a new base if needed.
+func (jb *journaledBackend) checkpointState(s *State) error {
+	if jb.journal == nil || s.dirtyAll || jb.journalSize > jb.baseSize {
+		if err := jb.compact(s.checkpointData()); err != nil {
+			return err
+		}
+		s.clearDirty()
+		return nil
+	}
+
+	ops, err := s.dirtyOps()
+	if err != nil {
+		return err
+	}
+	if len(ops) == 0 {
+		s.clearDirty()
+		return nil
+	}
+	content, err := json.Marshal(ops)
+	if err != nil {
+		return err
+	}
+	line := journalLine(content)
+	if err := jb.append(line); err != nil {
+		// the journal might end in a partial record now, start over
+		// with a new base on the next checkpoint
+		jb.reset()
+		return err
+	}
+	jb.journalSize += len(line)
+	s.clearDirty()
+	return nil
+}
+
+func (jb *journaledBackend) append(line []byte) error {
+	if _, err := jb.journal.Write(line); err != nil {
+		return err
+	}
+	return jb.journal.Sync()
+}
+
+// compact writes the given state as the new base and starts a new journal
+// for it.
+func (jb *journaledBackend) compact(data []byte) error {
+	jb.reset()
+
+	if err := osutil.AtomicWriteFile(jb.path, data, 0600, 0); err != nil {
+		return err
+	}
+	header, err := json.Marshal(journalHeader{Format: journalFormat, Base: baseChecksum(data)})
+	if err != nil {
+		return err
+	}
+	line := journalLine(header)
+	jpath := JournalPath(jb.path)
+	if err := osutil.AtomicWriteFile(jpath, line, 0600, 0); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(jpath, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+
+	jb.journal = f
+	jb.journalSize = len(line)
+	jb.baseSize = len(data)
+	return nil
+}
+
+func (jb *journaledBackend) reset() {
+	if jb.journal != nil {
+		jb.journal.Close()
+	}
+	jb.journal = nil
+	jb.journalSize = 0
+	jb.baseSize = 0
+}
+
+// markDirty records that the given entry was modified since the last
+// checkpoint, if the state is journaled.
+func (s *State) markDirty(entry, key string) {
+	if s.dirty != nil {
+		s.dirty[journalKey{entry: entry, key: key}] = true
+	}
+}
+
+func (s *State) clearDirty() {
+	if s.dirty != nil {
+		s.dirty = make(map[journalKey]bool)
+	}
+	s.dirtyAll = false
+}
+
+// dirtyOps returns the journal ops recording the current value of the
+// entries modified since the last checkpoint, sorted by entry and key.
+func (s *State) dirtyOps() ([]journalOp, error) {
+	ops := make([]journalOp, 0, len(s.dirty))
+	for k := range s.dirty {
+		var value interface{}
+		switch k.entry {
+		case "data":
+			if v := s.data[k.key]; v != nil {
+				value = v
+			}
+		case "changes":
+			if chg := s.changes[k.key]; chg != nil {
+				value = chg
+			}
+		case "tasks":
+			if t := s.tasks[k.key]; t != nil {
+				value = t
+			}
+		case "":
+			switch k.key {
+			case "warnings":
+				if warnings := s.flattenWarnings(); len(warnings) > 0 {
+					value = warnings
+				}
+			case "notices":
+				if notices := s.flattenNotices(nil); len(notices) > 0 {
+					value = notices
+				}
+			case "last-change-id":
+				value = s.lastChangeId
+			case "last-task-id":
+				value = s.lastTaskId
+			case "last-lane-id":
+				value = s.lastLaneId
+			case "last-notice-id":
+				if s.lastNoticeId != 0 {
+					value = s.lastNoticeId
+				}
+			default:
+				return nil, fmt.Errorf("internal error: unknown state entry %q", k.key)
+			}
+		default:
+			return nil, fmt.Errorf("internal error: unknown state entry %q", k.entry)
+		}
+
+		op := journalOp{Entry: k.entry, Key: k.key}
+		if value == nil {
+			op.Delete = true
+		} else {
+			data, err := json.Marshal(value)
+			if err != nil {
+				return nil, err
+			}
+			op.Value = data
+		}
+		ops = append(ops, op)
+	}
+	sort.Slice(ops, func(i, j int) bool {
+		if ops[i].Entry != ops[j].Entry {
+			return ops[i].Entry < ops[j].Entry
+		}
+		return ops[i].Key < ops[j].Key
+	})
+	return ops, nil
+}
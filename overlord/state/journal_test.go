@@ -0,0 +1,447 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package state_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type journalSuite struct {
+	path string
+}
+
+var _ = Suite(&journalSuite{})
+
+func (s *journalSuite) SetUpTest(c *C) {
+	s.path = filepath.Join(c.MkDir(), "state.json")
+}
+
+func newJournaledState(path string) *state.State {
+	return state.New(state.NewJournaledBackend(path, func(time.Duration) {}))
+}
+
+// fileBackend writes the full state on every checkpoint.
+type fileBackend struct {
+	path string
+}
+
+func (b *fileBackend) Checkpoint(data []byte) error {
+	return osutil.AtomicWriteFile(b.path, data, 0600, 0)
+}
+
+func (b *fileBackend) EnsureBefore(d time.Duration) {}
+
+func (s *journalSuite) journalLines(c *C) []string {
+	data, err := ioutil.ReadFile(state.JournalPath(s.path))
+	c.Assert(err, IsNil)
+	c.Assert(strings.HasSuffix(string(data), "\n"), Equals, true)
+	return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+}
+
+func (s *journalSuite) readState(c *C) *state.State {
+	st, err := state.ReadStateFile(nil, s.path)
+	c.Assert(err, IsNil)
+	return st
+}
+
+func (s *journalSuite) TestCheckpointAppendsToJournal(c *C) {
+	st := newJournaledState(s.path)
+	st.Lock()
+	// keep the journal smaller than the state
+	st.Set("padding", strings.Repeat("x", 1000))
+	st.Set("a", 1)
+	st.Unlock()
+
+	// the first checkpoint writes the full state
+	base, err := ioutil.ReadFile(s.path)
+	c.Assert(err, IsNil)
+	c.Check(string(base), testutil.Contains, `"a":1`)
+	c.Check(s.journalLines(c), HasLen, 1)
+
+	st.Lock()
+	st.Set("b", 2)
+	st.Unlock()
+
+	// the following ones only append the modified entries
+	c.Check(s.path, testutil.FileEquals, string(base))
+	lines := s.journalLines(c)
+	c.Assert(lines, HasLen, 2)
+	c.Check(lines[1], Matches, `[0-9a-f]{8} \[{"entry":"data","key":"b","value":2}\]`)
+
+	st.Lock()
+	st.Set("a", nil)
+	st.Unlock()
+
+	lines = s.journalLines(c)
+	c.Assert(lines, HasLen, 3)
+	c.Check(lines[2], Matches, `[0-9a-f]{8} \[{"entry":"data","key":"a","delete":true}\]`)
+
+	st2 := s.readState(c)
+	st2.Lock()
+	defer st2.Unlock()
+	var v int
+	c.Check(st2.Get("a", &v), testutil.ErrorIs, state.ErrNoState)
+	c.Assert(st2.Get("b", &v), IsNil)
+	c.Check(v, Equals, 2)
+}
+
+func (s *journalSuite) TestCheckpointJournalsChangesAndTasksPerKey(c *C) {
+	st := newJournaledState(s.path)
+	st.Lock()
+	chg := st.NewChange("install", "...")
+	var tasks []*state.Task
+	for i := 0; i < 10; i++ {
+		t := st.NewTask("download", fmt.Sprintf("task %d", i))
+		chg.AddTask(t)
+		tasks = append(tasks, t)
+	}
+	st.Unlock()
+
+	st.Lock()
+	tasks[3].SetStatus(state.DoneStatus)
+	st.Unlock()
+
+	// only the updated task (and the change-update notice) is journaled
+	lines := s.journalLines(c)
+	c.Assert(lines, HasLen, 2)
+	c.Check(lines[1], Matches, fmt.Sprintf(`[0-9a-f]{8} \[{"key":"notices",.*},{"entry":"tasks","key":"%s","value":{[^{}]*"status":4[^{}]*}}\]`, tasks[3].ID()))
+
+	st2 := s.readState(c)
+	st2.Lock()
+	defer st2.Unlock()
+	c.Assert(st2.Changes(), HasLen, 1)
+	c.Check(st2.Task(tasks[3].ID()).Status(), Equals, state.DoneStatus)
+	c.Check(st2.Task(tasks[4].ID()).Status(), Equals, state.DoStatus)
+}
+
+func (s *journalSuite) TestCheckpointJournalMatchesState(c *C) {
+	st := newJournaledState(s.path)
+	st.Lock()
+	st.Set("padding", strings.Repeat("x", 5000))
+	st.Unlock()
+
+	st.Lock()
+	chg := st.NewChange("install", "...")
+	t1 := st.NewTask("download", "1")
+	t2 := st.NewTask("link", "2")
+	t2.WaitFor(t1)
+	t2.JoinLane(st.NewLane())
+	chg.AddTask(t1)
+	chg.AddTask(t2)
+	chg.Set("some", "data")
+	st.NewTask("unlinked", "3")
+	st.Unlock()
+
+	st.Lock()
+	t1.SetStatus(state.DoneStatus)
+	t1.Logf("done")
+	t2.SetProgress("linking", 1, 2)
+	st.Unlock()
+
+	st.Lock()
+	t1.SetStatus(state.DoneStatus)
+	t2.SetStatus(state.DoneStatus)
+	t1.SetClean()
+	t2.SetClean()
+	st.Warnf("some warning")
+	_, err := st.AddNotice(nil, state.RefreshInhibitNotice, "-", nil)
+	c.Assert(err, IsNil)
+	st.Unlock()
+
+	// everything above was journaled instead of written as a new base
+	c.Check(len(s.journalLines(c)) > 1, Equals, true)
+
+	// compare with the state as read back from a full checkpoint
+	st.Lock()
+	data, err := json.Marshal(st)
+	st.Unlock()
+	c.Assert(err, IsNil)
+	full, err := state.ReadState(nil, bytes.NewReader(data))
+	c.Assert(err, IsNil)
+	full.Lock()
+	expected, err := json.Marshal(full)
+	full.Unlock()
+	c.Assert(err, IsNil)
+
+	st2 := s.readState(c)
+	st2.Lock()
+	defer st2.Unlock()
+	c.Check(st2.Change(chg.ID()).IsClean(), Equals, true)
+	c.Check(st2.Change(chg.ID()).ReadyTime().IsZero(), Equals, false)
+	replayed, err := json.Marshal(st2)
+	c.Assert(err, IsNil)
+
+	var expectedV, replayedV interface{}
+	c.Assert(json.Unmarshal(expected, &expectedV), IsNil)
+	c.Assert(json.Unmarshal(replayed, &replayedV), IsNil)
+	c.Check(replayedV, DeepEquals, expectedV)
+}
+
+func (s *journalSuite) TestCheckpointJournalsPrune(c *C) {
+	st := newJournaledState(s.path)
+	st.Lock()
+	st.Set("padding", strings.Repeat("x", 5000))
+	chg := st.NewChange("install", "...")
+	t := st.NewTask("download", "1")
+	chg.AddTask(t)
+	t.SetStatus(state.DoneStatus)
+	st.Unlock()
+
+	st.Lock()
+	st.Prune(time.Now(), time.Hour, time.Hour, 0)
+	c.Assert(st.Changes(), HasLen, 0)
+	st.Unlock()
+	c.Check(len(s.journalLines(c)) > 1, Equals, true)
+
+	st2 := s.readState(c)
+	st2.Lock()
+	defer st2.Unlock()
+	c.Check(st2.Changes(), HasLen, 0)
+	c.Check(st2.TaskCount(), Equals, 0)
+}
+
+func (s *journalSuite) TestCheckpointCompacts(c *C) {
+	st := newJournaledState(s.path)
+	st.Lock()
+	st.Set("a", strings.Repeat("x", 100))
+	st.Unlock()
+	base, err := ioutil.ReadFile(s.path)
+	c.Assert(err, IsNil)
+
+	for i := 0; i < 10; i++ {
+		st.Lock()
+		st.Set("b", i)
+		st.Unlock()
+	}
+
+	// the journal grew larger than the state and was compacted
+	newBase, err := ioutil.ReadFile(s.path)
+	c.Assert(err, IsNil)
+	c.Check(newBase, Not(DeepEquals), base)
+	c.Check(len(s.journalLines(c)) < 10, Equals, true)
+
+	st2 := s.readState(c)
+	st2.Lock()
+	defer st2.Unlock()
+	var v int
+	c.Assert(st2.Get("b", &v), IsNil)
+	c.Check(v, Equals, 9)
+}
+
+func (s *journalSuite) TestReadStateFileTornRecord(c *C) {
+	st := newJournaledState(s.path)
+	st.Lock()
+	st.Set("a", 1)
+	st.Unlock()
+	st.Lock()
+	st.Set("a", 2)
+	st.Unlock()
+
+	// simulate a record torn by a power loss
+	f, err := os.OpenFile(state.JournalPath(s.path), os.O_WRONLY|os.O_APPEND, 0600)
+	c.Assert(err, IsNil)
+	_, err = f.WriteString(`01234567 [{"entry":"data","key":"a","val`)
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+
+	st2 := s.readState(c)
+	st2.Lock()
+	var v int
+	c.Assert(st2.Get("a", &v), IsNil)
+	c.Check(v, Equals, 2)
+	st2.Unlock()
+}
+
+func (s *journalSuite) TestReadStateFileStaleJournal(c *C) {
+	st := newJournaledState(s.path)
+	st.Lock()
+	st.Set("a", 1)
+	st.Unlock()
+	st.Lock()
+	st.Set("a", 2)
+	st.Unlock()
+
+	// a base written after the journal, as if snapd stopped before
+	// resetting the journal after compaction
+	c.Assert(ioutil.WriteFile(s.path, []byte(`{"data":{"a":3}}`), 0600), IsNil)
+
+	st2 := s.readState(c)
+	st2.Lock()
+	defer st2.Unlock()
+	var v int
+	c.Assert(st2.Get("a", &v), IsNil)
+	c.Check(v, Equals, 3)
+}
+
+func (s *journalSuite) TestReadStateFileNoJournal(c *C) {
+	c.Assert(ioutil.WriteFile(s.path, []byte(`{"data":{"a":1}}`), 0600), IsNil)
+
+	st := s.readState(c)
+	st.Lock()
+	defer st.Unlock()
+	var v int
+	c.Assert(st.Get("a", &v), IsNil)
+	c.Check(v, Equals, 1)
+}
+
+func (s *journalSuite) TestReadStateFileBadHeader(c *C) {
+	c.Assert(ioutil.WriteFile(s.path, []byte(`{"data":{"a":1}}`), 0600), IsNil)
+	c.Assert(ioutil.WriteFile(state.JournalPath(s.path), []byte("garbage\n"), 0600), IsNil)
+
+	_, err := state.ReadStateFile(nil, s.path)
+	c.Check(err, ErrorMatches, "cannot read the state file: cannot read state journal: invalid header")
+}
+
+func (s *journalSuite) TestReadStateFileMissing(c *C) {
+	_, err := state.ReadStateFile(nil, s.path)
+	c.Check(err, ErrorMatches, "cannot read the state file: open .*/state.json: no such file or directory")
+}
+
+func (s *journalSuite) TestReadStateFileFoldsJournal(c *C) {
+	st := newJournaledState(s.path)
+	st.Lock()
+	st.Set("a", 1)
+	st.Unlock()
+	st.Lock()
+	st.Set("a", 2)
+	st.Unlock()
+
+	// readers without a backend leave the journal alone
+	s.readState(c)
+	c.Check(state.JournalPath(s.path), testutil.FilePresent)
+
+	// while a backend that does not maintain it folds it into the base
+	st2, err := state.ReadStateFile(&fileBackend{path: s.path}, s.path)
+	c.Assert(err, IsNil)
+	c.Check(state.JournalPath(s.path), testutil.FileAbsent)
+	c.Check(s.path, testutil.FileContains, `"a":2`)
+
+	st2.Lock()
+	defer st2.Unlock()
+	var v int
+	c.Assert(st2.Get("a", &v), IsNil)
+	c.Check(v, Equals, 2)
+}
+
+func (s *journalSuite) TestReadStateFileKeepsJournaling(c *C) {
+	st := newJournaledState(s.path)
+	st.Lock()
+	st.Set("padding", strings.Repeat("x", 1000))
+	st.Set("a", 1)
+	st.Unlock()
+	st.Lock()
+	st.Set("a", 2)
+	st.Unlock()
+
+	st2, err := state.ReadStateFile(state.NewJournaledBackend(s.path, func(time.Duration) {}), s.path)
+	c.Assert(err, IsNil)
+	st2.Lock()
+	st2.Set("b", 3)
+	st2.Unlock()
+	st2.Lock()
+	st2.Set("b", 4)
+	st2.Unlock()
+
+	// a new base was written on the first checkpoint, the following
+	// are journaled
+	c.Check(s.path, testutil.FileContains, `"a":2`)
+	c.Check(s.journalLines(c), HasLen, 2)
+
+	st3 := s.readState(c)
+	st3.Lock()
+	defer st3.Unlock()
+	var v int
+	c.Assert(st3.Get("b", &v), IsNil)
+	c.Check(v, Equals, 4)
+}
+
+func benchmarkCheckpoint(b *testing.B, backend state.Backend) {
+	st := state.New(backend)
+
+	// a state with a few hundred changes
+	st.Lock()
+	var tasks []*state.Task
+	for i := 0; i < 300; i++ {
+		chg := st.NewChange("install", fmt.Sprintf("Install snap %d", i))
+		for j := 0; j < 10; j++ {
+			t := st.NewTask("some-task", fmt.Sprintf("Task %d of change %d", j, i))
+			t.Set("snap-setup", map[string]interface{}{"name": fmt.Sprintf("snap-%d", i), "revision": j})
+			chg.AddTask(t)
+			tasks = append(tasks, t)
+		}
+		chg.SetStatus(state.DoneStatus)
+	}
+	st.Unlock()
+
+	written := writtenBytes(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		st.Lock()
+		tasks[i%len(tasks)].Logf("some progress at %v", time.Now())
+		st.Unlock()
+	}
+	b.StopTimer()
+	// what matters most on slow storage is how much is written
+	b.ReportMetric(float64(writtenBytes(b)-written)/float64(b.N), "written-B/op")
+}
+
+// writtenBytes returns the number of bytes written by the process so far.
+func writtenBytes(b *testing.B) int64 {
+	data, err := ioutil.ReadFile("/proc/self/io")
+	if err != nil {
+		b.Skip("cannot read /proc/self/io")
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.HasPrefix(line, "wchar: ") {
+			n, err := strconv.ParseInt(strings.TrimPrefix(line, "wchar: "), 10, 64)
+			if err != nil {
+				b.Fatal(err)
+			}
+			return n
+		}
+	}
+	b.Fatal("cannot find wchar in /proc/self/io")
+	return 0
+}
+
+func BenchmarkCheckpointFullState(b *testing.B) {
+	path := filepath.Join(b.TempDir(), "state.json")
+	benchmarkCheckpoint(b, &fileBackend{path: path})
+}
+
+func BenchmarkCheckpointJournaledState(b *testing.B) {
+	path := filepath.Join(b.TempDir(), "state.json")
+	benchmarkCheckpoint(b, state.NewJournaledBackend(path, func(time.Duration) {}))
+}
@@ -0,0 +1,520 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package state
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/snapcore/snapd/logger"
+)
+
+const (
+	// defaultNoticeExpireAfter is the default expiry time for notices.
+	defaultNoticeExpireAfter = 7 * 24 * time.Hour
+
+	// maxNoticeKeyLength is the max size in bytes for a notice key.
+	maxNoticeKeyLength = 256
+)
+
+// NoticeType represents the type of a notice.
+type NoticeType string
+
+const (
+	// ChangeUpdateNotice is recorded whenever a change is spawned or its
+	// status is updated. The key is the change ID.
+	ChangeUpdateNotice NoticeType = "change-update"
+
+	// RefreshInhibitNotice is recorded whenever an auto-refresh of a snap is
+	// inhibited by its running apps. The key is the snap instance name.
+	RefreshInhibitNotice NoticeType = "refresh-inhibit"
+
+	// SnapPublishedNotice is recorded whenever the store reports a new
+	// revision of an installed snap. The key is the snap instance name.
+	SnapPublishedNotice NoticeType = "snap-published"
+
+	// SnapNotifyNotice is a custom notice recorded by a snap through
+	// "snapctl notify". The key has the form <instance-name>/<key>.
+	SnapNotifyNotice NoticeType = "snap-notify"
+)
+
+func (t NoticeType) valid() bool {
+	switch t {
+	case ChangeUpdateNotice, RefreshInhibitNotice, SnapPublishedNotice, SnapNotifyNotice:
+		return true
+	}
+	return false
+}
+
+// Notice represents an aggregated notice. The combination of type and key is
+// unique per user (or among public notices).
+type Notice struct {
+	// Server-generated unique ID for this notice (a surrogate key).
+	id string
+
+	// The UID of the user who may view this notice, or nil if the notice is
+	// public and may be viewed by any user.
+	userID *uint32
+
+	// The notice type represents a group of notices originating from a
+	// common source.
+	noticeType NoticeType
+
+	// The notice key is a string that differentiates notices of this type.
+	key string
+
+	// The first time one of these notices (type and key combination) occurs.
+	firstOccurred time.Time
+
+	// The last time one of these notices occurred.
+	lastOccurred time.Time
+
+	// The time this notice was last "repeated". This is used for filtering
+	// and sorting, and is updated only when the notice occurs again after
+	// the repeatAfter duration has elapsed.
+	lastRepeated time.Time
+
+	// The number of times one of these notices has occurred.
+	occurrences int
+
+	// Additional data captured from the last occurrence of this notice.
+	lastData map[string]string
+
+	// How long after one of these was last repeated should we allow it to
+	// repeat.
+	repeatAfter time.Duration
+
+	// How much time after one of these was last occurred should we drop
+	// the notice.
+	expireAfter time.Duration
+}
+
+func (n *Notice) String() string {
+	userIDStr := "public"
+	if n.userID != nil {
+		userIDStr = strconv.FormatUint(uint64(*n.userID), 10)
+	}
+	return fmt.Sprintf("Notice %s (%s:%s:%s)", n.id, userIDStr, n.noticeType, n.key)
+}
+
+// ID returns the unique ID of the notice.
+func (n *Notice) ID() string {
+	return n.id
+}
+
+// UserID returns the ID of the user who may view the notice, and false if
+// the notice is public.
+func (n *Notice) UserID() (uint32, bool) {
+	if n.userID == nil {
+		return 0, false
+	}
+	return *n.userID, true
+}
+
+// Type returns the type of the notice.
+func (n *Notice) Type() NoticeType {
+	return n.noticeType
+}
+
+// Key returns the key of the notice.
+func (n *Notice) Key() string {
+	return n.key
+}
+
+// LastData returns the data recorded with the last occurrence of the notice.
+func (n *Notice) LastData() map[string]string {
+	return n.lastData
+}
+
+// LastRepeated returns the time the notice was last repeated.
+func (n *Notice) LastRepeated() time.Time {
+	return n.lastRepeated
+}
+
+// Occurrences returns how many times the notice has occurred.
+func (n *Notice) Occurrences() int {
+	return n.occurrences
+}
+
+func (n *Notice) expired(now time.Time) bool {
+	return n.lastOccurred.Add(n.expireAfter).Before(now)
+}
+
+type jsonNotice struct {
+	ID            string            `json:"id"`
+	UserID        *uint32           `json:"user-id"`
+	Type          string            `json:"type"`
+	Key           string            `json:"key"`
+	FirstOccurred time.Time         `json:"first-occurred"`
+	LastOccurred  time.Time         `json:"last-occurred"`
+	LastRepeated  time.Time         `json:"last-repeated"`
+	Occurrences   int               `json:"occurrences"`
+	LastData      map[string]string `json:"last-data,omitempty"`
+	RepeatAfter   string            `json:"repeat-after,omitempty"`
+	ExpireAfter   string            `json:"expire-after,omitempty"`
+}
+
+func (n *Notice) MarshalJSON() ([]byte, error) {
+	jn := jsonNotice{
+		ID:            n.id,
+		UserID:        n.userID,
+		Type:          string(n.noticeType),
+		Key:           n.key,
+		FirstOccurred: n.firstOccurred,
+		LastOccurred:  n.lastOccurred,
+		LastRepeated:  n.lastRepeated,
+		Occurrences:   n.occurrences,
+		LastData:      n.lastData,
+	}
+	if n.repeatAfter != 0 {
+		jn.RepeatAfter = n.repeatAfter.String()
+	}
+	if n.expireAfter != 0 {
+		jn.ExpireAfter = n.expireAfter.String()
+	}
+	return json.Marshal(jn)
+}
+
+func (n *Notice) UnmarshalJSON(data []byte) error {
+	var jn jsonNotice
+	if err := json.Unmarshal(data, &jn); err != nil {
+		return err
+	}
+	n.id = jn.ID
+	n.userID = jn.UserID
+	n.noticeType = NoticeType(jn.Type)
+	n.key = jn.Key
+	n.firstOccurred = jn.FirstOccurred
+	n.lastOccurred = jn.LastOccurred
+	n.lastRepeated = jn.LastRepeated
+	n.occurrences = jn.Occurrences
+	n.lastData = jn.LastData
+	var err error
+	if jn.RepeatAfter != "" {
+		n.repeatAfter, err = time.ParseDuration(jn.RepeatAfter)
+		if err != nil {
+			return err
+		}
+	}
+	if jn.ExpireAfter != "" {
+		n.expireAfter, err = time.ParseDuration(jn.ExpireAfter)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// noticeKey is the unique key of a notice in the state.
+type noticeKey struct {
+	hasUserID  bool
+	userID     uint32
+	noticeType NoticeType
+	key        string
+}
+
+func (n *Notice) uniqueKey() noticeKey {
+	k := noticeKey{noticeType: n.noticeType, key: n.key}
+	if n.userID != nil {
+		k.hasUserID = true
+		k.userID = *n.userID
+	}
+	return k
+}
+
+// AddNoticeOptions holds optional parameters for an AddNotice call.
+type AddNoticeOptions struct {
+	// Data is the optional key-value data for this occurrence.
+	Data map[string]string
+
+	// RepeatAfter defines how long after this notice was last repeated we
+	// should allow it to repeat. Zero means always repeat.
+	RepeatAfter time.Duration
+
+	// Time, if set, overrides time.Now() as the notice occurrence time.
+	Time time.Time
+}
+
+// AddNotice records an occurrence of a notice with the specified type and key
+// and returns the notice ID. A nil userID makes the notice public.
+func (s *State) AddNotice(userID *uint32, noticeType NoticeType, key string, options *AddNoticeOptions) (string, error) {
+	if options == nil {
+		options = &AddNoticeOptions{}
+	}
+	if err := validateNotice(noticeType, key, options); err != nil {
+		return "", fmt.Errorf("internal error: %v", err)
+	}
+
+	s.writingEntry("", "notices")
+
+	now := options.Time
+	if now.IsZero() {
+		now = timeNow()
+	}
+	now = now.UTC()
+	// notice timestamps are used for filtering, make sure they are unique
+	// and always increasing
+	if !now.After(s.lastNoticeTimestamp) {
+		now = s.lastNoticeTimestamp.Add(time.Nanosecond)
+	}
+	s.lastNoticeTimestamp = now
+
+	newOrRepeated := false
+	k := noticeKey{noticeType: noticeType, key: key}
+	if userID != nil {
+		k.hasUserID = true
+		k.userID = *userID
+	}
+	notice, ok := s.notices[k]
+	if !ok {
+		s.markDirty("", "last-notice-id")
+		s.lastNoticeId++
+		notice = &Notice{
+			id:            strconv.Itoa(s.lastNoticeId),
+			userID:        userID,
+			noticeType:    noticeType,
+			key:           key,
+			firstOccurred: now,
+			lastRepeated:  now,
+			expireAfter:   defaultNoticeExpireAfter,
+			occurrences:   1,
+		}
+		s.notices[k] = notice
+		newOrRepeated = true
+	} else {
+		notice.occurrences++
+		if options.RepeatAfter == 0 || now.After(notice.lastRepeated.Add(options.RepeatAfter)) {
+			notice.lastRepeated = now
+			newOrRepeated = true
+		}
+	}
+	notice.lastOccurred = now
+	notice.lastData = options.Data
+	notice.repeatAfter = options.RepeatAfter
+
+	if newOrRepeated {
+		s.noticeCond.Broadcast()
+	}
+
+	return notice.id, nil
+}
+
+func validateNotice(noticeType NoticeType, key string, options *AddNoticeOptions) error {
+	if !noticeType.valid() {
+		return fmt.Errorf("cannot add notice with invalid type %q", noticeType)
+	}
+	if key == "" {
+		return errors.New("cannot add notice with empty key")
+	}
+	if len(key) > maxNoticeKeyLength {
+		return fmt.Errorf("cannot add notice with key longer than %d bytes", maxNoticeKeyLength)
+	}
+	if options.RepeatAfter < 0 {
+		return fmt.Errorf("cannot add notice with negative repeat-after duration: %v", options.RepeatAfter)
+	}
+	return nil
+}
+
+// NoticeFilter allows filtering notices by various fields.
+type NoticeFilter struct {
+	// UserID, if set, includes only notices that are either public or
+	// visible to the given user.
+	UserID *uint32
+
+	// Types, if not empty, includes only notices whose type is one of these.
+	Types []NoticeType
+
+	// Keys, if not empty, includes only notices whose key is one of these.
+	Keys []string
+
+	// After, if set, includes only notices that were last repeated after
+	// this time.
+	After time.Time
+}
+
+func (f *NoticeFilter) matches(n *Notice) bool {
+	if f == nil {
+		return true
+	}
+	if f.UserID != nil && n.userID != nil && *f.UserID != *n.userID {
+		return false
+	}
+	if len(f.Types) > 0 && !noticeTypeIn(n.noticeType, f.Types) {
+		return false
+	}
+	if len(f.Keys) > 0 && !stringIn(n.key, f.Keys) {
+		return false
+	}
+	if !f.After.IsZero() && !n.lastRepeated.After(f.After) {
+		return false
+	}
+	return true
+}
+
+func noticeTypeIn(t NoticeType, types []NoticeType) bool {
+	for _, tt := range types {
+		if t == tt {
+			return true
+		}
+	}
+	return false
+}
+
+func stringIn(s string, strs []string) bool {
+	for _, ss := range strs {
+		if s == ss {
+			return true
+		}
+	}
+	return false
+}
+
+type byLastRepeated []*Notice
+
+func (a byLastRepeated) Len() int           { return len(a) }
+func (a byLastRepeated) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a byLastRepeated) Less(i, j int) bool { return a[i].lastRepeated.Before(a[j].lastRepeated) }
+
+// Notices returns the list of notices that match the filter (if any),
+// ordered by the last-repeated time.
+func (s *State) Notices(filter *NoticeFilter) []*Notice {
+	s.reading()
+
+	notices := s.flattenNotices(filter)
+	sort.Sort(byLastRepeated(notices))
+	return notices
+}
+
+// Notice returns a single notice by ID, or nil if not found.
+func (s *State) Notice(id string) *Notice {
+	s.reading()
+
+	// Could use another map for lookup, but the number of notices will
+	// likely be small, and this function is probably only rarely used.
+	for _, n := range s.notices {
+		if n.id == id {
+			return n
+		}
+	}
+	return nil
+}
+
+// flattenNotices returns the non-expired notices matching the filter, in no
+// particular order. Call with the lock held.
+func (s *State) flattenNotices(filter *NoticeFilter) []*Notice {
+	now := time.Now()
+	var notices []*Notice
+	for _, n := range s.notices {
+		if n.expired(now) || !filter.matches(n) {
+			continue
+		}
+		notices = append(notices, n)
+	}
+	// keep the order stable, notice IDs are increasing integers
+	sort.Slice(notices, func(i, j int) bool {
+		a, b := notices[i].id, notices[j].id
+		if len(a) != len(b) {
+			return len(a) < len(b)
+		}
+		return a < b
+	})
+	return notices
+}
+
+// unflattenNotices takes a flat list of notices and replaces the notice map
+// with them, ignoring expired notices in the process. Call with the lock held.
+func (s *State) unflattenNotices(flat []*Notice) {
+	now := time.Now()
+	s.notices = make(map[noticeKey]*Notice, len(flat))
+	for _, n := range flat {
+		if n.expired(now) {
+			continue
+		}
+		s.notices[n.uniqueKey()] = n
+		if n.lastRepeated.After(s.lastNoticeTimestamp) {
+			s.lastNoticeTimestamp = n.lastRepeated
+		}
+	}
+}
+
+// pruneNotices removes expired notices. Call with the lock held.
+func (s *State) pruneNotices(now time.Time) {
+	for k, n := range s.notices {
+		if n.expired(now) {
+			s.writingEntry("", "notices")
+			delete(s.notices, k)
+		}
+	}
+}
+
+// WaitNotices waits for notices that match the filter to exist or occur,
+// returning the list of matching notices ordered by the last-repeated time.
+//
+// It waits till there is at least one matching notice or the context is
+// cancelled. If there are existing notices that match the filter,
+// WaitNotices will return them immediately. The state lock must be held
+// when calling WaitNotices; it is released while waiting.
+func (s *State) WaitNotices(ctx context.Context, filter *NoticeFilter) ([]*Notice, error) {
+	s.reading()
+
+	notices := s.Notices(filter)
+	if len(notices) > 0 {
+		return notices, nil
+	}
+
+	// wake up the waiter(s) when the context is done
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			s.Lock()
+			s.noticeCond.Broadcast()
+			s.unlock()
+		case <-done:
+		}
+	}()
+
+	for {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		// wait for a new or repeated notice, this releases the lock
+		// while waiting
+		s.noticeCond.Wait()
+
+		notices = s.Notices(filter)
+		if len(notices) > 0 {
+			return notices, nil
+		}
+	}
+}
+
+func (s *State) addNoticeOrPanic(userID *uint32, noticeType NoticeType, key string, options *AddNoticeOptions) {
+	if _, err := s.AddNotice(userID, noticeType, key, options); err != nil {
+		// programming error!
+		logger.Panicf("%v", err)
+	}
+}
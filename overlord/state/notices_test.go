@@ -0,0 +1,336 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package state_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"time"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+type noticesSuite struct{}
+
+var _ = check.Suite(&noticesSuite{})
+
+func noticeToMap(c *check.C, notice *state.Notice) map[string]interface{} {
+	buf, err := json.Marshal(notice)
+	c.Assert(err, check.IsNil)
+	var m map[string]interface{}
+	c.Assert(json.Unmarshal(buf, &m), check.IsNil)
+	return m
+}
+
+func (s *noticesSuite) TestMarshal(c *check.C) {
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	start := time.Now()
+	uid := uint32(1000)
+	id, err := st.AddNotice(&uid, state.SnapNotifyNotice, "some-snap/foo", &state.AddNoticeOptions{
+		Data: map[string]string{"k": "v"},
+	})
+	c.Assert(err, check.IsNil)
+	c.Check(id, check.Equals, "1")
+	_, err = st.AddNotice(&uid, state.SnapNotifyNotice, "some-snap/foo", &state.AddNoticeOptions{
+		Data:        map[string]string{"k": "v2"},
+		RepeatAfter: time.Hour,
+	})
+	c.Assert(err, check.IsNil)
+
+	notices := st.Notices(nil)
+	c.Assert(notices, check.HasLen, 1)
+	c.Check(notices[0].String(), check.Equals, "Notice 1 (1000:snap-notify:some-snap/foo)")
+	n := noticeToMap(c, notices[0])
+
+	firstOccurred, err := time.Parse(time.RFC3339, n["first-occurred"].(string))
+	c.Assert(err, check.IsNil)
+	c.Check(!firstOccurred.Before(start), check.Equals, true)
+	lastOccurred, err := time.Parse(time.RFC3339, n["last-occurred"].(string))
+	c.Assert(err, check.IsNil)
+	c.Check(lastOccurred.After(firstOccurred), check.Equals, true)
+	lastRepeated, err := time.Parse(time.RFC3339, n["last-repeated"].(string))
+	c.Assert(err, check.IsNil)
+	// repeat-after was not yet elapsed
+	c.Check(lastRepeated.Equal(firstOccurred), check.Equals, true)
+
+	delete(n, "first-occurred")
+	delete(n, "last-occurred")
+	delete(n, "last-repeated")
+	c.Check(n, check.DeepEquals, map[string]interface{}{
+		"id":           "1",
+		"user-id":      1000.0,
+		"type":         "snap-notify",
+		"key":          "some-snap/foo",
+		"occurrences":  2.0,
+		"last-data":    map[string]interface{}{"k": "v2"},
+		"repeat-after": "1h0m0s",
+		"expire-after": "168h0m0s",
+	})
+}
+
+func (s *noticesSuite) TestUnmarshalRoundtrip(c *check.C) {
+	st := state.New(nil)
+	st.Lock()
+	_, err := st.AddNotice(nil, state.SnapPublishedNotice, "some-snap", &state.AddNoticeOptions{
+		Data: map[string]string{"revision": "2"},
+	})
+	c.Assert(err, check.IsNil)
+	before := noticeToMap(c, st.Notices(nil)[0])
+	buf, err := json.Marshal(st)
+	st.Unlock()
+	c.Assert(err, check.IsNil)
+
+	st2, err := state.ReadState(nil, bytes.NewReader(buf))
+	c.Assert(err, check.IsNil)
+	st2.Lock()
+	defer st2.Unlock()
+
+	notices := st2.Notices(nil)
+	c.Assert(notices, check.HasLen, 1)
+	c.Check(noticeToMap(c, notices[0]), check.DeepEquals, before)
+
+	// the notice ID sequence carries on
+	id, err := st2.AddNotice(nil, state.SnapPublishedNotice, "other-snap", nil)
+	c.Assert(err, check.IsNil)
+	c.Check(id, check.Equals, "2")
+	// and timestamps keep increasing
+	notices = st2.Notices(nil)
+	c.Assert(notices, check.HasLen, 2)
+	c.Check(notices[1].Key(), check.Equals, "other-snap")
+}
+
+func (s *noticesSuite) TestAddNoticeInvalid(c *check.C) {
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	_, err := st.AddNotice(nil, "bad-type", "key", nil)
+	c.Check(err, check.ErrorMatches, `internal error: cannot add notice with invalid type "bad-type"`)
+	_, err = st.AddNotice(nil, state.SnapNotifyNotice, "", nil)
+	c.Check(err, check.ErrorMatches, `internal error: cannot add notice with empty key`)
+	_, err = st.AddNotice(nil, state.SnapNotifyNotice, string(make([]byte, 257)), nil)
+	c.Check(err, check.ErrorMatches, `internal error: cannot add notice with key longer than 256 bytes`)
+	_, err = st.AddNotice(nil, state.SnapNotifyNotice, "key", &state.AddNoticeOptions{RepeatAfter: -time.Second})
+	c.Check(err, check.ErrorMatches, `internal error: cannot add notice with negative repeat-after duration: -1s`)
+	c.Check(st.Notices(nil), check.HasLen, 0)
+}
+
+func (s *noticesSuite) TestRepeatAfter(c *check.C) {
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	t0 := time.Now()
+	_, err := st.AddNotice(nil, state.RefreshInhibitNotice, "some-snap", &state.AddNoticeOptions{Time: t0})
+	c.Assert(err, check.IsNil)
+
+	// not repeated yet, so not visible after t0
+	_, err = st.AddNotice(nil, state.RefreshInhibitNotice, "some-snap", &state.AddNoticeOptions{
+		Time:        t0.Add(time.Minute),
+		RepeatAfter: time.Hour,
+	})
+	c.Assert(err, check.IsNil)
+	c.Check(st.Notices(&state.NoticeFilter{After: t0}), check.HasLen, 0)
+
+	// repeat-after elapsed
+	_, err = st.AddNotice(nil, state.RefreshInhibitNotice, "some-snap", &state.AddNoticeOptions{
+		Time:        t0.Add(2 * time.Hour),
+		RepeatAfter: time.Hour,
+	})
+	c.Assert(err, check.IsNil)
+	notices := st.Notices(&state.NoticeFilter{After: t0})
+	c.Assert(notices, check.HasLen, 1)
+	c.Check(notices[0].Occurrences(), check.Equals, 3)
+}
+
+func (s *noticesSuite) TestFilters(c *check.C) {
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	uid1 := uint32(1000)
+	uid2 := uint32(1001)
+	t0 := time.Now()
+	addNotice := func(uid *uint32, noticeType state.NoticeType, key string, t time.Time) {
+		_, err := st.AddNotice(uid, noticeType, key, &state.AddNoticeOptions{Time: t})
+		c.Assert(err, check.IsNil)
+	}
+	addNotice(nil, state.SnapPublishedNotice, "snap-a", t0)
+	addNotice(&uid1, state.SnapNotifyNotice, "snap-a/foo", t0.Add(time.Second))
+	addNotice(&uid2, state.SnapNotifyNotice, "snap-a/foo", t0.Add(2*time.Second))
+	addNotice(nil, state.RefreshInhibitNotice, "snap-b", t0.Add(3*time.Second))
+
+	keys := func(notices []*state.Notice) []string {
+		var ks []string
+		for _, n := range notices {
+			ks = append(ks, n.String())
+		}
+		return ks
+	}
+
+	c.Check(keys(st.Notices(nil)), check.DeepEquals, []string{
+		"Notice 1 (public:snap-published:snap-a)",
+		"Notice 2 (1000:snap-notify:snap-a/foo)",
+		"Notice 3 (1001:snap-notify:snap-a/foo)",
+		"Notice 4 (public:refresh-inhibit:snap-b)",
+	})
+	c.Check(keys(st.Notices(&state.NoticeFilter{UserID: &uid1})), check.DeepEquals, []string{
+		"Notice 1 (public:snap-published:snap-a)",
+		"Notice 2 (1000:snap-notify:snap-a/foo)",
+		"Notice 4 (public:refresh-inhibit:snap-b)",
+	})
+	c.Check(keys(st.Notices(&state.NoticeFilter{
+		Types: []state.NoticeType{state.SnapPublishedNotice, state.RefreshInhibitNotice},
+	})), check.DeepEquals, []string{
+		"Notice 1 (public:snap-published:snap-a)",
+		"Notice 4 (public:refresh-inhibit:snap-b)",
+	})
+	c.Check(keys(st.Notices(&state.NoticeFilter{
+		Keys: []string{"snap-a/foo"},
+	})), check.DeepEquals, []string{
+		"Notice 2 (1000:snap-notify:snap-a/foo)",
+		"Notice 3 (1001:snap-notify:snap-a/foo)",
+	})
+	c.Check(keys(st.Notices(&state.NoticeFilter{
+		After: t0.Add(time.Second),
+	})), check.DeepEquals, []string{
+		"Notice 3 (1001:snap-notify:snap-a/foo)",
+		"Notice 4 (public:refresh-inhibit:snap-b)",
+	})
+
+	c.Check(st.Notice("3").String(), check.Equals, "Notice 3 (1001:snap-notify:snap-a/foo)")
+	c.Check(st.Notice("42"), check.IsNil)
+}
+
+func (s *noticesSuite) TestPruneExpired(c *check.C) {
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	old := time.Now().Add(-8 * 24 * time.Hour)
+	_, err := st.AddNotice(nil, state.SnapPublishedNotice, "old-snap", &state.AddNoticeOptions{Time: old})
+	c.Assert(err, check.IsNil)
+	_, err = st.AddNotice(nil, state.SnapPublishedNotice, "new-snap", nil)
+	c.Assert(err, check.IsNil)
+
+	// expired notices are not visible
+	notices := st.Notices(nil)
+	c.Assert(notices, check.HasLen, 1)
+	c.Check(notices[0].Key(), check.Equals, "new-snap")
+
+	st.Prune(time.Now(), time.Hour, time.Hour, 100)
+	c.Check(st.Notice("1"), check.IsNil)
+	c.Check(st.Notice("2"), check.NotNil)
+}
+
+func (s *noticesSuite) TestWaitNoticesExisting(c *check.C) {
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	_, err := st.AddNotice(nil, state.SnapPublishedNotice, "some-snap", nil)
+	c.Assert(err, check.IsNil)
+
+	notices, err := st.WaitNotices(context.Background(), &state.NoticeFilter{Keys: []string{"some-snap"}})
+	c.Assert(err, check.IsNil)
+	c.Assert(notices, check.HasLen, 1)
+	c.Check(notices[0].Key(), check.Equals, "some-snap")
+}
+
+func (s *noticesSuite) TestWaitNoticesNew(c *check.C) {
+	st := state.New(nil)
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		st.Lock()
+		defer st.Unlock()
+		_, err := st.AddNotice(nil, state.SnapPublishedNotice, "other-snap", nil)
+		c.Check(err, check.IsNil)
+		_, err = st.AddNotice(nil, state.SnapPublishedNotice, "some-snap", nil)
+		c.Check(err, check.IsNil)
+	}()
+
+	st.Lock()
+	defer st.Unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	notices, err := st.WaitNotices(ctx, &state.NoticeFilter{Keys: []string{"some-snap"}})
+	c.Assert(err, check.IsNil)
+	c.Assert(notices, check.HasLen, 1)
+	c.Check(notices[0].Key(), check.Equals, "some-snap")
+}
+
+func (s *noticesSuite) TestWaitNoticesTimeout(c *check.C) {
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	notices, err := st.WaitNotices(ctx, nil)
+	c.Check(err, check.Equals, context.DeadlineExceeded)
+	c.Check(notices, check.HasLen, 0)
+}
+
+func (s *noticesSuite) TestChangeUpdateNotices(c *check.C) {
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	chg := st.NewChange("install", "...")
+	filter := &state.NoticeFilter{Types: []state.NoticeType{state.ChangeUpdateNotice}}
+	notices := st.Notices(filter)
+	c.Assert(notices, check.HasLen, 1)
+	c.Check(notices[0].Key(), check.Equals, chg.ID())
+	c.Check(notices[0].LastData(), check.DeepEquals, map[string]string{"kind": "install"})
+	c.Check(notices[0].Occurrences(), check.Equals, 1)
+
+	// adding tasks does not record notices by itself
+	t1 := st.NewTask("download", "...")
+	t2 := st.NewTask("link", "...")
+	chg.AddTask(t1)
+	chg.AddTask(t2)
+	c.Check(st.Notices(filter)[0].Occurrences(), check.Equals, 1)
+
+	// hold -> doing
+	t1.SetStatus(state.DoingStatus)
+	c.Check(st.Notices(filter)[0].Occurrences(), check.Equals, 2)
+
+	// change status is still doing
+	t2.SetStatus(state.DoingStatus)
+	c.Check(st.Notices(filter)[0].Occurrences(), check.Equals, 2)
+
+	t1.SetStatus(state.DoneStatus)
+	c.Check(st.Notices(filter)[0].Occurrences(), check.Equals, 2)
+
+	// doing -> done
+	t2.SetStatus(state.DoneStatus)
+	c.Check(st.Notices(filter)[0].Occurrences(), check.Equals, 3)
+
+	// explicitly setting the change status records a notice too
+	chg.SetStatus(state.ErrorStatus)
+	c.Check(st.Notices(filter)[0].Occurrences(), check.Equals, 4)
+}
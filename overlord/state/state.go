@@ -87,15 +87,29 @@ type State struct {
 	lastTaskId   int
 	lastChangeId int
 	lastLaneId   int
+	lastNoticeId int
+
+	// lastNoticeTimestamp is not persisted, it is recomputed from the
+	// notices when reading the state
+	lastNoticeTimestamp time.Time
 
 	backend  Backend
 	data     customData
 	changes  map[string]*Change
 	tasks    map[string]*Task
 	warnings map[string]*Warning
+	notices  map[noticeKey]*Notice
+
+	noticeCond *sync.Cond
+	changeCond *sync.Cond
 
 	modified bool
 
+	// dirty records the entries modified since the last checkpoint when
+	// the state is journaled, dirtyAll that the whole state was
+	dirty    map[journalKey]bool
+	dirtyAll bool
+
 	cache map[interface{}]interface{}
 
 	pendingChangeByAttr map[string]func(*Change) bool
@@ -103,16 +117,30 @@ type State struct {
 
 // New returns a new empty state.
 func New(backend Backend) *State {
-	return &State{
+	st := &State{
 		backend:             backend,
 		data:                make(customData),
 		changes:             make(map[string]*Change),
 		tasks:               make(map[string]*Task),
 		warnings:            make(map[string]*Warning),
+		notices:             make(map[noticeKey]*Notice),
 		modified:            true,
 		cache:               make(map[interface{}]interface{}),
 		pendingChangeByAttr: make(map[string]func(*Change) bool),
 	}
+	st.noticeCond = sync.NewCond(st)
+	st.changeCond = sync.NewCond(st)
+	st.initDirty()
+	return st
+}
+
+// initDirty sets up tracking of the modified entries if the state is
+// journaled.
+func (s *State) initDirty() {
+	if _, ok := s.backend.(*journaledBackend); ok {
+		s.dirty = make(map[journalKey]bool)
+	}
+	s.dirtyAll = true
 }
 
 // Modified returns whether the state was modified since the last checkpoint.
@@ -132,8 +160,19 @@ func (s *State) reading() {
 	}
 }
 
+// writing flags the whole state as modified.
 func (s *State) writing() {
+	s.dirtyAll = true
+	s.writingEntry("", "")
+}
+
+// writingEntry flags the given entry of the state as modified, see
+// journalKey.
+func (s *State) writingEntry(entry, key string) {
 	s.modified = true
+	if entry != "" || key != "" {
+		s.markDirty(entry, key)
+	}
 	if atomic.LoadInt32(&s.muC) != 1 {
 		panic("internal error: accessing state without lock")
 	}
@@ -149,10 +188,12 @@ type marshalledState struct {
 	Changes  map[string]*Change          `json:"changes"`
 	Tasks    map[string]*Task            `json:"tasks"`
 	Warnings []*Warning                  `json:"warnings,omitempty"`
+	Notices  []*Notice                   `json:"notices,omitempty"`
 
 	LastChangeId int `json:"last-change-id"`
 	LastTaskId   int `json:"last-task-id"`
 	LastLaneId   int `json:"last-lane-id"`
+	LastNoticeId int `json:"last-notice-id,omitempty"`
 }
 
 // MarshalJSON makes State a json.Marshaller
@@ -163,10 +204,12 @@ func (s *State) MarshalJSON() ([]byte, error) {
 		Changes:  s.changes,
 		Tasks:    s.tasks,
 		Warnings: s.flattenWarnings(),
+		Notices:  s.flattenNotices(nil),
 
 		LastTaskId:   s.lastTaskId,
 		LastChangeId: s.lastChangeId,
 		LastLaneId:   s.lastLaneId,
+		LastNoticeId: s.lastNoticeId,
 	})
 }
 
@@ -182,9 +225,11 @@ func (s *State) UnmarshalJSON(data []byte) error {
 	s.changes = unmarshalled.Changes
 	s.tasks = unmarshalled.Tasks
 	s.unflattenWarnings(unmarshalled.Warnings)
+	s.unflattenNotices(unmarshalled.Notices)
 	s.lastChangeId = unmarshalled.LastChangeId
 	s.lastTaskId = unmarshalled.LastTaskId
 	s.lastLaneId = unmarshalled.LastLaneId
+	s.lastNoticeId = unmarshalled.LastNoticeId
 	// backlink state again
 	for _, t := range s.tasks {
 		t.state = s
@@ -221,11 +266,17 @@ func (s *State) Unlock() {
 		return
 	}
 
-	data := s.checkpointData()
+	var checkpoint func() error
+	if jb, ok := s.backend.(*journaledBackend); ok {
+		checkpoint = func() error { return jb.checkpointState(s) }
+	} else {
+		data := s.checkpointData()
+		checkpoint = func() error { return s.backend.Checkpoint(data) }
+	}
 	var err error
 	start := time.Now()
 	for time.Since(start) <= unlockCheckpointRetryMaxTime {
-		if err = s.backend.Checkpoint(data); err == nil {
+		if err = checkpoint(); err == nil {
 			s.modified = false
 			return
 		}
@@ -283,7 +334,7 @@ func (s *State) Has(key string) bool {
 // Set associates value with key for future consulting by managers.
 // The provided value must properly marshal and unmarshal with encoding/json.
 func (s *State) Set(key string, value interface{}) {
-	s.writing()
+	s.writingEntry("data", key)
 	s.data.set(key, value)
 }
 
@@ -307,17 +358,21 @@ func (s *State) Cache(key, value interface{}) {
 
 // NewChange adds a new change to the state.
 func (s *State) NewChange(kind, summary string) *Change {
-	s.writing()
+	s.writingEntry("", "last-change-id")
 	s.lastChangeId++
 	id := strconv.Itoa(s.lastChangeId)
+	s.markDirty("changes", id)
 	chg := newChange(s, id, kind, summary)
 	s.changes[id] = chg
+	// a change starts with no tasks so it is in HoldStatus
+	chg.lastObservedStatus = HoldStatus
+	chg.addNotice()
 	return chg
 }
 
 // NewLane creates a new lane in the state.
 func (s *State) NewLane() int {
-	s.writing()
+	s.writingEntry("", "last-lane-id")
 	s.lastLaneId++
 	return s.lastLaneId
 }
@@ -342,9 +397,10 @@ func (s *State) Change(id string) *Change {
 // It usually will be registered with a Change using AddTask or
 // through a TaskSet.
 func (s *State) NewTask(kind, summary string) *Task {
-	s.writing()
+	s.writingEntry("", "last-task-id")
 	s.lastTaskId++
 	id := strconv.Itoa(s.lastTaskId)
+	s.markDirty("tasks", id)
 	t := newTask(s, id, kind, summary)
 	s.tasks[id] = t
 	return t
@@ -405,7 +461,7 @@ func (s *State) RegisterPendingChangeByAttr(attr string, f func(*Change) bool) {
 //     changes than the limit set via "maxReadyChanges" those changes in ready
 //     state will also removed even if they are below the pruneWait duration.
 //
-//   - it removes expired warnings.
+//   - it removes expired warnings and notices.
 func (s *State) Prune(startOfOperation time.Time, pruneWait, abortWait time.Duration, maxReadyChanges int) {
 	now := time.Now()
 	pruneLimit := now.Add(-pruneWait)
@@ -429,10 +485,13 @@ func (s *State) Prune(startOfOperation time.Time, pruneWait, abortWait time.Dura
 
 	for k, w := range s.warnings {
 		if w.ExpiredBefore(now) {
+			s.markDirty("", "warnings")
 			delete(s.warnings, k)
 		}
 	}
 
+	s.pruneNotices(now)
+
 NextChange:
 	for _, chg := range changes {
 		readyTime := chg.ReadyTime()
@@ -443,6 +502,7 @@ NextChange:
 		if readyTime.IsZero() {
 			if spawnTime.Before(pruneLimit) && len(chg.Tasks()) == 0 {
 				chg.Abort()
+				s.markDirty("changes", chg.ID())
 				delete(s.changes, chg.ID())
 			} else if spawnTime.Before(abortLimit) {
 				for attr, pending := range s.pendingChangeByAttr {
@@ -456,8 +516,9 @@ NextChange:
 		}
 		// change old or we have too many changes
 		if readyTime.Before(pruneLimit) || readyChangesCount > maxReadyChanges {
-			s.writing()
+			s.writingEntry("changes", chg.ID())
 			for _, t := range chg.Tasks() {
+				s.markDirty("tasks", t.ID())
 				delete(s.tasks, t.ID())
 			}
 			delete(s.changes, chg.ID())
@@ -468,7 +529,7 @@ NextChange:
 	for tid, t := range s.tasks {
 		// TODO: this could be done more aggressively
 		if t.Change() == nil && t.SpawnTime().Before(pruneLimit) {
-			s.writing()
+			s.writingEntry("tasks", tid)
 			delete(s.tasks, tid)
 		}
 	}
@@ -501,5 +562,8 @@ func ReadState(backend Backend, r io.Reader) (*State, error) {
 	s.modified = false
 	s.cache = make(map[interface{}]interface{})
 	s.pendingChangeByAttr = make(map[string]func(*Change) bool)
+	s.noticeCond = sync.NewCond(s)
+	s.changeCond = sync.NewCond(s)
+	s.initDirty()
 	return s, err
 }
@@ -156,6 +156,8 @@ func (s *State) flattenWarnings() []*Warning {
 		}
 		flat = append(flat, w)
 	}
+	// keep the order stable
+	sort.Slice(flat, func(i, j int) bool { return flat[i].message < flat[j].message })
 	return flat
 }
 
@@ -192,7 +194,7 @@ func (s *State) Warnf(template string, args ...interface{}) {
 }
 
 func (s *State) addWarning(w Warning, t time.Time) {
-	s.writing()
+	s.writingEntry("", "warnings")
 
 	if s.warnings[w.message] == nil {
 		w.firstAdded = t
@@ -226,7 +228,7 @@ func (s *State) AllWarnings() []*Warning {
 // OkayWarnings marks warnings that were showable at the given time as shown.
 func (s *State) OkayWarnings(t time.Time) int {
 	t = t.UTC()
-	s.writing()
+	s.writingEntry("", "warnings")
 
 	n := 0
 	for _, w := range s.warnings {
@@ -285,7 +287,7 @@ func (s *State) WarningsSummary() (int, time.Time) {
 // UnshowAllWarnings clears the lastShown timestamp from all the
 // warnings. For use in debugging.
 func (s *State) UnshowAllWarnings() {
-	s.writing()
+	s.writingEntry("", "warnings")
 	for _, w := range s.warnings {
 		w.lastShown = time.Time{}
 	}
@@ -0,0 +1,225 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package listener receives AppArmor prompting notifications from the kernel
+// and turns them into requests which can be replied to.
+package listener
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/sandbox/apparmor/notify"
+)
+
+var (
+	// ErrNotSupported is returned by Register if the kernel doesn't support
+	// prompting notifications.
+	ErrNotSupported = errors.New("kernel does not support apparmor notifications")
+	// ErrAlreadyReplied is returned when replying to a request twice.
+	ErrAlreadyReplied = errors.New("request already replied to")
+)
+
+var (
+	notifySupportAvailable = notify.SupportAvailable
+	notifyOpenConn         = notify.OpenConn
+)
+
+// Request is an access request for a file received from the kernel.
+type Request struct {
+	// Pid is the process performing the access.
+	Pid uint32
+	// Label is the AppArmor label of the process.
+	Label string
+	// SubjectUID is the user ID of the process.
+	SubjectUID uint32
+	// Path is the path of the file being accessed.
+	Path string
+	// Permission holds the requested permissions which need a decision.
+	Permission notify.FilePermission
+
+	msg      notify.MsgNotification
+	listener *Listener
+
+	replyMu sync.Mutex
+	replied bool
+}
+
+// Reply sends the decision on the request to the kernel, either allowing or
+// denying all the requested permissions.
+func (r *Request) Reply(allow bool) error {
+	r.replyMu.Lock()
+	defer r.replyMu.Unlock()
+
+	if r.replied {
+		return ErrAlreadyReplied
+	}
+
+	var err error
+	if allow {
+		err = r.listener.sendReply(&r.msg, uint32(r.Permission), 0)
+	} else {
+		err = r.listener.sendReply(&r.msg, 0, uint32(r.Permission))
+	}
+	if err != nil {
+		return err
+	}
+
+	r.replied = true
+	return nil
+}
+
+// Listener receives the prompting notifications of the kernel.
+type Listener struct {
+	conn notify.Conn
+	reqs chan *Request
+
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+// Register opens a connection to the kernel and registers for the
+// notifications meant for userspace prompting.
+func Register() (*Listener, error) {
+	if !notifySupportAvailable() {
+		return nil, ErrNotSupported
+	}
+
+	conn, err := notifyOpenConn()
+	if err != nil {
+		return nil, err
+	}
+
+	filter := notify.MsgNotificationFilter{ModeSet: notify.ModeSetUser}
+	data, err := filter.MarshalBinary()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if err := conn.SetFilter(data); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("cannot register for apparmor notifications: %v", err)
+	}
+
+	return &Listener{
+		conn:   conn,
+		reqs:   make(chan *Request),
+		closed: make(chan struct{}),
+	}, nil
+}
+
+// Reqs returns the channel over which the received requests are delivered.
+// It is closed when Run returns.
+func (l *Listener) Reqs() <-chan *Request {
+	return l.reqs
+}
+
+// Run receives notifications from the kernel until the listener is closed,
+// delivering the access requests over the Reqs channel.
+func (l *Listener) Run() error {
+	defer close(l.reqs)
+
+	for {
+		data, err := l.conn.Receive()
+		if err != nil {
+			if errors.Is(err, notify.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+
+		req, err := l.decode(data)
+		if err != nil {
+			logger.Noticef("cannot handle apparmor notification: %v", err)
+			continue
+		}
+		if req == nil {
+			continue
+		}
+
+		select {
+		case l.reqs <- req:
+		case <-l.closed:
+			return nil
+		}
+	}
+}
+
+// decode returns the request carried by the notification, or nil if the
+// notification isn't an access request that needs a reply.
+func (l *Listener) decode(data []byte) (*Request, error) {
+	var msg notify.MsgNotification
+	if err := msg.UnmarshalBinary(data); err != nil {
+		return nil, err
+	}
+	if msg.NotificationType != notify.NotificationOperation {
+		logger.Debugf("ignoring apparmor notification of type %d", msg.NotificationType)
+		return nil, nil
+	}
+
+	var op notify.MsgNotificationOp
+	if err := op.UnmarshalBinary(data); err != nil {
+		return nil, err
+	}
+	if op.Class != notify.MediationClassFile {
+		// the process is blocked until a reply is sent
+		logger.Noticef("denying apparmor notification of unsupported mediation class %d", op.Class)
+		return nil, l.sendReply(&op.MsgNotification, 0, op.Deny)
+	}
+
+	var file notify.MsgNotificationFile
+	if err := file.UnmarshalBinary(data); err != nil {
+		return nil, err
+	}
+
+	return &Request{
+		Pid:        file.Pid,
+		Label:      file.Label,
+		SubjectUID: file.SUID,
+		Path:       file.Name,
+		Permission: notify.FilePermission(file.Deny),
+
+		msg:      file.MsgNotification,
+		listener: l,
+	}, nil
+}
+
+func (l *Listener) sendReply(msg *notify.MsgNotification, allow, deny uint32) error {
+	resp := notify.ResponseForRequest(msg, allow, deny)
+	data, err := resp.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	if err := l.conn.Send(data); err != nil {
+		return fmt.Errorf("cannot send reply to the kernel: %v", err)
+	}
+	return nil
+}
+
+// Close closes the connection to the kernel, which stops Run.
+func (l *Listener) Close() error {
+	err := notify.ErrClosed
+	l.closeOnce.Do(func() {
+		close(l.closed)
+		err = l.conn.Close()
+	})
+	return err
+}
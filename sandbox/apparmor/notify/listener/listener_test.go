@@ -0,0 +1,244 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package listener_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/sandbox/apparmor/notify"
+	"github.com/snapcore/snapd/sandbox/apparmor/notify/listener"
+	"github.com/snapcore/snapd/testutil"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type listenerSuite struct {
+	testutil.BaseTest
+
+	conn *fakeConn
+}
+
+var _ = Suite(&listenerSuite{})
+
+// fakeConn fakes the kernel side of the notification interface.
+type fakeConn struct {
+	filter []byte
+	recv   chan []byte
+	sent   chan []byte
+	closed chan struct{}
+
+	setFilterErr error
+}
+
+func newFakeConn() *fakeConn {
+	return &fakeConn{
+		recv:   make(chan []byte),
+		sent:   make(chan []byte, 10),
+		closed: make(chan struct{}),
+	}
+}
+
+func (c *fakeConn) SetFilter(msg []byte) error {
+	c.filter = msg
+	return c.setFilterErr
+}
+
+func (c *fakeConn) Receive() ([]byte, error) {
+	select {
+	case data := <-c.recv:
+		return data, nil
+	case <-c.closed:
+		return nil, notify.ErrClosed
+	}
+}
+
+func (c *fakeConn) Send(msg []byte) error {
+	c.sent <- msg
+	return nil
+}
+
+func (c *fakeConn) Close() error {
+	close(c.closed)
+	return nil
+}
+
+func (s *listenerSuite) SetUpTest(c *C) {
+	s.BaseTest.SetUpTest(c)
+
+	s.conn = newFakeConn()
+	s.AddCleanup(listener.MockNotifySupportAvailable(true))
+	s.AddCleanup(listener.MockNotifyOpenConn(func() (notify.Conn, error) {
+		return s.conn, nil
+	}))
+}
+
+func fileNotification(c *C, id uint64, class notify.MediationClass, perm notify.FilePermission) []byte {
+	msg := notify.MsgNotificationFile{
+		MsgNotificationOp: notify.MsgNotificationOp{
+			MsgNotification: notify.MsgNotification{
+				NotificationType: notify.NotificationOperation,
+				ID:               id,
+			},
+			Deny:  uint32(perm),
+			Pid:   1234,
+			Label: "snap.foo.app",
+			Class: class,
+		},
+		SUID: 1000,
+		OUID: 1000,
+		Name: "/home/test/Documents/file.txt",
+	}
+	data, err := msg.MarshalBinary()
+	c.Assert(err, IsNil)
+	return data
+}
+
+func (s *listenerSuite) waitResponse(c *C) *notify.MsgNotificationResponse {
+	select {
+	case data := <-s.conn.sent:
+		var resp notify.MsgNotificationResponse
+		c.Assert(resp.UnmarshalBinary(data), IsNil)
+		return &resp
+	case <-time.After(5 * time.Second):
+		c.Fatal("timeout waiting for the response")
+	}
+	return nil
+}
+
+func (s *listenerSuite) TestRegisterNotSupported(c *C) {
+	restore := listener.MockNotifySupportAvailable(false)
+	defer restore()
+
+	_, err := listener.Register()
+	c.Assert(err, Equals, listener.ErrNotSupported)
+}
+
+func (s *listenerSuite) TestRegisterErrors(c *C) {
+	restore := listener.MockNotifyOpenConn(func() (notify.Conn, error) {
+		return nil, errors.New("boom")
+	})
+	_, err := listener.Register()
+	c.Assert(err, ErrorMatches, "boom")
+	restore()
+
+	s.conn.setFilterErr = errors.New("filter error")
+	_, err = listener.Register()
+	c.Assert(err, ErrorMatches, "cannot register for apparmor notifications: filter error")
+	// the connection was closed
+	_, err = s.conn.Receive()
+	c.Check(err, Equals, notify.ErrClosed)
+}
+
+func (s *listenerSuite) TestRunRequestAndReply(c *C) {
+	l, err := listener.Register()
+	c.Assert(err, IsNil)
+
+	var filter notify.MsgHeader
+	c.Assert(filter.UnmarshalBinary(s.conn.filter), IsNil)
+
+	runErr := make(chan error, 1)
+	go func() { runErr <- l.Run() }()
+
+	for i, allow := range []bool{true, false} {
+		id := uint64(i + 1)
+		s.conn.recv <- fileNotification(c, id, notify.MediationClassFile, notify.AA_MAY_READ|notify.AA_MAY_WRITE)
+
+		req := <-l.Reqs()
+		c.Check(req.Pid, Equals, uint32(1234))
+		c.Check(req.Label, Equals, "snap.foo.app")
+		c.Check(req.SubjectUID, Equals, uint32(1000))
+		c.Check(req.Path, Equals, "/home/test/Documents/file.txt")
+		c.Check(req.Permission, Equals, notify.AA_MAY_READ|notify.AA_MAY_WRITE)
+
+		c.Assert(req.Reply(allow), IsNil)
+		resp := s.waitResponse(c)
+		c.Check(resp.NotificationType, Equals, notify.NotificationResponse)
+		c.Check(resp.ID, Equals, id)
+		if allow {
+			c.Check(resp.Allow, Equals, uint32(notify.AA_MAY_READ|notify.AA_MAY_WRITE))
+			c.Check(resp.Deny, Equals, uint32(0))
+		} else {
+			c.Check(resp.Allow, Equals, uint32(0))
+			c.Check(resp.Deny, Equals, uint32(notify.AA_MAY_READ|notify.AA_MAY_WRITE))
+		}
+
+		c.Check(req.Reply(allow), Equals, listener.ErrAlreadyReplied)
+	}
+
+	c.Assert(l.Close(), IsNil)
+	c.Assert(<-runErr, IsNil)
+	_, ok := <-l.Reqs()
+	c.Check(ok, Equals, false)
+
+	c.Check(l.Close(), Equals, notify.ErrClosed)
+}
+
+func (s *listenerSuite) TestRunIgnoresOtherNotifications(c *C) {
+	logbuf, restore := logger.MockLogger()
+	defer restore()
+
+	l, err := listener.Register()
+	c.Assert(err, IsNil)
+
+	runErr := make(chan error, 1)
+	go func() { runErr <- l.Run() }()
+
+	// not an operation
+	alive, err := notify.ResponseForRequest(&notify.MsgNotification{}, 0, 0).MarshalBinary()
+	c.Assert(err, IsNil)
+	s.conn.recv <- alive
+
+	// garbage
+	s.conn.recv <- []byte{1, 2, 3}
+
+	// an unsupported mediation class is denied
+	s.conn.recv <- fileNotification(c, 42, notify.MediationClass(99), notify.AA_MAY_READ)
+	resp := s.waitResponse(c)
+	c.Check(resp.ID, Equals, uint64(42))
+	c.Check(resp.Deny, Equals, uint32(notify.AA_MAY_READ))
+
+	s.conn.recv <- fileNotification(c, 43, notify.MediationClassFile, notify.AA_MAY_READ)
+	req := <-l.Reqs()
+	c.Check(req.Path, Equals, "/home/test/Documents/file.txt")
+
+	c.Assert(l.Close(), IsNil)
+	c.Assert(<-runErr, IsNil)
+
+	c.Check(logbuf.String(), testutil.Contains, "cannot handle apparmor notification: cannot parse message header: message is too short (3 bytes)")
+	c.Check(logbuf.String(), testutil.Contains, "denying apparmor notification of unsupported mediation class 99")
+}
+
+func (s *listenerSuite) TestCloseWhileDelivering(c *C) {
+	l, err := listener.Register()
+	c.Assert(err, IsNil)
+
+	runErr := make(chan error, 1)
+	go func() { runErr <- l.Run() }()
+
+	// nobody reads the request
+	s.conn.recv <- fileNotification(c, 1, notify.MediationClassFile, notify.AA_MAY_READ)
+	c.Assert(l.Close(), IsNil)
+	c.Assert(<-runErr, IsNil)
+}
@@ -0,0 +1,368 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package notify
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"unsafe"
+)
+
+// ProtocolVersion is the version of the notification protocol implemented
+// by this package.
+const ProtocolVersion = 2
+
+// NotificationType is the type of a notification message.
+type NotificationType uint16
+
+const (
+	NotificationResponse NotificationType = iota
+	NotificationCancel
+	NotificationInterrupt
+	NotificationAlive
+	NotificationOperation
+)
+
+// MediationClass is the AppArmor class of the operation behind a
+// notification.
+type MediationClass uint16
+
+const (
+	MediationClassFile MediationClass = 2
+)
+
+// ModeSet selects the kinds of notifications the kernel sends.
+type ModeSet uint32
+
+const (
+	ModeSetAudit ModeSet = 1 << iota
+	ModeSetAllowed
+	ModeSetEnforce
+	ModeSetHint
+	ModeSetStatus
+	ModeSetError
+	ModeSetKill
+	ModeSetUser
+)
+
+// nativeEndian is the byte order of the kernel messages.
+var nativeEndian binary.ByteOrder
+
+func init() {
+	x := uint16(1)
+	if *(*byte)(unsafe.Pointer(&x)) == 1 {
+		nativeEndian = binary.LittleEndian
+	} else {
+		nativeEndian = binary.BigEndian
+	}
+}
+
+// Messages are packed C structures, strings are stored after the fixed
+// size part of the message and referenced by their offset from the start of
+// the message, a zero offset denoting an empty string.
+
+// MsgHeader is the header of all notification messages.
+type MsgHeader struct {
+	// Length is the length of the whole message, including the header.
+	Length  uint16
+	Version uint16
+}
+
+const sizeofMsgHeader = 4
+
+func (msg *MsgHeader) encode(buf []byte) {
+	nativeEndian.PutUint16(buf[0:], msg.Length)
+	nativeEndian.PutUint16(buf[2:], msg.Version)
+}
+
+// UnmarshalBinary decodes the header of a message, checking that it matches
+// the data.
+func (msg *MsgHeader) UnmarshalBinary(data []byte) error {
+	if len(data) < sizeofMsgHeader {
+		return fmt.Errorf("cannot parse message header: message is too short (%d bytes)", len(data))
+	}
+	msg.Length = nativeEndian.Uint16(data[0:])
+	msg.Version = nativeEndian.Uint16(data[2:])
+	if msg.Version != ProtocolVersion {
+		return fmt.Errorf("cannot parse message header: unsupported protocol version %d", msg.Version)
+	}
+	if int(msg.Length) != len(data) {
+		return fmt.Errorf("cannot parse message header: length %d does not match message size %d", msg.Length, len(data))
+	}
+	return nil
+}
+
+// MsgNotificationFilter is the message setting which notifications are sent
+// by the kernel.
+type MsgNotificationFilter struct {
+	MsgHeader
+	ModeSet ModeSet
+	// NameSpace restricts notifications to the given AppArmor namespace.
+	NameSpace string
+}
+
+// wire layout: header, modeset uint32, namespace offset uint32, filter
+// offset uint32
+const sizeofMsgNotificationFilter = sizeofMsgHeader + 12
+
+// MarshalBinary encodes the filter message.
+func (msg *MsgNotificationFilter) MarshalBinary() ([]byte, error) {
+	p := newPacker(sizeofMsgNotificationFilter)
+	nsOffset := p.packString(msg.NameSpace)
+	nativeEndian.PutUint32(p.buf[4:], uint32(msg.ModeSet))
+	nativeEndian.PutUint32(p.buf[8:], nsOffset)
+	// no DFA filter, all notifications matching the mode set are sent
+	nativeEndian.PutUint32(p.buf[12:], 0)
+	return p.finish()
+}
+
+// MsgNotification is the common part of all notifications and responses.
+type MsgNotification struct {
+	MsgHeader
+	NotificationType NotificationType
+	Signalled        uint8
+	Reserved         uint8
+	// ID identifies the notification for the kernel.
+	ID    uint64
+	Error int32
+}
+
+// wire layout: header, type uint16, signalled uint8, reserved uint8, id
+// uint64, error int32
+const sizeofMsgNotification = sizeofMsgHeader + 16
+
+func (msg *MsgNotification) encode(buf []byte) {
+	nativeEndian.PutUint16(buf[4:], uint16(msg.NotificationType))
+	buf[6] = msg.Signalled
+	buf[7] = msg.Reserved
+	nativeEndian.PutUint64(buf[8:], msg.ID)
+	nativeEndian.PutUint32(buf[16:], uint32(msg.Error))
+}
+
+// UnmarshalBinary decodes the common part of a notification.
+func (msg *MsgNotification) UnmarshalBinary(data []byte) error {
+	if err := msg.MsgHeader.UnmarshalBinary(data); err != nil {
+		return err
+	}
+	if len(data) < sizeofMsgNotification {
+		return fmt.Errorf("cannot parse notification: message is too short (%d bytes)", len(data))
+	}
+	msg.NotificationType = NotificationType(nativeEndian.Uint16(data[4:]))
+	msg.Signalled = data[6]
+	msg.Reserved = data[7]
+	msg.ID = nativeEndian.Uint64(data[8:])
+	msg.Error = int32(nativeEndian.Uint32(data[16:]))
+	return nil
+}
+
+// MsgNotificationResponse is the response to a notification, telling the
+// kernel which of the requested permissions are allowed and denied.
+type MsgNotificationResponse struct {
+	MsgNotification
+	Error int32
+	Allow uint32
+	Deny  uint32
+}
+
+// wire layout: notification, error int32, allow uint32, deny uint32
+const sizeofMsgNotificationResponse = sizeofMsgNotification + 12
+
+// ResponseForRequest returns a response to the given notification allowing
+// and denying the given permissions.
+func ResponseForRequest(req *MsgNotification, allow, deny uint32) *MsgNotificationResponse {
+	return &MsgNotificationResponse{
+		MsgNotification: MsgNotification{
+			NotificationType: NotificationResponse,
+			ID:               req.ID,
+			Error:            req.Error,
+		},
+		Allow: allow,
+		Deny:  deny,
+	}
+}
+
+// MarshalBinary encodes the response message.
+func (msg *MsgNotificationResponse) MarshalBinary() ([]byte, error) {
+	p := newPacker(sizeofMsgNotificationResponse)
+	msg.MsgNotification.encode(p.buf)
+	nativeEndian.PutUint32(p.buf[20:], uint32(msg.Error))
+	nativeEndian.PutUint32(p.buf[24:], msg.Allow)
+	nativeEndian.PutUint32(p.buf[28:], msg.Deny)
+	return p.finish()
+}
+
+// UnmarshalBinary decodes a response message.
+func (msg *MsgNotificationResponse) UnmarshalBinary(data []byte) error {
+	if err := msg.MsgNotification.UnmarshalBinary(data); err != nil {
+		return err
+	}
+	if len(data) < sizeofMsgNotificationResponse {
+		return fmt.Errorf("cannot parse response: message is too short (%d bytes)", len(data))
+	}
+	msg.Error = int32(nativeEndian.Uint32(data[20:]))
+	msg.Allow = nativeEndian.Uint32(data[24:])
+	msg.Deny = nativeEndian.Uint32(data[28:])
+	return nil
+}
+
+// MsgNotificationOp is the common part of the notifications about an
+// operation performed by a confined process.
+type MsgNotificationOp struct {
+	MsgNotification
+	// Allow holds the permissions already allowed by the policy.
+	Allow uint32
+	// Deny holds the requested permissions which need a decision.
+	Deny uint32
+	// Pid is the process performing the operation.
+	Pid uint32
+	// Label is the AppArmor label of the process.
+	Label string
+	Class MediationClass
+	Op    uint16
+}
+
+// wire layout: notification, allow uint32, deny uint32, pid uint32, label
+// offset uint32, class uint16, op uint16
+const sizeofMsgNotificationOp = sizeofMsgNotification + 20
+
+func (msg *MsgNotificationOp) encode(p *packer) {
+	labelOffset := p.packString(msg.Label)
+	msg.MsgNotification.encode(p.buf)
+	nativeEndian.PutUint32(p.buf[20:], msg.Allow)
+	nativeEndian.PutUint32(p.buf[24:], msg.Deny)
+	nativeEndian.PutUint32(p.buf[28:], msg.Pid)
+	nativeEndian.PutUint32(p.buf[32:], labelOffset)
+	nativeEndian.PutUint16(p.buf[36:], uint16(msg.Class))
+	nativeEndian.PutUint16(p.buf[38:], msg.Op)
+}
+
+// UnmarshalBinary decodes the common part of an operation notification.
+func (msg *MsgNotificationOp) UnmarshalBinary(data []byte) error {
+	if err := msg.MsgNotification.UnmarshalBinary(data); err != nil {
+		return err
+	}
+	if len(data) < sizeofMsgNotificationOp {
+		return fmt.Errorf("cannot parse operation notification: message is too short (%d bytes)", len(data))
+	}
+	msg.Allow = nativeEndian.Uint32(data[20:])
+	msg.Deny = nativeEndian.Uint32(data[24:])
+	msg.Pid = nativeEndian.Uint32(data[28:])
+	label, err := unpackString(data, nativeEndian.Uint32(data[32:]))
+	if err != nil {
+		return fmt.Errorf("cannot parse operation notification label: %v", err)
+	}
+	msg.Label = label
+	msg.Class = MediationClass(nativeEndian.Uint16(data[36:]))
+	msg.Op = nativeEndian.Uint16(data[38:])
+	return nil
+}
+
+// MsgNotificationFile is the notification about an operation on a file.
+type MsgNotificationFile struct {
+	MsgNotificationOp
+	// SUID is the user ID of the process performing the operation.
+	SUID uint32
+	// OUID is the user ID of the owner of the file.
+	OUID uint32
+	// Name is the path of the file.
+	Name string
+}
+
+// wire layout: operation notification, suid uint32, ouid uint32, name
+// offset uint32
+const sizeofMsgNotificationFile = sizeofMsgNotificationOp + 12
+
+// MarshalBinary encodes the file notification.
+func (msg *MsgNotificationFile) MarshalBinary() ([]byte, error) {
+	p := newPacker(sizeofMsgNotificationFile)
+	msg.MsgNotificationOp.encode(p)
+	nameOffset := p.packString(msg.Name)
+	nativeEndian.PutUint32(p.buf[40:], msg.SUID)
+	nativeEndian.PutUint32(p.buf[44:], msg.OUID)
+	nativeEndian.PutUint32(p.buf[48:], nameOffset)
+	return p.finish()
+}
+
+// UnmarshalBinary decodes a file notification.
+func (msg *MsgNotificationFile) UnmarshalBinary(data []byte) error {
+	if err := msg.MsgNotificationOp.UnmarshalBinary(data); err != nil {
+		return err
+	}
+	if len(data) < sizeofMsgNotificationFile {
+		return fmt.Errorf("cannot parse file notification: message is too short (%d bytes)", len(data))
+	}
+	msg.SUID = nativeEndian.Uint32(data[40:])
+	msg.OUID = nativeEndian.Uint32(data[44:])
+	name, err := unpackString(data, nativeEndian.Uint32(data[48:]))
+	if err != nil {
+		return fmt.Errorf("cannot parse file notification name: %v", err)
+	}
+	msg.Name = name
+	return nil
+}
+
+// packer builds a message made of a fixed size part followed by the strings
+// it references.
+type packer struct {
+	buf []byte
+}
+
+func newPacker(fixedSize int) *packer {
+	return &packer{buf: make([]byte, fixedSize)}
+}
+
+// packString appends the NUL-terminated string to the message and returns
+// its offset. As the buffer may be reallocated, the offset must be obtained
+// before writing into the buffer.
+func (p *packer) packString(s string) uint32 {
+	if s == "" {
+		return 0
+	}
+	offset := len(p.buf)
+	p.buf = append(p.buf, s...)
+	p.buf = append(p.buf, 0)
+	return uint32(offset)
+}
+
+// finish writes the header of the message and returns it.
+func (p *packer) finish() ([]byte, error) {
+	if len(p.buf) > maxMessageSize {
+		return nil, fmt.Errorf("cannot marshal message: too long (%d bytes)", len(p.buf))
+	}
+	header := MsgHeader{Length: uint16(len(p.buf)), Version: ProtocolVersion}
+	header.encode(p.buf)
+	return p.buf, nil
+}
+
+func unpackString(data []byte, offset uint32) (string, error) {
+	if offset == 0 {
+		return "", nil
+	}
+	if int(offset) >= len(data) {
+		return "", fmt.Errorf("offset %d is out of bounds", offset)
+	}
+	end := bytes.IndexByte(data[offset:], 0)
+	if end == -1 {
+		return "", errors.New("string is not NUL-terminated")
+	}
+	return string(data[offset : int(offset)+end]), nil
+}
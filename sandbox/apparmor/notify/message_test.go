@@ -0,0 +1,140 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package notify_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/sandbox/apparmor/notify"
+)
+
+type messageSuite struct{}
+
+var _ = Suite(&messageSuite{})
+
+func (s *messageSuite) TestFileRoundTrip(c *C) {
+	msg := notify.MsgNotificationFile{
+		MsgNotificationOp: notify.MsgNotificationOp{
+			MsgNotification: notify.MsgNotification{
+				NotificationType: notify.NotificationOperation,
+				ID:               1234,
+			},
+			Allow: uint32(notify.AA_MAY_OPEN),
+			Deny:  uint32(notify.AA_MAY_READ | notify.AA_MAY_WRITE),
+			Pid:   42,
+			Label: "snap.foo.app",
+			Class: notify.MediationClassFile,
+		},
+		SUID: 1000,
+		OUID: 1000,
+		Name: "/home/test/file.txt",
+	}
+
+	data, err := msg.MarshalBinary()
+	c.Assert(err, IsNil)
+	// fixed part, followed by the NUL-terminated strings
+	c.Check(data, HasLen, 52+len("snap.foo.app")+1+len("/home/test/file.txt")+1)
+
+	var decoded notify.MsgNotificationFile
+	c.Assert(decoded.UnmarshalBinary(data), IsNil)
+	msg.MsgHeader = notify.MsgHeader{Length: uint16(len(data)), Version: notify.ProtocolVersion}
+	c.Check(decoded, DeepEquals, msg)
+
+	// the common part can be decoded on its own
+	var common notify.MsgNotification
+	c.Assert(common.UnmarshalBinary(data), IsNil)
+	c.Check(common.NotificationType, Equals, notify.NotificationOperation)
+	c.Check(common.ID, Equals, uint64(1234))
+}
+
+func (s *messageSuite) TestResponse(c *C) {
+	req := notify.MsgNotification{
+		NotificationType: notify.NotificationOperation,
+		ID:               1234,
+	}
+	resp := notify.ResponseForRequest(&req, uint32(notify.AA_MAY_READ), uint32(notify.AA_MAY_WRITE))
+
+	data, err := resp.MarshalBinary()
+	c.Assert(err, IsNil)
+	c.Check(data, HasLen, 32)
+
+	var decoded notify.MsgNotificationResponse
+	c.Assert(decoded.UnmarshalBinary(data), IsNil)
+	c.Check(decoded.MsgHeader, Equals, notify.MsgHeader{Length: 32, Version: notify.ProtocolVersion})
+	c.Check(decoded.NotificationType, Equals, notify.NotificationResponse)
+	c.Check(decoded.ID, Equals, uint64(1234))
+	c.Check(decoded.Allow, Equals, uint32(notify.AA_MAY_READ))
+	c.Check(decoded.Deny, Equals, uint32(notify.AA_MAY_WRITE))
+}
+
+func (s *messageSuite) TestFilter(c *C) {
+	filter := notify.MsgNotificationFilter{ModeSet: notify.ModeSetUser, NameSpace: "ns"}
+	data, err := filter.MarshalBinary()
+	c.Assert(err, IsNil)
+
+	c.Assert(data, HasLen, 16+len("ns")+1)
+	var header notify.MsgHeader
+	c.Assert(header.UnmarshalBinary(data), IsNil)
+	c.Check(header.Length, Equals, uint16(19))
+	c.Check((*notify.NativeEndian).Uint32(data[4:]), Equals, uint32(notify.ModeSetUser))
+	c.Check((*notify.NativeEndian).Uint32(data[8:]), Equals, uint32(16))
+	c.Check(string(data[16:]), Equals, "ns\x00")
+}
+
+func (s *messageSuite) TestUnmarshalErrors(c *C) {
+	valid, err := (&notify.MsgNotificationFile{Name: "/foo"}).MarshalBinary()
+	c.Assert(err, IsNil)
+
+	withVersion := func(version uint16) []byte {
+		data := append([]byte(nil), valid...)
+		(*notify.NativeEndian).PutUint16(data[2:], version)
+		return data
+	}
+	withNameOffset := func(offset uint32) []byte {
+		data := append([]byte(nil), valid...)
+		(*notify.NativeEndian).PutUint32(data[48:], offset)
+		return data
+	}
+
+	for _, t := range []struct {
+		data []byte
+		err  string
+	}{
+		{[]byte{1, 0}, `cannot parse message header: message is too short \(2 bytes\)`},
+		{withVersion(1), `cannot parse message header: unsupported protocol version 1`},
+		{append(valid, 0), `cannot parse message header: length 57 does not match message size 58`},
+		{withNameOffset(100), `cannot parse file notification name: offset 100 is out of bounds`},
+		{valid[:len(valid)-1], `cannot parse message header: length 57 does not match message size 56`},
+	} {
+		var msg notify.MsgNotificationFile
+		c.Check(msg.UnmarshalBinary(t.data), ErrorMatches, t.err)
+	}
+
+	// a message too short for its type
+	short, err := (&notify.MsgNotificationResponse{}).MarshalBinary()
+	c.Assert(err, IsNil)
+	var msg notify.MsgNotificationFile
+	c.Check(msg.UnmarshalBinary(short), ErrorMatches, `cannot parse operation notification: message is too short \(32 bytes\)`)
+
+	// a string which isn't terminated
+	unterminated := append([]byte(nil), valid...)
+	unterminated[len(unterminated)-1] = 'x'
+	c.Check(msg.UnmarshalBinary(unterminated), ErrorMatches, `cannot parse file notification name: string is not NUL-terminated`)
+}
@@ -0,0 +1,199 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package notify implements the kernel side of AppArmor prompting: the
+// notification interface through which the kernel asks userspace to decide
+// on accesses which are not covered by the loaded policy.
+package notify
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
+)
+
+// SysPath is the path of the file through which prompting notifications are
+// received from the kernel.
+var SysPath string
+
+func setupSysPath(newrootdir string) {
+	SysPath = filepath.Join(newrootdir, "/sys/kernel/security/apparmor/.notify")
+}
+
+func init() {
+	dirs.AddRootDirCallback(setupSysPath)
+	setupSysPath(dirs.GlobalRootDir)
+}
+
+// SupportAvailable returns whether the running kernel supports AppArmor
+// prompting notifications.
+func SupportAvailable() bool {
+	return osutil.FileExists(SysPath)
+}
+
+// ioctlRequest is an ioctl request understood by the notification file
+// descriptor.
+type ioctlRequest uintptr
+
+// newIoctlRequest computes the request number the same way as the _IOC macro
+// of the kernel, all requests take a pointer to a buffer as argument.
+func newIoctlRequest(dir, nr uintptr) ioctlRequest {
+	const (
+		ioctlType = 0xF8
+		argSize   = 8
+	)
+	return ioctlRequest(dir<<30 | argSize<<16 | ioctlType<<8 | nr)
+}
+
+const (
+	ioctlDirWrite = 1
+	ioctlDirRead  = 2
+)
+
+var (
+	ioctlSetFilter = newIoctlRequest(ioctlDirWrite, 0)
+	ioctlRecv      = newIoctlRequest(ioctlDirRead|ioctlDirWrite, 4)
+	ioctlSend      = newIoctlRequest(ioctlDirRead|ioctlDirWrite, 5)
+)
+
+// ErrClosed is returned when using a connection that has been closed.
+var ErrClosed = errors.New("notification connection closed")
+
+// Conn is a connection to the kernel notification interface, exchanging
+// binary messages encoded as described in message.go.
+type Conn interface {
+	// SetFilter sets which notifications the kernel sends over the
+	// connection.
+	SetFilter(msg []byte) error
+	// Receive blocks until a notification is received from the kernel and
+	// returns it. ErrClosed is returned once the connection is closed.
+	Receive() ([]byte, error)
+	// Send sends a message, typically a response, to the kernel.
+	Send(msg []byte) error
+	// Close closes the connection, unblocking any pending Receive.
+	Close() error
+}
+
+// fdConn is a connection over the notification file descriptor.
+type fdConn struct {
+	file *os.File
+	// the read end of the pipe is polled together with the notification
+	// file descriptor so that Close can unblock Receive
+	wakeRead, wakeWrite *os.File
+
+	closeOnce sync.Once
+}
+
+// OpenConn opens a connection to the kernel notification interface.
+func OpenConn() (Conn, error) {
+	file, err := os.OpenFile(SysPath, os.O_RDWR|unix.O_NONBLOCK|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open %q: %v", SysPath, err)
+	}
+
+	wakeRead, wakeWrite, err := os.Pipe()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return &fdConn{file: file, wakeRead: wakeRead, wakeWrite: wakeWrite}, nil
+}
+
+func (c *fdConn) ioctl(req ioctlRequest, buf []byte) (int, error) {
+	n, _, errno := unix.Syscall(unix.SYS_IOCTL, c.file.Fd(), uintptr(req), uintptr(unsafe.Pointer(&buf[0])))
+	if errno != 0 {
+		return 0, errno
+	}
+	return int(n), nil
+}
+
+func (c *fdConn) SetFilter(msg []byte) error {
+	_, err := c.ioctl(ioctlSetFilter, msg)
+	return err
+}
+
+// maxMessageSize is the size of the buffer passed to the kernel to receive
+// notifications.
+const maxMessageSize = 0xFFFF
+
+func (c *fdConn) Receive() ([]byte, error) {
+	for {
+		fds := []unix.PollFd{
+			{Fd: int32(c.file.Fd()), Events: unix.POLLIN},
+			{Fd: int32(c.wakeRead.Fd()), Events: unix.POLLIN},
+		}
+		if _, err := unix.Poll(fds, -1); err != nil {
+			if err == unix.EINTR {
+				continue
+			}
+			return nil, err
+		}
+		if fds[1].Revents != 0 {
+			return nil, ErrClosed
+		}
+		if fds[0].Revents&(unix.POLLHUP|unix.POLLERR) != 0 {
+			return nil, fmt.Errorf("cannot receive notification: unexpected poll events %#x", fds[0].Revents)
+		}
+
+		// the kernel expects the buffer to start with a header telling
+		// its size
+		buf := make([]byte, maxMessageSize)
+		header := MsgHeader{Length: maxMessageSize, Version: ProtocolVersion}
+		header.encode(buf)
+
+		n, err := c.ioctl(ioctlRecv, buf)
+		if err != nil {
+			if err == unix.EAGAIN || err == unix.EINTR {
+				continue
+			}
+			return nil, err
+		}
+		return buf[:n], nil
+	}
+}
+
+func (c *fdConn) Send(msg []byte) error {
+	_, err := c.ioctl(ioctlSend, msg)
+	return err
+}
+
+func (c *fdConn) Close() error {
+	var err error
+	closed := false
+	c.closeOnce.Do(func() {
+		closed = true
+		// wake up any pending Receive before closing the file descriptor
+		c.wakeWrite.Close()
+		c.wakeRead.Close()
+		err = c.file.Close()
+	})
+	if !closed {
+		return ErrClosed
+	}
+	return err
+}
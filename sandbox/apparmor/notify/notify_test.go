@@ -0,0 +1,64 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package notify_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/sandbox/apparmor/notify"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type notifySuite struct{}
+
+var _ = Suite(&notifySuite{})
+
+func (s *notifySuite) TearDownTest(c *C) {
+	dirs.SetRootDir("/")
+}
+
+func (s *notifySuite) TestSysPath(c *C) {
+	c.Check(notify.SysPath, Equals, "/sys/kernel/security/apparmor/.notify")
+
+	root := c.MkDir()
+	dirs.SetRootDir(root)
+	c.Check(notify.SysPath, Equals, filepath.Join(root, "/sys/kernel/security/apparmor/.notify"))
+}
+
+func (s *notifySuite) TestSupportAvailable(c *C) {
+	dirs.SetRootDir(c.MkDir())
+	c.Check(notify.SupportAvailable(), Equals, false)
+
+	c.Assert(os.MkdirAll(filepath.Dir(notify.SysPath), 0755), IsNil)
+	c.Assert(os.WriteFile(notify.SysPath, nil, 0644), IsNil)
+	c.Check(notify.SupportAvailable(), Equals, true)
+}
+
+func (s *notifySuite) TestOpenConnError(c *C) {
+	dirs.SetRootDir(c.MkDir())
+	_, err := notify.OpenConn()
+	c.Check(err, ErrorMatches, `cannot open ".*/sys/kernel/security/apparmor/.notify": .* no such file or directory`)
+}
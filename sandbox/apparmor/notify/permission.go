@@ -0,0 +1,106 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package notify
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FilePermission is a mask of the permissions of a file operation, as used
+// in the Allow and Deny fields of notifications about files.
+type FilePermission uint32
+
+const (
+	AA_MAY_EXEC FilePermission = 1 << iota
+	AA_MAY_WRITE
+	AA_MAY_READ
+	AA_MAY_APPEND
+	AA_MAY_CREATE
+	AA_MAY_DELETE
+	AA_MAY_OPEN
+	AA_MAY_RENAME
+	AA_MAY_SETATTR
+	AA_MAY_GETATTR
+	AA_MAY_SETCRED
+	AA_MAY_GETCRED
+	AA_MAY_CHMOD
+	AA_MAY_CHOWN
+	AA_MAY_CHGRP
+	AA_MAY_LOCK
+	AA_EXEC_MMAP
+	AA_MAY_LINK FilePermission = 1 << 18
+
+	AA_MAY_ONEXEC         FilePermission = 1 << 29
+	AA_MAY_CHANGE_PROFILE FilePermission = 1 << 30
+)
+
+var filePermissionNames = []struct {
+	perm FilePermission
+	name string
+}{
+	{AA_MAY_EXEC, "exec"},
+	{AA_MAY_WRITE, "write"},
+	{AA_MAY_READ, "read"},
+	{AA_MAY_APPEND, "append"},
+	{AA_MAY_CREATE, "create"},
+	{AA_MAY_DELETE, "delete"},
+	{AA_MAY_OPEN, "open"},
+	{AA_MAY_RENAME, "rename"},
+	{AA_MAY_SETATTR, "set-attr"},
+	{AA_MAY_GETATTR, "get-attr"},
+	{AA_MAY_SETCRED, "set-cred"},
+	{AA_MAY_GETCRED, "get-cred"},
+	{AA_MAY_CHMOD, "chmod"},
+	{AA_MAY_CHOWN, "chown"},
+	{AA_MAY_CHGRP, "chgrp"},
+	{AA_MAY_LOCK, "lock"},
+	{AA_EXEC_MMAP, "exec-mmap"},
+	{AA_MAY_LINK, "link"},
+	{AA_MAY_ONEXEC, "on-exec"},
+	{AA_MAY_CHANGE_PROFILE, "change-profile"},
+}
+
+// IsValid returns whether the mask only holds known permissions.
+func (p FilePermission) IsValid() bool {
+	for _, fp := range filePermissionNames {
+		p &^= fp.perm
+	}
+	return p == 0
+}
+
+// String returns the names of the permissions in the mask separated by "|".
+func (p FilePermission) String() string {
+	if p == 0 {
+		return "none"
+	}
+
+	var names []string
+	for _, fp := range filePermissionNames {
+		if p&fp.perm != 0 {
+			names = append(names, fp.name)
+			p &^= fp.perm
+		}
+	}
+	if p != 0 {
+		names = append(names, fmt.Sprintf("%#x", uint32(p)))
+	}
+	return strings.Join(names, "|")
+}
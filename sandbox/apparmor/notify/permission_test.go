@@ -0,0 +1,52 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package notify_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/sandbox/apparmor/notify"
+)
+
+type permissionSuite struct{}
+
+var _ = Suite(&permissionSuite{})
+
+func (s *permissionSuite) TestString(c *C) {
+	for _, t := range []struct {
+		perm notify.FilePermission
+		str  string
+	}{
+		{0, "none"},
+		{notify.AA_MAY_READ, "read"},
+		{notify.AA_MAY_EXEC | notify.AA_MAY_WRITE | notify.AA_MAY_READ, "exec|write|read"},
+		{notify.AA_MAY_LINK | notify.AA_MAY_CHANGE_PROFILE, "link|change-profile"},
+		{notify.AA_MAY_OPEN | 1<<20, "open|0x100000"},
+	} {
+		c.Check(t.perm.String(), Equals, t.str)
+	}
+}
+
+func (s *permissionSuite) TestIsValid(c *C) {
+	c.Check(notify.FilePermission(0).IsValid(), Equals, true)
+	c.Check((notify.AA_MAY_READ | notify.AA_MAY_ONEXEC).IsValid(), Equals, true)
+	c.Check(notify.FilePermission(1<<20).IsValid(), Equals, false)
+	c.Check((notify.AA_MAY_READ | 1<<31).IsValid(), Equals, false)
+}
@@ -0,0 +1,211 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snap
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/snap/naming"
+	"github.com/snapcore/snapd/strutil"
+)
+
+// ComponentType is the type of a snap component.
+type ComponentType string
+
+const (
+	// TestComponent is a component meant for testing only.
+	TestComponent ComponentType = "test"
+	// KernelModulesComponent is a component holding kernel modules.
+	KernelModulesComponent ComponentType = "kernel-modules"
+)
+
+var validComponentTypes = []string{string(TestComponent), string(KernelModulesComponent)}
+
+// ValidateComponentType checks that the given component type is known.
+func ValidateComponentType(typ ComponentType) error {
+	if !strutil.ListContains(validComponentTypes, string(typ)) {
+		return fmt.Errorf("unknown component type %q", typ)
+	}
+	return nil
+}
+
+// Component holds the definition of a component as declared in the
+// snap.yaml of the snap owning it.
+type Component struct {
+	Name        string
+	Type        ComponentType
+	Summary     string
+	Description string
+}
+
+// ComponentSideInfo holds the information about a component revision that
+// is not part of the component.yaml.
+type ComponentSideInfo struct {
+	Component naming.ComponentRef `json:"component"`
+	Revision  Revision            `json:"revision"`
+}
+
+// NewComponentSideInfo creates a new ComponentSideInfo.
+func NewComponentSideInfo(cref naming.ComponentRef, rev Revision) *ComponentSideInfo {
+	return &ComponentSideInfo{
+		Component: cref,
+		Revision:  rev,
+	}
+}
+
+// Equal compares two ComponentSideInfo.
+func (csi *ComponentSideInfo) Equal(other *ComponentSideInfo) bool {
+	return *csi == *other
+}
+
+// ComponentInfo holds the information of a component revision, as read
+// from its meta/component.yaml.
+type ComponentInfo struct {
+	Component   naming.ComponentRef `yaml:"component"`
+	Type        ComponentType       `yaml:"type"`
+	Version     string              `yaml:"version"`
+	Summary     string              `yaml:"summary"`
+	Description string              `yaml:"description"`
+
+	ComponentSideInfo
+}
+
+// FullName returns the full name of the component, in the form
+// <snap>+<component>.
+func (ci *ComponentInfo) FullName() string {
+	return ci.Component.String()
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler, the "component" key holds the
+// full component name.
+func (ci *ComponentInfo) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var y struct {
+		Component   string        `yaml:"component"`
+		Type        ComponentType `yaml:"type"`
+		Version     string        `yaml:"version"`
+		Summary     string        `yaml:"summary"`
+		Description string        `yaml:"description"`
+	}
+	if err := unmarshal(&y); err != nil {
+		return err
+	}
+	snapName, compName, err := naming.SplitFullComponentName(y.Component)
+	if err != nil {
+		return err
+	}
+	*ci = ComponentInfo{
+		Component:   naming.NewComponentRef(snapName, compName),
+		Type:        y.Type,
+		Version:     y.Version,
+		Summary:     y.Summary,
+		Description: y.Description,
+	}
+	return nil
+}
+
+// InfoFromComponentYaml parses a ComponentInfo from the raw yaml data.
+func InfoFromComponentYaml(compYaml []byte) (*ComponentInfo, error) {
+	var ci ComponentInfo
+	if err := yaml.UnmarshalStrict(compYaml, &ci); err != nil {
+		return nil, fmt.Errorf("cannot parse component.yaml: %s", err)
+	}
+	if err := ci.validate(); err != nil {
+		return nil, err
+	}
+	return &ci, nil
+}
+
+// ReadComponentInfoFromContainer reads the ComponentInfo of a component
+// container, and checks that it matches the component declared by the
+// snap.yaml of the owning snap if snapInfo is not nil.
+func ReadComponentInfoFromContainer(compf Container, snapInfo *Info, csi *ComponentSideInfo) (*ComponentInfo, error) {
+	yamlData, err := compf.ReadFile("meta/component.yaml")
+	if err != nil {
+		return nil, err
+	}
+
+	ci, err := InfoFromComponentYaml(yamlData)
+	if err != nil {
+		return nil, err
+	}
+	if csi != nil {
+		if csi.Component != ci.Component {
+			return nil, fmt.Errorf("component %q does not match the expected %q", ci.FullName(), csi.Component)
+		}
+		ci.ComponentSideInfo = *csi
+	}
+
+	if snapInfo != nil {
+		if ci.Component.SnapName != snapInfo.SnapName() {
+			return nil, fmt.Errorf("component %q is not a component of snap %q", ci.FullName(), snapInfo.SnapName())
+		}
+		comp, ok := snapInfo.Components[ci.Component.ComponentName]
+		if !ok {
+			return nil, fmt.Errorf("component %q is not declared by snap %q", ci.FullName(), snapInfo.SnapName())
+		}
+		if comp.Type != ci.Type {
+			return nil, fmt.Errorf("inconsistent component type (%q in snap, %q in component)", comp.Type, ci.Type)
+		}
+	}
+
+	return ci, nil
+}
+
+func (ci *ComponentInfo) validate() error {
+	if err := ci.Component.Validate(); err != nil {
+		return err
+	}
+	if err := ValidateComponentType(ci.Type); err != nil {
+		return err
+	}
+	if err := ValidateVersion(ci.Version); err != nil {
+		return err
+	}
+	return validateDescription(ci.Description)
+}
+
+// ComponentMountDir returns the directory where the given revision of a
+// component of a snap instance is mounted.
+func ComponentMountDir(instanceName, compName string, compRev Revision) string {
+	return filepath.Join(BaseDir(instanceName), "components", "mnt", compName, compRev.String())
+}
+
+// ComponentMountFile returns the path of the file of the given revision of
+// a component of a snap instance.
+func ComponentMountFile(instanceName, compName string, compRev Revision) string {
+	return filepath.Join(dirs.SnapBlobDir, fmt.Sprintf("%s+%s_%s.comp", instanceName, compName, compRev))
+}
+
+// ComponentLinkPath returns the path of the symlink, in the tree of the
+// given snap revision, to the mount directory of the component revision
+// used by that snap revision.
+func ComponentLinkPath(instanceName string, snapRev Revision, compName string) string {
+	return filepath.Join(ComponentsDir(instanceName, snapRev), compName)
+}
+
+// ComponentsDir returns the directory holding the links to the components
+// used by the given snap revision.
+func ComponentsDir(instanceName string, snapRev Revision) string {
+	return filepath.Join(BaseDir(instanceName), "components", snapRev.String())
+}
@@ -0,0 +1,154 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snap_test
+
+import (
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/naming"
+	"github.com/snapcore/snapd/snap/snapdir"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type componentSuite struct {
+	testutil.BaseTest
+}
+
+var _ = Suite(&componentSuite{})
+
+func (s *componentSuite) SetUpTest(c *C) {
+	s.BaseTest.SetUpTest(c)
+	dirs.SetRootDir(c.MkDir())
+	s.AddCleanup(func() { dirs.SetRootDir("") })
+	s.AddCleanup(snap.MockSanitizePlugsSlots(func(snapInfo *snap.Info) {}))
+}
+
+const snapWithComponentsYaml = `name: mysnap
+version: 1
+components:
+  test-info:
+    type: test
+  kmods:
+    type: kernel-modules
+`
+
+func mockComponentContainer(c *C, compYaml string) snap.Container {
+	d := c.MkDir()
+	c.Assert(os.MkdirAll(filepath.Join(d, "meta"), 0755), IsNil)
+	c.Assert(os.WriteFile(filepath.Join(d, "meta", "component.yaml"), []byte(compYaml), 0644), IsNil)
+	return snapdir.New(d)
+}
+
+func (s *componentSuite) TestInfoFromComponentYaml(c *C) {
+	ci, err := snap.InfoFromComponentYaml([]byte(`component: mysnap+test-info
+type: test
+version: 1.0
+summary: short
+description: long
+`))
+	c.Assert(err, IsNil)
+	c.Check(ci, DeepEquals, &snap.ComponentInfo{
+		Component:   naming.NewComponentRef("mysnap", "test-info"),
+		Type:        snap.TestComponent,
+		Version:     "1.0",
+		Summary:     "short",
+		Description: "long",
+	})
+	c.Check(ci.FullName(), Equals, "mysnap+test-info")
+}
+
+func (s *componentSuite) TestInfoFromComponentYamlErrors(c *C) {
+	for _, tc := range []struct {
+		yaml, err string
+	}{
+		{"component: mysnap\ntype: test\nversion: 1\n", `cannot parse component.yaml: incorrect component name "mysnap"`},
+		{"component: mysnap+Comp\ntype: test\nversion: 1\n", `invalid component name: "Comp"`},
+		{"component: mysnap+comp\ntype: foo\nversion: 1\n", `unknown component type "foo"`},
+		{"component: mysnap+comp\ntype: test\n", `invalid snap version: cannot be empty`},
+		{"component: mysnap+comp\ntype: test\nversion: 1\nfoo: bar\n", `(?s)cannot parse component.yaml: .*field foo not found.*`},
+	} {
+		_, err := snap.InfoFromComponentYaml([]byte(tc.yaml))
+		c.Check(err, ErrorMatches, tc.err)
+	}
+}
+
+func (s *componentSuite) TestReadComponentInfoFromContainer(c *C) {
+	snapInfo, err := snap.InfoFromSnapYaml([]byte(snapWithComponentsYaml))
+	c.Assert(err, IsNil)
+
+	compf := mockComponentContainer(c, "component: mysnap+test-info\ntype: test\nversion: 1.0\n")
+	csi := snap.NewComponentSideInfo(naming.NewComponentRef("mysnap", "test-info"), snap.R(3))
+	ci, err := snap.ReadComponentInfoFromContainer(compf, snapInfo, csi)
+	c.Assert(err, IsNil)
+	c.Check(ci.FullName(), Equals, "mysnap+test-info")
+	c.Check(ci.Type, Equals, snap.TestComponent)
+	c.Check(ci.Revision, Equals, snap.R(3))
+
+	// the side info is optional
+	ci, err = snap.ReadComponentInfoFromContainer(compf, snapInfo, nil)
+	c.Assert(err, IsNil)
+	c.Check(ci.Revision, Equals, snap.R(0))
+}
+
+func (s *componentSuite) TestReadComponentInfoFromContainerMismatch(c *C) {
+	snapInfo, err := snap.InfoFromSnapYaml([]byte(snapWithComponentsYaml))
+	c.Assert(err, IsNil)
+
+	for _, tc := range []struct {
+		yaml string
+		csi  *snap.ComponentSideInfo
+		err  string
+	}{
+		{"component: othersnap+test-info\ntype: test\nversion: 1\n", nil, `component "othersnap\+test-info" is not a component of snap "mysnap"`},
+		{"component: mysnap+other\ntype: test\nversion: 1\n", nil, `component "mysnap\+other" is not declared by snap "mysnap"`},
+		{"component: mysnap+kmods\ntype: test\nversion: 1\n", nil, `inconsistent component type \("kernel-modules" in snap, "test" in component\)`},
+		{"component: mysnap+kmods\ntype: kernel-modules\nversion: 1\n", snap.NewComponentSideInfo(naming.NewComponentRef("mysnap", "test-info"), snap.R(1)), `component "mysnap\+kmods" does not match the expected "mysnap\+test-info"`},
+	} {
+		compf := mockComponentContainer(c, tc.yaml)
+		_, err := snap.ReadComponentInfoFromContainer(compf, snapInfo, tc.csi)
+		c.Check(err, ErrorMatches, tc.err)
+	}
+
+	_, err = snap.ReadComponentInfoFromContainer(snapdir.New(c.MkDir()), snapInfo, nil)
+	c.Check(err, ErrorMatches, `.*meta/component.yaml: no such file or directory`)
+}
+
+func (s *componentSuite) TestComponentPaths(c *C) {
+	c.Check(snap.ComponentMountDir("mysnap_inst", "comp", snap.R(3)), Equals,
+		filepath.Join(dirs.SnapMountDir, "mysnap_inst/components/mnt/comp/3"))
+	c.Check(snap.ComponentMountFile("mysnap_inst", "comp", snap.R(3)), Equals,
+		filepath.Join(dirs.SnapBlobDir, "mysnap_inst+comp_3.comp"))
+	c.Check(snap.ComponentsDir("mysnap", snap.R(11)), Equals,
+		filepath.Join(dirs.SnapMountDir, "mysnap/components/11"))
+	c.Check(snap.ComponentLinkPath("mysnap", snap.R(11), "comp"), Equals,
+		filepath.Join(dirs.SnapMountDir, "mysnap/components/11/comp"))
+}
+
+func (s *componentSuite) TestComponentSideInfoEqual(c *C) {
+	csi := snap.NewComponentSideInfo(naming.NewComponentRef("mysnap", "comp"), snap.R(1))
+	c.Check(csi.Equal(snap.NewComponentSideInfo(naming.NewComponentRef("mysnap", "comp"), snap.R(1))), Equals, true)
+	c.Check(csi.Equal(snap.NewComponentSideInfo(naming.NewComponentRef("mysnap", "comp"), snap.R(2))), Equals, false)
+	c.Check(csi.Equal(snap.NewComponentSideInfo(naming.NewComponentRef("mysnap", "other"), snap.R(1))), Equals, false)
+}
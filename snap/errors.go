@@ -43,6 +43,18 @@ func (e NotInstalledError) Error() string {
 	return fmt.Sprintf("revision %s of snap %q is not installed", e.Rev, e.Snap)
 }
 
+// ComponentNotInstalledError is returned if an operation is attempted on
+// a component that is not installed for the given snap revision.
+type ComponentNotInstalledError struct {
+	Snap      string
+	Component string
+	Rev       Revision
+}
+
+func (e ComponentNotInstalledError) Error() string {
+	return fmt.Sprintf("component %q is not installed for revision %s of snap %q", e.Component, e.Rev, e.Snap)
+}
+
 // NotSnapError is returned if an operation expects a snap file or snap dir
 // but no valid input is provided. When creating it ensure "Err" is set
 // so that a useful error can be displayed to the user.
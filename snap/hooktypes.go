@@ -31,6 +31,7 @@ var supportedHooks = []*HookType{
 	NewHookType(regexp.MustCompile("^install$")),
 	NewHookType(regexp.MustCompile("^pre-refresh$")),
 	NewHookType(regexp.MustCompile("^post-refresh$")),
+	NewHookType(regexp.MustCompile("^post-restore$")),
 	NewHookType(regexp.MustCompile("^remove$")),
 	NewHookType(regexp.MustCompile("^prepare-(?:plug|slot)-[-a-z0-9]+$")),
 	NewHookType(regexp.MustCompile("^unprepare-(?:plug|slot)-[-a-z0-9]+$")),
@@ -39,6 +40,9 @@ var supportedHooks = []*HookType{
 	NewHookType(regexp.MustCompile("^check-health$")),
 	NewHookType(regexp.MustCompile("^fde-setup$")),
 	NewHookType(regexp.MustCompile("^gate-auto-refresh$")),
+	NewHookType(regexp.MustCompile("^change-view-[-a-z0-9]+$")),
+	NewHookType(regexp.MustCompile("^save-view-[-a-z0-9]+$")),
+	NewHookType(regexp.MustCompile("^observe-view-[-a-z0-9]+$")),
 }
 
 // HookType represents a pattern of supported hook names.
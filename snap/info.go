@@ -363,6 +363,9 @@ type Info struct {
 
 	// Categories this snap is in.
 	Categories []CategoryInfo
+
+	// Components declared by the snap, indexed by name.
+	Components map[string]*Component
 }
 
 // StoreAccount holds information about a store account, for example of snap
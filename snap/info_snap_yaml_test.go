@@ -1990,6 +1990,33 @@ system-usernames:
 	})
 }
 
+func (s *YamlSuite) TestSnapYamlComponentsParsing(c *C) {
+	y := []byte(`name: foo
+version: 1.0
+components:
+  comp1:
+    type: test
+    summary: test component
+    description: long description
+  comp2:
+    type: kernel-modules
+`)
+	info, err := snap.InfoFromSnapYaml(y)
+	c.Assert(err, IsNil)
+	c.Check(info.Components, DeepEquals, map[string]*snap.Component{
+		"comp1": {
+			Name:        "comp1",
+			Type:        snap.TestComponent,
+			Summary:     "test component",
+			Description: "long description",
+		},
+		"comp2": {
+			Name: "comp2",
+			Type: snap.KernelModulesComponent,
+		},
+	})
+}
+
 func (s *YamlSuite) TestSnapYamlSystemUsernamesParsingBadType(c *C) {
 	y := []byte(`name: binary
 version: 1.0
@@ -21,11 +21,15 @@ package integrity
 
 import (
 	"bytes"
+	"crypto"
+	"encoding/binary"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 
+	"github.com/snapcore/snapd/asserts"
 	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/snap/integrity/dmverity"
 )
@@ -41,8 +45,19 @@ const (
 var (
 	// magic is the magic prefix of snap extension blocks.
 	magic = []byte{'s', 'n', 'a', 'p', 'e', 'x', 't'}
+
+	// squashfsMagic is the magic prefix of a squashfs superblock.
+	squashfsMagic = []byte{'h', 's', 'q', 's'}
 )
 
+// squashfsBytesUsedOffset is the offset of the field of the squashfs
+// superblock holding the size of the filesystem image.
+const squashfsBytesUsedOffset = 40
+
+// ErrNoIntegrityDataFound is returned when a snap file has no integrity data
+// appended to it.
+var ErrNoIntegrityDataFound = errors.New("no integrity data found")
+
 // align aligns input `size` to closest `blockSize` value
 func align(size uint64) uint64 {
 	return (size + blockSize - 1) / blockSize * blockSize
@@ -161,3 +176,106 @@ func GenerateAndAppend(snapPath string) (err error) {
 
 	return err
 }
+
+// IntegrityData holds the integrity data found appended to a snap file.
+type IntegrityData struct {
+	Header IntegrityDataHeader
+	// Offset is the offset in the snap file of the integrity data header.
+	Offset uint64
+	// SHA3_384 is the digest of the integrity data, header included.
+	SHA3_384 string
+}
+
+// HashOffset returns the offset in the snap file of the dm-verity hash data.
+func (integrityData *IntegrityData) HashOffset() uint64 {
+	return integrityData.Offset + HeaderSize
+}
+
+// Validate checks that the integrity data match the digest and size
+// recorded for them in the snap-revision assertion.
+func (integrityData *IntegrityData) Validate(snapIntegrity *asserts.SnapIntegrity) error {
+	if snapIntegrity == nil {
+		return fmt.Errorf("no integrity data expected by the snap revision")
+	}
+	if integrityData.Header.Size != snapIntegrity.Size {
+		return fmt.Errorf("integrity data size %d does not match the expected size %d", integrityData.Header.Size, snapIntegrity.Size)
+	}
+	if integrityData.SHA3_384 != snapIntegrity.SHA3_384 {
+		return fmt.Errorf("integrity data digest does not match the expected digest")
+	}
+	return nil
+}
+
+// squashfsSize returns the size of the squashfs image at the start of the
+// given snap file, as recorded in its superblock.
+func squashfsSize(snapFile *os.File) (uint64, error) {
+	superblock := make([]byte, squashfsBytesUsedOffset+8)
+	if _, err := snapFile.ReadAt(superblock, 0); err != nil {
+		return 0, fmt.Errorf("cannot read squashfs superblock: %v", err)
+	}
+	if !bytes.HasPrefix(superblock, squashfsMagic) {
+		return 0, fmt.Errorf("cannot read squashfs superblock: invalid magic value")
+	}
+	return binary.LittleEndian.Uint64(superblock[squashfsBytesUsedOffset:]), nil
+}
+
+// FindIntegrityData looks for integrity data appended to the snap file at
+// snapPath. Integrity data are expected to start right after the squashfs
+// image, aligned to blockSize. ErrNoIntegrityDataFound is returned if the
+// snap carries none.
+func FindIntegrityData(snapPath string) (*IntegrityData, error) {
+	snapFile, err := os.Open(snapPath)
+	if err != nil {
+		return nil, err
+	}
+	defer snapFile.Close()
+
+	fi, err := snapFile.Stat()
+	if err != nil {
+		return nil, err
+	}
+	fileSize := uint64(fi.Size())
+
+	size, err := squashfsSize(snapFile)
+	if err != nil {
+		return nil, err
+	}
+	offset := align(size)
+	if offset+HeaderSize > fileSize {
+		return nil, ErrNoIntegrityDataFound
+	}
+
+	header := make([]byte, HeaderSize)
+	if _, err := snapFile.ReadAt(header, int64(offset)); err != nil {
+		return nil, fmt.Errorf("cannot read integrity data header: %v", err)
+	}
+	if !bytes.HasPrefix(header, magic) {
+		return nil, ErrNoIntegrityDataFound
+	}
+
+	var integrityDataHeader IntegrityDataHeader
+	if err := integrityDataHeader.Decode(header); err != nil {
+		return nil, err
+	}
+	if integrityDataHeader.Type != "integrity" {
+		return nil, fmt.Errorf("invalid integrity data header: unexpected type %q", integrityDataHeader.Type)
+	}
+	if integrityDataHeader.Size < HeaderSize || offset+integrityDataHeader.Size > fileSize {
+		return nil, fmt.Errorf("invalid integrity data header: wrong size")
+	}
+
+	h := crypto.SHA3_384.New()
+	if _, err := io.Copy(h, io.NewSectionReader(snapFile, int64(offset), int64(integrityDataHeader.Size))); err != nil {
+		return nil, fmt.Errorf("cannot compute integrity data digest: %v", err)
+	}
+	digest, err := asserts.EncodeDigest(crypto.SHA3_384, h.Sum(nil))
+	if err != nil {
+		return nil, err
+	}
+
+	return &IntegrityData{
+		Header:   integrityDataHeader,
+		Offset:   offset,
+		SHA3_384: digest,
+	}, nil
+}
@@ -20,15 +20,22 @@
 package integrity_test
 
 import (
+	"bytes"
+	"crypto"
+	"encoding/binary"
 	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
+	"golang.org/x/crypto/sha3"
 	. "gopkg.in/check.v1"
 
+	"github.com/snapcore/snapd/asserts"
 	"github.com/snapcore/snapd/snap/integrity"
 	"github.com/snapcore/snapd/snap/integrity/dmverity"
 	"github.com/snapcore/snapd/snap/snaptest"
@@ -273,3 +280,80 @@ esac
 	c.Check(vscmd.Calls()[0], DeepEquals, []string{"veritysetup", "--version"})
 	c.Check(vscmd.Calls()[1], DeepEquals, []string{"veritysetup", "format", snapPath, snapPath + ".verity"})
 }
+
+func makeMockSnapFile(c *C, squashfsSize uint64, extra []byte) string {
+	superblock := make([]byte, integrity.Align(squashfsSize))
+	copy(superblock, "hsqs")
+	binary.LittleEndian.PutUint64(superblock[40:], squashfsSize)
+
+	snapPath := filepath.Join(c.MkDir(), "foo_1.snap")
+	err := ioutil.WriteFile(snapPath, append(superblock, extra...), 0644)
+	c.Assert(err, IsNil)
+	return snapPath
+}
+
+func makeIntegrityData(c *C, rootHash string, hashData []byte) []byte {
+	integrityDataHeader := integrity.NewIntegrityDataHeader(&dmverity.Info{RootHash: rootHash}, uint64(len(hashData)))
+	header, err := integrityDataHeader.Encode()
+	c.Assert(err, IsNil)
+	return append(header, hashData...)
+}
+
+func (s *IntegrityTestSuite) TestFindIntegrityData(c *C) {
+	rootHash := strings.Repeat("a", 64)
+	integrityData := makeIntegrityData(c, rootHash, bytes.Repeat([]byte{1}, 4096))
+	snapPath := makeMockSnapFile(c, 5000, integrityData)
+
+	found, err := integrity.FindIntegrityData(snapPath)
+	c.Assert(err, IsNil)
+	c.Check(found.Header.Type, Equals, "integrity")
+	c.Check(found.Header.Size, Equals, uint64(2*4096))
+	c.Check(found.Header.DmVerity.RootHash, Equals, rootHash)
+	c.Check(found.Offset, Equals, uint64(8192))
+	c.Check(found.HashOffset(), Equals, uint64(12288))
+
+	digest := sha3.Sum384(integrityData)
+	expectedDigest, err := asserts.EncodeDigest(crypto.SHA3_384, digest[:])
+	c.Assert(err, IsNil)
+	c.Check(found.SHA3_384, Equals, expectedDigest)
+
+	c.Check(found.Validate(&asserts.SnapIntegrity{SHA3_384: expectedDigest, Size: 8192}), IsNil)
+}
+
+func (s *IntegrityTestSuite) TestFindIntegrityDataNotFound(c *C) {
+	// no data after the squashfs image
+	snapPath := makeMockSnapFile(c, 4096, nil)
+	_, err := integrity.FindIntegrityData(snapPath)
+	c.Check(err, Equals, integrity.ErrNoIntegrityDataFound)
+
+	// unknown data after the squashfs image
+	snapPath = makeMockSnapFile(c, 4096, make([]byte, 4096))
+	_, err = integrity.FindIntegrityData(snapPath)
+	c.Check(err, Equals, integrity.ErrNoIntegrityDataFound)
+}
+
+func (s *IntegrityTestSuite) TestFindIntegrityDataErrors(c *C) {
+	snapPath := filepath.Join(c.MkDir(), "foo_1.snap")
+	err := ioutil.WriteFile(snapPath, make([]byte, 4096), 0644)
+	c.Assert(err, IsNil)
+	_, err = integrity.FindIntegrityData(snapPath)
+	c.Check(err, ErrorMatches, "cannot read squashfs superblock: invalid magic value")
+
+	// integrity data claiming more than what is in the file
+	integrityData := makeIntegrityData(c, strings.Repeat("a", 64), make([]byte, 4096))
+	snapPath = makeMockSnapFile(c, 4096, integrityData[:4096])
+	_, err = integrity.FindIntegrityData(snapPath)
+	c.Check(err, ErrorMatches, "invalid integrity data header: wrong size")
+}
+
+func (s *IntegrityTestSuite) TestIntegrityDataValidate(c *C) {
+	integrityData := &integrity.IntegrityData{
+		Header:   integrity.IntegrityDataHeader{Type: "integrity", Size: 8192},
+		SHA3_384: "digest",
+	}
+
+	c.Check(integrityData.Validate(&asserts.SnapIntegrity{SHA3_384: "digest", Size: 8192}), IsNil)
+	c.Check(integrityData.Validate(nil), ErrorMatches, "no integrity data expected by the snap revision")
+	c.Check(integrityData.Validate(&asserts.SnapIntegrity{SHA3_384: "digest", Size: 4096}), ErrorMatches, "integrity data size 8192 does not match the expected size 4096")
+	c.Check(integrityData.Validate(&asserts.SnapIntegrity{SHA3_384: "other", Size: 8192}), ErrorMatches, "integrity data digest does not match the expected digest")
+}
@@ -0,0 +1,71 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package naming
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ComponentRef references a component of a snap by the snap and component
+// names.
+type ComponentRef struct {
+	SnapName      string `yaml:"snap-name" json:"snap-name"`
+	ComponentName string `yaml:"component-name" json:"component-name"`
+}
+
+// NewComponentRef returns a reference to the given component of the given
+// snap.
+func NewComponentRef(snapName, componentName string) ComponentRef {
+	return ComponentRef{SnapName: snapName, ComponentName: componentName}
+}
+
+// String returns the full name of the component, in the form
+// <snap>+<component>.
+func (cr ComponentRef) String() string {
+	return fmt.Sprintf("%s+%s", cr.SnapName, cr.ComponentName)
+}
+
+// Validate checks that both the snap and component names are valid.
+func (cr ComponentRef) Validate() error {
+	if err := ValidateSnap(cr.SnapName); err != nil {
+		return err
+	}
+	return ValidateComponent(cr.ComponentName)
+}
+
+// SplitFullComponentName splits <snap>+<component> into the snap and
+// component names.
+func SplitFullComponentName(fullComp string) (snapName, componentName string, err error) {
+	names := strings.Split(fullComp, "+")
+	if len(names) != 2 {
+		return "", "", fmt.Errorf("incorrect component name %q", fullComp)
+	}
+	return names[0], names[1], nil
+}
+
+// ValidateComponent checks if a string can be used as a component name.
+func ValidateComponent(name string) error {
+	// component names follow the same rules as snap names
+	if len(name) < 2 || len(name) > 40 || !isValidName(name) {
+		return fmt.Errorf("invalid component name: %q", name)
+	}
+	return nil
+}
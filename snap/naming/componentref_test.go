@@ -0,0 +1,62 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package naming_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/snap/naming"
+)
+
+type componentRefSuite struct{}
+
+var _ = Suite(&componentRefSuite{})
+
+func (s *componentRefSuite) TestComponentRef(c *C) {
+	ref := naming.NewComponentRef("foo", "bar")
+	c.Check(ref.SnapName, Equals, "foo")
+	c.Check(ref.ComponentName, Equals, "bar")
+	c.Check(ref.String(), Equals, "foo+bar")
+	c.Check(ref.Validate(), IsNil)
+
+	c.Check(naming.NewComponentRef("foo_", "bar").Validate(), ErrorMatches, `invalid snap name: "foo_"`)
+	c.Check(naming.NewComponentRef("foo", "bar-").Validate(), ErrorMatches, `invalid component name: "bar-"`)
+}
+
+func (s *componentRefSuite) TestSplitFullComponentName(c *C) {
+	snapName, compName, err := naming.SplitFullComponentName("foo+bar")
+	c.Assert(err, IsNil)
+	c.Check(snapName, Equals, "foo")
+	c.Check(compName, Equals, "bar")
+
+	for _, name := range []string{"foo", "foo+bar+baz", ""} {
+		_, _, err := naming.SplitFullComponentName(name)
+		c.Check(err, ErrorMatches, `incorrect component name ".*"`)
+	}
+}
+
+func (s *componentRefSuite) TestValidateComponent(c *C) {
+	for _, name := range []string{"ab", "comp", "comp-1", "1comp"} {
+		c.Check(naming.ValidateComponent(name), IsNil)
+	}
+	for _, name := range []string{"", "a", "Comp", "comp_1", "-comp", "comp--1", "123"} {
+		c.Check(naming.ValidateComponent(name), ErrorMatches, `invalid component name: ".*"`)
+	}
+}
@@ -68,3 +68,15 @@ func MockRuntimeNumCPU(mock func() int) (restore func()) {
 	runtimeNumCPU = mock
 	return r
 }
+
+func MockFsquotaCheckProjectQuota(mock func(path string) error) (restore func()) {
+	r := testutil.Backup(&fsquotaCheckProjectQuota)
+	fsquotaCheckProjectQuota = mock
+	return r
+}
+
+func MockFsquotaUsage(mock func(paths ...string) (uint64, error)) (restore func()) {
+	r := testutil.Backup(&fsquotaUsage)
+	fsquotaUsage = mock
+	return r
+}
@@ -32,13 +32,17 @@ import (
 	// TODO: move this to snap/quantity? or similar
 	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/gadget/quantity"
+	"github.com/snapcore/snapd/osutil/fsquota"
 	"github.com/snapcore/snapd/progress"
 	"github.com/snapcore/snapd/snap/naming"
 	"github.com/snapcore/snapd/systemd"
 )
 
 // export it for test
-var runtimeNumCPU = runtime.NumCPU
+var (
+	runtimeNumCPU = runtime.NumCPU
+	fsquotaUsage  = fsquota.Usage
+)
 
 // GroupQuotaCPU contains the different knobs that can be tuned
 // for cpu quota limits. The allowed CPU percentage to use is split across two limits
@@ -83,6 +87,25 @@ type GroupQuotaJournal struct {
 	RatePeriod time.Duration `json:"rate-period,omitempty"`
 }
 
+// GroupQuotaDisk contains the disk space limit of a quota group. The limit is
+// enforced with filesystem project quotas, the data directories of all snaps in
+// the group and its sub-groups are assigned to a project unique to the group.
+type GroupQuotaDisk struct {
+	// Size is the maximum total disk space allowed for the data directories
+	// ($SNAP_DATA and $SNAP_COMMON) of the snaps in the group.
+	Size quantity.Size `json:"size"`
+
+	// UserData tells whether the per-user data directories of the snaps
+	// in the group are subject to the limit as well. Note that project
+	// quotas are accounted per filesystem, so if home directories are on a
+	// separate filesystem the limit applies to each of them separately.
+	UserData bool `json:"user-data,omitempty"`
+
+	// ProjectID is the filesystem project ID assigned to the group, it is
+	// allocated by snapd when the disk limit is first set.
+	ProjectID uint32 `json:"project-id,omitempty"`
+}
+
 // Group is a quota group of snaps, services or sub-groups that are all subject
 // to specific resource quotas. The only quota resource types currently
 // supported is memory, but this can be expanded in the future.
@@ -124,6 +147,10 @@ type Group struct {
 	// journald.
 	JournalLimit *GroupQuotaJournal `json:"journal-limit,omitempty"`
 
+	// DiskLimit is the limit of disk space that can be used by the data
+	// directories of the snaps in the group and its sub-groups.
+	DiskLimit *GroupQuotaDisk `json:"disk-limit,omitempty"`
+
 	// ParentGroup is the the parent group that this group is a child of. If it
 	// is empty, then this is a "root" quota group.
 	ParentGroup string `json:"parent-group,omitempty"`
@@ -192,6 +219,12 @@ func (grp *Group) GetQuotaResources() Resources {
 			resourcesBuilder.WithJournalRate(grp.JournalLimit.RateCount, grp.JournalLimit.RatePeriod)
 		}
 	}
+	if grp.DiskLimit != nil {
+		resourcesBuilder.WithDiskLimit(grp.DiskLimit.Size)
+		if grp.DiskLimit.UserData {
+			resourcesBuilder.WithDiskUserData()
+		}
+	}
 	return resourcesBuilder.Build()
 }
 
@@ -244,6 +277,65 @@ func (grp *Group) CurrentTaskUsage() (int, error) {
 	return int(count), nil
 }
 
+// DiskQuotaGroup returns the group whose disk quota applies to the snaps in
+// this group, which is either the group itself or its closest parent with a
+// disk limit. If no disk quota applies, nil is returned.
+func (grp *Group) DiskQuotaGroup() *Group {
+	for g := grp; g != nil; g = g.parentGroup {
+		if g.DiskLimit != nil {
+			return g
+		}
+	}
+	return nil
+}
+
+// AllSnaps returns the snaps in the group and in all of its sub-groups.
+func (grp *Group) AllSnaps() []string {
+	snaps := append([]string(nil), grp.Snaps...)
+	for _, sub := range grp.subGroups {
+		snaps = append(snaps, sub.AllSnaps()...)
+	}
+	return snaps
+}
+
+// DiskQuotaDirs returns the data directories of the given snap that are
+// subject to the disk quota of the group. Per-user directories are only
+// included if the group disk limit covers user data, and only for users
+// that have used the snap.
+func (grp *Group) DiskQuotaDirs(snapName string) ([]string, error) {
+	paths := []string{filepath.Join(dirs.SnapDataDir, snapName)}
+	if grp.DiskLimit == nil || !grp.DiskLimit.UserData {
+		return paths, nil
+	}
+	for _, glob := range []string{dirs.SnapDataHomeGlob, dirs.HiddenSnapDataHomeGlob} {
+		userPaths, err := filepath.Glob(filepath.Join(glob, snapName))
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, userPaths...)
+	}
+	return paths, nil
+}
+
+// CurrentDiskUsage returns the disk space currently used by the data
+// directories of the snaps subject to the disk quota of the group.
+func (grp *Group) CurrentDiskUsage() (quantity.Size, error) {
+	var paths []string
+	for _, snapName := range grp.AllSnaps() {
+		snapPaths, err := grp.DiskQuotaDirs(snapName)
+		if err != nil {
+			return 0, err
+		}
+		paths = append(paths, snapPaths...)
+	}
+
+	usage, err := fsquotaUsage(paths...)
+	if err != nil {
+		return 0, err
+	}
+	return quantity.Size(usage), nil
+}
+
 // SliceFileName returns the name of the slice file that should be used for this
 // quota group. This name will include all of the group's parents in the name.
 // For example, a group named "bar" that is a child of the "foo" group will have
@@ -751,9 +843,38 @@ func (grp *Group) validateQuotasFit(resourceLimits Resources) error {
 			return err
 		}
 	}
+	if resourceLimits.Disk != nil {
+		if err := grp.validateDiskNesting(); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+// validateDiskNesting verifies that no parent or sub-group of the group has a
+// disk limit, as a snap data directory can only be assigned to a single
+// filesystem project and thus only be subject to a single disk limit.
+func (grp *Group) validateDiskNesting() error {
+	for parent := grp.parentGroup; parent != nil; parent = parent.parentGroup {
+		if parent.DiskLimit != nil {
+			return fmt.Errorf("cannot set disk limit on group %q: parent group %q already has a disk limit", grp.Name, parent.Name)
+		}
+	}
+	var checkSubGroups func(g *Group) error
+	checkSubGroups = func(g *Group) error {
+		for _, sub := range g.subGroups {
+			if sub.DiskLimit != nil {
+				return fmt.Errorf("cannot set disk limit on group %q: sub-group %q already has a disk limit", grp.Name, sub.Name)
+			}
+			if err := checkSubGroups(sub); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return checkSubGroups(grp)
+}
+
 // UpdateQuotaLimits updates all the quota limits set for the group to the new limits
 // given. The limits will be validated against the group's parent group's limits, to verify
 // that they fit. For instance, if the parent group has a memory limit of 1GB, and the new limit
@@ -799,6 +920,13 @@ func (grp *Group) UpdateQuotaLimits(resourceLimits Resources) error {
 			grp.JournalLimit.RatePeriod = resourceLimits.Journal.Rate.Period
 		}
 	}
+	if resourceLimits.Disk != nil {
+		if grp.DiskLimit == nil {
+			grp.DiskLimit = &GroupQuotaDisk{}
+		}
+		grp.DiskLimit.Size = resourceLimits.Disk.Limit
+		grp.DiskLimit.UserData = resourceLimits.Disk.UserData
+	}
 	return nil
 }
 
@@ -836,6 +964,12 @@ func (grp *Group) validate() error {
 	if len(grp.Services) > 0 && grp.JournalLimit != nil {
 		return fmt.Errorf("journal quota is not supported for individual services")
 	}
+
+	// Services do not have data directories of their own, the disk quota
+	// must be applied to the group of the snap instead.
+	if len(grp.Services) > 0 && grp.DiskLimit != nil {
+		return fmt.Errorf("disk quota is not supported for individual services")
+	}
 	return nil
 }
 
@@ -22,11 +22,15 @@ package quota_test
 import (
 	"fmt"
 	"math"
+	"os"
+	"path/filepath"
+	"sort"
 	"testing"
 	"time"
 
 	. "gopkg.in/check.v1"
 
+	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/gadget/quantity"
 	"github.com/snapcore/snapd/snap/quota"
 	"github.com/snapcore/snapd/systemd"
@@ -1569,6 +1573,126 @@ func (ts *quotaTestSuite) TestJournalQuotasUpdatesCorrectly(c *C) {
 	c.Check(grp1.JournalLimit.RatePeriod, Equals, time.Microsecond*5)
 }
 
+func (ts *quotaTestSuite) TestDiskQuotasSetCorrectly(c *C) {
+	grp, err := quota.NewGroup("groot", quota.NewResourcesBuilder().WithDiskLimit(quantity.SizeGiB).Build())
+	c.Assert(err, IsNil)
+	c.Assert(grp.DiskLimit, NotNil)
+	c.Check(grp.DiskLimit, DeepEquals, &quota.GroupQuotaDisk{Size: quantity.SizeGiB})
+
+	// the project ID is preserved when updating the limit
+	grp.DiskLimit.ProjectID = 0x100001
+	err = grp.UpdateQuotaLimits(quota.NewResourcesBuilder().WithDiskLimit(2 * quantity.SizeGiB).WithDiskUserData().Build())
+	c.Assert(err, IsNil)
+	c.Check(grp.DiskLimit, DeepEquals, &quota.GroupQuotaDisk{Size: 2 * quantity.SizeGiB, UserData: true, ProjectID: 0x100001})
+
+	c.Check(grp.GetQuotaResources(), DeepEquals, quota.NewResourcesBuilder().WithDiskLimit(2*quantity.SizeGiB).WithDiskUserData().Build())
+}
+
+func (ts *quotaTestSuite) TestDiskQuotasNestingNotSupported(c *C) {
+	parent, err := quota.NewGroup("parent", quota.NewResourcesBuilder().WithDiskLimit(quantity.SizeGiB).Build())
+	c.Assert(err, IsNil)
+
+	_, err = parent.NewSubGroup("sub", quota.NewResourcesBuilder().WithDiskLimit(quantity.SizeMiB).Build())
+	c.Check(err, ErrorMatches, `cannot set disk limit on group "sub": parent group "parent" already has a disk limit`)
+
+	// other limits are fine
+	sub, err := parent.NewSubGroup("sub", quota.NewResourcesBuilder().WithMemoryLimit(quantity.SizeMiB).Build())
+	c.Assert(err, IsNil)
+	c.Check(sub.DiskQuotaGroup(), Equals, parent)
+
+	root, err := quota.NewGroup("root-grp", quota.NewResourcesBuilder().WithMemoryLimit(quantity.SizeGiB).Build())
+	c.Assert(err, IsNil)
+	c.Check(root.DiskQuotaGroup(), IsNil)
+	sub2, err := root.NewSubGroup("sub2", quota.NewResourcesBuilder().WithDiskLimit(quantity.SizeMiB).Build())
+	c.Assert(err, IsNil)
+	c.Check(sub2.DiskQuotaGroup(), Equals, sub2)
+
+	err = root.UpdateQuotaLimits(quota.NewResourcesBuilder().WithDiskLimit(quantity.SizeGiB).Build())
+	c.Check(err, ErrorMatches, `cannot set disk limit on group "root-grp": sub-group "sub2" already has a disk limit`)
+}
+
+func (ts *quotaTestSuite) TestDiskQuotaNotSupportedForServices(c *C) {
+	grp, err := quota.NewGroup("groot", quota.NewResourcesBuilder().WithDiskLimit(quantity.SizeGiB).Build())
+	c.Assert(err, IsNil)
+
+	grp.Services = []string{"snap.svc"}
+	c.Check(grp.ValidateGroup(), ErrorMatches, `disk quota is not supported for individual services`)
+}
+
+func (ts *quotaTestSuite) TestAllSnaps(c *C) {
+	parent, err := quota.NewGroup("parent", quota.NewResourcesBuilder().WithDiskLimit(quantity.SizeGiB).Build())
+	c.Assert(err, IsNil)
+	sub1, err := parent.NewSubGroup("sub1", quota.NewResourcesBuilder().WithMemoryLimit(quantity.SizeMiB).Build())
+	c.Assert(err, IsNil)
+	sub2, err := parent.NewSubGroup("sub2", quota.NewResourcesBuilder().WithMemoryLimit(quantity.SizeMiB).Build())
+	c.Assert(err, IsNil)
+	sub1.Snaps = []string{"foo", "bar"}
+	sub2.Snaps = []string{"baz"}
+
+	snaps := parent.AllSnaps()
+	sort.Strings(snaps)
+	c.Check(snaps, DeepEquals, []string{"bar", "baz", "foo"})
+	c.Check(sub2.AllSnaps(), DeepEquals, []string{"baz"})
+}
+
+func (ts *quotaTestSuite) TestDiskQuotaDirs(c *C) {
+	dirs.SetRootDir(c.MkDir())
+	defer dirs.SetRootDir("")
+
+	for _, d := range []string{
+		filepath.Join(dirs.GlobalRootDir, "home/user1/snap/foo"),
+		filepath.Join(dirs.GlobalRootDir, "home/user2/.snap/data/foo"),
+		filepath.Join(dirs.GlobalRootDir, "home/user2/snap/bar"),
+	} {
+		c.Assert(os.MkdirAll(d, 0755), IsNil)
+	}
+
+	grp, err := quota.NewGroup("groot", quota.NewResourcesBuilder().WithDiskLimit(quantity.SizeGiB).Build())
+	c.Assert(err, IsNil)
+
+	paths, err := grp.DiskQuotaDirs("foo")
+	c.Assert(err, IsNil)
+	c.Check(paths, DeepEquals, []string{filepath.Join(dirs.SnapDataDir, "foo")})
+
+	err = grp.UpdateQuotaLimits(quota.NewResourcesBuilder().WithDiskLimit(quantity.SizeGiB).WithDiskUserData().Build())
+	c.Assert(err, IsNil)
+	paths, err = grp.DiskQuotaDirs("foo")
+	c.Assert(err, IsNil)
+	c.Check(paths, DeepEquals, []string{
+		filepath.Join(dirs.SnapDataDir, "foo"),
+		filepath.Join(dirs.GlobalRootDir, "home/user1/snap/foo"),
+		filepath.Join(dirs.GlobalRootDir, "home/user2/.snap/data/foo"),
+	})
+}
+
+func (ts *quotaTestSuite) TestCurrentDiskUsage(c *C) {
+	var calls [][]string
+	restore := quota.MockFsquotaUsage(func(paths ...string) (uint64, error) {
+		calls = append(calls, paths)
+		return 4096 * uint64(len(paths)), nil
+	})
+	defer restore()
+
+	grp, err := quota.NewGroup("groot", quota.NewResourcesBuilder().WithDiskLimit(quantity.SizeGiB).Build())
+	c.Assert(err, IsNil)
+	grp.Snaps = []string{"foo", "bar"}
+
+	usage, err := grp.CurrentDiskUsage()
+	c.Assert(err, IsNil)
+	c.Check(usage, Equals, quantity.Size(8192))
+	c.Check(calls, DeepEquals, [][]string{{
+		filepath.Join(dirs.SnapDataDir, "foo"),
+		filepath.Join(dirs.SnapDataDir, "bar"),
+	}})
+
+	restore = quota.MockFsquotaUsage(func(paths ...string) (uint64, error) {
+		return 0, fmt.Errorf("permission denied")
+	})
+	defer restore()
+	_, err = grp.CurrentDiskUsage()
+	c.Check(err, ErrorMatches, "permission denied")
+}
+
 func (ts *quotaTestSuite) TestServiceMapEmptyOnEmptyGroup(c *C) {
 	rootGrp, err := quota.NewGroup("myroot", quota.NewResourcesBuilder().WithMemoryLimit(quantity.SizeGiB).Build())
 	c.Assert(err, IsNil)
@@ -23,7 +23,9 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/gadget/quantity"
+	"github.com/snapcore/snapd/osutil/fsquota"
 	"github.com/snapcore/snapd/sandbox/cgroup"
 )
 
@@ -32,6 +34,8 @@ var (
 	cgroupVerErr error
 
 	cgroupCheckMemoryCgroupErr error
+
+	fsquotaCheckProjectQuota = fsquota.CheckProjectQuota
 )
 
 func init() {
@@ -75,6 +79,14 @@ type ResourceJournal struct {
 	Rate *ResourceJournalRate `json:"rate,omitempty"`
 }
 
+// ResourceDisk limits the disk space used by the data directories of the
+// snaps in a quota group. UserData extends the limit to cover the per-user
+// data directories of the snaps as well.
+type ResourceDisk struct {
+	Limit    quantity.Size `json:"limit"`
+	UserData bool          `json:"user-data,omitempty"`
+}
+
 // Resources are built up of multiple quota limits. Each quota limit is a pointer
 // value to indicate that their presence may be optional, and because we want to detect
 // whenever someone changes a limit to '0' explicitly.
@@ -84,6 +96,7 @@ type Resources struct {
 	CPUSet  *ResourceCPUSet  `json:"cpu-set,omitempty"`
 	Threads *ResourceThreads `json:"thread,omitempty"`
 	Journal *ResourceJournal `json:"journal,omitempty"`
+	Disk    *ResourceDisk    `json:"disk,omitempty"`
 }
 
 const (
@@ -99,6 +112,10 @@ const (
 	// usage, but we have selected 64kB to protect against ridiculously small values.
 	journalLimitMin = 64 * quantity.SizeKiB
 	journalLimitMax = 4 * quantity.SizeGiB
+
+	// make sure the disk limit is at least 1MB, anything smaller would not
+	// even hold the data directories of a snap with a handful of files.
+	diskLimitMin = 1 * quantity.SizeMiB
 )
 
 func (qr *Resources) validateMemoryQuota() error {
@@ -186,6 +203,13 @@ func (qr *Resources) validateJournalQuota() error {
 	return nil
 }
 
+func (qr *Resources) validateDiskQuota() error {
+	if qr.Disk.Limit == 0 {
+		return fmt.Errorf("disk quota must have a limit set")
+	}
+	return nil
+}
+
 // CheckFeatureRequirements checks if the current system meets the
 // requirements for the given resource request.
 //
@@ -202,6 +226,13 @@ func (qr *Resources) CheckFeatureRequirements() error {
 	if qr.Memory != nil && cgroupCheckMemoryCgroupErr != nil {
 		return fmt.Errorf("cannot use memory quota: %v", cgroupCheckMemoryCgroupErr)
 	}
+	if qr.Disk != nil {
+		// snap data directories are all kept under the same
+		// directory, so checking it is enough for system data
+		if err := fsquotaCheckProjectQuota(dirs.SnapDataDir); err != nil {
+			return fmt.Errorf("cannot use disk quota: %v", err)
+		}
+	}
 
 	return nil
 }
@@ -251,6 +282,12 @@ func (qr *Resources) Validate() error {
 			return err
 		}
 	}
+
+	if qr.Disk != nil {
+		if err := qr.validateDiskQuota(); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -347,6 +384,19 @@ func (qr *Resources) ValidateChange(newLimits Resources) error {
 		// rate-limit for the group, overriding the journal default which is 10000/30s
 	}
 
+	// Check that the disk limit is not being removed, decreasing it is fine
+	// as project quotas simply start refusing writes once over the limit
+	if newLimits.Disk != nil {
+		if qr.Disk != nil && newLimits.Disk.Limit == 0 {
+			return fmt.Errorf("cannot remove disk limit from quota group")
+		}
+
+		if newLimits.Disk.Limit < diskLimitMin {
+			return fmt.Errorf("disk limit %d is too small: size must be at least %s",
+				newLimits.Disk.Limit, diskLimitMin.IECString())
+		}
+	}
+
 	return nil
 }
 
@@ -374,6 +424,9 @@ func (qr *Resources) clone() Resources {
 			resourcesCopy.Journal.Rate = &ResourceJournalRate{Count: qr.Journal.Rate.Count, Period: qr.Journal.Rate.Period}
 		}
 	}
+	if qr.Disk != nil {
+		resourcesCopy.Disk = &ResourceDisk{Limit: qr.Disk.Limit, UserData: qr.Disk.UserData}
+	}
 	return resourcesCopy
 }
 
@@ -402,6 +455,9 @@ func (qr *Resources) changeInternal(newLimits Resources) {
 			qr.Journal.Rate = newLimits.Journal.Rate
 		}
 	}
+	if newLimits.Disk != nil {
+		qr.Disk = newLimits.Disk
+	}
 }
 
 // Change updates the current quota limits with the new limits. Additional verification
@@ -49,6 +49,11 @@ type ResourcesBuilder struct {
 	JournalRateCountLimit  int
 	JournalRatePeriodLimit time.Duration
 	JournalRateSet         bool
+
+	DiskLimit    quantity.Size
+	DiskLimitSet bool
+
+	DiskUserData bool
 }
 
 func (rb *ResourcesBuilder) WithMemoryLimit(limit quantity.Size) *ResourcesBuilder {
@@ -99,6 +104,17 @@ func (rb *ResourcesBuilder) WithJournalRate(count int, period time.Duration) *Re
 	return rb
 }
 
+func (rb *ResourcesBuilder) WithDiskLimit(limit quantity.Size) *ResourcesBuilder {
+	rb.DiskLimit = limit
+	rb.DiskLimitSet = true
+	return rb
+}
+
+func (rb *ResourcesBuilder) WithDiskUserData() *ResourcesBuilder {
+	rb.DiskUserData = true
+	return rb
+}
+
 func (rb *ResourcesBuilder) Build() Resources {
 	var quotaResources Resources
 	if rb.MemoryLimitSet {
@@ -136,6 +152,12 @@ func (rb *ResourcesBuilder) Build() Resources {
 			}
 		}
 	}
+	if rb.DiskLimitSet {
+		quotaResources.Disk = &ResourceDisk{
+			Limit:    rb.DiskLimit,
+			UserData: rb.DiskUserData,
+		}
+	}
 	return quotaResources
 }
 
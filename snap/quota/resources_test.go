@@ -26,6 +26,7 @@ import (
 
 	. "gopkg.in/check.v1"
 
+	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/gadget/quantity"
 	"github.com/snapcore/snapd/snap/quota"
 )
@@ -50,6 +51,7 @@ func (s *resourcesTestSuite) TestQuotaValidationFails(c *C) {
 		{quota.NewResourcesBuilder().WithJournalRate(0, 1).Build(), `journal quota must have a period of at least 1 microsecond \(minimum resolution\)`},
 		{quota.NewResourcesBuilder().WithJournalRate(1, time.Nanosecond).Build(), `journal quota must have a period of at least 1 microsecond \(minimum resolution\)`},
 		{quota.NewResourcesBuilder().WithJournalSize(0).Build(), `journal size quota must have a limit set`},
+		{quota.NewResourcesBuilder().WithDiskLimit(0).Build(), `disk quota must have a limit set`},
 	}
 
 	for _, t := range tests {
@@ -84,6 +86,26 @@ func (s *resourcesTestSuite) TestResourceCheckFeatureRequirementsCgroupv1Err(c *
 	c.Check(bad.CheckFeatureRequirements(), ErrorMatches, "some cgroup detection error")
 }
 
+func (s *resourcesTestSuite) TestResourceCheckFeatureRequirementsDisk(c *C) {
+	var checkedPath string
+	r := quota.MockFsquotaCheckProjectQuota(func(path string) error {
+		checkedPath = path
+		return fmt.Errorf("project quotas are not enabled on ext4 filesystem mounted at \"/\"")
+	})
+	defer r()
+
+	res := quota.NewResourcesBuilder().WithDiskLimit(quantity.SizeGiB).Build()
+	c.Check(res.CheckFeatureRequirements(), ErrorMatches,
+		`cannot use disk quota: project quotas are not enabled on ext4 filesystem mounted at "/"`)
+	c.Check(checkedPath, Equals, dirs.SnapDataDir)
+
+	// no check is done when there is no disk quota
+	checkedPath = ""
+	res = quota.NewResourcesBuilder().WithThreadLimit(32).Build()
+	c.Check(res.CheckFeatureRequirements(), IsNil)
+	c.Check(checkedPath, Equals, "")
+}
+
 func (s *resourcesTestSuite) TestQuotaValidationPasses(c *C) {
 	tests := []struct {
 		limits quota.Resources
@@ -95,6 +117,8 @@ func (s *resourcesTestSuite) TestQuotaValidationPasses(c *C) {
 		{quota.NewResourcesBuilder().WithJournalSize(quantity.SizeMiB).Build()},
 		{quota.NewResourcesBuilder().WithJournalRate(1, time.Microsecond).Build()},
 		{quota.NewResourcesBuilder().WithJournalNamespace().Build()},
+		{quota.NewResourcesBuilder().WithDiskLimit(quantity.SizeGiB).Build()},
+		{quota.NewResourcesBuilder().WithDiskLimit(quantity.SizeGiB).WithDiskUserData().Build()},
 	}
 
 	for _, t := range tests {
@@ -205,6 +229,16 @@ func (s *resourcesTestSuite) TestQuotaChangeValidationFails(c *C) {
 			quota.NewResourcesBuilder().WithJournalSize(5 * quantity.SizeGiB).Build(),
 			`journal size quota must be smaller than 4 GiB`,
 		},
+		{
+			quota.NewResourcesBuilder().WithDiskLimit(quantity.SizeGiB).Build(),
+			quota.NewResourcesBuilder().WithDiskLimit(0).Build(),
+			`cannot remove disk limit from quota group`,
+		},
+		{
+			quota.NewResourcesBuilder().WithDiskLimit(quantity.SizeGiB).Build(),
+			quota.NewResourcesBuilder().WithDiskLimit(512 * quantity.SizeKiB).Build(),
+			`disk limit 524288 is too small: size must be at least 1 MiB`,
+		},
 	}
 
 	for _, t := range tests {
@@ -298,6 +332,16 @@ func (s *resourcesTestSuite) TestQuotaChangeValidationPasses(c *C) {
 			quota.NewResourcesBuilder().WithJournalRate(15, time.Second).Build(),
 			quota.NewResourcesBuilder().WithCPUCount(4).WithCPUPercentage(25).WithJournalRate(15, time.Second).Build(),
 		},
+		{
+			quota.NewResourcesBuilder().WithDiskLimit(quantity.SizeGiB).Build(),
+			quota.NewResourcesBuilder().WithDiskLimit(quantity.SizeMiB).WithDiskUserData().Build(),
+			quota.NewResourcesBuilder().WithDiskLimit(quantity.SizeMiB).WithDiskUserData().Build(),
+		},
+		{
+			quota.NewResourcesBuilder().WithMemoryLimit(quantity.SizeMiB).Build(),
+			quota.NewResourcesBuilder().WithDiskLimit(quantity.SizeGiB).Build(),
+			quota.NewResourcesBuilder().WithMemoryLimit(quantity.SizeMiB).WithDiskLimit(quantity.SizeGiB).Build(),
+		},
 		{
 			quota.NewResourcesBuilder().WithCPUCount(4).WithCPUPercentage(25).Build(),
 			quota.NewResourcesBuilder().WithJournalNamespace().Build(),
@@ -405,6 +405,19 @@ func Validate(info *Info) error {
 		return err
 	}
 
+	// validate the declared components
+	for _, comp := range info.Components {
+		if err := naming.ValidateComponent(comp.Name); err != nil {
+			return err
+		}
+		if err := ValidateComponentType(comp.Type); err != nil {
+			return fmt.Errorf("invalid definition of component %q: %v", comp.Name, err)
+		}
+		if err := validateDescription(comp.Description); err != nil {
+			return fmt.Errorf("invalid definition of component %q: %v", comp.Name, err)
+		}
+	}
+
 	return ValidateLayoutAll(info)
 }
 
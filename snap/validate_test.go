@@ -1754,6 +1754,35 @@ apps:
 	}
 }
 
+func (s *ValidateSuite) TestValidateComponents(c *C) {
+	meta := `
+name: foo
+version: 1.0
+components:
+`
+	for i, tc := range []struct {
+		comps string
+		err   string
+	}{
+		{"  comp:\n    type: test\n", ""},
+		{"  comp:\n    type: kernel-modules\n", ""},
+		{"  comp_1:\n    type: test\n", `invalid component name: "comp_1"`},
+		{"  comp:\n    type: foo\n", `invalid definition of component "comp": unknown component type "foo"`},
+		{"  comp:\n    type: test\n    description: " + strings.Repeat("x", 4097) + "\n", `invalid definition of component "comp": description can have up to 4096 codepoints, got 4097`},
+	} {
+		c.Logf("tc #%v", i)
+		info, err := InfoFromSnapYaml([]byte(meta + tc.comps))
+		c.Assert(err, IsNil)
+
+		err = Validate(info)
+		if tc.err == "" {
+			c.Check(err, IsNil)
+		} else {
+			c.Check(err, ErrorMatches, tc.err)
+		}
+	}
+}
+
 func (s *validateSuite) TestValidateDescription(c *C) {
 	for _, s := range []string{
 		"xx", // boringest ASCII
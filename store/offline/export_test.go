@@ -0,0 +1,40 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package offline
+
+import (
+	"github.com/snapcore/snapd/snap"
+)
+
+func MockSnapReadInfo(f func(snapPath string, si *snap.SideInfo) (*snap.Info, error)) (restore func()) {
+	old := snapReadInfo
+	snapReadInfo = f
+	return func() {
+		snapReadInfo = old
+	}
+}
+
+func MockSnapFileSHA3_384(f func(snapPath string) (string, uint64, error)) (restore func()) {
+	old := snapFileSHA3_384
+	snapFileSHA3_384 = f
+	return func() {
+		snapFileSHA3_384 = old
+	}
+}
@@ -0,0 +1,390 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package offline
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/channel"
+	"github.com/snapcore/snapd/snap/naming"
+	"github.com/snapcore/snapd/snap/snapfile"
+	"github.com/snapcore/snapd/strutil"
+)
+
+// IndexFile is the name of the file, in the directory of the offline store,
+// that describes the snaps it serves.
+const IndexFile = "index.json"
+
+// indexEntry describes one snap revision served by the offline store.
+type indexEntry struct {
+	Name     string        `json:"name"`
+	SnapID   string        `json:"snap-id"`
+	Revision snap.Revision `json:"revision"`
+	// File is the name of the snap file, relative to the directory of
+	// the store
+	File string `json:"file"`
+	// Channels are the channels the revision is released to
+	Channels []string `json:"channels,omitempty"`
+}
+
+type index struct {
+	Snaps []*indexEntry `json:"snaps"`
+}
+
+// contents holds what was loaded from the directory of the store.
+type contents struct {
+	dir   string
+	snaps []*indexEntry
+	// assertions are indexed by the unique form of their reference
+	assertions map[string]asserts.Assertion
+	// sequences holds the members of each sequence, by the unique form
+	// of their sequence key
+	sequences map[string][]asserts.SequenceMember
+}
+
+func loadContents(dir string) (*contents, error) {
+	f, err := os.Open(filepath.Join(dir, IndexFile))
+	if err != nil {
+		return nil, fmt.Errorf("cannot open offline store index: %v", err)
+	}
+	defer f.Close()
+	var idx index
+	if err := json.NewDecoder(f).Decode(&idx); err != nil {
+		return nil, fmt.Errorf("cannot decode offline store index: %v", err)
+	}
+
+	c := &contents{
+		dir:        dir,
+		assertions: make(map[string]asserts.Assertion),
+		sequences:  make(map[string][]asserts.SequenceMember),
+	}
+	for _, e := range idx.Snaps {
+		if err := e.validate(); err != nil {
+			return nil, fmt.Errorf("invalid offline store index: %v", err)
+		}
+		c.snaps = append(c.snaps, e)
+	}
+
+	assertFiles, err := filepath.Glob(filepath.Join(dir, "*.assert"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(assertFiles)
+	for _, fn := range assertFiles {
+		if err := c.loadAssertions(fn); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+func (e *indexEntry) validate() error {
+	if err := naming.ValidateSnap(e.Name); err != nil {
+		return err
+	}
+	if e.SnapID == "" {
+		return fmt.Errorf("snap %q has no snap-id", e.Name)
+	}
+	if !e.Revision.Store() {
+		return fmt.Errorf("snap %q has invalid revision %s", e.Name, e.Revision)
+	}
+	if e.File == "" || e.File != filepath.Base(e.File) || strings.HasPrefix(e.File, ".") {
+		return fmt.Errorf("snap %q has invalid file name %q", e.Name, e.File)
+	}
+	for i, ch := range e.Channels {
+		full, err := channel.Full(ch)
+		if err != nil || full == "" {
+			return fmt.Errorf("snap %q has invalid channel %q", e.Name, ch)
+		}
+		e.Channels[i] = full
+	}
+	return nil
+}
+
+func (c *contents) loadAssertions(fn string) error {
+	f, err := os.Open(fn)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	dec := asserts.NewDecoder(f)
+	for {
+		a, err := dec.Decode()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("cannot decode assertions from %q: %v", filepath.Base(fn), err)
+		}
+		c.addAssertion(a)
+	}
+}
+
+func (c *contents) addAssertion(a asserts.Assertion) {
+	key := a.Ref().Unique()
+	if old := c.assertions[key]; old != nil && old.Revision() >= a.Revision() {
+		return
+	}
+	c.assertions[key] = a
+	if !a.Type().SequenceForming() {
+		return
+	}
+	seq, ok := a.(asserts.SequenceMember)
+	if !ok {
+		return
+	}
+	seqKey := sequenceKey(a.Type(), a.Ref().PrimaryKey)
+	members := c.sequences[seqKey]
+	for i, m := range members {
+		if m.Sequence() == seq.Sequence() {
+			members[i] = seq
+			return
+		}
+	}
+	c.sequences[seqKey] = append(members, seq)
+}
+
+// sequenceKey returns the unique form of the sequence key of a sequence
+// forming assertion, given its primary key which ends with the sequence.
+func sequenceKey(assertType *asserts.AssertionType, primaryKey []string) string {
+	at := &asserts.AtSequence{Type: assertType, SequenceKey: primaryKey[:len(primaryKey)-1]}
+	return at.Unique()
+}
+
+// sequenceMember returns the member of the given sequence with the given
+// sequence number, or the latest one if sequence is not positive.
+func (c *contents) sequenceMember(assertType *asserts.AssertionType, seqKey []string, sequence int) asserts.SequenceMember {
+	at := &asserts.AtSequence{Type: assertType, SequenceKey: seqKey}
+	var found asserts.SequenceMember
+	for _, m := range c.sequences[at.Unique()] {
+		if sequence > 0 {
+			if m.Sequence() == sequence {
+				return m
+			}
+			continue
+		}
+		if found == nil || m.Sequence() > found.Sequence() {
+			found = m
+		}
+	}
+	return found
+}
+
+// byName returns the entries for the given snap.
+func (c *contents) byName(name string) []*indexEntry {
+	var entries []*indexEntry
+	for _, e := range c.snaps {
+		if e.Name == name {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+// bySnapID returns the entries for the snap with the given snap-id.
+func (c *contents) bySnapID(snapID string) []*indexEntry {
+	var entries []*indexEntry
+	for _, e := range c.snaps {
+		if e.SnapID == snapID {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+// names returns the sorted names of the snaps in the store.
+func (c *contents) names() []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, e := range c.snaps {
+		if !seen[e.Name] {
+			seen[e.Name] = true
+			names = append(names, e.Name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// risks are ordered from the most to the least stable.
+var risks = []string{"stable", "candidate", "beta", "edge"}
+
+// inChannel returns the entry released to the given channel, falling back
+// to more stable risks of the same track as the store does, along with the
+// channel it was found in.
+func inChannel(entries []*indexEntry, ch string) (*indexEntry, string, error) {
+	if ch == "" {
+		ch = "stable"
+	}
+	full, err := channel.Full(ch)
+	if err != nil {
+		return nil, "", err
+	}
+	candidates := []string{full}
+	// without a branch, a channel closed at a risk follows the more
+	// stable ones
+	if parts := strings.Split(full, "/"); len(parts) == 2 {
+		for level := len(risks) - 1; level >= 0; level-- {
+			if risks[level] != parts[1] {
+				continue
+			}
+			candidates = candidates[:0]
+			for i := level; i >= 0; i-- {
+				candidates = append(candidates, parts[0]+"/"+risks[i])
+			}
+			break
+		}
+	}
+	for _, cand := range candidates {
+		var found *indexEntry
+		for _, e := range entries {
+			if !strutil.ListContains(e.Channels, cand) {
+				continue
+			}
+			if found == nil || e.Revision.N > found.Revision.N {
+				found = e
+			}
+		}
+		if found != nil {
+			return found, cand, nil
+		}
+	}
+	return nil, "", nil
+}
+
+// atRevision returns the entry for the given revision.
+func atRevision(entries []*indexEntry, rev snap.Revision) *indexEntry {
+	for _, e := range entries {
+		if e.Revision == rev {
+			return e
+		}
+	}
+	return nil
+}
+
+var snapReadInfo = func(snapPath string, si *snap.SideInfo) (*snap.Info, error) {
+	snapf, err := snapfile.Open(snapPath)
+	if err != nil {
+		return nil, err
+	}
+	return snap.ReadInfoFromSnapFile(snapf, si)
+}
+
+var snapFileSHA3_384 = asserts.SnapFileSHA3_384
+
+// snapPath returns the path of the snap file of the entry.
+func (c *contents) snapPath(e *indexEntry) string {
+	return filepath.Join(c.dir, e.File)
+}
+
+// info returns the information about the snap revision of the entry, as
+// the store would for the given channel. The digest of the snap file is
+// not computed, see Store.addDigest.
+func (c *contents) info(e *indexEntry, effectiveChannel string) (*snap.Info, error) {
+	snapPath := c.snapPath(e)
+	si := &snap.SideInfo{
+		RealName: e.Name,
+		SnapID:   e.SnapID,
+		Revision: e.Revision,
+		Channel:  effectiveChannel,
+	}
+	info, err := snapReadInfo(snapPath, si)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read snap %q from offline store: %v", e.File, err)
+	}
+	fi, err := os.Stat(snapPath)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read snap %q from offline store: %v", e.File, err)
+	}
+	info.DownloadInfo = snap.DownloadInfo{
+		DownloadURL: (&url.URL{Scheme: "file", Path: snapPath}).String(),
+		Size:        fi.Size(),
+	}
+	return info, nil
+}
+
+// fileStamp identifies a version of a file in the directory of the store,
+// so that what was read from it is only read again once it changes.
+type fileStamp struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+func stampFile(path string) (fileStamp, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return fileStamp{}, err
+	}
+	return fileStamp{path: path, size: fi.Size(), modTime: fi.ModTime()}, nil
+}
+
+func (fs fileStamp) equal(other fileStamp) bool {
+	return fs.path == other.path && fs.size == other.size && fs.modTime.Equal(other.modTime)
+}
+
+// contentsStamps returns the stamps of the index and assertion files the
+// contents of the store are loaded from.
+func contentsStamps(dir string) ([]fileStamp, error) {
+	assertFiles, err := filepath.Glob(filepath.Join(dir, "*.assert"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(assertFiles)
+	paths := append([]string{filepath.Join(dir, IndexFile)}, assertFiles...)
+	stamps := make([]fileStamp, 0, len(paths))
+	for _, path := range paths {
+		st, err := stampFile(path)
+		if err != nil {
+			return nil, err
+		}
+		stamps = append(stamps, st)
+	}
+	return stamps, nil
+}
+
+func stampsEqual(a, b []fileStamp) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !a[i].equal(b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// snapDigest is the digest of a snap file as of the version identified by
+// its stamp.
+type snapDigest struct {
+	stamp  fileStamp
+	digest string
+}
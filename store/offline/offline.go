@@ -0,0 +1,638 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package offline implements a store backend serving snaps and assertions
+// from a local directory, for devices without access to a store.
+//
+// The directory holds the snap files, files with the ".assert" extension
+// containing the assertions for them and any validation sets, and an index
+// file describing the snaps:
+//
+//	{"snaps": [
+//	  {"name": "foo", "snap-id": "...", "revision": 12, "file": "foo_12.snap",
+//	   "channels": ["latest/stable", "latest/candidate"]}
+//	]}
+package offline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/progress"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/channel"
+	"github.com/snapcore/snapd/snap/naming"
+	"github.com/snapcore/snapd/store"
+	"github.com/snapcore/snapd/strutil"
+)
+
+var errUnsupported = errors.New("operation not supported by the offline store")
+
+// Store serves snaps and assertions from a local directory.
+type Store struct {
+	dir string
+
+	mu sync.Mutex
+	// loaded holds the contents last loaded from the directory, as of
+	// the versions of the files identified by loadedStamps
+	loaded       *contents
+	loadedStamps []fileStamp
+	// digests holds the digests of the snap files, by their path
+	digests map[string]snapDigest
+}
+
+// New returns a store serving the contents of the given directory. The
+// directory is checked for changes on each operation, so it can be updated,
+// or be on removable media, while the store is in use.
+func New(dir string) *Store {
+	return &Store{dir: filepath.Clean(dir)}
+}
+
+// Dir returns the directory the store serves.
+func (s *Store) Dir() string {
+	return s.dir
+}
+
+func (s *Store) EnsureDeviceSession() error {
+	return nil
+}
+
+// contents returns the contents of the directory of the store, loading
+// them again only if the index or the assertion files changed.
+func (s *Store) contents() (*contents, error) {
+	stamps, err := contentsStamps(s.dir)
+	if err != nil {
+		// let loading report the problem
+		return loadContents(s.dir)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.loaded != nil && stampsEqual(s.loadedStamps, stamps) {
+		return s.loaded, nil
+	}
+	c, err := loadContents(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	s.loaded = c
+	s.loadedStamps = stamps
+	return c, nil
+}
+
+// addDigest adds the digest of the snap file of the entry to the download
+// information of the snap. The digest is computed only if the file changed
+// since it was last computed, as that means reading the whole file.
+func (s *Store) addDigest(c *contents, e *indexEntry, info *snap.Info) error {
+	snapPath := c.snapPath(e)
+	stamp, err := stampFile(snapPath)
+	if err != nil {
+		return fmt.Errorf("cannot read snap %q from offline store: %v", e.File, err)
+	}
+
+	s.mu.Lock()
+	cached, ok := s.digests[snapPath]
+	s.mu.Unlock()
+	if ok && cached.stamp.equal(stamp) {
+		info.Sha3_384 = cached.digest
+		return nil
+	}
+
+	digest, _, err := snapFileSHA3_384(snapPath)
+	if err != nil {
+		return fmt.Errorf("cannot read snap %q from offline store: %v", e.File, err)
+	}
+	s.mu.Lock()
+	if s.digests == nil {
+		s.digests = make(map[string]snapDigest)
+	}
+	s.digests[snapPath] = snapDigest{stamp: stamp, digest: digest}
+	s.mu.Unlock()
+	info.Sha3_384 = digest
+	return nil
+}
+
+// SnapInfo returns the information about the snap released to the default
+// channel, along with the channels the snap is available in.
+func (s *Store) SnapInfo(ctx context.Context, spec store.SnapSpec, user *auth.UserState) (*snap.Info, error) {
+	c, err := s.contents()
+	if err != nil {
+		return nil, err
+	}
+	entries := c.byName(spec.Name)
+	if len(entries) == 0 {
+		return nil, store.ErrSnapNotFound
+	}
+	e, ch, err := inChannel(entries, "")
+	if err != nil {
+		return nil, err
+	}
+	if e == nil {
+		// not released to stable, use the latest revision
+		e = entries[0]
+		for _, other := range entries[1:] {
+			if other.Revision.N > e.Revision.N {
+				e = other
+			}
+		}
+	}
+	info, err := c.info(e, ch)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.addDigest(c, e, info); err != nil {
+		return nil, err
+	}
+	info.Channels = make(map[string]*snap.ChannelSnapInfo)
+	for _, other := range entries {
+		otherInfo := info
+		if other != e {
+			if otherInfo, err = c.info(other, ""); err != nil {
+				return nil, err
+			}
+		}
+		for _, ch := range other.Channels {
+			if cur := info.Channels[ch]; cur != nil && cur.Revision.N > other.Revision.N {
+				continue
+			}
+			info.Channels[ch] = &snap.ChannelSnapInfo{
+				Revision:    other.Revision,
+				Confinement: otherInfo.Confinement,
+				Version:     otherInfo.Version,
+				Channel:     ch,
+				Epoch:       otherInfo.Epoch,
+				Size:        otherInfo.Size,
+			}
+		}
+	}
+	return info, nil
+}
+
+func (s *Store) SnapExists(ctx context.Context, spec store.SnapSpec, user *auth.UserState) (naming.SnapRef, *channel.Channel, error) {
+	c, err := s.contents()
+	if err != nil {
+		return nil, nil, err
+	}
+	entries := c.byName(spec.Name)
+	if len(entries) == 0 {
+		return nil, nil, store.ErrSnapNotFound
+	}
+	ref := naming.NewSnapRef(entries[0].Name, entries[0].SnapID)
+	e, ch, err := inChannel(entries, "")
+	if err != nil || e == nil {
+		return ref, nil, err
+	}
+	defaultChannel, err := channel.Parse(ch, "")
+	if err != nil {
+		return ref, nil, err
+	}
+	return ref, &defaultChannel, nil
+}
+
+// Find returns the snaps whose name or summary match the search, considering
+// the revisions in the default channel.
+func (s *Store) Find(ctx context.Context, search *store.Search, user *auth.UserState) ([]*snap.Info, error) {
+	if search.Private {
+		return nil, errUnsupported
+	}
+	c, err := s.contents()
+	if err != nil {
+		return nil, err
+	}
+	if search.Category != "" {
+		// there are no categories offline
+		return nil, nil
+	}
+	var infos []*snap.Info
+	for _, name := range c.names() {
+		if search.Prefix && !strings.HasPrefix(name, search.Query) {
+			continue
+		}
+		e, ch, err := inChannel(c.byName(name), "")
+		if err != nil {
+			return nil, err
+		}
+		if e == nil {
+			continue
+		}
+		info, err := c.info(e, ch)
+		if err != nil {
+			return nil, err
+		}
+		if !search.Prefix && !strings.Contains(name, search.Query) && !strings.Contains(info.Summary(), search.Query) {
+			continue
+		}
+		if search.CommonID != "" && !strutil.ListContains(info.CommonIDs, search.CommonID) {
+			continue
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// SnapAction resolves install, download and refresh actions against the
+// snaps in the index, and assertion queries against the assertions in the
+// directory.
+func (s *Store) SnapAction(ctx context.Context, currentSnaps []*store.CurrentSnap, actions []*store.SnapAction, assertQuery store.AssertionQuery, user *auth.UserState, opts *store.RefreshOptions) ([]store.SnapActionResult, []store.AssertionResult, error) {
+	c, err := s.contents()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var ars []store.AssertionResult
+	resolving := false
+	if assertQuery != nil {
+		ars, resolving, err = c.resolveAssertions(assertQuery)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	if len(currentSnaps) == 0 && len(actions) == 0 {
+		if !resolving {
+			return nil, nil, &store.SnapActionError{NoResults: true}
+		}
+		return nil, ars, nil
+	}
+
+	curSnaps := make(map[string]*store.CurrentSnap, len(currentSnaps))
+	for _, cur := range currentSnaps {
+		curSnaps[cur.InstanceName] = cur
+	}
+
+	refreshErrors := make(map[string]error)
+	installErrors := make(map[string]error)
+	downloadErrors := make(map[string]error)
+	var sars []store.SnapActionResult
+	for _, a := range actions {
+		var entries []*indexEntry
+		ch := a.Channel
+		switch a.Action {
+		case "install", "download":
+			entries = c.byName(snap.InstanceSnap(a.InstanceName))
+		case "refresh":
+			cur := curSnaps[a.InstanceName]
+			if cur == nil {
+				return nil, nil, fmt.Errorf("internal error: refresh of %q without its current state", a.InstanceName)
+			}
+			if ch == "" && a.Revision.Unset() {
+				ch = cur.TrackingChannel
+			}
+			entries = c.bySnapID(a.SnapID)
+		default:
+			return nil, nil, fmt.Errorf("internal error: unsupported action %q", a.Action)
+		}
+
+		e, effectiveChannel, err := resolveAction(a, entries, ch)
+		if err == nil && a.Action == "refresh" {
+			cur := curSnaps[a.InstanceName]
+			if e.Revision == cur.Revision || revisionsContain(cur.Block, e.Revision) {
+				err = store.ErrNoUpdateAvailable
+			}
+		}
+		var info *snap.Info
+		if err == nil {
+			info, err = c.info(e, effectiveChannel)
+		}
+		if err == nil {
+			err = s.addDigest(c, e, info)
+		}
+		if err != nil {
+			switch a.Action {
+			case "install":
+				installErrors[a.InstanceName] = err
+			case "download":
+				downloadErrors[a.InstanceName] = err
+			case "refresh":
+				refreshErrors[a.InstanceName] = err
+			}
+			continue
+		}
+		if a.Action != "download" {
+			_, info.InstanceKey = snap.SplitInstanceName(a.InstanceName)
+		}
+		sars = append(sars, store.SnapActionResult{Info: info})
+	}
+
+	if len(refreshErrors)+len(installErrors)+len(downloadErrors) != 0 {
+		// normalize empty maps
+		if len(refreshErrors) == 0 {
+			refreshErrors = nil
+		}
+		if len(installErrors) == 0 {
+			installErrors = nil
+		}
+		if len(downloadErrors) == 0 {
+			downloadErrors = nil
+		}
+		return sars, ars, &store.SnapActionError{
+			NoResults: len(sars) == 0 && len(ars) == 0,
+			Refresh:   refreshErrors,
+			Install:   installErrors,
+			Download:  downloadErrors,
+		}
+	}
+	return sars, ars, nil
+}
+
+// resolveAction picks the entry for the revision or channel asked for by the
+// action.
+func resolveAction(a *store.SnapAction, entries []*indexEntry, ch string) (*indexEntry, string, error) {
+	if len(entries) == 0 {
+		return nil, "", store.ErrSnapNotFound
+	}
+	if !a.Revision.Unset() {
+		if e := atRevision(entries, a.Revision); e != nil {
+			return e, "", nil
+		}
+	} else {
+		e, effectiveChannel, err := inChannel(entries, ch)
+		if err != nil {
+			return nil, "", err
+		}
+		if e != nil {
+			return e, effectiveChannel, nil
+		}
+	}
+	rnaErr := &store.RevisionNotAvailableError{
+		Action:  a.Action,
+		Channel: ch,
+	}
+	for _, e := range entries {
+		for _, rel := range e.Channels {
+			if parsed, err := channel.Parse(rel, ""); err == nil {
+				rnaErr.Releases = append(rnaErr.Releases, parsed)
+			}
+		}
+	}
+	return nil, "", rnaErr
+}
+
+func revisionsContain(revs []snap.Revision, rev snap.Revision) bool {
+	for _, r := range revs {
+		if r == rev {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveAssertions finds the assertions newer than the ones the query
+// knows about. The results refer to the assertions by the unique form of
+// their reference, for DownloadAssertions.
+func (c *contents) resolveAssertions(q store.AssertionQuery) (ars []store.AssertionResult, resolving bool, err error) {
+	toResolve, toResolveSeq, err := q.ToResolve()
+	if err != nil {
+		return nil, false, err
+	}
+	resolving = len(toResolve) != 0 || len(toResolveSeq) != 0
+
+	results := make(map[asserts.Grouping][]string)
+	for grouping, ats := range toResolve {
+		for _, at := range ats {
+			a := c.assertions[at.Unique()]
+			if a == nil {
+				if at.Revision == asserts.RevisionNotKnown {
+					headers, _ := asserts.HeadersFromPrimaryKey(at.Type, at.PrimaryKey)
+					nfErr := &asserts.NotFoundError{Type: at.Type, Headers: headers}
+					if err := q.AddError(nfErr, &at.Ref); err != nil {
+						return nil, false, err
+					}
+				}
+				continue
+			}
+			if a.Revision() > at.Revision {
+				results[grouping] = append(results[grouping], at.Unique())
+			}
+		}
+	}
+	for grouping, ats := range toResolveSeq {
+		for _, at := range ats {
+			seq := 0
+			if at.Pinned {
+				seq = at.Sequence
+			}
+			m := c.sequenceMember(at.Type, at.SequenceKey, seq)
+			if m == nil || m.Sequence() < at.Sequence {
+				if at.Revision == asserts.RevisionNotKnown {
+					headers, _ := asserts.HeadersFromSequenceKey(at.Type, at.SequenceKey)
+					nfErr := &asserts.NotFoundError{Type: at.Type, Headers: headers}
+					if err := q.AddSequenceError(nfErr, at); err != nil {
+						return nil, false, err
+					}
+				}
+				continue
+			}
+			if m.Sequence() == at.Sequence && m.Revision() <= at.Revision {
+				continue
+			}
+			results[grouping] = append(results[grouping], m.Ref().Unique())
+		}
+	}
+	for grouping, refs := range results {
+		ars = append(ars, store.AssertionResult{Grouping: grouping, StreamURLs: refs})
+	}
+	return ars, resolving, nil
+}
+
+func (s *Store) Sections(ctx context.Context, user *auth.UserState) ([]string, error) {
+	return nil, nil
+}
+
+func (s *Store) Categories(ctx context.Context, user *auth.UserState) ([]store.CategoryDetails, error) {
+	return nil, nil
+}
+
+// WriteCatalogs writes the names of the snaps in the store, and adds the
+// commands of their revisions in the default channel.
+func (s *Store) WriteCatalogs(ctx context.Context, names io.Writer, adder store.SnapAdder) error {
+	c, err := s.contents()
+	if err != nil {
+		return err
+	}
+	for _, name := range c.names() {
+		fmt.Fprintln(names, name)
+		e, ch, err := inChannel(c.byName(name), "")
+		if err != nil || e == nil {
+			continue
+		}
+		info, err := c.info(e, ch)
+		if err != nil {
+			return err
+		}
+		var commands []string
+		for _, app := range info.Apps {
+			if !app.IsService() {
+				commands = append(commands, app.Name)
+			}
+		}
+		if err := adder.AddSnap(name, info.Version, info.Summary(), commands); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// snapPath returns the path of the snap file the download info refers to,
+// checking that it is served by the store.
+func (s *Store) snapPath(downloadInfo *snap.DownloadInfo) (string, error) {
+	u, err := url.Parse(downloadInfo.DownloadURL)
+	if err != nil || u.Scheme != "file" || filepath.Dir(u.Path) != s.dir {
+		return "", fmt.Errorf("cannot download %q: not served by the offline store in %q", downloadInfo.DownloadURL, s.dir)
+	}
+	return u.Path, nil
+}
+
+// Download copies the snap file into place, checking its digest.
+func (s *Store) Download(ctx context.Context, name, targetPath string, downloadInfo *snap.DownloadInfo, pbar progress.Meter, user *auth.UserState, dlOpts *store.DownloadOptions) error {
+	snapPath, err := s.snapPath(downloadInfo)
+	if err != nil {
+		return err
+	}
+	if pbar == nil {
+		pbar = progress.Null
+	}
+	pbar.Start(name, float64(downloadInfo.Size))
+	defer pbar.Finished()
+
+	if err := os.MkdirAll(filepath.Dir(targetPath), 0755); err != nil {
+		return err
+	}
+	if err := osutil.AtomicWriteFileCopy(targetPath, snapPath, 0); err != nil {
+		return fmt.Errorf("cannot download %q: %v", name, err)
+	}
+	if downloadInfo.Sha3_384 != "" {
+		digest, _, err := asserts.SnapFileSHA3_384(targetPath)
+		if err != nil {
+			os.Remove(targetPath)
+			return err
+		}
+		if digest != downloadInfo.Sha3_384 {
+			os.Remove(targetPath)
+			return fmt.Errorf("sha3-384 mismatch for %q: got %s but expected %s", name, digest, downloadInfo.Sha3_384)
+		}
+	}
+	pbar.Set(float64(downloadInfo.Size))
+	return nil
+}
+
+func (s *Store) DownloadStream(ctx context.Context, name string, downloadInfo *snap.DownloadInfo, resume int64, user *auth.UserState) (io.ReadCloser, int, error) {
+	snapPath, err := s.snapPath(downloadInfo)
+	if err != nil {
+		return nil, 0, err
+	}
+	f, err := os.Open(snapPath)
+	if err != nil {
+		return nil, 0, err
+	}
+	if resume <= 0 {
+		return f, 200, nil
+	}
+	if _, err := f.Seek(resume, io.SeekStart); err != nil {
+		f.Close()
+		return nil, 0, err
+	}
+	return f, 206, nil
+}
+
+func (s *Store) Assertion(assertType *asserts.AssertionType, primaryKey []string, user *auth.UserState) (asserts.Assertion, error) {
+	c, err := s.contents()
+	if err != nil {
+		return nil, err
+	}
+	ref := &asserts.Ref{Type: assertType, PrimaryKey: primaryKey}
+	if a := c.assertions[ref.Unique()]; a != nil {
+		return a, nil
+	}
+	headers, _ := asserts.HeadersFromPrimaryKey(assertType, primaryKey)
+	return nil, &asserts.NotFoundError{Type: assertType, Headers: headers}
+}
+
+func (s *Store) SeqFormingAssertion(assertType *asserts.AssertionType, sequenceKey []string, sequence int, user *auth.UserState) (asserts.Assertion, error) {
+	c, err := s.contents()
+	if err != nil {
+		return nil, err
+	}
+	if m := c.sequenceMember(assertType, sequenceKey, sequence); m != nil {
+		return m, nil
+	}
+	headers, _ := asserts.HeadersFromSequenceKey(assertType, sequenceKey)
+	return nil, &asserts.NotFoundError{Type: assertType, Headers: headers}
+}
+
+// DownloadAssertions adds the assertions referred to by results of
+// SnapAction to the batch.
+func (s *Store) DownloadAssertions(refs []string, b *asserts.Batch, user *auth.UserState) error {
+	c, err := s.contents()
+	if err != nil {
+		return err
+	}
+	for _, ref := range refs {
+		a := c.assertions[ref]
+		if a == nil {
+			return fmt.Errorf("cannot find assertion %q in the offline store", ref)
+		}
+		if err := b.Add(a); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Store) SuggestedCurrency() string {
+	return ""
+}
+
+func (s *Store) Buy(options *client.BuyOptions, user *auth.UserState) (*client.BuyResult, error) {
+	return nil, errUnsupported
+}
+
+func (s *Store) ReadyToBuy(user *auth.UserState) error {
+	return errUnsupported
+}
+
+// ConnectivityCheck reports whether the directory of the store is
+// available.
+func (s *Store) ConnectivityCheck() (map[string]bool, error) {
+	return map[string]bool{
+		s.dir: osutil.FileExists(filepath.Join(s.dir, IndexFile)),
+	}, nil
+}
+
+func (s *Store) CreateCohorts(ctx context.Context, snaps []string) (map[string]string, error) {
+	return nil, errUnsupported
+}
+
+func (s *Store) LoginUser(username, password, otp string) (string, string, error) {
+	return "", "", errUnsupported
+}
+
+func (s *Store) UserInfo(email string) (*store.User, error) {
+	return nil, errUnsupported
+}
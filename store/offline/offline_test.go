@@ -0,0 +1,593 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package offline_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/asserts/assertstest"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/store"
+	"github.com/snapcore/snapd/store/offline"
+	"github.com/snapcore/snapd/testutil"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type offlineSuite struct {
+	testutil.BaseTest
+
+	dir          string
+	storeSigning *assertstest.StoreStack
+	sto          *offline.Store
+}
+
+var _ = Suite(&offlineSuite{})
+
+// ensure we conform
+var _ snapstate.StoreService = (*offline.Store)(nil)
+
+const fooID = "qOqKhntON3vR7kwEbVPsILm7bUViPDzz"
+
+func (s *offlineSuite) SetUpTest(c *C) {
+	s.BaseTest.SetUpTest(c)
+
+	s.dir = c.MkDir()
+	s.storeSigning = assertstest.NewStoreStack("canonical", nil)
+	s.sto = offline.New(s.dir)
+
+	s.AddCleanup(offline.MockSnapReadInfo(func(snapPath string, si *snap.SideInfo) (*snap.Info, error) {
+		data, err := ioutil.ReadFile(snapPath)
+		if err != nil {
+			return nil, err
+		}
+		// the mocked snap files hold their snap.yaml
+		info, err := snap.InfoFromSnapYaml(data)
+		if err != nil {
+			return nil, err
+		}
+		info.SideInfo = *si
+		return info, nil
+	}))
+	s.AddCleanup(snap.MockSanitizePlugsSlots(func(snapInfo *snap.Info) {}))
+}
+
+func (s *offlineSuite) mockSnap(c *C, name string, rev int, channels ...string) map[string]interface{} {
+	fn := fmt.Sprintf("%s_%d.snap", name, rev)
+	yaml := fmt.Sprintf("name: %s\nversion: %d.0\nsummary: the %s snap\napps:\n  %s:\n", name, rev, name, name)
+	c.Assert(ioutil.WriteFile(filepath.Join(s.dir, fn), []byte(yaml), 0644), IsNil)
+	return map[string]interface{}{
+		"name":     name,
+		"snap-id":  name + "-id",
+		"revision": rev,
+		"file":     fn,
+		"channels": channels,
+	}
+}
+
+func (s *offlineSuite) writeIndex(c *C, snaps ...map[string]interface{}) {
+	data, err := json.Marshal(map[string]interface{}{"snaps": snaps})
+	c.Assert(err, IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(s.dir, offline.IndexFile), data, 0644), IsNil)
+}
+
+func (s *offlineSuite) mockStore(c *C) {
+	s.writeIndex(c,
+		s.mockSnap(c, "foo", 1, "latest/stable"),
+		s.mockSnap(c, "foo", 2, "candidate"),
+		s.mockSnap(c, "foo", 3, "edge", "2.0/stable"),
+		s.mockSnap(c, "bar", 7, "stable"),
+	)
+}
+
+func (s *offlineSuite) validationSet(c *C, sequence, revision int) *asserts.ValidationSet {
+	headers := map[string]interface{}{
+		"series":     "16",
+		"account-id": "canonical",
+		"name":       "base-set",
+		"sequence":   fmt.Sprint(sequence),
+		"revision":   fmt.Sprint(revision),
+		"snaps": []interface{}{map[string]interface{}{
+			"id":       fooID,
+			"name":     "foo",
+			"presence": "required",
+		}},
+		"timestamp": time.Now().Format(time.RFC3339),
+	}
+	a, err := s.storeSigning.Sign(asserts.ValidationSetType, headers, nil, "")
+	c.Assert(err, IsNil)
+	return a.(*asserts.ValidationSet)
+}
+
+func (s *offlineSuite) writeAssertions(c *C, fn string, as ...asserts.Assertion) {
+	buf := new(bytes.Buffer)
+	enc := asserts.NewEncoder(buf)
+	for _, a := range as {
+		c.Assert(enc.Encode(a), IsNil)
+	}
+	c.Assert(ioutil.WriteFile(filepath.Join(s.dir, fn), buf.Bytes(), 0644), IsNil)
+}
+
+func (s *offlineSuite) TestSnapInfo(c *C) {
+	s.mockStore(c)
+
+	info, err := s.sto.SnapInfo(context.Background(), store.SnapSpec{Name: "foo"}, nil)
+	c.Assert(err, IsNil)
+	c.Check(info.SnapName(), Equals, "foo")
+	c.Check(info.SnapID, Equals, "foo-id")
+	c.Check(info.Revision, Equals, snap.R(1))
+	c.Check(info.Channel, Equals, "latest/stable")
+	c.Check(info.Version, Equals, "1.0")
+	c.Check(info.Size, Not(Equals), int64(0))
+	c.Check(info.Sha3_384, Not(Equals), "")
+	c.Check(info.DownloadURL, Equals, "file://"+filepath.Join(s.dir, "foo_1.snap"))
+	c.Assert(info.Channels, HasLen, 4)
+	for ch, rev := range map[string]int{
+		"latest/stable":    1,
+		"latest/candidate": 2,
+		"latest/edge":      3,
+		"2.0/stable":       3,
+	} {
+		c.Check(info.Channels[ch].Revision, Equals, snap.R(rev), Commentf(ch))
+		c.Check(info.Channels[ch].Version, Equals, fmt.Sprintf("%d.0", rev), Commentf(ch))
+	}
+
+	_, err = s.sto.SnapInfo(context.Background(), store.SnapSpec{Name: "baz"}, nil)
+	c.Check(err, Equals, store.ErrSnapNotFound)
+}
+
+func (s *offlineSuite) TestSnapExists(c *C) {
+	s.mockStore(c)
+
+	ref, ch, err := s.sto.SnapExists(context.Background(), store.SnapSpec{Name: "bar"}, nil)
+	c.Assert(err, IsNil)
+	c.Check(ref.SnapName(), Equals, "bar")
+	c.Check(ref.ID(), Equals, "bar-id")
+	c.Check(ch.Full(), Equals, "latest/stable")
+
+	_, _, err = s.sto.SnapExists(context.Background(), store.SnapSpec{Name: "baz"}, nil)
+	c.Check(err, Equals, store.ErrSnapNotFound)
+}
+
+func (s *offlineSuite) TestInvalidIndex(c *C) {
+	_, err := s.sto.SnapInfo(context.Background(), store.SnapSpec{Name: "foo"}, nil)
+	c.Check(err, ErrorMatches, `cannot open offline store index: .*`)
+
+	for _, t := range []struct {
+		entry map[string]interface{}
+		err   string
+	}{
+		{map[string]interface{}{"name": "-foo"}, `invalid offline store index: invalid snap name: "-foo"`},
+		{map[string]interface{}{"name": "foo"}, `invalid offline store index: snap "foo" has no snap-id`},
+		{map[string]interface{}{"name": "foo", "snap-id": "foo-id", "revision": -1}, `invalid offline store index: snap "foo" has invalid revision x1`},
+		{map[string]interface{}{"name": "foo", "snap-id": "foo-id", "revision": 1, "file": "../foo.snap"}, `invalid offline store index: snap "foo" has invalid file name "../foo.snap"`},
+		{map[string]interface{}{"name": "foo", "snap-id": "foo-id", "revision": 1, "file": "foo.snap", "channels": []string{"a/b/c/d"}}, `invalid offline store index: snap "foo" has invalid channel "a/b/c/d"`},
+	} {
+		s.writeIndex(c, t.entry)
+		_, err := s.sto.SnapInfo(context.Background(), store.SnapSpec{Name: "foo"}, nil)
+		c.Check(err, ErrorMatches, t.err)
+	}
+
+	c.Assert(ioutil.WriteFile(filepath.Join(s.dir, "bad.assert"), []byte("junk"), 0644), IsNil)
+	s.writeIndex(c)
+	_, err = s.sto.SnapInfo(context.Background(), store.SnapSpec{Name: "foo"}, nil)
+	c.Check(err, ErrorMatches, `cannot decode assertions from "bad.assert": .*`)
+}
+
+func (s *offlineSuite) TestSnapActionInstall(c *C) {
+	s.mockStore(c)
+
+	for _, t := range []struct {
+		action   *store.SnapAction
+		rev      int
+		instance string
+		channel  string
+	}{
+		{&store.SnapAction{Action: "install", InstanceName: "foo"}, 1, "", "latest/stable"},
+		{&store.SnapAction{Action: "install", InstanceName: "foo", Channel: "candidate"}, 2, "", "latest/candidate"},
+		// beta follows candidate
+		{&store.SnapAction{Action: "install", InstanceName: "foo", Channel: "beta"}, 2, "", "latest/candidate"},
+		{&store.SnapAction{Action: "install", InstanceName: "foo", Channel: "2.0"}, 3, "", "2.0/stable"},
+		{&store.SnapAction{Action: "install", InstanceName: "foo_inst", Channel: "edge"}, 3, "inst", "latest/edge"},
+		{&store.SnapAction{Action: "install", InstanceName: "foo", Revision: snap.R(2)}, 2, "", ""},
+		{&store.SnapAction{Action: "download", InstanceName: "foo", Channel: "candidate"}, 2, "", "latest/candidate"},
+	} {
+		sars, ars, err := s.sto.SnapAction(context.Background(), nil, []*store.SnapAction{t.action}, nil, nil, nil)
+		c.Assert(err, IsNil)
+		c.Check(ars, HasLen, 0)
+		c.Assert(sars, HasLen, 1)
+		c.Check(sars[0].Revision, Equals, snap.R(t.rev))
+		c.Check(sars[0].InstanceKey, Equals, t.instance)
+		c.Check(sars[0].Channel, Equals, t.channel)
+		c.Check(sars[0].SnapID, Equals, "foo-id")
+	}
+}
+
+func (s *offlineSuite) TestSnapActionInstallErrors(c *C) {
+	s.mockStore(c)
+
+	sars, _, err := s.sto.SnapAction(context.Background(), nil, []*store.SnapAction{
+		{Action: "install", InstanceName: "bar"},
+		{Action: "install", InstanceName: "baz"},
+		{Action: "install", InstanceName: "foo", Channel: "3.0/stable"},
+		{Action: "download", InstanceName: "bar", Revision: snap.R(1)},
+	}, nil, nil, nil)
+	c.Assert(sars, HasLen, 1)
+	c.Check(sars[0].SnapName(), Equals, "bar")
+	saErr, ok := err.(*store.SnapActionError)
+	c.Assert(ok, Equals, true)
+	c.Check(saErr.NoResults, Equals, false)
+	c.Check(saErr.Install["baz"], Equals, store.ErrSnapNotFound)
+	rnaErr, ok := saErr.Install["foo"].(*store.RevisionNotAvailableError)
+	c.Assert(ok, Equals, true)
+	c.Check(rnaErr.Action, Equals, "install")
+	c.Check(rnaErr.Channel, Equals, "3.0/stable")
+	c.Check(rnaErr.Releases, HasLen, 4)
+	c.Check(saErr.Download["bar"], FitsTypeOf, &store.RevisionNotAvailableError{})
+}
+
+func (s *offlineSuite) TestSnapActionRefresh(c *C) {
+	s.mockStore(c)
+
+	current := []*store.CurrentSnap{
+		{InstanceName: "foo", SnapID: "foo-id", Revision: snap.R(1), TrackingChannel: "latest/candidate"},
+		{InstanceName: "foo_inst", SnapID: "foo-id", Revision: snap.R(1), TrackingChannel: "latest/stable"},
+		{InstanceName: "bar", SnapID: "bar-id", Revision: snap.R(7), TrackingChannel: "latest/stable"},
+	}
+	sars, _, err := s.sto.SnapAction(context.Background(), current, []*store.SnapAction{
+		{Action: "refresh", InstanceName: "foo", SnapID: "foo-id"},
+		{Action: "refresh", InstanceName: "foo_inst", SnapID: "foo-id", Channel: "2.0/stable"},
+		{Action: "refresh", InstanceName: "bar", SnapID: "bar-id"},
+	}, nil, nil, nil)
+	c.Assert(sars, HasLen, 2)
+	c.Check(sars[0].InstanceName(), Equals, "foo")
+	c.Check(sars[0].Revision, Equals, snap.R(2))
+	c.Check(sars[0].Channel, Equals, "latest/candidate")
+	c.Check(sars[1].InstanceName(), Equals, "foo_inst")
+	c.Check(sars[1].Revision, Equals, snap.R(3))
+	c.Check(err, DeepEquals, &store.SnapActionError{
+		Refresh: map[string]error{"bar": store.ErrNoUpdateAvailable},
+	})
+
+	// blocked revisions are not refreshed to
+	current[0].Block = []snap.Revision{snap.R(2)}
+	_, _, err = s.sto.SnapAction(context.Background(), current[:1], []*store.SnapAction{
+		{Action: "refresh", InstanceName: "foo", SnapID: "foo-id"},
+	}, nil, nil, nil)
+	c.Check(err, DeepEquals, &store.SnapActionError{
+		NoResults: true,
+		Refresh:   map[string]error{"foo": store.ErrNoUpdateAvailable},
+	})
+
+	_, _, err = s.sto.SnapAction(context.Background(), nil, []*store.SnapAction{
+		{Action: "refresh", InstanceName: "foo", SnapID: "foo-id"},
+	}, nil, nil, nil)
+	c.Check(err, ErrorMatches, `internal error: refresh of "foo" without its current state`)
+}
+
+func (s *offlineSuite) TestSnapActionNothingToDo(c *C) {
+	s.mockStore(c)
+
+	_, _, err := s.sto.SnapAction(context.Background(), nil, nil, nil, nil, nil)
+	c.Check(err, DeepEquals, &store.SnapActionError{NoResults: true})
+}
+
+func (s *offlineSuite) TestFind(c *C) {
+	s.mockStore(c)
+
+	for _, t := range []struct {
+		search *store.Search
+		names  []string
+	}{
+		{&store.Search{}, []string{"bar", "foo"}},
+		{&store.Search{Query: "fo"}, []string{"foo"}},
+		{&store.Search{Query: "the bar"}, []string{"bar"}},
+		{&store.Search{Query: "ba", Prefix: true}, []string{"bar"}},
+		{&store.Search{Query: "the", Prefix: true}, nil},
+		{&store.Search{Category: "games"}, nil},
+	} {
+		infos, err := s.sto.Find(context.Background(), t.search, nil)
+		c.Assert(err, IsNil)
+		var names []string
+		for _, info := range infos {
+			names = append(names, info.SnapName())
+		}
+		c.Check(names, DeepEquals, t.names, Commentf("%+v", t.search))
+	}
+}
+
+type fakeAdder struct {
+	added map[string][]string
+}
+
+func (a *fakeAdder) AddSnap(snapName, version, summary string, commands []string) error {
+	a.added[snapName] = commands
+	return nil
+}
+
+func (s *offlineSuite) TestWriteCatalogs(c *C) {
+	s.mockStore(c)
+
+	var names bytes.Buffer
+	adder := &fakeAdder{added: make(map[string][]string)}
+	c.Assert(s.sto.WriteCatalogs(context.Background(), &names, adder), IsNil)
+	c.Check(names.String(), Equals, "bar\nfoo\n")
+	c.Check(adder.added, DeepEquals, map[string][]string{
+		"bar": {"bar"},
+		"foo": {"foo"},
+	})
+}
+
+func (s *offlineSuite) TestDownload(c *C) {
+	s.mockStore(c)
+	info, err := s.sto.SnapInfo(context.Background(), store.SnapSpec{Name: "foo"}, nil)
+	c.Assert(err, IsNil)
+
+	target := filepath.Join(c.MkDir(), "partial", "foo_1.snap")
+	err = s.sto.Download(context.Background(), "foo", target, &info.DownloadInfo, nil, nil, nil)
+	c.Assert(err, IsNil)
+	c.Check(target, testutil.FileEquals, testutil.FileContentRef(filepath.Join(s.dir, "foo_1.snap")))
+
+	// the file changed after the information was obtained
+	c.Assert(ioutil.WriteFile(filepath.Join(s.dir, "foo_1.snap"), []byte("changed"), 0644), IsNil)
+	err = s.sto.Download(context.Background(), "foo", target, &info.DownloadInfo, nil, nil, nil)
+	c.Check(err, ErrorMatches, `sha3-384 mismatch for "foo": got .* but expected .*`)
+	c.Check(target, testutil.FileAbsent)
+
+	err = s.sto.Download(context.Background(), "foo", target, &snap.DownloadInfo{DownloadURL: "https://example.com/foo.snap"}, nil, nil, nil)
+	c.Check(err, ErrorMatches, `cannot download "https://example.com/foo.snap": not served by the offline store in ".*"`)
+}
+
+func (s *offlineSuite) TestDownloadStream(c *C) {
+	s.mockStore(c)
+	info, err := s.sto.SnapInfo(context.Background(), store.SnapSpec{Name: "bar"}, nil)
+	c.Assert(err, IsNil)
+
+	r, status, err := s.sto.DownloadStream(context.Background(), "bar", &info.DownloadInfo, 0, nil)
+	c.Assert(err, IsNil)
+	c.Check(status, Equals, 200)
+	data, err := ioutil.ReadAll(r)
+	r.Close()
+	c.Assert(err, IsNil)
+	c.Check(string(data), Matches, "name: bar\n(.|\n)*")
+
+	r, status, err = s.sto.DownloadStream(context.Background(), "bar", &info.DownloadInfo, 6, nil)
+	c.Assert(err, IsNil)
+	c.Check(status, Equals, 206)
+	data, err = ioutil.ReadAll(r)
+	r.Close()
+	c.Assert(err, IsNil)
+	c.Check(string(data), Matches, "bar\n(.|\n)*")
+}
+
+func (s *offlineSuite) TestAssertion(c *C) {
+	s.writeIndex(c)
+	s.writeAssertions(c, "keys.assert", s.storeSigning.StoreAccountKey(""))
+	s.writeAssertions(c, "sets.assert", s.validationSet(c, 1, 0), s.validationSet(c, 2, 0), s.validationSet(c, 2, 1))
+
+	key := s.storeSigning.StoreAccountKey("")
+	a, err := s.sto.Assertion(asserts.AccountKeyType, key.Ref().PrimaryKey, nil)
+	c.Assert(err, IsNil)
+	c.Check(a.Ref().Unique(), Equals, key.Ref().Unique())
+
+	_, err = s.sto.Assertion(asserts.AccountType, []string{"someone"}, nil)
+	c.Check(errors.Is(err, &asserts.NotFoundError{}), Equals, true)
+
+	seqKey := []string{"16", "canonical", "base-set"}
+	a, err = s.sto.SeqFormingAssertion(asserts.ValidationSetType, seqKey, 0, nil)
+	c.Assert(err, IsNil)
+	c.Check(a.(*asserts.ValidationSet).Sequence(), Equals, 2)
+	c.Check(a.Revision(), Equals, 1)
+
+	a, err = s.sto.SeqFormingAssertion(asserts.ValidationSetType, seqKey, 1, nil)
+	c.Assert(err, IsNil)
+	c.Check(a.(*asserts.ValidationSet).Sequence(), Equals, 1)
+
+	_, err = s.sto.SeqFormingAssertion(asserts.ValidationSetType, seqKey, 3, nil)
+	c.Check(errors.Is(err, &asserts.NotFoundError{}), Equals, true)
+}
+
+func (s *offlineSuite) TestContentsLoadedOnlyWhenChanged(c *C) {
+	s.writeIndex(c)
+	key := s.storeSigning.StoreAccountKey("")
+	s.writeAssertions(c, "keys.assert", key)
+
+	_, err := s.sto.Assertion(asserts.AccountKeyType, key.Ref().PrimaryKey, nil)
+	c.Assert(err, IsNil)
+
+	// the assertions are not read again if the file looks unchanged
+	keysFile := filepath.Join(s.dir, "keys.assert")
+	fi, err := os.Stat(keysFile)
+	c.Assert(err, IsNil)
+	c.Assert(ioutil.WriteFile(keysFile, bytes.Repeat([]byte("x"), int(fi.Size())), 0644), IsNil)
+	c.Assert(os.Chtimes(keysFile, fi.ModTime(), fi.ModTime()), IsNil)
+	_, err = s.sto.Assertion(asserts.AccountKeyType, key.Ref().PrimaryKey, nil)
+	c.Assert(err, IsNil)
+
+	// but they are once it changes
+	c.Assert(os.Chtimes(keysFile, fi.ModTime(), fi.ModTime().Add(time.Second)), IsNil)
+	_, err = s.sto.Assertion(asserts.AccountKeyType, key.Ref().PrimaryKey, nil)
+	c.Check(err, ErrorMatches, `cannot decode assertions from "keys.assert": .*`)
+
+	// as is the index
+	c.Assert(os.Remove(keysFile), IsNil)
+	_, err = s.sto.SnapInfo(context.Background(), store.SnapSpec{Name: "foo"}, nil)
+	c.Check(err, Equals, store.ErrSnapNotFound)
+	s.mockStore(c)
+	_, err = s.sto.SnapInfo(context.Background(), store.SnapSpec{Name: "foo"}, nil)
+	c.Check(err, IsNil)
+}
+
+func (s *offlineSuite) TestDigestOnlyComputedWhenNeeded(c *C) {
+	s.mockStore(c)
+	var hashed []string
+	s.AddCleanup(offline.MockSnapFileSHA3_384(func(snapPath string) (string, uint64, error) {
+		hashed = append(hashed, filepath.Base(snapPath))
+		data, err := ioutil.ReadFile(snapPath)
+		if err != nil {
+			return "", 0, err
+		}
+		return fmt.Sprintf("digest-of-%s", data), uint64(len(data)), nil
+	}))
+
+	// results that cannot be downloaded have no digest
+	infos, err := s.sto.Find(context.Background(), &store.Search{}, nil)
+	c.Assert(err, IsNil)
+	c.Assert(infos, HasLen, 2)
+	c.Check(infos[0].Sha3_384, Equals, "")
+	c.Assert(s.sto.WriteCatalogs(context.Background(), ioutil.Discard, &fakeAdder{added: make(map[string][]string)}), IsNil)
+	c.Check(hashed, HasLen, 0)
+
+	info, err := s.sto.SnapInfo(context.Background(), store.SnapSpec{Name: "foo"}, nil)
+	c.Assert(err, IsNil)
+	c.Check(info.Sha3_384, Equals, "digest-of-name: foo\nversion: 1.0\nsummary: the foo snap\napps:\n  foo:\n")
+	c.Check(info.Channels["latest/edge"].Revision, Equals, snap.R(3))
+	c.Check(hashed, DeepEquals, []string{"foo_1.snap"})
+
+	// the digest is reused while the file is unchanged
+	sars, _, err := s.sto.SnapAction(context.Background(), nil, []*store.SnapAction{
+		{Action: "install", InstanceName: "foo"},
+	}, nil, nil, nil)
+	c.Assert(err, IsNil)
+	c.Assert(sars, HasLen, 1)
+	c.Check(sars[0].Sha3_384, Equals, info.Sha3_384)
+	c.Check(hashed, DeepEquals, []string{"foo_1.snap"})
+
+	c.Assert(ioutil.WriteFile(filepath.Join(s.dir, "foo_1.snap"), []byte("name: foo\nversion: 1.1\n"), 0644), IsNil)
+	info, err = s.sto.SnapInfo(context.Background(), store.SnapSpec{Name: "foo"}, nil)
+	c.Assert(err, IsNil)
+	c.Check(info.Sha3_384, Equals, "digest-of-name: foo\nversion: 1.1\n")
+	c.Check(info.Size, Equals, int64(len("name: foo\nversion: 1.1\n")))
+	c.Check(hashed, DeepEquals, []string{"foo_1.snap", "foo_1.snap"})
+}
+
+type fakeAssertQuery struct {
+	toResolve    map[asserts.Grouping][]*asserts.AtRevision
+	toResolveSeq map[asserts.Grouping][]*asserts.AtSequence
+	errors       map[string]error
+}
+
+func (q *fakeAssertQuery) ToResolve() (map[asserts.Grouping][]*asserts.AtRevision, map[asserts.Grouping][]*asserts.AtSequence, error) {
+	return q.toResolve, q.toResolveSeq, nil
+}
+
+func (q *fakeAssertQuery) AddError(e error, ref *asserts.Ref) error {
+	q.errors[ref.Unique()] = e
+	return nil
+}
+
+func (q *fakeAssertQuery) AddSequenceError(e error, atSeq *asserts.AtSequence) error {
+	q.errors[atSeq.Unique()] = e
+	return nil
+}
+
+func (q *fakeAssertQuery) AddGroupingError(e error, grouping asserts.Grouping) error {
+	q.errors[string(grouping)] = e
+	return nil
+}
+
+func (s *offlineSuite) TestSnapActionAssertions(c *C) {
+	s.writeIndex(c)
+	key := s.storeSigning.StoreAccountKey("")
+	s.writeAssertions(c, "keys.assert", key)
+	vs := s.validationSet(c, 2, 1)
+	s.writeAssertions(c, "sets.assert", s.validationSet(c, 1, 0), vs)
+
+	seqKey := []string{"16", "canonical", "base-set"}
+	q := &fakeAssertQuery{
+		toResolve: map[asserts.Grouping][]*asserts.AtRevision{
+			"g1": {
+				{Ref: *key.Ref(), Revision: asserts.RevisionNotKnown},
+				{Ref: asserts.Ref{Type: asserts.AccountType, PrimaryKey: []string{"someone"}}, Revision: asserts.RevisionNotKnown},
+			},
+			// up to date
+			"g2": {{Ref: *key.Ref(), Revision: key.Revision()}},
+		},
+		toResolveSeq: map[asserts.Grouping][]*asserts.AtSequence{
+			"g3": {{Type: asserts.ValidationSetType, SequenceKey: seqKey, Sequence: 1, Revision: 0}},
+			"g4": {{Type: asserts.ValidationSetType, SequenceKey: seqKey, Sequence: 1, Pinned: true, Revision: 0}},
+			"g5": {{Type: asserts.ValidationSetType, SequenceKey: []string{"16", "canonical", "other"}, Revision: asserts.RevisionNotKnown}},
+		},
+		errors: make(map[string]error),
+	}
+	sars, ars, err := s.sto.SnapAction(context.Background(), nil, nil, q, nil, nil)
+	c.Assert(err, IsNil)
+	c.Check(sars, HasLen, 0)
+	c.Assert(ars, HasLen, 2)
+	results := make(map[asserts.Grouping][]string)
+	for _, ar := range ars {
+		results[ar.Grouping] = ar.StreamURLs
+	}
+	c.Check(results, DeepEquals, map[asserts.Grouping][]string{
+		"g1": {key.Ref().Unique()},
+		"g3": {vs.Ref().Unique()},
+	})
+	c.Check(q.errors, HasLen, 2)
+	c.Check(errors.Is(q.errors["account/someone"], &asserts.NotFoundError{}), Equals, true)
+	c.Check(errors.Is(q.errors["validation-set/16/canonical/other"], &asserts.NotFoundError{}), Equals, true)
+
+	db, err := asserts.OpenDatabase(&asserts.DatabaseConfig{
+		Backstore: asserts.NewMemoryBackstore(),
+		Trusted:   s.storeSigning.Trusted,
+	})
+	c.Assert(err, IsNil)
+	b := asserts.NewBatch(nil)
+	c.Assert(s.sto.DownloadAssertions(results["g1"], b, nil), IsNil)
+	c.Assert(s.sto.DownloadAssertions(results["g3"], b, nil), IsNil)
+	c.Assert(b.CommitTo(db, nil), IsNil)
+	_, err = vs.Ref().Resolve(db.Find)
+	c.Check(err, IsNil)
+
+	err = s.sto.DownloadAssertions([]string{"account/someone"}, b, nil)
+	c.Check(err, ErrorMatches, `cannot find assertion "account/someone" in the offline store`)
+}
+
+func (s *offlineSuite) TestUnsupported(c *C) {
+	_, err := s.sto.Buy(nil, nil)
+	c.Check(err, ErrorMatches, "operation not supported by the offline store")
+	_, err = s.sto.CreateCohorts(context.Background(), []string{"foo"})
+	c.Check(err, ErrorMatches, "operation not supported by the offline store")
+	_, _, err = s.sto.LoginUser("user", "pass", "")
+	c.Check(err, ErrorMatches, "operation not supported by the offline store")
+}
+
+func (s *offlineSuite) TestConnectivityCheck(c *C) {
+	status, err := s.sto.ConnectivityCheck()
+	c.Assert(err, IsNil)
+	c.Check(status, DeepEquals, map[string]bool{s.dir: false})
+
+	s.writeIndex(c)
+	status, err = s.sto.ConnectivityCheck()
+	c.Assert(err, IsNil)
+	c.Check(status, DeepEquals, map[string]bool{s.dir: true})
+}
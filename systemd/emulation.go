@@ -124,7 +124,7 @@ func (s *emulation) LogReader(services []string, n int, follow, namespaces bool)
 	return nil, fmt.Errorf("LogReader")
 }
 
-func (s *emulation) EnsureMountUnitFile(snapName, revision, what, where, fstype string) (string, error) {
+func (s *emulation) EnsureMountUnitFile(snapName, revision, what, where, fstype string, dmVerity *DmVerityOptions) (string, error) {
 	if osutil.IsDirectory(what) {
 		return "", fmt.Errorf("bind-mounted directory is not supported in emulation mode")
 	}
@@ -142,6 +142,7 @@ func (s *emulation) EnsureMountUnitFile(snapName, revision, what, where, fstype
 		Where:    where,
 		Fstype:   fstype,
 		Options:  mountUnitOptions,
+		DmVerity: dmVerity,
 	})
 	if err != nil {
 		return "", err
@@ -152,6 +153,12 @@ func (s *emulation) EnsureMountUnitFile(snapName, revision, what, where, fstype
 	}
 
 	hostFsType, actualOptions := hostFsTypeAndMountOptions(fstype)
+	if dmVerity != nil {
+		if hostFsType != fstype {
+			return "", fmt.Errorf("cannot mount %s with dm-verity using %s in preseed mode", what, hostFsType)
+		}
+		actualOptions = append(actualOptions, dmVerity.mountOptions()...)
+	}
 	if modified == mountUpdated {
 		actualOptions = append(actualOptions, "remount")
 	}
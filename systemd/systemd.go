@@ -312,6 +312,26 @@ type MountUnitOptions struct {
 	Fstype   string
 	Options  []string
 	Origin   string
+	// DmVerity, if set, makes the mount verify the data with dm-verity
+	DmVerity *DmVerityOptions
+}
+
+// DmVerityOptions holds the dm-verity parameters for a mount, the hash data
+// are looked up in HashDevice at HashOffset and verified against RootHash.
+type DmVerityOptions struct {
+	RootHash   string
+	HashDevice string
+	HashOffset uint64
+}
+
+// mountOptions returns the options understood by mount(8) to set up
+// dm-verity for the mount.
+func (o *DmVerityOptions) mountOptions() []string {
+	return []string{
+		"verity.roothash=" + o.RootHash,
+		"verity.hashdevice=" + o.HashDevice,
+		fmt.Sprintf("verity.hashoffset=%d", o.HashOffset),
+	}
 }
 
 // Backend identifies the implementation backend in use by a Systemd instance.
@@ -387,8 +407,9 @@ type Systemd interface {
 	// If namespaces is set to true, the log reader will include journal namespace
 	// logs, and is required to get logs for services which are in journal namespaces.
 	LogReader(services []string, n int, follow, namespaces bool) (io.ReadCloser, error)
-	// EnsureMountUnitFile adds/enables/starts a mount unit.
-	EnsureMountUnitFile(name, revision, what, where, fstype string) (string, error)
+	// EnsureMountUnitFile adds/enables/starts a mount unit, verified
+	// with dm-verity if dmVerity is not nil.
+	EnsureMountUnitFile(name, revision, what, where, fstype string, dmVerity *DmVerityOptions) (string, error)
 	// EnsureMountUnitFileWithOptions adds/enables/starts a mount unit with options.
 	EnsureMountUnitFileWithOptions(unitOptions *MountUnitOptions) (string, error)
 	// RemoveMountUnitFile unmounts/stops/disables/removes a mount unit.
@@ -1408,6 +1429,11 @@ func ensureMountUnitFile(u *MountUnitOptions) (mountUnitName string, modified mo
 	mu := MountUnitPathWithLifetime(u.Lifetime, u.Where)
 	var unitContent bytes.Buffer
 
+	if u.DmVerity != nil {
+		withVerity := *u
+		withVerity.Options = append(append([]string(nil), u.Options...), u.DmVerity.mountOptions()...)
+		u = &withVerity
+	}
 	if err := parsedMountUnitTemplate.Execute(&unitContent, &u); err != nil {
 		return "", mountUnchanged, fmt.Errorf("cannot generate mount unit: %v", err)
 	}
@@ -1462,12 +1488,15 @@ func hostFsTypeAndMountOptions(fstype string) (hostFsType string, options []stri
 	return hostFsType, options
 }
 
-func (s *systemd) EnsureMountUnitFile(snapName, revision, what, where, fstype string) (string, error) {
+func (s *systemd) EnsureMountUnitFile(snapName, revision, what, where, fstype string, dmVerity *DmVerityOptions) (string, error) {
 	hostFsType, options := hostFsTypeAndMountOptions(fstype)
 	if osutil.IsDirectory(what) {
 		options = append(options, "bind")
 		hostFsType = "none"
 	}
+	if dmVerity != nil && hostFsType != fstype {
+		return "", fmt.Errorf("cannot mount %s with dm-verity using %s", what, hostFsType)
+	}
 	return s.EnsureMountUnitFileWithOptions(&MountUnitOptions{
 		Lifetime: Persistent,
 		SnapName: snapName,
@@ -1476,6 +1505,7 @@ func (s *systemd) EnsureMountUnitFile(snapName, revision, what, where, fstype st
 		Where:    where,
 		Fstype:   hostFsType,
 		Options:  options,
+		DmVerity: dmVerity,
 	})
 }
 